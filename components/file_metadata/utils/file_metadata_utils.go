@@ -13,6 +13,14 @@ func GetStatusBadgeClass(status string) string {
 		return "bg-yellow-100 text-yellow-800 dark:bg-yellow-900 dark:text-yellow-300"
 	case "archived_and_deleted":
 		return "bg-orange-100 text-orange-800 dark:bg-orange-900 dark:text-orange-300"
+	case "skipped":
+		return "bg-purple-100 text-purple-800 dark:bg-purple-900 dark:text-purple-300"
+	case "retention_deleted":
+		return "bg-pink-100 text-pink-800 dark:bg-pink-900 dark:text-pink-300"
+	case "duplicate":
+		return "bg-indigo-100 text-indigo-800 dark:bg-indigo-900 dark:text-indigo-300"
+	case "quarantined":
+		return "bg-rose-100 text-rose-800 dark:bg-rose-900 dark:text-rose-300"
 	case "error":
 		return "bg-red-100 text-red-800 dark:bg-red-900 dark:text-red-300"
 	default:
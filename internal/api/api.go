@@ -289,6 +289,8 @@ func handleUpdateConfig(database *db.DB) gin.HandlerFunc {
 		updatedConfig.ID = existingConfig.ID
 		updatedConfig.CreatedBy = existingConfig.CreatedBy
 		updatedConfig.CreatedAt = existingConfig.CreatedAt
+		updatedConfig.SourceOAuthToken = existingConfig.SourceOAuthToken
+		updatedConfig.DestOAuthToken = existingConfig.DestOAuthToken
 
 		if err := database.UpdateTransferConfig(&updatedConfig); err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update config"})
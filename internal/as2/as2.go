@@ -0,0 +1,506 @@
+// Package as2 sends and receives AS2 (RFC 4130) messages: payloads signed, encrypted and
+// compressed with S/MIME, and the message disposition notifications (MDNs) that confirm
+// their receipt, returned in the HTTP response or posted back later.
+package as2
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Version is the AS2 version sent with messages and MDNs
+const Version = "1.2"
+
+// UserAgent identifies GoMFT in MDNs
+const UserAgent = "GoMFT AS2"
+
+// Signing algorithms, the digests of signatures and message integrity checks (MICs)
+const (
+	SigningSHA1   = "sha1"
+	SigningSHA256 = "sha256"
+	SigningSHA384 = "sha384"
+	SigningSHA512 = "sha512"
+)
+
+// Encryption algorithms of the message content
+const (
+	EncryptionAES128 = "aes128-cbc"
+	EncryptionAES192 = "aes192-cbc"
+	EncryptionAES256 = "aes256-cbc"
+	EncryptionDES3   = "3des-cbc"
+)
+
+// MDN modes: no receipt, a receipt in the HTTP response, or a receipt posted back later
+const (
+	MDNNone  = ""
+	MDNSync  = "sync"
+	MDNAsync = "async"
+)
+
+// DefaultContentType is the content type of payloads when a partner does not set one
+const DefaultContentType = "application/octet-stream"
+
+// DefaultMaxMessageSize bounds the inbound messages read into memory, in bytes, unless
+// AS2_MAX_MESSAGE_SIZE sets another limit
+const DefaultMaxMessageSize = 100 << 20
+
+// maxMDNSize bounds the MDNs read from responses, in bytes
+const maxMDNSize = 1 << 20
+
+// signingHashes maps the signing algorithms to their hash
+var signingHashes = map[string]crypto.Hash{
+	SigningSHA1:   crypto.SHA1,
+	SigningSHA256: crypto.SHA256,
+	SigningSHA384: crypto.SHA384,
+	SigningSHA512: crypto.SHA512,
+}
+
+// Identity is the local side of a partnership: the AS2 ID GoMFT uses with the partner
+// and the certificate and key it signs and decrypts with
+type Identity struct {
+	AS2ID       string
+	Certificate *x509.Certificate
+	PrivateKey  *rsa.PrivateKey
+}
+
+// Partner is the remote side of a partnership and how messages are exchanged with it
+type Partner struct {
+	AS2ID       string
+	URL         string            // Endpoint outbound messages are posted to
+	Certificate *x509.Certificate // Verifies the partner's signatures and encrypts for it
+
+	ContentType         string
+	Sign                bool
+	SigningAlgorithm    string
+	Encrypt             bool
+	EncryptionAlgorithm string
+	Compress            bool
+
+	MDN         string // MDN mode of outbound messages
+	SignedMDN   bool   // Request signed MDNs
+	AsyncMDNURL string // URL the partner posts asynchronous MDNs to
+
+	RequireSigned    bool // Reject inbound messages that are not signed
+	RequireEncrypted bool // Reject inbound messages that are not encrypted
+}
+
+// Message is an AS2 message or MDN ready to be posted
+type Message struct {
+	MessageID    string
+	Header       http.Header
+	Body         []byte
+	MIC          string // Base64 MIC the receipt must return, for messages
+	MICAlgorithm string
+	Signed       bool
+	Encrypted    bool
+	Compressed   bool
+	Disposition  string // Disposition reported, for MDNs
+}
+
+// ValidSigningAlgorithm reports whether a signing algorithm is supported
+func ValidSigningAlgorithm(algorithm string) bool {
+	_, ok := signingHashes[algorithm]
+	return ok
+}
+
+// ValidEncryptionAlgorithm reports whether an encryption algorithm is supported
+func ValidEncryptionAlgorithm(algorithm string) bool {
+	_, ok := contentCiphers[algorithm]
+	return ok
+}
+
+// ParseCertificate parses a PEM or DER encoded X.509 certificate with an RSA key
+func ParseCertificate(data []byte) (*x509.Certificate, error) {
+	if block, _ := pem.Decode(data); block != nil {
+		if block.Type != "CERTIFICATE" {
+			return nil, fmt.Errorf("expected a CERTIFICATE PEM block, got %s", block.Type)
+		}
+		data = block.Bytes
+	}
+	cert, err := x509.ParseCertificate(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid certificate: %v", err)
+	}
+	if _, ok := cert.PublicKey.(*rsa.PublicKey); !ok {
+		return nil, errors.New("only certificates with RSA keys are supported")
+	}
+	return cert, nil
+}
+
+// ParsePrivateKey parses a PEM encoded RSA private key in PKCS#1 or PKCS#8 format
+func ParsePrivateKey(data []byte) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("private key is not PEM encoded")
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("invalid private key: %v", err)
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("only RSA private keys are supported")
+	}
+	return key, nil
+}
+
+// GenerateCertificate creates an RSA key and a self-signed certificate for an AS2 ID,
+// valid for the given number of years. Both are returned PEM encoded.
+func GenerateCertificate(as2ID string, bits, years int) (string, string, error) {
+	key, err := rsa.GenerateKey(rand.Reader, bits)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to generate key: %v", err)
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 127))
+	if err != nil {
+		return "", "", err
+	}
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: as2ID, Organization: []string{"GoMFT"}},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.AddDate(years, 0, 0),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment | x509.KeyUsageDataEncipherment,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageEmailProtection},
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to create certificate: %v", err)
+	}
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	return string(certPEM), string(keyPEM), nil
+}
+
+// Fingerprint returns the SHA-256 fingerprint of a certificate as colon separated hex
+func Fingerprint(cert *x509.Certificate) string {
+	sum := crypto.SHA256.New()
+	sum.Write(cert.Raw)
+	hex := fmt.Sprintf("%X", sum.Sum(nil))
+	parts := make([]string, 0, len(hex)/2)
+	for i := 0; i < len(hex); i += 2 {
+		parts = append(parts, hex[i:i+2])
+	}
+	return strings.Join(parts, ":")
+}
+
+// Validate checks that a partnership has what its options need
+func (p *Partner) Validate(local Identity) error {
+	if p.AS2ID == "" || local.AS2ID == "" {
+		return errors.New("both AS2 IDs are required")
+	}
+	if err := validateAS2ID(p.AS2ID); err != nil {
+		return err
+	}
+	if err := validateAS2ID(local.AS2ID); err != nil {
+		return err
+	}
+	if p.URL != "" {
+		if u, err := url.Parse(p.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("partner URL %q must be an http or https URL", p.URL)
+		}
+	}
+	if p.AsyncMDNURL != "" {
+		if u, err := url.Parse(p.AsyncMDNURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("asynchronous MDN URL %q must be an http or https URL", p.AsyncMDNURL)
+		}
+	}
+	if p.Sign || p.SigningAlgorithm != "" {
+		if !ValidSigningAlgorithm(p.SigningAlgorithm) {
+			return fmt.Errorf("unsupported signing algorithm %q", p.SigningAlgorithm)
+		}
+	}
+	if p.Encrypt && !ValidEncryptionAlgorithm(p.EncryptionAlgorithm) {
+		return fmt.Errorf("unsupported encryption algorithm %q", p.EncryptionAlgorithm)
+	}
+	switch p.MDN {
+	case MDNNone, MDNSync:
+	case MDNAsync:
+		if p.AsyncMDNURL == "" {
+			return errors.New("asynchronous MDNs need the URL of this GoMFT instance's AS2 endpoint")
+		}
+	default:
+		return fmt.Errorf("unknown MDN mode %q", p.MDN)
+	}
+	if (p.Encrypt || p.RequireSigned) && p.Certificate == nil {
+		return errors.New("the partner certificate is required to encrypt or verify signatures")
+	}
+	if (p.Sign || p.SignedMDN || p.RequireEncrypted) && (local.Certificate == nil || local.PrivateKey == nil) {
+		return errors.New("a local certificate and private key are required to sign or decrypt")
+	}
+	return nil
+}
+
+// validateAS2ID checks an AS2 ID is printable ASCII of at most 128 characters
+func validateAS2ID(id string) error {
+	if len(id) > 128 {
+		return fmt.Errorf("AS2 ID %q is longer than 128 characters", id)
+	}
+	for _, r := range id {
+		if r < 0x20 || r > 0x7e {
+			return fmt.Errorf("AS2 ID %q must be printable ASCII", id)
+		}
+	}
+	return nil
+}
+
+// quoteAS2ID quotes an AS2 ID for the AS2-From and AS2-To headers when it has spaces
+func quoteAS2ID(id string) string {
+	if strings.ContainsAny(id, " \t\"\\") {
+		return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(id) + `"`
+	}
+	return id
+}
+
+// unquoteAS2ID reads an AS2-From or AS2-To header
+func unquoteAS2ID(value string) string {
+	value = strings.TrimSpace(value)
+	if len(value) >= 2 && value[0] == '"' && value[len(value)-1] == '"' {
+		return strings.NewReplacer(`\\`, `\`, `\"`, `"`).Replace(value[1 : len(value)-1])
+	}
+	return value
+}
+
+// newMessageID returns a unique message ID in angle brackets for an AS2 ID
+func newMessageID(as2ID string) string {
+	host := strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '.' {
+			return r
+		}
+		return '-'
+	}, as2ID)
+	return "<" + uuid.New().String() + "@" + host + ">"
+}
+
+// Build creates the AS2 message of a file for a partner. The payload is compressed, then
+// signed, then encrypted, as far as the partner options ask for it.
+func Build(local Identity, partner Partner, fileName string, payload []byte) (*Message, error) {
+	if err := partner.Validate(local); err != nil {
+		return nil, err
+	}
+	contentType := partner.ContentType
+	if contentType == "" {
+		contentType = DefaultContentType
+	}
+	msg := &Message{MessageID: newMessageID(local.AS2ID)}
+
+	entity := newEntity([][2]string{
+		{"Content-Type", contentType},
+		{"Content-Transfer-Encoding", "binary"},
+		{"Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": fileName})},
+	}, payload)
+	micEntity := payload
+
+	if partner.Compress {
+		compressed, err := compress(entity)
+		if err != nil {
+			return nil, fmt.Errorf("failed to compress: %v", err)
+		}
+		entity = smimeEntity("compressed-data", "smime.p7z", compressed)
+		micEntity = compressed
+		msg.Compressed = true
+	}
+
+	micAlgorithm := partner.SigningAlgorithm
+	if micAlgorithm == "" {
+		micAlgorithm = SigningSHA256
+	}
+	hash := signingHashes[micAlgorithm]
+
+	if partner.Sign {
+		signed, err := signEntity(entity, local, hash)
+		if err != nil {
+			return nil, err
+		}
+		micEntity = entity
+		entity = signed
+		msg.Signed = true
+	}
+
+	if partner.Encrypt {
+		if !partner.Sign {
+			micEntity = entity
+		}
+		encrypted, err := encrypt(entity, partner.Certificate, partner.EncryptionAlgorithm)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encrypt: %v", err)
+		}
+		entity = smimeEntity("enveloped-data", "smime.p7m", encrypted)
+		msg.Encrypted = true
+	}
+
+	msg.MIC = computeMIC(micEntity, hash)
+	msg.MICAlgorithm = micAlgorithm
+
+	// The headers of the outermost entity become HTTP headers
+	header, body, err := splitEntity(entity)
+	if err != nil {
+		return nil, err
+	}
+	msg.Body = body
+	msg.Header = http.Header{}
+	msg.Header.Set("Content-Type", header.Get("Content-Type"))
+	if disposition := header.Get("Content-Disposition"); disposition != "" {
+		msg.Header.Set("Content-Disposition", disposition)
+	}
+	msg.Header.Set("MIME-Version", "1.0")
+	msg.Header.Set("AS2-Version", Version)
+	msg.Header.Set("AS2-From", quoteAS2ID(local.AS2ID))
+	msg.Header.Set("AS2-To", quoteAS2ID(partner.AS2ID))
+	msg.Header.Set("Message-ID", msg.MessageID)
+	msg.Header.Set("Subject", "AS2 message "+fileName)
+	msg.Header.Set("Date", time.Now().UTC().Format(time.RFC1123Z))
+	msg.Header.Set("User-Agent", UserAgent)
+	if partner.MDN != MDNNone {
+		msg.Header.Set("Disposition-Notification-To", local.AS2ID)
+		options := "signed-receipt-micalg=optional, " + micAlgorithmName(micAlgorithm)
+		if partner.SignedMDN {
+			options = "signed-receipt-protocol=optional, pkcs7-signature; " + options
+		}
+		msg.Header.Set("Disposition-Notification-Options", options)
+		if partner.MDN == MDNAsync {
+			msg.Header.Set("Receipt-Delivery-Option", partner.AsyncMDNURL)
+		}
+	}
+	return msg, nil
+}
+
+// signEntity wraps a MIME entity in a multipart/signed entity with a detached signature
+func signEntity(entity []byte, local Identity, hash crypto.Hash) ([]byte, error) {
+	signature, err := signDetached(entity, local.Certificate, local.PrivateKey, hash)
+	if err != nil {
+		return nil, err
+	}
+	boundary := "----=_Part_" + strings.ReplaceAll(uuid.New().String(), "-", "")
+	var body bytes.Buffer
+	body.WriteString("--" + boundary + "\r\n")
+	body.Write(entity)
+	body.WriteString("\r\n--" + boundary + "\r\n")
+	body.WriteString("Content-Type: application/pkcs7-signature; name=smime.p7s\r\n")
+	body.WriteString("Content-Transfer-Encoding: base64\r\n")
+	body.WriteString("Content-Disposition: attachment; filename=smime.p7s\r\n\r\n")
+	body.WriteString(wrapBase64(signature))
+	body.WriteString("\r\n--" + boundary + "--\r\n")
+
+	contentType := mime.FormatMediaType("multipart/signed", map[string]string{
+		"protocol": "application/pkcs7-signature",
+		"micalg":   micAlgorithmName(hashName(hash)),
+		"boundary": boundary,
+	})
+	return newEntity([][2]string{{"Content-Type", contentType}}, body.Bytes()), nil
+}
+
+// smimeEntity returns an application/pkcs7-mime entity holding CMS content
+func smimeEntity(smimeType, fileName string, content []byte) []byte {
+	contentType := mime.FormatMediaType("application/pkcs7-mime", map[string]string{
+		"smime-type": smimeType,
+		"name":       fileName,
+	})
+	return newEntity([][2]string{
+		{"Content-Type", contentType},
+		{"Content-Transfer-Encoding", "binary"},
+		{"Content-Disposition", "attachment; filename=" + fileName},
+	}, content)
+}
+
+// hashName returns the signing algorithm of a hash
+func hashName(hash crypto.Hash) string {
+	for name, candidate := range signingHashes {
+		if candidate == hash {
+			return name
+		}
+	}
+	return SigningSHA256
+}
+
+// micAlgorithmName returns the name of a signing algorithm in micalg parameters, such as
+// sha-256 (RFC 5751)
+func micAlgorithmName(algorithm string) string {
+	if algorithm == SigningSHA1 {
+		return "sha1"
+	}
+	return "sha-" + strings.TrimPrefix(algorithm, "sha")
+}
+
+// parseMICAlgorithm returns the signing algorithm of a micalg name, accepting both the
+// sha-256 and sha256 spellings
+func parseMICAlgorithm(name string) (string, bool) {
+	algorithm := strings.ReplaceAll(strings.ToLower(strings.TrimSpace(name)), "-", "")
+	_, ok := signingHashes[algorithm]
+	return algorithm, ok
+}
+
+// computeMIC returns the base64 digest of the MIC content
+func computeMIC(content []byte, hash crypto.Hash) string {
+	h := hash.New()
+	h.Write(content)
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+// wrapBase64 encodes data as base64 in lines of 76 characters
+func wrapBase64(data []byte) string {
+	encoded := base64.StdEncoding.EncodeToString(data)
+	var lines []string
+	for len(encoded) > 76 {
+		lines = append(lines, encoded[:76])
+		encoded = encoded[76:]
+	}
+	lines = append(lines, encoded)
+	return strings.Join(lines, "\r\n")
+}
+
+// Send posts a message to the partner. With synchronous MDNs the MDN in the response is
+// parsed and checked, otherwise the returned MDN is nil.
+func Send(ctx context.Context, client *http.Client, partner Partner, msg *Message) (*MDN, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, partner.URL, bytes.NewReader(msg.Body))
+	if err != nil {
+		return nil, fmt.Errorf("invalid partner URL: %v", err)
+	}
+	req.Header = msg.Header.Clone()
+	req.ContentLength = int64(len(msg.Body))
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to post AS2 message: %v", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxMDNSize))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read AS2 response: %v", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, fmt.Errorf("partner rejected the AS2 message: %s", resp.Status)
+	}
+	if partner.MDN != MDNSync {
+		return nil, nil
+	}
+
+	mdn, err := ParseMDN(resp.Header, body, partner.Certificate, partner.SignedMDN)
+	if err != nil {
+		return nil, err
+	}
+	return mdn, CheckMDN(mdn, msg.MessageID, msg.MIC, msg.MICAlgorithm)
+}
@@ -0,0 +1,436 @@
+package as2
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testPartners returns the partnerships of two identities with each other: how the first
+// sees the second and how the second sees the first
+func testPartners(t *testing.T, options Partner) (Identity, Partner, Identity, Partner) {
+	t.Helper()
+	a, b := testIdentity(t, 0), testIdentity(t, 1)
+	aSeesB := options
+	aSeesB.AS2ID, aSeesB.Certificate = b.AS2ID, b.Certificate
+	bSeesA := Partner{AS2ID: a.AS2ID, Certificate: a.Certificate}
+	return a, aSeesB, b, bSeesA
+}
+
+func TestBuildOpen(t *testing.T) {
+	tests := []struct {
+		name    string
+		options Partner
+	}{
+		{"plain", Partner{MDN: MDNSync}},
+		{"compressed", Partner{Compress: true, MDN: MDNSync}},
+		{"signed", Partner{Sign: true, SigningAlgorithm: SigningSHA1, MDN: MDNSync}},
+		{"encrypted", Partner{Encrypt: true, EncryptionAlgorithm: EncryptionDES3, MDN: MDNSync}},
+		{"compressed and encrypted", Partner{Compress: true, Encrypt: true, EncryptionAlgorithm: EncryptionAES128, MDN: MDNSync}},
+		{"signed and encrypted", Partner{Sign: true, SigningAlgorithm: SigningSHA256, Encrypt: true, EncryptionAlgorithm: EncryptionAES256, MDN: MDNSync, SignedMDN: true}},
+		{"all", Partner{Compress: true, Sign: true, SigningAlgorithm: SigningSHA512, Encrypt: true, EncryptionAlgorithm: EncryptionAES192, MDN: MDNSync}},
+	}
+	payload := []byte("ISA*00*          *00*          *ZZ*SENDER         *ZZ*RECEIVER       ~\n")
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, aSeesB, b, bSeesA := testPartners(t, tt.options)
+			aSeesB.ContentType = "application/edi-x12"
+			msg, err := Build(a, aSeesB, "orders 850.edi", payload)
+			if err != nil {
+				t.Fatalf("Build() error = %v", err)
+			}
+			if msg.Signed != tt.options.Sign || msg.Encrypted != tt.options.Encrypt || msg.Compressed != tt.options.Compress {
+				t.Errorf("Build() signed, encrypted, compressed = %v, %v, %v", msg.Signed, msg.Encrypted, msg.Compressed)
+			}
+			if tt.options.Encrypt && bytes.Contains(msg.Body, payload[:20]) {
+				t.Error("Build() left the encrypted payload in clear")
+			}
+
+			req, err := ParseRequest(msg.Header)
+			if err != nil {
+				t.Fatalf("ParseRequest() error = %v", err)
+			}
+			if req.From != a.AS2ID || req.To != b.AS2ID || req.MessageID != msg.MessageID || req.MDN != MDNSync || req.SignedReceipt != tt.options.SignedMDN {
+				t.Errorf("ParseRequest() = %+v", req)
+			}
+			got, err := req.Open(msg.Body, b, bSeesA)
+			if err != nil {
+				t.Fatalf("Open() error = %v", err)
+			}
+			if !bytes.Equal(got.Data, payload) || got.FileName != "orders 850.edi" || got.ContentType != "application/edi-x12" {
+				t.Errorf("Open() = %q, %q, %q", got.Data, got.FileName, got.ContentType)
+			}
+			if got.Signed != msg.Signed || got.Encrypted != msg.Encrypted || got.Compressed != msg.Compressed {
+				t.Errorf("Open() signed, encrypted, compressed = %v, %v, %v", got.Signed, got.Encrypted, got.Compressed)
+			}
+			if got.MIC != msg.MIC || got.MICAlgorithm != msg.MICAlgorithm {
+				t.Errorf("Open() MIC = %s (%s), sender computed %s (%s)", got.MIC, got.MICAlgorithm, msg.MIC, msg.MICAlgorithm)
+			}
+
+			mdn, err := BuildMDN(b, req, got, nil)
+			if err != nil {
+				t.Fatalf("BuildMDN() error = %v", err)
+			}
+			if !IsMDN(mdn.Header, mdn.Body) || IsMDN(msg.Header, msg.Body) {
+				t.Error("IsMDN() does not tell the MDN from the message")
+			}
+			parsed, err := ParseMDN(mdn.Header, mdn.Body, b.Certificate, tt.options.SignedMDN)
+			if err != nil {
+				t.Fatalf("ParseMDN() error = %v", err)
+			}
+			if parsed.Signed != tt.options.SignedMDN {
+				t.Errorf("ParseMDN() signed = %v", parsed.Signed)
+			}
+			if err := CheckMDN(parsed, msg.MessageID, msg.MIC, msg.MICAlgorithm); err != nil {
+				t.Errorf("CheckMDN() error = %v", err)
+			}
+		})
+	}
+}
+
+func TestOpenFailures(t *testing.T) {
+	payload := []byte("UNA:+.? 'UNB+UNOC:3+A+B'")
+	tests := []struct {
+		name     string
+		options  Partner
+		receiver func(partner *Partner, local *Identity)
+		tamper   func(msg *Message)
+		want     string
+	}{
+		{
+			"unknown signer",
+			Partner{Sign: true, SigningAlgorithm: SigningSHA256},
+			func(partner *Partner, local *Identity) { partner.Certificate = local.Certificate },
+			nil,
+			DispositionAuthenticationFailed,
+		},
+		{
+			"changed content",
+			Partner{Sign: true, SigningAlgorithm: SigningSHA256},
+			nil,
+			func(msg *Message) { msg.Body = bytes.Replace(msg.Body, []byte("UNB"), []byte("UNX"), 1) },
+			DispositionIntegrityCheckFailed,
+		},
+		{
+			"encrypted for another key",
+			Partner{Encrypt: true, EncryptionAlgorithm: EncryptionAES256},
+			func(partner *Partner, local *Identity) { *local = testIdentity(t, 0) },
+			nil,
+			DispositionDecryptionFailed,
+		},
+		{
+			"unsigned when signatures are required",
+			Partner{Encrypt: true, EncryptionAlgorithm: EncryptionAES256},
+			func(partner *Partner, local *Identity) { partner.RequireSigned = true },
+			nil,
+			DispositionInsufficientSecurity,
+		},
+		{
+			"unencrypted when encryption is required",
+			Partner{Sign: true, SigningAlgorithm: SigningSHA256},
+			func(partner *Partner, local *Identity) { partner.RequireEncrypted = true },
+			nil,
+			DispositionInsufficientSecurity,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, aSeesB, b, bSeesA := testPartners(t, tt.options)
+			aSeesB.MDN = MDNSync
+			msg, err := Build(a, aSeesB, "invoice.edi", payload)
+			if err != nil {
+				t.Fatalf("Build() error = %v", err)
+			}
+			if tt.tamper != nil {
+				tt.tamper(msg)
+			}
+			if tt.receiver != nil {
+				tt.receiver(&bSeesA, &b)
+			}
+			req, err := ParseRequest(msg.Header)
+			if err != nil {
+				t.Fatalf("ParseRequest() error = %v", err)
+			}
+			_, err = req.Open(msg.Body, b, bSeesA)
+			var dispositionErr *DispositionError
+			if !errors.As(err, &dispositionErr) || dispositionErr.Modifier != tt.want {
+				t.Fatalf("Open() error = %v, want %s", err, tt.want)
+			}
+			signatureFailure := tt.want == DispositionAuthenticationFailed || tt.want == DispositionIntegrityCheckFailed
+			if IsSignatureFailure(err) != signatureFailure {
+				t.Errorf("IsSignatureFailure() = %v, want %v", !signatureFailure, signatureFailure)
+			}
+
+			// The failure is reported back in an error disposition without a MIC
+			mdn, err := BuildMDN(b, req, nil, err)
+			if err != nil {
+				t.Fatalf("BuildMDN() error = %v", err)
+			}
+			parsed, err := ParseMDN(mdn.Header, mdn.Body, nil, false)
+			if err != nil {
+				t.Fatalf("ParseMDN() error = %v", err)
+			}
+			if parsed.Processed() || !strings.HasSuffix(parsed.Disposition, "processed/error: "+tt.want) {
+				t.Errorf("ParseMDN() disposition = %q", parsed.Disposition)
+			}
+			if err := CheckMDN(parsed, msg.MessageID, msg.MIC, msg.MICAlgorithm); err == nil {
+				t.Error("CheckMDN() of an error disposition error = nil")
+			}
+		})
+	}
+}
+
+func TestCheckMDN(t *testing.T) {
+	mdn := &MDN{
+		OriginalMessageID: "<1@a>",
+		Disposition:       "automatic-action/MDN-sent-automatically; processed",
+		MIC:               "abc=",
+		MICAlgorithm:      SigningSHA256,
+	}
+	if err := CheckMDN(mdn, "<1@a>", "abc=", SigningSHA256); err != nil {
+		t.Errorf("CheckMDN() error = %v", err)
+	}
+	if err := CheckMDN(mdn, "<2@a>", "abc=", SigningSHA256); err == nil || !strings.Contains(err.Error(), "not <2@a>") {
+		t.Errorf("CheckMDN() of another message error = %v", err)
+	}
+	if err := CheckMDN(mdn, "<1@a>", "xyz=", SigningSHA256); err == nil || !strings.Contains(err.Error(), "MIC mismatch") {
+		t.Errorf("CheckMDN() of another MIC error = %v", err)
+	}
+	warning := *mdn
+	warning.Disposition = "automatic-action/MDN-sent-automatically; processed/warning: duplicate-document"
+	if err := CheckMDN(&warning, "<1@a>", "abc=", SigningSHA256); err != nil {
+		t.Errorf("CheckMDN() of a warning error = %v", err)
+	}
+}
+
+func TestCheckMDNURL(t *testing.T) {
+	partner := Partner{AS2ID: "B", URL: "https://as2.b.example:4443/as2"}
+	tests := []struct {
+		name    string
+		url     string
+		wantErr string
+	}{
+		{"partner host", "https://as2.b.example/mdn", ""},
+		{"partner host in other case", "http://AS2.B.example:8080/mdn", ""},
+		{"other host", "http://169.254.169.254/latest/meta-data", "not on the host of the partner URL"},
+		{"subdomain", "https://as2.b.example.evil.example/mdn", "not on the host of the partner URL"},
+		{"mailto", "mailto:as2@b.example", "http or https URL"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := partner.CheckMDNURL(tt.url)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("CheckMDNURL() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("CheckMDNURL() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+
+	if err := (&Partner{AS2ID: "B"}).CheckMDNURL("https://as2.b.example/mdn"); err == nil {
+		t.Error("CheckMDNURL() without a partner URL succeeded")
+	}
+}
+
+func TestPartnerValidate(t *testing.T) {
+	a, b := testIdentity(t, 0), testIdentity(t, 1)
+	tests := []struct {
+		name    string
+		partner Partner
+		local   Identity
+		wantErr string
+	}{
+		{"plain", Partner{AS2ID: "B", URL: "http://b.example/as2"}, Identity{AS2ID: "A"}, ""},
+		{"signed and encrypted", Partner{AS2ID: "B", Certificate: b.Certificate, Sign: true, SigningAlgorithm: SigningSHA256, Encrypt: true, EncryptionAlgorithm: EncryptionAES128}, a, ""},
+		{"missing IDs", Partner{}, Identity{AS2ID: "A"}, "both AS2 IDs"},
+		{"long ID", Partner{AS2ID: strings.Repeat("B", 129)}, Identity{AS2ID: "A"}, "longer than 128"},
+		{"bad URL", Partner{AS2ID: "B", URL: "ftp://b.example"}, Identity{AS2ID: "A"}, "http or https URL"},
+		{"unknown signing algorithm", Partner{AS2ID: "B", Sign: true, SigningAlgorithm: "md5"}, a, "unsupported signing algorithm"},
+		{"unknown encryption algorithm", Partner{AS2ID: "B", Certificate: b.Certificate, Encrypt: true, EncryptionAlgorithm: "rc2-40"}, a, "unsupported encryption algorithm"},
+		{"encrypt without certificate", Partner{AS2ID: "B", Encrypt: true, EncryptionAlgorithm: EncryptionAES128}, a, "partner certificate is required"},
+		{"sign without key", Partner{AS2ID: "B", Sign: true, SigningAlgorithm: SigningSHA256}, Identity{AS2ID: "A"}, "local certificate and private key"},
+		{"async without URL", Partner{AS2ID: "B", MDN: MDNAsync}, Identity{AS2ID: "A"}, "asynchronous MDNs need"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.partner.Validate(tt.local)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Validate() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Validate() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// station is a minimal AS2 endpoint built like the GoMFT one: it opens inbound messages,
+// answers with sync or async MDNs, and matches async MDNs to the messages it sent
+type station struct {
+	local   Identity
+	partner Partner
+	server  *httptest.Server
+
+	mu       sync.Mutex
+	received map[string][]byte   // Payloads by file name
+	sent     map[string]*Message // Outbound messages by message ID
+	receipts chan *MDN           // Async MDNs matched to a sent message
+	mdnErrs  chan error
+}
+
+func newStation(t *testing.T, local Identity, partner Partner) *station {
+	s := &station{
+		local:    local,
+		partner:  partner,
+		received: map[string][]byte{},
+		sent:     map[string]*Message{},
+		receipts: make(chan *MDN, 1),
+		mdnErrs:  make(chan error, 1),
+	}
+	s.server = httptest.NewServer(http.HandlerFunc(s.handle))
+	t.Cleanup(s.server.Close)
+	return s
+}
+
+func (s *station) handle(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	if IsMDN(r.Header, body) {
+		mdn, err := ParseMDN(r.Header, body, s.partner.Certificate, s.partner.SignedMDN)
+		if err == nil {
+			s.mu.Lock()
+			msg := s.sent[mdn.OriginalMessageID]
+			s.mu.Unlock()
+			if msg == nil {
+				err = errors.New("MDN for an unknown message")
+			} else {
+				err = CheckMDN(mdn, msg.MessageID, msg.MIC, msg.MICAlgorithm)
+			}
+		}
+		if err != nil {
+			s.mdnErrs <- err
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		s.receipts <- mdn
+		return
+	}
+
+	req, err := ParseRequest(r.Header)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.From != s.partner.AS2ID || req.To != s.local.AS2ID {
+		http.Error(w, "unknown partnership", http.StatusForbidden)
+		return
+	}
+	payload, openErr := req.Open(body, s.local, s.partner)
+	if openErr == nil {
+		s.mu.Lock()
+		s.received[payload.FileName] = payload.Data
+		s.mu.Unlock()
+	}
+	mdn, err := BuildMDN(s.local, req, payload, openErr)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	switch req.MDN {
+	case MDNSync:
+		for name, values := range mdn.Header {
+			w.Header()[name] = values
+		}
+		w.Write(mdn.Body)
+	case MDNAsync:
+		go SendMDN(context.Background(), http.DefaultClient, req.AsyncURL, mdn)
+	}
+}
+
+func (s *station) send(t *testing.T, fileName string, payload []byte) (*MDN, error) {
+	t.Helper()
+	msg, err := Build(s.local, s.partner, fileName, payload)
+	if err != nil {
+		t.Fatalf("Build() error = %v", err)
+	}
+	s.mu.Lock()
+	s.sent[msg.MessageID] = msg
+	s.mu.Unlock()
+	return Send(context.Background(), http.DefaultClient, s.partner, msg)
+}
+
+// TestInterop exchanges messages between two stations in both directions, with the
+// security and MDN options an EDI partnership typically uses
+func TestInterop(t *testing.T) {
+	a, b := testIdentity(t, 0), testIdentity(t, 1)
+	stationA := newStation(t, a, Partner{
+		AS2ID: b.AS2ID, Certificate: b.Certificate, ContentType: "application/edifact",
+		Sign: true, SigningAlgorithm: SigningSHA256, Encrypt: true, EncryptionAlgorithm: EncryptionAES256, Compress: true,
+		MDN: MDNSync, SignedMDN: true, RequireSigned: true, RequireEncrypted: true,
+	})
+	stationB := newStation(t, b, Partner{
+		AS2ID: a.AS2ID, Certificate: a.Certificate,
+		Sign: true, SigningAlgorithm: SigningSHA1, Encrypt: true, EncryptionAlgorithm: EncryptionDES3,
+		MDN: MDNAsync, SignedMDN: true, RequireSigned: true,
+	})
+	stationA.partner.URL = stationB.server.URL
+	stationB.partner.URL = stationA.server.URL
+	stationB.partner.AsyncMDNURL = stationB.server.URL
+
+	payload := bytes.Repeat([]byte("UNH+1+ORDERS:D:96A:UN'BGM+220+4500001'"), 500)
+
+	// A to B with a signed synchronous MDN
+	mdn, err := stationA.send(t, "orders.edi", payload)
+	if err != nil {
+		t.Fatalf("send to B error = %v", err)
+	}
+	if mdn == nil || !mdn.Processed() || !mdn.Signed || mdn.From != b.AS2ID {
+		t.Fatalf("send to B MDN = %+v", mdn)
+	}
+	if !bytes.Equal(stationB.received["orders.edi"], payload) {
+		t.Error("B did not receive the payload")
+	}
+
+	// B to A with a signed asynchronous MDN posted back to B
+	mdn, err = stationB.send(t, "invoice.edi", []byte("UNH+1+INVOIC:D:96A:UN'"))
+	if err != nil || mdn != nil {
+		t.Fatalf("send to A = %v, %v, want no MDN in the response", mdn, err)
+	}
+	select {
+	case receipt := <-stationB.receipts:
+		if !receipt.Processed() || !receipt.Signed || receipt.MICAlgorithm != SigningSHA1 {
+			t.Errorf("async MDN = %+v", receipt)
+		}
+	case err := <-stationB.mdnErrs:
+		t.Fatalf("async MDN error = %v", err)
+	case <-time.After(10 * time.Second):
+		t.Fatal("no async MDN")
+	}
+	if string(stationA.received["invoice.edi"]) != "UNH+1+INVOIC:D:96A:UN'" {
+		t.Error("A did not receive the payload")
+	}
+
+	// A message B requires to be encrypted is refused in the MDN
+	stationB.partner.Encrypt = false
+	stationB.partner.MDN = MDNSync
+	_, err = stationB.send(t, "plain.edi", []byte("UNH+2'"))
+	if err == nil || !strings.Contains(err.Error(), DispositionInsufficientSecurity) {
+		t.Errorf("send unencrypted to A error = %v, want %s", err, DispositionInsufficientSecurity)
+	}
+	if _, ok := stationA.received["plain.edi"]; ok {
+		t.Error("A accepted an unencrypted message")
+	}
+}
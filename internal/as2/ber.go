@@ -0,0 +1,171 @@
+package as2
+
+import (
+	"fmt"
+)
+
+// maxBERDepth bounds the nesting of BER elements to protect against crafted messages
+const maxBERDepth = 64
+
+// berToDER converts a BER encoded element to DER, as far as the CMS parsing needs it:
+// indefinite lengths get definite ones and constructed octet strings are merged into
+// primitive ones. Other partners' AS2 software commonly streams BER.
+func berToDER(data []byte) ([]byte, error) {
+	element, rest, err := readBER(data, 0)
+	if err != nil {
+		return nil, err
+	}
+	if len(rest) > 0 && !allZero(rest) {
+		return nil, fmt.Errorf("malformed CMS content: trailing data")
+	}
+	return element, nil
+}
+
+// readBER reads one BER element and returns its DER encoding and the remaining input
+func readBER(data []byte, depth int) ([]byte, []byte, error) {
+	if depth > maxBERDepth {
+		return nil, nil, fmt.Errorf("malformed CMS content: nested too deeply")
+	}
+	if len(data) < 2 {
+		return nil, nil, fmt.Errorf("malformed CMS content: truncated")
+	}
+
+	// Identifier octets, including high tag numbers
+	headerLen := 1
+	if data[0]&0x1f == 0x1f {
+		for {
+			if headerLen >= len(data) {
+				return nil, nil, fmt.Errorf("malformed CMS content: truncated tag")
+			}
+			headerLen++
+			if data[headerLen-1]&0x80 == 0 {
+				break
+			}
+		}
+	}
+	identifier := data[:headerLen]
+	constructed := data[0]&0x20 != 0
+	if headerLen >= len(data) {
+		return nil, nil, fmt.Errorf("malformed CMS content: truncated length")
+	}
+
+	lengthByte := data[headerLen]
+	body := data[headerLen+1:]
+	if lengthByte == 0x80 {
+		if !constructed {
+			return nil, nil, fmt.Errorf("malformed CMS content: indefinite primitive length")
+		}
+		var children [][]byte
+		for {
+			if len(body) < 2 {
+				return nil, nil, fmt.Errorf("malformed CMS content: missing end of contents")
+			}
+			if body[0] == 0 && body[1] == 0 {
+				body = body[2:]
+				break
+			}
+			child, rest, err := readBER(body, depth+1)
+			if err != nil {
+				return nil, nil, err
+			}
+			children = append(children, child)
+			body = rest
+		}
+		return encodeConstructed(identifier, children), body, nil
+	}
+
+	length := int(lengthByte)
+	if lengthByte&0x80 != 0 {
+		count := int(lengthByte & 0x7f)
+		if count > 4 || count > len(body) {
+			return nil, nil, fmt.Errorf("malformed CMS content: bad length")
+		}
+		length = 0
+		for _, b := range body[:count] {
+			length = length<<8 | int(b)
+		}
+		body = body[count:]
+	}
+	if length < 0 || length > len(body) {
+		return nil, nil, fmt.Errorf("malformed CMS content: truncated")
+	}
+	content, rest := body[:length], body[length:]
+
+	if !constructed {
+		return encodeElement(identifier, content), rest, nil
+	}
+	var children [][]byte
+	for len(content) > 0 {
+		child, remaining, err := readBER(content, depth+1)
+		if err != nil {
+			return nil, nil, err
+		}
+		children = append(children, child)
+		content = remaining
+	}
+	return encodeConstructed(identifier, children), rest, nil
+}
+
+// encodeConstructed encodes a constructed element from its DER encoded children. A
+// constructed OCTET STRING becomes a primitive one holding the concatenated chunks.
+func encodeConstructed(identifier []byte, children [][]byte) []byte {
+	if len(identifier) == 1 && identifier[0] == 0x24 {
+		var merged []byte
+		for _, child := range children {
+			_, content := splitElement(child)
+			merged = append(merged, content...)
+		}
+		return encodeElement([]byte{0x04}, merged)
+	}
+	var content []byte
+	for _, child := range children {
+		content = append(content, child...)
+	}
+	return encodeElement(identifier, content)
+}
+
+// encodeElement encodes an element with a definite DER length
+func encodeElement(identifier, content []byte) []byte {
+	out := append([]byte{}, identifier...)
+	length := len(content)
+	switch {
+	case length < 0x80:
+		out = append(out, byte(length))
+	default:
+		var lengthBytes []byte
+		for l := length; l > 0; l >>= 8 {
+			lengthBytes = append([]byte{byte(l)}, lengthBytes...)
+		}
+		out = append(out, 0x80|byte(len(lengthBytes)))
+		out = append(out, lengthBytes...)
+	}
+	return append(out, content...)
+}
+
+// splitElement returns the identifier and the content of a DER encoded element
+func splitElement(element []byte) ([]byte, []byte) {
+	headerLen := 1
+	if element[0]&0x1f == 0x1f {
+		for element[headerLen]&0x80 != 0 {
+			headerLen++
+		}
+		headerLen++
+	}
+	identifier := element[:headerLen]
+	lengthByte := element[headerLen]
+	start := headerLen + 1
+	if lengthByte&0x80 != 0 {
+		start += int(lengthByte & 0x7f)
+	}
+	return identifier, element[start:]
+}
+
+// allZero reports whether data only holds zero padding
+func allZero(data []byte) bool {
+	for _, b := range data {
+		if b != 0 {
+			return false
+		}
+	}
+	return true
+}
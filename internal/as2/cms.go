@@ -0,0 +1,643 @@
+package as2
+
+import (
+	"bytes"
+	"compress/zlib"
+	"crypto"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/des"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"sort"
+	"time"
+
+	"golang.org/x/crypto/cryptobyte"
+	cbasn1 "golang.org/x/crypto/cryptobyte/asn1"
+)
+
+// Object identifiers of the CMS (RFC 5652) structures and algorithms used by AS2
+var (
+	oidData           = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 1}
+	oidSignedData     = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 2}
+	oidEnvelopedData  = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 3}
+	oidCompressedData = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 16, 1, 9}
+	oidZlibCompress   = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 16, 3, 8}
+
+	oidAttributeContentType   = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 3}
+	oidAttributeMessageDigest = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 4}
+	oidAttributeSigningTime   = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 5}
+
+	oidRSAEncryption = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 1}
+
+	oidAES128CBC  = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 2}
+	oidAES192CBC  = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 22}
+	oidAES256CBC  = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 42}
+	oidDESEDE3CBC = asn1.ObjectIdentifier{1, 2, 840, 113549, 3, 7}
+)
+
+// digestAlgorithms maps the digest algorithm identifiers to their hash
+var digestAlgorithms = []struct {
+	oid  asn1.ObjectIdentifier
+	hash crypto.Hash
+}{
+	{asn1.ObjectIdentifier{1, 3, 14, 3, 2, 26}, crypto.SHA1},
+	{asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 1}, crypto.SHA256},
+	{asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 2}, crypto.SHA384},
+	{asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 3}, crypto.SHA512},
+}
+
+// signatureAlgorithms are the RSA signature algorithm identifiers accepted in signer
+// infos besides rsaEncryption, which leaves the hash to the digest algorithm
+var signatureAlgorithms = []asn1.ObjectIdentifier{
+	oidRSAEncryption,
+	{1, 2, 840, 113549, 1, 1, 5},  // sha1WithRSAEncryption
+	{1, 2, 840, 113549, 1, 1, 11}, // sha256WithRSAEncryption
+	{1, 2, 840, 113549, 1, 1, 12}, // sha384WithRSAEncryption
+	{1, 2, 840, 113549, 1, 1, 13}, // sha512WithRSAEncryption
+}
+
+// contentCiphers maps the encryption algorithms to their content encryption algorithm
+var contentCiphers = map[string]struct {
+	oid     asn1.ObjectIdentifier
+	keySize int
+}{
+	EncryptionAES128: {oidAES128CBC, 16},
+	EncryptionAES192: {oidAES192CBC, 24},
+	EncryptionAES256: {oidAES256CBC, 32},
+	EncryptionDES3:   {oidDESEDE3CBC, 24},
+}
+
+// errDigestMismatch reports signed content that was changed after signing
+var errDigestMismatch = errors.New("content does not match the signature")
+
+var tagContext0 = cbasn1.Tag(0).ContextSpecific().Constructed()
+
+// digestOID returns the digest algorithm identifier of a hash
+func digestOID(hash crypto.Hash) (asn1.ObjectIdentifier, error) {
+	for _, algorithm := range digestAlgorithms {
+		if algorithm.hash == hash {
+			return algorithm.oid, nil
+		}
+	}
+	return nil, fmt.Errorf("unsupported digest algorithm %v", hash)
+}
+
+// digestHash returns the hash of a digest algorithm identifier
+func digestHash(oid asn1.ObjectIdentifier) (crypto.Hash, error) {
+	for _, algorithm := range digestAlgorithms {
+		if algorithm.oid.Equal(oid) {
+			return algorithm.hash, nil
+		}
+	}
+	return 0, fmt.Errorf("unsupported digest algorithm %v", oid)
+}
+
+// addContentInfo wraps CMS content in a ContentInfo of the given type
+func addContentInfo(contentType asn1.ObjectIdentifier, content func(b *cryptobyte.Builder)) ([]byte, error) {
+	var b cryptobyte.Builder
+	b.AddASN1(cbasn1.SEQUENCE, func(b *cryptobyte.Builder) {
+		b.AddASN1ObjectIdentifier(contentType)
+		b.AddASN1(tagContext0, content)
+	})
+	return b.Bytes()
+}
+
+// readContentInfo returns the type and the content of a ContentInfo. BER encodings, as
+// produced by streaming implementations, are converted to DER first.
+func readContentInfo(data []byte) (asn1.ObjectIdentifier, cryptobyte.String, error) {
+	der, err := berToDER(data)
+	if err != nil {
+		return nil, nil, err
+	}
+	var contentInfo, content cryptobyte.String
+	var contentType asn1.ObjectIdentifier
+	input := cryptobyte.String(der)
+	if !input.ReadASN1(&contentInfo, cbasn1.SEQUENCE) ||
+		!contentInfo.ReadASN1ObjectIdentifier(&contentType) ||
+		!contentInfo.ReadASN1(&content, tagContext0) {
+		return nil, nil, fmt.Errorf("malformed CMS content")
+	}
+	return contentType, content, nil
+}
+
+// addIssuerAndSerial adds the IssuerAndSerialNumber identifying a certificate
+func addIssuerAndSerial(b *cryptobyte.Builder, cert *x509.Certificate) {
+	b.AddASN1(cbasn1.SEQUENCE, func(b *cryptobyte.Builder) {
+		b.AddBytes(cert.RawIssuer)
+		b.AddASN1BigInt(cert.SerialNumber)
+	})
+}
+
+// addAlgorithm adds an AlgorithmIdentifier, with NULL parameters unless they are given
+func addAlgorithm(b *cryptobyte.Builder, oid asn1.ObjectIdentifier, parameters func(b *cryptobyte.Builder)) {
+	b.AddASN1(cbasn1.SEQUENCE, func(b *cryptobyte.Builder) {
+		b.AddASN1ObjectIdentifier(oid)
+		if parameters != nil {
+			parameters(b)
+		} else {
+			b.AddASN1NULL()
+		}
+	})
+}
+
+// readAlgorithm reads an AlgorithmIdentifier and returns its identifier and parameters
+func readAlgorithm(s *cryptobyte.String) (asn1.ObjectIdentifier, cryptobyte.String, bool) {
+	var algorithm cryptobyte.String
+	var oid asn1.ObjectIdentifier
+	if !s.ReadASN1(&algorithm, cbasn1.SEQUENCE) || !algorithm.ReadASN1ObjectIdentifier(&oid) {
+		return nil, nil, false
+	}
+	return oid, algorithm, true
+}
+
+// signedAttributes returns the DER encoded signed attributes of a signature over content
+// with the given digest, sorted as a DER SET OF requires
+func signedAttributes(digest []byte, signingTime time.Time) ([]byte, error) {
+	attributes := make([][]byte, 0, 3)
+	values := []struct {
+		oid asn1.ObjectIdentifier
+		add func(b *cryptobyte.Builder)
+	}{
+		{oidAttributeContentType, func(b *cryptobyte.Builder) { b.AddASN1ObjectIdentifier(oidData) }},
+		{oidAttributeSigningTime, func(b *cryptobyte.Builder) { b.AddASN1UTCTime(signingTime.UTC()) }},
+		{oidAttributeMessageDigest, func(b *cryptobyte.Builder) { b.AddASN1OctetString(digest) }},
+	}
+	for _, value := range values {
+		var b cryptobyte.Builder
+		b.AddASN1(cbasn1.SEQUENCE, func(b *cryptobyte.Builder) {
+			b.AddASN1ObjectIdentifier(value.oid)
+			b.AddASN1(cbasn1.SET, value.add)
+		})
+		attribute, err := b.Bytes()
+		if err != nil {
+			return nil, err
+		}
+		attributes = append(attributes, attribute)
+	}
+	sort.Slice(attributes, func(i, j int) bool { return bytes.Compare(attributes[i], attributes[j]) < 0 })
+
+	var b cryptobyte.Builder
+	b.AddASN1(cbasn1.SET, func(b *cryptobyte.Builder) {
+		for _, attribute := range attributes {
+			b.AddBytes(attribute)
+		}
+	})
+	return b.Bytes()
+}
+
+// signDetached returns a detached CMS signature of content, made with an RSA key and
+// carrying the signing certificate
+func signDetached(content []byte, cert *x509.Certificate, key *rsa.PrivateKey, hash crypto.Hash) ([]byte, error) {
+	digestAlgorithm, err := digestOID(hash)
+	if err != nil {
+		return nil, err
+	}
+	h := hash.New()
+	h.Write(content)
+	attributes, err := signedAttributes(h.Sum(nil), time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	// The signature covers the attributes encoded as a SET OF
+	h = hash.New()
+	h.Write(attributes)
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, hash, h.Sum(nil))
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign: %v", err)
+	}
+	var attributeSet cryptobyte.String
+	input := cryptobyte.String(attributes)
+	if !input.ReadASN1(&attributeSet, cbasn1.SET) {
+		return nil, fmt.Errorf("failed to encode signed attributes")
+	}
+
+	return addContentInfo(oidSignedData, func(b *cryptobyte.Builder) {
+		b.AddASN1(cbasn1.SEQUENCE, func(b *cryptobyte.Builder) {
+			b.AddASN1Int64(1)
+			b.AddASN1(cbasn1.SET, func(b *cryptobyte.Builder) {
+				addAlgorithm(b, digestAlgorithm, nil)
+			})
+			// Detached: the encapsulated content is left out
+			b.AddASN1(cbasn1.SEQUENCE, func(b *cryptobyte.Builder) {
+				b.AddASN1ObjectIdentifier(oidData)
+			})
+			b.AddASN1(tagContext0, func(b *cryptobyte.Builder) {
+				b.AddBytes(cert.Raw)
+			})
+			b.AddASN1(cbasn1.SET, func(b *cryptobyte.Builder) {
+				b.AddASN1(cbasn1.SEQUENCE, func(b *cryptobyte.Builder) {
+					b.AddASN1Int64(1)
+					addIssuerAndSerial(b, cert)
+					addAlgorithm(b, digestAlgorithm, nil)
+					// Signed attributes are IMPLICIT [0], the signature covers them as a SET
+					b.AddASN1(tagContext0, func(b *cryptobyte.Builder) {
+						b.AddBytes(attributeSet)
+					})
+					addAlgorithm(b, oidRSAEncryption, nil)
+					b.AddASN1OctetString(signature)
+				})
+			})
+		})
+	})
+}
+
+// verifyDetached checks a detached CMS signature of content against the certificate
+// of the signer and returns the digest algorithm of the signature
+func verifyDetached(signature, content []byte, cert *x509.Certificate) (crypto.Hash, error) {
+	publicKey, ok := cert.PublicKey.(*rsa.PublicKey)
+	if !ok {
+		return 0, fmt.Errorf("only RSA certificates are supported")
+	}
+	contentType, content0, err := readContentInfo(signature)
+	if err != nil {
+		return 0, err
+	}
+	if !contentType.Equal(oidSignedData) {
+		return 0, fmt.Errorf("signature is not CMS signed data")
+	}
+
+	var signedData, digestAlgorithms, encapContentInfo, signerInfos cryptobyte.String
+	var version int
+	if !content0.ReadASN1(&signedData, cbasn1.SEQUENCE) ||
+		!signedData.ReadASN1Integer(&version) ||
+		!signedData.ReadASN1(&digestAlgorithms, cbasn1.SET) ||
+		!signedData.ReadASN1(&encapContentInfo, cbasn1.SEQUENCE) ||
+		!signedData.SkipOptionalASN1(tagContext0) || // certificates
+		!signedData.SkipOptionalASN1(cbasn1.Tag(1).ContextSpecific().Constructed()) || // crls
+		!signedData.ReadASN1(&signerInfos, cbasn1.SET) {
+		return 0, fmt.Errorf("malformed CMS signed data")
+	}
+
+	var lastErr error = fmt.Errorf("signature has no signer")
+	for !signerInfos.Empty() {
+		var signerInfo cryptobyte.String
+		if !signerInfos.ReadASN1(&signerInfo, cbasn1.SEQUENCE) {
+			return 0, fmt.Errorf("malformed CMS signer info")
+		}
+		hash, err := verifySignerInfo(signerInfo, content, publicKey)
+		if err == nil {
+			return hash, nil
+		}
+		lastErr = err
+	}
+	return 0, lastErr
+}
+
+// verifySignerInfo checks the signature of a signer info over content with a public key
+func verifySignerInfo(signerInfo cryptobyte.String, content []byte, publicKey *rsa.PublicKey) (crypto.Hash, error) {
+	var version int
+	var sid cryptobyte.String
+	var sidTag cbasn1.Tag
+	if !signerInfo.ReadASN1Integer(&version) || !signerInfo.ReadAnyASN1Element(&sid, &sidTag) {
+		return 0, fmt.Errorf("malformed CMS signer info")
+	}
+	digestAlgorithm, _, ok := readAlgorithm(&signerInfo)
+	if !ok {
+		return 0, fmt.Errorf("malformed CMS signer info")
+	}
+	hash, err := digestHash(digestAlgorithm)
+	if err != nil {
+		return 0, err
+	}
+
+	var attributes cryptobyte.String
+	hasAttributes := signerInfo.PeekASN1Tag(tagContext0)
+	if hasAttributes && !signerInfo.ReadASN1Element(&attributes, tagContext0) {
+		return 0, fmt.Errorf("malformed CMS signed attributes")
+	}
+	signatureAlgorithm, _, ok := readAlgorithm(&signerInfo)
+	var signature []byte
+	if !ok || !signerInfo.ReadASN1Bytes(&signature, cbasn1.OCTET_STRING) {
+		return 0, fmt.Errorf("malformed CMS signer info")
+	}
+	supported := false
+	for _, oid := range signatureAlgorithms {
+		supported = supported || oid.Equal(signatureAlgorithm)
+	}
+	if !supported {
+		return 0, fmt.Errorf("unsupported signature algorithm %v", signatureAlgorithm)
+	}
+
+	h := hash.New()
+	h.Write(content)
+	digest := h.Sum(nil)
+	if hasAttributes {
+		messageDigest, err := attributeDigest(attributes)
+		if err != nil {
+			return 0, err
+		}
+		if !bytes.Equal(messageDigest, digest) {
+			return 0, errDigestMismatch
+		}
+		// The signature covers the attributes encoded as a SET OF rather than [0]
+		signed := append([]byte{}, attributes...)
+		signed[0] = 0x31
+		h = hash.New()
+		h.Write(signed)
+		digest = h.Sum(nil)
+	}
+	if err := rsa.VerifyPKCS1v15(publicKey, hash, digest, signature); err != nil {
+		return 0, fmt.Errorf("signature verification failed: %v", err)
+	}
+	return hash, nil
+}
+
+// attributeDigest returns the message digest in the signed attributes of a signer info
+func attributeDigest(attributes cryptobyte.String) ([]byte, error) {
+	var set cryptobyte.String
+	if !attributes.ReadASN1(&set, tagContext0) {
+		return nil, fmt.Errorf("malformed CMS signed attributes")
+	}
+	for !set.Empty() {
+		var attribute, values cryptobyte.String
+		var oid asn1.ObjectIdentifier
+		if !set.ReadASN1(&attribute, cbasn1.SEQUENCE) ||
+			!attribute.ReadASN1ObjectIdentifier(&oid) ||
+			!attribute.ReadASN1(&values, cbasn1.SET) {
+			return nil, fmt.Errorf("malformed CMS signed attributes")
+		}
+		if oid.Equal(oidAttributeMessageDigest) {
+			var digest []byte
+			if !values.ReadASN1Bytes(&digest, cbasn1.OCTET_STRING) {
+				return nil, fmt.Errorf("malformed CMS message digest")
+			}
+			return digest, nil
+		}
+	}
+	return nil, fmt.Errorf("signed attributes have no message digest")
+}
+
+// encrypt returns content as CMS enveloped data for the key of a recipient certificate
+func encrypt(content []byte, recipient *x509.Certificate, algorithm string) ([]byte, error) {
+	publicKey, ok := recipient.PublicKey.(*rsa.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("only RSA certificates are supported")
+	}
+	contentCipher, ok := contentCiphers[algorithm]
+	if !ok {
+		return nil, fmt.Errorf("unsupported encryption algorithm %q", algorithm)
+	}
+
+	key := make([]byte, contentCipher.keySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	block, err := newBlockCipher(algorithm, key)
+	if err != nil {
+		return nil, err
+	}
+	iv := make([]byte, block.BlockSize())
+	if _, err := rand.Read(iv); err != nil {
+		return nil, err
+	}
+	padding := block.BlockSize() - len(content)%block.BlockSize()
+	encrypted := append(append([]byte{}, content...), bytes.Repeat([]byte{byte(padding)}, padding)...)
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(encrypted, encrypted)
+
+	encryptedKey, err := rsa.EncryptPKCS1v15(rand.Reader, publicKey, key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt the content key: %v", err)
+	}
+
+	return addContentInfo(oidEnvelopedData, func(b *cryptobyte.Builder) {
+		b.AddASN1(cbasn1.SEQUENCE, func(b *cryptobyte.Builder) {
+			b.AddASN1Int64(0)
+			b.AddASN1(cbasn1.SET, func(b *cryptobyte.Builder) {
+				b.AddASN1(cbasn1.SEQUENCE, func(b *cryptobyte.Builder) {
+					b.AddASN1Int64(0)
+					addIssuerAndSerial(b, recipient)
+					addAlgorithm(b, oidRSAEncryption, nil)
+					b.AddASN1OctetString(encryptedKey)
+				})
+			})
+			b.AddASN1(cbasn1.SEQUENCE, func(b *cryptobyte.Builder) {
+				b.AddASN1ObjectIdentifier(oidData)
+				addAlgorithm(b, contentCipher.oid, func(b *cryptobyte.Builder) {
+					b.AddASN1OctetString(iv)
+				})
+				b.AddASN1(cbasn1.Tag(0).ContextSpecific(), func(b *cryptobyte.Builder) {
+					b.AddBytes(encrypted)
+				})
+			})
+		})
+	})
+}
+
+// decrypt returns the content of CMS enveloped data encrypted for a certificate
+func decrypt(data []byte, cert *x509.Certificate, key *rsa.PrivateKey) ([]byte, error) {
+	contentType, content, err := readContentInfo(data)
+	if err != nil {
+		return nil, err
+	}
+	if !contentType.Equal(oidEnvelopedData) {
+		return nil, fmt.Errorf("content is not CMS enveloped data")
+	}
+
+	var envelopedData, recipientInfos, encryptedContentInfo cryptobyte.String
+	var version int
+	if !content.ReadASN1(&envelopedData, cbasn1.SEQUENCE) ||
+		!envelopedData.ReadASN1Integer(&version) ||
+		!envelopedData.SkipOptionalASN1(tagContext0) || // originatorInfo
+		!envelopedData.ReadASN1(&recipientInfos, cbasn1.SET) ||
+		!envelopedData.ReadASN1(&encryptedContentInfo, cbasn1.SEQUENCE) {
+		return nil, fmt.Errorf("malformed CMS enveloped data")
+	}
+
+	// The content key is encrypted for each recipient, find the one of the certificate
+	var encryptedKey []byte
+	for !recipientInfos.Empty() {
+		var recipientInfo, rid cryptobyte.String
+		var recipientVersion int
+		if !recipientInfos.ReadASN1(&recipientInfo, cbasn1.SEQUENCE) {
+			// Other kinds of recipient infos are tagged and not supported
+			var skipped cryptobyte.String
+			var tag cbasn1.Tag
+			if !recipientInfos.ReadAnyASN1Element(&skipped, &tag) {
+				return nil, fmt.Errorf("malformed CMS recipient info")
+			}
+			continue
+		}
+		if !recipientInfo.ReadASN1Integer(&recipientVersion) || !recipientInfo.ReadASN1Element(&rid, cbasn1.SEQUENCE) {
+			continue
+		}
+		if _, _, ok := readAlgorithm(&recipientInfo); !ok {
+			continue
+		}
+		var candidate []byte
+		if !recipientInfo.ReadASN1Bytes(&candidate, cbasn1.OCTET_STRING) {
+			continue
+		}
+		if encryptedKey == nil || identifies(rid, cert) {
+			encryptedKey = candidate
+		}
+	}
+	if encryptedKey == nil {
+		return nil, fmt.Errorf("message is not encrypted for a supported recipient")
+	}
+
+	var innerType asn1.ObjectIdentifier
+	if !encryptedContentInfo.ReadASN1ObjectIdentifier(&innerType) {
+		return nil, fmt.Errorf("malformed CMS encrypted content info")
+	}
+	cipherOID, cipherParameters, ok := readAlgorithm(&encryptedContentInfo)
+	var iv []byte
+	if !ok || !cipherParameters.ReadASN1Bytes(&iv, cbasn1.OCTET_STRING) {
+		return nil, fmt.Errorf("malformed CMS content encryption algorithm")
+	}
+	ciphertext, err := readEncryptedContent(encryptedContentInfo)
+	if err != nil {
+		return nil, err
+	}
+
+	contentKey, err := rsa.DecryptPKCS1v15(rand.Reader, key, encryptedKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt the content key: %v", err)
+	}
+	var name string
+	for candidate, contentCipher := range contentCiphers {
+		if contentCipher.oid.Equal(cipherOID) {
+			name = candidate
+		}
+	}
+	if name == "" {
+		return nil, fmt.Errorf("unsupported content encryption algorithm %v", cipherOID)
+	}
+	block, err := newBlockCipher(name, contentKey)
+	if err != nil {
+		return nil, err
+	}
+	if len(iv) != block.BlockSize() || len(ciphertext) == 0 || len(ciphertext)%block.BlockSize() != 0 {
+		return nil, fmt.Errorf("malformed encrypted content")
+	}
+	plaintext := make([]byte, len(ciphertext))
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(plaintext, ciphertext)
+
+	padding := int(plaintext[len(plaintext)-1])
+	if padding == 0 || padding > block.BlockSize() || !bytes.Equal(plaintext[len(plaintext)-padding:], bytes.Repeat([]byte{byte(padding)}, padding)) {
+		return nil, fmt.Errorf("failed to decrypt the content")
+	}
+	return plaintext[:len(plaintext)-padding], nil
+}
+
+// readEncryptedContent returns the encrypted content of an encrypted content info, which
+// BER encoders may split into a constructed string of octet strings
+func readEncryptedContent(encryptedContentInfo cryptobyte.String) ([]byte, error) {
+	var ciphertext []byte
+	var primitive, constructed cryptobyte.String
+	var hasPrimitive, hasConstructed bool
+	if !encryptedContentInfo.ReadOptionalASN1(&primitive, &hasPrimitive, cbasn1.Tag(0).ContextSpecific()) {
+		return nil, fmt.Errorf("malformed CMS encrypted content")
+	}
+	if hasPrimitive {
+		return primitive, nil
+	}
+	if !encryptedContentInfo.ReadOptionalASN1(&constructed, &hasConstructed, tagContext0) || !hasConstructed {
+		return nil, fmt.Errorf("message has no encrypted content")
+	}
+	for !constructed.Empty() {
+		var chunk []byte
+		if !constructed.ReadASN1Bytes(&chunk, cbasn1.OCTET_STRING) {
+			return nil, fmt.Errorf("malformed CMS encrypted content")
+		}
+		ciphertext = append(ciphertext, chunk...)
+	}
+	return ciphertext, nil
+}
+
+// identifies reports whether an IssuerAndSerialNumber names a certificate
+func identifies(rid cryptobyte.String, cert *x509.Certificate) bool {
+	var issuerAndSerial cryptobyte.String
+	var issuer cryptobyte.String
+	serial := new(big.Int)
+	if !rid.ReadASN1(&issuerAndSerial, cbasn1.SEQUENCE) ||
+		!issuerAndSerial.ReadASN1Element(&issuer, cbasn1.SEQUENCE) ||
+		!issuerAndSerial.ReadASN1Integer(serial) {
+		return false
+	}
+	return bytes.Equal(issuer, cert.RawIssuer) && serial.Cmp(cert.SerialNumber) == 0
+}
+
+// newBlockCipher returns the block cipher of an encryption algorithm
+func newBlockCipher(algorithm string, key []byte) (cipher.Block, error) {
+	if algorithm == EncryptionDES3 {
+		return des.NewTripleDESCipher(key)
+	}
+	return aes.NewCipher(key)
+}
+
+// compress returns content as CMS compressed data (RFC 3274)
+func compress(content []byte) ([]byte, error) {
+	var compressed bytes.Buffer
+	w := zlib.NewWriter(&compressed)
+	if _, err := w.Write(content); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+
+	return addContentInfo(oidCompressedData, func(b *cryptobyte.Builder) {
+		b.AddASN1(cbasn1.SEQUENCE, func(b *cryptobyte.Builder) {
+			b.AddASN1Int64(0)
+			b.AddASN1(cbasn1.SEQUENCE, func(b *cryptobyte.Builder) {
+				b.AddASN1ObjectIdentifier(oidZlibCompress)
+			})
+			b.AddASN1(cbasn1.SEQUENCE, func(b *cryptobyte.Builder) {
+				b.AddASN1ObjectIdentifier(oidData)
+				b.AddASN1(tagContext0, func(b *cryptobyte.Builder) {
+					b.AddASN1OctetString(compressed.Bytes())
+				})
+			})
+		})
+	})
+}
+
+// decompress returns the content of CMS compressed data
+func decompress(data []byte) ([]byte, error) {
+	contentType, content, err := readContentInfo(data)
+	if err != nil {
+		return nil, err
+	}
+	if !contentType.Equal(oidCompressedData) {
+		return nil, fmt.Errorf("content is not CMS compressed data")
+	}
+
+	var compressedData, encapContentInfo, eContent cryptobyte.String
+	var version int
+	var eContentType asn1.ObjectIdentifier
+	var compressed []byte
+	if !content.ReadASN1(&compressedData, cbasn1.SEQUENCE) ||
+		!compressedData.ReadASN1Integer(&version) {
+		return nil, fmt.Errorf("malformed CMS compressed data")
+	}
+	algorithm, _, ok := readAlgorithm(&compressedData)
+	if !ok ||
+		!compressedData.ReadASN1(&encapContentInfo, cbasn1.SEQUENCE) ||
+		!encapContentInfo.ReadASN1ObjectIdentifier(&eContentType) ||
+		!encapContentInfo.ReadASN1(&eContent, tagContext0) ||
+		!eContent.ReadASN1Bytes(&compressed, cbasn1.OCTET_STRING) {
+		return nil, fmt.Errorf("malformed CMS compressed data")
+	}
+	if !algorithm.Equal(oidZlibCompress) {
+		return nil, fmt.Errorf("unsupported compression algorithm %v", algorithm)
+	}
+
+	r, err := zlib.NewReader(bytes.NewReader(compressed))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress: %v", err)
+	}
+	defer r.Close()
+	decompressed, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress: %v", err)
+	}
+	return decompressed, nil
+}
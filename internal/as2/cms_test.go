@@ -0,0 +1,147 @@
+package as2
+
+import (
+	"bytes"
+	"crypto"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+)
+
+var (
+	testIdentitiesOnce sync.Once
+	testIdentities     []Identity
+)
+
+// testIdentity returns one of two generated identities, shared by the tests because RSA
+// key generation is slow
+func testIdentity(t *testing.T, index int) Identity {
+	t.Helper()
+	testIdentitiesOnce.Do(func() {
+		for _, id := range []string{"GOMFT-A", "GOMFT B"} {
+			certPEM, keyPEM, err := GenerateCertificate(id, 2048, 1)
+			if err != nil {
+				panic(err)
+			}
+			cert, err := ParseCertificate([]byte(certPEM))
+			if err != nil {
+				panic(err)
+			}
+			key, err := ParsePrivateKey([]byte(keyPEM))
+			if err != nil {
+				panic(err)
+			}
+			testIdentities = append(testIdentities, Identity{AS2ID: id, Certificate: cert, PrivateKey: key})
+		}
+	})
+	return testIdentities[index]
+}
+
+func TestSignVerify(t *testing.T) {
+	signer, other := testIdentity(t, 0), testIdentity(t, 1)
+	content := []byte("Content-Type: application/edi-x12\r\n\r\nISA*00*~")
+
+	for _, hash := range []crypto.Hash{crypto.SHA1, crypto.SHA256, crypto.SHA384, crypto.SHA512} {
+		signature, err := signDetached(content, signer.Certificate, signer.PrivateKey, hash)
+		if err != nil {
+			t.Fatalf("signDetached(%v) error = %v", hash, err)
+		}
+		got, err := verifyDetached(signature, content, signer.Certificate)
+		if err != nil || got != hash {
+			t.Errorf("verifyDetached(%v) = %v, %v", hash, got, err)
+		}
+		if _, err := verifyDetached(signature, append(content, '!'), signer.Certificate); !errors.Is(err, errDigestMismatch) {
+			t.Errorf("verifyDetached(%v) of changed content error = %v, want digest mismatch", hash, err)
+		}
+		if _, err := verifyDetached(signature, content, other.Certificate); err == nil {
+			t.Errorf("verifyDetached(%v) with another certificate error = nil", hash)
+		}
+	}
+}
+
+func TestEncryptDecrypt(t *testing.T) {
+	recipient, other := testIdentity(t, 0), testIdentity(t, 1)
+	content := bytes.Repeat([]byte("UNB+UNOC:3+SENDER+RECEIVER'"), 100)
+
+	for _, algorithm := range []string{EncryptionAES128, EncryptionAES192, EncryptionAES256, EncryptionDES3} {
+		encrypted, err := encrypt(content, recipient.Certificate, algorithm)
+		if err != nil {
+			t.Fatalf("encrypt(%s) error = %v", algorithm, err)
+		}
+		if bytes.Contains(encrypted, content[:20]) {
+			t.Errorf("encrypt(%s) left the content in clear", algorithm)
+		}
+		decrypted, err := decrypt(encrypted, recipient.Certificate, recipient.PrivateKey)
+		if err != nil || !bytes.Equal(decrypted, content) {
+			t.Errorf("decrypt(%s) = %d bytes, %v", algorithm, len(decrypted), err)
+		}
+		if _, err := decrypt(encrypted, other.Certificate, other.PrivateKey); err == nil {
+			t.Errorf("decrypt(%s) with another key error = nil", algorithm)
+		}
+	}
+	if _, err := encrypt(content, recipient.Certificate, "rc2-cbc"); err == nil {
+		t.Error("encrypt(rc2-cbc) error = nil, want unsupported algorithm")
+	}
+}
+
+func TestCompressDecompress(t *testing.T) {
+	content := []byte(strings.Repeat("compressible ", 1000))
+	compressed, err := compress(content)
+	if err != nil {
+		t.Fatalf("compress() error = %v", err)
+	}
+	if len(compressed) >= len(content) {
+		t.Errorf("compress() = %d bytes for %d bytes of content", len(compressed), len(content))
+	}
+	decompressed, err := decompress(compressed)
+	if err != nil || !bytes.Equal(decompressed, content) {
+		t.Errorf("decompress() = %d bytes, %v", len(decompressed), err)
+	}
+	if _, err := decompress(content); err == nil {
+		t.Error("decompress() of plain content error = nil")
+	}
+}
+
+func TestBERToDER(t *testing.T) {
+	tests := []struct {
+		name string
+		ber  []byte
+		der  []byte
+	}{
+		{
+			"definite",
+			[]byte{0x30, 0x03, 0x02, 0x01, 0x05},
+			[]byte{0x30, 0x03, 0x02, 0x01, 0x05},
+		},
+		{
+			"indefinite sequence",
+			[]byte{0x30, 0x80, 0x02, 0x01, 0x05, 0x00, 0x00},
+			[]byte{0x30, 0x03, 0x02, 0x01, 0x05},
+		},
+		{
+			"constructed octet string",
+			[]byte{0x30, 0x80, 0x24, 0x80, 0x04, 0x02, 'a', 'b', 0x04, 0x01, 'c', 0x00, 0x00, 0x00, 0x00},
+			[]byte{0x30, 0x05, 0x04, 0x03, 'a', 'b', 'c'},
+		},
+		{
+			"nested indefinite context tag",
+			[]byte{0xa0, 0x80, 0x30, 0x80, 0x05, 0x00, 0x00, 0x00, 0x00, 0x00},
+			[]byte{0xa0, 0x04, 0x30, 0x02, 0x05, 0x00},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := berToDER(tt.ber)
+			if err != nil || !bytes.Equal(got, tt.der) {
+				t.Errorf("berToDER() = %x, %v, want %x", got, err, tt.der)
+			}
+		})
+	}
+
+	for _, malformed := range [][]byte{{0x30}, {0x30, 0x05, 0x02}, {0x30, 0x80, 0x02, 0x01, 0x05}, {0x04, 0x80, 0x00, 0x00}} {
+		if _, err := berToDER(malformed); err == nil {
+			t.Errorf("berToDER(%x) error = nil", malformed)
+		}
+	}
+}
@@ -0,0 +1,312 @@
+package as2
+
+import (
+	"crypto"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/textproto"
+	"path"
+	"strings"
+)
+
+// Disposition modifiers of failed messages (RFC 4130 section 7.4.3)
+const (
+	DispositionDecryptionFailed      = "decryption-failed"
+	DispositionAuthenticationFailed  = "authentication-failed"
+	DispositionIntegrityCheckFailed  = "integrity-check-failed"
+	DispositionInsufficientSecurity  = "insufficient-message-security"
+	DispositionUnexpectedProcessing  = "unexpected-processing-error"
+	DispositionUnknownTradingPartner = "unknown-trading-partner"
+)
+
+// maxLayers bounds the nested S/MIME layers of an inbound message
+const maxLayers = 8
+
+// DispositionError is an inbound message failure reported to the sender in the MDN
+type DispositionError struct {
+	Modifier string
+	Err      error
+}
+
+func (e *DispositionError) Error() string {
+	return e.Modifier + ": " + e.Err.Error()
+}
+
+func (e *DispositionError) Unwrap() error {
+	return e.Err
+}
+
+// Failure wraps an error as a disposition error with the given modifier
+func Failure(modifier string, err error) error {
+	return &DispositionError{Modifier: modifier, Err: err}
+}
+
+// IsSignatureFailure reports whether an inbound message failed because its signature could
+// not be verified, in which case its sender is unknown
+func IsSignatureFailure(err error) bool {
+	var dispositionErr *DispositionError
+	if !errors.As(err, &dispositionErr) {
+		return false
+	}
+	return dispositionErr.Modifier == DispositionAuthenticationFailed || dispositionErr.Modifier == DispositionIntegrityCheckFailed
+}
+
+// Request holds the AS2 headers of an inbound message and the MDN the sender asked for
+type Request struct {
+	From        string
+	To          string
+	MessageID   string
+	Subject     string
+	ContentType string
+	Header      textproto.MIMEHeader // Headers of the message entity
+
+	MDN           string // MDN mode the sender asked for
+	AsyncURL      string
+	SignedReceipt bool
+	MICAlgorithm  string // Algorithm of the returned MIC, empty when none was asked for
+}
+
+// Payload is the file carried by an inbound message
+type Payload struct {
+	FileName     string
+	ContentType  string
+	Data         []byte
+	MIC          string
+	MICAlgorithm string
+	Signed       bool
+	Encrypted    bool
+	Compressed   bool
+}
+
+// ParseRequest reads the AS2 headers of an inbound message
+func ParseRequest(header http.Header) (*Request, error) {
+	r := &Request{
+		From:        unquoteAS2ID(header.Get("AS2-From")),
+		To:          unquoteAS2ID(header.Get("AS2-To")),
+		MessageID:   strings.TrimSpace(header.Get("Message-ID")),
+		Subject:     header.Get("Subject"),
+		ContentType: header.Get("Content-Type"),
+		Header:      textproto.MIMEHeader{"Content-Type": {header.Get("Content-Type")}},
+	}
+	if value := header.Get("Content-Transfer-Encoding"); value != "" {
+		r.Header.Set("Content-Transfer-Encoding", value)
+	}
+	if value := header.Get("Content-Disposition"); value != "" {
+		r.Header.Set("Content-Disposition", value)
+	}
+	if r.From == "" || r.To == "" {
+		return nil, errors.New("AS2-From and AS2-To headers are required")
+	}
+	if r.MessageID == "" {
+		return nil, errors.New("Message-ID header is required")
+	}
+	if r.ContentType == "" {
+		return nil, errors.New("Content-Type header is required")
+	}
+
+	if header.Get("Disposition-Notification-To") != "" {
+		r.MDN = MDNSync
+		if r.AsyncURL = strings.TrimSpace(header.Get("Receipt-Delivery-Option")); r.AsyncURL != "" {
+			r.MDN = MDNAsync
+		}
+		r.SignedReceipt, r.MICAlgorithm = parseNotificationOptions(header.Get("Disposition-Notification-Options"))
+	}
+	return r, nil
+}
+
+// Parties returns the AS2 IDs of the sender and the recipient of an inbound message or
+// MDN, which identify the partnership it belongs to
+func Parties(header http.Header) (from, to string) {
+	return unquoteAS2ID(header.Get("AS2-From")), unquoteAS2ID(header.Get("AS2-To"))
+}
+
+// parseNotificationOptions reads whether a signed MDN is asked for and the first supported
+// MIC algorithm of Disposition-Notification-Options, such as
+// "signed-receipt-protocol=optional, pkcs7-signature; signed-receipt-micalg=optional, sha-256"
+func parseNotificationOptions(options string) (bool, string) {
+	signed, micAlgorithm := false, ""
+	for _, option := range strings.Split(options, ";") {
+		name, value, ok := strings.Cut(option, "=")
+		if !ok {
+			continue
+		}
+		values := strings.Split(value, ",")
+		switch strings.ToLower(strings.TrimSpace(name)) {
+		case "signed-receipt-protocol":
+			for _, protocol := range values[1:] {
+				signed = signed || strings.EqualFold(strings.TrimSpace(protocol), "pkcs7-signature")
+			}
+		case "signed-receipt-micalg":
+			for _, candidate := range values[1:] {
+				if algorithm, ok := parseMICAlgorithm(candidate); ok && micAlgorithm == "" {
+					micAlgorithm = algorithm
+				}
+			}
+		}
+	}
+	return signed, micAlgorithm
+}
+
+// Open unwraps the payload of an inbound message, decrypting, verifying and decompressing
+// its layers, and computes the MIC returned in the MDN. Failures are DispositionErrors.
+func (r *Request) Open(body []byte, local Identity, partner Partner) (*Payload, error) {
+	payload := &Payload{}
+	header, content := r.Header, body
+	var micContent []byte
+	micAlgorithm := r.MICAlgorithm
+
+	for layer := 0; ; layer++ {
+		if layer > maxLayers {
+			return nil, Failure(DispositionUnexpectedProcessing, errors.New("too many nested S/MIME layers"))
+		}
+		decoded, err := decodeContent(header, content)
+		if err != nil {
+			return nil, Failure(DispositionUnexpectedProcessing, err)
+		}
+		mediaType, params := parseContentType(header)
+
+		switch {
+		case mediaType == "multipart/signed":
+			if payload.Signed {
+				return nil, Failure(DispositionUnexpectedProcessing, errors.New("message is signed twice"))
+			}
+			parts, err := multipartParts(content, params["boundary"])
+			if err != nil || len(parts) != 2 {
+				return nil, Failure(DispositionUnexpectedProcessing, errors.New("malformed multipart/signed content"))
+			}
+			signatureHeader, signatureContent, err := splitEntity(parts[1])
+			if err != nil {
+				return nil, Failure(DispositionUnexpectedProcessing, err)
+			}
+			signature, err := decodeContent(signatureHeader, signatureContent)
+			if err != nil {
+				return nil, Failure(DispositionUnexpectedProcessing, err)
+			}
+			if partner.Certificate == nil {
+				return nil, Failure(DispositionAuthenticationFailed, errors.New("no partner certificate to verify the signature with"))
+			}
+			hash, err := verifyDetached(signature, parts[0], partner.Certificate)
+			if errors.Is(err, errDigestMismatch) {
+				return nil, Failure(DispositionIntegrityCheckFailed, err)
+			} else if err != nil {
+				return nil, Failure(DispositionAuthenticationFailed, err)
+			}
+			payload.Signed = true
+			micContent = parts[0]
+			if micAlgorithm == "" {
+				micAlgorithm = hashName(hash)
+			}
+			if header, content, err = splitEntity(parts[0]); err != nil {
+				return nil, Failure(DispositionUnexpectedProcessing, err)
+			}
+
+		case isSMIME(mediaType) && smimeType(params, decoded) == "enveloped-data":
+			if payload.Encrypted {
+				return nil, Failure(DispositionUnexpectedProcessing, errors.New("message is encrypted twice"))
+			}
+			if local.Certificate == nil || local.PrivateKey == nil {
+				return nil, Failure(DispositionDecryptionFailed, errors.New("no local key to decrypt the message with"))
+			}
+			decrypted, err := decrypt(decoded, local.Certificate, local.PrivateKey)
+			if err != nil {
+				return nil, Failure(DispositionDecryptionFailed, err)
+			}
+			payload.Encrypted = true
+			if !payload.Signed {
+				micContent = decrypted
+			}
+			if header, content, err = splitEntity(decrypted); err != nil {
+				return nil, Failure(DispositionDecryptionFailed, err)
+			}
+
+		case isSMIME(mediaType) && smimeType(params, decoded) == "compressed-data":
+			decompressed, err := decompress(decoded)
+			if err != nil {
+				return nil, Failure(DispositionUnexpectedProcessing, err)
+			}
+			payload.Compressed = true
+			if !payload.Signed && !payload.Encrypted && micContent == nil {
+				micContent = decoded
+			}
+			if header, content, err = splitEntity(decompressed); err != nil {
+				return nil, Failure(DispositionUnexpectedProcessing, err)
+			}
+
+		case isSMIME(mediaType):
+			return nil, Failure(DispositionUnexpectedProcessing, fmt.Errorf("unsupported S/MIME content %q", params["smime-type"]))
+
+		default:
+			if partner.RequireEncrypted && !payload.Encrypted {
+				return nil, Failure(DispositionInsufficientSecurity, errors.New("the partner requires encrypted messages"))
+			}
+			if partner.RequireSigned && !payload.Signed {
+				return nil, Failure(DispositionInsufficientSecurity, errors.New("the partner requires signed messages"))
+			}
+			if micContent == nil {
+				micContent = body
+			}
+			if micAlgorithm == "" {
+				micAlgorithm = SigningSHA256
+			}
+			payload.Data = decoded
+			payload.ContentType = header.Get("Content-Type")
+			payload.FileName = payloadFileName(header, r.MessageID)
+			payload.MICAlgorithm = micAlgorithm
+			payload.MIC = computeMIC(micContent, signingHashes[micAlgorithm])
+			return payload, nil
+		}
+	}
+}
+
+// isSMIME reports whether a media type holds CMS content
+func isSMIME(mediaType string) bool {
+	return mediaType == "application/pkcs7-mime" || mediaType == "application/x-pkcs7-mime"
+}
+
+// smimeType returns the lower-case smime-type of S/MIME content. Some implementations
+// leave it out, then the CMS content type decides.
+func smimeType(params map[string]string, content []byte) string {
+	if value := params["smime-type"]; value != "" {
+		return strings.ToLower(value)
+	}
+	contentType, _, err := readContentInfo(content)
+	switch {
+	case err != nil:
+		return ""
+	case contentType.Equal(oidEnvelopedData):
+		return "enveloped-data"
+	case contentType.Equal(oidCompressedData):
+		return "compressed-data"
+	case contentType.Equal(oidSignedData):
+		return "signed-data"
+	}
+	return ""
+}
+
+// payloadFileName returns the file name of the payload: the Content-Disposition file name
+// without directories, or one derived from the message ID
+func payloadFileName(header textproto.MIMEHeader, messageID string) string {
+	if _, params, err := mime.ParseMediaType(header.Get("Content-Disposition")); err == nil {
+		name := path.Base(strings.ReplaceAll(params["filename"], `\`, "/"))
+		if name != "" && name != "." && name != "/" && name != ".." {
+			return name
+		}
+	}
+	name := strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '.' || r == '_' {
+			return r
+		}
+		return '_'
+	}, strings.Trim(messageID, "<>"))
+	return fmt.Sprintf("as2-%s.dat", name)
+}
+
+// hashOf returns the hash of a signing algorithm, SHA-256 when it is unknown
+func hashOf(algorithm string) crypto.Hash {
+	if hash, ok := signingHashes[algorithm]; ok {
+		return hash
+	}
+	return crypto.SHA256
+}
@@ -0,0 +1,284 @@
+package as2
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// MDN is a message disposition notification, the receipt of an AS2 message
+type MDN struct {
+	MessageID         string
+	OriginalMessageID string
+	From              string
+	To                string
+	Disposition       string
+	MIC               string
+	MICAlgorithm      string
+	Signed            bool
+}
+
+// Processed reports whether the disposition reports the message as processed without
+// errors. Warnings still count as processed.
+func (m *MDN) Processed() bool {
+	_, status, _ := strings.Cut(m.Disposition, ";")
+	status = strings.ToLower(strings.TrimSpace(status))
+	return status == "processed" || strings.HasPrefix(status, "processed/warning")
+}
+
+// BuildMDN creates the MDN of an inbound message. Without a failure it reports the message
+// as processed with the MIC of the payload, otherwise the failure is reported as an error
+// disposition. The MDN is signed when the sender asked for it.
+func BuildMDN(local Identity, r *Request, payload *Payload, failure error) (*Message, error) {
+	disposition := "automatic-action/MDN-sent-automatically; processed"
+	text := fmt.Sprintf("The AS2 message %s received from %s was processed successfully.", r.MessageID, r.From)
+	if failure != nil {
+		modifier := DispositionUnexpectedProcessing
+		var dispositionErr *DispositionError
+		if errors.As(failure, &dispositionErr) {
+			modifier = dispositionErr.Modifier
+		}
+		disposition += "/error: " + modifier
+		text = fmt.Sprintf("The AS2 message %s received from %s could not be processed: %v", r.MessageID, r.From, failure)
+	}
+
+	var notification bytes.Buffer
+	notification.WriteString("Reporting-UA: " + UserAgent + "\r\n")
+	notification.WriteString("Original-Recipient: rfc822; " + r.To + "\r\n")
+	notification.WriteString("Final-Recipient: rfc822; " + r.To + "\r\n")
+	notification.WriteString("Original-Message-ID: " + r.MessageID + "\r\n")
+	notification.WriteString("Disposition: " + disposition + "\r\n")
+	if failure == nil && payload != nil {
+		notification.WriteString("Received-Content-MIC: " + payload.MIC + ", " + micAlgorithmName(payload.MICAlgorithm) + "\r\n")
+	}
+
+	boundary := "----=_Report_" + strings.ReplaceAll(uuid.New().String(), "-", "")
+	var report bytes.Buffer
+	report.WriteString("--" + boundary + "\r\n")
+	report.WriteString("Content-Type: text/plain; charset=us-ascii\r\n")
+	report.WriteString("Content-Transfer-Encoding: 7bit\r\n\r\n")
+	report.WriteString(text + "\r\n")
+	report.WriteString("\r\n--" + boundary + "\r\n")
+	report.WriteString("Content-Type: message/disposition-notification\r\n")
+	report.WriteString("Content-Transfer-Encoding: 7bit\r\n\r\n")
+	report.Write(notification.Bytes())
+	report.WriteString("\r\n--" + boundary + "--\r\n")
+
+	contentType := mime.FormatMediaType("multipart/report", map[string]string{
+		"report-type": "disposition-notification",
+		"boundary":    boundary,
+	})
+	entity := newEntity([][2]string{{"Content-Type", contentType}}, report.Bytes())
+
+	msg := &Message{MessageID: newMessageID(r.To), Disposition: disposition}
+	if r.SignedReceipt && local.Certificate != nil && local.PrivateKey != nil {
+		algorithm := r.MICAlgorithm
+		if payload != nil && payload.MICAlgorithm != "" {
+			algorithm = payload.MICAlgorithm
+		}
+		signed, err := signEntity(entity, local, hashOf(algorithm))
+		if err != nil {
+			return nil, err
+		}
+		entity = signed
+		msg.Signed = true
+	}
+
+	header, body, err := splitEntity(entity)
+	if err != nil {
+		return nil, err
+	}
+	msg.Body = body
+	msg.Header = http.Header{}
+	msg.Header.Set("Content-Type", header.Get("Content-Type"))
+	msg.Header.Set("MIME-Version", "1.0")
+	msg.Header.Set("AS2-Version", Version)
+	msg.Header.Set("AS2-From", quoteAS2ID(r.To))
+	msg.Header.Set("AS2-To", quoteAS2ID(r.From))
+	msg.Header.Set("Message-ID", msg.MessageID)
+	msg.Header.Set("Subject", "Message Disposition Notification")
+	msg.Header.Set("Date", time.Now().UTC().Format(time.RFC1123Z))
+	msg.Header.Set("User-Agent", UserAgent)
+	return msg, nil
+}
+
+// IsMDN reports whether an inbound request carries an MDN rather than a message: a
+// multipart/report, possibly signed
+func IsMDN(header http.Header, body []byte) bool {
+	entityHeader := textproto.MIMEHeader{"Content-Type": {header.Get("Content-Type")}}
+	mediaType, params := parseContentType(entityHeader)
+	if mediaType == "multipart/report" {
+		return true
+	}
+	if mediaType != "multipart/signed" {
+		return false
+	}
+	parts, err := multipartParts(body, params["boundary"])
+	if err != nil || len(parts) == 0 {
+		return false
+	}
+	partHeader, _, err := splitEntity(parts[0])
+	if err != nil {
+		return false
+	}
+	innerType, _ := parseContentType(partHeader)
+	return innerType == "multipart/report"
+}
+
+// ParseMDN reads an MDN from a response or request. A signed MDN is verified against the
+// partner certificate, an unsigned one is rejected when a signed one is required.
+func ParseMDN(header http.Header, body []byte, partnerCert *x509.Certificate, requireSigned bool) (*MDN, error) {
+	mdn := &MDN{
+		MessageID: strings.TrimSpace(header.Get("Message-ID")),
+		From:      unquoteAS2ID(header.Get("AS2-From")),
+		To:        unquoteAS2ID(header.Get("AS2-To")),
+	}
+	entityHeader := textproto.MIMEHeader{"Content-Type": {header.Get("Content-Type")}}
+	if value := header.Get("Content-Transfer-Encoding"); value != "" {
+		entityHeader.Set("Content-Transfer-Encoding", value)
+	}
+	content := body
+
+	mediaType, params := parseContentType(entityHeader)
+	if mediaType == "multipart/signed" {
+		parts, err := multipartParts(content, params["boundary"])
+		if err != nil || len(parts) != 2 {
+			return nil, errors.New("malformed signed MDN")
+		}
+		signatureHeader, signatureContent, err := splitEntity(parts[1])
+		if err != nil {
+			return nil, fmt.Errorf("malformed MDN signature: %v", err)
+		}
+		signature, err := decodeContent(signatureHeader, signatureContent)
+		if err != nil {
+			return nil, fmt.Errorf("malformed MDN signature: %v", err)
+		}
+		if partnerCert == nil {
+			return nil, errors.New("no partner certificate to verify the MDN signature with")
+		}
+		if _, err := verifyDetached(signature, parts[0], partnerCert); err != nil {
+			return nil, fmt.Errorf("MDN signature is invalid: %v", err)
+		}
+		mdn.Signed = true
+		if entityHeader, content, err = splitEntity(parts[0]); err != nil {
+			return nil, fmt.Errorf("malformed MDN: %v", err)
+		}
+		mediaType, params = parseContentType(entityHeader)
+	} else if requireSigned {
+		return nil, errors.New("MDN is not signed")
+	}
+	if mediaType != "multipart/report" {
+		return nil, fmt.Errorf("response is not an MDN: unexpected content type %q", mediaType)
+	}
+
+	parts, err := multipartParts(content, params["boundary"])
+	if err != nil {
+		return nil, fmt.Errorf("malformed MDN: %v", err)
+	}
+	for _, part := range parts {
+		partHeader, partContent, err := splitEntity(part)
+		if err != nil {
+			continue
+		}
+		if partType, _ := parseContentType(partHeader); partType != "message/disposition-notification" {
+			continue
+		}
+		decoded, err := decodeContent(partHeader, partContent)
+		if err != nil {
+			return nil, fmt.Errorf("malformed MDN: %v", err)
+		}
+		fields, err := textproto.NewReader(bufio.NewReader(io.MultiReader(
+			bytes.NewReader(bytes.TrimSpace(decoded)), strings.NewReader("\r\n\r\n")))).ReadMIMEHeader()
+		if err != nil {
+			return nil, fmt.Errorf("malformed disposition notification: %v", err)
+		}
+		mdn.OriginalMessageID = strings.TrimSpace(fields.Get("Original-Message-ID"))
+		mdn.Disposition = strings.TrimSpace(fields.Get("Disposition"))
+		if mic := fields.Get("Received-Content-MIC"); mic != "" {
+			if index := strings.LastIndex(mic, ","); index >= 0 {
+				mdn.MIC = strings.TrimSpace(mic[:index])
+				mdn.MICAlgorithm, _ = parseMICAlgorithm(mic[index+1:])
+			} else {
+				mdn.MIC = strings.TrimSpace(mic)
+			}
+		}
+		if mdn.Disposition == "" {
+			return nil, errors.New("MDN has no disposition")
+		}
+		return mdn, nil
+	}
+	return nil, errors.New("MDN has no disposition notification")
+}
+
+// CheckMDN checks that an MDN confirms a message was processed with the MIC it was sent with
+func CheckMDN(mdn *MDN, messageID, mic, micAlgorithm string) error {
+	if mdn.OriginalMessageID != messageID {
+		return fmt.Errorf("MDN is for message %s, not %s", mdn.OriginalMessageID, messageID)
+	}
+	if !mdn.Processed() {
+		return fmt.Errorf("partner reported disposition %q", mdn.Disposition)
+	}
+	if mic == "" {
+		return nil
+	}
+	if mdn.MIC == "" {
+		return errors.New("MDN has no MIC to confirm the content")
+	}
+	if mdn.MIC != mic || (mdn.MICAlgorithm != "" && mdn.MICAlgorithm != micAlgorithm) {
+		return fmt.Errorf("MIC mismatch: sent %s (%s), partner received %s (%s)", mic, micAlgorithm, mdn.MIC, mdn.MICAlgorithm)
+	}
+	return nil
+}
+
+// CheckMDNURL checks that the URL a sender asked an asynchronous MDN to be posted to is on
+// the host of the partner's endpoint. The URL comes from the message, without the check
+// anyone could make GoMFT post to any host.
+func (p *Partner) CheckMDNURL(rawURL string) error {
+	target, err := url.Parse(rawURL)
+	if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Host == "" {
+		return fmt.Errorf("asynchronous MDN URL %q must be an http or https URL", rawURL)
+	}
+	if p.URL == "" {
+		return errors.New("asynchronous MDNs are only sent to the host of the partner URL, which is not set")
+	}
+	endpoint, err := url.Parse(p.URL)
+	if err != nil {
+		return fmt.Errorf("invalid partner URL: %v", err)
+	}
+	if !strings.EqualFold(target.Hostname(), endpoint.Hostname()) {
+		return fmt.Errorf("asynchronous MDN URL %q is not on the host of the partner URL, %s", rawURL, endpoint.Hostname())
+	}
+	return nil
+}
+
+// SendMDN posts an asynchronous MDN to the URL the sender asked for
+func SendMDN(ctx context.Context, client *http.Client, url string, mdn *Message) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(mdn.Body))
+	if err != nil {
+		return fmt.Errorf("invalid MDN URL: %v", err)
+	}
+	req.Header = mdn.Header.Clone()
+	req.ContentLength = int64(len(mdn.Body))
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to post MDN: %v", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<20))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("MDN rejected: %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,139 @@
+package as2
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/quotedprintable"
+	"net/textproto"
+	"strings"
+)
+
+// newEntity returns a MIME entity made of header fields, in order, and content
+func newEntity(fields [][2]string, content []byte) []byte {
+	var entity bytes.Buffer
+	for _, field := range fields {
+		entity.WriteString(field[0] + ": " + field[1] + "\r\n")
+	}
+	entity.WriteString("\r\n")
+	entity.Write(content)
+	return entity.Bytes()
+}
+
+// splitEntity returns the header and the raw content of a MIME entity
+func splitEntity(entity []byte) (textproto.MIMEHeader, []byte, error) {
+	end, separator := bytes.Index(entity, []byte("\r\n\r\n")), 4
+	if lf := bytes.Index(entity, []byte("\n\n")); lf >= 0 && (end < 0 || lf < end) {
+		end, separator = lf, 2
+	}
+	if bytes.HasPrefix(entity, []byte("\r\n")) {
+		end, separator = 0, 2
+	} else if bytes.HasPrefix(entity, []byte("\n")) {
+		end, separator = 0, 1
+	}
+	if end < 0 {
+		return nil, nil, errors.New("malformed MIME entity: no end of headers")
+	}
+	header := textproto.MIMEHeader{}
+	if end > 0 {
+		reader := textproto.NewReader(bufio.NewReader(bytes.NewReader(append(append([]byte{}, entity[:end]...), "\r\n\r\n"...))))
+		parsed, err := reader.ReadMIMEHeader()
+		if err != nil {
+			return nil, nil, fmt.Errorf("malformed MIME headers: %v", err)
+		}
+		header = parsed
+	}
+	return header, entity[end+separator:], nil
+}
+
+// decodeContent undoes the transfer encoding of MIME content
+func decodeContent(header textproto.MIMEHeader, content []byte) ([]byte, error) {
+	switch strings.ToLower(strings.TrimSpace(header.Get("Content-Transfer-Encoding"))) {
+	case "base64":
+		decoded, err := base64.StdEncoding.DecodeString(strings.Map(func(r rune) rune {
+			if r == '\r' || r == '\n' || r == ' ' || r == '\t' {
+				return -1
+			}
+			return r
+		}, string(content)))
+		if err != nil {
+			return nil, fmt.Errorf("malformed base64 content: %v", err)
+		}
+		return decoded, nil
+	case "quoted-printable":
+		decoded, err := io.ReadAll(quotedprintable.NewReader(bytes.NewReader(content)))
+		if err != nil {
+			return nil, fmt.Errorf("malformed quoted-printable content: %v", err)
+		}
+		return decoded, nil
+	default:
+		return content, nil
+	}
+}
+
+// parseContentType returns the lower-case media type and parameters of a Content-Type header
+func parseContentType(header textproto.MIMEHeader) (string, map[string]string) {
+	value := header.Get("Content-Type")
+	if value == "" {
+		return "text/plain", map[string]string{}
+	}
+	mediaType, params, err := mime.ParseMediaType(value)
+	if err != nil {
+		// Keep the type of headers with malformed parameters
+		return strings.ToLower(strings.TrimSpace(strings.SplitN(value, ";", 2)[0])), map[string]string{}
+	}
+	return mediaType, params
+}
+
+// multipartParts returns the raw bytes of the body parts of multipart content, exactly
+// as they appear between the boundaries so signatures over them can be verified
+func multipartParts(content []byte, boundary string) ([][]byte, error) {
+	if boundary == "" {
+		return nil, errors.New("multipart content without a boundary")
+	}
+	delimiter := []byte("--" + boundary)
+	var parts [][]byte
+	start := -1
+	for offset := 0; offset <= len(content); {
+		index := bytes.Index(content[offset:], delimiter)
+		if index < 0 {
+			break
+		}
+		index += offset
+		// Delimiters start a line
+		if index > 0 && content[index-1] != '\n' {
+			offset = index + len(delimiter)
+			continue
+		}
+		if start >= 0 {
+			end := index
+			// The line break before the delimiter belongs to it
+			if end > start && content[end-1] == '\n' {
+				end--
+				if end > start && content[end-1] == '\r' {
+					end--
+				}
+			}
+			parts = append(parts, content[start:end])
+		}
+		after := content[index+len(delimiter):]
+		if bytes.HasPrefix(after, []byte("--")) {
+			return parts, nil
+		}
+		lineEnd := bytes.IndexByte(after, '\n')
+		if lineEnd < 0 {
+			break
+		}
+		start = index + len(delimiter) + lineEnd + 1
+		offset = start
+	}
+	if len(parts) == 0 {
+		return nil, errors.New("malformed multipart content")
+	}
+	// Tolerate a missing close delimiter
+	return parts, nil
+}
@@ -0,0 +1,92 @@
+package auth
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"io"
+
+	"github.com/starfleetcptn/gomft/internal/config"
+)
+
+// keyStoreCipher returns the AES-256-GCM cipher used to protect private key material.
+// The configured key is hashed so keys of any length can be used.
+func keyStoreCipher() (cipher.AEAD, error) {
+	appConfig, err := config.Load()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load config: %v", err)
+	}
+
+	secret := appConfig.KeyStoreKey
+	if secret == "" {
+		// Fall back to the TOTP encryption key so existing installs work without extra configuration
+		secret = appConfig.TOTPEncryptKey
+	}
+	if secret == "" {
+		return nil, fmt.Errorf("no key store encryption key configured")
+	}
+
+	key := sha256.Sum256([]byte(secret))
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %v", err)
+	}
+
+	aesGCM, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create GCM: %v", err)
+	}
+	return aesGCM, nil
+}
+
+// EncryptKeyMaterial encrypts private key material for storage in the key store
+func EncryptKeyMaterial(plaintext string) (string, error) {
+	if plaintext == "" {
+		return "", nil
+	}
+
+	aesGCM, err := keyStoreCipher()
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, aesGCM.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", fmt.Errorf("failed to generate nonce: %v", err)
+	}
+
+	ciphertext := aesGCM.Seal(nil, nonce, []byte(plaintext), nil)
+	return base64.StdEncoding.EncodeToString(append(nonce, ciphertext...)), nil
+}
+
+// DecryptKeyMaterial decrypts private key material read from the key store
+func DecryptKeyMaterial(encrypted string) (string, error) {
+	if encrypted == "" {
+		return "", nil
+	}
+
+	aesGCM, err := keyStoreCipher()
+	if err != nil {
+		return "", err
+	}
+
+	decoded, err := base64.StdEncoding.DecodeString(encrypted)
+	if err != nil {
+		return "", fmt.Errorf("failed to decode key material: %v", err)
+	}
+
+	nonceSize := aesGCM.NonceSize()
+	if len(decoded) < nonceSize {
+		return "", fmt.Errorf("ciphertext too short")
+	}
+
+	nonce, ciphertext := decoded[:nonceSize], decoded[nonceSize:]
+	plaintext, err := aesGCM.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt key material: %v", err)
+	}
+	return string(plaintext), nil
+}
@@ -16,6 +16,7 @@ type Config struct {
 	Email          EmailConfig `json:"email"`
 	BaseURL        string      `json:"base_url"`         // Base URL for generating links in emails
 	TOTPEncryptKey string      `json:"totp_encrypt_key"` // Encryption key for TOTP secrets
+	KeyStoreKey    string      `json:"key_store_key"`    // Encryption key for private keys in the key store (defaults to TOTPEncryptKey)
 	SkipSSLVerify  bool        `json:"skip_ssl_verify"`  // Skip SSL verification for outgoing webhooks/notifications
 }
 
@@ -87,6 +88,9 @@ func Load() (*Config, error) {
 		if totpKey := os.Getenv("TOTP_ENCRYPTION_KEY"); totpKey != "" {
 			cfg.TOTPEncryptKey = totpKey
 		}
+		if keyStoreKey := os.Getenv("KEY_STORE_ENCRYPTION_KEY"); keyStoreKey != "" {
+			cfg.KeyStoreKey = keyStoreKey
+		}
 
 		// Email configuration
 		if emailEnabled := os.Getenv("EMAIL_ENABLED"); emailEnabled != "" {
@@ -146,6 +150,9 @@ func Load() (*Config, error) {
 			"# Two-Factor Authentication configuration",
 			"TOTP_ENCRYPTION_KEY=" + cfg.TOTPEncryptKey,
 			"",
+			"# Key store configuration (private PGP and age keys, defaults to TOTP_ENCRYPTION_KEY)",
+			"# KEY_STORE_ENCRYPTION_KEY=",
+			"",
 			"# Email configuration",
 			"EMAIL_ENABLED=" + strconv.FormatBool(cfg.Email.Enabled),
 			"EMAIL_HOST=" + cfg.Email.Host,
@@ -0,0 +1,139 @@
+package db
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/starfleetcptn/gomft/internal/as2"
+	"github.com/starfleetcptn/gomft/internal/auth"
+)
+
+// AS2 message directions
+const (
+	AS2Outbound = "outbound"
+	AS2Inbound  = "inbound"
+)
+
+// MDN statuses of AS2 messages
+const (
+	MDNStatusNone      = "none"      // No MDN was requested
+	MDNStatusPending   = "pending"   // Waiting for an asynchronous MDN
+	MDNStatusProcessed = "processed" // The MDN confirmed the message with a matching MIC
+	MDNStatusFailed    = "failed"    // The message or its MDN failed
+	MDNStatusSent      = "sent"      // An MDN was returned for an inbound message
+)
+
+// AS2Partner is an AS2 trading partnership: the partner's AS2 ID, endpoint and certificate,
+// the local AS2 ID, certificate and key used with it, and the message options. Configs
+// reference partners by name.
+type AS2Partner struct {
+	ID                  uint      `gorm:"primarykey" json:"id"`
+	Name                string    `gorm:"not null;uniqueIndex" json:"name"`
+	AS2ID               string    `gorm:"not null" json:"as2_id"`
+	LocalAS2ID          string    `gorm:"not null" json:"local_as2_id"`
+	URL                 string    `json:"url"`                                // Endpoint outbound messages are posted to
+	Certificate         string    `gorm:"type:text" json:"certificate"`       // Partner certificate, PEM
+	LocalCertificate    string    `gorm:"type:text" json:"local_certificate"` // PEM
+	LocalPrivateKey     string    `gorm:"type:text" json:"-"`                 // Encrypted at rest, PEM
+	ContentType         string    `json:"content_type"`
+	Sign                bool      `json:"sign"`
+	SigningAlgorithm    string    `json:"signing_algorithm"`
+	Encrypt             bool      `json:"encrypt"`
+	EncryptionAlgorithm string    `json:"encryption_algorithm"`
+	Compress            bool      `json:"compress"`
+	MDNMode             string    `json:"mdn_mode"` // "", sync or async
+	SignedMDN           bool      `json:"signed_mdn"`
+	AsyncMDNURL         string    `json:"async_mdn_url"` // URL of this instance's AS2 endpoint
+	RequireSigned       bool      `json:"require_signed"`
+	RequireEncrypted    bool      `json:"require_encrypted"`
+	InboundConfigID     uint      `json:"inbound_config_id"` // Config whose destination receives inbound payloads, 0 rejects them
+	CreatedBy           uint      `json:"created_by"`
+	CreatedAt           time.Time `json:"created_at"`
+	UpdatedAt           time.Time `json:"updated_at"`
+}
+
+// AS2Message records an AS2 message sent to or received from a partner and the state of
+// its MDN
+type AS2Message struct {
+	ID              uint       `gorm:"primarykey" json:"id"`
+	MessageID       string     `gorm:"index;not null" json:"message_id"`
+	Direction       string     `gorm:"not null" json:"direction"`
+	PartnerID       uint       `json:"partner_id"`
+	PartnerName     string     `json:"partner_name"`
+	JobHistoryID    uint       `gorm:"index" json:"job_history_id"` // Run that sent the message, 0 for inbound messages
+	ConfigID        uint       `json:"config_id"`
+	FileName        string     `json:"file_name"`
+	FileSize        int64      `json:"file_size"`
+	DestinationPath string     `json:"destination_path"` // Where an inbound payload was delivered
+	MIC             string     `json:"mic"`
+	MICAlgorithm    string     `json:"mic_algorithm"`
+	Signed          bool       `json:"signed"`
+	Encrypted       bool       `json:"encrypted"`
+	Compressed      bool       `json:"compressed"`
+	MDNMode         string     `json:"mdn_mode"`
+	MDNStatus       string     `gorm:"column:mdn_status" json:"mdn_status"`
+	MDNDisposition  string     `json:"mdn_disposition"`
+	MDNReceivedAt   *time.Time `json:"mdn_received_at"`
+	ErrorMessage    string     `json:"error_message"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+}
+
+// Profile returns the local identity and partner settings of the partnership, with the
+// certificates parsed and the private key decrypted
+func (p *AS2Partner) Profile() (as2.Identity, as2.Partner, error) {
+	local := as2.Identity{AS2ID: p.LocalAS2ID}
+	partner := as2.Partner{
+		AS2ID:               p.AS2ID,
+		URL:                 p.URL,
+		ContentType:         p.ContentType,
+		Sign:                p.Sign,
+		SigningAlgorithm:    p.SigningAlgorithm,
+		Encrypt:             p.Encrypt,
+		EncryptionAlgorithm: p.EncryptionAlgorithm,
+		Compress:            p.Compress,
+		MDN:                 p.MDNMode,
+		SignedMDN:           p.SignedMDN,
+		AsyncMDNURL:         p.AsyncMDNURL,
+		RequireSigned:       p.RequireSigned,
+		RequireEncrypted:    p.RequireEncrypted,
+	}
+
+	var err error
+	if p.Certificate != "" {
+		if partner.Certificate, err = as2.ParseCertificate([]byte(p.Certificate)); err != nil {
+			return local, partner, fmt.Errorf("partner certificate: %v", err)
+		}
+	}
+	if p.LocalCertificate != "" {
+		if local.Certificate, err = as2.ParseCertificate([]byte(p.LocalCertificate)); err != nil {
+			return local, partner, fmt.Errorf("local certificate: %v", err)
+		}
+	}
+	if p.LocalPrivateKey != "" {
+		keyPEM, err := auth.DecryptKeyMaterial(p.LocalPrivateKey)
+		if err != nil {
+			return local, partner, fmt.Errorf("failed to decrypt the local private key: %v", err)
+		}
+		if local.PrivateKey, err = as2.ParsePrivateKey([]byte(keyPEM)); err != nil {
+			return local, partner, fmt.Errorf("local private key: %v", err)
+		}
+	}
+	return local, partner, nil
+}
+
+// SetLocalIdentity stores the local certificate and private key of the partnership. The
+// key is encrypted before it is stored.
+func (p *AS2Partner) SetLocalIdentity(certPEM, keyPEM string) error {
+	encrypted, err := auth.EncryptKeyMaterial(keyPEM)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt the local private key: %v", err)
+	}
+	p.LocalCertificate, p.LocalPrivateKey = certPEM, encrypted
+	return nil
+}
+
+// IsAS2Destination reports whether the config delivers its files to an AS2 partner
+func (tc *TransferConfig) IsAS2Destination() bool {
+	return tc.DestinationType == "as2"
+}
@@ -0,0 +1,148 @@
+package db
+
+import (
+	"fmt"
+	"time"
+)
+
+// --- AS2 Partner Store Methods ---
+
+// GetAS2Partners returns all AS2 partners ordered by name
+func (db *DB) GetAS2Partners() ([]AS2Partner, error) {
+	var partners []AS2Partner
+	err := db.Order("name asc").Find(&partners).Error
+	return partners, err
+}
+
+// GetAS2Partner returns a specific AS2 partner by ID
+func (db *DB) GetAS2Partner(id uint) (*AS2Partner, error) {
+	var partner AS2Partner
+	err := db.First(&partner, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &partner, nil
+}
+
+// GetAS2PartnerByName returns the AS2 partner with the given name
+func (db *DB) GetAS2PartnerByName(name string) (*AS2Partner, error) {
+	var partner AS2Partner
+	err := db.Where("name = ?", name).First(&partner).Error
+	if err != nil {
+		return nil, err
+	}
+	return &partner, nil
+}
+
+// FindAS2Partnership returns the partner an inbound message or MDN belongs to, from the
+// AS2-From and AS2-To headers: the partner's AS2 ID and the local one
+func (db *DB) FindAS2Partnership(from, to string) (*AS2Partner, error) {
+	var partner AS2Partner
+	err := db.Where("as2_id = ? AND local_as2_id = ?", from, to).First(&partner).Error
+	if err != nil {
+		return nil, err
+	}
+	return &partner, nil
+}
+
+// CreateAS2Partner adds an AS2 partner
+func (db *DB) CreateAS2Partner(partner *AS2Partner) error {
+	return db.Create(partner).Error
+}
+
+// UpdateAS2Partner saves the changes to an AS2 partner
+func (db *DB) UpdateAS2Partner(partner *AS2Partner) error {
+	return db.Save(partner).Error
+}
+
+// DeleteAS2Partner removes an AS2 partner
+func (db *DB) DeleteAS2Partner(id uint) error {
+	result := db.Delete(&AS2Partner{}, id)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("AS2 partner not found: %d", id)
+	}
+	return nil
+}
+
+// CountConfigsUsingAS2Partner returns the number of transfer configs that send to a partner
+func (db *DB) CountConfigsUsingAS2Partner(name string) (int64, error) {
+	var count int64
+	err := db.Model(&TransferConfig{}).Where("destination_type = ? AND dest_as2_partner = ?", "as2", name).Count(&count).Error
+	return count, err
+}
+
+// CheckAS2Partner returns an error when the config sends to an AS2 partner that does not
+// exist or cannot send
+func (db *DB) CheckAS2Partner(config *TransferConfig) error {
+	if !config.IsAS2Destination() {
+		return nil
+	}
+	partner, err := db.GetAS2PartnerByName(config.DestAS2Partner)
+	if err != nil {
+		return fmt.Errorf("AS2 partner %q not found", config.DestAS2Partner)
+	}
+	if partner.URL == "" {
+		return fmt.Errorf("AS2 partner %q has no URL to send to", partner.Name)
+	}
+	return nil
+}
+
+// --- AS2 Message Store Methods ---
+
+// CreateAS2Message records an AS2 message
+func (db *DB) CreateAS2Message(message *AS2Message) error {
+	return db.Create(message).Error
+}
+
+// UpdateAS2Message saves the changes to an AS2 message
+func (db *DB) UpdateAS2Message(message *AS2Message) error {
+	return db.Save(message).Error
+}
+
+// GetOutboundAS2Message returns the message sent with the given message ID, to match an
+// asynchronous MDN
+func (db *DB) GetOutboundAS2Message(messageID string) (*AS2Message, error) {
+	var message AS2Message
+	err := db.Where("message_id = ? AND direction = ?", messageID, AS2Outbound).First(&message).Error
+	if err != nil {
+		return nil, err
+	}
+	return &message, nil
+}
+
+// HasReceivedAS2Message reports whether the payload of a message with the given message ID
+// from a partner was already delivered
+func (db *DB) HasReceivedAS2Message(partnerID uint, messageID string) (bool, error) {
+	var count int64
+	err := db.Model(&AS2Message{}).
+		Where("message_id = ? AND direction = ? AND partner_id = ? AND destination_path <> ''", messageID, AS2Inbound, partnerID).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// GetAS2MessagesForRun returns the messages a run sent, oldest first
+func (db *DB) GetAS2MessagesForRun(historyID uint) ([]AS2Message, error) {
+	var messages []AS2Message
+	err := db.Where("job_history_id = ?", historyID).Order("id asc").Find(&messages).Error
+	return messages, err
+}
+
+// GetRecentAS2Messages returns the most recent messages exchanged with all partners
+func (db *DB) GetRecentAS2Messages(limit int) ([]AS2Message, error) {
+	var messages []AS2Message
+	err := db.Order("created_at desc").Limit(limit).Find(&messages).Error
+	return messages, err
+}
+
+// RecordAS2MDN stores the outcome of the MDN of a message
+func (db *DB) RecordAS2MDN(message *AS2Message, status, disposition, errorMessage string) error {
+	now := time.Now()
+	message.MDNStatus = status
+	message.MDNDisposition = disposition
+	message.MDNReceivedAt = &now
+	message.ErrorMessage = errorMessage
+	return db.UpdateAS2Message(message)
+}
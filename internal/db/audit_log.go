@@ -44,3 +44,8 @@ func (d AuditLogDetails) Value() (driver.Value, error) {
 	}
 	return json.Marshal(d)
 }
+
+// CreateAuditLog creates a new audit log entry
+func (db *DB) CreateAuditLog(log *AuditLog) error {
+	return db.Create(log).Error
+}
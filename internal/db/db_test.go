@@ -0,0 +1,170 @@
+package db
+
+import (
+	"path/filepath"
+	"slices"
+	"sync"
+	"testing"
+
+	"gorm.io/gorm/schema"
+)
+
+// newTestDB returns a database migrated with the migrations GoMFT runs at startup, so the
+// models are checked against the tables the migrations create rather than AutoMigrate's
+func newTestDB(t *testing.T) *DB {
+	t.Helper()
+	// Migrations back up the database before they run, keep the backups out of the tree
+	dir := t.TempDir()
+	t.Setenv("BACKUP_DIR", filepath.Join(dir, "backups"))
+	database, err := Initialize(filepath.Join(dir, "gomft.db"))
+	if err != nil {
+		t.Fatalf("Initialize() error = %v", err)
+	}
+	t.Cleanup(func() { database.Close() })
+	return database
+}
+
+func TestMigratedSchema(t *testing.T) {
+	database := newTestDB(t)
+
+	models := []interface{}{
+		&User{}, &PasswordHistory{}, &PasswordResetToken{}, &Role{},
+		&TransferConfig{}, &Job{}, &JobHistory{}, &FileMetadata{}, &RunFile{},
+		&RcloneCommand{}, &RcloneCommandFlag{}, &NotificationService{}, &UserNotification{},
+		&AuthProvider{}, &ExternalUserIdentity{}, &AuditLog{},
+		&EncryptionKey{}, &SSHKey{}, &HostKey{}, &AS2Partner{}, &AS2Message{},
+	}
+	for _, model := range models {
+		parsed, err := schema.Parse(model, &sync.Map{}, database.NamingStrategy)
+		if err != nil {
+			t.Fatalf("schema.Parse(%T) error = %v", model, err)
+		}
+		if !database.Migrator().HasTable(parsed.Table) {
+			t.Errorf("table %s of %T does not exist", parsed.Table, model)
+			continue
+		}
+		for _, field := range parsed.Fields {
+			if field.DBName != "" && !database.Migrator().HasColumn(model, field.DBName) {
+				t.Errorf("%T.%s: table %s has no column %s", model, field.Name, parsed.Table, field.DBName)
+			}
+		}
+	}
+}
+
+func TestTransferConfigStore(t *testing.T) {
+	database := newTestDB(t)
+
+	config := &TransferConfig{
+		Name:             "FTPS partner",
+		SourceType:       "ftp",
+		SourceHost:       "ftp.example.com",
+		SourcePath:       "/outbound",
+		SourceFTPSMode:   "explicit",
+		SourceFTPSCACert: "source CA",
+		DestinationType:  "ftp",
+		DestHost:         "ftp.example.org",
+		DestinationPath:  "/inbound",
+		DestFTPSMode:     "implicit",
+		DestFTPSCACert:   "dest CA",
+		CreatedBy:        1,
+	}
+	if err := database.CreateTransferConfig(config); err != nil {
+		t.Fatalf("CreateTransferConfig() error = %v", err)
+	}
+
+	config.DestFTPSCACert = "rotated CA"
+	if err := database.UpdateTransferConfig(config); err != nil {
+		t.Fatalf("UpdateTransferConfig() error = %v", err)
+	}
+
+	loaded, err := database.GetTransferConfig(config.ID)
+	if err != nil {
+		t.Fatalf("GetTransferConfig() error = %v", err)
+	}
+	if loaded.SourceFTPSCACert != "source CA" || loaded.DestFTPSCACert != "rotated CA" {
+		t.Errorf("GetTransferConfig() CA certificates = %q, %q", loaded.SourceFTPSCACert, loaded.DestFTPSCACert)
+	}
+}
+
+func TestGetConfigIDsWithDestination(t *testing.T) {
+	database := newTestDB(t)
+
+	var saved []*TransferConfig
+	for _, name := range []string{"first", "second"} {
+		config := &TransferConfig{Name: name, SourceType: "local", SourcePath: "/outbound",
+			DestinationType: "sftp", DestHost: "sftp.example.com", DestinationPath: "/inbound", CreatedBy: 1}
+		if err := database.CreateTransferConfig(config); err != nil {
+			t.Fatalf("CreateTransferConfig() error = %v", err)
+		}
+		saved = append(saved, config)
+	}
+	other := &TransferConfig{Name: "other", SourceType: "local", SourcePath: "/outbound",
+		DestinationType: "sftp", DestHost: "sftp.example.org", DestinationPath: "/inbound", CreatedBy: 1}
+	if err := database.CreateTransferConfig(other); err != nil {
+		t.Fatalf("CreateTransferConfig() error = %v", err)
+	}
+
+	ids, err := database.GetConfigIDsWithDestination(saved[0])
+	if err != nil {
+		t.Fatalf("GetConfigIDsWithDestination() error = %v", err)
+	}
+	slices.Sort(ids)
+	if want := []uint{saved[0].ID, saved[1].ID}; !slices.Equal(ids, want) {
+		t.Errorf("GetConfigIDsWithDestination() = %v, want %v", ids, want)
+	}
+
+	// A config that is not saved yet is included as well
+	created := &TransferConfig{DestinationType: "sftp", DestHost: "sftp.example.com", DestinationPath: "/inbound"}
+	ids, err = database.GetConfigIDsWithDestination(created)
+	if err != nil {
+		t.Fatalf("GetConfigIDsWithDestination() error = %v", err)
+	}
+	slices.Sort(ids)
+	if want := []uint{0, saved[0].ID, saved[1].ID}; !slices.Equal(ids, want) {
+		t.Errorf("GetConfigIDsWithDestination() = %v, want %v", ids, want)
+	}
+}
+
+func TestAS2MessageStore(t *testing.T) {
+	database := newTestDB(t)
+
+	received := &AS2Message{
+		MessageID: "<1@partner>",
+		Direction: AS2Inbound,
+		PartnerID: 1,
+		MDNStatus: MDNStatusNone,
+	}
+	if err := database.CreateAS2Message(received); err != nil {
+		t.Fatalf("CreateAS2Message() error = %v", err)
+	}
+	if found, err := database.HasReceivedAS2Message(1, "<1@partner>"); err != nil || found {
+		t.Fatalf("HasReceivedAS2Message() before delivery = %v, %v", found, err)
+	}
+
+	received.DestinationPath = "/inbound/invoice.edi"
+	received.MDNStatus = MDNStatusSent
+	if err := database.UpdateAS2Message(received); err != nil {
+		t.Fatalf("UpdateAS2Message() error = %v", err)
+	}
+	if found, err := database.HasReceivedAS2Message(1, "<1@partner>"); err != nil || !found {
+		t.Errorf("HasReceivedAS2Message() after delivery = %v, %v", found, err)
+	}
+	if found, err := database.HasReceivedAS2Message(2, "<1@partner>"); err != nil || found {
+		t.Errorf("HasReceivedAS2Message() of another partner = %v, %v", found, err)
+	}
+
+	sent := &AS2Message{MessageID: "<2@gomft>", Direction: AS2Outbound, PartnerID: 1, MDNStatus: MDNStatusPending}
+	if err := database.CreateAS2Message(sent); err != nil {
+		t.Fatalf("CreateAS2Message() error = %v", err)
+	}
+	if err := database.RecordAS2MDN(sent, MDNStatusProcessed, "automatic-action/MDN-sent-automatically; processed", ""); err != nil {
+		t.Fatalf("RecordAS2MDN() error = %v", err)
+	}
+	loaded, err := database.GetOutboundAS2Message("<2@gomft>")
+	if err != nil {
+		t.Fatalf("GetOutboundAS2Message() error = %v", err)
+	}
+	if loaded.MDNStatus != MDNStatusProcessed || loaded.MDNReceivedAt == nil {
+		t.Errorf("GetOutboundAS2Message() MDN status = %q, received at %v", loaded.MDNStatus, loaded.MDNReceivedAt)
+	}
+}
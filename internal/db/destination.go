@@ -0,0 +1,199 @@
+package db
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+// Destination is an additional destination of a transfer config. Files of the config are
+// read once and delivered to the main destination and to each additional destination.
+type Destination struct {
+	ID            string `json:"id"`   // Stable ID that names the rclone remote of the destination
+	Name          string `json:"name"` // Label shown in the file metadata
+	Type          string `json:"type"`
+	Path          string `json:"path"`
+	Host          string `json:"host,omitempty"`
+	Port          int    `json:"port,omitempty"`
+	User          string `json:"user,omitempty"`
+	Password      string `json:"password,omitempty"` // Not stored in DB, only used for the rclone config
+	KeyFile       string `json:"key_file,omitempty"`
+	SSHKey        string `json:"ssh_key,omitempty"` // Name of a managed SSH key
+	Bucket        string `json:"bucket,omitempty"`
+	Region        string `json:"region,omitempty"`
+	AccessKey     string `json:"access_key,omitempty"`
+	SecretKey     string `json:"secret_key,omitempty"` // Not stored in DB, only used for the rclone config
+	Endpoint      string `json:"endpoint,omitempty"`
+	OutputPattern string `json:"output_pattern,omitempty"` // Pattern for output filenames at this destination
+}
+
+// Label returns the name of the destination at the given position of the config's list
+func (d Destination) Label(index int) string {
+	if d.Name != "" {
+		return d.Name
+	}
+	return fmt.Sprintf("Destination %d", index+2)
+}
+
+// additionalDestinationTypes are the storage types an additional destination can use.
+// Types that authenticate through OAuth are only available as the main destination.
+var additionalDestinationTypes = map[string]bool{
+	"local":     true,
+	"sftp":      true,
+	"hetzner":   true,
+	"s3":        true,
+	"wasabi":    true,
+	"b2":        true,
+	"minio":     true,
+	"azureblob": true,
+	"webdav":    true,
+	"nextcloud": true,
+}
+
+// ParseDestinations parses the JSON list of additional destinations of a config
+func ParseDestinations(destinationsJSON string) ([]Destination, error) {
+	if destinationsJSON == "" {
+		return nil, nil
+	}
+	var destinations []Destination
+	if err := json.Unmarshal([]byte(destinationsJSON), &destinations); err != nil {
+		return nil, fmt.Errorf("invalid destinations: %v", err)
+	}
+	for i, destination := range destinations {
+		if !additionalDestinationTypes[destination.Type] {
+			return nil, fmt.Errorf("%s: unsupported destination type %q", destination.Label(i), destination.Type)
+		}
+	}
+	return destinations, nil
+}
+
+// GetDestinations returns the additional destinations of the config
+func (tc *TransferConfig) GetDestinations() ([]Destination, error) {
+	return ParseDestinations(tc.Destinations)
+}
+
+// ForDestination returns a copy of the config that delivers to an additional
+// destination instead of the main one. The copy uses its own rclone remote.
+func (tc *TransferConfig) ForDestination(destination Destination) TransferConfig {
+	config := *tc
+	config.DestRemote = fmt.Sprintf("dest_%d_%s", tc.ID, destination.ID)
+	config.DestinationType = destination.Type
+	config.DestinationPath = destination.Path
+	config.DestHost = destination.Host
+	config.DestPort = destination.Port
+	config.DestUser = destination.User
+	config.DestPassword = destination.Password
+	config.DestKeyFile = destination.KeyFile
+	config.DestSSHKey = destination.SSHKey
+	config.DestBucket = destination.Bucket
+	config.DestRegion = destination.Region
+	config.DestAccessKey = destination.AccessKey
+	config.DestSecretKey = destination.SecretKey
+	config.DestEndpoint = destination.Endpoint
+	config.DestSasURL = ""
+	config.OutputPattern = destination.OutputPattern
+	// Crypt remotes and retention only apply to the main destination
+	destCrypt := false
+	config.DestCrypt = &destCrypt
+	config.DestRetentionKeepLast = 0
+	config.DestRetentionMaxAgeDays = 0
+	config.DestRetentionMaxSize = ""
+	return config
+}
+
+// newDestinationID returns a random ID for a new destination
+func newDestinationID() (string, error) {
+	b := make([]byte, 4)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// GetDestRemote returns the name of the rclone remote of the destination
+func (tc *TransferConfig) GetDestRemote() string {
+	if tc.DestRemote != "" {
+		return tc.DestRemote
+	}
+	return fmt.Sprintf("dest_%d", tc.ID)
+}
+
+// BeforeSave gives new additional destinations an ID and removes their secrets, which
+// are not stored
+func (tc *TransferConfig) BeforeSave(tx *gorm.DB) error {
+	destinations, err := tc.GetDestinations()
+	if err != nil || len(destinations) == 0 {
+		return nil
+	}
+	used := make(map[string]bool)
+	for i := range destinations {
+		for destinations[i].ID == "" || used[destinations[i].ID] {
+			if destinations[i].ID, err = newDestinationID(); err != nil {
+				return err
+			}
+		}
+		used[destinations[i].ID] = true
+	}
+	submittedJSON, err := json.Marshal(destinations)
+	if err != nil {
+		return err
+	}
+	stripped := make([]Destination, len(destinations))
+	for i, destination := range destinations {
+		destination.Password = ""
+		destination.SecretKey = ""
+		stripped[i] = destination
+	}
+	destinationsJSON, err := json.Marshal(stripped)
+	if err != nil {
+		return err
+	}
+	tc.submittedDestinations = string(submittedJSON)
+	tc.Destinations = string(destinationsJSON)
+	return nil
+}
+
+// AfterSave restores the submitted destinations so the rclone config can still be
+// generated with their secrets
+func (tc *TransferConfig) AfterSave(tx *gorm.DB) error {
+	if tc.submittedDestinations != "" {
+		tc.Destinations = tc.submittedDestinations
+		tc.submittedDestinations = ""
+	}
+	return nil
+}
+
+// DestinationResult is the outcome of delivering a file to one destination
+type DestinationResult struct {
+	Destination    string `json:"destination"`
+	Path           string `json:"path,omitempty"`
+	Status         string `json:"status"` // processed, skipped or error
+	ConflictAction string `json:"conflict_action,omitempty"`
+	Error          string `json:"error,omitempty"`
+}
+
+// SetDestinationResults stores the per-destination outcome of a fanned out file
+func (m *FileMetadata) SetDestinationResults(results []DestinationResult) {
+	if len(results) == 0 {
+		m.DestinationResults = ""
+		return
+	}
+	if resultsJSON, err := json.Marshal(results); err == nil {
+		m.DestinationResults = string(resultsJSON)
+	}
+}
+
+// GetDestinationResults returns the per-destination outcome of a fanned out file
+func (m *FileMetadata) GetDestinationResults() []DestinationResult {
+	if m.DestinationResults == "" {
+		return nil
+	}
+	var results []DestinationResult
+	if err := json.Unmarshal([]byte(m.DestinationResults), &results); err != nil {
+		return nil
+	}
+	return results
+}
@@ -0,0 +1,24 @@
+package db
+
+import (
+	"time"
+)
+
+// EncryptionKey is a PGP or age key used to encrypt, decrypt, sign or verify transferred files
+type EncryptionKey struct {
+	ID          uint      `gorm:"primarykey" json:"id"`
+	Name        string    `gorm:"not null" json:"name"`
+	Type        string    `gorm:"not null" json:"type"` // pgp or age
+	Fingerprint string    `gorm:"index" json:"fingerprint"`
+	PublicKey   string    `gorm:"type:text" json:"public_key"` // Armored PGP public key or age recipient
+	PrivateKey  string    `gorm:"type:text" json:"-"`          // Encrypted at rest, empty for public keys
+	Passphrase  string    `json:"-"`                           // Encrypted passphrase of a protected PGP private key
+	CreatedBy   uint      `json:"created_by"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// HasPrivateKey reports whether the key can be used to decrypt or sign
+func (k *EncryptionKey) HasPrivateKey() bool {
+	return k.PrivateKey != ""
+}
@@ -0,0 +1,84 @@
+package db
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// --- Encryption Key Store Methods ---
+
+// GetEncryptionKeys returns all keys in the key store ordered by name
+func (db *DB) GetEncryptionKeys() ([]EncryptionKey, error) {
+	var keys []EncryptionKey
+	err := db.Order("name asc").Find(&keys).Error
+	return keys, err
+}
+
+// GetEncryptionKey returns a specific key by ID
+func (db *DB) GetEncryptionKey(id uint) (*EncryptionKey, error) {
+	var key EncryptionKey
+	err := db.First(&key, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+// CreateEncryptionKey adds a key to the key store
+func (db *DB) CreateEncryptionKey(key *EncryptionKey) error {
+	return db.Create(key).Error
+}
+
+// DeleteEncryptionKey removes a key from the key store
+func (db *DB) DeleteEncryptionKey(id uint) error {
+	result := db.Delete(&EncryptionKey{}, id)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("encryption key not found: %d", id)
+	}
+	return nil
+}
+
+// CountConfigsUsingEncryptionKey returns the number of transfer configs that reference a key
+func (db *DB) CountConfigsUsingEncryptionKey(id uint) (int64, error) {
+	var configs []TransferConfig
+	if err := db.Where("encryption_mode <> ''").Find(&configs).Error; err != nil {
+		return 0, err
+	}
+
+	var count int64
+	for _, config := range configs {
+		if config.SigningKeyID == id || containsKeyID(config.EncryptionKeyIDs, id) || containsKeyID(config.VerifyKeyIDs, id) {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// ParseKeyIDs parses a comma separated list of key IDs
+func ParseKeyIDs(ids string) []uint {
+	var result []uint
+	for _, part := range strings.Split(ids, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		if id, err := strconv.ParseUint(part, 10, 64); err == nil && id > 0 {
+			result = append(result, uint(id))
+		}
+	}
+	return result
+}
+
+// containsKeyID reports whether a comma separated list of key IDs contains id
+func containsKeyID(ids string, id uint) bool {
+	for _, keyID := range ParseKeyIDs(ids) {
+		if keyID == id {
+			return true
+		}
+	}
+	return false
+}
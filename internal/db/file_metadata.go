@@ -4,22 +4,35 @@ import (
 	"time"
 )
 
+// FileStatusRetentionDeleted is the status of the file metadata recorded when retention
+// deletes a file from the archive or the destination. These records describe the stored
+// copy, not the transfer of the source file.
+const FileStatusRetentionDeleted = "retention_deleted"
+
 // FileMetadata stores information about processed files
 type FileMetadata struct {
-	ID              uint   `gorm:"primarykey"`
-	JobID           uint   `gorm:"not null;index"`
-	Job             Job    `gorm:"foreignkey:JobID"`
-	ConfigID        uint   `gorm:"default:0"` // The specific config ID this file was processed with
-	FileName        string `gorm:"not null"`
-	OriginalPath    string `gorm:"not null"`
-	FileSize        int64  `gorm:"not null"`
-	FileHash        string `gorm:"index"` // MD5 or other hash for file identity
-	CreationTime    time.Time
-	ModTime         time.Time
-	ProcessedTime   time.Time `gorm:"not null"`
-	DestinationPath string    `gorm:"not null"`
-	Status          string    `gorm:"not null"` // processed, archived, deleted, etc.
-	ErrorMessage    string
-	CreatedAt       time.Time
-	UpdatedAt       time.Time
+	ID                    uint   `gorm:"primarykey"`
+	JobID                 uint   `gorm:"not null;index"`
+	Job                   Job    `gorm:"foreignkey:JobID"`
+	ConfigID              uint   `gorm:"default:0"` // The specific config ID this file was processed with
+	FileName              string `gorm:"not null"`
+	OriginalPath          string `gorm:"not null"`
+	FileSize              int64  `gorm:"not null"`
+	FileHash              string `gorm:"index"` // MD5 or other hash for file identity
+	OriginalSize          int64  // Uncompressed size when compression was applied
+	CompressedSize        int64  // Compressed size when compression was applied
+	EncryptionOperation   string // encrypted, encrypted_signed or decrypted
+	EncryptionFingerprint string // Fingerprint of the key(s) used
+	SignatureStatus       string // signed, valid, invalid, unknown_signer or unsigned
+	ConflictAction        string // overwritten, skipped, renamed or versioned when the destination already existed
+	CreationTime          time.Time
+	ModTime               time.Time
+	ProcessedTime         time.Time `gorm:"not null"`
+	DestinationPath       string    `gorm:"not null"`
+	Status                string    `gorm:"not null"` // processed, archived, deleted, etc.
+	ErrorMessage          string
+	FailureCount          int    // Consecutive failed transfers of the file, reset once it is delivered
+	DestinationResults    string `gorm:"type:text"` // JSON outcome per destination when a config has additional destinations
+	CreatedAt             time.Time
+	UpdatedAt             time.Time
 }
@@ -7,16 +7,33 @@ func (db *DB) CreateFileMetadata(metadata *FileMetadata) error {
 	return db.Create(metadata).Error
 }
 
-// GetFileMetadataByJobAndName retrieves file metadata by job ID and filename
+// GetFileMetadataByJobAndName retrieves file metadata of a transfer by job ID and filename
 func (db *DB) GetFileMetadataByJobAndName(jobID uint, fileName string) (*FileMetadata, error) {
 	var metadata FileMetadata
-	err := db.Where("job_id = ? AND file_name = ?", jobID, fileName).First(&metadata).Error
+	err := db.Where("job_id = ? AND file_name = ? AND status != ?", jobID, fileName, FileStatusRetentionDeleted).First(&metadata).Error
 	if err != nil {
 		return nil, err
 	}
 	return &metadata, nil
 }
 
+// GetLatestFileMetadata retrieves the most recent file metadata of a transfer of a file for a
+// job and config. Retention records are skipped as they do not describe a transfer.
+func (db *DB) GetLatestFileMetadata(jobID, configID uint, fileName string) (*FileMetadata, error) {
+	var metadata FileMetadata
+	err := db.Where("job_id = ? AND config_id = ? AND file_name = ? AND status != ?", jobID, configID, fileName, FileStatusRetentionDeleted).
+		Order("id desc").First(&metadata).Error
+	if err != nil {
+		return nil, err
+	}
+	return &metadata, nil
+}
+
+// UpdateFileMetadata updates an existing file metadata record
+func (db *DB) UpdateFileMetadata(metadata *FileMetadata) error {
+	return db.Save(metadata).Error
+}
+
 // GetFileMetadataByHash retrieves file metadata by file hash
 func (db *DB) GetFileMetadataByHash(fileHash string) (*FileMetadata, error) {
 	var metadata FileMetadata
@@ -27,6 +44,26 @@ func (db *DB) GetFileMetadataByHash(fileHash string) (*FileMetadata, error) {
 	return &metadata, nil
 }
 
+// FindDeliveredFileByContent retrieves the earliest delivered file with the given hash and
+// size. The lookup is limited to a job when jobID is not 0 and to the given configs when
+// configIDs is not nil.
+func (db *DB) FindDeliveredFileByContent(fileHash string, fileSize int64, jobID uint, configIDs []uint) (*FileMetadata, error) {
+	query := db.Where("file_hash = ? AND file_size = ?", fileHash, fileSize).
+		Where("status IN ?", []string{"processed", "archived", "deleted", "archived_and_deleted"})
+	if jobID != 0 {
+		query = query.Where("job_id = ?", jobID)
+	}
+	if configIDs != nil {
+		query = query.Where("config_id IN ?", configIDs)
+	}
+
+	var metadata FileMetadata
+	if err := query.Order("id asc").First(&metadata).Error; err != nil {
+		return nil, err
+	}
+	return &metadata, nil
+}
+
 // DeleteFileMetadata deletes file metadata by ID
 func (db *DB) DeleteFileMetadata(id uint) error {
 	return db.Delete(&FileMetadata{}, id).Error
@@ -0,0 +1,106 @@
+package db
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/starfleetcptn/gomft/internal/auth"
+	"github.com/starfleetcptn/gomft/internal/ftps"
+)
+
+// ftpsCertificateOptions are the rclone options of the certificate files of an FTPS remote
+var ftpsCertificateOptions = []string{"ca_cert", "client_cert", "client_key"}
+
+// FTPSOptions returns the TLS options of the FTP source or destination of the config, with
+// the stored client certificate decrypted
+func (tc *TransferConfig) FTPSOptions(side string) (ftps.Options, error) {
+	var opts ftps.Options
+	var clientCert string
+	if side == "source" {
+		opts = ftps.Options{
+			Mode:         tc.SourceFTPSMode,
+			SkipVerify:   tc.GetSourceFTPSSkipVerify(),
+			SessionReuse: tc.GetSourceFTPSSessionReuse(),
+			CACert:       tc.SourceFTPSCACert,
+		}
+		clientCert = tc.SourceFTPSClientCert
+	} else {
+		opts = ftps.Options{
+			Mode:         tc.DestFTPSMode,
+			SkipVerify:   tc.GetDestFTPSSkipVerify(),
+			SessionReuse: tc.GetDestFTPSSessionReuse(),
+			CACert:       tc.DestFTPSCACert,
+		}
+		clientCert = tc.DestFTPSClientCert
+	}
+
+	if opts.Enabled() && clientCert != "" {
+		decrypted, err := auth.DecryptKeyMaterial(clientCert)
+		if err != nil {
+			return opts, fmt.Errorf("failed to decrypt client certificate: %v", err)
+		}
+		opts.ClientCert = decrypted
+	}
+	return opts, nil
+}
+
+// ftpConfigArgs returns the rclone config options of an FTP remote
+func ftpConfigArgs(host string, port int, user, pass string, opts ftps.Options) []string {
+	args := []string{"host", host, "user", user}
+	if port != 0 {
+		args = append(args, "port", fmt.Sprintf("%d", port))
+	}
+	if pass != "" {
+		args = append(args, "pass", pass) // rclone obscures this
+	}
+	return append(args, opts.RcloneArgs()...)
+}
+
+// ftpsCertificatePath returns the path of a certificate file of an FTPS remote. The files
+// are kept next to the rclone config, which holds the other credentials of the remote.
+func ftpsCertificatePath(configPath, remote, option string) string {
+	return fmt.Sprintf("%s_%s_%s.pem", strings.TrimSuffix(configPath, ".conf"), remote, option)
+}
+
+// writeFTPSCertificates writes the CA and client certificates of an FTPS remote and points
+// the remote at them. rclone only reads certificates through its global options, which
+// override.* options of the remote set for this remote alone. Files of certificates that
+// are no longer used are removed.
+func writeFTPSCertificates(configPath, remote string, opts ftps.Options) error {
+	files := make(map[string]string)
+	if opts.Enabled() && opts.CACert != "" {
+		files["ca_cert"] = opts.CACert
+	}
+	if opts.Enabled() && opts.ClientCert != "" {
+		certs, key, err := ftps.SplitClientCertificate(opts.ClientCert)
+		if err != nil {
+			return err
+		}
+		files["client_cert"] = certs
+		files["client_key"] = key
+	}
+
+	section := readConfigSection(configPath, remote)
+	if section == "" {
+		if len(files) == 0 {
+			return nil
+		}
+		return fmt.Errorf("remote %s not found in the rclone config", remote)
+	}
+	for _, option := range ftpsCertificateOptions {
+		path := ftpsCertificatePath(configPath, remote, option)
+		// WriteFile keeps the mode of existing files
+		os.Remove(path)
+		content, ok := files[option]
+		if !ok {
+			section = setSectionOption(section, "override."+option, nil)
+			continue
+		}
+		if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+			return fmt.Errorf("failed to write %s file: %v", option, err)
+		}
+		section = setSectionOption(section, "override."+option, []string{"override." + option, path})
+	}
+	return replaceConfigSection(configPath, remote, section)
+}
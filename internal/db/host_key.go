@@ -0,0 +1,120 @@
+package db
+
+import (
+	"time"
+
+	"github.com/starfleetcptn/gomft/internal/hostkeys"
+	"gorm.io/gorm"
+)
+
+// HostKey is the SSH host key pinned for a server of a transfer config. Once a key is
+// pinned, the SFTP remotes of the config connecting to the server only accept that key.
+type HostKey struct {
+	ID          uint           `gorm:"primarykey" json:"id"`
+	ConfigID    uint           `gorm:"not null;uniqueIndex:idx_host_keys_config_server" json:"config_id"`
+	Config      TransferConfig `gorm:"foreignkey:ConfigID" json:"-"`
+	Host        string         `gorm:"not null;uniqueIndex:idx_host_keys_config_server" json:"host"`
+	Port        int            `gorm:"not null;uniqueIndex:idx_host_keys_config_server" json:"port"`
+	KeyType     string         `json:"key_type"`
+	PublicKey   string         `gorm:"type:text" json:"public_key"` // Key in authorized_keys format
+	Fingerprint string         `json:"fingerprint"`                 // SHA256 fingerprint
+	PinnedBy    uint           `json:"pinned_by"`
+	CreatedAt   time.Time      `json:"created_at"`
+	UpdatedAt   time.Time      `json:"updated_at"`
+}
+
+// Address returns the host:port address of the server
+func (k *HostKey) Address() string {
+	return hostkeys.Address(k.Host, k.Port)
+}
+
+// AfterDelete removes the host keys pinned for a deleted config
+func (tc *TransferConfig) AfterDelete(tx *gorm.DB) error {
+	if tc.ID == 0 {
+		return nil
+	}
+	return tx.Where("config_id = ?", tc.ID).Delete(&HostKey{}).Error
+}
+
+// SFTPServer is a server an SFTP remote of a config connects to
+type SFTPServer struct {
+	Host string
+	Port int
+}
+
+// SFTPServers returns the servers the SFTP remotes of the config connect to: the source,
+// the destination and the additional destinations
+func (tc *TransferConfig) SFTPServers() []SFTPServer {
+	var servers []SFTPServer
+	add := func(providerType, host string, port int) {
+		if (providerType == "sftp" || providerType == "hetzner") && host != "" {
+			servers = append(servers, SFTPServer{Host: host, Port: normalizePort(port)})
+		}
+	}
+	add(tc.SourceType, tc.SourceHost, tc.SourcePort)
+	add(tc.DestinationType, tc.DestHost, tc.DestPort)
+	if destinations, err := tc.GetDestinations(); err == nil {
+		for _, destination := range destinations {
+			add(destination.Type, destination.Host, destination.Port)
+		}
+	}
+	return servers
+}
+
+// normalizePort returns the SSH port of a server, 22 when none is set
+func normalizePort(port int) int {
+	if port == 0 {
+		return 22
+	}
+	return port
+}
+
+// IsSFTPSide reports whether the source or destination of the config is an SFTP server
+func (tc *TransferConfig) IsSFTPSide(side string) bool {
+	return (tc.SideType(side) == "sftp" || tc.SideType(side) == "hetzner") && tc.sideHost(side) != ""
+}
+
+// GetHostKey returns the known_hosts line of the host key of a side
+func (tc *TransferConfig) GetHostKey(side string) string {
+	if side == "source" {
+		return tc.SourceHostKey
+	}
+	return tc.DestHostKey
+}
+
+// SetHostKey sets the known_hosts line of the host key of a side
+func (tc *TransferConfig) SetHostKey(side, line string) {
+	if side == "source" {
+		tc.SourceHostKey = line
+	} else {
+		tc.DestHostKey = line
+	}
+}
+
+// PinnedHostKey returns the host key set for the SFTP server of a side, or nil when none is
+// set or the key was set for another server
+func (tc *TransferConfig) PinnedHostKey(side string) (*hostkeys.HostKey, error) {
+	if !tc.IsSFTPSide(side) {
+		return nil, nil
+	}
+	return hostkeys.ParsePin(tc.GetHostKey(side), tc.sideHost(side), tc.sidePort(side))
+}
+
+// SideServer returns the server of the SFTP source or destination of the config
+func (tc *TransferConfig) SideServer(side string) SFTPServer {
+	return SFTPServer{Host: tc.sideHost(side), Port: tc.sidePort(side)}
+}
+
+func (tc *TransferConfig) sideHost(side string) string {
+	if side == "source" {
+		return tc.SourceHost
+	}
+	return tc.DestHost
+}
+
+func (tc *TransferConfig) sidePort(side string) int {
+	if side == "source" {
+		return normalizePort(tc.SourcePort)
+	}
+	return normalizePort(tc.DestPort)
+}
@@ -0,0 +1,309 @@
+package db
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/starfleetcptn/gomft/internal/hostkeys"
+	"gorm.io/gorm/clause"
+)
+
+// --- Host Key Store Methods ---
+
+// GetHostKeys returns all pinned host keys with their configs, ordered by config and server
+func (db *DB) GetHostKeys() ([]HostKey, error) {
+	var keys []HostKey
+	err := db.Preload("Config").Order("config_id asc, host asc, port asc").Find(&keys).Error
+	return keys, err
+}
+
+// GetHostKey returns a specific pinned host key by ID
+func (db *DB) GetHostKey(id uint) (*HostKey, error) {
+	var key HostKey
+	if err := db.Preload("Config").First(&key, id).Error; err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+// GetConfigHostKeys returns the host keys pinned for a config
+func (db *DB) GetConfigHostKeys(configID uint) ([]HostKey, error) {
+	var keys []HostKey
+	err := db.Where("config_id = ?", configID).Order("host asc, port asc").Find(&keys).Error
+	return keys, err
+}
+
+// GetConfigHostKey returns the host key pinned for a server of a config, or nil if none is
+func (db *DB) GetConfigHostKey(configID uint, host string, port int) (*HostKey, error) {
+	var keys []HostKey
+	if err := db.Where("config_id = ? AND host = ? AND port = ?", configID, host, normalizePort(port)).Limit(1).Find(&keys).Error; err != nil {
+		return nil, err
+	}
+	if len(keys) == 0 {
+		return nil, nil
+	}
+	return &keys[0], nil
+}
+
+// PinHostKey pins a host key in authorized_keys format for a server of a config, replacing
+// the key pinned before
+func (db *DB) PinHostKey(configID uint, host string, port int, publicKey string, userID uint) (*HostKey, error) {
+	parsed, err := hostkeys.Parse(publicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	key := &HostKey{
+		ConfigID:    configID,
+		Host:        host,
+		Port:        normalizePort(port),
+		KeyType:     parsed.Type,
+		PublicKey:   parsed.PublicKey,
+		Fingerprint: parsed.Fingerprint,
+		PinnedBy:    userID,
+	}
+	err = db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "config_id"}, {Name: "host"}, {Name: "port"}},
+		DoUpdates: clause.AssignmentColumns([]string{"key_type", "public_key", "fingerprint", "pinned_by", "updated_at"}),
+	}).Create(key).Error
+	if err != nil {
+		return nil, err
+	}
+	// The ID of a replaced key is not set by the upsert
+	return db.GetConfigHostKey(configID, host, port)
+}
+
+// DeleteHostKey removes a pinned host key
+func (db *DB) DeleteHostKey(id uint) error {
+	result := db.Delete(&HostKey{}, id)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("host key not found: %d", id)
+	}
+	return nil
+}
+
+// CopyHostKeys pins the host keys pinned for a config for another config
+func (db *DB) CopyHostKeys(fromConfigID, toConfigID uint) error {
+	keys, err := db.GetConfigHostKeys(fromConfigID)
+	if err != nil {
+		return err
+	}
+	for _, key := range keys {
+		if _, err := db.PinHostKey(toConfigID, key.Host, key.Port, key.PublicKey, key.PinnedBy); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// PruneHostKeys removes the host keys pinned for servers the config no longer connects to
+func (db *DB) PruneHostKeys(config *TransferConfig) error {
+	keys, err := db.GetConfigHostKeys(config.ID)
+	if err != nil {
+		return err
+	}
+	for _, key := range keys {
+		if !usesServer(config, key.Host, key.Port) {
+			if err := db.Delete(&HostKey{}, key.ID).Error; err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// GetConfigKnownHostsPath returns the path to the known_hosts file of a transfer config
+func (db *DB) GetConfigKnownHostsPath(config *TransferConfig) string {
+	return strings.TrimSuffix(db.GetConfigRclonePath(config), ".conf") + ".known_hosts"
+}
+
+// knownHosts is the known_hosts file of a config and the servers pinned in it
+type knownHosts struct {
+	path   string
+	pinned map[string]bool
+}
+
+// option returns the rclone option checking the host key of a server against the
+// known_hosts file. Servers without a pinned key are not checked, runs pin the key they
+// present first.
+func (k *knownHosts) option(host string, port int) []string {
+	if k == nil || !k.pinned[hostkeys.Address(host, normalizePort(port))] {
+		return nil
+	}
+	return []string{"known_hosts_file", k.path}
+}
+
+// writeKnownHosts writes the known_hosts file of the host keys pinned for a config
+func (db *DB) writeKnownHosts(config *TransferConfig) (*knownHosts, error) {
+	keys, err := db.GetConfigHostKeys(config.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load pinned host keys: %v", err)
+	}
+
+	known := &knownHosts{path: db.GetConfigKnownHostsPath(config), pinned: make(map[string]bool)}
+	if len(keys) == 0 {
+		if err := os.Remove(known.path); err != nil && !os.IsNotExist(err) {
+			return nil, fmt.Errorf("failed to remove known_hosts file: %v", err)
+		}
+		return known, nil
+	}
+
+	var content strings.Builder
+	for _, key := range keys {
+		line, err := hostkeys.KnownHostsLine(key.Host, key.Port, key.PublicKey)
+		if err != nil {
+			return nil, fmt.Errorf("host key of %s: %v", key.Address(), err)
+		}
+		content.WriteString(line + "\n")
+		known.pinned[key.Address()] = true
+	}
+	if err := os.MkdirAll(filepath.Dir(known.path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create configs directory: %v", err)
+	}
+	if err := os.WriteFile(known.path, []byte(content.String()), 0600); err != nil {
+		return nil, fmt.Errorf("failed to write known_hosts file: %v", err)
+	}
+	return known, nil
+}
+
+// usesServer reports whether an SFTP remote of the config connects to the server
+func usesServer(config *TransferConfig, host string, port int) bool {
+	for _, server := range config.SFTPServers() {
+		if server.Host == host && server.Port == normalizePort(port) {
+			return true
+		}
+	}
+	return false
+}
+
+// hostKeyScanTimeout bounds fetching the host key of a server pinned on first use
+const hostKeyScanTimeout = 10 * time.Second
+
+// PinHostKeysOnFirstUse pins the key presented by each SFTP server of the config that has
+// no pinned key yet (trust on first use), and points the SFTP remotes of the config at the
+// updated known_hosts file. It returns the keys it pinned, which later runs enforce.
+func (db *DB) PinHostKeysOnFirstUse(config *TransferConfig) ([]*HostKey, error) {
+	var pinned []*HostKey
+	for _, server := range config.SFTPServers() {
+		key, err := db.GetConfigHostKey(config.ID, server.Host, server.Port)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load pinned host keys: %v", err)
+		}
+		if key != nil {
+			continue
+		}
+		scanned, err := hostkeys.Scan(server.Host, server.Port, hostKeyScanTimeout)
+		if err != nil {
+			return nil, err
+		}
+		if key, err = db.PinHostKey(config.ID, server.Host, server.Port, scanned.PublicKey, 0); err != nil {
+			return nil, fmt.Errorf("failed to pin the host key of %s: %v", hostkeys.Address(server.Host, server.Port), err)
+		}
+		pinned = append(pinned, key)
+	}
+	if len(pinned) > 0 {
+		if err := db.SyncKnownHosts(config); err != nil {
+			return pinned, err
+		}
+	}
+	return pinned, nil
+}
+
+// LoadHostKeys sets the host keys of the source and destination of a config to the keys
+// pinned for their SFTP servers
+func (db *DB) LoadHostKeys(config *TransferConfig) error {
+	for _, side := range []string{"source", "dest"} {
+		config.SetHostKey(side, "")
+		if !config.IsSFTPSide(side) {
+			continue
+		}
+		key, err := db.GetConfigHostKey(config.ID, config.sideHost(side), config.sidePort(side))
+		if err != nil {
+			return err
+		}
+		if key == nil {
+			continue
+		}
+		line, err := hostkeys.KnownHostsLine(key.Host, key.Port, key.PublicKey)
+		if err != nil {
+			return err
+		}
+		config.SetHostKey(side, line)
+	}
+	return nil
+}
+
+// SaveHostKey pins the host key set for the SFTP server of a side of a config, or removes
+// the pinned key when none is set. It reports whether the pin changed, and returns the key
+// pinned for the server, or the removed key.
+func (db *DB) SaveHostKey(config *TransferConfig, side string, userID uint) (changed bool, key *HostKey, err error) {
+	if !config.IsSFTPSide(side) {
+		return false, nil, nil
+	}
+	submitted, err := config.PinnedHostKey(side)
+	if err != nil {
+		return false, nil, err
+	}
+	existing, err := db.GetConfigHostKey(config.ID, config.sideHost(side), config.sidePort(side))
+	if err != nil {
+		return false, nil, err
+	}
+
+	switch {
+	case submitted == nil && existing == nil:
+		return false, nil, nil
+	case submitted == nil:
+		return true, existing, db.DeleteHostKey(existing.ID)
+	case existing != nil && existing.Fingerprint == submitted.Fingerprint:
+		return false, existing, nil
+	}
+	key, err = db.PinHostKey(config.ID, config.sideHost(side), config.sidePort(side), submitted.PublicKey, userID)
+	return err == nil, key, err
+}
+
+// SyncKnownHosts writes the known_hosts file of a config after its pinned host keys changed,
+// and points the existing SFTP remotes of the config at it, or no longer when the key of
+// their server is unpinned. The remotes are kept as their passwords are not stored.
+func (db *DB) SyncKnownHosts(config *TransferConfig) error {
+	known, err := db.writeKnownHosts(config)
+	if err != nil {
+		return err
+	}
+
+	remotes := make(map[string]SFTPServer)
+	if config.IsSFTPSide("source") {
+		remotes[fmt.Sprintf("source_%d", config.ID)] = config.SideServer("source")
+	}
+	if config.IsSFTPSide("dest") {
+		remotes[fmt.Sprintf("dest_%d", config.ID)] = config.SideServer("dest")
+	}
+	destinations, err := config.GetDestinations()
+	if err != nil {
+		return err
+	}
+	for _, destination := range destinations {
+		if destination.Type == "sftp" || destination.Type == "hetzner" {
+			destinationConfig := config.ForDestination(destination)
+			remotes[destinationConfig.GetDestRemote()] = SFTPServer{Host: destination.Host, Port: normalizePort(destination.Port)}
+		}
+	}
+
+	configPath := db.GetConfigRclonePath(config)
+	for remote, server := range remotes {
+		section := readConfigSection(configPath, remote)
+		if section == "" {
+			continue
+		}
+		section = setSectionOption(section, "known_hosts_file", known.option(server.Host, server.Port))
+		if err := replaceConfigSection(configPath, remote, section); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,39 @@
+package db
+
+import (
+	"net"
+	"strings"
+	"testing"
+)
+
+const testHostKey = "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIOMqqnkVzrm0SdG6UOoqKLsabgH5C9okWi0dh2l9GKJl"
+
+func TestPinHostKeysOnFirstUse(t *testing.T) {
+	database := newTestDB(t)
+
+	// Nothing listens on the port of a closed listener
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := listener.Addr().(*net.TCPAddr).Port
+	listener.Close()
+
+	config := &TransferConfig{Name: "SFTP partner", SourceType: "sftp", SourceHost: "127.0.0.1", SourcePort: port, SourcePath: "/out", DestinationType: "local", DestinationPath: "/in"}
+	if err := database.CreateTransferConfig(config); err != nil {
+		t.Fatalf("CreateTransferConfig() error = %v", err)
+	}
+
+	if _, err := database.PinHostKeysOnFirstUse(config); err == nil || !strings.Contains(err.Error(), "failed to fetch the host key") {
+		t.Errorf("PinHostKeysOnFirstUse() of an unreachable server error = %v", err)
+	}
+
+	// A pinned server is not contacted again
+	if _, err := database.PinHostKey(config.ID, "127.0.0.1", port, testHostKey, 1); err != nil {
+		t.Fatalf("PinHostKey() error = %v", err)
+	}
+	pinned, err := database.PinHostKeysOnFirstUse(config)
+	if err != nil || len(pinned) != 0 {
+		t.Errorf("PinHostKeysOnFirstUse() of a pinned server = %v, %v", pinned, err)
+	}
+}
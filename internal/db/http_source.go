@@ -0,0 +1,59 @@
+package db
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/starfleetcptn/gomft/internal/auth"
+	"github.com/starfleetcptn/gomft/internal/httpsource"
+)
+
+// HTTPSource returns the HTTP source of the config, with the stored secret decrypted
+func (tc *TransferConfig) HTTPSource() (*httpsource.Source, error) {
+	headers, err := httpsource.ParseHeaders(tc.SourceHTTPHeaders)
+	if err != nil {
+		return nil, fmt.Errorf("invalid headers: %v", err)
+	}
+	source := &httpsource.Source{
+		BaseURL: tc.SourceURL,
+		URLs:    httpsource.ParseURLs(tc.SourceURLs),
+		Headers: headers,
+		Auth:    tc.SourceHTTPAuth,
+		User:    tc.SourceUser,
+	}
+	if tc.SourceHTTPAuth != "" && tc.SourceHTTPSecret != "" {
+		secret, err := auth.DecryptKeyMaterial(tc.SourceHTTPSecret)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt credentials: %v", err)
+		}
+		source.Secret = secret
+	}
+	return source, nil
+}
+
+// GetHTTPValidators returns the validators of the delivered version of each URL of the
+// HTTP source, by URL
+func (tc *TransferConfig) GetHTTPValidators() (map[string]httpsource.Validators, error) {
+	validators := make(map[string]httpsource.Validators)
+	if tc.SourceHTTPValidators == "" {
+		return validators, nil
+	}
+	if err := json.Unmarshal([]byte(tc.SourceHTTPValidators), &validators); err != nil {
+		return nil, fmt.Errorf("invalid HTTP validators: %v", err)
+	}
+	return validators, nil
+}
+
+// SetHTTPValidators sets the validators of the delivered version of each URL of the HTTP source
+func (tc *TransferConfig) SetHTTPValidators(validators map[string]httpsource.Validators) error {
+	if len(validators) == 0 {
+		tc.SourceHTTPValidators = ""
+		return nil
+	}
+	data, err := json.Marshal(validators)
+	if err != nil {
+		return err
+	}
+	tc.SourceHTTPValidators = string(data)
+	return nil
+}
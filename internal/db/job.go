@@ -44,6 +44,10 @@ type JobHistory struct {
 	BytesTransferred int64
 	FilesTransferred int
 	ErrorMessage     string
+	ResumedFromID    uint   `gorm:"default:0"` // The run this run resumed from, 0 for new runs
+	Conflicts        string `gorm:"type:text"` // Files changed on both sides of a bisync run, one per line
+	Manifest         string // Name of the manifest delivered by the run, empty when none was created
+	DuplicateFiles   int    // Files whose content had already been delivered within the dedupe scope of the config
 	CreatedAt        time.Time
 	UpdatedAt        time.Time
 }
@@ -3,6 +3,8 @@ package db
 import (
 	"fmt"
 	"log"
+	"os"
+	"path/filepath"
 )
 
 // --- Job Store Methods ---
@@ -64,6 +66,19 @@ func (db *DB) DeleteJob(id uint) error {
 		return tx.Error
 	}
 
+	// Delete the checkpoints of the job's runs
+	if err := tx.Where("job_history_id IN (?)", tx.Model(&JobHistory{}).Select("id").Where("job_id = ?", id)).Delete(&RunFile{}).Error; err != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to delete run checkpoints: %v", err)
+	}
+
+	// Remember the runs that kept a manifest so it can be removed with them
+	var manifestRuns []JobHistory
+	if err := tx.Where("job_id = ? AND manifest <> ''", id).Find(&manifestRuns).Error; err != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to load job history: %v", err)
+	}
+
 	// Delete associated job history records first
 	if err := tx.Where("job_id = ?", id).Delete(&JobHistory{}).Error; err != nil {
 		tx.Rollback()
@@ -76,7 +91,16 @@ func (db *DB) DeleteJob(id uint) error {
 		return fmt.Errorf("failed to delete job: %v", err)
 	}
 
-	return tx.Commit().Error
+	if err := tx.Commit().Error; err != nil {
+		return err
+	}
+
+	for i := range manifestRuns {
+		if err := os.RemoveAll(db.GetRunManifestDir(&manifestRuns[i])); err != nil {
+			log.Printf("Warning: failed to remove manifest of run %d: %v", manifestRuns[i].ID, err)
+		}
+	}
+	return nil
 }
 
 // UpdateJobStatus updates the LastRun and NextRun fields of a job
@@ -146,6 +170,31 @@ func (db *DB) UpdateJobHistory(history *JobHistory) error {
 	return db.Save(history).Error
 }
 
+// GetRunManifestDir returns the directory the manifest of a job run is kept in
+func (db *DB) GetRunManifestDir(history *JobHistory) string {
+	// Get data directory from environment or use default
+	dataDir := os.Getenv("DATA_DIR")
+	if dataDir == "" {
+		dataDir = "./data"
+	}
+
+	// Keep the manifest of each run so it can be downloaded from the run details
+	return filepath.Join(dataDir, "manifests", fmt.Sprintf("run_%d", history.ID))
+}
+
+// GetResumingRun retrieves the run that resumed a run from its checkpoint, or nil when the
+// run has not been resumed
+func (db *DB) GetResumingRun(historyID uint) (*JobHistory, error) {
+	var histories []JobHistory
+	if err := db.Where("resumed_from_id = ?", historyID).Order("id desc").Limit(1).Find(&histories).Error; err != nil {
+		return nil, err
+	}
+	if len(histories) == 0 {
+		return nil, nil
+	}
+	return &histories[0], nil
+}
+
 // GetJobHistory retrieves all history records for a specific job, ordered by start time descending
 func (db *DB) GetJobHistory(jobID uint) ([]JobHistory, error) {
 	var histories []JobHistory
@@ -0,0 +1,52 @@
+package migrations
+
+import (
+	"github.com/go-gormigrate/gormigrate/v2"
+	"gorm.io/gorm"
+)
+
+// AddCompressionOptions adds the compression processing fields to transfer_configs
+// and the size tracking fields to file_metadata
+func AddCompressionOptions() *gormigrate.Migration {
+	return &gormigrate.Migration{
+		ID: "014_add_compression_options",
+		Migrate: func(tx *gorm.DB) error {
+			// Add new columns one at a time for SQLite compatibility
+			if err := tx.Exec(`ALTER TABLE transfer_configs ADD COLUMN compression_type TEXT DEFAULT ''`).Error; err != nil {
+				return err
+			}
+			if err := tx.Exec(`ALTER TABLE transfer_configs ADD COLUMN bundle_name TEXT`).Error; err != nil {
+				return err
+			}
+			if err := tx.Exec(`ALTER TABLE transfer_configs ADD COLUMN decompress_on_receive INTEGER DEFAULT 0`).Error; err != nil {
+				return err
+			}
+			if err := tx.Exec(`ALTER TABLE file_metadata ADD COLUMN original_size INTEGER DEFAULT 0`).Error; err != nil {
+				return err
+			}
+			if err := tx.Exec(`ALTER TABLE file_metadata ADD COLUMN compressed_size INTEGER DEFAULT 0`).Error; err != nil {
+				return err
+			}
+			return nil
+		},
+		Rollback: func(tx *gorm.DB) error {
+			// Remove columns one at a time for SQLite compatibility
+			if err := tx.Exec(`ALTER TABLE file_metadata DROP COLUMN compressed_size`).Error; err != nil {
+				return err
+			}
+			if err := tx.Exec(`ALTER TABLE file_metadata DROP COLUMN original_size`).Error; err != nil {
+				return err
+			}
+			if err := tx.Exec(`ALTER TABLE transfer_configs DROP COLUMN decompress_on_receive`).Error; err != nil {
+				return err
+			}
+			if err := tx.Exec(`ALTER TABLE transfer_configs DROP COLUMN bundle_name`).Error; err != nil {
+				return err
+			}
+			if err := tx.Exec(`ALTER TABLE transfer_configs DROP COLUMN compression_type`).Error; err != nil {
+				return err
+			}
+			return nil
+		},
+	}
+}
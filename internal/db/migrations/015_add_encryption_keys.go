@@ -0,0 +1,78 @@
+package migrations
+
+import (
+	"github.com/go-gormigrate/gormigrate/v2"
+	"gorm.io/gorm"
+)
+
+// AddEncryptionKeys adds the key store table, the encryption options of
+// transfer_configs and the encryption results of file_metadata
+func AddEncryptionKeys() *gormigrate.Migration {
+	return &gormigrate.Migration{
+		ID: "015_add_encryption_keys",
+		Migrate: func(tx *gorm.DB) error {
+			// Create encryption_keys table
+			if err := tx.Exec(`CREATE TABLE IF NOT EXISTS encryption_keys (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				name VARCHAR(255) NOT NULL,
+				type VARCHAR(50) NOT NULL,
+				fingerprint VARCHAR(255),
+				public_key TEXT,
+				private_key TEXT,
+				passphrase TEXT,
+				created_by INTEGER,
+				created_at DATETIME,
+				updated_at DATETIME
+			)`).Error; err != nil {
+				return err
+			}
+			if err := tx.Exec(`CREATE INDEX IF NOT EXISTS idx_encryption_keys_fingerprint ON encryption_keys(fingerprint)`).Error; err != nil {
+				return err
+			}
+
+			// Add new columns one at a time for SQLite compatibility
+			if err := tx.Exec(`ALTER TABLE transfer_configs ADD COLUMN encryption_mode TEXT DEFAULT ''`).Error; err != nil {
+				return err
+			}
+			if err := tx.Exec(`ALTER TABLE transfer_configs ADD COLUMN encryption_key_ids TEXT`).Error; err != nil {
+				return err
+			}
+			if err := tx.Exec(`ALTER TABLE transfer_configs ADD COLUMN signing_key_id INTEGER DEFAULT 0`).Error; err != nil {
+				return err
+			}
+			if err := tx.Exec(`ALTER TABLE transfer_configs ADD COLUMN verify_key_ids TEXT`).Error; err != nil {
+				return err
+			}
+			if err := tx.Exec(`ALTER TABLE transfer_configs ADD COLUMN require_signature INTEGER DEFAULT 0`).Error; err != nil {
+				return err
+			}
+			if err := tx.Exec(`ALTER TABLE file_metadata ADD COLUMN encryption_operation TEXT`).Error; err != nil {
+				return err
+			}
+			if err := tx.Exec(`ALTER TABLE file_metadata ADD COLUMN encryption_fingerprint TEXT`).Error; err != nil {
+				return err
+			}
+			if err := tx.Exec(`ALTER TABLE file_metadata ADD COLUMN signature_status TEXT`).Error; err != nil {
+				return err
+			}
+			return nil
+		},
+		Rollback: func(tx *gorm.DB) error {
+			// Remove columns one at a time for SQLite compatibility
+			for _, column := range []string{"signature_status", "encryption_fingerprint", "encryption_operation"} {
+				if err := tx.Exec(`ALTER TABLE file_metadata DROP COLUMN ` + column).Error; err != nil {
+					return err
+				}
+			}
+			for _, column := range []string{"require_signature", "verify_key_ids", "signing_key_id", "encryption_key_ids", "encryption_mode"} {
+				if err := tx.Exec(`ALTER TABLE transfer_configs DROP COLUMN ` + column).Error; err != nil {
+					return err
+				}
+			}
+			if err := tx.Exec("DROP TABLE IF EXISTS encryption_keys").Error; err != nil {
+				return err
+			}
+			return nil
+		},
+	}
+}
@@ -0,0 +1,45 @@
+package migrations
+
+import (
+	"github.com/go-gormigrate/gormigrate/v2"
+	"gorm.io/gorm"
+)
+
+// AddCryptRemotes adds the rclone crypt wrapper options to transfer_configs
+func AddCryptRemotes() *gormigrate.Migration {
+	return &gormigrate.Migration{
+		ID: "016_add_crypt_remotes",
+		Migrate: func(tx *gorm.DB) error {
+			// Add new columns one at a time for SQLite compatibility
+			if err := tx.Exec(`ALTER TABLE transfer_configs ADD COLUMN source_crypt INTEGER DEFAULT 0`).Error; err != nil {
+				return err
+			}
+			if err := tx.Exec(`ALTER TABLE transfer_configs ADD COLUMN source_crypt_filename_encryption TEXT`).Error; err != nil {
+				return err
+			}
+			if err := tx.Exec(`ALTER TABLE transfer_configs ADD COLUMN dest_crypt INTEGER DEFAULT 0`).Error; err != nil {
+				return err
+			}
+			if err := tx.Exec(`ALTER TABLE transfer_configs ADD COLUMN dest_crypt_filename_encryption TEXT`).Error; err != nil {
+				return err
+			}
+			return nil
+		},
+		Rollback: func(tx *gorm.DB) error {
+			// Remove columns one at a time for SQLite compatibility
+			if err := tx.Exec(`ALTER TABLE transfer_configs DROP COLUMN dest_crypt_filename_encryption`).Error; err != nil {
+				return err
+			}
+			if err := tx.Exec(`ALTER TABLE transfer_configs DROP COLUMN dest_crypt`).Error; err != nil {
+				return err
+			}
+			if err := tx.Exec(`ALTER TABLE transfer_configs DROP COLUMN source_crypt_filename_encryption`).Error; err != nil {
+				return err
+			}
+			if err := tx.Exec(`ALTER TABLE transfer_configs DROP COLUMN source_crypt`).Error; err != nil {
+				return err
+			}
+			return nil
+		},
+	}
+}
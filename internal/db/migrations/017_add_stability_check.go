@@ -0,0 +1,39 @@
+package migrations
+
+import (
+	"github.com/go-gormigrate/gormigrate/v2"
+	"gorm.io/gorm"
+)
+
+// AddStabilityCheck adds the stable file detection options to transfer_configs
+func AddStabilityCheck() *gormigrate.Migration {
+	return &gormigrate.Migration{
+		ID: "017_add_stability_check",
+		Migrate: func(tx *gorm.DB) error {
+			// Add new columns one at a time for SQLite compatibility
+			if err := tx.Exec(`ALTER TABLE transfer_configs ADD COLUMN min_file_age INTEGER DEFAULT 0`).Error; err != nil {
+				return err
+			}
+			if err := tx.Exec(`ALTER TABLE transfer_configs ADD COLUMN stability_check INTEGER DEFAULT 0`).Error; err != nil {
+				return err
+			}
+			if err := tx.Exec(`ALTER TABLE transfer_configs ADD COLUMN stability_delay INTEGER DEFAULT 30`).Error; err != nil {
+				return err
+			}
+			return nil
+		},
+		Rollback: func(tx *gorm.DB) error {
+			// Remove columns one at a time for SQLite compatibility
+			if err := tx.Exec(`ALTER TABLE transfer_configs DROP COLUMN stability_delay`).Error; err != nil {
+				return err
+			}
+			if err := tx.Exec(`ALTER TABLE transfer_configs DROP COLUMN stability_check`).Error; err != nil {
+				return err
+			}
+			if err := tx.Exec(`ALTER TABLE transfer_configs DROP COLUMN min_file_age`).Error; err != nil {
+				return err
+			}
+			return nil
+		},
+	}
+}
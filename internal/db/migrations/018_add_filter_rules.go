@@ -0,0 +1,41 @@
+package migrations
+
+import (
+	"github.com/go-gormigrate/gormigrate/v2"
+	"gorm.io/gorm"
+)
+
+// AddFilterRules adds the structured include/exclude, size and age filters to transfer_configs
+func AddFilterRules() *gormigrate.Migration {
+	return &gormigrate.Migration{
+		ID: "018_add_filter_rules",
+		Migrate: func(tx *gorm.DB) error {
+			// Add new columns one at a time for SQLite compatibility
+			if err := tx.Exec(`ALTER TABLE transfer_configs ADD COLUMN filter_rules TEXT`).Error; err != nil {
+				return err
+			}
+			if err := tx.Exec(`ALTER TABLE transfer_configs ADD COLUMN filter_min_size TEXT`).Error; err != nil {
+				return err
+			}
+			if err := tx.Exec(`ALTER TABLE transfer_configs ADD COLUMN filter_max_size TEXT`).Error; err != nil {
+				return err
+			}
+			if err := tx.Exec(`ALTER TABLE transfer_configs ADD COLUMN filter_min_age TEXT`).Error; err != nil {
+				return err
+			}
+			if err := tx.Exec(`ALTER TABLE transfer_configs ADD COLUMN filter_max_age TEXT`).Error; err != nil {
+				return err
+			}
+			return nil
+		},
+		Rollback: func(tx *gorm.DB) error {
+			// Remove columns one at a time for SQLite compatibility
+			for _, column := range []string{"filter_max_age", "filter_min_age", "filter_max_size", "filter_min_size", "filter_rules"} {
+				if err := tx.Exec(`ALTER TABLE transfer_configs DROP COLUMN ` + column).Error; err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	}
+}
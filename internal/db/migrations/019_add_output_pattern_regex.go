@@ -0,0 +1,19 @@
+package migrations
+
+import (
+	"github.com/go-gormigrate/gormigrate/v2"
+	"gorm.io/gorm"
+)
+
+// AddOutputPatternRegex adds the capture pattern used by output pattern variables to transfer_configs
+func AddOutputPatternRegex() *gormigrate.Migration {
+	return &gormigrate.Migration{
+		ID: "019_add_output_pattern_regex",
+		Migrate: func(tx *gorm.DB) error {
+			return tx.Exec(`ALTER TABLE transfer_configs ADD COLUMN output_pattern_regex TEXT`).Error
+		},
+		Rollback: func(tx *gorm.DB) error {
+			return tx.Exec(`ALTER TABLE transfer_configs DROP COLUMN output_pattern_regex`).Error
+		},
+	}
+}
@@ -0,0 +1,31 @@
+package migrations
+
+import (
+	"github.com/go-gormigrate/gormigrate/v2"
+	"gorm.io/gorm"
+)
+
+// AddConflictPolicy adds the destination conflict policy to transfer_configs and
+// the action taken for a conflict to file_metadata
+func AddConflictPolicy() *gormigrate.Migration {
+	return &gormigrate.Migration{
+		ID: "020_add_conflict_policy",
+		Migrate: func(tx *gorm.DB) error {
+			// Add new columns one at a time for SQLite compatibility
+			if err := tx.Exec(`ALTER TABLE transfer_configs ADD COLUMN conflict_policy TEXT`).Error; err != nil {
+				return err
+			}
+			if err := tx.Exec(`ALTER TABLE file_metadata ADD COLUMN conflict_action TEXT`).Error; err != nil {
+				return err
+			}
+			return nil
+		},
+		Rollback: func(tx *gorm.DB) error {
+			// Remove columns one at a time for SQLite compatibility
+			if err := tx.Exec(`ALTER TABLE file_metadata DROP COLUMN conflict_action`).Error; err != nil {
+				return err
+			}
+			return tx.Exec(`ALTER TABLE transfer_configs DROP COLUMN conflict_policy`).Error
+		},
+	}
+}
@@ -0,0 +1,38 @@
+package migrations
+
+import (
+	"github.com/go-gormigrate/gormigrate/v2"
+	"gorm.io/gorm"
+)
+
+// AddAtomicDelivery adds the atomic delivery options to transfer_configs
+func AddAtomicDelivery() *gormigrate.Migration {
+	return &gormigrate.Migration{
+		ID: "021_add_atomic_delivery",
+		Migrate: func(tx *gorm.DB) error {
+			// Add new columns one at a time for SQLite compatibility
+			if err := tx.Exec(`ALTER TABLE transfer_configs ADD COLUMN atomic_delivery INTEGER DEFAULT 0`).Error; err != nil {
+				return err
+			}
+			if err := tx.Exec(`ALTER TABLE transfer_configs ADD COLUMN atomic_temp_prefix TEXT`).Error; err != nil {
+				return err
+			}
+			if err := tx.Exec(`ALTER TABLE transfer_configs ADD COLUMN atomic_temp_suffix TEXT`).Error; err != nil {
+				return err
+			}
+			if err := tx.Exec(`ALTER TABLE transfer_configs ADD COLUMN atomic_verify INTEGER DEFAULT 0`).Error; err != nil {
+				return err
+			}
+			return nil
+		},
+		Rollback: func(tx *gorm.DB) error {
+			// Remove columns one at a time for SQLite compatibility
+			for _, column := range []string{"atomic_verify", "atomic_temp_suffix", "atomic_temp_prefix", "atomic_delivery"} {
+				if err := tx.Exec(`ALTER TABLE transfer_configs DROP COLUMN ` + column).Error; err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	}
+}
@@ -0,0 +1,51 @@
+package migrations
+
+import (
+	"github.com/go-gormigrate/gormigrate/v2"
+	"gorm.io/gorm"
+)
+
+// AddRetention adds the archive and destination retention rules to transfer_configs
+func AddRetention() *gormigrate.Migration {
+	return &gormigrate.Migration{
+		ID: "022_add_retention",
+		Migrate: func(tx *gorm.DB) error {
+			// Add new columns one at a time for SQLite compatibility
+			if err := tx.Exec(`ALTER TABLE transfer_configs ADD COLUMN archive_retention_keep_last INTEGER DEFAULT 0`).Error; err != nil {
+				return err
+			}
+			if err := tx.Exec(`ALTER TABLE transfer_configs ADD COLUMN archive_retention_max_age_days INTEGER DEFAULT 0`).Error; err != nil {
+				return err
+			}
+			if err := tx.Exec(`ALTER TABLE transfer_configs ADD COLUMN archive_retention_max_size TEXT`).Error; err != nil {
+				return err
+			}
+			if err := tx.Exec(`ALTER TABLE transfer_configs ADD COLUMN dest_retention_keep_last INTEGER DEFAULT 0`).Error; err != nil {
+				return err
+			}
+			if err := tx.Exec(`ALTER TABLE transfer_configs ADD COLUMN dest_retention_max_age_days INTEGER DEFAULT 0`).Error; err != nil {
+				return err
+			}
+			if err := tx.Exec(`ALTER TABLE transfer_configs ADD COLUMN dest_retention_max_size TEXT`).Error; err != nil {
+				return err
+			}
+			if err := tx.Exec(`ALTER TABLE transfer_configs ADD COLUMN retention_preview INTEGER DEFAULT 0`).Error; err != nil {
+				return err
+			}
+			return nil
+		},
+		Rollback: func(tx *gorm.DB) error {
+			// Remove columns one at a time for SQLite compatibility
+			for _, column := range []string{
+				"retention_preview",
+				"dest_retention_max_size", "dest_retention_max_age_days", "dest_retention_keep_last",
+				"archive_retention_max_size", "archive_retention_max_age_days", "archive_retention_keep_last",
+			} {
+				if err := tx.Exec(`ALTER TABLE transfer_configs DROP COLUMN ` + column).Error; err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	}
+}
@@ -0,0 +1,49 @@
+package migrations
+
+import (
+	"github.com/go-gormigrate/gormigrate/v2"
+	"gorm.io/gorm"
+)
+
+// AddRunCheckpoints adds the run_files table holding the planned files of each run
+// and the resumed run reference of job_histories
+func AddRunCheckpoints() *gormigrate.Migration {
+	return &gormigrate.Migration{
+		ID: "023_add_run_checkpoints",
+		Migrate: func(tx *gorm.DB) error {
+			// Create run_files table
+			if err := tx.Exec(`CREATE TABLE IF NOT EXISTS run_files (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				job_history_id INTEGER NOT NULL,
+				path TEXT NOT NULL,
+				size INTEGER,
+				hash TEXT,
+				mod_time DATETIME,
+				status VARCHAR(20) NOT NULL DEFAULT 'pending',
+				error_message TEXT,
+				created_at DATETIME,
+				updated_at DATETIME,
+				FOREIGN KEY (job_history_id) REFERENCES job_histories(id) ON DELETE CASCADE
+			)`).Error; err != nil {
+				return err
+			}
+			if err := tx.Exec(`CREATE INDEX IF NOT EXISTS idx_run_files_job_history_id ON run_files(job_history_id)`).Error; err != nil {
+				return err
+			}
+
+			if err := tx.Exec(`ALTER TABLE job_histories ADD COLUMN resumed_from_id INTEGER DEFAULT 0`).Error; err != nil {
+				return err
+			}
+			return nil
+		},
+		Rollback: func(tx *gorm.DB) error {
+			if err := tx.Exec(`ALTER TABLE job_histories DROP COLUMN resumed_from_id`).Error; err != nil {
+				return err
+			}
+			if err := tx.Exec("DROP TABLE IF EXISTS run_files").Error; err != nil {
+				return err
+			}
+			return nil
+		},
+	}
+}
@@ -0,0 +1,38 @@
+package migrations
+
+import (
+	"github.com/go-gormigrate/gormigrate/v2"
+	"gorm.io/gorm"
+)
+
+// AddBisync adds the bisync options to transfer_configs and the conflicts of a run to job_histories
+func AddBisync() *gormigrate.Migration {
+	return &gormigrate.Migration{
+		ID: "024_add_bisync",
+		Migrate: func(tx *gorm.DB) error {
+			// Add new columns one at a time for SQLite compatibility
+			if err := tx.Exec(`ALTER TABLE transfer_configs ADD COLUMN bisync_max_delete INTEGER DEFAULT 50`).Error; err != nil {
+				return err
+			}
+			if err := tx.Exec(`ALTER TABLE transfer_configs ADD COLUMN bisync_conflict_resolve TEXT`).Error; err != nil {
+				return err
+			}
+			if err := tx.Exec(`ALTER TABLE job_histories ADD COLUMN conflicts TEXT`).Error; err != nil {
+				return err
+			}
+			return nil
+		},
+		Rollback: func(tx *gorm.DB) error {
+			if err := tx.Exec(`ALTER TABLE job_histories DROP COLUMN conflicts`).Error; err != nil {
+				return err
+			}
+			// Remove columns one at a time for SQLite compatibility
+			for _, column := range []string{"bisync_conflict_resolve", "bisync_max_delete"} {
+				if err := tx.Exec(`ALTER TABLE transfer_configs DROP COLUMN ` + column).Error; err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	}
+}
@@ -0,0 +1,29 @@
+package migrations
+
+import (
+	"github.com/go-gormigrate/gormigrate/v2"
+	"gorm.io/gorm"
+)
+
+// AddDestinations adds the additional destinations of a config and the per-destination
+// outcome of a file
+func AddDestinations() *gormigrate.Migration {
+	return &gormigrate.Migration{
+		ID: "025_add_destinations",
+		Migrate: func(tx *gorm.DB) error {
+			if err := tx.Exec(`ALTER TABLE transfer_configs ADD COLUMN destinations TEXT`).Error; err != nil {
+				return err
+			}
+			if err := tx.Exec(`ALTER TABLE file_metadata ADD COLUMN destination_results TEXT`).Error; err != nil {
+				return err
+			}
+			return nil
+		},
+		Rollback: func(tx *gorm.DB) error {
+			if err := tx.Exec(`ALTER TABLE file_metadata DROP COLUMN destination_results`).Error; err != nil {
+				return err
+			}
+			return tx.Exec(`ALTER TABLE transfer_configs DROP COLUMN destinations`).Error
+		},
+	}
+}
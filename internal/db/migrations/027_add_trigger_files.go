@@ -0,0 +1,31 @@
+package migrations
+
+import (
+	"github.com/go-gormigrate/gormigrate/v2"
+	"gorm.io/gorm"
+)
+
+// AddTriggerFiles adds the trigger file and completion marker options to transfer_configs
+func AddTriggerFiles() *gormigrate.Migration {
+	return &gormigrate.Migration{
+		ID: "027_add_trigger_files",
+		Migrate: func(tx *gorm.DB) error {
+			// Add new columns one at a time for SQLite compatibility
+			for _, column := range []string{"trigger_mode", "trigger_name", "trigger_action", "marker_mode", "marker_name"} {
+				if err := tx.Exec(`ALTER TABLE transfer_configs ADD COLUMN ` + column + ` TEXT`).Error; err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+		Rollback: func(tx *gorm.DB) error {
+			// Remove columns one at a time for SQLite compatibility
+			for _, column := range []string{"marker_name", "marker_mode", "trigger_action", "trigger_name", "trigger_mode"} {
+				if err := tx.Exec(`ALTER TABLE transfer_configs DROP COLUMN ` + column).Error; err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	}
+}
@@ -0,0 +1,39 @@
+package migrations
+
+import (
+	"github.com/go-gormigrate/gormigrate/v2"
+	"gorm.io/gorm"
+)
+
+// AddQuarantine adds the quarantine options to transfer_configs and the consecutive
+// failure count of a file to file_metadata
+func AddQuarantine() *gormigrate.Migration {
+	return &gormigrate.Migration{
+		ID: "028_add_quarantine",
+		Migrate: func(tx *gorm.DB) error {
+			// Add new columns one at a time for SQLite compatibility
+			if err := tx.Exec(`ALTER TABLE transfer_configs ADD COLUMN quarantine_path TEXT`).Error; err != nil {
+				return err
+			}
+			if err := tx.Exec(`ALTER TABLE transfer_configs ADD COLUMN quarantine_after INTEGER DEFAULT 3`).Error; err != nil {
+				return err
+			}
+			if err := tx.Exec(`ALTER TABLE file_metadata ADD COLUMN failure_count INTEGER DEFAULT 0`).Error; err != nil {
+				return err
+			}
+			return nil
+		},
+		Rollback: func(tx *gorm.DB) error {
+			if err := tx.Exec(`ALTER TABLE file_metadata DROP COLUMN failure_count`).Error; err != nil {
+				return err
+			}
+			// Remove columns one at a time for SQLite compatibility
+			for _, column := range []string{"quarantine_after", "quarantine_path"} {
+				if err := tx.Exec(`ALTER TABLE transfer_configs DROP COLUMN ` + column).Error; err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	}
+}
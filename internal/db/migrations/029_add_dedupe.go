@@ -0,0 +1,39 @@
+package migrations
+
+import (
+	"github.com/go-gormigrate/gormigrate/v2"
+	"gorm.io/gorm"
+)
+
+// AddDedupe adds the deduplication options to transfer_configs and the duplicate count
+// of a run to job_histories
+func AddDedupe() *gormigrate.Migration {
+	return &gormigrate.Migration{
+		ID: "029_add_dedupe",
+		Migrate: func(tx *gorm.DB) error {
+			// Add new columns one at a time for SQLite compatibility
+			if err := tx.Exec(`ALTER TABLE transfer_configs ADD COLUMN dedupe_scope TEXT`).Error; err != nil {
+				return err
+			}
+			if err := tx.Exec(`ALTER TABLE transfer_configs ADD COLUMN dedupe_action TEXT`).Error; err != nil {
+				return err
+			}
+			if err := tx.Exec(`ALTER TABLE job_histories ADD COLUMN duplicate_files INTEGER DEFAULT 0`).Error; err != nil {
+				return err
+			}
+			return nil
+		},
+		Rollback: func(tx *gorm.DB) error {
+			if err := tx.Exec(`ALTER TABLE job_histories DROP COLUMN duplicate_files`).Error; err != nil {
+				return err
+			}
+			// Remove columns one at a time for SQLite compatibility
+			for _, column := range []string{"dedupe_action", "dedupe_scope"} {
+				if err := tx.Exec(`ALTER TABLE transfer_configs DROP COLUMN ` + column).Error; err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	}
+}
@@ -0,0 +1,40 @@
+package migrations
+
+import (
+	"github.com/go-gormigrate/gormigrate/v2"
+	"gorm.io/gorm"
+)
+
+// AddHostKeys adds the table of the SSH host keys pinned for the SFTP servers of transfer configs
+func AddHostKeys() *gormigrate.Migration {
+	return &gormigrate.Migration{
+		ID: "033_add_host_keys",
+		Migrate: func(tx *gorm.DB) error {
+			// Create host_keys table
+			if err := tx.Exec(`CREATE TABLE IF NOT EXISTS host_keys (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				config_id INTEGER NOT NULL,
+				host VARCHAR(255) NOT NULL,
+				port INTEGER NOT NULL,
+				key_type VARCHAR(100),
+				public_key TEXT,
+				fingerprint VARCHAR(255),
+				pinned_by INTEGER,
+				created_at DATETIME,
+				updated_at DATETIME
+			)`).Error; err != nil {
+				return err
+			}
+			if err := tx.Exec(`CREATE UNIQUE INDEX IF NOT EXISTS idx_host_keys_config_server ON host_keys(config_id, host, port)`).Error; err != nil {
+				return err
+			}
+			return nil
+		},
+		Rollback: func(tx *gorm.DB) error {
+			if err := tx.Exec("DROP TABLE IF EXISTS host_keys").Error; err != nil {
+				return err
+			}
+			return nil
+		},
+	}
+}
@@ -0,0 +1,54 @@
+package migrations
+
+import (
+	"github.com/go-gormigrate/gormigrate/v2"
+	"gorm.io/gorm"
+)
+
+// AddSSHKeys adds the SSH key store table and the managed SSH keys of transfer_configs
+func AddSSHKeys() *gormigrate.Migration {
+	return &gormigrate.Migration{
+		ID: "034_add_ssh_keys",
+		Migrate: func(tx *gorm.DB) error {
+			// Create ssh_keys table
+			if err := tx.Exec(`CREATE TABLE IF NOT EXISTS ssh_keys (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				name VARCHAR(255) NOT NULL,
+				type VARCHAR(100) NOT NULL,
+				fingerprint VARCHAR(255),
+				public_key TEXT,
+				private_key TEXT,
+				generated INTEGER DEFAULT 0,
+				created_by INTEGER,
+				created_at DATETIME,
+				updated_at DATETIME
+			)`).Error; err != nil {
+				return err
+			}
+			if err := tx.Exec(`CREATE UNIQUE INDEX IF NOT EXISTS idx_ssh_keys_name ON ssh_keys(name)`).Error; err != nil {
+				return err
+			}
+
+			// Add new columns one at a time for SQLite compatibility
+			if err := tx.Exec(`ALTER TABLE transfer_configs ADD COLUMN source_ssh_key TEXT DEFAULT ''`).Error; err != nil {
+				return err
+			}
+			if err := tx.Exec(`ALTER TABLE transfer_configs ADD COLUMN dest_ssh_key TEXT DEFAULT ''`).Error; err != nil {
+				return err
+			}
+			return nil
+		},
+		Rollback: func(tx *gorm.DB) error {
+			// Remove columns one at a time for SQLite compatibility
+			for _, column := range []string{"dest_ssh_key", "source_ssh_key"} {
+				if err := tx.Exec(`ALTER TABLE transfer_configs DROP COLUMN ` + column).Error; err != nil {
+					return err
+				}
+			}
+			if err := tx.Exec("DROP TABLE IF EXISTS ssh_keys").Error; err != nil {
+				return err
+			}
+			return nil
+		},
+	}
+}
@@ -0,0 +1,46 @@
+package migrations
+
+import (
+	"github.com/go-gormigrate/gormigrate/v2"
+	"gorm.io/gorm"
+)
+
+// AddFTPS adds the FTPS options of the FTP source and destination to transfer_configs
+func AddFTPS() *gormigrate.Migration {
+	return &gormigrate.Migration{
+		ID: "035_add_ftps",
+		Migrate: func(tx *gorm.DB) error {
+			// Add new columns one at a time for SQLite compatibility
+			for _, side := range []string{"source", "dest"} {
+				for _, column := range []string{
+					side + "_ftps_mode TEXT DEFAULT ''",
+					side + "_ftps_skip_verify INTEGER DEFAULT 0",
+					side + "_ftps_session_reuse INTEGER DEFAULT 1",
+					side + "_ftps_ca_cert TEXT",
+					side + "_ftps_client_cert TEXT",
+				} {
+					if err := tx.Exec(`ALTER TABLE transfer_configs ADD COLUMN ` + column).Error; err != nil {
+						return err
+					}
+				}
+			}
+
+			// Connection tests of FTP remotes always used explicit TLS, keep it for existing configs
+			if err := tx.Exec(`UPDATE transfer_configs SET source_ftps_mode = 'explicit' WHERE source_type = 'ftp'`).Error; err != nil {
+				return err
+			}
+			return tx.Exec(`UPDATE transfer_configs SET dest_ftps_mode = 'explicit' WHERE destination_type = 'ftp'`).Error
+		},
+		Rollback: func(tx *gorm.DB) error {
+			// Remove columns one at a time for SQLite compatibility
+			for _, side := range []string{"dest", "source"} {
+				for _, column := range []string{"ftps_client_cert", "ftps_ca_cert", "ftps_session_reuse", "ftps_skip_verify", "ftps_mode"} {
+					if err := tx.Exec(`ALTER TABLE transfer_configs DROP COLUMN ` + side + "_" + column).Error; err != nil {
+						return err
+					}
+				}
+			}
+			return nil
+		},
+	}
+}
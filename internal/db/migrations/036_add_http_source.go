@@ -0,0 +1,38 @@
+package migrations
+
+import (
+	"github.com/go-gormigrate/gormigrate/v2"
+	"gorm.io/gorm"
+)
+
+// AddHTTPSource adds the URLs, headers, credentials and validators of HTTP sources to transfer_configs
+func AddHTTPSource() *gormigrate.Migration {
+	return &gormigrate.Migration{
+		ID: "036_add_http_source",
+		Migrate: func(tx *gorm.DB) error {
+			// Add new columns one at a time for SQLite compatibility
+			for _, column := range []string{
+				"source_url TEXT",
+				"source_urls TEXT",
+				"source_http_headers TEXT",
+				"source_http_auth TEXT DEFAULT ''",
+				"source_http_secret TEXT",
+				"source_http_validators TEXT",
+			} {
+				if err := tx.Exec(`ALTER TABLE transfer_configs ADD COLUMN ` + column).Error; err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+		Rollback: func(tx *gorm.DB) error {
+			// Remove columns one at a time for SQLite compatibility
+			for _, column := range []string{"source_http_validators", "source_http_secret", "source_http_auth", "source_http_headers", "source_urls", "source_url"} {
+				if err := tx.Exec(`ALTER TABLE transfer_configs DROP COLUMN ` + column).Error; err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	}
+}
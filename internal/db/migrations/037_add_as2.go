@@ -0,0 +1,99 @@
+package migrations
+
+import (
+	"github.com/go-gormigrate/gormigrate/v2"
+	"gorm.io/gorm"
+)
+
+// AddAS2 adds the AS2 partner and message tables and the AS2 partner of transfer_configs
+func AddAS2() *gormigrate.Migration {
+	return &gormigrate.Migration{
+		ID: "037_add_as2",
+		Migrate: func(tx *gorm.DB) error {
+			// Create as2_partners table
+			if err := tx.Exec(`CREATE TABLE IF NOT EXISTS as2_partners (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				name VARCHAR(255) NOT NULL,
+				as2_id VARCHAR(128) NOT NULL,
+				local_as2_id VARCHAR(128) NOT NULL,
+				url TEXT,
+				certificate TEXT,
+				local_certificate TEXT,
+				local_private_key TEXT,
+				content_type VARCHAR(255),
+				sign INTEGER DEFAULT 0,
+				signing_algorithm VARCHAR(20),
+				encrypt INTEGER DEFAULT 0,
+				encryption_algorithm VARCHAR(20),
+				compress INTEGER DEFAULT 0,
+				mdn_mode VARCHAR(10) DEFAULT '',
+				signed_mdn INTEGER DEFAULT 0,
+				async_mdn_url TEXT,
+				require_signed INTEGER DEFAULT 1,
+				require_encrypted INTEGER DEFAULT 0,
+				inbound_config_id INTEGER DEFAULT 0,
+				created_by INTEGER,
+				created_at DATETIME,
+				updated_at DATETIME
+			)`).Error; err != nil {
+				return err
+			}
+			if err := tx.Exec(`CREATE UNIQUE INDEX IF NOT EXISTS idx_as2_partners_name ON as2_partners(name)`).Error; err != nil {
+				return err
+			}
+			if err := tx.Exec(`CREATE UNIQUE INDEX IF NOT EXISTS idx_as2_partners_ids ON as2_partners(as2_id, local_as2_id)`).Error; err != nil {
+				return err
+			}
+
+			// Create as2_messages table
+			if err := tx.Exec(`CREATE TABLE IF NOT EXISTS as2_messages (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				message_id VARCHAR(255) NOT NULL,
+				direction VARCHAR(10) NOT NULL,
+				partner_id INTEGER,
+				partner_name VARCHAR(255),
+				job_history_id INTEGER DEFAULT 0,
+				config_id INTEGER DEFAULT 0,
+				file_name TEXT,
+				file_size INTEGER DEFAULT 0,
+				destination_path TEXT,
+				mic VARCHAR(255),
+				mic_algorithm VARCHAR(20),
+				signed INTEGER DEFAULT 0,
+				encrypted INTEGER DEFAULT 0,
+				compressed INTEGER DEFAULT 0,
+				mdn_mode VARCHAR(10),
+				mdn_status VARCHAR(20),
+				mdn_disposition TEXT,
+				mdn_received_at DATETIME,
+				error_message TEXT,
+				created_at DATETIME,
+				updated_at DATETIME
+			)`).Error; err != nil {
+				return err
+			}
+			if err := tx.Exec(`CREATE INDEX IF NOT EXISTS idx_as2_messages_message_id ON as2_messages(message_id)`).Error; err != nil {
+				return err
+			}
+			if err := tx.Exec(`CREATE INDEX IF NOT EXISTS idx_as2_messages_job_history_id ON as2_messages(job_history_id)`).Error; err != nil {
+				return err
+			}
+
+			if err := tx.Exec(`ALTER TABLE transfer_configs ADD COLUMN dest_as2_partner TEXT DEFAULT ''`).Error; err != nil {
+				return err
+			}
+			return nil
+		},
+		Rollback: func(tx *gorm.DB) error {
+			if err := tx.Exec(`ALTER TABLE transfer_configs DROP COLUMN dest_as2_partner`).Error; err != nil {
+				return err
+			}
+			for _, table := range []string{"as2_messages", "as2_partners"} {
+				if err := tx.Exec("DROP TABLE IF EXISTS " + table).Error; err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	}
+}
@@ -5,12 +5,10 @@ import (
 	"gorm.io/gorm"
 )
 
-var migrations []*gormigrate.Migration
-
 // GetMigrations returns all migrations
 func GetMigrations(db *gorm.DB) *gormigrate.Gormigrate {
-	// Add all migrations in order
-	migrations = append(migrations,
+	// Add all migrations in order, a fresh list per call so a process can migrate several databases
+	migrations := []*gormigrate.Migration{
 		InitialSchema(),                     // 001
 		UpdateGDriveType(),                  // 002
 		Add2FA(),                            // 003
@@ -27,7 +25,31 @@ func GetMigrations(db *gorm.DB) *gormigrate.Gormigrate {
 		RecoverNotificationServicesRename(), // 012b
 		RecoverAuthProvidersRename(),        // 012c
 		CleanupInvalidBooleans(),            // 013
-	)
+		AddCompressionOptions(),             // 014
+		AddEncryptionKeys(),                 // 015
+		AddCryptRemotes(),                   // 016
+		AddStabilityCheck(),                 // 017
+		AddFilterRules(),                    // 018
+		AddOutputPatternRegex(),             // 019
+		AddConflictPolicy(),                 // 020
+		AddAtomicDelivery(),                 // 021
+		AddRetention(),                      // 022
+		AddRunCheckpoints(),                 // 023
+		AddBisync(),                         // 024
+		AddDestinations(),                   // 025
+		AddManifests(),                      // 026
+		AddTriggerFiles(),                   // 027
+		AddQuarantine(),                     // 028
+		AddDedupe(),                         // 029
+		AddChangeDetection(),                // 030
+		AddGCS(),                            // 031
+		AddOAuthProviders(),                 // 032
+		AddHostKeys(),                       // 033
+		AddSSHKeys(),                        // 034
+		AddFTPS(),                           // 035
+		AddHTTPSource(),                     // 036
+		AddAS2(),                            // 037
+	}
 
 	return gormigrate.New(db, gormigrate.DefaultOptions, migrations)
 }
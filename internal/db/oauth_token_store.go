@@ -0,0 +1,256 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/starfleetcptn/gomft/internal/auth"
+	"github.com/starfleetcptn/gomft/internal/oauth"
+)
+
+// oauthRemoteName returns the rclone remote of a side of a config
+func oauthRemoteName(config *TransferConfig, side string) string {
+	return fmt.Sprintf("%s_%d", side, config.ID)
+}
+
+// GetOAuthClient returns the OAuth client a side of a config is authorized with: the client
+// entered in the config, unless built-in authentication is used, or the default client of
+// the provider
+func (db *DB) GetOAuthClient(config *TransferConfig, side string) oauth.Client {
+	provider, ok := oauth.Get(config.SideType(side))
+	if !ok {
+		return oauth.Client{}
+	}
+
+	clientID, clientSecret, builtin := config.DestClientID, config.DestClientSecret, config.UseBuiltinAuthDest
+	if side == "source" {
+		clientID, clientSecret, builtin = config.SourceClientID, config.SourceClientSecret, config.UseBuiltinAuthSource
+	}
+	if builtin != nil && *builtin {
+		return provider.DefaultClient()
+	}
+	// Client secrets are not stored in the database, only in the rclone config
+	if clientSecret == "" {
+		clientSecret = configSectionValue(readConfigSection(db.GetConfigRclonePath(config), oauthRemoteName(config, side)), "client_secret")
+	}
+	if clientID != "" && clientSecret != "" {
+		return oauth.Client{ID: clientID, Secret: clientSecret}
+	}
+	return provider.DefaultClient()
+}
+
+// StoreOAuthToken stores the token of a side of a config, encrypted in the database and in
+// the remote of the rclone config
+func (db *DB) StoreOAuthToken(config *TransferConfig, side, token string) error {
+	encrypted, err := auth.EncryptKeyMaterial(token)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt token: %v", err)
+	}
+	config.SetOAuthToken(side, encrypted)
+
+	updates := map[string]interface{}{side + "_oauth_token": encrypted}
+	if config.SideType(side) == "gdrive" || config.SideType(side) == "gphotos" {
+		config.SetGoogleAuthenticated(true)
+		updates["google_drive_authenticated"] = true
+	}
+	if err := db.Model(&TransferConfig{}).Where("id = ?", config.ID).Updates(updates).Error; err != nil {
+		return fmt.Errorf("failed to store token: %v", err)
+	}
+
+	configPath := db.GetConfigRclonePath(config)
+	if err := os.MkdirAll(filepath.Dir(configPath), 0755); err != nil {
+		return fmt.Errorf("failed to create config directory: %v", err)
+	}
+	return writeOAuthRemote(configPath, side, config, token)
+}
+
+// RefreshOAuthTokens refreshes the expired tokens of the OAuth remotes of a config. rclone
+// refreshes tokens it uses as well and writes them to its config, so the stored tokens are
+// updated to the ones in the rclone config, as some providers rotate refresh tokens.
+func (db *DB) RefreshOAuthTokens(config *TransferConfig) error {
+	configPath := db.GetConfigRclonePath(config)
+	for _, side := range config.OAuthSides() {
+		provider, _ := oauth.Get(config.SideType(side))
+		current := configSectionValue(readConfigSection(configPath, oauthRemoteName(config, side)), "token")
+		if current == "" {
+			continue // Not authorized yet
+		}
+		token, err := oauth.ParseToken(current)
+		if err != nil {
+			return fmt.Errorf("%s token of the %s: %v", provider.Name(), side, err)
+		}
+
+		if token.Expired() {
+			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			refreshed, err := provider.Refresh(ctx, db.GetOAuthClient(config, side), token)
+			cancel()
+			if err != nil {
+				return err
+			}
+			current = refreshed.JSON()
+		}
+
+		stored, err := auth.DecryptKeyMaterial(config.GetOAuthToken(side))
+		if err == nil && stored == current {
+			continue
+		}
+		if err := db.StoreOAuthToken(config, side, current); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// keepOAuthTokens stores the tokens that are only in the rclone config, such as the tokens
+// of Google remotes authorized before tokens were stored in the database
+func (db *DB) keepOAuthTokens(config *TransferConfig) error {
+	configPath := db.GetConfigRclonePath(config)
+	for _, side := range config.OAuthSides() {
+		provider, _ := oauth.Get(config.SideType(side))
+		existing := readConfigSection(configPath, oauthRemoteName(config, side))
+		token := configSectionValue(existing, "token")
+		if config.GetOAuthToken(side) != "" || token == "" || configSectionValue(existing, "type") != provider.RcloneType() {
+			continue
+		}
+		if err := db.StoreOAuthToken(config, side, token); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeOAuthRemote writes the rclone remote of an OAuth side of a config. Without a token,
+// the token rclone last wrote to the remote is kept, or else the stored token is used.
+func writeOAuthRemote(configPath, side string, config *TransferConfig, token string) error {
+	provider, ok := oauth.Get(config.SideType(side))
+	if !ok {
+		return fmt.Errorf("%s is not an OAuth provider", config.SideType(side))
+	}
+	name := oauthRemoteName(config, side)
+
+	// Values of a remote of another provider type are not carried over
+	existing := readConfigSection(configPath, name)
+	if configSectionValue(existing, "type") != provider.RcloneType() {
+		existing = ""
+	}
+	if token == "" {
+		token = configSectionValue(existing, "token")
+	}
+	if token == "" && config.GetOAuthToken(side) != "" {
+		decrypted, err := auth.DecryptKeyMaterial(config.GetOAuthToken(side))
+		if err != nil {
+			return fmt.Errorf("failed to decrypt token: %v", err)
+		}
+		token = decrypted
+	}
+
+	options := []string{"type", provider.RcloneType()}
+	clientID, clientSecret, builtin := config.DestClientID, config.DestClientSecret, config.UseBuiltinAuthDest
+	if side == "source" {
+		clientID, clientSecret, builtin = config.SourceClientID, config.SourceClientSecret, config.UseBuiltinAuthSource
+	}
+	if builtin == nil || !*builtin {
+		if clientSecret == "" {
+			clientSecret = configSectionValue(existing, "client_secret")
+		}
+		if clientID != "" {
+			options = append(options, "client_id", clientID)
+		}
+		if clientSecret != "" {
+			options = append(options, "client_secret", clientSecret)
+		}
+	}
+	if token != "" {
+		options = append(options, "token", strings.TrimSpace(strings.NewReplacer("\n", "", "\r", "").Replace(token)))
+	}
+	options = append(options, oauthRemoteOptions(config, side)...)
+
+	section := fmt.Sprintf("[%s]\n", name)
+	for i := 0; i < len(options); i += 2 {
+		section += fmt.Sprintf("%s = %s\n", options[i], options[i+1])
+	}
+	return replaceConfigSection(configPath, name, section)
+}
+
+// oauthRemoteOptions returns the provider specific rclone options of an OAuth side of a config
+func oauthRemoteOptions(config *TransferConfig, side string) []string {
+	driveID, driveType, teamDrive := config.DestDriveID, config.DestDriveType, config.DestTeamDrive
+	readOnly, startYear, includeArchived := config.DestReadOnly, config.DestStartYear, config.DestIncludeArchived
+	if side == "source" {
+		driveID, driveType, teamDrive = config.SourceDriveID, config.SourceDriveType, config.SourceTeamDrive
+		readOnly, startYear, includeArchived = config.SourceReadOnly, config.SourceStartYear, config.SourceIncludeArchived
+	}
+
+	var options []string
+	switch config.SideType(side) {
+	case "gdrive":
+		if teamDrive != "" {
+			options = append(options, "team_drive", teamDrive)
+		}
+		if driveID != "" {
+			options = append(options, "root_folder_id", driveID)
+		}
+	case "gphotos":
+		if readOnly != nil && *readOnly {
+			options = append(options, "read_only", "true")
+		}
+		if startYear > 0 {
+			options = append(options, "start_year", fmt.Sprintf("%d", startYear))
+		}
+		if includeArchived != nil && *includeArchived {
+			options = append(options, "include_archived", "true")
+		}
+	case "onedrive":
+		// The drive is looked up when the config is authorized
+		if driveID != "" {
+			options = append(options, "drive_id", driveID)
+		}
+		if driveType != "" {
+			options = append(options, "drive_type", driveType)
+		}
+	}
+	return options
+}
+
+// configSectionValue returns the value of an option of a section of an rclone config
+func configSectionValue(section, key string) string {
+	for _, line := range strings.Split(section, "\n") {
+		parts := strings.SplitN(line, "=", 2)
+		if len(parts) == 2 && strings.TrimSpace(parts[0]) == key {
+			return strings.TrimSpace(parts[1])
+		}
+	}
+	return ""
+}
+
+// replaceConfigSection replaces a section of an rclone config file, or appends it if missing
+func replaceConfigSection(configPath, name, section string) error {
+	contentBytes, err := os.ReadFile(configPath)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to read config file: %v", err)
+	}
+	content := string(contentBytes)
+
+	sectionPattern := regexp.MustCompile(fmt.Sprintf(`(?m)^%s[^\[]*`, regexp.QuoteMeta(fmt.Sprintf("[%s]", name))))
+	if sectionPattern.MatchString(content) {
+		content = sectionPattern.ReplaceAllLiteralString(content, section+"\n")
+	} else {
+		if content != "" && !strings.HasSuffix(content, "\n\n") {
+			if !strings.HasSuffix(content, "\n") {
+				content += "\n"
+			}
+			content += "\n"
+		}
+		content += section
+	}
+
+	if err := os.WriteFile(configPath, []byte(content), 0600); err != nil {
+		return fmt.Errorf("failed to write config file: %v", err)
+	}
+	return nil
+}
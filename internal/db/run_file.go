@@ -0,0 +1,33 @@
+package db
+
+import (
+	"time"
+)
+
+// Run file states
+const (
+	RunFilePending   = "pending"
+	RunFileCompleted = "completed"
+	RunFileSkipped   = "skipped"
+	RunFileFailed    = "failed"
+)
+
+// RunFile is a file planned for transfer by a job run. The planned files of a run and
+// their state form the checkpoint from which an interrupted or failed run is resumed.
+type RunFile struct {
+	ID           uint   `gorm:"primarykey"`
+	JobHistoryID uint   `gorm:"not null;index"`
+	Path         string `gorm:"not null"`
+	Size         int64
+	Hash         string
+	ModTime      time.Time
+	Status       string `gorm:"not null;default:pending"` // pending, completed, skipped or failed
+	ErrorMessage string
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+}
+
+// IsDone reports whether the file needs no further work when the run is resumed
+func (f *RunFile) IsDone() bool {
+	return f.Status == RunFileCompleted || f.Status == RunFileSkipped
+}
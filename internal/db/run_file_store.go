@@ -0,0 +1,55 @@
+package db
+
+import (
+	"time"
+)
+
+// runFileBatchSize limits the number of rows inserted per statement
+const runFileBatchSize = 500
+
+// CreateRunFiles stores the planned files of a run
+func (db *DB) CreateRunFiles(files []RunFile) error {
+	if len(files) == 0 {
+		return nil
+	}
+	return db.CreateInBatches(files, runFileBatchSize).Error
+}
+
+// GetRunFiles retrieves the planned files of a run in planning order
+func (db *DB) GetRunFiles(jobHistoryID uint) ([]RunFile, error) {
+	var files []RunFile
+	err := db.Where("job_history_id = ?", jobHistoryID).Order("id").Find(&files).Error
+	return files, err
+}
+
+// UpdateRunFileStatus records the state of a planned file
+func (db *DB) UpdateRunFileStatus(id uint, status, errorMessage string) error {
+	return db.Model(&RunFile{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"status":        status,
+		"error_message": errorMessage,
+	}).Error
+}
+
+// CountRunFiles returns the number of planned files of a run and how many of them
+// still have to be transferred
+func (db *DB) CountRunFiles(jobHistoryID uint) (total int64, remaining int64, err error) {
+	if err = db.Model(&RunFile{}).Where("job_history_id = ?", jobHistoryID).Count(&total).Error; err != nil {
+		return 0, 0, err
+	}
+	err = db.Model(&RunFile{}).
+		Where("job_history_id = ? AND status NOT IN ?", jobHistoryID, []string{RunFileCompleted, RunFileSkipped}).
+		Count(&remaining).Error
+	return total, remaining, err
+}
+
+// MarkInterruptedJobHistories marks runs that were still running when the application
+// stopped as interrupted, so they can be resumed. It returns the number of runs marked.
+func (db *DB) MarkInterruptedJobHistories() (int64, error) {
+	now := time.Now()
+	result := db.Model(&JobHistory{}).Where("status = ?", "running").Updates(map[string]interface{}{
+		"status":        "interrupted",
+		"error_message": "The run was interrupted before it completed",
+		"end_time":      &now,
+	})
+	return result.RowsAffected, result.Error
+}
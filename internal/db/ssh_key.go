@@ -0,0 +1,54 @@
+package db
+
+import (
+	"fmt"
+	"time"
+)
+
+// SSHKey is an SSH key pair SFTP remotes authenticate with. Configs reference keys by name.
+type SSHKey struct {
+	ID          uint      `gorm:"primarykey" json:"id"`
+	Name        string    `gorm:"not null;uniqueIndex" json:"name"`
+	Type        string    `gorm:"not null" json:"type"`        // Key algorithm, such as ssh-ed25519
+	Fingerprint string    `json:"fingerprint"`                 // SHA256 fingerprint
+	PublicKey   string    `gorm:"type:text" json:"public_key"` // Key in authorized_keys format
+	PrivateKey  string    `gorm:"type:text" json:"-"`          // Encrypted at rest, without passphrase
+	Generated   bool      `json:"generated"`                   // Generated by GoMFT rather than imported
+	CreatedBy   uint      `json:"created_by"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// SSHKeyRemotes returns the managed SSH keys the SFTP remotes of the config authenticate
+// with, by remote name
+func (tc *TransferConfig) SSHKeyRemotes() map[string]string {
+	remotes := make(map[string]string)
+	if isSFTPType(tc.SourceType) && tc.SourceSSHKey != "" {
+		remotes[fmt.Sprintf("source_%d", tc.ID)] = tc.SourceSSHKey
+	}
+	if isSFTPType(tc.DestinationType) && tc.DestSSHKey != "" {
+		remotes[tc.GetDestRemote()] = tc.DestSSHKey
+	}
+	destinations, _ := tc.GetDestinations()
+	for _, destination := range destinations {
+		if isSFTPType(destination.Type) && destination.SSHKey != "" {
+			destinationConfig := tc.ForDestination(destination)
+			remotes[destinationConfig.GetDestRemote()] = destination.SSHKey
+		}
+	}
+	return remotes
+}
+
+// UsesSSHKey reports whether an SFTP remote of the config authenticates with a managed key
+func (tc *TransferConfig) UsesSSHKey(name string) bool {
+	for _, keyName := range tc.SSHKeyRemotes() {
+		if keyName == name {
+			return true
+		}
+	}
+	return false
+}
+
+func isSFTPType(storageType string) bool {
+	return storageType == "sftp" || storageType == "hetzner"
+}
@@ -0,0 +1,209 @@
+package db
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/starfleetcptn/gomft/internal/auth"
+)
+
+// --- SSH Key Store Methods ---
+
+// GetSSHKeys returns all managed SSH keys ordered by name
+func (db *DB) GetSSHKeys() ([]SSHKey, error) {
+	var keys []SSHKey
+	err := db.Order("name asc").Find(&keys).Error
+	return keys, err
+}
+
+// GetSSHKey returns a specific SSH key by ID
+func (db *DB) GetSSHKey(id uint) (*SSHKey, error) {
+	var key SSHKey
+	err := db.First(&key, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+// GetSSHKeyByName returns the SSH key with the given name
+func (db *DB) GetSSHKeyByName(name string) (*SSHKey, error) {
+	var key SSHKey
+	err := db.Where("name = ?", name).First(&key).Error
+	if err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+// CreateSSHKey adds a key pair to the SSH key store
+func (db *DB) CreateSSHKey(key *SSHKey) error {
+	return db.Create(key).Error
+}
+
+// DeleteSSHKey removes a key pair from the SSH key store
+func (db *DB) DeleteSSHKey(id uint) error {
+	result := db.Delete(&SSHKey{}, id)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("SSH key not found: %d", id)
+	}
+	return nil
+}
+
+// CountConfigsUsingSSHKey returns the number of transfer configs that authenticate with a key
+func (db *DB) CountConfigsUsingSSHKey(name string) (int64, error) {
+	var configs []TransferConfig
+	if err := db.Where("source_ssh_key = ? OR dest_ssh_key = ? OR destinations <> ''", name, name).Find(&configs).Error; err != nil {
+		return 0, err
+	}
+
+	var count int64
+	for _, config := range configs {
+		if config.UsesSSHKey(name) {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// CheckSSHKeys returns an error when the config references a managed SSH key that does
+// not exist
+func (db *DB) CheckSSHKeys(config *TransferConfig) error {
+	for _, name := range config.SSHKeyRemotes() {
+		if _, err := db.GetSSHKeyByName(name); err != nil {
+			return fmt.Errorf("SSH key %q not found", name)
+		}
+	}
+	return nil
+}
+
+// GetSSHKeyPrivateKey returns the decrypted private key of the SSH key with the given name
+func (db *DB) GetSSHKeyPrivateKey(name string) (string, error) {
+	key, err := db.GetSSHKeyByName(name)
+	if err != nil {
+		return "", fmt.Errorf("SSH key %q not found", name)
+	}
+	privateKey, err := auth.DecryptKeyMaterial(key.PrivateKey)
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt SSH key %q: %v", name, err)
+	}
+	return privateKey, nil
+}
+
+// sshKeyDir returns the directory the private keys of managed SSH keys are written to
+// while a run uses them
+func sshKeyDir() string {
+	// Get data directory from environment or use default
+	dataDir := os.Getenv("DATA_DIR")
+	if dataDir == "" {
+		dataDir = "./data"
+	}
+
+	// Keep the keys out of the shared temp directory, where other users could
+	// create the directory first or read the files
+	return filepath.Join(dataDir, "ssh-keys")
+}
+
+// sshKeyPath returns the path the private key of an rclone remote authenticating with a
+// managed SSH key is written to while a run uses it
+func sshKeyPath(remote string) string {
+	return filepath.Join(sshKeyDir(), remote+".key")
+}
+
+// privateSSHKeyDir creates the SSH key directory readable by the owner only and checks
+// that an existing one is a real directory nobody else can access
+func privateSSHKeyDir(dir string) error {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return fmt.Errorf("failed to create SSH key directory: %v", err)
+	}
+	info, err := os.Lstat(dir)
+	if err != nil {
+		return fmt.Errorf("failed to check SSH key directory: %v", err)
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("SSH key directory %s is not a directory", dir)
+	}
+	if info.Mode().Perm() != 0700 {
+		// MkdirAll keeps the mode of an existing directory
+		if err := os.Chmod(dir, 0700); err != nil {
+			return fmt.Errorf("failed to restrict SSH key directory: %v", err)
+		}
+	}
+	return nil
+}
+
+// keyFileOption returns the rclone option of the private key an SFTP remote authenticates
+// with, the file of its managed key takes precedence over a key file
+func keyFileOption(remote, keyFile, sshKey string) []string {
+	if sshKey != "" {
+		return []string{"key_file", sshKeyPath(remote)}
+	}
+	if keyFile != "" {
+		return []string{"key_file", keyFile}
+	}
+	return nil
+}
+
+// sshKeyFiles counts the runs using each written key file, so concurrent runs of a config
+// share the file and the last one to finish removes it
+var sshKeyFiles = struct {
+	sync.Mutex
+	refs map[string]int
+}{refs: make(map[string]int)}
+
+// WriteSSHKeys writes the private keys of the managed SSH keys a config authenticates with
+// to files in a private directory under the data directory. The returned function removes the files once the run is done.
+func (db *DB) WriteSSHKeys(config *TransferConfig) (func(), error) {
+	var written []string
+	release := func() {
+		sshKeyFiles.Lock()
+		defer sshKeyFiles.Unlock()
+		for _, path := range written {
+			sshKeyFiles.refs[path]--
+			if sshKeyFiles.refs[path] <= 0 {
+				delete(sshKeyFiles.refs, path)
+				os.Remove(path)
+			}
+		}
+	}
+
+	for remote, name := range config.SSHKeyRemotes() {
+		privateKey, err := db.GetSSHKeyPrivateKey(name)
+		if err != nil {
+			release()
+			return nil, err
+		}
+		path := sshKeyPath(remote)
+		if err := acquireSSHKeyFile(path, privateKey); err != nil {
+			release()
+			return nil, err
+		}
+		written = append(written, path)
+	}
+	return release, nil
+}
+
+// acquireSSHKeyFile writes a private key file readable by the owner only, unless a running
+// run has already written it
+func acquireSSHKeyFile(path, privateKey string) error {
+	sshKeyFiles.Lock()
+	defer sshKeyFiles.Unlock()
+
+	if sshKeyFiles.refs[path] == 0 {
+		if err := privateSSHKeyDir(filepath.Dir(path)); err != nil {
+			return err
+		}
+		// Remove a file left over by a crashed run, WriteFile keeps the mode of existing files
+		os.Remove(path)
+		if err := os.WriteFile(path, []byte(privateKey), 0600); err != nil {
+			return fmt.Errorf("failed to write SSH key file: %v", err)
+		}
+	}
+	sshKeyFiles.refs[path]++
+	return nil
+}
@@ -2,6 +2,8 @@ package db
 
 import (
 	"time"
+
+	"github.com/starfleetcptn/gomft/internal/oauth"
 )
 
 // TransferConfig holds the configuration for a data transfer operation
@@ -15,26 +17,54 @@ type TransferConfig struct {
 	SourceUser     string `form:"source_user"`
 	SourcePassword string `form:"source_password" gorm:"-"` // Not stored in DB, only used for form
 	SourceKeyFile  string `form:"source_key_file"`
+	SourceSSHKey   string `form:"source_ssh_key"` // Name of a managed SSH key, used instead of the key file
 	// S3 source fields
 	SourceBucket    string `form:"source_bucket"`
 	SourceRegion    string `form:"source_region"`
 	SourceAccessKey string `form:"source_access_key"`
 	SourceSecretKey string `form:"source_secret_key" gorm:"-"` // Not stored in DB, only used for form
 	SourceEndpoint  string `form:"source_endpoint"`
+	// Azure source fields (the account, key, container and endpoint use the S3 fields,
+	// the Azure Files share uses the SMB share)
+	SourceSasURL string `form:"source_sas_url" gorm:"-"` // Not stored in DB, only used for form
+	// Google Cloud Storage source fields (the bucket uses the S3 bucket)
+	SourceServiceAccount string `form:"source_service_account"` // Encrypted at rest, service account JSON key
+	SourceProject        string `form:"source_project"`         // Project number, only needed to create buckets
+	SourceStorageClass   string `form:"source_storage_class"`   // Storage class of archived objects
+	SourceObjectACL      string `form:"source_object_acl"`      // "" uses the bucket-level IAM policies
 	// SMB source fields
 	SourceShare  string `form:"source_share"`
 	SourceDomain string `form:"source_domain"`
 	// FTP source fields
-	SourcePassiveMode *bool `gorm:"default:true" form:"source_passive_mode"` // Already a pointer, no change needed here
+	SourcePassiveMode      *bool  `gorm:"default:true" form:"source_passive_mode"`               // Already a pointer, no change needed here
+	SourceFTPSMode         string `form:"source_ftps_mode"`                                      // "" for plain FTP, explicit (AUTH TLS) or implicit
+	SourceFTPSSkipVerify   *bool  `gorm:"default:false" form:"source_ftps_skip_verify"`          // Accept any server certificate
+	SourceFTPSSessionReuse *bool  `gorm:"default:true" form:"source_ftps_session_reuse"`         // Reuse the TLS session on data connections
+	SourceFTPSCACert       string `gorm:"column:source_ftps_ca_cert" form:"source_ftps_ca_cert"` // PEM certificates trusted instead of the system roots
+	SourceFTPSClientCert   string `form:"source_ftps_client_cert"`                               // Encrypted at rest, PEM certificate and private key
+	// HTTP source fields (basic authentication uses the source user)
+	SourceURL            string `form:"source_url"`                           // Index page listing the files, or base of relative URLs
+	SourceURLs           string `gorm:"type:text" form:"source_urls"`         // URLs of the files, one per line, with ${date:layout} variables
+	SourceHTTPHeaders    string `gorm:"type:text" form:"source_http_headers"` // Request headers, one "Name: value" per line
+	SourceHTTPAuth       string `form:"source_http_auth"`                     // "", basic or bearer
+	SourceHTTPSecret     string `form:"source_http_secret"`                   // Encrypted at rest, basic password or bearer token
+	SourceHTTPValidators string `gorm:"type:text"`                            // ETag and Last-Modified of the delivered version of each URL, as JSON
+	SourceHTTPRunDir     string `gorm:"-" form:"-" json:"-"`                  // Not stored in DB, directory of the running transfer in the download directory
 	// OneDrive and Google Drive source fields
 	SourceClientID     string `form:"source_client_id"`
 	SourceClientSecret string `form:"source_client_secret" gorm:"-"` // Not stored in DB, only used for form
 	SourceDriveID      string `form:"source_drive_id"`               // For OneDrive
+	SourceDriveType    string `form:"source_drive_type"`             // For OneDrive: personal, business or documentLibrary
 	SourceTeamDrive    string `form:"source_team_drive"`             // For Google Drive
 	// Google Photos source fields
 	SourceReadOnly        *bool `form:"source_read_only"`        // For Google Photos
 	SourceStartYear       int   `form:"source_start_year"`       // For Google Photos
 	SourceIncludeArchived *bool `form:"source_include_archived"` // For Google Photos
+	// Crypt source fields
+	SourceCrypt                   *bool  `gorm:"default:false" form:"source_crypt"` // Layer an rclone crypt remote over the source
+	SourceCryptPassword           string `form:"source_crypt_password" gorm:"-"`    // Not stored in DB, only used for form
+	SourceCryptSalt               string `form:"source_crypt_salt" gorm:"-"`        // Not stored in DB, only used for form
+	SourceCryptFilenameEncryption string `form:"source_crypt_filename_encryption"`  // standard, obfuscate or off
 	// General fields
 	FilePattern     string `gorm:"default:'*'" form:"file_pattern"`
 	OutputPattern   string `form:"output_pattern"` // Pattern for output filenames with date variables
@@ -45,30 +75,58 @@ type TransferConfig struct {
 	DestUser        string `form:"dest_user"`
 	DestPassword    string `form:"dest_password" gorm:"-"` // Not stored in DB, only used for form
 	DestKeyFile     string `form:"dest_key_file"`
+	DestSSHKey      string `form:"dest_ssh_key"` // Name of a managed SSH key, used instead of the key file
+	// AS2 destination fields
+	DestAS2Partner string `form:"dest_as2_partner"` // Name of the AS2 partner files are sent to
 	// S3 destination fields
 	DestBucket    string `form:"dest_bucket"`
 	DestRegion    string `form:"dest_region"`
 	DestAccessKey string `form:"dest_access_key"`
 	DestSecretKey string `form:"dest_secret_key" gorm:"-"` // Not stored in DB, only used for form
 	DestEndpoint  string `form:"dest_endpoint"`
+	// Azure destination fields (the account, key, container and endpoint use the S3 fields,
+	// the Azure Files share uses the SMB share)
+	DestSasURL string `form:"dest_sas_url" gorm:"-"` // Not stored in DB, only used for form
+	// Google Cloud Storage destination fields (the bucket uses the S3 bucket)
+	DestServiceAccount string `form:"dest_service_account"` // Encrypted at rest, service account JSON key
+	DestProject        string `form:"dest_project"`         // Project number, only needed to create buckets
+	DestStorageClass   string `form:"dest_storage_class"`   // Storage class of delivered objects
+	DestObjectACL      string `form:"dest_object_acl"`      // "" uses the bucket-level IAM policies
 	// SMB destination fields
 	DestShare  string `form:"dest_share"`
 	DestDomain string `form:"dest_domain"`
 	// FTP destination fields
-	DestPassiveMode *bool `gorm:"default:true" form:"dest_passive_mode"`
+	DestPassiveMode      *bool  `gorm:"default:true" form:"dest_passive_mode"`
+	DestFTPSMode         string `form:"dest_ftps_mode"`                                    // "" for plain FTP, explicit (AUTH TLS) or implicit
+	DestFTPSSkipVerify   *bool  `gorm:"default:false" form:"dest_ftps_skip_verify"`        // Accept any server certificate
+	DestFTPSSessionReuse *bool  `gorm:"default:true" form:"dest_ftps_session_reuse"`       // Reuse the TLS session on data connections
+	DestFTPSCACert       string `gorm:"column:dest_ftps_ca_cert" form:"dest_ftps_ca_cert"` // PEM certificates trusted instead of the system roots
+	DestFTPSClientCert   string `form:"dest_ftps_client_cert"`                             // Encrypted at rest, PEM certificate and private key
 	// OneDrive and Google Drive destination fields
 	DestClientID     string `form:"dest_client_id"`
 	DestClientSecret string `form:"dest_client_secret" gorm:"-"` // Not stored in DB, only used for form
 	DestDriveID      string `form:"dest_drive_id"`               // For OneDrive
+	DestDriveType    string `form:"dest_drive_type"`             // For OneDrive: personal, business or documentLibrary
 	DestTeamDrive    string `form:"dest_team_drive"`             // For Google Drive
 	// Google Photos destination fields
 	DestReadOnly        *bool `form:"dest_read_only"`        // For Google Photos
 	DestStartYear       int   `form:"dest_start_year"`       // For Google Photos
 	DestIncludeArchived *bool `form:"dest_include_archived"` // For Google Photos
+	// Crypt destination fields
+	DestCrypt                   *bool  `gorm:"default:false" form:"dest_crypt"` // Layer an rclone crypt remote over the destination
+	DestCryptPassword           string `form:"dest_crypt_password" gorm:"-"`    // Not stored in DB, only used for form
+	DestCryptSalt               string `form:"dest_crypt_salt" gorm:"-"`        // Not stored in DB, only used for form
+	DestCryptFilenameEncryption string `form:"dest_crypt_filename_encryption"`  // standard, obfuscate or off
 	// Security fields
 	UseBuiltinAuthSource     *bool `form:"use_builtin_auth_source"` // For Google and other OAuth services
 	UseBuiltinAuthDest       *bool `form:"use_builtin_auth_dest"`   // For Google and other OAuth services
 	GoogleDriveAuthenticated *bool // Whether Google Drive auth is completed
+	// OAuth tokens of Google Drive, Google Photos, OneDrive, Dropbox and Box remotes
+	SourceOAuthToken string `form:"-" json:"-" gorm:"column:source_oauth_token"` // Encrypted at rest, set by the authorization flow
+	DestOAuthToken   string `form:"-" json:"-" gorm:"column:dest_oauth_token"`   // Encrypted at rest, set by the authorization flow
+	// SSH host keys of the SFTP servers, as known_hosts lines. Keys are stored in the host key store.
+	SourceHostKey string `form:"source_host_key" json:"-" gorm:"-"`
+	DestHostKey   string `form:"dest_host_key" json:"-" gorm:"-"`
 	// General fields
 	ArchivePath    string `form:"archive_path"`
 	ArchiveEnabled *bool  `gorm:"default:false" form:"archive_enabled"`
@@ -80,10 +138,74 @@ type TransferConfig struct {
 	DeleteAfterTransfer    *bool  `gorm:"default:false" form:"delete_after_transfer"`
 	SkipProcessedFiles     *bool  `gorm:"default:true" form:"skip_processed_files"`
 	MaxConcurrentTransfers int    `gorm:"default:4" form:"max_concurrent_transfers"` // Number of concurrent file transfers
-	CreatedBy              uint
-	User                   User `gorm:"foreignkey:CreatedBy"`
-	CreatedAt              time.Time
-	UpdatedAt              time.Time
+	// Filter fields
+	FilterRules   string `form:"filter_rules"`    // JSON array of ordered include/exclude rules
+	FilterMinSize string `form:"filter_min_size"` // rclone size, e.g. 100k
+	FilterMaxSize string `form:"filter_max_size"` // rclone size, e.g. 1G
+	FilterMinAge  string `form:"filter_min_age"`  // rclone duration, e.g. 30m
+	FilterMaxAge  string `form:"filter_max_age"`  // rclone duration, e.g. 7d
+	// Output pattern fields
+	OutputPatternRegex string `form:"output_pattern_regex"` // Regex on the source name whose groups are available as ${match:N}
+	// Conflict fields
+	ConflictPolicy string `form:"conflict_policy"` // "", overwrite, skip, fail, rename_number, rename_timestamp or version
+	// Atomic delivery fields
+	AtomicDelivery   *bool  `gorm:"default:false" form:"atomic_delivery"` // Upload to a temporary name and rename once complete
+	AtomicTempPrefix string `form:"atomic_temp_prefix"`                   // Prefix of the temporary name, e.g. "."
+	AtomicTempSuffix string `form:"atomic_temp_suffix"`                   // Suffix of the temporary name, ".part" when both are empty
+	AtomicVerify     *bool  `gorm:"default:false" form:"atomic_verify"`   // Check the uploaded size before renaming
+	// Retention fields
+	ArchiveRetentionKeepLast   int    `gorm:"default:0" form:"archive_retention_keep_last"`    // Keep only the newest N archived files, 0 keeps all
+	ArchiveRetentionMaxAgeDays int    `gorm:"default:0" form:"archive_retention_max_age_days"` // Delete archived files older than this many days
+	ArchiveRetentionMaxSize    string `form:"archive_retention_max_size"`                      // Cap the archive size, rclone size e.g. 10G
+	DestRetentionKeepLast      int    `gorm:"default:0" form:"dest_retention_keep_last"`       // Keep only the newest N destination files, 0 keeps all
+	DestRetentionMaxAgeDays    int    `gorm:"default:0" form:"dest_retention_max_age_days"`    // Delete destination files older than this many days
+	DestRetentionMaxSize       string `form:"dest_retention_max_size"`                         // Cap the destination size, rclone size e.g. 10G
+	RetentionPreview           *bool  `gorm:"default:false" form:"retention_preview"`          // Only log the files retention would delete
+	// Bisync fields
+	BisyncMaxDelete       int    `gorm:"default:50" form:"bisync_max_delete"` // Abort a bisync run that would delete more than this percentage of files
+	BisyncConflictResolve string `form:"bisync_conflict_resolve"`             // How bisync resolves files changed on both sides, empty keeps both copies
+	// Fan-out fields
+	Destinations          string `form:"destinations"` // JSON array of additional destinations, secrets are not stored
+	DestRemote            string `gorm:"-" form:"-"`   // rclone remote of the destination, set when delivering to an additional destination
+	submittedDestinations string // Destinations as submitted, with secrets, until the config has been saved
+	// Manifest fields
+	ManifestFormat  string `form:"manifest_format"`                    // "", csv, json or xml; a manifest of the delivered files is uploaded after each run
+	ManifestName    string `form:"manifest_name"`                      // Name pattern for the manifest
+	ManifestSign    *bool  `gorm:"default:false" form:"manifest_sign"` // Upload an HMAC-SHA256 signature next to the manifest
+	ManifestHMACKey string `form:"manifest_hmac_key"`                  // Encrypted at rest, key of the manifest signature
+	// Trigger and marker file fields
+	TriggerMode   string `form:"trigger_mode"`   // "", file or folder: only transfer files once their trigger file exists
+	TriggerName   string `form:"trigger_name"`   // Trigger file name, ".done" appended to the file name or in the folder by default
+	TriggerAction string `form:"trigger_action"` // "", delete or archive the trigger once its files have been delivered
+	MarkerMode    string `form:"marker_mode"`    // "", file or folder: write a completion marker at the destination
+	MarkerName    string `form:"marker_name"`    // Marker file name, ".done" appended to the file name or in the folder by default
+	// Quarantine fields
+	QuarantinePath  string `form:"quarantine_path"`                   // Folder on the source that files failing repeatedly are moved to
+	QuarantineAfter int    `gorm:"default:3" form:"quarantine_after"` // Consecutive failures of a file before it is quarantined
+	// Deduplication fields
+	DedupeScope  string `form:"dedupe_scope"`  // "", job, config, destination or global: files whose content was already delivered within the scope are duplicates
+	DedupeAction string `form:"dedupe_action"` // skip (default) or alert: deliver duplicates and report them
+	// Change detection fields
+	ChangeDetection string     `form:"change_detection"` // "" (hashes), fingerprint, high_water_mark or download_hash: how unchanged files are recognised when skipping processed files
+	HighWaterMark   *time.Time // Newest modification time delivered with high_water_mark change detection
+	// Stable file detection fields
+	MinFileAge     int   `gorm:"default:0" form:"min_file_age"`        // Only transfer files not modified for this many seconds
+	StabilityCheck *bool `gorm:"default:false" form:"stability_check"` // List files twice and skip files whose size or mtime changed
+	StabilityDelay int   `gorm:"default:30" form:"stability_delay"`    // Seconds between the two listings of the stability check
+	// Processing fields
+	CompressionType     string `form:"compression_type"`                           // "", gzip, zstd or zip (bundle all files of a run)
+	BundleName          string `form:"bundle_name"`                                // Name pattern for zip bundles
+	DecompressOnReceive *bool  `gorm:"default:false" form:"decompress_on_receive"` // Decompress .gz, .zst and .zip files before delivery
+	// Encryption fields
+	EncryptionMode   string `form:"encryption_mode"`                        // "", encrypt or decrypt
+	EncryptionKeyIDs string `form:"encryption_key_ids"`                     // Comma separated key IDs: recipients when encrypting, private keys when decrypting
+	SigningKeyID     uint   `gorm:"default:0" form:"signing_key_id"`        // Private PGP key used to sign outgoing files, 0 to not sign
+	VerifyKeyIDs     string `form:"verify_key_ids"`                         // Comma separated public PGP key IDs trusted to sign incoming files
+	RequireSignature *bool  `gorm:"default:false" form:"require_signature"` // Reject incoming files without a valid signature
+	CreatedBy        uint
+	User             User `gorm:"foreignkey:CreatedBy"`
+	CreatedAt        time.Time
+	UpdatedAt        time.Time
 }
 
 // --- TransferConfig Helper Methods ---
@@ -114,6 +236,26 @@ func (tc *TransferConfig) SetDestPassiveMode(value bool) {
 	tc.DestPassiveMode = &value
 }
 
+// GetSourceFTPSSkipVerify returns whether the FTPS source accepts any server certificate
+func (tc *TransferConfig) GetSourceFTPSSkipVerify() bool {
+	return tc.SourceFTPSSkipVerify != nil && *tc.SourceFTPSSkipVerify
+}
+
+// GetSourceFTPSSessionReuse returns whether the FTPS source reuses TLS sessions, default true
+func (tc *TransferConfig) GetSourceFTPSSessionReuse() bool {
+	return tc.SourceFTPSSessionReuse == nil || *tc.SourceFTPSSessionReuse
+}
+
+// GetDestFTPSSkipVerify returns whether the FTPS destination accepts any server certificate
+func (tc *TransferConfig) GetDestFTPSSkipVerify() bool {
+	return tc.DestFTPSSkipVerify != nil && *tc.DestFTPSSkipVerify
+}
+
+// GetDestFTPSSessionReuse returns whether the FTPS destination reuses TLS sessions, default true
+func (tc *TransferConfig) GetDestFTPSSessionReuse() bool {
+	return tc.DestFTPSSessionReuse == nil || *tc.DestFTPSSessionReuse
+}
+
 // GetGoogleDriveAuthenticated returns whether the transfer config has been authenticated with Google Drive
 func (tc *TransferConfig) GetGoogleDriveAuthenticated() bool {
 	return tc.GoogleDriveAuthenticated != nil && *tc.GoogleDriveAuthenticated
@@ -134,6 +276,64 @@ func (tc *TransferConfig) SetGoogleAuthenticated(value bool) {
 	tc.SetGoogleDriveAuthenticated(value)
 }
 
+// SideType returns the type of the "source" or "dest" side of the config
+func (tc *TransferConfig) SideType(side string) string {
+	if side == "source" {
+		return tc.SourceType
+	}
+	return tc.DestinationType
+}
+
+// OAuthSides returns the sides of the config whose remote is authorized with OAuth
+func (tc *TransferConfig) OAuthSides() []string {
+	var sides []string
+	for _, side := range []string{"source", "dest"} {
+		if oauth.IsOAuthType(tc.SideType(side)) {
+			sides = append(sides, side)
+		}
+	}
+	return sides
+}
+
+// RequiresOAuth reports whether the source or destination is authorized with OAuth
+func (tc *TransferConfig) RequiresOAuth() bool {
+	return len(tc.OAuthSides()) > 0
+}
+
+// GetOAuthToken returns the encrypted OAuth token of a side
+func (tc *TransferConfig) GetOAuthToken(side string) string {
+	if side == "source" {
+		return tc.SourceOAuthToken
+	}
+	return tc.DestOAuthToken
+}
+
+// SetOAuthToken sets the encrypted OAuth token of a side
+func (tc *TransferConfig) SetOAuthToken(side, token string) {
+	if side == "source" {
+		tc.SourceOAuthToken = token
+	} else {
+		tc.DestOAuthToken = token
+	}
+}
+
+// UnauthorizedOAuthSide returns the first side that still has to be authorized, or ""
+func (tc *TransferConfig) UnauthorizedOAuthSide() string {
+	for _, side := range tc.OAuthSides() {
+		// Google configs authorized before tokens were stored only have the flag
+		isGoogle := tc.SideType(side) == "gdrive" || tc.SideType(side) == "gphotos"
+		if tc.GetOAuthToken(side) == "" && !(isGoogle && tc.GetGoogleAuthenticated()) {
+			return side
+		}
+	}
+	return ""
+}
+
+// GetOAuthAuthenticated returns whether every OAuth remote of the config has been authorized
+func (tc *TransferConfig) GetOAuthAuthenticated() bool {
+	return tc.UnauthorizedOAuthSide() == ""
+}
+
 // GetArchiveEnabled returns the value of ArchiveEnabled with a default if nil
 func (tc *TransferConfig) GetArchiveEnabled() bool {
 	if tc.ArchiveEnabled == nil {
@@ -198,3 +398,120 @@ func (tc *TransferConfig) GetUseBuiltinAuthDest() bool {
 func (tc *TransferConfig) SetUseBuiltinAuthDest(value bool) {
 	tc.UseBuiltinAuthDest = &value
 }
+
+// GetDecompressOnReceive returns the value of DecompressOnReceive with a default if nil
+func (tc *TransferConfig) GetDecompressOnReceive() bool {
+	if tc.DecompressOnReceive == nil {
+		return false // Default to false if not set
+	}
+	return *tc.DecompressOnReceive
+}
+
+// SetDecompressOnReceive sets the DecompressOnReceive field
+func (tc *TransferConfig) SetDecompressOnReceive(value bool) {
+	tc.DecompressOnReceive = &value
+}
+
+// GetRequireSignature returns the value of RequireSignature with a default if nil
+func (tc *TransferConfig) GetRequireSignature() bool {
+	if tc.RequireSignature == nil {
+		return false // Default to false if not set
+	}
+	return *tc.RequireSignature
+}
+
+// SetRequireSignature sets the RequireSignature field
+func (tc *TransferConfig) SetRequireSignature(value bool) {
+	tc.RequireSignature = &value
+}
+
+// GetSourceCrypt returns the value of SourceCrypt with a default if nil
+func (tc *TransferConfig) GetSourceCrypt() bool {
+	if tc.SourceCrypt == nil {
+		return false // Default to false if not set
+	}
+	return *tc.SourceCrypt
+}
+
+// SetSourceCrypt sets the SourceCrypt field
+func (tc *TransferConfig) SetSourceCrypt(value bool) {
+	tc.SourceCrypt = &value
+}
+
+// GetDestCrypt returns the value of DestCrypt with a default if nil
+func (tc *TransferConfig) GetDestCrypt() bool {
+	if tc.DestCrypt == nil {
+		return false // Default to false if not set
+	}
+	return *tc.DestCrypt
+}
+
+// SetDestCrypt sets the DestCrypt field
+func (tc *TransferConfig) SetDestCrypt(value bool) {
+	tc.DestCrypt = &value
+}
+
+// GetStabilityCheck returns the value of StabilityCheck with a default if nil
+func (tc *TransferConfig) GetStabilityCheck() bool {
+	if tc.StabilityCheck == nil {
+		return false // Default to false if not set
+	}
+	return *tc.StabilityCheck
+}
+
+// SetStabilityCheck sets the StabilityCheck field
+func (tc *TransferConfig) SetStabilityCheck(value bool) {
+	tc.StabilityCheck = &value
+}
+
+// GetAtomicDelivery returns the value of AtomicDelivery with a default if nil
+func (tc *TransferConfig) GetAtomicDelivery() bool {
+	if tc.AtomicDelivery == nil {
+		return false // Default to false if not set
+	}
+	return *tc.AtomicDelivery
+}
+
+// SetAtomicDelivery sets the AtomicDelivery field
+func (tc *TransferConfig) SetAtomicDelivery(value bool) {
+	tc.AtomicDelivery = &value
+}
+
+// GetAtomicVerify returns the value of AtomicVerify with a default if nil
+func (tc *TransferConfig) GetAtomicVerify() bool {
+	if tc.AtomicVerify == nil {
+		return false // Default to false if not set
+	}
+	return *tc.AtomicVerify
+}
+
+// SetAtomicVerify sets the AtomicVerify field
+func (tc *TransferConfig) SetAtomicVerify(value bool) {
+	tc.AtomicVerify = &value
+}
+
+// GetRetentionPreview returns the value of RetentionPreview with a default if nil
+func (tc *TransferConfig) GetRetentionPreview() bool {
+	if tc.RetentionPreview == nil {
+		return false // Default to false if not set
+	}
+	return *tc.RetentionPreview
+}
+
+// SetRetentionPreview sets the RetentionPreview field
+func (tc *TransferConfig) SetRetentionPreview(value bool) {
+	tc.RetentionPreview = &value
+}
+
+// GetManifestSign returns the value of ManifestSign with a default if nil
+func (tc *TransferConfig) GetManifestSign() bool {
+	if tc.ManifestSign == nil {
+		return false // Default to false if not set
+	}
+	return *tc.ManifestSign
+}
+
+// SetManifestSign sets the ManifestSign field
+func (tc *TransferConfig) SetManifestSign(value bool) {
+	tc.ManifestSign = &value
+}
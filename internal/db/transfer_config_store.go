@@ -7,8 +7,12 @@ import (
 	"os/exec"
 	"path/filepath"
 	"regexp"
+	"slices"
 	"strconv"
 	"strings"
+	"time"
+
+	"github.com/starfleetcptn/gomft/internal/auth"
 )
 
 // --- TransferConfig Store Methods ---
@@ -40,6 +44,35 @@ func (db *DB) UpdateTransferConfig(config *TransferConfig) error {
 	return db.Save(config).Error
 }
 
+// UpdateHighWaterMark stores the high-water mark of a transfer config without saving its other fields
+func (db *DB) UpdateHighWaterMark(configID uint, mark time.Time) error {
+	return db.Model(&TransferConfig{}).Where("id = ?", configID).Update("high_water_mark", mark).Error
+}
+
+// UpdateHTTPValidators stores the validators of the URLs of an HTTP source without saving
+// the other fields of its transfer config
+func (db *DB) UpdateHTTPValidators(configID uint, validators string) error {
+	return db.Model(&TransferConfig{}).Where("id = ?", configID).Update("source_http_validators", validators).Error
+}
+
+// GetConfigIDsWithDestination returns the IDs of the transfer configs delivering to the
+// same main destination as the given config, including the config itself
+func (db *DB) GetConfigIDsWithDestination(config *TransferConfig) ([]uint, error) {
+	var ids []uint
+	err := db.Model(&TransferConfig{}).
+		Where("destination_type = ? AND destination_path = ?", config.DestinationType, config.DestinationPath).
+		Where("COALESCE(dest_host, '') = ? AND COALESCE(dest_bucket, '') = ? AND COALESCE(dest_share, '') = ?", config.DestHost, config.DestBucket, config.DestShare).
+		Pluck("id", &ids).Error
+	if err != nil {
+		return nil, err
+	}
+	// A saved config is already listed, one that is being created is not
+	if !slices.Contains(ids, config.ID) {
+		ids = append(ids, config.ID)
+	}
+	return ids, nil
+}
+
 // DeleteTransferConfig deletes a transfer config record after checking dependencies
 func (db *DB) DeleteTransferConfig(id uint) error {
 	// First check if any jobs are using this config
@@ -54,7 +87,7 @@ func (db *DB) DeleteTransferConfig(id uint) error {
 	}
 
 	// Delete the config
-	return db.Delete(&TransferConfig{}, id).Error
+	return db.Delete(&TransferConfig{ID: id}).Error
 }
 
 // GetConfigRclonePath returns the path to the rclone config file for a given transfer config
@@ -69,6 +102,36 @@ func (db *DB) GetConfigRclonePath(config *TransferConfig) string {
 	return filepath.Join(dataDir, "configs", fmt.Sprintf("config_%d.conf", config.ID))
 }
 
+// GetConfigBisyncDir returns the bisync working directory for a given transfer config
+func (db *DB) GetConfigBisyncDir(config *TransferConfig) string {
+	// Get data directory from environment or use default
+	dataDir := os.Getenv("DATA_DIR")
+	if dataDir == "" {
+		dataDir = "./data"
+	}
+
+	// Keep the bisync listings of each config in their own directory
+	return filepath.Join(dataDir, "bisync", fmt.Sprintf("config_%d", config.ID))
+}
+
+// GetConfigHTTPDir returns the directory the source remote of an HTTP source reads from,
+// each run of a given transfer config downloads its files to a directory of its own in it
+func (db *DB) GetConfigHTTPDir(config *TransferConfig) string {
+	// Get data directory from environment or use default
+	dataDir := os.Getenv("DATA_DIR")
+	if dataDir == "" {
+		dataDir = "./data"
+	}
+
+	return filepath.Join(dataDir, "http", fmt.Sprintf("config_%d", config.ID))
+}
+
+// ResetBisyncState removes the bisync working directory of a transfer config so the
+// next bisync run starts over with a resync
+func (db *DB) ResetBisyncState(config *TransferConfig) error {
+	return os.RemoveAll(db.GetConfigBisyncDir(config))
+}
+
 // GenerateRcloneConfig generates the rclone config file content based on TransferConfig
 // This function now primarily focuses on generating the content string or calling rclone config create
 func (db *DB) GenerateRcloneConfig(config *TransferConfig) error {
@@ -89,6 +152,25 @@ func (db *DB) GenerateRcloneConfig(config *TransferConfig) error {
 	}
 
 	sourceName := fmt.Sprintf("source_%d", config.ID)
+	destName := fmt.Sprintf("dest_%d", config.ID)
+
+	// Crypt passwords are not stored, keep them from the existing crypt sections when they are not re-entered
+	existingSourceCrypt := readConfigSection(configPath, sourceName+"_crypt")
+	existingDestCrypt := readConfigSection(configPath, destName+"_crypt")
+	existingDestinations := readConfigSections(configPath, destName+"_")
+	delete(existingDestinations, destName+"_crypt")
+
+	// The local source rewrites the config file, keep the tokens of OAuth remotes first
+	if err := db.keepOAuthTokens(config); err != nil {
+		return err
+	}
+
+	// SFTP remotes of servers with a pinned host key only accept that key
+	known, err := db.writeKnownHosts(config)
+	if err != nil {
+		return err
+	}
+
 	// Generate rclone config using rclone CLI for source
 	switch config.SourceType {
 	case "sftp", "hetzner":
@@ -104,9 +186,8 @@ func (db *DB) GenerateRcloneConfig(config *TransferConfig) error {
 		if config.SourcePassword != "" {
 			args = append(args, "pass", config.SourcePassword)
 		}
-		if config.SourceKeyFile != "" {
-			args = append(args, "key_file", config.SourceKeyFile)
-		}
+		args = append(args, keyFileOption(sourceName, config.SourceKeyFile, config.SourceSSHKey)...)
+		args = append(args, known.option(config.SourceHost, config.SourcePort)...)
 		cmd := exec.Command(rclonePath, args...)
 		if output, err := cmd.CombinedOutput(); err != nil {
 			return fmt.Errorf("failed to create source config (sftp): %v\nOutput: %s", err, output)
@@ -228,7 +309,70 @@ func (db *DB) GenerateRcloneConfig(config *TransferConfig) error {
 			if len(output) > 0 {
 				errorMsg += fmt.Sprintf("\nOutput: %s", output)
 			}
-			return fmt.Errorf(errorMsg)
+			return fmt.Errorf("%s", errorMsg)
+		}
+	case "ftp":
+		opts, err := config.FTPSOptions("source")
+		if err != nil {
+			return fmt.Errorf("failed to create source config (ftp): %v", err)
+		}
+		args := []string{
+			"config", "create", sourceName, "ftp",
+			"--non-interactive",
+			"--config", configPath,
+			"--log-level", "ERROR",
+		}
+		args = append(args, ftpConfigArgs(config.SourceHost, config.SourcePort, config.SourceUser, config.SourcePassword, opts)...)
+		cmd := exec.Command(rclonePath, args...)
+		if output, err := cmd.CombinedOutput(); err != nil {
+			return fmt.Errorf("failed to create source config (ftp): %v\nOutput: %s", err, output)
+		}
+		if err := writeFTPSCertificates(configPath, sourceName, opts); err != nil {
+			return fmt.Errorf("failed to create source config (ftp): %v", err)
+		}
+	case "gcs":
+		gcsArgs, err := gcsConfigArgs(config.SourceServiceAccount, config.SourceProject, config.SourceStorageClass, config.SourceObjectACL)
+		if err != nil {
+			return fmt.Errorf("failed to create source config (gcs): %v", err)
+		}
+		args := []string{
+			"config", "create", sourceName, "google cloud storage",
+			"--non-interactive",
+			"--config", configPath,
+			"--log-level", "ERROR",
+		}
+		args = append(args, gcsArgs...)
+		cmd := exec.Command(rclonePath, args...)
+		if output, err := cmd.CombinedOutput(); err != nil {
+			return fmt.Errorf("failed to create source config (gcs): %v\nOutput: %s", err, output)
+		}
+	case "azureblob", "azurefiles":
+		args := []string{
+			"config", "create", sourceName, config.SourceType,
+			"--non-interactive",
+			"--config", configPath,
+			"--log-level", "ERROR",
+		}
+		args = append(args, azureConfigArgs(config.SourceType, config.SourceAccessKey, config.SourceSecretKey,
+			config.SourceSasURL, config.SourceShare, config.SourceEndpoint)...)
+		cmd := exec.Command(rclonePath, args...)
+		if output, err := cmd.CombinedOutput(); err != nil {
+			return fmt.Errorf("failed to create source config (%s): %v\nOutput: %s", config.SourceType, err, output)
+		}
+	case "gdrive", "gphotos", "onedrive", "dropbox", "box":
+		// The token is added by the authorization flow
+		if err := writeOAuthRemote(configPath, "source", config, ""); err != nil {
+			return fmt.Errorf("failed to create source config (%s): %v", config.SourceType, err)
+		}
+	case "http":
+		// Runs download the files of the URLs, which the source remote reads from
+		downloadDir, err := filepath.Abs(db.GetConfigHTTPDir(config))
+		if err != nil {
+			return fmt.Errorf("failed to write source config (http): %v", err)
+		}
+		content := fmt.Sprintf("[%s]\ntype = alias\nremote = %s\n\n", sourceName, downloadDir)
+		if err := os.WriteFile(configPath, []byte(content), 0600); err != nil {
+			return fmt.Errorf("failed to write source config (http): %v", err)
 		}
 	case "local":
 		// For local source, ensure the section exists but might not need specific rclone config create
@@ -242,8 +386,69 @@ func (db *DB) GenerateRcloneConfig(config *TransferConfig) error {
 
 	}
 
-	destName := fmt.Sprintf("dest_%d", config.ID)
 	// Generate rclone config using rclone CLI for destination
+	if err := createDestRemote(rclonePath, configPath, destName, config, known); err != nil {
+		return err
+	}
+
+	// Destination secrets are not stored, keep the secret of an additional destination
+	// from its existing section when it is not re-entered
+	destinations, err := config.GetDestinations()
+	if err != nil {
+		return err
+	}
+	for i, destination := range destinations {
+		destinationConfig := config.ForDestination(destination)
+		remote := destinationConfig.GetDestRemote()
+		existing := existingDestinations[remote]
+		delete(existingDestinations, remote)
+		if err := createDestRemote(rclonePath, configPath, remote, &destinationConfig, known); err != nil {
+			return fmt.Errorf("%s: %v", destination.Label(i), err)
+		}
+		if destination.Password == "" && destination.SecretKey == "" && existing != "" {
+			if err := keepSecretOptions(configPath, remote, existing); err != nil {
+				return fmt.Errorf("failed to keep config of %s: %v", destination.Label(i), err)
+			}
+		}
+	}
+	// Remove the remotes of destinations that are no longer configured
+	for remote := range existingDestinations {
+		if err := removeConfigSection(configPath, remote); err != nil {
+			return fmt.Errorf("failed to remove config of removed destination: %v", err)
+		}
+	}
+
+	// Layer crypt remotes over the configured source and destination directories
+	if config.GetSourceCrypt() && config.SourceCryptPassword == "" && existingSourceCrypt != "" {
+		remote := fmt.Sprintf("%s:%s", sourceName, remoteRootPath(config.SourceType, config.SourceBucket, config.SourcePath))
+		if err := updateCryptRemote(configPath, sourceName+"_crypt", remote, existingSourceCrypt, config.SourceCryptFilenameEncryption); err != nil {
+			return fmt.Errorf("failed to keep source crypt config: %v", err)
+		}
+	} else if config.GetSourceCrypt() {
+		remote := fmt.Sprintf("%s:%s", sourceName, remoteRootPath(config.SourceType, config.SourceBucket, config.SourcePath))
+		if err := createCryptRemote(rclonePath, configPath, sourceName+"_crypt", remote,
+			config.SourceCryptPassword, config.SourceCryptSalt, config.SourceCryptFilenameEncryption); err != nil {
+			return fmt.Errorf("failed to create source crypt config: %v", err)
+		}
+	}
+	if config.GetDestCrypt() && config.DestCryptPassword == "" && existingDestCrypt != "" {
+		remote := fmt.Sprintf("%s:%s", destName, remoteRootPath(config.DestinationType, config.DestBucket, config.DestinationPath))
+		if err := updateCryptRemote(configPath, destName+"_crypt", remote, existingDestCrypt, config.DestCryptFilenameEncryption); err != nil {
+			return fmt.Errorf("failed to keep destination crypt config: %v", err)
+		}
+	} else if config.GetDestCrypt() {
+		remote := fmt.Sprintf("%s:%s", destName, remoteRootPath(config.DestinationType, config.DestBucket, config.DestinationPath))
+		if err := createCryptRemote(rclonePath, configPath, destName+"_crypt", remote,
+			config.DestCryptPassword, config.DestCryptSalt, config.DestCryptFilenameEncryption); err != nil {
+			return fmt.Errorf("failed to create destination crypt config: %v", err)
+		}
+	}
+
+	return nil
+}
+
+// createDestRemote creates the rclone remote of the destination of a config
+func createDestRemote(rclonePath, configPath, destName string, config *TransferConfig, known *knownHosts) error {
 	switch config.DestinationType {
 	case "sftp", "hetzner":
 		args := []string{
@@ -258,9 +463,8 @@ func (db *DB) GenerateRcloneConfig(config *TransferConfig) error {
 		if config.DestPassword != "" {
 			args = append(args, "pass", config.DestPassword)
 		}
-		if config.DestKeyFile != "" {
-			args = append(args, "key_file", config.DestKeyFile)
-		}
+		args = append(args, keyFileOption(destName, config.DestKeyFile, config.DestSSHKey)...)
+		args = append(args, known.option(config.DestHost, config.DestPort)...)
 		cmd := exec.Command(rclonePath, args...)
 		if output, err := cmd.CombinedOutput(); err != nil {
 			return fmt.Errorf("failed to create destination config (sftp): %v\nOutput: %s", err, output)
@@ -380,8 +584,63 @@ func (db *DB) GenerateRcloneConfig(config *TransferConfig) error {
 			if len(output) > 0 {
 				errorMsg += fmt.Sprintf("\nOutput: %s", output)
 			}
-			return fmt.Errorf(errorMsg)
+			return fmt.Errorf("%s", errorMsg)
+		}
+	case "ftp":
+		opts, err := config.FTPSOptions("dest")
+		if err != nil {
+			return fmt.Errorf("failed to create destination config (ftp): %v", err)
+		}
+		args := []string{
+			"config", "create", destName, "ftp",
+			"--non-interactive",
+			"--config", configPath,
+			"--log-level", "ERROR",
+		}
+		args = append(args, ftpConfigArgs(config.DestHost, config.DestPort, config.DestUser, config.DestPassword, opts)...)
+		cmd := exec.Command(rclonePath, args...)
+		if output, err := cmd.CombinedOutput(); err != nil {
+			return fmt.Errorf("failed to create destination config (ftp): %v\nOutput: %s", err, output)
+		}
+		if err := writeFTPSCertificates(configPath, destName, opts); err != nil {
+			return fmt.Errorf("failed to create destination config (ftp): %v", err)
+		}
+	case "gcs":
+		gcsArgs, err := gcsConfigArgs(config.DestServiceAccount, config.DestProject, config.DestStorageClass, config.DestObjectACL)
+		if err != nil {
+			return fmt.Errorf("failed to create destination config (gcs): %v", err)
+		}
+		args := []string{
+			"config", "create", destName, "google cloud storage",
+			"--non-interactive",
+			"--config", configPath,
+			"--log-level", "ERROR",
+		}
+		args = append(args, gcsArgs...)
+		cmd := exec.Command(rclonePath, args...)
+		if output, err := cmd.CombinedOutput(); err != nil {
+			return fmt.Errorf("failed to create destination config (gcs): %v\nOutput: %s", err, output)
+		}
+	case "azureblob", "azurefiles":
+		args := []string{
+			"config", "create", destName, config.DestinationType,
+			"--non-interactive",
+			"--config", configPath,
+			"--log-level", "ERROR",
+		}
+		args = append(args, azureConfigArgs(config.DestinationType, config.DestAccessKey, config.DestSecretKey,
+			config.DestSasURL, config.DestShare, config.DestEndpoint)...)
+		cmd := exec.Command(rclonePath, args...)
+		if output, err := cmd.CombinedOutput(); err != nil {
+			return fmt.Errorf("failed to create destination config (%s): %v\nOutput: %s", config.DestinationType, err, output)
 		}
+	case "gdrive", "gphotos", "onedrive", "dropbox", "box":
+		// The token is added by the authorization flow
+		if err := writeOAuthRemote(configPath, "dest", config, ""); err != nil {
+			return fmt.Errorf("failed to create destination config (%s): %v", config.DestinationType, err)
+		}
+	case "as2":
+		// Files are sent to the AS2 partner by GoMFT, no remote is needed
 	case "local":
 		// Append local config section
 		content := fmt.Sprintf("\n[%s]\ntype = local\n", destName)
@@ -401,6 +660,199 @@ func (db *DB) GenerateRcloneConfig(config *TransferConfig) error {
 	return nil
 }
 
+// gcsConfigArgs returns the rclone config options of a Google Cloud Storage remote. The
+// service account key is stored encrypted and decrypted into the rclone config. Without
+// an object ACL, access is controlled by the bucket-level IAM policies.
+func gcsConfigArgs(serviceAccount, project, storageClass, objectACL string) ([]string, error) {
+	credentials, err := auth.DecryptKeyMaterial(serviceAccount)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt service account key: %v", err)
+	}
+	if credentials == "" {
+		return nil, fmt.Errorf("a service account key is required")
+	}
+
+	args := []string{"service_account_credentials", credentials, "env_auth", "false"}
+	if project != "" {
+		args = append(args, "project_number", project)
+	}
+	if storageClass != "" {
+		args = append(args, "storage_class", storageClass)
+	}
+	if objectACL != "" {
+		args = append(args, "object_acl", objectACL)
+	} else {
+		args = append(args, "bucket_policy_only", "true")
+	}
+	return args, nil
+}
+
+// azureConfigArgs returns the rclone config options of an Azure Blob Storage or Azure
+// Files remote. A SAS URL replaces the account key, and the endpoint points the remote
+// at a storage emulator such as Azurite.
+func azureConfigArgs(storageType, account, key, sasURL, share, endpoint string) []string {
+	var args []string
+	if sasURL != "" {
+		args = append(args, "sas_url", sasURL)
+	} else {
+		args = append(args, "account", account, "key", key)
+	}
+	if storageType == "azurefiles" {
+		args = append(args, "share_name", share)
+	}
+	if endpoint != "" {
+		args = append(args, "endpoint", endpoint)
+	}
+	return args
+}
+
+// remoteRootPath returns the path of the configured directory within a remote,
+// including the bucket for bucket based storage
+func remoteRootPath(storageType, bucket, path string) string {
+	if storageType == "s3" || storageType == "minio" || storageType == "b2" || storageType == "azureblob" || storageType == "gcs" {
+		if path != "" && path != "/" {
+			return fmt.Sprintf("%s/%s", bucket, path)
+		}
+		return bucket
+	}
+	return path
+}
+
+// readConfigSection returns the raw content of a section of an rclone config file, or "" if missing
+func readConfigSection(configPath, name string) string {
+	content, err := os.ReadFile(configPath)
+	if err != nil {
+		return ""
+	}
+	sectionPattern := regexp.MustCompile(fmt.Sprintf(`(?m)^%s[^\[]*`, regexp.QuoteMeta(fmt.Sprintf("[%s]", name))))
+	return sectionPattern.FindString(string(content))
+}
+
+// readConfigSections returns the raw content of the sections of an rclone config file
+// whose name starts with prefix, by name
+func readConfigSections(configPath, prefix string) map[string]string {
+	sections := make(map[string]string)
+	content, err := os.ReadFile(configPath)
+	if err != nil {
+		return sections
+	}
+	namePattern := regexp.MustCompile(fmt.Sprintf(`(?m)^\[(%s[^\]]*)\]`, regexp.QuoteMeta(prefix)))
+	for _, match := range namePattern.FindAllStringSubmatch(string(content), -1) {
+		sections[match[1]] = readConfigSection(configPath, match[1])
+	}
+	return sections
+}
+
+// removeConfigSection removes a section from an rclone config file
+func removeConfigSection(configPath, name string) error {
+	content, err := os.ReadFile(configPath)
+	if err != nil {
+		return err
+	}
+	sectionPattern := regexp.MustCompile(fmt.Sprintf(`(?m)^%s[^\[]*`, regexp.QuoteMeta(fmt.Sprintf("[%s]", name))))
+	return os.WriteFile(configPath, []byte(sectionPattern.ReplaceAllLiteralString(string(content), "")), 0600)
+}
+
+// secretOptions are the options of rclone remotes that hold a secret which is not stored
+var secretOptions = []string{"pass", "secret_access_key", "key"}
+
+// keepSecretOptions copies the secrets of a previous section of a remote into its newly
+// written section, as long as the remote kept its type. All other options keep their
+// new values.
+func keepSecretOptions(configPath, name, previous string) error {
+	section := readConfigSection(configPath, name)
+	if section == "" || configSectionValue(section, "type") != configSectionValue(previous, "type") {
+		return nil
+	}
+	for _, key := range secretOptions {
+		if value := configSectionValue(previous, key); value != "" && configSectionValue(section, key) == "" {
+			section = setSectionOption(section, key, []string{key, value})
+		}
+	}
+	return replaceConfigSection(configPath, name, section)
+}
+
+// setSectionOption sets an option of a section of an rclone config, given as a key and
+// value pair, or removes the option when none is given
+func setSectionOption(section, key string, option []string) string {
+	var lines []string
+	for _, line := range strings.Split(strings.TrimSpace(section), "\n") {
+		parts := strings.SplitN(line, "=", 2)
+		if len(parts) == 2 && strings.TrimSpace(parts[0]) == key {
+			continue
+		}
+		lines = append(lines, line)
+	}
+	if len(option) == 2 {
+		lines = append(lines, fmt.Sprintf("%s = %s", option[0], option[1]))
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// cryptNameEncryption returns the filename and directory name encryption options of a
+// crypt remote for a filename encryption mode
+func cryptNameEncryption(filenameEncryption string) (string, string, error) {
+	switch filenameEncryption {
+	case "":
+		return "standard", "true", nil
+	case "standard", "obfuscate":
+		return filenameEncryption, "true", nil
+	case "off":
+		return filenameEncryption, "false", nil
+	default:
+		return "", "", fmt.Errorf("unsupported filename encryption mode: %s", filenameEncryption)
+	}
+}
+
+// updateCryptRemote rewrites a crypt remote whose passwords were not re-entered. Only the
+// passwords are kept from its previous section, the remote it wraps and the filename
+// encryption follow the config.
+func updateCryptRemote(configPath, name, remote, previous, filenameEncryption string) error {
+	filenameEncryption, directoryNameEncryption, err := cryptNameEncryption(filenameEncryption)
+	if err != nil {
+		return err
+	}
+	section := fmt.Sprintf("[%s]\ntype = crypt\nremote = %s\nfilename_encryption = %s\ndirectory_name_encryption = %s\n",
+		name, remote, filenameEncryption, directoryNameEncryption)
+	for _, key := range []string{"password", "password2"} {
+		if value := configSectionValue(previous, key); value != "" {
+			section = setSectionOption(section, key, []string{key, value})
+		}
+	}
+	return replaceConfigSection(configPath, name, section)
+}
+
+// createCryptRemote creates an rclone crypt remote that encrypts everything stored below remote
+func createCryptRemote(rclonePath, configPath, name, remote, password, salt, filenameEncryption string) error {
+	if password == "" {
+		return fmt.Errorf("a crypt password is required")
+	}
+
+	filenameEncryption, directoryNameEncryption, err := cryptNameEncryption(filenameEncryption)
+	if err != nil {
+		return err
+	}
+
+	args := []string{
+		"config", "create", name, "crypt",
+		"remote", remote,
+		"password", password, // rclone obscures this
+		"filename_encryption", filenameEncryption,
+		"directory_name_encryption", directoryNameEncryption,
+		"--non-interactive",
+		"--config", configPath,
+		"--log-level", "ERROR",
+	}
+	if salt != "" {
+		args = append(args, "password2", salt)
+	}
+	cmd := exec.Command(rclonePath, args...)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("%v\nOutput: %s", err, output)
+	}
+	return nil
+}
+
 // StoreGoogleDriveToken stores the Google Drive auth token for a config
 func (db *DB) StoreGoogleDriveToken(configIDStr string, token string) error {
 	configID, err := strconv.ParseUint(configIDStr, 10, 64)
@@ -469,11 +921,10 @@ func (db *DB) StoreGoogleDriveToken(configIDStr string, token string) error {
 	return nil
 }
 
-// GenerateRcloneConfigWithToken generates a rclone config file for a transfer config with a provided token
-// Note: This seems partially redundant with StoreGoogleDriveToken and GenerateRcloneConfig. Consolidate if possible.
+// GenerateRcloneConfigWithToken stores the token of the Google Drive or Google Photos
+// remote of a config, the destination when both are
 func (db *DB) GenerateRcloneConfigWithToken(config *TransferConfig, token string) error {
-	configPath := db.GetConfigRclonePath(config)
-	if configPath == "" {
+	if db.GetConfigRclonePath(config) == "" {
 		return fmt.Errorf("failed to get config path")
 	}
 
@@ -481,116 +932,12 @@ func (db *DB) GenerateRcloneConfigWithToken(config *TransferConfig, token string
 	token = strings.ReplaceAll(token, "\n", "")
 	token = strings.ReplaceAll(token, "\r", "")
 
-	var configType, section, clientID, clientSecret string
-	var readOnly, includeArchived *bool
-	var startYear int
-
-	// Determine if source or destination needs token update
-	if config.DestinationType == "gdrive" || config.DestinationType == "gphotos" {
-		configType = config.DestinationType
-		section = "dest"
-		clientID = config.DestClientID
-		clientSecret = config.DestClientSecret
-		readOnly = config.DestReadOnly
-		startYear = config.DestStartYear
-		includeArchived = config.DestIncludeArchived
-	} else if config.SourceType == "gdrive" || config.SourceType == "gphotos" {
-		configType = config.SourceType
-		section = "source"
-		clientID = config.SourceClientID
-		clientSecret = config.SourceClientSecret
-		readOnly = config.SourceReadOnly
-		startYear = config.SourceStartYear
-		includeArchived = config.SourceIncludeArchived
-	} else {
-		return fmt.Errorf("config is not for Google Drive or Google Photos")
-	}
-
-	contentBytes, err := os.ReadFile(configPath)
-	if err != nil && !os.IsNotExist(err) { // Allow file not existing yet
-		return fmt.Errorf("failed to read config file: %v", err)
-	}
-	content := string(contentBytes)
-
-	var sectionContent string
-	sectionHeader := fmt.Sprintf("[%s_%d]", section, config.ID)
-
-	if configType == "gdrive" {
-		sectionContent = sectionHeader + "\ntype = drive\n"
-		if clientID != "" {
-			sectionContent += fmt.Sprintf("client_id = %s\n", clientID)
-		}
-		if clientSecret != "" {
-			sectionContent += fmt.Sprintf("client_secret = %s\n", clientSecret)
-		}
-		sectionContent += fmt.Sprintf("token = %s\n", token)
-		if section == "source" && config.SourceTeamDrive != "" {
-			sectionContent += fmt.Sprintf("team_drive = %s\n", config.SourceTeamDrive)
-		}
-		if section == "dest" && config.DestTeamDrive != "" {
-			sectionContent += fmt.Sprintf("team_drive = %s\n", config.DestTeamDrive)
-		}
-		if section == "dest" && config.DestDriveID != "" {
-			sectionContent += fmt.Sprintf("root_folder_id = %s\n", config.DestDriveID)
-		} // Use DestDriveID for root_folder_id
-	} else if configType == "gphotos" {
-		sectionContent = sectionHeader + "\ntype = google photos\n"
-		if clientID != "" {
-			sectionContent += fmt.Sprintf("client_id = %s\n", clientID)
-		}
-		if clientSecret != "" {
-			sectionContent += fmt.Sprintf("client_secret = %s\n", clientSecret)
-		}
-		sectionContent += fmt.Sprintf("token = %s\n", token)
-		if readOnly != nil && *readOnly {
-			sectionContent += "read_only = true\n"
-		}
-		if startYear > 0 {
-			sectionContent += fmt.Sprintf("start_year = %d\n", startYear)
-		}
-		if includeArchived != nil && *includeArchived {
-			sectionContent += "include_archived = true\n"
-		}
-	}
-
-	// Replace or append logic
-	sectionPattern := regexp.MustCompile(fmt.Sprintf(`(?m)^%s[^\[]*`, regexp.QuoteMeta(sectionHeader))) // Match section start to next section or EOF
-	if sectionPattern.MatchString(content) {
-		content = sectionPattern.ReplaceAllString(content, sectionContent)
-	} else {
-		if content != "" && !strings.HasSuffix(content, "\n\n") { // Ensure separation
-			if !strings.HasSuffix(content, "\n") {
-				content += "\n"
-			}
-			content += "\n"
-		}
-		content += sectionContent
-	}
-
-	// Ensure directory exists
-	configDir := filepath.Dir(configPath)
-	if err := os.MkdirAll(configDir, 0755); err != nil {
-		return fmt.Errorf("failed to create config directory: %v", err)
-	}
-
-	// Write the updated config file
-	if err := os.WriteFile(configPath, []byte(content), 0600); err != nil { // Use 0600 for sensitive files
-		return fmt.Errorf("failed to write updated config file: %v", err)
-	}
-
-	// Update the authentication status in DB
-	authenticated := true
 	if config.DestinationType == "gdrive" || config.DestinationType == "gphotos" {
-		config.SetGoogleAuthenticated(authenticated)
+		return db.StoreOAuthToken(config, "dest", token)
 	} else if config.SourceType == "gdrive" || config.SourceType == "gphotos" {
-		config.SetGoogleAuthenticated(authenticated)
+		return db.StoreOAuthToken(config, "source", token)
 	}
-	// Persist the change (assuming UpdateTransferConfig saves the whole object)
-	if err := db.UpdateTransferConfig(config); err != nil {
-		return fmt.Errorf("failed to update config authentication status: %v", err)
-	}
-
-	return nil
+	return fmt.Errorf("config is not for Google Drive or Google Photos")
 }
 
 // GetGDriveCredentialsFromConfig extracts Google Drive client ID and secret from an existing rclone config file
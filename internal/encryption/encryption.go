@@ -0,0 +1,419 @@
+// Package encryption provides PGP and age encryption, decryption and signing of files.
+package encryption
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"filippo.io/age"
+	agearmor "filippo.io/age/armor"
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+)
+
+// Supported key types
+const (
+	TypePGP = "pgp"
+	TypeAge = "age"
+)
+
+// Operations recorded for processed files
+const (
+	OperationEncrypted       = "encrypted"
+	OperationEncryptedSigned = "encrypted_signed"
+	OperationDecrypted       = "decrypted"
+)
+
+// Signature results recorded for processed files
+const (
+	SignatureSigned        = "signed"         // Outgoing file was signed
+	SignatureValid         = "valid"          // Incoming signature verified successfully
+	SignatureInvalid       = "invalid"        // Incoming signature did not verify
+	SignatureUnknownSigner = "unknown_signer" // Incoming file was signed by a key that is not trusted
+	SignatureUnsigned      = "unsigned"       // Incoming file was not signed
+)
+
+// KeyInfo describes a key parsed from user supplied key material
+type KeyInfo struct {
+	Type          string
+	Fingerprint   string
+	PublicKey     string // Armored PGP public key or age recipient
+	HasPrivateKey bool
+}
+
+// Key holds the plaintext key material used for an operation
+type Key struct {
+	Type        string
+	Fingerprint string
+	PublicKey   string // Armored PGP public key or age recipient
+	PrivateKey  string // Armored PGP private key or age identity
+	Passphrase  string // Passphrase of a protected PGP private key
+}
+
+// Result describes the outcome of an encryption or decryption
+type Result struct {
+	Operation       string
+	Fingerprint     string
+	SignatureStatus string
+}
+
+// Extension returns the file extension added when encrypting with a key type
+func Extension(keyType string) string {
+	switch keyType {
+	case TypePGP:
+		return ".pgp"
+	case TypeAge:
+		return ".age"
+	default:
+		return ""
+	}
+}
+
+// TrimExtension removes a known encrypted file extension from a file name and
+// reports whether one was found
+func TrimExtension(fileName string) (string, bool) {
+	lower := strings.ToLower(fileName)
+	for _, ext := range []string{".pgp", ".gpg", ".asc", ".age"} {
+		if strings.HasSuffix(lower, ext) {
+			return fileName[:len(fileName)-len(ext)], true
+		}
+	}
+	return fileName, false
+}
+
+// ParseKey validates key material and returns its public part and fingerprint.
+// Private PGP keys protected by a passphrase are only accepted when the
+// passphrase unlocks them.
+func ParseKey(keyType, material, passphrase string) (*KeyInfo, error) {
+	material = strings.TrimSpace(material)
+	if material == "" {
+		return nil, fmt.Errorf("key material is empty")
+	}
+
+	switch keyType {
+	case TypePGP:
+		entities, err := openpgp.ReadArmoredKeyRing(strings.NewReader(material))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read PGP key: %v", err)
+		}
+		if len(entities) != 1 {
+			return nil, fmt.Errorf("expected a single PGP key, found %d", len(entities))
+		}
+		entity := entities[0]
+
+		info := &KeyInfo{
+			Type:          TypePGP,
+			Fingerprint:   pgpFingerprint(entity),
+			HasPrivateKey: entity.PrivateKey != nil,
+		}
+		if info.HasPrivateKey {
+			if err := unlockEntity(entity, passphrase); err != nil {
+				return nil, err
+			}
+		}
+
+		var buf bytes.Buffer
+		w, err := armor.Encode(&buf, openpgp.PublicKeyType, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode PGP public key: %v", err)
+		}
+		if err := entity.Serialize(w); err != nil {
+			return nil, fmt.Errorf("failed to serialize PGP public key: %v", err)
+		}
+		if err := w.Close(); err != nil {
+			return nil, fmt.Errorf("failed to encode PGP public key: %v", err)
+		}
+		info.PublicKey = buf.String()
+		return info, nil
+
+	case TypeAge:
+		if strings.HasPrefix(strings.ToUpper(material), "AGE-SECRET-KEY-") || strings.Contains(material, "\nAGE-SECRET-KEY-") {
+			identity, err := parseAgeIdentity(material)
+			if err != nil {
+				return nil, err
+			}
+			recipient := identity.Recipient().String()
+			return &KeyInfo{Type: TypeAge, Fingerprint: recipient, PublicKey: recipient, HasPrivateKey: true}, nil
+		}
+		recipient, err := age.ParseX25519Recipient(material)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read age recipient: %v", err)
+		}
+		return &KeyInfo{Type: TypeAge, Fingerprint: recipient.String(), PublicKey: recipient.String()}, nil
+
+	default:
+		return nil, fmt.Errorf("unsupported key type: %s", keyType)
+	}
+}
+
+// EncryptFile encrypts srcPath to the recipients and writes the result to dstPath.
+// When signer is set the file is also signed (PGP only).
+func EncryptFile(srcPath, dstPath string, recipients []Key, signer *Key) (*Result, error) {
+	if len(recipients) == 0 {
+		return nil, fmt.Errorf("no recipient keys configured")
+	}
+	keyType := recipients[0].Type
+	for _, key := range recipients {
+		if key.Type != keyType {
+			return nil, fmt.Errorf("recipient keys must all be of the same type")
+		}
+	}
+
+	in, err := os.Open(srcPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file for encryption: %v", err)
+	}
+	defer in.Close()
+
+	out, err := os.Create(dstPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create encrypted file: %v", err)
+	}
+	defer out.Close()
+
+	result := &Result{Operation: OperationEncrypted, Fingerprint: joinFingerprints(recipients)}
+
+	var writer io.WriteCloser
+	switch keyType {
+	case TypePGP:
+		var to openpgp.EntityList
+		for _, key := range recipients {
+			entity, err := readPGPEntity(key.PublicKey)
+			if err != nil {
+				return nil, err
+			}
+			to = append(to, entity)
+		}
+
+		var signedBy *openpgp.Entity
+		if signer != nil {
+			if signer.Type != TypePGP {
+				return nil, fmt.Errorf("signing requires a PGP private key")
+			}
+			signedBy, err = readPGPEntity(signer.PrivateKey)
+			if err != nil {
+				return nil, err
+			}
+			if err := unlockEntity(signedBy, signer.Passphrase); err != nil {
+				return nil, err
+			}
+			result.Operation = OperationEncryptedSigned
+			result.SignatureStatus = SignatureSigned
+		}
+
+		hints := &openpgp.FileHints{IsBinary: true, FileName: filepath.Base(srcPath)}
+		writer, err = openpgp.Encrypt(out, to, signedBy, hints, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to start PGP encryption: %v", err)
+		}
+
+	case TypeAge:
+		if signer != nil {
+			return nil, fmt.Errorf("age does not support signing")
+		}
+		var to []age.Recipient
+		for _, key := range recipients {
+			recipient, err := age.ParseX25519Recipient(strings.TrimSpace(key.PublicKey))
+			if err != nil {
+				return nil, fmt.Errorf("failed to read age recipient: %v", err)
+			}
+			to = append(to, recipient)
+		}
+		writer, err = age.Encrypt(out, to...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to start age encryption: %v", err)
+		}
+
+	default:
+		return nil, fmt.Errorf("unsupported key type: %s", keyType)
+	}
+
+	if _, err := io.Copy(writer, in); err != nil {
+		writer.Close()
+		return nil, fmt.Errorf("failed to encrypt file: %v", err)
+	}
+	if err := writer.Close(); err != nil {
+		return nil, fmt.Errorf("failed to finish encrypted file: %v", err)
+	}
+
+	return result, nil
+}
+
+// DecryptFile decrypts srcPath with one of the identities and writes the plaintext to
+// dstPath. Signatures of PGP messages are checked against the verifier keys.
+func DecryptFile(srcPath, dstPath string, identities []Key, verifiers []Key) (*Result, error) {
+	if len(identities) == 0 {
+		return nil, fmt.Errorf("no decryption keys configured")
+	}
+	keyType := identities[0].Type
+
+	in, err := os.Open(srcPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file for decryption: %v", err)
+	}
+	defer in.Close()
+
+	out, err := os.Create(dstPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create decrypted file: %v", err)
+	}
+	defer out.Close()
+
+	// Armored input is detected by its header so both encodings are accepted
+	reader := bufio.NewReader(in)
+	peek, _ := reader.Peek(64)
+	armored := bytes.HasPrefix(bytes.TrimSpace(peek), []byte("-----BEGIN"))
+
+	result := &Result{Operation: OperationDecrypted}
+
+	switch keyType {
+	case TypePGP:
+		var keyring openpgp.EntityList
+		for _, key := range identities {
+			entity, err := readPGPEntity(key.PrivateKey)
+			if err != nil {
+				return nil, err
+			}
+			if err := unlockEntity(entity, key.Passphrase); err != nil {
+				return nil, err
+			}
+			keyring = append(keyring, entity)
+		}
+		// Signatures are only trusted when made by a verifier key, the decryption keys are in
+		// the keyring to decrypt with but must not vouch for the sender
+		var trusted openpgp.EntityList
+		for _, key := range verifiers {
+			entity, err := readPGPEntity(key.PublicKey)
+			if err != nil {
+				return nil, err
+			}
+			keyring = append(keyring, entity)
+			trusted = append(trusted, entity)
+		}
+
+		var src io.Reader = reader
+		if armored {
+			block, err := armor.Decode(reader)
+			if err != nil {
+				return nil, fmt.Errorf("failed to decode armored PGP message: %v", err)
+			}
+			src = block.Body
+		}
+
+		md, err := openpgp.ReadMessage(src, keyring, nil, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt PGP message: %v", err)
+		}
+		if _, err := io.Copy(out, md.UnverifiedBody); err != nil {
+			return nil, fmt.Errorf("failed to decrypt file: %v", err)
+		}
+
+		if md.DecryptedWith.Entity != nil {
+			result.Fingerprint = pgpFingerprint(md.DecryptedWith.Entity)
+		}
+		switch {
+		case !md.IsSigned:
+			result.SignatureStatus = SignatureUnsigned
+		case md.SignedBy == nil || len(trusted.KeysById(md.SignedByKeyId)) == 0:
+			result.SignatureStatus = SignatureUnknownSigner
+		case md.SignatureError != nil:
+			result.SignatureStatus = SignatureInvalid
+		default:
+			result.SignatureStatus = SignatureValid
+		}
+
+	case TypeAge:
+		var ids []age.Identity
+		for _, key := range identities {
+			identity, err := parseAgeIdentity(key.PrivateKey)
+			if err != nil {
+				return nil, err
+			}
+			ids = append(ids, identity)
+		}
+
+		var src io.Reader = reader
+		if armored {
+			src = agearmor.NewReader(reader)
+		}
+
+		plaintext, err := age.Decrypt(src, ids...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt age file: %v", err)
+		}
+		if _, err := io.Copy(out, plaintext); err != nil {
+			return nil, fmt.Errorf("failed to decrypt file: %v", err)
+		}
+		// age does not report which identity matched
+		result.Fingerprint = joinFingerprints(identities)
+
+	default:
+		return nil, fmt.Errorf("unsupported key type: %s", keyType)
+	}
+
+	return result, nil
+}
+
+// readPGPEntity reads a single armored PGP key
+func readPGPEntity(material string) (*openpgp.Entity, error) {
+	entities, err := openpgp.ReadArmoredKeyRing(strings.NewReader(material))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read PGP key: %v", err)
+	}
+	if len(entities) == 0 {
+		return nil, fmt.Errorf("no PGP key found")
+	}
+	return entities[0], nil
+}
+
+// unlockEntity decrypts the private keys of an entity when they are passphrase protected
+func unlockEntity(entity *openpgp.Entity, passphrase string) error {
+	if entity.PrivateKey == nil {
+		return fmt.Errorf("PGP key %s has no private key", pgpFingerprint(entity))
+	}
+	if !entity.PrivateKey.Encrypted {
+		return nil
+	}
+	if passphrase == "" {
+		return fmt.Errorf("PGP key %s is protected by a passphrase", pgpFingerprint(entity))
+	}
+	if err := entity.DecryptPrivateKeys([]byte(passphrase)); err != nil {
+		return fmt.Errorf("failed to unlock PGP key %s: %v", pgpFingerprint(entity), err)
+	}
+	return nil
+}
+
+// parseAgeIdentity reads a single X25519 age identity, ignoring comment lines
+func parseAgeIdentity(material string) (*age.X25519Identity, error) {
+	for _, line := range strings.Split(material, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		identity, err := age.ParseX25519Identity(line)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read age identity: %v", err)
+		}
+		return identity, nil
+	}
+	return nil, fmt.Errorf("no age identity found")
+}
+
+// pgpFingerprint returns the upper case hex fingerprint of the primary key
+func pgpFingerprint(entity *openpgp.Entity) string {
+	return fmt.Sprintf("%X", entity.PrimaryKey.Fingerprint)
+}
+
+// joinFingerprints returns the fingerprints of the keys as a comma separated list
+func joinFingerprints(keys []Key) string {
+	fingerprints := make([]string, 0, len(keys))
+	for _, key := range keys {
+		fingerprints = append(fingerprints, key.Fingerprint)
+	}
+	return strings.Join(fingerprints, ", ")
+}
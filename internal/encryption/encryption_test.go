@@ -0,0 +1,236 @@
+package encryption
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"filippo.io/age"
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+)
+
+// generatePGPKey creates a PGP key pair and returns it as a Key with armored material
+func generatePGPKey(t *testing.T, name string) Key {
+	t.Helper()
+	entity, err := openpgp.NewEntity(name, "", name+"@example.com", nil)
+	if err != nil {
+		t.Fatalf("Failed to generate PGP key: %v", err)
+	}
+
+	var private bytes.Buffer
+	w, err := armor.Encode(&private, openpgp.PrivateKeyType, nil)
+	if err != nil {
+		t.Fatalf("Failed to armor private key: %v", err)
+	}
+	if err := entity.SerializePrivate(w, nil); err != nil {
+		t.Fatalf("Failed to serialize private key: %v", err)
+	}
+	w.Close()
+
+	info, err := ParseKey(TypePGP, private.String(), "")
+	if err != nil {
+		t.Fatalf("ParseKey() error = %v", err)
+	}
+	if !info.HasPrivateKey {
+		t.Fatalf("ParseKey() did not detect private key")
+	}
+
+	return Key{Type: TypePGP, Fingerprint: info.Fingerprint, PublicKey: info.PublicKey, PrivateKey: private.String()}
+}
+
+// generateAgeKey creates an age identity and returns it as a Key
+func generateAgeKey(t *testing.T) Key {
+	t.Helper()
+	identity, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatalf("Failed to generate age identity: %v", err)
+	}
+
+	info, err := ParseKey(TypeAge, identity.String(), "")
+	if err != nil {
+		t.Fatalf("ParseKey() error = %v", err)
+	}
+	if info.PublicKey != identity.Recipient().String() {
+		t.Errorf("ParseKey() public key = %q, want %q", info.PublicKey, identity.Recipient().String())
+	}
+
+	return Key{Type: TypeAge, Fingerprint: info.Fingerprint, PublicKey: info.PublicKey, PrivateKey: identity.String()}
+}
+
+func writeTestFile(t *testing.T, dir string, content []byte) string {
+	t.Helper()
+	path := filepath.Join(dir, "data.csv")
+	if err := os.WriteFile(path, content, 0600); err != nil {
+		t.Fatalf("Failed to write test file: %v", err)
+	}
+	return path
+}
+
+func TestPGPEncryptDecryptWithSignature(t *testing.T) {
+	dir := t.TempDir()
+	content := []byte("account,amount\n1234,100.00\n")
+	srcPath := writeTestFile(t, dir, content)
+
+	recipient := generatePGPKey(t, "bank")
+	signer := generatePGPKey(t, "gomft")
+
+	encrypted := filepath.Join(dir, "data.csv.pgp")
+	result, err := EncryptFile(srcPath, encrypted, []Key{{Type: TypePGP, Fingerprint: recipient.Fingerprint, PublicKey: recipient.PublicKey}}, &signer)
+	if err != nil {
+		t.Fatalf("EncryptFile() error = %v", err)
+	}
+	if result.Operation != OperationEncryptedSigned || result.SignatureStatus != SignatureSigned {
+		t.Errorf("EncryptFile() result = %+v, want signed encryption", result)
+	}
+	if result.Fingerprint != recipient.Fingerprint {
+		t.Errorf("EncryptFile() fingerprint = %q, want %q", result.Fingerprint, recipient.Fingerprint)
+	}
+
+	tests := []struct {
+		name       string
+		verifiers  []Key
+		wantStatus string
+	}{
+		{"Trusted signer", []Key{{Type: TypePGP, PublicKey: signer.PublicKey}}, SignatureValid},
+		{"Unknown signer", nil, SignatureUnknownSigner},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			decrypted := filepath.Join(t.TempDir(), "data.csv")
+			result, err := DecryptFile(encrypted, decrypted, []Key{recipient}, tt.verifiers)
+			if err != nil {
+				t.Fatalf("DecryptFile() error = %v", err)
+			}
+			if result.SignatureStatus != tt.wantStatus {
+				t.Errorf("DecryptFile() signature = %q, want %q", result.SignatureStatus, tt.wantStatus)
+			}
+			if result.Fingerprint != recipient.Fingerprint {
+				t.Errorf("DecryptFile() fingerprint = %q, want %q", result.Fingerprint, recipient.Fingerprint)
+			}
+			got, _ := os.ReadFile(decrypted)
+			if !bytes.Equal(got, content) {
+				t.Errorf("Decrypted content does not match original")
+			}
+		})
+	}
+}
+
+func TestPGPDecryptSignedWithDecryptionKey(t *testing.T) {
+	dir := t.TempDir()
+	srcPath := writeTestFile(t, dir, []byte("forged"))
+	recipient := generatePGPKey(t, "gomft")
+	partner := generatePGPKey(t, "bank")
+
+	// Anyone holding the decryption key signs with it, it does not identify the sender
+	encrypted := filepath.Join(dir, "data.csv.pgp")
+	if _, err := EncryptFile(srcPath, encrypted, []Key{recipient}, &recipient); err != nil {
+		t.Fatalf("EncryptFile() error = %v", err)
+	}
+
+	result, err := DecryptFile(encrypted, filepath.Join(dir, "out.csv"), []Key{recipient}, []Key{{Type: TypePGP, PublicKey: partner.PublicKey}})
+	if err != nil {
+		t.Fatalf("DecryptFile() error = %v", err)
+	}
+	if result.SignatureStatus != SignatureUnknownSigner {
+		t.Errorf("DecryptFile() signature = %q, want %q", result.SignatureStatus, SignatureUnknownSigner)
+	}
+}
+
+func TestPGPDecryptUnsigned(t *testing.T) {
+	dir := t.TempDir()
+	srcPath := writeTestFile(t, dir, []byte("unsigned"))
+	recipient := generatePGPKey(t, "bank")
+
+	encrypted := filepath.Join(dir, "data.csv.pgp")
+	if _, err := EncryptFile(srcPath, encrypted, []Key{recipient}, nil); err != nil {
+		t.Fatalf("EncryptFile() error = %v", err)
+	}
+
+	result, err := DecryptFile(encrypted, filepath.Join(dir, "out.csv"), []Key{recipient}, nil)
+	if err != nil {
+		t.Fatalf("DecryptFile() error = %v", err)
+	}
+	if result.SignatureStatus != SignatureUnsigned {
+		t.Errorf("DecryptFile() signature = %q, want %q", result.SignatureStatus, SignatureUnsigned)
+	}
+}
+
+func TestAgeEncryptDecrypt(t *testing.T) {
+	dir := t.TempDir()
+	content := []byte("age encrypted content")
+	srcPath := writeTestFile(t, dir, content)
+	key := generateAgeKey(t)
+
+	encrypted := filepath.Join(dir, "data.csv.age")
+	if _, err := EncryptFile(srcPath, encrypted, []Key{{Type: TypeAge, Fingerprint: key.Fingerprint, PublicKey: key.PublicKey}}, nil); err != nil {
+		t.Fatalf("EncryptFile() error = %v", err)
+	}
+
+	decrypted := filepath.Join(dir, "out.csv")
+	result, err := DecryptFile(encrypted, decrypted, []Key{key}, nil)
+	if err != nil {
+		t.Fatalf("DecryptFile() error = %v", err)
+	}
+	if result.Operation != OperationDecrypted || result.Fingerprint != key.Fingerprint {
+		t.Errorf("DecryptFile() result = %+v", result)
+	}
+	got, _ := os.ReadFile(decrypted)
+	if !bytes.Equal(got, content) {
+		t.Errorf("Decrypted content does not match original")
+	}
+
+	if _, err := EncryptFile(srcPath, encrypted, []Key{key}, &key); err == nil {
+		t.Error("EncryptFile() expected error when signing with age")
+	}
+}
+
+func TestDecryptWithWrongKey(t *testing.T) {
+	dir := t.TempDir()
+	srcPath := writeTestFile(t, dir, []byte("secret"))
+	key := generateAgeKey(t)
+	other := generateAgeKey(t)
+
+	encrypted := filepath.Join(dir, "data.csv.age")
+	if _, err := EncryptFile(srcPath, encrypted, []Key{key}, nil); err != nil {
+		t.Fatalf("EncryptFile() error = %v", err)
+	}
+	if _, err := DecryptFile(encrypted, filepath.Join(dir, "out.csv"), []Key{other}, nil); err == nil {
+		t.Error("DecryptFile() expected error with wrong identity")
+	}
+}
+
+func TestTrimExtension(t *testing.T) {
+	tests := []struct {
+		fileName  string
+		want      string
+		wantFound bool
+	}{
+		{"report.csv.pgp", "report.csv", true},
+		{"report.csv.GPG", "report.csv", true},
+		{"report.csv.asc", "report.csv", true},
+		{"report.csv.age", "report.csv", true},
+		{"report.csv", "report.csv", false},
+	}
+
+	for _, tt := range tests {
+		got, found := TrimExtension(tt.fileName)
+		if got != tt.want || found != tt.wantFound {
+			t.Errorf("TrimExtension(%q) = (%q, %v), want (%q, %v)", tt.fileName, got, found, tt.want, tt.wantFound)
+		}
+	}
+}
+
+func TestParseKeyInvalid(t *testing.T) {
+	if _, err := ParseKey(TypePGP, "not a key", ""); err == nil {
+		t.Error("ParseKey() expected error for invalid PGP key")
+	}
+	if _, err := ParseKey(TypeAge, "age1invalid", ""); err == nil {
+		t.Error("ParseKey() expected error for invalid age recipient")
+	}
+	if _, err := ParseKey("rsa", "key", ""); err == nil {
+		t.Error("ParseKey() expected error for unsupported key type")
+	}
+}
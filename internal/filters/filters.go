@@ -0,0 +1,421 @@
+// Package filters translates the include/exclude, size and age filters of a
+// transfer config into rclone filter rules and flags.
+package filters
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/starfleetcptn/gomft/internal/db"
+)
+
+// Rule actions
+const (
+	ActionInclude = "include"
+	ActionExclude = "exclude"
+)
+
+// Rule pattern types
+const (
+	TypeGlob  = "glob"
+	TypeRegex = "regex"
+)
+
+// Rule is a single include or exclude rule. Rules are evaluated in order and the
+// first matching rule decides whether a file is transferred.
+type Rule struct {
+	Action  string `json:"action"`
+	Type    string `json:"type"`
+	Pattern string `json:"pattern"`
+}
+
+// Filter is the complete file filter of a transfer config
+type Filter struct {
+	Rules   []Rule
+	MinSize string // rclone size, e.g. 100k or 1.5G
+	MaxSize string
+	MinAge  string // rclone duration, e.g. 30m or 2d
+	MaxAge  string
+}
+
+// ParseRules parses the JSON encoded rules stored on a transfer config
+func ParseRules(rulesJSON string) ([]Rule, error) {
+	if strings.TrimSpace(rulesJSON) == "" {
+		return nil, nil
+	}
+	var rules []Rule
+	if err := json.Unmarshal([]byte(rulesJSON), &rules); err != nil {
+		return nil, fmt.Errorf("invalid filter rules: %v", err)
+	}
+	return rules, nil
+}
+
+// FromConfig builds the filter of a transfer config. A legacy FilePattern is
+// used as an include glob when no structured rules are configured.
+func FromConfig(config *db.TransferConfig) (*Filter, error) {
+	rules, err := ParseRules(config.FilterRules)
+	if err != nil {
+		return nil, err
+	}
+	if len(rules) == 0 && config.FilePattern != "" && config.FilePattern != "*" {
+		for _, pattern := range strings.Split(config.FilePattern, ",") {
+			if pattern = strings.TrimSpace(pattern); pattern != "" {
+				rules = append(rules, Rule{Action: ActionInclude, Type: TypeGlob, Pattern: pattern})
+			}
+		}
+	}
+
+	return &Filter{
+		Rules:   rules,
+		MinSize: strings.TrimSpace(config.FilterMinSize),
+		MaxSize: strings.TrimSpace(config.FilterMaxSize),
+		MinAge:  strings.TrimSpace(config.FilterMinAge),
+		MaxAge:  strings.TrimSpace(config.FilterMaxAge),
+	}, nil
+}
+
+// IsEmpty reports whether the filter lets every file through
+func (f *Filter) IsEmpty() bool {
+	return len(f.Rules) == 0 && f.MinSize == "" && f.MaxSize == "" && f.MinAge == "" && f.MaxAge == ""
+}
+
+// Validate checks the rules, sizes and ages of the filter
+func (f *Filter) Validate() error {
+	for i, rule := range f.Rules {
+		if rule.Action != ActionInclude && rule.Action != ActionExclude {
+			return fmt.Errorf("rule %d: unsupported action %q", i+1, rule.Action)
+		}
+		if strings.TrimSpace(rule.Pattern) == "" {
+			return fmt.Errorf("rule %d: pattern is empty", i+1)
+		}
+		if _, err := rule.regexp(); err != nil {
+			return fmt.Errorf("rule %d: %v", i+1, err)
+		}
+	}
+
+	minSize, err := parseSizeOrZero(f.MinSize)
+	if err != nil {
+		return fmt.Errorf("minimum size: %v", err)
+	}
+	maxSize, err := parseSizeOrZero(f.MaxSize)
+	if err != nil {
+		return fmt.Errorf("maximum size: %v", err)
+	}
+	if maxSize > 0 && minSize > maxSize {
+		return fmt.Errorf("minimum size is larger than maximum size")
+	}
+
+	minAge, err := parseAgeOrZero(f.MinAge)
+	if err != nil {
+		return fmt.Errorf("minimum age: %v", err)
+	}
+	maxAge, err := parseAgeOrZero(f.MaxAge)
+	if err != nil {
+		return fmt.Errorf("maximum age: %v", err)
+	}
+	if maxAge > 0 && minAge > maxAge {
+		return fmt.Errorf("minimum age is larger than maximum age")
+	}
+	return nil
+}
+
+// RulesFileContent returns the rules in rclone filter file syntax
+func (f *Filter) RulesFileContent() string {
+	var b strings.Builder
+	hasInclude := false
+	for _, rule := range f.Rules {
+		prefix := "-"
+		if rule.Action == ActionInclude {
+			prefix = "+"
+			hasInclude = true
+		}
+		pattern := rule.Pattern
+		if rule.Type == TypeRegex {
+			pattern = "{{" + pattern + "}}"
+		}
+		fmt.Fprintf(&b, "%s %s\n", prefix, pattern)
+	}
+	// Only the included files are transferred once an include rule exists
+	if hasInclude {
+		b.WriteString("- **\n")
+	}
+	return b.String()
+}
+
+// RaiseMinAge raises the minimum age of a validated filter to age when it is lower
+func (f *Filter) RaiseMinAge(age time.Duration) {
+	if age <= 0 {
+		return
+	}
+	if current, err := parseAgeOrZero(f.MinAge); err == nil && current >= age {
+		return
+	}
+	f.MinAge = fmt.Sprintf("%ds", int64(age/time.Second))
+}
+
+// ExcludeDir excludes a directory below the root of the remote and everything in it,
+// ahead of the rules of a validated filter
+func (f *Filter) ExcludeDir(dir string) {
+	dir = strings.Trim(dir, "/")
+	if dir == "" {
+		return
+	}
+	rule := Rule{Action: ActionExclude, Type: TypeGlob, Pattern: "/" + escapeGlob(dir) + "/**"}
+	f.Rules = append([]Rule{rule}, f.Rules...)
+}
+
+// escapeGlob escapes the characters of a path that have a meaning in an rclone glob
+func escapeGlob(path string) string {
+	var b strings.Builder
+	for _, c := range path {
+		if strings.ContainsRune(`\*?[]{}`, c) {
+			b.WriteRune('\\')
+		}
+		b.WriteRune(c)
+	}
+	return b.String()
+}
+
+// Args writes the rules to a temporary filter file and returns the rclone flags
+// for the filter. The returned cleanup function removes the filter file.
+func (f *Filter) Args() ([]string, func(), error) {
+	var args []string
+	cleanup := func() {}
+
+	if len(f.Rules) > 0 {
+		tmpFile, err := os.CreateTemp("", "rclone-filter-*.txt")
+		if err != nil {
+			return nil, cleanup, fmt.Errorf("failed to create temporary filter file: %v", err)
+		}
+		defer tmpFile.Close()
+		if _, err := tmpFile.WriteString(f.RulesFileContent()); err != nil {
+			os.Remove(tmpFile.Name())
+			return nil, cleanup, fmt.Errorf("failed to write to filter file: %v", err)
+		}
+		cleanup = func() { os.Remove(tmpFile.Name()) }
+		args = append(args, "--filter-from", tmpFile.Name())
+	}
+
+	if f.MinSize != "" {
+		args = append(args, "--min-size", f.MinSize)
+	}
+	if f.MaxSize != "" {
+		args = append(args, "--max-size", f.MaxSize)
+	}
+	if f.MinAge != "" {
+		args = append(args, "--min-age", f.MinAge)
+	}
+	if f.MaxAge != "" {
+		args = append(args, "--max-age", f.MaxAge)
+	}
+	return args, cleanup, nil
+}
+
+// Match reports whether a file passes the filter, with the reason when it does not.
+// The filter must have been validated.
+func (f *Filter) Match(path string, size int64, modTime time.Time, now time.Time) (bool, string) {
+	path = strings.TrimPrefix(path, "/")
+
+	hasInclude := false
+	matched := false
+	for i, rule := range f.Rules {
+		if rule.Action == ActionInclude {
+			hasInclude = true
+		}
+		re, err := rule.regexp()
+		if err != nil || !re.MatchString(path) {
+			continue
+		}
+		if rule.Action == ActionExclude {
+			return false, fmt.Sprintf("excluded by rule %d (%s)", i+1, rule.Pattern)
+		}
+		matched = true
+		break
+	}
+	if !matched && hasInclude {
+		return false, "not matched by any include rule"
+	}
+
+	if minSize, _ := parseSizeOrZero(f.MinSize); minSize > 0 && size >= 0 && size < minSize {
+		return false, fmt.Sprintf("smaller than %s", f.MinSize)
+	}
+	if maxSize, _ := parseSizeOrZero(f.MaxSize); maxSize > 0 && size > maxSize {
+		return false, fmt.Sprintf("larger than %s", f.MaxSize)
+	}
+	if !modTime.IsZero() {
+		age := now.Sub(modTime)
+		if minAge, _ := parseAgeOrZero(f.MinAge); minAge > 0 && age < minAge {
+			return false, fmt.Sprintf("newer than %s", f.MinAge)
+		}
+		if maxAge, _ := parseAgeOrZero(f.MaxAge); maxAge > 0 && age > maxAge {
+			return false, fmt.Sprintf("older than %s", f.MaxAge)
+		}
+	}
+	return true, ""
+}
+
+// regexp compiles the rule into a regular expression matching file paths the way rclone does
+func (r Rule) regexp() (*regexp.Regexp, error) {
+	pattern := r.Pattern
+	switch r.Type {
+	case TypeRegex:
+		if strings.Contains(pattern, "}}") {
+			return nil, fmt.Errorf("regular expression must not contain }}")
+		}
+		if _, err := regexp.Compile(pattern); err != nil {
+			return nil, fmt.Errorf("invalid regular expression: %v", err)
+		}
+		return regexp.Compile("(^|/)(" + pattern + ")$")
+	case TypeGlob:
+		return globToRegexp(pattern)
+	default:
+		return nil, fmt.Errorf("unsupported pattern type %q", r.Type)
+	}
+}
+
+// globToRegexp converts an rclone glob into a regular expression. Patterns starting
+// with / are anchored at the root of the remote, others match the end of the path.
+func globToRegexp(glob string) (*regexp.Regexp, error) {
+	var re strings.Builder
+	if strings.HasPrefix(glob, "/") {
+		re.WriteString("^")
+		glob = glob[1:]
+	} else {
+		re.WriteString("(^|/)")
+	}
+
+	inBraces := false
+	for i := 0; i < len(glob); i++ {
+		c := glob[i]
+		switch {
+		case c == '\\' && i+1 < len(glob):
+			i++
+			re.WriteString(regexp.QuoteMeta(string(glob[i])))
+		case c == '*' && i+1 < len(glob) && glob[i+1] == '*':
+			i++
+			re.WriteString(".*")
+		case c == '*':
+			re.WriteString("[^/]*")
+		case c == '?':
+			re.WriteString("[^/]")
+		case c == '[':
+			end := strings.IndexByte(glob[i+1:], ']')
+			if end < 0 {
+				return nil, fmt.Errorf("invalid glob %q: unterminated [", glob)
+			}
+			re.WriteString(glob[i : i+end+2])
+			i += end + 1
+		case c == '{' && i+1 < len(glob) && glob[i+1] == '{':
+			end := strings.Index(glob[i+2:], "}}")
+			if end < 0 {
+				return nil, fmt.Errorf("invalid glob %q: unterminated {{", glob)
+			}
+			re.WriteString("(" + glob[i+2:i+2+end] + ")")
+			i += end + 3
+		case c == '{' && !inBraces:
+			inBraces = true
+			re.WriteString("(")
+		case c == ',' && inBraces:
+			re.WriteString("|")
+		case c == '}' && inBraces:
+			inBraces = false
+			re.WriteString(")")
+		default:
+			re.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	if inBraces {
+		return nil, fmt.Errorf("invalid glob %q: unterminated {", glob)
+	}
+	re.WriteString("$")
+
+	compiled, err := regexp.Compile(re.String())
+	if err != nil {
+		return nil, fmt.Errorf("invalid glob %q: %v", glob, err)
+	}
+	return compiled, nil
+}
+
+var sizeRegex = regexp.MustCompile(`^(\d+(?:\.\d+)?)\s*([bBkKmMgGtTpP]?)(?:i?[bB])?$`)
+
+// ParseSize parses an rclone size such as 512, 100k or 1.5G. Sizes without a
+// suffix are in KiB, like rclone's --min-size and --max-size.
+func ParseSize(value string) (int64, error) {
+	matches := sizeRegex.FindStringSubmatch(strings.TrimSpace(value))
+	if matches == nil {
+		return 0, fmt.Errorf("invalid size %q, use a number with an optional unit like 100k, 10M or 1G", value)
+	}
+	number, err := strconv.ParseFloat(matches[1], 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid size %q: %v", value, err)
+	}
+
+	multiplier := float64(1 << 10)
+	switch strings.ToLower(matches[2]) {
+	case "b":
+		multiplier = 1
+	case "m":
+		multiplier = 1 << 20
+	case "g":
+		multiplier = 1 << 30
+	case "t":
+		multiplier = 1 << 40
+	case "p":
+		multiplier = 1 << 50
+	}
+	return int64(number * multiplier), nil
+}
+
+var ageRegex = regexp.MustCompile(`(\d+(?:\.\d+)?)(ms|s|m|h|d|w|M|y)`)
+
+var ageUnits = map[string]time.Duration{
+	"ms": time.Millisecond,
+	"s":  time.Second,
+	"m":  time.Minute,
+	"h":  time.Hour,
+	"d":  24 * time.Hour,
+	"w":  7 * 24 * time.Hour,
+	"M":  30 * 24 * time.Hour,
+	"y":  365 * 24 * time.Hour,
+}
+
+// ParseAge parses an rclone duration such as 30m, 2d or 1w3d. Units are
+// ms, s, m, h, d, w, M (30 days) and y (365 days).
+func ParseAge(value string) (time.Duration, error) {
+	value = strings.TrimSpace(value)
+	matches := ageRegex.FindAllStringSubmatch(value, -1)
+	if len(matches) == 0 || ageRegex.ReplaceAllString(value, "") != "" {
+		return 0, fmt.Errorf("invalid age %q, use a number with a unit like 30m, 12h, 2d or 1w", value)
+	}
+
+	var total time.Duration
+	for _, match := range matches {
+		number, err := strconv.ParseFloat(match[1], 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid age %q: %v", value, err)
+		}
+		total += time.Duration(number * float64(ageUnits[match[2]]))
+	}
+	return total, nil
+}
+
+// parseSizeOrZero parses an optional size
+func parseSizeOrZero(value string) (int64, error) {
+	if value == "" {
+		return 0, nil
+	}
+	return ParseSize(value)
+}
+
+// parseAgeOrZero parses an optional age
+func parseAgeOrZero(value string) (time.Duration, error) {
+	if value == "" {
+		return 0, nil
+	}
+	return ParseAge(value)
+}
@@ -0,0 +1,255 @@
+package filters
+
+import (
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/starfleetcptn/gomft/internal/db"
+)
+
+func TestFromConfig(t *testing.T) {
+	tests := []struct {
+		name        string
+		config      db.TransferConfig
+		wantRules   []Rule
+		wantContent string // Filter file passed to rclone
+		wantErr     bool
+	}{
+		{"Default pattern", db.TransferConfig{FilePattern: "*"}, nil, "", false},
+		{
+			"Legacy file pattern",
+			db.TransferConfig{FilePattern: "*.csv, *.txt"},
+			[]Rule{{ActionInclude, TypeGlob, "*.csv"}, {ActionInclude, TypeGlob, "*.txt"}},
+			"+ *.csv\n+ *.txt\n- **\n",
+			false,
+		},
+		{
+			"Structured rules win over the file pattern",
+			db.TransferConfig{FilePattern: "*.csv", FilterRules: `[{"action":"exclude","type":"regex","pattern":"^tmp_"}]`},
+			[]Rule{{ActionExclude, TypeRegex, "^tmp_"}},
+			"- {{^tmp_}}\n",
+			false,
+		},
+		{
+			"Include and exclude rules",
+			db.TransferConfig{FilterRules: `[{"action":"exclude","type":"glob","pattern":"*.tmp"},{"action":"include","type":"glob","pattern":"data/**"}]`},
+			[]Rule{{ActionExclude, TypeGlob, "*.tmp"}, {ActionInclude, TypeGlob, "data/**"}},
+			"- *.tmp\n+ data/**\n- **\n",
+			false,
+		},
+		{"Invalid JSON", db.TransferConfig{FilterRules: `[{"action":`}, nil, "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filter, err := FromConfig(&tt.config)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("FromConfig() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if !reflect.DeepEqual(filter.Rules, tt.wantRules) {
+				t.Errorf("FromConfig() rules = %v, want %v", filter.Rules, tt.wantRules)
+			}
+			if content := filter.RulesFileContent(); content != tt.wantContent {
+				t.Errorf("RulesFileContent() = %q, want %q", content, tt.wantContent)
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		filter  Filter
+		wantErr bool
+	}{
+		{"Empty", Filter{}, false},
+		{"Valid", Filter{Rules: []Rule{{ActionInclude, TypeGlob, "*.{csv,txt}"}}, MinSize: "1k", MaxSize: "1.5G", MinAge: "1h30m", MaxAge: "2w"}, false},
+		{"Unknown action", Filter{Rules: []Rule{{"copy", TypeGlob, "*"}}}, true},
+		{"Unknown type", Filter{Rules: []Rule{{ActionInclude, "sql", "*"}}}, true},
+		{"Empty pattern", Filter{Rules: []Rule{{ActionInclude, TypeGlob, " "}}}, true},
+		{"Invalid regex", Filter{Rules: []Rule{{ActionInclude, TypeRegex, "report_(\\d+"}}}, true},
+		{"Unterminated glob", Filter{Rules: []Rule{{ActionInclude, TypeGlob, "*.{csv"}}}, true},
+		{"Invalid size", Filter{MinSize: "ten megabytes"}, true},
+		{"Invalid age", Filter{MaxAge: "3 days"}, true},
+		{"Min size above max size", Filter{MinSize: "2M", MaxSize: "1M"}, true},
+		{"Min age above max age", Filter{MinAge: "2d", MaxAge: "1d"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.filter.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestArgs(t *testing.T) {
+	filter := Filter{
+		Rules: []Rule{
+			{ActionExclude, TypeGlob, "/tmp/**"},
+			{ActionInclude, TypeRegex, `report_\d+\.csv`},
+		},
+		MinSize: "1k",
+		MaxAge:  "7d",
+	}
+
+	args, cleanup, err := filter.Args()
+	if err != nil {
+		t.Fatalf("Args() error = %v", err)
+	}
+	if len(args) != 6 || args[0] != "--filter-from" {
+		t.Fatalf("Args() = %v", args)
+	}
+	if !reflect.DeepEqual(args[2:], []string{"--min-size", "1k", "--max-age", "7d"}) {
+		t.Errorf("Args() flags = %v", args[2:])
+	}
+
+	content, err := os.ReadFile(args[1])
+	if err != nil {
+		t.Fatalf("Failed to read filter file: %v", err)
+	}
+	want := "- /tmp/**\n+ {{report_\\d+\\.csv}}\n- **\n"
+	if string(content) != want {
+		t.Errorf("Filter file = %q, want %q", content, want)
+	}
+
+	cleanup()
+	if _, err := os.Stat(args[1]); !os.IsNotExist(err) {
+		t.Errorf("cleanup() did not remove the filter file")
+	}
+
+	if args, _, _ := (&Filter{}).Args(); len(args) != 0 {
+		t.Errorf("Args() for an empty filter = %v", args)
+	}
+}
+
+func TestRaiseMinAge(t *testing.T) {
+	tests := []struct {
+		name   string
+		minAge string
+		raise  time.Duration
+		want   string
+	}{
+		{"No filter age", "", 5 * time.Minute, "300s"},
+		{"Lower filter age", "1m", 5 * time.Minute, "300s"},
+		{"Higher filter age", "1h", 5 * time.Minute, "1h"},
+		{"No minimum", "2d", 0, "2d"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filter := Filter{MinAge: tt.minAge}
+			filter.RaiseMinAge(tt.raise)
+			if filter.MinAge != tt.want {
+				t.Errorf("RaiseMinAge() = %q, want %q", filter.MinAge, tt.want)
+			}
+		})
+	}
+}
+
+func TestExcludeDir(t *testing.T) {
+	filter := Filter{Rules: []Rule{{Action: ActionInclude, Type: TypeGlob, Pattern: "*.csv"}}}
+	filter.ExcludeDir("/archive[1]/")
+	filter.ExcludeDir("")
+	if err := filter.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+	if content := filter.RulesFileContent(); content != "- /archive\\[1\\]/**\n+ *.csv\n- **\n" {
+		t.Errorf("RulesFileContent() = %q", content)
+	}
+	now := time.Now()
+	if ok, _ := filter.Match("archive[1]/a.csv", 10, time.Time{}, now); ok {
+		t.Error("Match() of a file in the excluded directory = true, want false")
+	}
+	if ok, _ := filter.Match("in/a.csv", 10, time.Time{}, now); !ok {
+		t.Error("Match() of a file outside the excluded directory = false, want true")
+	}
+}
+
+func TestMatch(t *testing.T) {
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	filter := Filter{
+		Rules: []Rule{
+			{ActionExclude, TypeGlob, "/archive/**"},
+			{ActionExclude, TypeGlob, "*.{tmp,part}"},
+			{ActionInclude, TypeGlob, "*.csv"},
+			{ActionInclude, TypeRegex, `invoice_\d{4}\.pdf`},
+		},
+		MinSize: "1k",
+		MaxAge:  "30d",
+	}
+
+	tests := []struct {
+		path    string
+		size    int64
+		age     time.Duration
+		want    bool
+		wantWhy string
+	}{
+		{"data.csv", 2048, time.Hour, true, ""},
+		{"nested/dir/data.csv", 2048, time.Hour, true, ""},
+		{"archive/old.csv", 2048, time.Hour, false, "excluded by rule 1 (/archive/**)"},
+		{"upload.part", 2048, time.Hour, false, "excluded by rule 2 (*.{tmp,part})"},
+		{"invoice_2024.pdf", 2048, time.Hour, true, ""},
+		{"invoice_24.pdf", 2048, time.Hour, false, "not matched by any include rule"},
+		{"notes.txt", 2048, time.Hour, false, "not matched by any include rule"},
+		{"small.csv", 100, time.Hour, false, "smaller than 1k"},
+		{"stale.csv", 2048, 40 * 24 * time.Hour, false, "older than 30d"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			got, why := filter.Match(tt.path, tt.size, now.Add(-tt.age), now)
+			if got != tt.want || why != tt.wantWhy {
+				t.Errorf("Match(%q) = %v, %q, want %v, %q", tt.path, got, why, tt.want, tt.wantWhy)
+			}
+		})
+	}
+}
+
+func TestParseSizeAndAge(t *testing.T) {
+	sizes := map[string]int64{"512b": 512, "100": 100 << 10, "1.5k": 1536, "10M": 10 << 20, "1GiB": 1 << 30}
+	for value, want := range sizes {
+		if got, err := ParseSize(value); err != nil || got != want {
+			t.Errorf("ParseSize(%q) = %d, %v, want %d", value, got, err, want)
+		}
+	}
+
+	ages := map[string]time.Duration{"90s": 90 * time.Second, "1h30m": 90 * time.Minute, "2d": 48 * time.Hour, "1w": 7 * 24 * time.Hour, "1M": 30 * 24 * time.Hour}
+	for value, want := range ages {
+		if got, err := ParseAge(value); err != nil || got != want {
+			t.Errorf("ParseAge(%q) = %v, %v, want %v", value, got, err, want)
+		}
+	}
+	if _, err := ParseAge("2 days"); err == nil {
+		t.Error("ParseAge() expected error for unsupported format")
+	}
+}
+
+func TestTestSample(t *testing.T) {
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	filter := Filter{Rules: []Rule{{ActionInclude, TypeGlob, "*.csv"}}, MinAge: "1h"}
+
+	results, err := filter.TestSample("a.csv, 10M, 2d\n\nb.txt\nc.csv,,5m\n", now)
+	if err != nil {
+		t.Fatalf("TestSample() error = %v", err)
+	}
+	want := []SampleResult{
+		{Path: "a.csv", Included: true},
+		{Path: "b.txt", Included: false, Reason: "not matched by any include rule"},
+		{Path: "c.csv", Included: false, Reason: "newer than 1h"},
+	}
+	if !reflect.DeepEqual(results, want) {
+		t.Errorf("TestSample() = %+v, want %+v", results, want)
+	}
+
+	if _, err := filter.TestSample("a.csv, huge", now); err == nil {
+		t.Error("TestSample() expected error for invalid size")
+	}
+}
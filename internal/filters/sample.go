@@ -0,0 +1,54 @@
+package filters
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// SampleResult is the outcome of testing a filter against one line of a sample listing
+type SampleResult struct {
+	Path     string
+	Included bool
+	Reason   string
+}
+
+// TestSample tests the filter against a sample listing. Each line holds a path,
+// optionally followed by a size and an age separated by commas, e.g.
+// "reports/2024-01.csv, 10M, 2d". Lines without a size or age only test the rules.
+func (f *Filter) TestSample(listing string, now time.Time) ([]SampleResult, error) {
+	var results []SampleResult
+	for i, line := range strings.Split(listing, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		parts := strings.Split(line, ",")
+		path := strings.TrimSpace(parts[0])
+		size := int64(-1)
+		var modTime time.Time
+
+		if len(parts) > 1 && strings.TrimSpace(parts[1]) != "" {
+			parsed, err := ParseSize(parts[1])
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %v", i+1, err)
+			}
+			size = parsed
+		}
+		if len(parts) > 2 && strings.TrimSpace(parts[2]) != "" {
+			age, err := ParseAge(parts[2])
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %v", i+1, err)
+			}
+			modTime = now.Add(-age)
+		}
+		if len(parts) > 3 {
+			return nil, fmt.Errorf("line %d: expected path, size and age", i+1)
+		}
+
+		included, reason := f.Match(path, size, modTime, now)
+		results = append(results, SampleResult{Path: path, Included: included, Reason: reason})
+	}
+	return results, nil
+}
@@ -0,0 +1,278 @@
+// Package ftps holds the TLS options of FTP remotes and checks the TLS setup of FTPS
+// servers before rclone connects to them.
+package ftps
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TLS modes of an FTP remote. Without a mode the remote uses plain FTP.
+const (
+	ModeExplicit = "explicit" // Upgrades the control connection with AUTH TLS, usually on port 21
+	ModeImplicit = "implicit" // Speaks TLS from the first byte, usually on port 990
+)
+
+// Options are the TLS options of an FTP remote
+type Options struct {
+	Mode         string // "", ModeExplicit or ModeImplicit
+	SkipVerify   bool   // Accept any server certificate
+	SessionReuse bool   // Resume the TLS session of the control connection on data connections
+	CACert       string // PEM certificates trusted instead of the system roots
+	ClientCert   string // PEM certificate and private key presented to the server
+}
+
+// Enabled reports whether the remote uses TLS
+func (o Options) Enabled() bool {
+	return o.Mode != ""
+}
+
+// Validate checks the mode and parses the certificates of the options
+func (o Options) Validate() error {
+	switch o.Mode {
+	case "", ModeExplicit, ModeImplicit:
+	default:
+		return fmt.Errorf("unsupported FTPS mode %q", o.Mode)
+	}
+	if !o.Enabled() {
+		return nil
+	}
+	if o.CACert != "" {
+		if _, err := ParseCACertificates(o.CACert); err != nil {
+			return err
+		}
+	}
+	if o.ClientCert != "" {
+		if _, _, err := SplitClientCertificate(o.ClientCert); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RcloneArgs returns the rclone config options of the TLS mode, certificate verification
+// and session reuse of an FTP remote. The certificate files are set separately.
+func (o Options) RcloneArgs() []string {
+	switch o.Mode {
+	case ModeExplicit:
+		return append([]string{"explicit_tls", "true"}, o.tlsArgs()...)
+	case ModeImplicit:
+		return append([]string{"tls", "true"}, o.tlsArgs()...)
+	}
+	return []string{"tls", "false", "explicit_tls", "false"}
+}
+
+func (o Options) tlsArgs() []string {
+	var args []string
+	if o.SkipVerify {
+		args = append(args, "no_check_certificate", "true")
+	}
+	if !o.SessionReuse {
+		// Servers that require session reuse reject the data connections without it
+		args = append(args, "tls_cache_size", "0")
+	}
+	return args
+}
+
+// ParseCACertificates parses the PEM certificates a remote trusts
+func ParseCACertificates(material string) (*x509.CertPool, error) {
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM([]byte(material)) {
+		return nil, fmt.Errorf("no valid CA certificate found")
+	}
+	return pool, nil
+}
+
+// SplitClientCertificate splits PEM material holding a client certificate, its chain and
+// its private key into the certificates and the key, after checking that they belong together
+func SplitClientCertificate(material string) (string, string, error) {
+	var certs, key strings.Builder
+	rest := []byte(material)
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		if block.Type == "CERTIFICATE" {
+			certs.Write(pem.EncodeToMemory(block))
+		} else if strings.HasSuffix(block.Type, "PRIVATE KEY") {
+			if key.Len() > 0 {
+				return "", "", fmt.Errorf("the client certificate holds more than one private key")
+			}
+			key.Write(pem.EncodeToMemory(block))
+		}
+	}
+	if certs.Len() == 0 {
+		return "", "", fmt.Errorf("no certificate found in the client certificate")
+	}
+	if key.Len() == 0 {
+		return "", "", fmt.Errorf("no private key found in the client certificate, include the unencrypted key in the PEM file")
+	}
+	if _, err := tls.X509KeyPair([]byte(certs.String()), []byte(key.String())); err != nil {
+		return "", "", fmt.Errorf("invalid client certificate: %v", err)
+	}
+	return certs.String(), key.String(), nil
+}
+
+// TLSConfig returns the TLS configuration rclone uses for a server with these options
+func (o Options) TLSConfig(host string) (*tls.Config, error) {
+	config := &tls.Config{
+		ServerName:         host,
+		InsecureSkipVerify: o.SkipVerify,
+	}
+	if o.SessionReuse {
+		config.ClientSessionCache = tls.NewLRUClientSessionCache(0)
+	}
+	if o.CACert != "" {
+		pool, err := ParseCACertificates(o.CACert)
+		if err != nil {
+			return nil, err
+		}
+		config.RootCAs = pool
+	}
+	if o.ClientCert != "" {
+		certs, key, err := SplitClientCertificate(o.ClientCert)
+		if err != nil {
+			return nil, err
+		}
+		cert, err := tls.X509KeyPair([]byte(certs), []byte(key))
+		if err != nil {
+			return nil, err
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+	return config, nil
+}
+
+// Certificate describes the certificate an FTPS server presented
+type Certificate struct {
+	Subject     string    `json:"subject"`
+	Issuer      string    `json:"issuer"`
+	Fingerprint string    `json:"fingerprint"` // SHA-256 of the DER encoding, in colon separated hex
+	NotAfter    time.Time `json:"not_after"`
+	TLSVersion  string    `json:"tls_version"`
+}
+
+// Probe connects to an FTPS server, negotiates TLS the way the mode requires and returns
+// the certificate of the server. The connection is closed before logging in.
+func Probe(ctx context.Context, host string, port int, opts Options) (*Certificate, error) {
+	if !opts.Enabled() {
+		return nil, fmt.Errorf("the remote does not use TLS")
+	}
+	config, err := opts.TLSConfig(host)
+	if err != nil {
+		return nil, err
+	}
+	if port == 0 {
+		port = 21
+		if opts.Mode == ModeImplicit {
+			port = 990
+		}
+	}
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(host, strconv.Itoa(port)))
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	var tlsConn *tls.Conn
+	if opts.Mode == ModeImplicit {
+		tlsConn = tls.Client(conn, config)
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			return nil, fmt.Errorf("TLS handshake failed: %v", err)
+		}
+		if reply, err := expectReply(bufio.NewReader(tlsConn), 220); err != nil && reply == "" {
+			return nil, fmt.Errorf("TLS session rejected: %v", err)
+		} else if err != nil {
+			return nil, err
+		}
+	} else {
+		reader := bufio.NewReader(conn)
+		if _, err := expectReply(reader, 220); err != nil {
+			return nil, err
+		}
+		if _, err := fmt.Fprint(conn, "AUTH TLS\r\n"); err != nil {
+			return nil, err
+		}
+		if reply, err := expectReply(reader, 234); err != nil {
+			if reply != "" {
+				return nil, fmt.Errorf("the server does not support AUTH TLS: %s", reply)
+			}
+			return nil, err
+		}
+		tlsConn = tls.Client(conn, config)
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			return nil, fmt.Errorf("TLS handshake failed: %v", err)
+		}
+	}
+
+	// TLS 1.3 servers reject a missing client certificate after the handshake, so the
+	// session is only confirmed once the server answered over it
+	if _, err := fmt.Fprint(tlsConn, "QUIT\r\n"); err != nil {
+		return nil, fmt.Errorf("TLS session rejected: %v", err)
+	}
+	if _, err := readReply(bufio.NewReader(tlsConn)); err != nil {
+		return nil, fmt.Errorf("TLS session rejected: %v", err)
+	}
+
+	state := tlsConn.ConnectionState()
+	if len(state.PeerCertificates) == 0 {
+		return nil, fmt.Errorf("the server presented no certificate")
+	}
+	leaf := state.PeerCertificates[0]
+	sum := sha256.Sum256(leaf.Raw)
+	return &Certificate{
+		Subject:     leaf.Subject.String(),
+		Issuer:      leaf.Issuer.String(),
+		Fingerprint: strings.ReplaceAll(fmt.Sprintf("% X", sum[:]), " ", ":"),
+		NotAfter:    leaf.NotAfter,
+		TLSVersion:  tls.VersionName(state.Version),
+	}, nil
+}
+
+// expectReply reads a reply and returns an error unless it has the expected code
+func expectReply(reader *bufio.Reader, code int) (string, error) {
+	reply, err := readReply(reader)
+	if err != nil {
+		return "", err
+	}
+	if !strings.HasPrefix(reply, strconv.Itoa(code)) {
+		return reply, fmt.Errorf("unexpected reply from server: %s", reply)
+	}
+	return reply, nil
+}
+
+// readReply reads a single or multi-line FTP reply and returns its last line
+func readReply(reader *bufio.Reader) (string, error) {
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return "", err
+		}
+		line = strings.TrimRight(line, "\r\n")
+		// Lines of a multi-line reply use a dash after the code, the last one a space
+		if len(line) >= 4 && line[3] == ' ' {
+			if _, err := strconv.Atoi(line[:3]); err == nil {
+				return line, nil
+			}
+		}
+		if len(line) == 3 {
+			return line, nil
+		}
+	}
+}
@@ -0,0 +1,240 @@
+package ftps
+
+import (
+	"bufio"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testCA issues the certificates of the local FTPS server and its clients
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  string
+}
+
+func newTestCA(t *testing.T) *testCA {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey() error = %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "GoMFT Test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("CreateCertificate() error = %v", err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	return &testCA{cert: cert, key: key, pem: string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))}
+}
+
+// issue returns a certificate signed by the CA and its private key, PEM encoded
+func (ca *testCA) issue(t *testing.T, commonName string, usage x509.ExtKeyUsage) (string, string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey() error = %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: commonName},
+		DNSNames:     []string{commonName},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatalf("CreateCertificate() error = %v", err)
+	}
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatalf("MarshalPKCS8PrivateKey() error = %v", err)
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})),
+		string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER}))
+}
+
+// serveFTPS runs a local FTPS server that answers the greeting, AUTH TLS and QUIT of each
+// connection, and returns its port
+func serveFTPS(t *testing.T, mode string, config *tls.Config, authReply string) int {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen() error = %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func(conn net.Conn) {
+				defer conn.Close()
+				conn.SetDeadline(time.Now().Add(5 * time.Second))
+				if mode == ModeImplicit {
+					conn = tls.Server(conn, config)
+				} else {
+					reader := bufio.NewReader(conn)
+					conn.Write([]byte("220-GoMFT test server\r\n220 Ready\r\n"))
+					if line, _ := reader.ReadString('\n'); !strings.HasPrefix(line, "AUTH TLS") {
+						return
+					}
+					conn.Write([]byte(authReply + "\r\n"))
+					if !strings.HasPrefix(authReply, "234") {
+						return
+					}
+					conn = tls.Server(conn, config)
+				}
+				reader := bufio.NewReader(conn)
+				if mode == ModeImplicit {
+					if _, err := conn.Write([]byte("220 Ready\r\n")); err != nil {
+						return
+					}
+				}
+				if line, _ := reader.ReadString('\n'); strings.HasPrefix(line, "QUIT") {
+					conn.Write([]byte("221 Goodbye\r\n"))
+				}
+			}(conn)
+		}
+	}()
+	return listener.Addr().(*net.TCPAddr).Port
+}
+
+func TestProbe(t *testing.T) {
+	ca := newTestCA(t)
+	serverCert, serverKey := ca.issue(t, "localhost", x509.ExtKeyUsageServerAuth)
+	clientCert, clientKey := ca.issue(t, "gomft", x509.ExtKeyUsageClientAuth)
+	otherCA := newTestCA(t)
+	otherCert, otherKey := otherCA.issue(t, "gomft", x509.ExtKeyUsageClientAuth)
+
+	pair, err := tls.X509KeyPair([]byte(serverCert), []byte(serverKey))
+	if err != nil {
+		t.Fatalf("X509KeyPair() error = %v", err)
+	}
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(ca.cert)
+	serverConfig := &tls.Config{Certificates: []tls.Certificate{pair}}
+	mutualConfig := &tls.Config{Certificates: []tls.Certificate{pair}, ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: clientCAs}
+
+	tests := []struct {
+		name      string
+		mode      string
+		server    *tls.Config
+		authReply string
+		opts      Options
+		wantErr   string
+	}{
+		{"explicit", ModeExplicit, serverConfig, "234 AUTH TLS successful", Options{CACert: ca.pem}, ""},
+		{"implicit", ModeImplicit, serverConfig, "", Options{CACert: ca.pem, SessionReuse: true}, ""},
+		{"untrusted certificate", ModeExplicit, serverConfig, "234 AUTH TLS successful", Options{}, "TLS handshake failed"},
+		{"skip verification", ModeImplicit, serverConfig, "", Options{SkipVerify: true}, ""},
+		{"wrong CA", ModeImplicit, serverConfig, "", Options{CACert: otherCA.pem}, "TLS handshake failed"},
+		{"AUTH TLS refused", ModeExplicit, serverConfig, "500 AUTH not understood", Options{CACert: ca.pem}, "does not support AUTH TLS: 500"},
+		{"client certificate", ModeExplicit, mutualConfig, "234 AUTH TLS successful", Options{CACert: ca.pem, ClientCert: clientCert + clientKey}, ""},
+		{"missing client certificate", ModeImplicit, mutualConfig, "", Options{CACert: ca.pem}, "TLS session rejected"},
+		{"unknown client certificate", ModeExplicit, mutualConfig, "234 AUTH TLS successful", Options{CACert: ca.pem, ClientCert: otherCert + otherKey}, "TLS session rejected"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			port := serveFTPS(t, tt.mode, tt.server, tt.authReply)
+			tt.opts.Mode = tt.mode
+
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			cert, err := Probe(ctx, "localhost", port, tt.opts)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Probe() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Probe() error = %v", err)
+			}
+			if cert.Subject != "CN=localhost" || cert.Issuer != "CN=GoMFT Test CA" {
+				t.Errorf("Probe() certificate = %s issued by %s", cert.Subject, cert.Issuer)
+			}
+			if len(cert.Fingerprint) != 95 {
+				t.Errorf("Probe() fingerprint = %q, want colon separated SHA-256", cert.Fingerprint)
+			}
+		})
+	}
+}
+
+func TestRcloneArgs(t *testing.T) {
+	tests := []struct {
+		opts Options
+		want string
+	}{
+		{Options{}, "tls false explicit_tls false"},
+		{Options{Mode: ModeExplicit, SessionReuse: true}, "explicit_tls true"},
+		{Options{Mode: ModeImplicit, SessionReuse: true, SkipVerify: true}, "tls true no_check_certificate true"},
+		{Options{Mode: ModeExplicit}, "explicit_tls true tls_cache_size 0"},
+	}
+	for _, tt := range tests {
+		if got := strings.Join(tt.opts.RcloneArgs(), " "); got != tt.want {
+			t.Errorf("RcloneArgs(%+v) = %q, want %q", tt.opts, got, tt.want)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	ca := newTestCA(t)
+	cert, key := ca.issue(t, "gomft", x509.ExtKeyUsageClientAuth)
+	_, otherKey := ca.issue(t, "other", x509.ExtKeyUsageClientAuth)
+
+	tests := []struct {
+		name    string
+		opts    Options
+		wantErr string
+	}{
+		{"plain FTP", Options{}, ""},
+		{"certificates ignored without TLS", Options{CACert: "not a certificate"}, ""},
+		{"unknown mode", Options{Mode: "starttls"}, "unsupported FTPS mode"},
+		{"CA certificate", Options{Mode: ModeExplicit, CACert: ca.pem}, ""},
+		{"invalid CA certificate", Options{Mode: ModeExplicit, CACert: "not a certificate"}, "no valid CA certificate"},
+		{"client certificate", Options{Mode: ModeImplicit, ClientCert: key + cert}, ""},
+		{"missing private key", Options{Mode: ModeImplicit, ClientCert: cert}, "no private key"},
+		{"missing certificate", Options{Mode: ModeImplicit, ClientCert: key}, "no certificate"},
+		{"mismatched private key", Options{Mode: ModeImplicit, ClientCert: cert + otherKey}, "invalid client certificate"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.opts.Validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Validate() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Validate() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,111 @@
+// Package hostkeys fetches the host keys of SSH servers and builds the known_hosts files
+// that SFTP remotes pinned to a host key are checked against.
+package hostkeys
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// HostKey is the host key an SSH server presented
+type HostKey struct {
+	Type        string // Key algorithm, such as ssh-ed25519
+	PublicKey   string // Key in authorized_keys format
+	Fingerprint string // SHA256 fingerprint as printed by ssh-keygen -l
+}
+
+// errCaptured aborts the handshake once the host key has been received
+var errCaptured = errors.New("host key captured")
+
+// Scan connects to an SSH server and returns its host key. The connection is closed
+// before authenticating, so no credentials are needed.
+func Scan(host string, port int, timeout time.Duration) (*HostKey, error) {
+	var captured ssh.PublicKey
+	config := &ssh.ClientConfig{
+		User: "gomft",
+		HostKeyCallback: func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+			captured = key
+			return errCaptured
+		},
+		Timeout: timeout,
+	}
+
+	client, err := ssh.Dial("tcp", Address(host, port), config)
+	if err == nil {
+		client.Close()
+	}
+	if captured == nil {
+		if err == nil {
+			err = errors.New("the server did not present a host key")
+		}
+		return nil, fmt.Errorf("failed to fetch the host key of %s: %v", Address(host, port), err)
+	}
+	return newHostKey(captured), nil
+}
+
+// Parse parses a host key in authorized_keys format
+func Parse(publicKey string) (*HostKey, error) {
+	key, _, _, _, err := ssh.ParseAuthorizedKey([]byte(strings.TrimSpace(publicKey)))
+	if err != nil {
+		return nil, fmt.Errorf("invalid host key: %v", err)
+	}
+	return newHostKey(key), nil
+}
+
+func newHostKey(key ssh.PublicKey) *HostKey {
+	return &HostKey{
+		Type:        key.Type(),
+		PublicKey:   strings.TrimSpace(string(ssh.MarshalAuthorizedKey(key))),
+		Fingerprint: ssh.FingerprintSHA256(key),
+	}
+}
+
+// Address returns the host:port address of an SSH server, port 22 when none is set
+func Address(host string, port int) string {
+	if port == 0 {
+		port = 22
+	}
+	return net.JoinHostPort(host, strconv.Itoa(port))
+}
+
+// KnownHostsLine returns the known_hosts line pinning a host key to a server
+func KnownHostsLine(host string, port int, publicKey string) (string, error) {
+	key, _, _, _, err := ssh.ParseAuthorizedKey([]byte(strings.TrimSpace(publicKey)))
+	if err != nil {
+		return "", fmt.Errorf("invalid host key: %v", err)
+	}
+	return knownhosts.Line([]string{knownhosts.Normalize(Address(host, port))}, key), nil
+}
+
+// ParsePin parses a known_hosts line pinning a host key, and returns the key when the line
+// pins it to the server, or nil when the line is empty or pins a key of another server
+func ParsePin(line, host string, port int) (*HostKey, error) {
+	line = strings.TrimSpace(line)
+	if line == "" {
+		return nil, nil
+	}
+	_, hosts, key, _, _, err := ssh.ParseKnownHosts([]byte(line))
+	if err != nil {
+		return nil, fmt.Errorf("invalid host key: %v", err)
+	}
+	address := knownhosts.Normalize(Address(host, port))
+	for _, pinned := range hosts {
+		if pinned == address {
+			return newHostKey(key), nil
+		}
+	}
+	return nil, nil
+}
+
+// IsKeyMismatch reports whether rclone output shows that a server presented a host key
+// other than the one pinned in the known_hosts file
+func IsKeyMismatch(output string) bool {
+	return strings.Contains(output, "knownhosts: key mismatch")
+}
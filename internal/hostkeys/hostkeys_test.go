@@ -0,0 +1,158 @@
+package hostkeys
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// startServer starts an SSH server that presents the signer as its host key and
+// rejects every login
+func startServer(t *testing.T, signer ssh.Signer) (string, int) {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen() error = %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	config := &ssh.ServerConfig{
+		PasswordCallback: func(ssh.ConnMetadata, []byte) (*ssh.Permissions, error) {
+			return nil, ssh.ErrNoAuth
+		},
+	}
+	config.AddHostKey(signer)
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				ssh.NewServerConn(conn, config)
+			}()
+		}
+	}()
+
+	addr := listener.Addr().(*net.TCPAddr)
+	return addr.IP.String(), addr.Port
+}
+
+func newSigner(t *testing.T) ssh.Signer {
+	t.Helper()
+	_, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey() error = %v", err)
+	}
+	signer, err := ssh.NewSignerFromKey(private)
+	if err != nil {
+		t.Fatalf("NewSignerFromKey() error = %v", err)
+	}
+	return signer
+}
+
+func TestScan(t *testing.T) {
+	signer := newSigner(t)
+	host, port := startServer(t, signer)
+
+	key, err := Scan(host, port, 5*time.Second)
+	if err != nil {
+		t.Fatalf("Scan() error = %v", err)
+	}
+	if key.Type != ssh.KeyAlgoED25519 {
+		t.Errorf("Scan() type = %q, want %q", key.Type, ssh.KeyAlgoED25519)
+	}
+	if want := ssh.FingerprintSHA256(signer.PublicKey()); key.Fingerprint != want {
+		t.Errorf("Scan() fingerprint = %q, want %q", key.Fingerprint, want)
+	}
+
+	parsed, err := Parse(key.PublicKey)
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if *parsed != *key {
+		t.Errorf("Parse() = %+v, want %+v", parsed, key)
+	}
+}
+
+func TestScanUnreachable(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen() error = %v", err)
+	}
+	port := listener.Addr().(*net.TCPAddr).Port
+	listener.Close()
+
+	if _, err := Scan("127.0.0.1", port, time.Second); err == nil {
+		t.Errorf("Scan() of a closed port succeeded")
+	}
+}
+
+func TestKnownHostsLine(t *testing.T) {
+	key := newHostKey(newSigner(t).PublicKey())
+
+	tests := []struct {
+		host string
+		port int
+		want string
+	}{
+		{"sftp.example.com", 22, "sftp.example.com " + key.PublicKey},
+		{"sftp.example.com", 0, "sftp.example.com " + key.PublicKey},
+		{"sftp.example.com", 2222, "[sftp.example.com]:2222 " + key.PublicKey},
+	}
+	for _, tt := range tests {
+		line, err := KnownHostsLine(tt.host, tt.port, key.PublicKey)
+		if err != nil {
+			t.Fatalf("KnownHostsLine() error = %v", err)
+		}
+		if line != tt.want {
+			t.Errorf("KnownHostsLine(%q, %d) = %q, want %q", tt.host, tt.port, line, tt.want)
+		}
+	}
+
+	if _, err := KnownHostsLine("sftp.example.com", 22, "not a key"); err == nil {
+		t.Errorf("KnownHostsLine() with an invalid key succeeded")
+	}
+}
+
+func TestParsePin(t *testing.T) {
+	key := newHostKey(newSigner(t).PublicKey())
+	line, err := KnownHostsLine("sftp.example.com", 2222, key.PublicKey)
+	if err != nil {
+		t.Fatalf("KnownHostsLine() error = %v", err)
+	}
+
+	pinned, err := ParsePin(line, "sftp.example.com", 2222)
+	if err != nil || pinned == nil || *pinned != *key {
+		t.Errorf("ParsePin() = %+v, %v, want %+v", pinned, err, key)
+	}
+	// A key pinned for another server is not used
+	for _, port := range []int{22, 0} {
+		if pinned, err := ParsePin(line, "sftp.example.com", port); pinned != nil || err != nil {
+			t.Errorf("ParsePin() for port %d = %+v, %v, want none", port, pinned, err)
+		}
+	}
+	if pinned, err := ParsePin("", "sftp.example.com", 2222); pinned != nil || err != nil {
+		t.Errorf("ParsePin() of an empty line = %+v, %v, want none", pinned, err)
+	}
+	if _, err := ParsePin("sftp.example.com not-a-key", "sftp.example.com", 22); err == nil {
+		t.Errorf("ParsePin() of an invalid line succeeded")
+	}
+}
+
+func TestIsKeyMismatch(t *testing.T) {
+	output := `ERROR : : error listing: couldn't connect SSH: ssh: handshake failed: knownhosts: key mismatch`
+	if !IsKeyMismatch(output) {
+		t.Errorf("IsKeyMismatch() = false for %q", output)
+	}
+	if IsKeyMismatch(strings.Replace(output, "key mismatch", "key is unknown", 1)) {
+		t.Errorf("IsKeyMismatch() = true for an unknown key")
+	}
+}
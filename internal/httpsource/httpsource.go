@@ -0,0 +1,419 @@
+// Package httpsource downloads the files of HTTP(S) sources: a list of URLs or the files
+// linked from the index page of a base URL, with date variables expanded in the URLs.
+// Downloads are conditional on the ETag and Last-Modified of the previous delivery.
+package httpsource
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Authentication types of an HTTP source. Without a type no credentials are sent.
+const (
+	AuthBasic  = "basic"  // User name and password
+	AuthBearer = "bearer" // Token in the Authorization header
+)
+
+// variableRegex matches the ${date:layout} and ${yesterday:layout} variables of a URL
+var variableRegex = regexp.MustCompile(`\$\{([a-z_]+)(?::([^}]*))?\}`)
+
+// hrefRegex matches the links of an index page
+var hrefRegex = regexp.MustCompile(`(?i)<a\s[^>]*href\s*=\s*["']([^"']+)["']`)
+
+// Source describes the URLs of an HTTP source and how to authenticate to them
+type Source struct {
+	BaseURL string      // Index page listing the files, or base of relative URLs
+	URLs    []string    // URLs of the files, absolute or relative to the base URL
+	Headers http.Header // Sent with every request
+	Auth    string      // "", AuthBasic or AuthBearer
+	User    string      // User name of basic authentication
+	Secret  string      // Password of basic authentication or bearer token
+	Client  *http.Client
+}
+
+// Validators identify the version of a file a server returned, so the next download
+// only transfers the file when it changed
+type Validators struct {
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"last_modified,omitempty"`
+}
+
+// IsZero reports whether the server returned no validators
+func (v Validators) IsZero() bool {
+	return v.ETag == "" && v.LastModified == ""
+}
+
+// File is a file downloaded from an HTTP source
+type File struct {
+	URL         string
+	Name        string
+	Size        int64
+	ModTime     time.Time // Last-Modified of the response, zero when the server sent none
+	MD5         string
+	Validators  Validators
+	NotModified bool // The server confirmed the previous version is current, nothing was downloaded
+}
+
+// ParseURLs parses URLs given one per line, ignoring blank lines and # comments
+func ParseURLs(text string) []string {
+	var urls []string
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		urls = append(urls, line)
+	}
+	return urls
+}
+
+// ParseHeaders parses headers given as "Name: value" lines
+func ParseHeaders(text string) (http.Header, error) {
+	headers := make(http.Header)
+	for i, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(line, ":")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" || strings.ContainsAny(name, " \t") {
+			return nil, fmt.Errorf("line %d: expected a header as Name: value", i+1)
+		}
+		if http.CanonicalHeaderKey(name) == "Authorization" {
+			return nil, fmt.Errorf("line %d: set the Authorization header with basic or bearer authentication", i+1)
+		}
+		headers.Add(name, strings.TrimSpace(value))
+	}
+	return headers, nil
+}
+
+// Expand replaces the date variables of a URL. ${date:layout} is the given time and
+// ${yesterday:layout} the day before, both formatted with a Go time layout.
+func Expand(rawURL string, now time.Time) (string, error) {
+	var firstErr error
+	result := variableRegex.ReplaceAllStringFunc(rawURL, func(match string) string {
+		parts := variableRegex.FindStringSubmatch(match)
+		name, layout := parts[1], parts[2]
+		var t time.Time
+		switch name {
+		case "date":
+			t = now
+		case "yesterday":
+			t = now.AddDate(0, 0, -1)
+		default:
+			if firstErr == nil {
+				firstErr = fmt.Errorf("unknown variable ${%s}", name)
+			}
+			return match
+		}
+		if layout == "" {
+			if firstErr == nil {
+				firstErr = fmt.Errorf("${%s} requires a format, e.g. ${%s:20060102}", name, name)
+			}
+			return match
+		}
+		return t.Format(layout)
+	})
+	return result, firstErr
+}
+
+// Validate checks the URLs and authentication of the source, expanding the date variables
+// of the URLs with the given time
+func (s *Source) Validate(now time.Time) error {
+	if s.BaseURL == "" && len(s.URLs) == 0 {
+		return fmt.Errorf("a base URL or a list of URLs is required")
+	}
+	if s.BaseURL != "" {
+		if _, err := s.base(now); err != nil {
+			return err
+		}
+	}
+	for _, rawURL := range s.URLs {
+		fileURL, err := s.resolve(rawURL, now)
+		if err != nil {
+			return err
+		}
+		if _, err := fileName(fileURL); err != nil {
+			return err
+		}
+	}
+	switch s.Auth {
+	case "", AuthBearer:
+	case AuthBasic:
+		if s.User == "" {
+			return fmt.Errorf("basic authentication requires a user name")
+		}
+	default:
+		return fmt.Errorf("unsupported authentication %q", s.Auth)
+	}
+	return nil
+}
+
+// base returns the expanded base URL
+func (s *Source) base(now time.Time) (*url.URL, error) {
+	expanded, err := Expand(s.BaseURL, now)
+	if err != nil {
+		return nil, fmt.Errorf("base URL: %v", err)
+	}
+	base, err := parseHTTPURL(expanded)
+	if err != nil {
+		return nil, fmt.Errorf("base URL: %v", err)
+	}
+	// The base URL is a directory, relative URLs and index links are below it
+	if !strings.HasSuffix(base.Path, "/") {
+		base.Path += "/"
+	}
+	return base, nil
+}
+
+// resolve returns the expanded URL of a file, resolving relative URLs against the base URL
+func (s *Source) resolve(rawURL string, now time.Time) (*url.URL, error) {
+	expanded, err := Expand(rawURL, now)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", rawURL, err)
+	}
+	if s.BaseURL == "" {
+		fileURL, err := parseHTTPURL(expanded)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", rawURL, err)
+		}
+		return fileURL, nil
+	}
+	base, err := s.base(now)
+	if err != nil {
+		return nil, err
+	}
+	relative, err := url.Parse(expanded)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", rawURL, err)
+	}
+	return base.ResolveReference(relative), nil
+}
+
+// parseHTTPURL parses an absolute http or https URL
+func parseHTTPURL(rawURL string) (*url.URL, error) {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	if (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return nil, fmt.Errorf("%s is not an http or https URL", rawURL)
+	}
+	return parsed, nil
+}
+
+// fileName returns the name a URL is downloaded as, the last segment of its path
+func fileName(fileURL *url.URL) (string, error) {
+	name := path.Base(fileURL.Path)
+	if strings.HasSuffix(fileURL.Path, "/") || name == "/" || name == "." {
+		return "", fmt.Errorf("%s does not name a file", fileURL.Redacted())
+	}
+	return name, nil
+}
+
+// FileName returns the name the file of a URL is downloaded as
+func FileName(fileURL string) (string, error) {
+	parsed, err := parseHTTPURL(fileURL)
+	if err != nil {
+		return "", err
+	}
+	return fileName(parsed)
+}
+
+// Resolve returns the URLs of the files of the source at the given time: the URLs of the
+// list, or the files the index page of the base URL links to when there is no list
+func (s *Source) Resolve(ctx context.Context, now time.Time) ([]string, error) {
+	if err := s.Validate(now); err != nil {
+		return nil, err
+	}
+
+	var fileURLs []*url.URL
+	if len(s.URLs) > 0 {
+		for _, rawURL := range s.URLs {
+			fileURL, _ := s.resolve(rawURL, now)
+			fileURLs = append(fileURLs, fileURL)
+		}
+	} else {
+		base, _ := s.base(now)
+		links, err := s.indexLinks(ctx, base)
+		if err != nil {
+			return nil, err
+		}
+		fileURLs = links
+	}
+
+	// Files are downloaded next to each other, so their names must be unique
+	names := make(map[string]string)
+	var urls []string
+	for _, fileURL := range fileURLs {
+		name, err := fileName(fileURL)
+		if err != nil {
+			return nil, err
+		}
+		if other, ok := names[name]; ok {
+			if other == fileURL.Redacted() {
+				continue
+			}
+			return nil, fmt.Errorf("%s and %s are both downloaded as %s", other, fileURL.Redacted(), name)
+		}
+		names[name] = fileURL.Redacted()
+		urls = append(urls, fileURL.String())
+	}
+	return urls, nil
+}
+
+// indexLinks returns the files an index page links to directly below its own path.
+// Links to other hosts, parent and sub directories and sorting links are ignored.
+func (s *Source) indexLinks(ctx context.Context, base *url.URL) ([]*url.URL, error) {
+	resp, err := s.get(ctx, base.String(), Validators{})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s: %s", base.Redacted(), resp.Status)
+	}
+	page, err := io.ReadAll(io.LimitReader(resp.Body, 10<<20))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", base.Redacted(), err)
+	}
+
+	var links []*url.URL
+	for _, match := range hrefRegex.FindAllStringSubmatch(string(page), -1) {
+		href, err := url.Parse(strings.TrimSpace(match[1]))
+		if err != nil || href.RawQuery != "" || strings.HasSuffix(href.Path, "/") {
+			continue
+		}
+		link := base.ResolveReference(href)
+		link.Fragment = ""
+		if link.Host != base.Host || path.Dir(link.Path) != path.Clean(base.Path) {
+			continue
+		}
+		links = append(links, link)
+	}
+	return links, nil
+}
+
+// Download fetches a file into dir. With the validators of the previous delivery the
+// request is conditional, and a file the server reports as not modified is not downloaded.
+func (s *Source) Download(ctx context.Context, fileURL string, previous Validators, dir string) (*File, error) {
+	parsed, err := parseHTTPURL(fileURL)
+	if err != nil {
+		return nil, err
+	}
+	name, err := fileName(parsed)
+	if err != nil {
+		return nil, err
+	}
+	file := &File{URL: fileURL, Name: name}
+
+	resp, err := s.get(ctx, fileURL, previous)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified && !previous.IsZero() {
+		file.NotModified = true
+		file.Validators = previous
+		return file, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s: %s", parsed.Redacted(), resp.Status)
+	}
+
+	file.Validators = Validators{
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+	}
+	if modTime, err := http.ParseTime(file.Validators.LastModified); err == nil {
+		file.ModTime = modTime
+	}
+
+	out, err := os.OpenFile(filepath.Join(dir, name), os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return nil, err
+	}
+	hash := md5.New()
+	file.Size, err = io.Copy(io.MultiWriter(out, hash), resp.Body)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(filepath.Join(dir, name))
+		return nil, fmt.Errorf("%s: %v", parsed.Redacted(), err)
+	}
+	if resp.ContentLength >= 0 && file.Size != resp.ContentLength {
+		os.Remove(filepath.Join(dir, name))
+		return nil, fmt.Errorf("%s: received %d of %d bytes", parsed.Redacted(), file.Size, resp.ContentLength)
+	}
+	file.MD5 = hex.EncodeToString(hash.Sum(nil))
+
+	// The listing of the download directory reports the modification time of the server
+	if !file.ModTime.IsZero() {
+		os.Chtimes(filepath.Join(dir, name), file.ModTime, file.ModTime)
+	}
+	return file, nil
+}
+
+// Check requests a file and returns an error unless the server answers with its content.
+// The content itself is not read.
+func (s *Source) Check(ctx context.Context, fileURL string) error {
+	parsed, err := parseHTTPURL(fileURL)
+	if err != nil {
+		return err
+	}
+	resp, err := s.get(ctx, fileURL, Validators{})
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s: %s", parsed.Redacted(), resp.Status)
+	}
+	return nil
+}
+
+// get sends a GET request with the headers and credentials of the source, conditional on
+// the given validators
+func (s *Source) get(ctx context.Context, rawURL string, previous Validators) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	for name, values := range s.Headers {
+		for _, value := range values {
+			req.Header.Add(name, value)
+		}
+	}
+	switch s.Auth {
+	case AuthBasic:
+		req.SetBasicAuth(s.User, s.Secret)
+	case AuthBearer:
+		req.Header.Set("Authorization", "Bearer "+s.Secret)
+	}
+	if previous.ETag != "" {
+		req.Header.Set("If-None-Match", previous.ETag)
+	}
+	if previous.LastModified != "" {
+		req.Header.Set("If-Modified-Since", previous.LastModified)
+	}
+
+	client := s.Client
+	if client == nil {
+		client = &http.Client{Timeout: 30 * time.Minute}
+	}
+	return client.Do(req)
+}
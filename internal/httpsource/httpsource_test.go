@@ -0,0 +1,219 @@
+package httpsource
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// serveReports runs a server holding two reports behind bearer authentication, with an
+// index page linking them, and returns its URL
+func serveReports(t *testing.T) string {
+	t.Helper()
+	modified := time.Date(2024, 3, 14, 6, 0, 0, 0, time.UTC)
+	reports := map[string]string{
+		"/exports/sales-20240314.csv": "id,amount\n1,10\n",
+		"/exports/stock-20240314.csv": "sku,count\nA,3\n",
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" || r.Header.Get("X-Vendor") != "gomft" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if r.URL.Path == "/exports/" {
+			w.Write([]byte(`<html><body>
+				<a href="?C=M;O=A">Last modified</a>
+				<a href="../">Parent Directory</a>
+				<a href="archive/">archive/</a>
+				<a href="sales-20240314.csv">sales-20240314.csv</a>
+				<A HREF='/exports/stock-20240314.csv'>stock-20240314.csv</A>
+				<a href="https://example.com/other.csv">other.csv</a>
+			</body></html>`))
+			return
+		}
+		content, ok := reports[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("ETag", `"`+r.URL.Path+`"`)
+		http.ServeContent(w, r, "", modified, strings.NewReader(content))
+	}))
+	t.Cleanup(server.Close)
+	return server.URL
+}
+
+func TestExpand(t *testing.T) {
+	now := time.Date(2024, 3, 1, 6, 0, 0, 0, time.UTC)
+	tests := []struct {
+		url     string
+		want    string
+		wantErr string
+	}{
+		{"https://vendor.example/report.csv", "https://vendor.example/report.csv", ""},
+		{"https://vendor.example/${date:2006/01}/report-${date:20060102}.csv", "https://vendor.example/2024/03/report-20240301.csv", ""},
+		{"https://vendor.example/report?day=${yesterday:2006-01-02}", "https://vendor.example/report?day=2024-02-29", ""},
+		{"https://vendor.example/${date}.csv", "", "requires a format"},
+		{"https://vendor.example/${uuid}.csv", "", "unknown variable ${uuid}"},
+	}
+	for _, tt := range tests {
+		got, err := Expand(tt.url, now)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Expand(%q) error = %v, want %q", tt.url, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("Expand(%q) = %q, %v, want %q", tt.url, got, err, tt.want)
+		}
+	}
+}
+
+func TestParseHeaders(t *testing.T) {
+	headers, err := ParseHeaders("X-Vendor: gomft\n\nAccept: text/csv\n")
+	if err != nil {
+		t.Fatalf("ParseHeaders() error = %v", err)
+	}
+	if headers.Get("X-Vendor") != "gomft" || headers.Get("Accept") != "text/csv" {
+		t.Errorf("ParseHeaders() = %v", headers)
+	}
+
+	for _, text := range []string{"X-Vendor", "X Vendor: gomft", "Authorization: Bearer secret"} {
+		if _, err := ParseHeaders(text); err == nil {
+			t.Errorf("ParseHeaders(%q) error = nil, want error", text)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		source  Source
+		wantErr string
+	}{
+		{"base URL", Source{BaseURL: "https://vendor.example/exports"}, ""},
+		{"URL list", Source{URLs: []string{"https://vendor.example/a.csv"}, Auth: AuthBearer}, ""},
+		{"relative URLs", Source{BaseURL: "https://vendor.example/exports/", URLs: []string{"a-${date:20060102}.csv"}}, ""},
+		{"no URLs", Source{}, "base URL or a list of URLs"},
+		{"relative URL without base", Source{URLs: []string{"a.csv"}}, "not an http or https URL"},
+		{"unsupported scheme", Source{URLs: []string{"ftp://vendor.example/a.csv"}}, "not an http or https URL"},
+		{"directory URL", Source{URLs: []string{"https://vendor.example/exports/"}}, "does not name a file"},
+		{"invalid variable", Source{BaseURL: "https://vendor.example/${date}/"}, "base URL: ${date} requires a format"},
+		{"basic without user", Source{BaseURL: "https://vendor.example/", Auth: AuthBasic}, "requires a user name"},
+		{"unsupported authentication", Source{BaseURL: "https://vendor.example/", Auth: "digest"}, "unsupported authentication"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.source.Validate(time.Now())
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Validate() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Validate() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestResolve(t *testing.T) {
+	serverURL := serveReports(t)
+	headers := http.Header{"X-Vendor": {"gomft"}}
+	now := time.Date(2024, 3, 14, 8, 0, 0, 0, time.UTC)
+
+	source := Source{BaseURL: serverURL + "/exports", Headers: headers, Auth: AuthBearer, Secret: "secret"}
+	urls, err := source.Resolve(context.Background(), now)
+	if err != nil {
+		t.Fatalf("Resolve() error = %v", err)
+	}
+	want := []string{serverURL + "/exports/sales-20240314.csv", serverURL + "/exports/stock-20240314.csv"}
+	if !reflect.DeepEqual(urls, want) {
+		t.Errorf("Resolve() index = %v, want %v", urls, want)
+	}
+
+	source.URLs = []string{"sales-${date:20060102}.csv", serverURL + "/exports/stock-${date:20060102}.csv"}
+	urls, err = source.Resolve(context.Background(), now)
+	if err != nil {
+		t.Fatalf("Resolve() error = %v", err)
+	}
+	if !reflect.DeepEqual(urls, want) {
+		t.Errorf("Resolve() list = %v, want %v", urls, want)
+	}
+
+	source.URLs = []string{"sales.csv", "archive/sales.csv"}
+	if _, err := source.Resolve(context.Background(), now); err == nil || !strings.Contains(err.Error(), "both downloaded as sales.csv") {
+		t.Errorf("Resolve() error = %v, want duplicate name", err)
+	}
+
+	source = Source{BaseURL: serverURL + "/exports/", Headers: headers}
+	if _, err := source.Resolve(context.Background(), now); err == nil || !strings.Contains(err.Error(), "401") {
+		t.Errorf("Resolve() error = %v, want 401", err)
+	}
+}
+
+func TestDownload(t *testing.T) {
+	serverURL := serveReports(t)
+	source := Source{Headers: http.Header{"X-Vendor": {"gomft"}}, Auth: AuthBearer, Secret: "secret"}
+	fileURL := serverURL + "/exports/sales-20240314.csv"
+	dir := t.TempDir()
+
+	file, err := source.Download(context.Background(), fileURL, Validators{}, dir)
+	if err != nil {
+		t.Fatalf("Download() error = %v", err)
+	}
+	if file.NotModified || file.Name != "sales-20240314.csv" || file.Size != 15 {
+		t.Errorf("Download() = %+v", file)
+	}
+	if file.Validators.ETag != `"/exports/sales-20240314.csv"` || file.Validators.LastModified != "Thu, 14 Mar 2024 06:00:00 GMT" {
+		t.Errorf("Download() validators = %+v", file.Validators)
+	}
+	if file.MD5 == "" || !file.ModTime.Equal(time.Date(2024, 3, 14, 6, 0, 0, 0, time.UTC)) {
+		t.Errorf("Download() hash %q, modification time %v", file.MD5, file.ModTime)
+	}
+	info, err := os.Stat(filepath.Join(dir, file.Name))
+	if err != nil || info.Size() != 15 || !info.ModTime().Equal(file.ModTime) {
+		t.Errorf("downloaded file = %v, %v", info, err)
+	}
+
+	// The previous version is confirmed by its ETag or its modification time
+	for _, previous := range []Validators{{ETag: file.Validators.ETag}, {LastModified: file.Validators.LastModified}} {
+		notModified, err := source.Download(context.Background(), fileURL, previous, t.TempDir())
+		if err != nil {
+			t.Fatalf("Download(%+v) error = %v", previous, err)
+		}
+		if !notModified.NotModified || notModified.Validators != previous {
+			t.Errorf("Download(%+v) = %+v, want not modified", previous, notModified)
+		}
+	}
+
+	changed, err := source.Download(context.Background(), fileURL, Validators{ETag: `"old"`}, t.TempDir())
+	if err != nil || changed.NotModified || changed.Size != 15 {
+		t.Errorf("Download() of changed file = %+v, %v", changed, err)
+	}
+
+	if _, err := source.Download(context.Background(), serverURL+"/exports/missing.csv", Validators{}, dir); err == nil || !strings.Contains(err.Error(), "404") {
+		t.Errorf("Download() error = %v, want 404", err)
+	}
+}
+
+func TestCheck(t *testing.T) {
+	serverURL := serveReports(t)
+	source := Source{Headers: http.Header{"X-Vendor": {"gomft"}}, Auth: AuthBearer, Secret: "secret"}
+	if err := source.Check(context.Background(), serverURL+"/exports/sales-20240314.csv"); err != nil {
+		t.Errorf("Check() error = %v", err)
+	}
+
+	source.Secret = "wrong"
+	if err := source.Check(context.Background(), serverURL+"/exports/sales-20240314.csv"); err == nil || !strings.Contains(err.Error(), "401") {
+		t.Errorf("Check() error = %v, want 401", err)
+	}
+}
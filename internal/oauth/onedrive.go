@@ -0,0 +1,76 @@
+package oauth
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+)
+
+// OneDrive drive types, as reported by Microsoft Graph and used by the drive_type option of rclone
+const (
+	DriveTypePersonal        = "personal"
+	DriveTypeBusiness        = "business"
+	DriveTypeDocumentLibrary = "documentLibrary"
+)
+
+// oneDrive authorizes with the Microsoft identity platform. A OneDrive remote points at one
+// drive: the personal or business drive of the account, or a SharePoint document library.
+type oneDrive struct {
+	codeFlow
+	graphURL string
+}
+
+// ResolveDrive looks up the drive with the ID, or the default drive of the signed-in account
+func (p *oneDrive) ResolveDrive(ctx context.Context, token *Token, driveID string) (*Drive, error) {
+	endpoint := p.graphURL + "/me/drive"
+	if driveID != "" {
+		endpoint = p.graphURL + "/drives/" + url.PathEscape(driveID)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+token.AccessToken)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up the OneDrive drive: %v", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the OneDrive drive: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to look up the OneDrive drive, status %d: %s", resp.StatusCode, body)
+	}
+
+	var drive struct {
+		ID        string `json:"id"`
+		DriveType string `json:"driveType"`
+	}
+	if err := json.Unmarshal(body, &drive); err != nil {
+		return nil, fmt.Errorf("failed to parse the OneDrive drive: %v", err)
+	}
+	return &Drive{ID: drive.ID, Type: drive.DriveType}, nil
+}
+
+// CheckDriveType returns an error when a drive is not of the type selected in a configuration.
+// OneDrive for Business also covers SharePoint document libraries.
+func CheckDriveType(selected string, drive *Drive) error {
+	switch {
+	case selected == "" || selected == drive.Type:
+		return nil
+	case selected == DriveTypeBusiness && drive.Type == DriveTypeDocumentLibrary:
+		return nil
+	case drive.Type == DriveTypePersonal:
+		return fmt.Errorf("the account has a personal OneDrive, select OneDrive Personal or sign in with a work or school account")
+	default:
+		return fmt.Errorf("the drive is a %s drive, select OneDrive for Business or sign in with a personal Microsoft account", drive.Type)
+	}
+}
@@ -0,0 +1,271 @@
+// Package oauth implements the OAuth 2.0 authorization code flow of the storage providers
+// whose rclone remotes are authorized with a token, such as Google Drive, OneDrive,
+// Dropbox and Box.
+package oauth
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// Token is an OAuth token in the JSON format rclone stores in its config
+type Token struct {
+	AccessToken  string    `json:"access_token"`
+	TokenType    string    `json:"token_type"`
+	RefreshToken string    `json:"refresh_token,omitempty"`
+	Expiry       time.Time `json:"expiry"`
+}
+
+// Client holds the credentials of an OAuth application registered with a provider
+type Client struct {
+	ID     string
+	Secret string
+}
+
+// Provider is a storage provider whose rclone remote is authorized with the OAuth
+// authorization code flow
+type Provider interface {
+	// Name returns the display name of the provider
+	Name() string
+	// RcloneType returns the rclone backend of the provider
+	RcloneType() string
+	// DefaultClient returns the client configured for the provider in the environment
+	DefaultClient() Client
+	// AuthCodeURL returns the consent page the user is sent to
+	AuthCodeURL(client Client, redirectURI, state string) string
+	// Exchange trades the authorization code of the callback for a token
+	Exchange(ctx context.Context, client Client, redirectURI, code string) (*Token, error)
+	// Refresh returns a new token for the refresh token of an expired token
+	Refresh(ctx context.Context, client Client, token *Token) (*Token, error)
+}
+
+// Drive is a drive of an account, OneDrive accounts may have access to several
+type Drive struct {
+	ID   string
+	Type string
+}
+
+// DriveResolver is implemented by providers whose remotes point at one drive of an account
+type DriveResolver interface {
+	// ResolveDrive returns the drive with the ID, or the default drive of the account
+	ResolveDrive(ctx context.Context, token *Token, driveID string) (*Drive, error)
+}
+
+// expiryDelta is how long before its expiry a token is refreshed
+const expiryDelta = 5 * time.Minute
+
+// Expired reports whether the access token has expired or is about to
+func (t *Token) Expired() bool {
+	return !t.Expiry.IsZero() && time.Now().Add(expiryDelta).After(t.Expiry)
+}
+
+// JSON returns the token in the format of the token option of an rclone remote
+func (t *Token) JSON() string {
+	data, _ := json.Marshal(t)
+	return string(data)
+}
+
+// ParseToken parses a token in the format of the token option of an rclone remote
+func ParseToken(data string) (*Token, error) {
+	var token Token
+	if err := json.Unmarshal([]byte(data), &token); err != nil {
+		return nil, fmt.Errorf("invalid token: %v", err)
+	}
+	if token.AccessToken == "" {
+		return nil, fmt.Errorf("invalid token: no access token")
+	}
+	return &token, nil
+}
+
+// providers holds the OAuth providers by the source or destination type of transfer configs
+var providers = map[string]Provider{
+	"gdrive": &codeFlow{
+		name:       "Google Drive",
+		rcloneType: "drive",
+		authURL:    "https://accounts.google.com/o/oauth2/auth",
+		tokenURL:   "https://oauth2.googleapis.com/token",
+		scopes:     []string{"https://www.googleapis.com/auth/drive"},
+		authParams: url.Values{"access_type": {"offline"}, "prompt": {"consent"}},
+		envPrefix:  "GOOGLE",
+		fallback:   rcloneGoogleClient,
+	},
+	"gphotos": &codeFlow{
+		name:       "Google Photos",
+		rcloneType: "google photos",
+		authURL:    "https://accounts.google.com/o/oauth2/auth",
+		tokenURL:   "https://oauth2.googleapis.com/token",
+		scopes:     []string{"https://www.googleapis.com/auth/photoslibrary"},
+		authParams: url.Values{"access_type": {"offline"}, "prompt": {"consent"}},
+		envPrefix:  "GOOGLE",
+		fallback:   rcloneGoogleClient,
+	},
+	"onedrive": &oneDrive{
+		codeFlow: codeFlow{
+			name:       "OneDrive",
+			rcloneType: "onedrive",
+			authURL:    "https://login.microsoftonline.com/common/oauth2/v2.0/authorize",
+			tokenURL:   "https://login.microsoftonline.com/common/oauth2/v2.0/token",
+			scopes:     []string{"Files.Read", "Files.ReadWrite", "Files.Read.All", "Files.ReadWrite.All", "Sites.Read.All", "offline_access"},
+			envPrefix:  "ONEDRIVE",
+		},
+		graphURL: "https://graph.microsoft.com/v1.0",
+	},
+	"dropbox": &codeFlow{
+		name:       "Dropbox",
+		rcloneType: "dropbox",
+		authURL:    "https://www.dropbox.com/oauth2/authorize",
+		tokenURL:   "https://api.dropboxapi.com/oauth2/token",
+		authParams: url.Values{"token_access_type": {"offline"}},
+		envPrefix:  "DROPBOX",
+	},
+	"box": &codeFlow{
+		name:       "Box",
+		rcloneType: "box",
+		authURL:    "https://app.box.com/api/oauth2/authorize",
+		tokenURL:   "https://app.box.com/api/oauth2/token",
+		envPrefix:  "BOX",
+	},
+}
+
+// rcloneGoogleClient is the Google client rclone ships with, used when none is configured
+var rcloneGoogleClient = Client{
+	ID:     "202264815644.apps.googleusercontent.com",
+	Secret: "X4Z3ca8xfWDb1Voo-F9a7ZxJ",
+}
+
+// Get returns the OAuth provider of a source or destination type
+func Get(providerType string) (Provider, bool) {
+	provider, ok := providers[providerType]
+	return provider, ok
+}
+
+// IsOAuthType reports whether remotes of the source or destination type are authorized with OAuth
+func IsOAuthType(providerType string) bool {
+	_, ok := providers[providerType]
+	return ok
+}
+
+// codeFlow is a provider implementing the standard authorization code flow
+type codeFlow struct {
+	name       string
+	rcloneType string
+	authURL    string
+	tokenURL   string
+	scopes     []string
+	authParams url.Values
+	envPrefix  string // Prefix of the <PREFIX>_CLIENT_ID and <PREFIX>_CLIENT_SECRET variables
+	fallback   Client // Used when the environment configures no client
+}
+
+func (p *codeFlow) Name() string       { return p.name }
+func (p *codeFlow) RcloneType() string { return p.rcloneType }
+
+func (p *codeFlow) DefaultClient() Client {
+	client := Client{
+		ID:     os.Getenv(p.envPrefix + "_CLIENT_ID"),
+		Secret: os.Getenv(p.envPrefix + "_CLIENT_SECRET"),
+	}
+	if client.ID == "" || client.Secret == "" {
+		return p.fallback
+	}
+	return client
+}
+
+func (p *codeFlow) AuthCodeURL(client Client, redirectURI, state string) string {
+	params := url.Values{
+		"client_id":     {client.ID},
+		"redirect_uri":  {redirectURI},
+		"response_type": {"code"},
+		"state":         {state},
+	}
+	if len(p.scopes) > 0 {
+		params.Set("scope", strings.Join(p.scopes, " "))
+	}
+	for key, values := range p.authParams {
+		params[key] = values
+	}
+	return p.authURL + "?" + params.Encode()
+}
+
+func (p *codeFlow) Exchange(ctx context.Context, client Client, redirectURI, code string) (*Token, error) {
+	return p.requestToken(ctx, client, url.Values{
+		"grant_type":   {"authorization_code"},
+		"code":         {code},
+		"redirect_uri": {redirectURI},
+	})
+}
+
+func (p *codeFlow) Refresh(ctx context.Context, client Client, token *Token) (*Token, error) {
+	if token.RefreshToken == "" {
+		return nil, fmt.Errorf("the %s token has no refresh token, authorize the configuration again", p.name)
+	}
+	refreshed, err := p.requestToken(ctx, client, url.Values{
+		"grant_type":    {"refresh_token"},
+		"refresh_token": {token.RefreshToken},
+	})
+	if err != nil {
+		return nil, err
+	}
+	// Providers that do not rotate refresh tokens leave it out of the response
+	if refreshed.RefreshToken == "" {
+		refreshed.RefreshToken = token.RefreshToken
+	}
+	return refreshed, nil
+}
+
+// requestToken posts a token request to the token endpoint of the provider
+func (p *codeFlow) requestToken(ctx context.Context, client Client, form url.Values) (*Token, error) {
+	form.Set("client_id", client.ID)
+	form.Set("client_secret", client.Secret)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%s token request failed: %v", p.name, err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s token response: %v", p.name, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s token request failed with status %d: %s", p.name, resp.StatusCode, body)
+	}
+
+	var tokenResp struct {
+		AccessToken  string `json:"access_token"`
+		TokenType    string `json:"token_type"`
+		RefreshToken string `json:"refresh_token"`
+		ExpiresIn    int    `json:"expires_in"`
+	}
+	if err := json.Unmarshal(body, &tokenResp); err != nil {
+		return nil, fmt.Errorf("failed to parse %s token response: %v", p.name, err)
+	}
+	if tokenResp.AccessToken == "" {
+		return nil, fmt.Errorf("%s token response has no access token", p.name)
+	}
+
+	token := &Token{
+		AccessToken:  tokenResp.AccessToken,
+		TokenType:    tokenResp.TokenType,
+		RefreshToken: tokenResp.RefreshToken,
+	}
+	if tokenResp.ExpiresIn > 0 {
+		token.Expiry = time.Now().Add(time.Duration(tokenResp.ExpiresIn) * time.Second)
+	}
+	return token, nil
+}
@@ -0,0 +1,189 @@
+package oauth
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestAuthCodeURL(t *testing.T) {
+	client := Client{ID: "app-id", Secret: "app-secret"}
+	tests := []struct {
+		providerType string
+		wantParams   map[string]string
+	}{
+		{"dropbox", map[string]string{"token_access_type": "offline"}},
+		{"onedrive", map[string]string{"scope": "Files.Read Files.ReadWrite Files.Read.All Files.ReadWrite.All Sites.Read.All offline_access"}},
+		{"gdrive", map[string]string{"access_type": "offline", "scope": "https://www.googleapis.com/auth/drive"}},
+		{"box", map[string]string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.providerType, func(t *testing.T) {
+			provider, ok := Get(tt.providerType)
+			if !ok {
+				t.Fatalf("Get(%q) found no provider", tt.providerType)
+			}
+			authURL, err := url.Parse(provider.AuthCodeURL(client, "https://mft.example.com/configs/oauth-callback", "abc"))
+			if err != nil {
+				t.Fatalf("AuthCodeURL() is not a URL: %v", err)
+			}
+			query := authURL.Query()
+			want := map[string]string{
+				"client_id":     "app-id",
+				"redirect_uri":  "https://mft.example.com/configs/oauth-callback",
+				"response_type": "code",
+				"state":         "abc",
+			}
+			for key, value := range tt.wantParams {
+				want[key] = value
+			}
+			for key, value := range want {
+				if query.Get(key) != value {
+					t.Errorf("AuthCodeURL() %s = %q, want %q", key, query.Get(key), value)
+				}
+			}
+			if query.Get("client_secret") != "" {
+				t.Errorf("AuthCodeURL() must not contain the client secret: %s", authURL)
+			}
+		})
+	}
+
+	if IsOAuthType("s3") {
+		t.Errorf("IsOAuthType(s3) = true, want false")
+	}
+}
+
+func TestExchangeAndRefresh(t *testing.T) {
+	var forms []url.Values
+	refreshToken := "rotated-refresh"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			t.Fatalf("ParseForm() error = %v", err)
+		}
+		forms = append(forms, r.PostForm)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"access_token":  "access-" + r.PostForm.Get("grant_type"),
+			"token_type":    "bearer",
+			"refresh_token": refreshToken,
+			"expires_in":    3600,
+		})
+	}))
+	defer server.Close()
+
+	provider := &codeFlow{name: "Box", rcloneType: "box", tokenURL: server.URL}
+	client := Client{ID: "app-id", Secret: "app-secret"}
+
+	token, err := provider.Exchange(context.Background(), client, "https://mft.example.com/configs/oauth-callback", "the-code")
+	if err != nil {
+		t.Fatalf("Exchange() error = %v", err)
+	}
+	if token.AccessToken != "access-authorization_code" || token.RefreshToken != "rotated-refresh" {
+		t.Errorf("Exchange() token = %+v", token)
+	}
+	if time.Until(token.Expiry) < 59*time.Minute || token.Expired() {
+		t.Errorf("Exchange() expiry = %v, want in an hour", token.Expiry)
+	}
+	if forms[0].Get("code") != "the-code" || forms[0].Get("client_secret") != "app-secret" || forms[0].Get("redirect_uri") == "" {
+		t.Errorf("Exchange() posted %v", forms[0])
+	}
+
+	// Providers that do not rotate refresh tokens leave them out of the response
+	refreshToken = ""
+	token.Expiry = time.Now().Add(time.Minute)
+	if !token.Expired() {
+		t.Fatalf("Expired() = false for a token expiring in a minute")
+	}
+	refreshed, err := provider.Refresh(context.Background(), client, token)
+	if err != nil {
+		t.Fatalf("Refresh() error = %v", err)
+	}
+	if forms[1].Get("grant_type") != "refresh_token" || forms[1].Get("refresh_token") != "rotated-refresh" {
+		t.Errorf("Refresh() posted %v", forms[1])
+	}
+	if refreshed.AccessToken != "access-refresh_token" || refreshed.RefreshToken != "rotated-refresh" {
+		t.Errorf("Refresh() token = %+v", refreshed)
+	}
+
+	parsed, err := ParseToken(refreshed.JSON())
+	if err != nil || parsed.RefreshToken != refreshed.RefreshToken || !parsed.Expiry.Equal(refreshed.Expiry) {
+		t.Errorf("ParseToken(JSON()) = %+v, %v, want %+v", parsed, err, refreshed)
+	}
+
+	if _, err := provider.Refresh(context.Background(), client, &Token{AccessToken: "a"}); err == nil {
+		t.Errorf("Refresh() without a refresh token succeeded")
+	}
+}
+
+func TestExchangeError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"error":"invalid_grant"}`, http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	provider := &codeFlow{name: "Dropbox", tokenURL: server.URL}
+	if _, err := provider.Exchange(context.Background(), Client{ID: "a", Secret: "b"}, "http://localhost", "code"); err == nil {
+		t.Errorf("Exchange() with a rejected code succeeded")
+	}
+}
+
+func TestResolveDrive(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer access" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/me/drive":
+			w.Write([]byte(`{"id":"b!personal","driveType":"personal"}`))
+		case "/drives/b!library":
+			w.Write([]byte(`{"id":"b!library","driveType":"documentLibrary"}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	provider := &oneDrive{codeFlow: codeFlow{name: "OneDrive"}, graphURL: server.URL}
+	token := &Token{AccessToken: "access"}
+
+	drive, err := provider.ResolveDrive(context.Background(), token, "")
+	if err != nil || drive.ID != "b!personal" || drive.Type != DriveTypePersonal {
+		t.Errorf("ResolveDrive() default drive = %+v, %v", drive, err)
+	}
+	drive, err = provider.ResolveDrive(context.Background(), token, "b!library")
+	if err != nil || drive.ID != "b!library" || drive.Type != DriveTypeDocumentLibrary {
+		t.Errorf("ResolveDrive() library = %+v, %v", drive, err)
+	}
+	if _, err := provider.ResolveDrive(context.Background(), token, "missing"); err == nil {
+		t.Errorf("ResolveDrive() of a missing drive succeeded")
+	}
+}
+
+func TestCheckDriveType(t *testing.T) {
+	tests := []struct {
+		selected  string
+		driveType string
+		wantErr   bool
+	}{
+		{"", DriveTypeBusiness, false},
+		{DriveTypePersonal, DriveTypePersonal, false},
+		{DriveTypeBusiness, DriveTypeBusiness, false},
+		{DriveTypeBusiness, DriveTypeDocumentLibrary, false},
+		{DriveTypeBusiness, DriveTypePersonal, true},
+		{DriveTypePersonal, DriveTypeBusiness, true},
+		{DriveTypeDocumentLibrary, DriveTypeBusiness, true},
+	}
+
+	for _, tt := range tests {
+		err := CheckDriveType(tt.selected, &Drive{ID: "id", Type: tt.driveType})
+		if (err != nil) != tt.wantErr {
+			t.Errorf("CheckDriveType(%q, %q) error = %v, wantErr %v", tt.selected, tt.driveType, err, tt.wantErr)
+		}
+	}
+}
@@ -6,13 +6,18 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"net/http"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"strings"
 	"time"
 
+	"github.com/starfleetcptn/gomft/internal/as2"
 	"github.com/starfleetcptn/gomft/internal/db"
+	"github.com/starfleetcptn/gomft/internal/ftps"
+	"github.com/starfleetcptn/gomft/internal/hostkeys"
+	"github.com/starfleetcptn/gomft/internal/httpsource"
 )
 
 // --- Mockable os/exec ---
@@ -26,6 +31,9 @@ var cmdCombinedOutput = (*exec.Cmd).CombinedOutput
 // cmdRun allows mocking the Run method during tests.
 var cmdRun = (*exec.Cmd).Run
 
+// probeFTPS allows mocking the TLS check of FTPS servers during tests.
+var probeFTPS = ftps.Probe
+
 // --- Function Implementation ---
 
 // TestRcloneConnection attempts to connect to a provider using temporary config created via `rclone config create`.
@@ -34,10 +42,27 @@ func TestRcloneConnection(config db.TransferConfig, providerType string, dbInsta
 	var remoteName string
 	var remotePath string
 	var provider string
-	var host, user, pass, keyFile, region, accessKey, secretKey, endpoint, domain, clientID, clientSecret, driveID, teamDrive string
+	var host, user, pass, keyFile, sshKey, region, accessKey, secretKey, endpoint, domain, clientID, clientSecret, driveID, teamDrive string
+	var bucket, share, sasURL string
+	var serviceAccount, project, storageClass, objectACL string
+	var driveType string
+	var hostKey *hostkeys.HostKey
+	var ftpsOpts ftps.Options
+	var crypt bool
+	var cryptPassword, cryptSalt, cryptFilenameEncryption string
 	var port int
 	var err error
 
+	// HTTP sources are downloaded by GoMFT rather than rclone
+	if providerType == "source" && config.SourceType == "http" {
+		return testHTTPSource(config)
+	}
+
+	// AS2 partners are sent files by GoMFT rather than rclone
+	if providerType == "destination" && config.DestinationType == "as2" {
+		return testAS2Destination(config, dbInstance)
+	}
+
 	tempDir, err := os.MkdirTemp("", "gomft-rclone-test-")
 	if err != nil {
 		return false, "Failed to create temp directory for rclone config", err
@@ -54,15 +79,36 @@ func TestRcloneConnection(config db.TransferConfig, providerType string, dbInsta
 		user = config.SourceUser
 		pass = config.SourcePassword
 		keyFile = config.SourceKeyFile
+		sshKey = config.SourceSSHKey
 		region = config.SourceRegion
 		accessKey = config.SourceAccessKey
 		secretKey = config.SourceSecretKey
 		endpoint = config.SourceEndpoint
+		bucket = config.SourceBucket
+		share = config.SourceShare
+		sasURL = config.SourceSasURL
+		serviceAccount = config.SourceServiceAccount
+		project = config.SourceProject
+		storageClass = config.SourceStorageClass
+		objectACL = config.SourceObjectACL
 		domain = config.SourceDomain
 		clientID = config.SourceClientID
 		clientSecret = config.SourceClientSecret
 		driveID = config.SourceDriveID
+		driveType = config.SourceDriveType
 		teamDrive = config.SourceTeamDrive
+		hostKey, err = config.PinnedHostKey("source")
+		ftpsOpts = ftps.Options{
+			Mode:         config.SourceFTPSMode,
+			SkipVerify:   config.GetSourceFTPSSkipVerify(),
+			SessionReuse: config.GetSourceFTPSSessionReuse(),
+			CACert:       config.SourceFTPSCACert,
+			ClientCert:   config.SourceFTPSClientCert,
+		}
+		crypt = config.GetSourceCrypt()
+		cryptPassword = config.SourceCryptPassword
+		cryptSalt = config.SourceCryptSalt
+		cryptFilenameEncryption = config.SourceCryptFilenameEncryption
 	} else if providerType == "destination" {
 		remoteName = "testDest"
 		remotePath = config.DestinationPath
@@ -72,18 +118,42 @@ func TestRcloneConnection(config db.TransferConfig, providerType string, dbInsta
 		user = config.DestUser
 		pass = config.DestPassword
 		keyFile = config.DestKeyFile
+		sshKey = config.DestSSHKey
 		region = config.DestRegion
 		accessKey = config.DestAccessKey
 		secretKey = config.DestSecretKey
 		endpoint = config.DestEndpoint
+		bucket = config.DestBucket
+		share = config.DestShare
+		sasURL = config.DestSasURL
+		serviceAccount = config.DestServiceAccount
+		project = config.DestProject
+		storageClass = config.DestStorageClass
+		objectACL = config.DestObjectACL
 		domain = config.DestDomain
 		clientID = config.DestClientID
 		clientSecret = config.DestClientSecret
 		driveID = config.DestDriveID
+		driveType = config.DestDriveType
 		teamDrive = config.DestTeamDrive
+		hostKey, err = config.PinnedHostKey("dest")
+		ftpsOpts = ftps.Options{
+			Mode:         config.DestFTPSMode,
+			SkipVerify:   config.GetDestFTPSSkipVerify(),
+			SessionReuse: config.GetDestFTPSSessionReuse(),
+			CACert:       config.DestFTPSCACert,
+			ClientCert:   config.DestFTPSClientCert,
+		}
+		crypt = config.GetDestCrypt()
+		cryptPassword = config.DestCryptPassword
+		cryptSalt = config.DestCryptSalt
+		cryptFilenameEncryption = config.DestCryptFilenameEncryption
 	} else {
 		return false, "Invalid provider type specified", fmt.Errorf("unknown provider type: %s", providerType)
 	}
+	if err != nil {
+		return false, "Invalid pinned host key", err
+	}
 
 	rclonePath := os.Getenv("RCLONE_PATH")
 	if rclonePath == "" {
@@ -102,6 +172,10 @@ func TestRcloneConnection(config db.TransferConfig, providerType string, dbInsta
 		primaryProvider = "s3"
 	}
 
+	if provider == "gcs" {
+		primaryProvider = "google cloud storage"
+	}
+
 	createArgs := []string{
 		"config", "create", remoteName, primaryProvider,
 		"--config", tempConfigPath,
@@ -112,6 +186,9 @@ func TestRcloneConnection(config db.TransferConfig, providerType string, dbInsta
 	var ctx context.Context
 	var cancel context.CancelFunc
 	var lsdArgs []string
+	var lsdRemote string
+	var tlsFlags []string
+	var tlsMessage string
 	var stdout, stderr bytes.Buffer
 	var lsdCmd *exec.Cmd
 	var createCmd *exec.Cmd
@@ -125,9 +202,35 @@ func TestRcloneConnection(config db.TransferConfig, providerType string, dbInsta
 		if pass != "" {
 			createArgs = append(createArgs, "pass", pass)
 		}
+		if sshKey != "" {
+			// Managed keys are written to the private temp directory for the test only
+			if dbInstance == nil {
+				return false, "Managed SSH keys require a database", fmt.Errorf("no database to load SSH key %q from", sshKey)
+			}
+			privateKey, err := dbInstance.GetSSHKeyPrivateKey(sshKey)
+			if err != nil {
+				return false, fmt.Sprintf("Failed to load SSH key: %v", err), err
+			}
+			keyFile = filepath.Join(tempDir, "ssh_key")
+			if err := os.WriteFile(keyFile, []byte(privateKey), 0600); err != nil {
+				return false, fmt.Sprintf("Failed to write temporary SSH key file: %v", err), err
+			}
+		}
 		if keyFile != "" {
 			createArgs = append(createArgs, "key_file", keyFile)
 		}
+		// Only accept the pinned host key, as transfers do
+		if hostKey != nil {
+			line, err := hostkeys.KnownHostsLine(host, port, hostKey.PublicKey)
+			if err != nil {
+				return false, "Invalid pinned host key", err
+			}
+			knownHostsPath := filepath.Join(tempDir, "known_hosts")
+			if err := os.WriteFile(knownHostsPath, []byte(line+"\n"), 0600); err != nil {
+				return false, fmt.Sprintf("Failed to write temporary known_hosts file: %v", err), err
+			}
+			createArgs = append(createArgs, "known_hosts_file", knownHostsPath)
+		}
 	case "s3":
 		createArgs = append(createArgs, "provider", "AWS", "env_auth", "false")
 		if accessKey != "" {
@@ -184,6 +287,41 @@ func TestRcloneConnection(config db.TransferConfig, providerType string, dbInsta
 		if region != "" {
 			createArgs = append(createArgs, "region", region)
 		}
+	case "azureblob", "azurefiles":
+		// A SAS URL replaces the account key; the endpoint points at an emulator such as Azurite
+		if sasURL != "" {
+			createArgs = append(createArgs, "sas_url", sasURL)
+		} else {
+			createArgs = append(createArgs, "account", accessKey, "key", secretKey)
+		}
+		if provider == "azurefiles" {
+			createArgs = append(createArgs, "share_name", share)
+		}
+		if endpoint != "" {
+			createArgs = append(createArgs, "endpoint", endpoint)
+		}
+		// Blob paths start with the container
+		if provider == "azureblob" {
+			remotePath = strings.TrimSuffix(bucket+"/"+strings.TrimPrefix(remotePath, "/"), "/")
+		}
+	case "gcs":
+		// The submitted form holds the uploaded key in plain text, it is only encrypted on save
+		if serviceAccount == "" {
+			return false, "Upload the service account key to test the connection", fmt.Errorf("service account key is required")
+		}
+		createArgs = append(createArgs, "service_account_credentials", serviceAccount, "env_auth", "false")
+		if project != "" {
+			createArgs = append(createArgs, "project_number", project)
+		}
+		if storageClass != "" {
+			createArgs = append(createArgs, "storage_class", storageClass)
+		}
+		if objectACL != "" {
+			createArgs = append(createArgs, "object_acl", objectACL)
+		} else {
+			createArgs = append(createArgs, "bucket_policy_only", "true")
+		}
+		remotePath = strings.TrimSuffix(bucket+"/"+strings.TrimPrefix(remotePath, "/"), "/")
 	case "ftp":
 		createArgs = append(createArgs, "host", host, "user", user)
 		if port != 0 {
@@ -197,7 +335,42 @@ func TestRcloneConnection(config db.TransferConfig, providerType string, dbInsta
 		} else {
 			createArgs = append(createArgs, "passive_mode", "false")
 		}
-		createArgs = append(createArgs, "explicit_tls", "true")
+		createArgs = append(createArgs, ftpsOpts.RcloneArgs()...)
+		if ftpsOpts.Enabled() {
+			if err := ftpsOpts.Validate(); err != nil {
+				return false, fmt.Sprintf("Invalid FTPS options: %v", err), err
+			}
+			// Check the TLS setup first, rclone reports certificate problems less clearly
+			probeCtx, probeCancel := context.WithTimeout(context.Background(), 15*time.Second)
+			cert, err := probeFTPS(probeCtx, host, port, ftpsOpts)
+			probeCancel()
+			if err != nil {
+				return false, fmt.Sprintf("Connection test failed: %v", err), err
+			}
+			tlsMessage = fmt.Sprintf(" %s with certificate %s issued by %s, expires %s, SHA-256 fingerprint %s.",
+				cert.TLSVersion, cert.Subject, cert.Issuer, cert.NotAfter.Format("2006-01-02"), cert.Fingerprint)
+
+			// rclone reads certificates through its global options, passed to the listing only
+			if ftpsOpts.CACert != "" {
+				caPath := filepath.Join(tempDir, "ca_cert.pem")
+				if err := os.WriteFile(caPath, []byte(ftpsOpts.CACert), 0600); err != nil {
+					return false, fmt.Sprintf("Failed to write temporary CA certificate file: %v", err), err
+				}
+				tlsFlags = append(tlsFlags, "--ca-cert", caPath)
+			}
+			if ftpsOpts.ClientCert != "" {
+				certs, key, _ := ftps.SplitClientCertificate(ftpsOpts.ClientCert)
+				certPath := filepath.Join(tempDir, "client_cert.pem")
+				keyPath := filepath.Join(tempDir, "client_key.pem")
+				if err := os.WriteFile(certPath, []byte(certs), 0600); err != nil {
+					return false, fmt.Sprintf("Failed to write temporary client certificate file: %v", err), err
+				}
+				if err := os.WriteFile(keyPath, []byte(key), 0600); err != nil {
+					return false, fmt.Sprintf("Failed to write temporary client key file: %v", err), err
+				}
+				tlsFlags = append(tlsFlags, "--client-cert", certPath, "--client-key", keyPath)
+			}
+		}
 	case "smb":
 		createArgs = append(createArgs, "host", host, "user", user)
 		if port != 0 {
@@ -242,6 +415,17 @@ func TestRcloneConnection(config db.TransferConfig, providerType string, dbInsta
 			createArgs = append(createArgs, "client_secret", clientSecret)
 		}
 		log.Println("Warning: Google Photos test may require pre-existing token or manual auth.")
+	case "onedrive", "dropbox", "box":
+		if clientID != "" {
+			createArgs = append(createArgs, "client_id", clientID)
+		}
+		if clientSecret != "" {
+			createArgs = append(createArgs, "client_secret", clientSecret)
+		}
+		if provider == "onedrive" && driveID != "" {
+			createArgs = append(createArgs, "drive_id", driveID, "drive_type", driveType)
+		}
+		log.Printf("Warning: %s test may require pre-existing token or manual auth.", provider)
 	case "local":
 		localConfigContent := fmt.Sprintf("[%s]\ntype = local\nnounc = true\n", remoteName)
 		if err := os.WriteFile(tempConfigPath, []byte(localConfigContent), 0600); err != nil {
@@ -264,16 +448,47 @@ func TestRcloneConnection(config db.TransferConfig, providerType string, dbInsta
 
 RunLsd:
 
+	lsdRemote = fmt.Sprintf("%s:%s", remoteName, remotePath)
+
+	// Layer a crypt remote over the tested path so the password and salt are checked too
+	if crypt {
+		if cryptPassword == "" {
+			return false, "A crypt password is required to test an encrypted remote", fmt.Errorf("missing crypt password")
+		}
+		if cryptFilenameEncryption == "" {
+			cryptFilenameEncryption = "standard"
+		}
+		cryptArgs := []string{
+			"config", "create", remoteName + "Crypt", "crypt",
+			"remote", lsdRemote,
+			"password", cryptPassword,
+			"filename_encryption", cryptFilenameEncryption,
+			"directory_name_encryption", fmt.Sprintf("%t", cryptFilenameEncryption != "off"),
+			"--config", tempConfigPath,
+			"--non-interactive",
+			"--log-level", "DEBUG",
+		}
+		if cryptSalt != "" {
+			cryptArgs = append(cryptArgs, "password2", cryptSalt)
+		}
+		cryptCmd := execCommandContext(context.Background(), rclonePath, cryptArgs...)
+		if output, err := cmdCombinedOutput(cryptCmd); err != nil {
+			return false, fmt.Sprintf("Failed to create temp crypt config section: %v\nOutput: %s", err, string(output)), err
+		}
+		lsdRemote = remoteName + "Crypt:"
+	}
+
 	ctx, cancel = context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
 	lsdArgs = []string{
 		"--config", tempConfigPath,
 		"lsd",
-		fmt.Sprintf("%s:%s", remoteName, remotePath),
+		lsdRemote,
 		"--low-level-retries", "1",
 		"--retries", "1",
 	}
+	lsdArgs = append(lsdArgs, tlsFlags...)
 
 	log.Printf("Executing rclone lsd command: %s %s", rclonePath, strings.Join(lsdArgs, " "))
 	lsdCmd = execCommandContext(ctx, rclonePath, lsdArgs...)
@@ -300,7 +515,9 @@ RunLsd:
 		}
 
 		errMsg := fmt.Sprintf("Connection test failed: %v. Stderr: %s", err, stderrStr)
-		if strings.Contains(stderrStr, "connect: connection refused") {
+		if hostkeys.IsKeyMismatch(stderrStr) {
+			errMsg = "Connection test failed: The server presented a host key that does not match the pinned key."
+		} else if strings.Contains(stderrStr, "connect: connection refused") {
 			errMsg = "Connection test failed: Connection refused by host."
 		} else if strings.Contains(stderrStr, "no such host") || strings.Contains(stderrStr, "name resolution error") {
 			errMsg = "Connection test failed: Hostname not found or DNS resolution error."
@@ -314,5 +531,86 @@ RunLsd:
 		return false, errMsg, err
 	}
 
-	return true, "Connection test successful!", nil
+	return true, "Connection test successful!" + tlsMessage, nil
+}
+
+// testHTTPSource resolves the URLs of an HTTP source with the submitted credentials and
+// requests each file without downloading it
+func testHTTPSource(config db.TransferConfig) (bool, string, error) {
+	headers, err := httpsource.ParseHeaders(config.SourceHTTPHeaders)
+	if err != nil {
+		return false, fmt.Sprintf("Invalid headers: %v", err), err
+	}
+	source := httpsource.Source{
+		BaseURL: config.SourceURL,
+		URLs:    httpsource.ParseURLs(config.SourceURLs),
+		Headers: headers,
+		Auth:    config.SourceHTTPAuth,
+		User:    config.SourceUser,
+		Secret:  config.SourceHTTPSecret,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	urls, err := source.Resolve(ctx, time.Now())
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			return false, "Connection test timed out after 30 seconds.", err
+		}
+		return false, fmt.Sprintf("Connection test failed: %v", err), err
+	}
+	if len(urls) == 0 {
+		return false, "Connection test failed: The index page links to no files.", fmt.Errorf("no files found")
+	}
+	for _, fileURL := range urls {
+		if err := source.Check(ctx, fileURL); err != nil {
+			if errors.Is(err, context.DeadlineExceeded) {
+				return false, "Connection test timed out after 30 seconds.", err
+			}
+			return false, fmt.Sprintf("Connection test failed: %v", err), err
+		}
+	}
+	return true, fmt.Sprintf("Connection test successful! %d files are available.", len(urls)), nil
+}
+
+// testAS2Destination checks the profile of the AS2 partner of a destination and that its
+// endpoint answers. Nothing is sent, as partners process every message they receive.
+func testAS2Destination(config db.TransferConfig, dbInstance *db.DB) (bool, string, error) {
+	if dbInstance == nil {
+		return false, "Connection test failed: The AS2 partner cannot be loaded.", fmt.Errorf("no database")
+	}
+	partner, err := dbInstance.GetAS2PartnerByName(config.DestAS2Partner)
+	if err != nil {
+		return false, fmt.Sprintf("Connection test failed: AS2 partner %q not found.", config.DestAS2Partner), err
+	}
+	local, profile, err := partner.Profile()
+	if err == nil {
+		err = profile.Validate(local)
+	}
+	if err != nil {
+		return false, fmt.Sprintf("Connection test failed: %v", err), err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, profile.URL, nil)
+	if err != nil {
+		return false, fmt.Sprintf("Connection test failed: %v", err), err
+	}
+	req.Header.Set("User-Agent", as2.UserAgent)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			return false, "Connection test timed out after 30 seconds.", err
+		}
+		return false, fmt.Sprintf("Connection test failed: %v", err), err
+	}
+	resp.Body.Close()
+	// AS2 endpoints only accept POST requests, so any answer but a missing page or a server
+	// error shows the endpoint is reachable
+	if resp.StatusCode == http.StatusNotFound || resp.StatusCode >= 500 {
+		return false, fmt.Sprintf("Connection test failed: The AS2 endpoint returned %s.", resp.Status), fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return true, fmt.Sprintf("Connection test successful! The AS2 endpoint of %s is reachable.", partner.AS2ID), nil
 }
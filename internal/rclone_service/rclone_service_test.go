@@ -2,12 +2,27 @@ package rclone_service
 
 import (
 	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
 	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
 	"os/exec"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/glebarez/sqlite"
+	"github.com/starfleetcptn/gomft/internal/as2"
 	"github.com/starfleetcptn/gomft/internal/db"
+	"github.com/starfleetcptn/gomft/internal/ftps"
+	"gorm.io/gorm"
 )
 
 // --- Mock os/exec ---
@@ -296,6 +311,598 @@ func TestTestRcloneConnection_LocalSuccess(t *testing.T) {
 	}
 }
 
-// TODO: Add more tests for other providers (S3, FTP, WebDAV, etc.)
+func TestTestRcloneConnection_CryptDestination(t *testing.T) {
+	tempPath := t.TempDir()
+	crypt := true
+	config := db.TransferConfig{
+		DestinationType:             "local",
+		DestinationPath:             tempPath,
+		DestCrypt:                   &crypt,
+		DestCryptPassword:           "secret",
+		DestCryptSalt:               "pepper",
+		DestCryptFilenameEncryption: "obfuscate",
+	}
+	var dbInstance *db.DB
+	var cryptArgs, lsdArgs []string
+
+	// Capture the arguments of the crypt config create and lsd calls
+	restoreExec := MockExecCommand(func(ctx context.Context, command string, args ...string) *exec.Cmd {
+		switch findRcloneCommand(args) {
+		case "config":
+			cryptArgs = args
+		case "lsd":
+			lsdArgs = args
+		default:
+			t.Fatalf("Unexpected command call: %v", args)
+		}
+		return exec.Command("echo", "mocked")
+	})
+	defer restoreExec()
+
+	originalCombinedOutput := cmdCombinedOutput
+	cmdCombinedOutput = func(c *exec.Cmd) ([]byte, error) {
+		return []byte(""), nil
+	}
+	defer func() { cmdCombinedOutput = originalCombinedOutput }()
+
+	originalRun := cmdRun
+	cmdRun = func(c *exec.Cmd) error {
+		return nil
+	}
+	defer func() { cmdRun = originalRun }()
+
+	success, msg, err := TestRcloneConnection(config, "destination", dbInstance)
+	if err != nil || !success {
+		t.Fatalf("Expected success, got success=%v msg=%q err=%v", success, msg, err)
+	}
+
+	joined := strings.Join(cryptArgs, " ")
+	for _, want := range []string{
+		"create testDestCrypt crypt",
+		"remote testDest:" + tempPath,
+		"password secret",
+		"password2 pepper",
+		"filename_encryption obfuscate",
+	} {
+		if !strings.Contains(joined, want) {
+			t.Errorf("Expected crypt config args to contain %q, got: %s", want, joined)
+		}
+	}
+	if !strings.Contains(strings.Join(lsdArgs, " "), "lsd testDestCrypt:") {
+		t.Errorf("Expected lsd to list the crypt remote, got: %v", lsdArgs)
+	}
+}
+
+func TestTestRcloneConnection_CryptMissingPassword(t *testing.T) {
+	crypt := true
+	config := db.TransferConfig{
+		SourceType:  "local",
+		SourcePath:  t.TempDir(),
+		SourceCrypt: &crypt,
+	}
+	var dbInstance *db.DB
+
+	restoreExec := MockExecCommand(func(ctx context.Context, command string, args ...string) *exec.Cmd {
+		t.Fatalf("Unexpected command call without a crypt password: %v", args)
+		return nil
+	})
+	defer restoreExec()
+
+	success, _, err := TestRcloneConnection(config, "source", dbInstance)
+	if err == nil || success {
+		t.Errorf("Expected failure for crypt remote without password, got success=%v err=%v", success, err)
+	}
+}
+
+// Azurite, the Azure Storage emulator, accepts this well-known development account
+const (
+	azuriteAccount = "devstoreaccount1"
+	azuriteKey     = "Eby8vdM02xNOcqFlqUwJPLlmEtlCDXJ1OUzFT50uSRZ6IFsuFq2UVErCz4I6tq/K1SZFPTOtr/KBHBeksoGMGw=="
+)
+
+func TestTestRcloneConnection_AzureBlob(t *testing.T) {
+	config := db.TransferConfig{
+		SourceType:      "azureblob",
+		SourceBucket:    "inbound",
+		SourcePath:      "/orders",
+		SourceAccessKey: azuriteAccount,
+		SourceSecretKey: azuriteKey,
+		SourceEndpoint:  "http://127.0.0.1:10000/" + azuriteAccount,
+	}
+	var dbInstance *db.DB
+	var createArgs, lsdArgs []string
+
+	restoreExec := MockExecCommand(func(ctx context.Context, command string, args ...string) *exec.Cmd {
+		switch findRcloneCommand(args) {
+		case "config":
+			createArgs = args
+		case "lsd":
+			lsdArgs = args
+		}
+		return exec.Command("echo", "mocked")
+	})
+	defer restoreExec()
+
+	originalCombinedOutput := cmdCombinedOutput
+	cmdCombinedOutput = func(c *exec.Cmd) ([]byte, error) {
+		return []byte(""), nil
+	}
+	defer func() { cmdCombinedOutput = originalCombinedOutput }()
+
+	originalRun := cmdRun
+	cmdRun = func(c *exec.Cmd) error {
+		return nil
+	}
+	defer func() { cmdRun = originalRun }()
+
+	success, msg, err := TestRcloneConnection(config, "source", dbInstance)
+	if err != nil || !success {
+		t.Fatalf("Expected success, got success=%v msg=%q err=%v", success, msg, err)
+	}
+
+	joined := strings.Join(createArgs, " ")
+	for _, want := range []string{
+		"create testSource azureblob",
+		"account " + azuriteAccount,
+		"key " + azuriteKey,
+		"endpoint http://127.0.0.1:10000/" + azuriteAccount,
+	} {
+		if !strings.Contains(joined, want) {
+			t.Errorf("Expected config args to contain %q, got: %s", want, joined)
+		}
+	}
+	if !strings.Contains(strings.Join(lsdArgs, " "), "lsd testSource:inbound/orders ") {
+		t.Errorf("Expected lsd to list the container path, got: %v", lsdArgs)
+	}
+}
+
+func TestTestRcloneConnection_AzureFilesSasURL(t *testing.T) {
+	sasURL := "https://example.file.core.windows.net/?sv=2022-11-02&sig=abc"
+	config := db.TransferConfig{
+		DestinationType: "azurefiles",
+		DestinationPath: "/reports",
+		DestShare:       "exports",
+		DestAccessKey:   "example",
+		DestSasURL:      sasURL,
+	}
+	var dbInstance *db.DB
+	var createArgs, lsdArgs []string
+
+	restoreExec := MockExecCommand(func(ctx context.Context, command string, args ...string) *exec.Cmd {
+		switch findRcloneCommand(args) {
+		case "config":
+			createArgs = args
+		case "lsd":
+			lsdArgs = args
+		}
+		return exec.Command("echo", "mocked")
+	})
+	defer restoreExec()
+
+	originalCombinedOutput := cmdCombinedOutput
+	cmdCombinedOutput = func(c *exec.Cmd) ([]byte, error) {
+		return []byte(""), nil
+	}
+	defer func() { cmdCombinedOutput = originalCombinedOutput }()
+
+	originalRun := cmdRun
+	cmdRun = func(c *exec.Cmd) error {
+		return nil
+	}
+	defer func() { cmdRun = originalRun }()
+
+	success, msg, err := TestRcloneConnection(config, "destination", dbInstance)
+	if err != nil || !success {
+		t.Fatalf("Expected success, got success=%v msg=%q err=%v", success, msg, err)
+	}
+
+	joined := strings.Join(createArgs, " ")
+	for _, want := range []string{"create testDest azurefiles", "sas_url " + sasURL, "share_name exports"} {
+		if !strings.Contains(joined, want) {
+			t.Errorf("Expected config args to contain %q, got: %s", want, joined)
+		}
+	}
+	if strings.Contains(joined, "account ") {
+		t.Errorf("Expected the SAS URL to replace the account key, got: %s", joined)
+	}
+	if !strings.Contains(strings.Join(lsdArgs, " "), "lsd testDest:/reports ") {
+		t.Errorf("Expected lsd to list the share path, got: %v", lsdArgs)
+	}
+}
+
+func TestTestRcloneConnection_GCS(t *testing.T) {
+	serviceAccount := `{"type":"service_account","client_email":"gomft@example.iam.gserviceaccount.com","private_key":"key"}`
+	config := db.TransferConfig{
+		DestinationType:    "gcs",
+		DestBucket:         "archive",
+		DestinationPath:    "/daily",
+		DestServiceAccount: serviceAccount,
+		DestStorageClass:   "COLDLINE",
+	}
+	var dbInstance *db.DB
+	var createArgs, lsdArgs []string
+
+	restoreExec := MockExecCommand(func(ctx context.Context, command string, args ...string) *exec.Cmd {
+		switch findRcloneCommand(args) {
+		case "config":
+			createArgs = args
+		case "lsd":
+			lsdArgs = args
+		}
+		return exec.Command("echo", "mocked")
+	})
+	defer restoreExec()
+
+	originalCombinedOutput := cmdCombinedOutput
+	cmdCombinedOutput = func(c *exec.Cmd) ([]byte, error) {
+		return []byte(""), nil
+	}
+	defer func() { cmdCombinedOutput = originalCombinedOutput }()
+
+	originalRun := cmdRun
+	cmdRun = func(c *exec.Cmd) error {
+		return nil
+	}
+	defer func() { cmdRun = originalRun }()
+
+	success, msg, err := TestRcloneConnection(config, "destination", dbInstance)
+	if err != nil || !success {
+		t.Fatalf("Expected success, got success=%v msg=%q err=%v", success, msg, err)
+	}
+
+	joined := strings.Join(createArgs, " ")
+	for _, want := range []string{
+		"create testDest google cloud storage",
+		"service_account_credentials " + serviceAccount,
+		"storage_class COLDLINE",
+		"bucket_policy_only true",
+	} {
+		if !strings.Contains(joined, want) {
+			t.Errorf("Expected config args to contain %q, got: %s", want, joined)
+		}
+	}
+	if !strings.Contains(strings.Join(lsdArgs, " "), "lsd testDest:archive/daily ") {
+		t.Errorf("Expected lsd to list the bucket path, got: %v", lsdArgs)
+	}
+
+	// Without an uploaded key the connection cannot be tested
+	config.DestServiceAccount = ""
+	if success, _, err := TestRcloneConnection(config, "destination", dbInstance); success || err == nil {
+		t.Errorf("Expected a missing service account key to fail, got success=%v err=%v", success, err)
+	}
+}
+
+func TestTestRcloneConnection_SFTPPinnedHostKey(t *testing.T) {
+	publicKey := "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIOMqqnkVzrm0SdG6UOoqKLsabgH5C9okWi0dh2l9GKJl"
+	config := db.TransferConfig{
+		SourceType:    "sftp",
+		SourceHost:    "sftp.example.com",
+		SourcePort:    2222,
+		SourceUser:    "gomft",
+		SourcePath:    "/outbound",
+		SourceHostKey: "[sftp.example.com]:2222 " + publicKey,
+	}
+	var dbInstance *db.DB
+	var createArgs []string
+	var knownHosts string
+
+	restoreExec := MockExecCommand(func(ctx context.Context, command string, args ...string) *exec.Cmd {
+		if findRcloneCommand(args) == "config" {
+			createArgs = args
+			for i, arg := range args {
+				if arg == "known_hosts_file" && i+1 < len(args) {
+					content, _ := os.ReadFile(args[i+1])
+					knownHosts = string(content)
+				}
+			}
+		}
+		return exec.Command("echo", "mocked")
+	})
+	defer restoreExec()
+
+	originalCombinedOutput := cmdCombinedOutput
+	cmdCombinedOutput = func(c *exec.Cmd) ([]byte, error) {
+		return []byte(""), nil
+	}
+	defer func() { cmdCombinedOutput = originalCombinedOutput }()
+
+	originalRun := cmdRun
+	cmdRun = func(c *exec.Cmd) error {
+		c.Stderr.Write([]byte("couldn't connect SSH: ssh: handshake failed: knownhosts: key mismatch"))
+		return errors.New("exit status 1")
+	}
+	defer func() { cmdRun = originalRun }()
+
+	success, msg, _ := TestRcloneConnection(config, "source", dbInstance)
+	if success || !strings.Contains(msg, "does not match the pinned key") {
+		t.Errorf("Expected a host key mismatch, got success=%v msg=%q", success, msg)
+	}
+	if knownHosts != "[sftp.example.com]:2222 "+publicKey+"\n" {
+		t.Errorf("Expected the pinned key in the known_hosts file, got %q (args %v)", knownHosts, createArgs)
+	}
+
+	// A key set for another server is not checked
+	config.SourcePort = 22
+	createArgs = nil
+	TestRcloneConnection(config, "source", dbInstance)
+	if strings.Contains(strings.Join(createArgs, " "), "known_hosts_file") {
+		t.Errorf("Expected no known_hosts file for another server, got: %v", createArgs)
+	}
+}
+
+// TestTestRcloneConnection_Azurite runs against a real Azurite blob service, for example
+// started with `docker run -p 10000:10000 mcr.microsoft.com/azure-storage/azurite azurite-blob --blobHost 0.0.0.0`
+// and AZURITE_BLOB_ENDPOINT=http://127.0.0.1:10000/devstoreaccount1
+func TestTestRcloneConnection_Azurite(t *testing.T) {
+	endpoint := os.Getenv("AZURITE_BLOB_ENDPOINT")
+	if endpoint == "" {
+		t.Skip("AZURITE_BLOB_ENDPOINT not set")
+	}
+	rclonePath, err := exec.LookPath("rclone")
+	if err != nil {
+		t.Skip("rclone not found in PATH")
+	}
+
+	// Create the container the connection test lists
+	mkdir := exec.Command(rclonePath, "mkdir", ":azureblob:gomft-test/incoming")
+	mkdir.Env = append(os.Environ(),
+		"RCLONE_AZUREBLOB_ACCOUNT="+azuriteAccount,
+		"RCLONE_AZUREBLOB_KEY="+azuriteKey,
+		"RCLONE_AZUREBLOB_ENDPOINT="+endpoint,
+	)
+	if output, err := mkdir.CombinedOutput(); err != nil {
+		t.Fatalf("Failed to create Azurite container: %v\nOutput: %s", err, output)
+	}
+
+	config := db.TransferConfig{
+		SourceType:      "azureblob",
+		SourceBucket:    "gomft-test",
+		SourcePath:      "incoming",
+		SourceAccessKey: azuriteAccount,
+		SourceSecretKey: azuriteKey,
+		SourceEndpoint:  endpoint,
+	}
+	success, msg, err := TestRcloneConnection(config, "source", nil)
+	if err != nil || !success {
+		t.Fatalf("Expected success against Azurite, got success=%v msg=%q err=%v", success, msg, err)
+	}
+}
+
+func TestTestRcloneConnection_FTPS(t *testing.T) {
+	clientCert := selfSignedCertificate(t)
+	config := db.TransferConfig{
+		DestinationType:      "ftp",
+		DestHost:             "ftps.bank.example",
+		DestPort:             990,
+		DestUser:             "gomft",
+		DestPassword:         "secret",
+		DestinationPath:      "/inbound",
+		DestFTPSMode:         ftps.ModeImplicit,
+		DestFTPSCACert:       clientCert,
+		DestFTPSClientCert:   clientCert,
+		DestFTPSSessionReuse: new(bool),
+	}
+	var dbInstance *db.DB
+	var createArgs, lsdArgs []string
+	var probed ftps.Options
+
+	restoreExec := MockExecCommand(func(ctx context.Context, command string, args ...string) *exec.Cmd {
+		switch findRcloneCommand(args) {
+		case "config":
+			createArgs = args
+		case "lsd":
+			lsdArgs = args
+		}
+		return exec.Command("echo", "mocked")
+	})
+	defer restoreExec()
+
+	originalCombinedOutput := cmdCombinedOutput
+	cmdCombinedOutput = func(c *exec.Cmd) ([]byte, error) {
+		return []byte(""), nil
+	}
+	defer func() { cmdCombinedOutput = originalCombinedOutput }()
+
+	originalRun := cmdRun
+	cmdRun = func(c *exec.Cmd) error {
+		return nil
+	}
+	defer func() { cmdRun = originalRun }()
+
+	originalProbe := probeFTPS
+	probeFTPS = func(ctx context.Context, host string, port int, opts ftps.Options) (*ftps.Certificate, error) {
+		probed = opts
+		return &ftps.Certificate{Subject: "CN=ftps.bank.example", Issuer: "CN=Bank CA", Fingerprint: "AB:CD", TLSVersion: "TLS 1.3"}, nil
+	}
+	defer func() { probeFTPS = originalProbe }()
+
+	success, msg, err := TestRcloneConnection(config, "destination", dbInstance)
+	if err != nil || !success {
+		t.Fatalf("Expected success, got success=%v msg=%q err=%v", success, msg, err)
+	}
+	if probed.Mode != ftps.ModeImplicit || probed.ClientCert != clientCert {
+		t.Errorf("Expected the TLS setup to be checked with the form options, got %+v", probed)
+	}
+	if !strings.Contains(msg, "CN=ftps.bank.example issued by CN=Bank CA") {
+		t.Errorf("Expected the server certificate in the message, got %q", msg)
+	}
+
+	joined := strings.Join(createArgs, " ")
+	for _, want := range []string{"create testDest ftp", "port 990", "tls true", "tls_cache_size 0"} {
+		if !strings.Contains(joined, want) {
+			t.Errorf("Expected config args to contain %q, got: %s", want, joined)
+		}
+	}
+	if strings.Contains(joined, "explicit_tls true") || strings.Contains(joined, "no_check_certificate") {
+		t.Errorf("Expected implicit TLS with certificate verification, got: %s", joined)
+	}
+	joined = strings.Join(lsdArgs, " ")
+	for _, want := range []string{"--ca-cert", "--client-cert", "--client-key"} {
+		if !strings.Contains(joined, want) {
+			t.Errorf("Expected lsd args to contain %q, got: %s", want, joined)
+		}
+	}
+
+	// A failed TLS check is reported without running rclone
+	probeFTPS = func(ctx context.Context, host string, port int, opts ftps.Options) (*ftps.Certificate, error) {
+		return nil, errors.New("TLS handshake failed: x509: certificate signed by unknown authority")
+	}
+	lsdArgs = nil
+	success, msg, _ = TestRcloneConnection(config, "destination", dbInstance)
+	if success || !strings.Contains(msg, "unknown authority") || lsdArgs != nil {
+		t.Errorf("Expected the TLS failure to be reported, got success=%v msg=%q lsd=%v", success, msg, lsdArgs)
+	}
+
+	// Plain FTP turns TLS off
+	config.DestFTPSMode = ""
+	createArgs = nil
+	TestRcloneConnection(config, "destination", dbInstance)
+	if !strings.Contains(strings.Join(createArgs, " "), "tls false explicit_tls false") {
+		t.Errorf("Expected plain FTP, got: %v", createArgs)
+	}
+}
+
+// selfSignedCertificate returns a PEM certificate and its private key
+func selfSignedCertificate(t *testing.T) string {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey() error = %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "gomft"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("CreateCertificate() error = %v", err)
+	}
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatalf("MarshalPKCS8PrivateKey() error = %v", err)
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})) +
+		string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER}))
+}
+
+// TODO: Add more tests for other providers (S3, WebDAV, etc.)
 // TODO: Add tests for destination providerType
 // TODO: Add tests for specific error string parsing (connection refused, dir not found)
+
+func TestTestRcloneConnection_HTTPSource(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, pass, ok := r.BasicAuth()
+		if !ok || user != "reports" || pass != "secret" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if r.URL.Path == "/exports/missing.csv" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte("id,amount\n"))
+	}))
+	defer server.Close()
+
+	// rclone is not involved in testing an HTTP source
+	restoreExec := MockExecCommand(func(ctx context.Context, command string, args ...string) *exec.Cmd {
+		t.Errorf("Unexpected rclone call: %v", args)
+		return exec.Command("echo", "mocked")
+	})
+	defer restoreExec()
+
+	config := db.TransferConfig{
+		SourceType:     "http",
+		SourceURL:      server.URL + "/exports/",
+		SourceURLs:     "sales-${date:20060102}.csv\nstock.csv",
+		SourceHTTPAuth: "basic",
+		SourceUser:     "reports",
+	}
+	var dbInstance *db.DB
+
+	config.SourceHTTPSecret = "secret"
+	success, msg, err := TestRcloneConnection(config, "source", dbInstance)
+	if err != nil || !success || !strings.Contains(msg, "2 files are available") {
+		t.Fatalf("Expected success, got success=%v msg=%q err=%v", success, msg, err)
+	}
+
+	config.SourceHTTPSecret = "wrong"
+	success, msg, _ = TestRcloneConnection(config, "source", dbInstance)
+	if success || !strings.Contains(msg, "401 Unauthorized") {
+		t.Errorf("Expected an authentication failure, got success=%v msg=%q", success, msg)
+	}
+
+	config.SourceHTTPSecret = "secret"
+	config.SourceURLs += "\nmissing.csv"
+	success, msg, _ = TestRcloneConnection(config, "source", dbInstance)
+	if success || !strings.Contains(msg, "missing.csv: 404 Not Found") {
+		t.Errorf("Expected the missing file to be reported, got success=%v msg=%q", success, msg)
+	}
+}
+
+func TestTestRcloneConnection_AS2Destination(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/as2" {
+			http.NotFound(w, r)
+			return
+		}
+		http.Error(w, "AS2 messages are posted", http.StatusMethodNotAllowed)
+	}))
+	defer server.Close()
+
+	// rclone is not involved in testing an AS2 destination
+	restoreExec := MockExecCommand(func(ctx context.Context, command string, args ...string) *exec.Cmd {
+		t.Errorf("Unexpected rclone call: %v", args)
+		return exec.Command("echo", "mocked")
+	})
+	defer restoreExec()
+
+	gormDB, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	if err := gormDB.AutoMigrate(&db.AS2Partner{}); err != nil {
+		t.Fatalf("Failed to migrate database: %v", err)
+	}
+	dbInstance := &db.DB{DB: gormDB}
+	certPEM, _, err := as2.GenerateCertificate("PARTNER", 2048, 1)
+	if err != nil {
+		t.Fatalf("GenerateCertificate() error = %v", err)
+	}
+	partner := &db.AS2Partner{Name: "acme", AS2ID: "PARTNER", LocalAS2ID: "GOMFT", URL: server.URL + "/as2",
+		Certificate: certPEM, Encrypt: true, EncryptionAlgorithm: as2.EncryptionAES256, MDNMode: as2.MDNSync}
+	if err := dbInstance.CreateAS2Partner(partner); err != nil {
+		t.Fatalf("CreateAS2Partner() error = %v", err)
+	}
+
+	config := db.TransferConfig{DestinationType: "as2", DestAS2Partner: "acme"}
+	success, msg, err := TestRcloneConnection(config, "destination", dbInstance)
+	if err != nil || !success || !strings.Contains(msg, "PARTNER is reachable") {
+		t.Fatalf("Expected success, got success=%v msg=%q err=%v", success, msg, err)
+	}
+
+	partner.URL = server.URL + "/missing"
+	dbInstance.UpdateAS2Partner(partner)
+	success, msg, _ = TestRcloneConnection(config, "destination", dbInstance)
+	if success || !strings.Contains(msg, "404 Not Found") {
+		t.Errorf("Expected the missing endpoint to be reported, got success=%v msg=%q", success, msg)
+	}
+
+	// Signing needs a local certificate and key, which the partner does not have
+	partner.URL = server.URL + "/as2"
+	partner.Sign = true
+	partner.SigningAlgorithm = as2.SigningSHA256
+	dbInstance.UpdateAS2Partner(partner)
+	success, msg, _ = TestRcloneConnection(config, "destination", dbInstance)
+	if success || !strings.Contains(msg, "local certificate and private key") {
+		t.Errorf("Expected the missing local key to be reported, got success=%v msg=%q", success, msg)
+	}
+
+	config.DestAS2Partner = "unknown"
+	success, msg, _ = TestRcloneConnection(config, "destination", dbInstance)
+	if success || !strings.Contains(msg, "not found") {
+		t.Errorf("Expected the unknown partner to be reported, got success=%v msg=%q", success, msg)
+	}
+}
@@ -0,0 +1,172 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/starfleetcptn/gomft/internal/as2"
+	"github.com/starfleetcptn/gomft/internal/db"
+)
+
+// as2SendTimeout bounds sending a message and waiting for its synchronous MDN
+const as2SendTimeout = 10 * time.Minute
+
+// as2Client posts AS2 messages to partners
+var as2Client = &http.Client{}
+
+// isAS2Destination reports whether the files of a config are sent to an AS2 partner
+func isAS2Destination(config *db.TransferConfig) bool {
+	return config.IsAS2Destination()
+}
+
+// ValidateAS2Destination checks that a config sending to an AS2 partner names one, and
+// rejects the options that act on files kept at the destination, which AS2 does not have
+func ValidateAS2Destination(config *db.TransferConfig) error {
+	if !isAS2Destination(config) {
+		return nil
+	}
+	if config.DestAS2Partner == "" {
+		return fmt.Errorf("an AS2 partner is required")
+	}
+	if checksConflicts(config) {
+		return fmt.Errorf("conflict policies cannot be used with an AS2 destination")
+	}
+	if usesAtomicDelivery(config) {
+		return fmt.Errorf("atomic delivery cannot be used with an AS2 destination")
+	}
+	if usesMarkers(config) {
+		return fmt.Errorf("completion markers cannot be used with an AS2 destination")
+	}
+	_, destination, err := retentionRules(config)
+	if err != nil {
+		return err
+	}
+	if !destination.isEmpty() {
+		return fmt.Errorf("retention cannot be applied to an AS2 destination")
+	}
+	if config.GetDestCrypt() {
+		return fmt.Errorf("an AS2 destination cannot be encrypted with rclone crypt")
+	}
+	return nil
+}
+
+// sendAS2File sends a local file to the AS2 partner of a destination and records the
+// message in the run. With synchronous MDNs the file is only delivered once the MDN
+// confirms the partner received it intact; asynchronous MDNs are matched when they arrive.
+func (te *TransferExecutor) sendAS2File(target deliveryTarget, localPath, destFile string) error {
+	config := target.config
+	partner, err := te.db.GetAS2PartnerByName(config.DestAS2Partner) // Calls interface method
+	if err != nil {
+		return fmt.Errorf("AS2 partner %q not found", config.DestAS2Partner)
+	}
+	local, profile, err := partner.Profile()
+	if err != nil {
+		return fmt.Errorf("AS2 partner %s: %v", partner.Name, err)
+	}
+	payload, err := os.ReadFile(localPath)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %v", destFile, err)
+	}
+	msg, err := as2.Build(local, profile, destFile, payload)
+	if err != nil {
+		return fmt.Errorf("failed to build the AS2 message for %s: %v", destFile, err)
+	}
+
+	record := &db.AS2Message{
+		MessageID:    msg.MessageID,
+		Direction:    db.AS2Outbound,
+		PartnerID:    partner.ID,
+		PartnerName:  partner.Name,
+		ConfigID:     config.ID,
+		FileName:     destFile,
+		FileSize:     int64(len(payload)),
+		MIC:          msg.MIC,
+		MICAlgorithm: msg.MICAlgorithm,
+		Signed:       msg.Signed,
+		Encrypted:    msg.Encrypted,
+		Compressed:   msg.Compressed,
+		MDNMode:      profile.MDN,
+		MDNStatus:    db.MDNStatusPending,
+	}
+	if target.pattern != nil {
+		record.JobHistoryID = target.pattern.runID
+	}
+	if profile.MDN == as2.MDNNone {
+		record.MDNStatus = db.MDNStatusNone
+	}
+	if err := te.db.CreateAS2Message(record); err != nil { // Calls interface method
+		return fmt.Errorf("failed to record the AS2 message for %s: %v", destFile, err)
+	}
+
+	te.logger.LogInfo("Sending %s to AS2 partner %s as message %s", destFile, partner.Name, msg.MessageID)
+	ctx, cancel := context.WithTimeout(context.Background(), as2SendTimeout)
+	defer cancel()
+	mdn, sendErr := as2.Send(ctx, as2Client, profile, msg)
+	if mdn != nil {
+		receivedAt := time.Now()
+		record.MDNDisposition = mdn.Disposition
+		record.MDNReceivedAt = &receivedAt
+		record.MDNStatus = db.MDNStatusProcessed
+	}
+	if sendErr != nil {
+		record.MDNStatus = db.MDNStatusFailed
+		record.ErrorMessage = sendErr.Error()
+	}
+	if err := te.db.UpdateAS2Message(record); err != nil { // Calls interface method
+		te.logger.LogError("Error updating AS2 message %s: %v", msg.MessageID, err)
+	}
+	if sendErr != nil {
+		return fmt.Errorf("failed to send %s to AS2 partner %s: %v", destFile, partner.Name, sendErr)
+	}
+	te.logger.LogInfo("Sent %s to AS2 partner %s, MDN %s", destFile, partner.Name, record.MDNStatus)
+	return nil
+}
+
+// deliverInboundFile delivers a payload received over AS2 to the destination of a config
+// and returns the path it was delivered to
+func (te *TransferExecutor) deliverInboundFile(config *db.TransferConfig, fileName, localPath string) (string, error) {
+	if isAS2Destination(config) {
+		return "", fmt.Errorf("configuration %d sends to an AS2 partner and cannot receive AS2 payloads", config.ID)
+	}
+	rclonePath := os.Getenv("RCLONE_PATH")
+	if rclonePath == "" {
+		rclonePath = "rclone"
+	}
+	if err := te.db.RefreshOAuthTokens(config); err != nil { // Calls interface method
+		return "", err
+	}
+	if err := te.pinHostKeysOnFirstUse(config); err != nil {
+		return "", err
+	}
+	releaseSSHKeys, err := te.db.WriteSSHKeys(config) // Calls interface method
+	if err != nil {
+		return "", err
+	}
+	defer releaseSSHKeys()
+
+	pattern, err := newOutputPattern(config, "", 0, time.Now())
+	if err != nil {
+		return "", err
+	}
+	patternFile := outputPatternFile{name: fileName, modTime: time.Now()}
+	if info, err := os.Stat(localPath); err == nil {
+		patternFile.size = info.Size()
+	}
+	destFile, err := pattern.fileName(patternFile, "")
+	if err != nil {
+		return "", err
+	}
+	target := deliveryTarget{label: mainDestinationLabel, config: config, pattern: pattern}
+	destFile, action, err := te.deliverLocalFile(target, te.db.GetConfigRclonePath(config), rclonePath, localPath, destFile) // Calls interface method
+	if err != nil {
+		return "", err
+	}
+	if action == conflictSkipped {
+		return "", fmt.Errorf("%s already exists at the destination", destFile)
+	}
+	te.logger.LogInfo("Delivered AS2 payload %s to configuration %d", destFile, config.ID)
+	return buildDestPathForDB(config, destFile), nil
+}
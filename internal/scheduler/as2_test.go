@@ -0,0 +1,210 @@
+package scheduler
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/starfleetcptn/gomft/internal/as2"
+	"github.com/starfleetcptn/gomft/internal/db"
+	"gorm.io/gorm"
+)
+
+func TestValidateAS2Destination(t *testing.T) {
+	crypt := true
+	tests := []struct {
+		name    string
+		config  db.TransferConfig
+		wantErr bool
+	}{
+		{"Other destination", db.TransferConfig{DestinationType: "local", ConflictPolicy: "skip"}, false},
+		{"Partner", db.TransferConfig{DestinationType: "as2", DestAS2Partner: "acme"}, false},
+		{"No partner", db.TransferConfig{DestinationType: "as2"}, true},
+		{"Conflict policy", db.TransferConfig{DestinationType: "as2", DestAS2Partner: "acme", ConflictPolicy: "skip"}, true},
+		{"Completion marker", db.TransferConfig{DestinationType: "as2", DestAS2Partner: "acme", MarkerMode: "file"}, true},
+		{"Retention", db.TransferConfig{DestinationType: "as2", DestAS2Partner: "acme", DestRetentionKeepLast: 5}, true},
+		{"Crypt", db.TransferConfig{DestinationType: "as2", DestAS2Partner: "acme", DestCrypt: &crypt}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := ValidateAS2Destination(&tt.config); (err != nil) != tt.wantErr {
+				t.Errorf("ValidateAS2Destination() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// as2Receiver is an AS2 partner that requires encrypted messages and returns
+// synchronous MDNs
+type as2Receiver struct {
+	server   *httptest.Server
+	identity as2.Identity
+	certPEM  string
+	received map[string]string
+}
+
+func newAS2Receiver(t *testing.T) *as2Receiver {
+	t.Helper()
+	certPEM, keyPEM, err := as2.GenerateCertificate("PARTNER", 2048, 1)
+	if err != nil {
+		t.Fatalf("GenerateCertificate() error = %v", err)
+	}
+	cert, _ := as2.ParseCertificate([]byte(certPEM))
+	key, _ := as2.ParsePrivateKey([]byte(keyPEM))
+	r := &as2Receiver{
+		identity: as2.Identity{AS2ID: "PARTNER", Certificate: cert, PrivateKey: key},
+		certPEM:  certPEM,
+		received: map[string]string{},
+	}
+	r.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, _ := io.ReadAll(req.Body)
+		request, err := as2.ParseRequest(req.Header)
+		if err != nil || request.From != "GOMFT" || request.To != "PARTNER" {
+			http.Error(w, "unknown partnership", http.StatusForbidden)
+			return
+		}
+		payload, openErr := request.Open(body, r.identity, as2.Partner{AS2ID: "GOMFT", RequireEncrypted: true})
+		if openErr == nil {
+			r.received[payload.FileName] = string(payload.Data)
+		}
+		mdn, err := as2.BuildMDN(r.identity, request, payload, openErr)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		for name, values := range mdn.Header {
+			w.Header()[name] = values
+		}
+		w.Write(mdn.Body)
+	}))
+	t.Cleanup(r.server.Close)
+	return r
+}
+
+func TestDeliverLocalFile_AS2(t *testing.T) {
+	receiver := newAS2Receiver(t)
+	localPath := filepath.Join(t.TempDir(), "orders.edi")
+	if err := os.WriteFile(localPath, []byte("ISA*00*          *00*~"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		encrypt    bool
+		wantErr    string
+		wantStatus string
+	}{
+		{"Encrypted", true, "", db.MDNStatusProcessed},
+		{"Rejected by the partner", false, "insufficient-message-security", db.MDNStatusFailed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			comps := setupTestExecutor()
+			defer comps.logger.Close()
+			comps.db.GetAS2PartnerByNameFunc = func(name string) (*db.AS2Partner, error) {
+				return &db.AS2Partner{ID: 4, Name: name, AS2ID: "PARTNER", LocalAS2ID: "GOMFT", URL: receiver.server.URL,
+					Certificate: receiver.certPEM, Encrypt: tt.encrypt, EncryptionAlgorithm: as2.EncryptionAES256,
+					Compress: true, MDNMode: as2.MDNSync}, nil
+			}
+			var calls [][]string
+			defer mockRcloneSequence(nil, &calls)()
+
+			config := &db.TransferConfig{ID: 2, DestinationType: "as2", DestAS2Partner: "acme"}
+			target := deliveryTarget{label: mainDestinationLabel, config: config, pattern: &outputPattern{runID: 7}}
+			destFile, _, err := comps.executor.deliverLocalFile(target, "/tmp/rclone.conf", "rclone", localPath, "orders.edi")
+			if tt.wantErr == "" && err != nil || tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Fatalf("deliverLocalFile() error = %v, want %q", err, tt.wantErr)
+			}
+			if len(calls) != 0 {
+				t.Errorf("deliverLocalFile() ran rclone %v", calls)
+			}
+
+			if len(comps.db.as2Messages) != 1 {
+				t.Fatalf("deliverLocalFile() recorded %d AS2 messages, want 1", len(comps.db.as2Messages))
+			}
+			record := comps.db.as2Messages[0]
+			if record.MDNStatus != tt.wantStatus || record.JobHistoryID != 7 || record.PartnerID != 4 || record.MIC == "" || record.MDNReceivedAt == nil {
+				t.Errorf("deliverLocalFile() recorded %+v", record)
+			}
+			if tt.wantErr == "" && (receiver.received[destFile] != "ISA*00*          *00*~" || !record.Encrypted || !record.Compressed) {
+				t.Errorf("partner received %q as %s, record %+v", receiver.received[destFile], destFile, record)
+			}
+			if got := buildDestPathForDB(config, destFile); got != "as2://acme/orders.edi" {
+				t.Errorf("buildDestPathForDB() = %q", got)
+			}
+		})
+	}
+}
+
+func TestExecuteConfigTransfer_AS2Command(t *testing.T) {
+	comps := setupTestExecutor()
+	defer comps.logger.Close()
+	comps.db.GetRcloneCommandFunc = func(id uint) (*db.RcloneCommand, error) {
+		return &db.RcloneCommand{ID: id, Name: "sync"}, nil
+	}
+	var calls [][]string
+	defer mockRcloneSequence(nil, &calls)()
+
+	config := db.TransferConfig{ID: 2, SourceType: "local", SourcePath: "/src", DestinationType: "as2", DestAS2Partner: "acme", CommandID: 3}
+	history := &db.JobHistory{ID: 7, JobID: 1, ConfigID: 2}
+	comps.executor.executeConfigTransfer(db.Job{ID: 1}, config, history)
+
+	if history.Status != "failed" || !strings.Contains(history.ErrorMessage, "needed to send files to an AS2 partner") {
+		t.Errorf("executeConfigTransfer() status = %s: %s", history.Status, history.ErrorMessage)
+	}
+	if len(calls) != 0 {
+		t.Errorf("executeConfigTransfer() ran rclone %v", calls)
+	}
+}
+
+func TestDeliverInboundFile(t *testing.T) {
+	comps := setupTestExecutor()
+	defer comps.logger.Close()
+	var calls [][]string
+	defer mockRcloneSequence(nil, &calls)()
+	localPath := filepath.Join(t.TempDir(), "payload")
+	if err := os.WriteFile(localPath, []byte("UNB+UNOC:3'"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	config := &db.TransferConfig{ID: 3, DestinationType: "local", DestinationPath: "/inbox", OutputPattern: "edi/${filename}.${ext}"}
+	got, err := comps.executor.deliverInboundFile(config, "invoice.edi", localPath)
+	if err != nil {
+		t.Fatalf("deliverInboundFile() error = %v", err)
+	}
+	if got != "/inbox/edi/invoice.edi" {
+		t.Errorf("deliverInboundFile() = %q, want /inbox/edi/invoice.edi", got)
+	}
+	if len(calls) != 1 || !hasArg(calls[0], "copyto") || calls[0][len(calls[0])-2] != localPath || calls[0][len(calls[0])-1] != "dest_3:/inbox/edi/invoice.edi" {
+		t.Errorf("deliverInboundFile() rclone calls = %v", calls)
+	}
+
+	// Payloads cannot be forwarded to another AS2 partner
+	if _, err := comps.executor.deliverInboundFile(&db.TransferConfig{ID: 4, DestinationType: "as2", DestAS2Partner: "acme"}, "invoice.edi", localPath); err == nil {
+		t.Error("deliverInboundFile() to an AS2 destination succeeded, want an error")
+	}
+}
+
+func TestDeliverAS2Payload(t *testing.T) {
+	comps := setupTestJobExecutor()
+	defer comps.logger.Close()
+	comps.db.FirstFunc = func(dest interface{}, conds ...interface{}) *gorm.DB {
+		if config, ok := dest.(*db.TransferConfig); ok {
+			*config = db.TransferConfig{ID: 3, DestinationType: "local", DestinationPath: "/inbox"}
+		}
+		return &gorm.DB{Error: nil}
+	}
+
+	got, err := comps.executor.deliverAS2Payload(3, "invoice.edi", "/tmp/payload")
+	if err != nil || got != "/inbox/invoice.edi" {
+		t.Errorf("deliverAS2Payload() = %q, %v", got, err)
+	}
+	if len(comps.transfer.inboundFiles) != 1 || comps.transfer.inboundFiles[0] != "invoice.edi" {
+		t.Errorf("deliverAS2Payload() delivered %v, want invoice.edi", comps.transfer.inboundFiles)
+	}
+}
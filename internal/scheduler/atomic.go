@@ -0,0 +1,94 @@
+package scheduler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path"
+
+	"github.com/starfleetcptn/gomft/internal/db"
+)
+
+// defaultAtomicSuffix is used when atomic delivery is enabled without a prefix or suffix
+const defaultAtomicSuffix = ".part"
+
+// hasAtomicUploads reports whether files only become visible on a destination once
+// their upload has completed. Renaming is a copy and delete on these backends, so
+// files are uploaded under their final name.
+func hasAtomicUploads(destinationType string) bool {
+	switch destinationType {
+	case "s3", "minio", "b2", "wasabi", "azureblob", "gcs", "gdrive", "gphotos", "onedrive", "dropbox", "box":
+		return true
+	default:
+		return false
+	}
+}
+
+// usesAtomicDelivery reports whether files are uploaded under a temporary name and
+// renamed once complete
+func usesAtomicDelivery(config *db.TransferConfig) bool {
+	return config.GetAtomicDelivery() && !hasAtomicUploads(config.DestinationType)
+}
+
+// uploadName returns the name a file is written to at the destination. With atomic
+// delivery the prefix and suffix are applied to the base name, so the temporary
+// file is created in the same directory as the final file.
+func uploadName(config *db.TransferConfig, destFile string) string {
+	if !usesAtomicDelivery(config) {
+		return destFile
+	}
+	prefix, suffix := config.AtomicTempPrefix, config.AtomicTempSuffix
+	if prefix == "" && suffix == "" {
+		suffix = defaultAtomicSuffix
+	}
+	dir, base := path.Split(destFile)
+	return dir + prefix + base + suffix
+}
+
+// completeDelivery verifies a file uploaded under a temporary name and renames it to
+// its final name. Nothing is done for files uploaded under their final name.
+// The expected size is not checked when it is negative.
+func (te *TransferExecutor) completeDelivery(config *db.TransferConfig, configPath, rclonePath, tempFile, destFile string, expectedSize int64) error {
+	if tempFile == destFile {
+		return nil
+	}
+
+	if config.GetAtomicVerify() && expectedSize >= 0 {
+		listArgs := []string{
+			"--config", configPath,
+			"lsjson",
+			"--files-only",
+			"--no-mimetype",
+			buildDestRemotePath(config, tempFile),
+		}
+		listCmd := execCommandContext(context.Background(), rclonePath, listArgs...)
+		output, err := listCmd.CombinedOutput()
+		if err != nil {
+			return fmt.Errorf("failed to verify %s: %v\nOutput: %s", tempFile, err, string(output))
+		}
+		var entries []map[string]interface{}
+		if err := json.Unmarshal(output, &entries); err != nil {
+			return fmt.Errorf("failed to parse listing of %s: %v", tempFile, err)
+		}
+		if len(entries) != 1 {
+			return fmt.Errorf("uploaded file %s was not found at the destination", tempFile)
+		}
+		if size, _ := entries[0]["Size"].(float64); int64(size) != expectedSize {
+			return fmt.Errorf("uploaded file %s has %d bytes, expected %d", tempFile, int64(size), expectedSize)
+		}
+	}
+
+	// moveto within a remote is a server-side rename on SFTP, FTP, SMB and local paths
+	renameArgs := []string{
+		"--config", configPath,
+		"moveto",
+		buildDestRemotePath(config, tempFile),
+		buildDestRemotePath(config, destFile),
+	}
+	te.logger.LogDebug("Renaming %s to %s for config %d", tempFile, destFile, config.ID)
+	renameCmd := execCommandContext(context.Background(), rclonePath, renameArgs...)
+	if output, err := renameCmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to rename %s to %s: %v\nOutput: %s", tempFile, destFile, err, string(output))
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+package scheduler
+
+import (
+	"testing"
+
+	"github.com/starfleetcptn/gomft/internal/db"
+)
+
+func TestUploadName(t *testing.T) {
+	enabled := true
+	tests := []struct {
+		name   string
+		config db.TransferConfig
+		file   string
+		want   string
+	}{
+		{"Disabled", db.TransferConfig{DestinationType: "sftp"}, "out/report.csv", "out/report.csv"},
+		{"Default suffix", db.TransferConfig{DestinationType: "sftp", AtomicDelivery: &enabled}, "out/report.csv", "out/report.csv.part"},
+		{"Prefix", db.TransferConfig{DestinationType: "local", AtomicDelivery: &enabled, AtomicTempPrefix: "."}, "out/report.csv", "out/.report.csv"},
+		{"Prefix and suffix", db.TransferConfig{DestinationType: "ftp", AtomicDelivery: &enabled, AtomicTempPrefix: "~", AtomicTempSuffix: ".tmp"}, "report.csv", "~report.csv.tmp"},
+		{"Object store", db.TransferConfig{DestinationType: "s3", AtomicDelivery: &enabled}, "out/report.csv", "out/report.csv"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := uploadName(&tt.config, tt.file); got != tt.want {
+				t.Errorf("uploadName() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompleteDelivery(t *testing.T) {
+	enabled := true
+	tests := []struct {
+		name      string
+		verify    bool
+		size      int64
+		outputs   []string
+		wantErr   bool
+		wantCalls int
+	}{
+		{"Rename", false, 10, []string{""}, false, 1},
+		{"Verified", true, 10, []string{`[{"Path":"report.csv.part","Size":10}]`, ""}, false, 2},
+		{"Size mismatch", true, 10, []string{`[{"Path":"report.csv.part","Size":4}]`}, true, 1},
+		{"Missing upload", true, 10, []string{`[]`}, true, 1},
+		{"Unknown size", true, -1, []string{""}, false, 1},
+		{"Rename fails", false, 10, []string{"error:permission denied"}, true, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			comps := setupTestExecutor()
+			defer comps.logger.Close()
+			var calls [][]string
+			defer mockRcloneSequence(tt.outputs, &calls)()
+
+			verify := tt.verify
+			config := &db.TransferConfig{ID: 4, DestinationType: "sftp", DestinationPath: "/drop", AtomicDelivery: &enabled, AtomicVerify: &verify}
+			err := comps.executor.completeDelivery(config, "/tmp/rclone.conf", "rclone", "out/report.csv.part", "out/report.csv", tt.size)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("completeDelivery() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if len(calls) != tt.wantCalls {
+				t.Fatalf("completeDelivery() made %d rclone calls, want %d: %v", len(calls), tt.wantCalls, calls)
+			}
+			if !tt.wantErr {
+				rename := calls[len(calls)-1]
+				if rename[2] != "moveto" || rename[3] != "dest_4:/drop/out/report.csv.part" || rename[4] != "dest_4:/drop/out/report.csv" {
+					t.Errorf("completeDelivery() rename args = %v", rename)
+				}
+			}
+		})
+	}
+
+	// Files uploaded under their final name are left alone
+	comps := setupTestExecutor()
+	defer comps.logger.Close()
+	var calls [][]string
+	defer mockRcloneSequence(nil, &calls)()
+	if err := comps.executor.completeDelivery(&db.TransferConfig{}, "", "rclone", "report.csv", "report.csv", 10); err != nil || len(calls) != 0 {
+		t.Errorf("completeDelivery() without temporary name = %v, %d calls", err, len(calls))
+	}
+}
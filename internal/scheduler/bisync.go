@@ -0,0 +1,131 @@
+package scheduler
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/starfleetcptn/gomft/internal/db"
+)
+
+// bisyncStateFile records the paths the listings in a bisync working directory belong to
+const bisyncStateFile = "gomft_paths"
+
+// bisyncConflictResolutions are the values rclone accepts for --conflict-resolve
+var bisyncConflictResolutions = map[string]bool{
+	"none":    true,
+	"path1":   true,
+	"path2":   true,
+	"newer":   true,
+	"older":   true,
+	"larger":  true,
+	"smaller": true,
+}
+
+// bisyncConflictRegex matches the files bisync reports as changed on both sides
+var bisyncConflictRegex = regexp.MustCompile(`New or changed in both paths\s+-\s+(.+?)\s*$`)
+
+// ValidateBisync checks the bisync options of a config
+func ValidateBisync(config *db.TransferConfig) error {
+	if config.BisyncMaxDelete < 0 || config.BisyncMaxDelete > 100 {
+		return fmt.Errorf("delete threshold must be a percentage between 0 and 100")
+	}
+	if config.BisyncConflictResolve != "" && !bisyncConflictResolutions[config.BisyncConflictResolve] {
+		return fmt.Errorf("unknown conflict resolution %q", config.BisyncConflictResolve)
+	}
+	return nil
+}
+
+// bisyncPaths returns the content of the state file for the current paths of a config
+func bisyncPaths(config *db.TransferConfig) string {
+	return buildSourceRemoteRoot(config) + "\n" + buildDestRemoteRoot(config) + "\n"
+}
+
+// hasArg reports whether a flag is already present in the arguments
+func hasArg(args []string, flag string) bool {
+	for _, arg := range args {
+		if arg == flag || strings.HasPrefix(arg, flag+"=") {
+			return true
+		}
+	}
+	return false
+}
+
+// bisyncArgs adds the managed bisync options to the arguments of a run. The listings are
+// kept in a working directory per config; when it holds no listings for the current
+// paths the run is a first run and is started with --resync.
+func (te *TransferExecutor) bisyncArgs(config *db.TransferConfig, args []string) ([]string, error) {
+	workDir := te.db.GetConfigBisyncDir(config) // Calls interface method
+
+	state, err := os.ReadFile(filepath.Join(workDir, bisyncStateFile))
+	if err != nil || string(state) != bisyncPaths(config) {
+		// Listings of earlier paths are of no use and would be mistaken for the current ones
+		if err := os.RemoveAll(workDir); err != nil {
+			return nil, fmt.Errorf("failed to clear bisync working directory: %v", err)
+		}
+		if !hasArg(args, "--resync") {
+			te.logger.LogInfo("No bisync listings for config %d, running with --resync", config.ID)
+			args = append(args, "--resync")
+		}
+	}
+	if err := os.MkdirAll(workDir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create bisync working directory: %v", err)
+	}
+
+	args = append(args, "--workdir", workDir)
+	if config.BisyncMaxDelete > 0 && !hasArg(args, "--max-delete") {
+		args = append(args, "--max-delete", fmt.Sprintf("%d", config.BisyncMaxDelete))
+	}
+	if config.BisyncConflictResolve != "" && !hasArg(args, "--conflict-resolve") {
+		args = append(args, "--conflict-resolve", config.BisyncConflictResolve)
+	}
+	return args, nil
+}
+
+// saveBisyncState marks the listings in the working directory as belonging to the
+// current paths once a bisync run has completed
+func (te *TransferExecutor) saveBisyncState(config *db.TransferConfig) {
+	statePath := filepath.Join(te.db.GetConfigBisyncDir(config), bisyncStateFile) // Calls interface method
+	if err := os.WriteFile(statePath, []byte(bisyncPaths(config)), 0600); err != nil {
+		te.logger.LogError("Error saving bisync state for config %d: %v; the next run will resync", config.ID, err)
+	}
+}
+
+// bisyncConflicts returns the files a bisync log reports as changed on both sides
+func bisyncConflicts(logContent string) []string {
+	var conflicts []string
+	seen := make(map[string]bool)
+	for _, line := range strings.Split(logContent, "\n") {
+		matches := bisyncConflictRegex.FindStringSubmatch(line)
+		if len(matches) < 2 || seen[matches[1]] {
+			continue
+		}
+		seen[matches[1]] = true
+		conflicts = append(conflicts, matches[1])
+	}
+	return conflicts
+}
+
+// bisyncFailure explains why a bisync run was aborted, or returns an empty string
+// when the output does not match a known bisync abort
+func bisyncFailure(config *db.TransferConfig, output string) string {
+	output = strings.ToLower(output)
+	switch {
+	case strings.Contains(output, "too many deletes"):
+		return fmt.Sprintf("Bisync Safe Abort: the run would have deleted more than %d%% of the files on one side. "+
+			"Check both paths; if the deletes are intended, raise the delete threshold or reset the bisync state.", bisyncMaxDelete(config))
+	case strings.Contains(output, "must run --resync"):
+		return "Bisync Critical Error: the bisync listings cannot be trusted. Check both paths, then reset the bisync state so the next run resyncs."
+	}
+	return ""
+}
+
+// bisyncMaxDelete returns the delete threshold rclone applies to a config
+func bisyncMaxDelete(config *db.TransferConfig) int {
+	if config.BisyncMaxDelete > 0 {
+		return config.BisyncMaxDelete
+	}
+	return 50 // rclone default
+}
@@ -0,0 +1,97 @@
+package scheduler
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/starfleetcptn/gomft/internal/db"
+)
+
+func TestValidateBisync(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  db.TransferConfig
+		wantErr bool
+	}{
+		{"Defaults", db.TransferConfig{}, false},
+		{"Valid options", db.TransferConfig{BisyncMaxDelete: 25, BisyncConflictResolve: "newer"}, false},
+		{"Threshold above 100", db.TransferConfig{BisyncMaxDelete: 120}, true},
+		{"Negative threshold", db.TransferConfig{BisyncMaxDelete: -1}, true},
+		{"Unknown resolution", db.TransferConfig{BisyncConflictResolve: "latest"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := ValidateBisync(&tt.config); (err != nil) != tt.wantErr {
+				t.Errorf("ValidateBisync() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestBisyncArgs(t *testing.T) {
+	comps := setupTestExecutor()
+	defer comps.logger.Close()
+	workDir := t.TempDir()
+	comps.db.GetConfigBisyncDirFunc = func(config *db.TransferConfig) string { return workDir }
+
+	config := &db.TransferConfig{ID: 3, SourceType: "local", SourcePath: "/share", DestinationType: "local", DestinationPath: "/cloud", BisyncMaxDelete: 20, BisyncConflictResolve: "newer"}
+	args, err := comps.executor.bisyncArgs(config, []string{"bisync"})
+	if err != nil {
+		t.Fatalf("bisyncArgs() error = %v", err)
+	}
+	want := "bisync --resync --workdir " + workDir + " --max-delete 20 --conflict-resolve newer"
+	if got := strings.Join(args, " "); got != want {
+		t.Errorf("bisyncArgs() first run = %q, want %q", got, want)
+	}
+
+	// Once a run has completed the listings are reused
+	comps.executor.saveBisyncState(config)
+	args, err = comps.executor.bisyncArgs(config, []string{"bisync"})
+	if err != nil {
+		t.Fatalf("bisyncArgs() error = %v", err)
+	}
+	if hasArg(args, "--resync") {
+		t.Errorf("bisyncArgs() after a completed run = %v, want no --resync", args)
+	}
+
+	// Changing a path makes the listings stale
+	config.DestinationPath = "/other"
+	args, _ = comps.executor.bisyncArgs(config, []string{"bisync"})
+	if !hasArg(args, "--resync") {
+		t.Errorf("bisyncArgs() after a path change = %v, want --resync", args)
+	}
+
+	// Flags set on the command are not overridden
+	args, _ = comps.executor.bisyncArgs(config, []string{"bisync", "--max-delete=5"})
+	if strings.Count(strings.Join(args, " "), "--max-delete") != 1 {
+		t.Errorf("bisyncArgs() with --max-delete flag = %v", args)
+	}
+}
+
+func TestBisyncConflicts(t *testing.T) {
+	log := strings.Join([]string{
+		"2024/06/01 12:00:00 NOTICE: - WARNING  New or changed in both paths  - reports/q2.xlsx",
+		"2024/06/01 12:00:00 NOTICE: - Path1    Renaming Path1 copy  - local{b6816}:/share/reports/q2.xlsx.conflict1",
+		"2024/06/01 12:00:00 NOTICE: - WARNING  New or changed in both paths  - notes.txt ",
+		"2024/06/01 12:00:01 NOTICE: - WARNING  New or changed in both paths  - reports/q2.xlsx",
+		"2024/06/01 12:00:02 INFO  : notes.txt: Copied (new)",
+	}, "\n")
+	got := bisyncConflicts(log)
+	if strings.Join(got, ",") != "reports/q2.xlsx,notes.txt" {
+		t.Errorf("bisyncConflicts() = %v, want [reports/q2.xlsx notes.txt]", got)
+	}
+}
+
+func TestBisyncFailure(t *testing.T) {
+	config := &db.TransferConfig{BisyncMaxDelete: 10}
+	if got := bisyncFailure(config, `ERROR : Safety abort: too many deletes (>10%, 8 of 10) on Path1 "local{b6816}:/share".`); !strings.Contains(got, "Safe Abort") || !strings.Contains(got, "10%") {
+		t.Errorf("bisyncFailure() for too many deletes = %q", got)
+	}
+	if got := bisyncFailure(config, "ERROR : Bisync aborted. Must run --resync to recover."); !strings.Contains(got, "Critical Error") {
+		t.Errorf("bisyncFailure() for a critical error = %q", got)
+	}
+	if got := bisyncFailure(config, "ERROR : connection refused"); got != "" {
+		t.Errorf("bisyncFailure() for other errors = %q, want empty", got)
+	}
+}
@@ -0,0 +1,148 @@
+package scheduler
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/starfleetcptn/gomft/internal/db"
+)
+
+// Change detection modes: how files already delivered by an earlier run are recognised
+// when processed files are skipped. FTP, SMB and many WebDAV servers report no hashes.
+const (
+	changeDetectionHash          = ""                // Compare the hashes reported by the source
+	changeDetectionFingerprint   = "fingerprint"     // Compare size and modification time
+	changeDetectionHighWaterMark = "high_water_mark" // Only transfer files modified after the newest delivered file
+	changeDetectionDownloadHash  = "download_hash"   // Compute missing hashes by downloading the files
+)
+
+// ValidateChangeDetection checks the change detection options of a config
+func ValidateChangeDetection(config *db.TransferConfig) error {
+	switch config.ChangeDetection {
+	case changeDetectionHash, changeDetectionFingerprint, changeDetectionDownloadHash:
+	case changeDetectionHighWaterMark:
+		// Files waiting for their trigger may be older than files delivered meanwhile
+		if usesTriggers(config) {
+			return fmt.Errorf("the high-water mark cannot be combined with trigger files")
+		}
+	default:
+		return fmt.Errorf("unknown change detection mode %q", config.ChangeDetection)
+	}
+	return nil
+}
+
+// changeDetectionName returns the change detection mode of a config for logging
+func changeDetectionName(config *db.TransferConfig) string {
+	if config.ChangeDetection == changeDetectionHash {
+		return "hash"
+	}
+	return config.ChangeDetection
+}
+
+// wasDelivered reports whether a file metadata status is that of a delivered file
+func wasDelivered(status string) bool {
+	return status == "processed" || status == "archived" || status == "deleted" || status == "archived_and_deleted"
+}
+
+// unchangedReason describes why a file is unchanged since an earlier run under the
+// fingerprint and high-water mark modes, or returns "" when it has to be transferred
+func (te *TransferExecutor) unchangedReason(job db.Job, config *db.TransferConfig, fileName string, fileSize int64, entry map[string]interface{}) string {
+	if config.ChangeDetection != changeDetectionFingerprint && config.ChangeDetection != changeDetectionHighWaterMark {
+		return ""
+	}
+	modTime, ok := fileModTime(entry)
+	if !ok {
+		te.logger.LogDebug("No modification time found for file %s; it is transferred as changed", fileName)
+		return ""
+	}
+
+	if config.ChangeDetection == changeDetectionHighWaterMark {
+		if config.HighWaterMark != nil && !modTime.After(*config.HighWaterMark) {
+			return fmt.Sprintf("not modified since the high-water mark %s", config.HighWaterMark.Format(time.RFC3339))
+		}
+		return ""
+	}
+
+	// Modification times are compared to the second, as not every remote or database keeps more
+	previous, err := te.db.GetLatestFileMetadata(job.ID, config.ID, fileName) // Calls interface method
+	if err == nil && wasDelivered(previous.Status) && previous.FileSize == fileSize && previous.ModTime.Unix() == modTime.Unix() {
+		return "size and modification time match previous processing"
+	}
+	return ""
+}
+
+// nextHighWaterMark returns the newest modification time of the delivered files, held
+// back below the oldest file that failed, was skipped or waits for its trigger file so
+// the next run picks it up, and whether it moved past the current mark. Only
+// modification times reported by the listing count.
+func nextHighWaterMark(current *time.Time, files []map[string]interface{}, delivered []*db.FileMetadata, heldBack []string) (time.Time, bool) {
+	modTimes := make(map[string]time.Time, len(files))
+	for _, entry := range files {
+		path, _ := entry["Path"].(string)
+		if modTime, ok := fileModTime(entry); ok {
+			modTimes[path] = modTime
+		}
+	}
+
+	var mark time.Time
+	if current != nil {
+		mark = *current
+	}
+	for _, metadata := range delivered {
+		if modTime, ok := modTimes[metadata.FileName]; ok && modTime.After(mark) {
+			mark = modTime
+		}
+	}
+	for _, fileName := range heldBack {
+		if modTime, ok := modTimes[fileName]; ok && !modTime.After(mark) {
+			mark = modTime.Add(-time.Nanosecond)
+		}
+	}
+
+	if current != nil && !mark.After(*current) {
+		return *current, false
+	}
+	return mark, !mark.IsZero()
+}
+
+// downloadHashes adds an MD5 hash to the lsjson entries the source reported no hash for,
+// computed by rclone downloading the files. It reads every file of the source each run.
+func (te *TransferExecutor) downloadHashes(job db.Job, config *db.TransferConfig, configPath, rclonePath string, filterArgs []string, files []map[string]interface{}) error {
+	missing := make(map[string]map[string]interface{})
+	for _, entry := range files {
+		if path, ok := entry["Path"].(string); ok && entryHash(entry) == "" {
+			missing[path] = entry
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+
+	args := []string{"--config", configPath, "hashsum", "MD5", "--download"}
+	args = append(args, filterArgs...)
+	args = append(args, buildSourceRemoteRoot(config))
+	te.logger.LogDebug("Full hashsum command: %s %v", rclonePath, args)
+	output, err := execCommandContext(context.Background(), rclonePath, args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%v\nOutput: %s", err, string(output))
+	}
+
+	// Each line holds the hash and the path of a file separated by two spaces
+	hashed := 0
+	scanner := bufio.NewScanner(strings.NewReader(string(output)))
+	for scanner.Scan() {
+		hash, path, ok := strings.Cut(scanner.Text(), "  ")
+		if !ok {
+			continue
+		}
+		if entry, ok := missing[path]; ok && hash != "" {
+			entry["Hashes"] = map[string]interface{}{"md5": hash}
+			hashed++
+		}
+	}
+	te.logger.LogInfo("Computed hashes of %d files by download for job %d, config %d", hashed, job.ID, config.ID)
+	return nil
+}
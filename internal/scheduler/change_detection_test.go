@@ -0,0 +1,177 @@
+package scheduler
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/starfleetcptn/gomft/internal/db"
+)
+
+func TestValidateChangeDetection(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  db.TransferConfig
+		wantErr bool
+	}{
+		{"Hashes", db.TransferConfig{}, false},
+		{"Fingerprint", db.TransferConfig{ChangeDetection: "fingerprint"}, false},
+		{"High-water mark", db.TransferConfig{ChangeDetection: "high_water_mark"}, false},
+		{"High-water mark with triggers", db.TransferConfig{ChangeDetection: "high_water_mark", TriggerMode: "file"}, true},
+		{"Unknown mode", db.TransferConfig{ChangeDetection: "ctime"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := ValidateChangeDetection(&tt.config); (err != nil) != tt.wantErr {
+				t.Errorf("ValidateChangeDetection() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNextHighWaterMark(t *testing.T) {
+	files := []map[string]interface{}{
+		{"Path": "a.csv", "ModTime": "2026-01-01T10:00:00Z"},
+		{"Path": "b.csv", "ModTime": "2026-01-01T11:00:00Z"},
+		{"Path": "c.csv", "ModTime": "2026-01-01T12:00:00Z"},
+		{"Path": "d.csv"},
+	}
+	delivered := func(names ...string) []*db.FileMetadata {
+		var metadata []*db.FileMetadata
+		for _, name := range names {
+			metadata = append(metadata, &db.FileMetadata{FileName: name})
+		}
+		return metadata
+	}
+	at := func(hour int) time.Time { return time.Date(2026, 1, 1, hour, 0, 0, 0, time.UTC) }
+	current := at(9)
+
+	tests := []struct {
+		name      string
+		current   *time.Time
+		delivered []*db.FileMetadata
+		heldBack  []string
+		wantMark  time.Time
+		wantMoved bool
+	}{
+		{"Newest delivered file", &current, delivered("a.csv", "c.csv"), nil, at(12), true},
+		{"First run", nil, delivered("b.csv"), nil, at(11), true},
+		{"Held back below a failed file", &current, delivered("a.csv", "c.csv"), []string{"b.csv"}, at(11).Add(-time.Nanosecond), true},
+		{"Oldest file failed", &current, delivered("b.csv", "c.csv"), []string{"a.csv"}, at(10).Add(-time.Nanosecond), true},
+		{"No modification time", &current, delivered("d.csv"), nil, current, false},
+		{"Nothing delivered", nil, nil, []string{"a.csv"}, time.Time{}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mark, moved := nextHighWaterMark(tt.current, files, tt.delivered, tt.heldBack)
+			if !mark.Equal(tt.wantMark) || moved != tt.wantMoved {
+				t.Errorf("nextHighWaterMark() = %v, %v, want %v, %v", mark, moved, tt.wantMark, tt.wantMoved)
+			}
+		})
+	}
+}
+
+func TestExecuteConfigTransfer_ChangeDetection(t *testing.T) {
+	// Neither file has a hash; a.csv is unchanged since it was delivered at 10:00
+	listing := `[{"Path":"a.csv","Size":10,"ModTime":"2026-01-01T10:00:00.25Z"},{"Path":"b.csv","Size":20,"ModTime":"2026-01-01T11:00:00Z"}]`
+	mark := time.Date(2026, 1, 1, 10, 30, 0, 0, time.UTC)
+	tests := []struct {
+		name       string
+		mode       string
+		skip       bool
+		wantCopies int
+		wantMark   bool
+	}{
+		{"Hashes", "", true, 2, false},
+		{"Fingerprint", "fingerprint", true, 1, false},
+		{"High-water mark", "high_water_mark", true, 1, true},
+		{"Processed files not skipped", "fingerprint", false, 2, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			comps := setupTestExecutor()
+			defer comps.logger.Close()
+			comps.db.GetLatestFileMetadataFunc = func(jobID, configID uint, fileName string) (*db.FileMetadata, error) {
+				if fileName != "a.csv" {
+					return nil, errors.New("record not found")
+				}
+				return &db.FileMetadata{FileName: "a.csv", FileSize: 10, ModTime: time.Date(2026, 1, 1, 10, 0, 0, 0, time.UTC), Status: "processed"}, nil
+			}
+			var newMark *time.Time
+			comps.db.UpdateHighWaterMarkFunc = func(configID uint, mark time.Time) error {
+				newMark = &mark
+				return nil
+			}
+			var calls [][]string
+			defer mockRcloneSequence([]string{listing}, &calls)()
+
+			config := db.TransferConfig{ID: 2, SourceType: "local", SourcePath: "/src", DestinationType: "local", DestinationPath: "/dst",
+				ChangeDetection: tt.mode, HighWaterMark: &mark}
+			config.SetSkipProcessedFiles(tt.skip)
+			history := &db.JobHistory{ID: 7, JobID: 1, ConfigID: 2}
+			comps.executor.executeConfigTransfer(db.Job{ID: 1}, config, history)
+
+			if history.Status != "completed" {
+				t.Fatalf("executeConfigTransfer() status = %s: %s", history.Status, history.ErrorMessage)
+			}
+			if copies := len(calls) - 1; copies != tt.wantCopies {
+				t.Errorf("executeConfigTransfer() copied %d files, want %d (calls %v)", copies, tt.wantCopies, calls)
+			}
+			if tt.wantCopies == 1 && calls[1][len(calls[1])-2] != "source_2:/src/b.csv" {
+				t.Errorf("executeConfigTransfer() copied %v, want b.csv", calls[1])
+			}
+			wantMark := time.Date(2026, 1, 1, 11, 0, 0, 0, time.UTC)
+			if (newMark != nil) != tt.wantMark || (newMark != nil && !newMark.Equal(wantMark)) {
+				t.Errorf("executeConfigTransfer() high-water mark = %v, want moved %v to %v", newMark, tt.wantMark, wantMark)
+			}
+		})
+	}
+}
+
+func TestExecuteConfigTransfer_HighWaterMarkSkippedFile(t *testing.T) {
+	comps := setupTestExecutor()
+	defer comps.logger.Close()
+	var newMark *time.Time
+	comps.db.UpdateHighWaterMarkFunc = func(configID uint, mark time.Time) error {
+		newMark = &mark
+		return nil
+	}
+	// a.csv already exists at the destination and is skipped, b.csv is delivered
+	listing := `[{"Path":"a.csv","Size":10,"ModTime":"2026-01-01T10:00:00Z"},{"Path":"b.csv","Size":20,"ModTime":"2026-01-01T11:00:00Z"}]`
+	var calls [][]string
+	defer mockRcloneSequence([]string{listing, "[]", "", `[{"Path":"a.csv","Size":10}]`}, &calls)()
+
+	config := db.TransferConfig{ID: 2, SourceType: "local", SourcePath: "/src", DestinationType: "local", DestinationPath: "/dst",
+		ChangeDetection: "high_water_mark", ConflictPolicy: conflictPolicySkip, MaxConcurrentTransfers: 1}
+	config.SetSkipProcessedFiles(true)
+	history := &db.JobHistory{ID: 7, JobID: 1, ConfigID: 2}
+	comps.executor.executeConfigTransfer(db.Job{ID: 1}, config, history)
+
+	wantMark := time.Date(2026, 1, 1, 10, 0, 0, 0, time.UTC).Add(-time.Nanosecond)
+	if newMark == nil || !newMark.Equal(wantMark) {
+		t.Errorf("executeConfigTransfer() high-water mark = %v, want %v below the skipped file (calls %v)", newMark, wantMark, calls)
+	}
+}
+
+func TestListTransferFiles_DownloadHashes(t *testing.T) {
+	comps := setupTestExecutor()
+	defer comps.logger.Close()
+	var calls [][]string
+	listing := `[{"Path":"a.csv","Size":10},{"Path":"b.csv","Size":20,"Hashes":{"md5":"bbb"}}]`
+	defer mockRcloneSequence([]string{listing, "aaa  a.csv\nbbb  b.csv\n"}, &calls)()
+
+	config := &db.TransferConfig{ID: 2, SourceType: "local", SourcePath: "/src", ChangeDetection: "download_hash"}
+	files, _, _, err := comps.executor.listTransferFiles(db.Job{ID: 1}, config, "/tmp/rclone.conf", "rclone")
+	if err != nil {
+		t.Fatalf("listTransferFiles() error = %v", err)
+	}
+	if len(calls) != 2 || !hasArg(calls[1], "hashsum") || !hasArg(calls[1], "--download") {
+		t.Fatalf("listTransferFiles() rclone calls = %v", calls)
+	}
+	if entryHash(files[0]) != "aaa" || entryHash(files[1]) != "bbb" {
+		t.Errorf("listTransferFiles() hashes = %q, %q, want aaa, bbb", entryHash(files[0]), entryHash(files[1]))
+	}
+}
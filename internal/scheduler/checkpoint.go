@@ -0,0 +1,112 @@
+package scheduler
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/starfleetcptn/gomft/internal/db"
+)
+
+// resumableStatuses are the run statuses from which a run can be resumed
+var resumableStatuses = map[string]bool{
+	"failed":                true,
+	"completed_with_errors": true,
+	"interrupted":           true,
+}
+
+// IsResumable reports whether a run with the given status can be resumed from its checkpoint
+func IsResumable(status string) bool {
+	return resumableStatuses[status]
+}
+
+// runFileStatus maps the status of a file's metadata to its checkpoint state
+func runFileStatus(fileStatus string) string {
+	switch fileStatus {
+	case "error":
+		return db.RunFileFailed
+	case "skipped", fileStatusQuarantined:
+		// A quarantined file is no longer in the source
+		return db.RunFileSkipped
+	default:
+		return db.RunFileCompleted
+	}
+}
+
+// saveCheckpoint stores the planned files of a run and returns the checkpoint ID of
+// each file by path. The run continues without a checkpoint when it cannot be stored.
+func (te *TransferExecutor) saveCheckpoint(job db.Job, config *db.TransferConfig, history *db.JobHistory, files []map[string]interface{}) map[string]uint {
+	runFiles := make([]db.RunFile, 0, len(files))
+	for _, entry := range files {
+		filePath, ok := entry["Path"].(string)
+		if !ok || filePath == "" {
+			continue
+		}
+		size, _ := entry["Size"].(float64)
+		modTime, _ := fileModTime(entry)
+		runFiles = append(runFiles, db.RunFile{
+			JobHistoryID: history.ID,
+			Path:         filePath,
+			Size:         int64(size),
+			Hash:         entryHash(entry),
+			ModTime:      modTime,
+			Status:       db.RunFilePending,
+		})
+	}
+
+	if err := te.db.CreateRunFiles(runFiles); err != nil { // Calls interface method
+		te.logger.LogError("Error saving checkpoint for job %d, config %d: %v; the run cannot be resumed", job.ID, config.ID, err)
+		return nil
+	}
+	te.logger.LogDebug("Saved checkpoint of %d files for job %d, config %d, run %d", len(runFiles), job.ID, config.ID, history.ID)
+
+	ids := make(map[string]uint, len(runFiles))
+	for _, runFile := range runFiles {
+		ids[runFile.Path] = runFile.ID
+	}
+	return ids
+}
+
+// updateCheckpoint records the state of a planned file once it has been handled
+func (te *TransferExecutor) updateCheckpoint(id uint, fileStatus, errorMessage string) {
+	if id == 0 {
+		return
+	}
+	if err := te.db.UpdateRunFileStatus(id, runFileStatus(fileStatus), errorMessage); err != nil { // Calls interface method
+		te.logger.LogError("Error updating checkpoint file %d: %v", id, err)
+	}
+}
+
+// checkpointFiles returns the files of an earlier run that still have to be transferred,
+// in the form of lsjson entries so they are handled like a fresh listing
+func (te *TransferExecutor) checkpointFiles(job db.Job, config *db.TransferConfig, resumedFromID uint) ([]map[string]interface{}, error) {
+	runFiles, err := te.db.GetRunFiles(resumedFromID) // Calls interface method
+	if err != nil {
+		return nil, fmt.Errorf("Checkpoint Error: %v", err)
+	}
+	if len(runFiles) == 0 {
+		return nil, fmt.Errorf("Checkpoint Error: run %d has no checkpoint to resume from", resumedFromID)
+	}
+
+	var files []map[string]interface{}
+	for _, runFile := range runFiles {
+		if runFile.IsDone() {
+			continue
+		}
+		entry := map[string]interface{}{
+			"Path": runFile.Path,
+			"Size": float64(runFile.Size),
+		}
+		if !runFile.ModTime.IsZero() {
+			entry["ModTime"] = runFile.ModTime.Format(time.RFC3339Nano)
+		}
+		if runFile.Hash != "" {
+			// The hash type is not kept; the value is only compared with earlier runs
+			entry["Hashes"] = map[string]interface{}{"sha1": runFile.Hash}
+		}
+		files = append(files, entry)
+	}
+
+	te.logger.LogInfo("Resuming run %d for job %d, config %d: %d of %d files remaining",
+		resumedFromID, job.ID, config.ID, len(files), len(runFiles))
+	return files, nil
+}
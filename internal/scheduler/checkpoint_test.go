@@ -0,0 +1,137 @@
+package scheduler
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/starfleetcptn/gomft/internal/db"
+)
+
+func TestRunFileStatus(t *testing.T) {
+	tests := map[string]string{
+		"processed":            db.RunFileCompleted,
+		"archived_and_deleted": db.RunFileCompleted,
+		"skipped":              db.RunFileSkipped,
+		"error":                db.RunFileFailed,
+	}
+	for status, want := range tests {
+		if got := runFileStatus(status); got != want {
+			t.Errorf("runFileStatus(%q) = %q, want %q", status, got, want)
+		}
+	}
+}
+
+func TestCheckpointFiles(t *testing.T) {
+	comps := setupTestExecutor()
+	defer comps.logger.Close()
+
+	modTime := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	comps.db.GetRunFilesFunc = func(jobHistoryID uint) ([]db.RunFile, error) {
+		if jobHistoryID != 5 {
+			return nil, nil
+		}
+		return []db.RunFile{
+			{ID: 1, Path: "a.csv", Size: 10, Status: db.RunFileCompleted},
+			{ID: 2, Path: "b.csv", Size: 20, Status: db.RunFileSkipped},
+			{ID: 3, Path: "c.csv", Size: 30, Hash: "abc", ModTime: modTime, Status: db.RunFileFailed},
+			{ID: 4, Path: "d.csv", Size: 40, Status: db.RunFilePending},
+		}, nil
+	}
+
+	job := db.Job{ID: 1}
+	config := &db.TransferConfig{ID: 2}
+	files, err := comps.executor.checkpointFiles(job, config, 5)
+	if err != nil {
+		t.Fatalf("checkpointFiles() error = %v", err)
+	}
+	if len(files) != 2 || files[0]["Path"] != "c.csv" || files[1]["Path"] != "d.csv" {
+		t.Fatalf("checkpointFiles() = %v, want c.csv and d.csv", files)
+	}
+	if entryHash(files[0]) != "abc" || files[0]["Size"] != float64(30) {
+		t.Errorf("checkpointFiles() entry = %v", files[0])
+	}
+	if got, ok := fileModTime(files[0]); !ok || !got.Equal(modTime) {
+		t.Errorf("checkpointFiles() mod time = %v, want %v", got, modTime)
+	}
+
+	if _, err := comps.executor.checkpointFiles(job, config, 6); err == nil {
+		t.Error("checkpointFiles() without checkpoint should fail")
+	}
+}
+
+func TestExecuteConfigTransfer_Checkpoint(t *testing.T) {
+	comps := setupTestExecutor()
+	defer comps.logger.Close()
+	var calls [][]string
+	listing := `[{"Path":"a.csv","Size":10},{"Path":"b.csv","Size":20}]`
+	defer mockRcloneSequence([]string{listing, "", ""}, &calls)()
+
+	job := db.Job{ID: 1}
+	config := db.TransferConfig{ID: 2, SourceType: "local", SourcePath: "/src", DestinationType: "local", DestinationPath: "/dst"}
+	history := &db.JobHistory{ID: 7, JobID: 1, ConfigID: 2}
+	comps.executor.executeConfigTransfer(job, config, history)
+
+	if history.Status != "completed" {
+		t.Fatalf("executeConfigTransfer() status = %s: %s", history.Status, history.ErrorMessage)
+	}
+	if len(comps.db.createdRunFiles) != 2 {
+		t.Fatalf("executeConfigTransfer() saved %d checkpoint files, want 2", len(comps.db.createdRunFiles))
+	}
+	for _, runFile := range comps.db.createdRunFiles {
+		if runFile.JobHistoryID != 7 || runFile.Status != db.RunFilePending {
+			t.Errorf("checkpoint file = %+v", runFile)
+		}
+		if status := comps.db.runFileStatuses[runFile.ID]; status != db.RunFileCompleted {
+			t.Errorf("checkpoint file %s status = %q, want %q", runFile.Path, status, db.RunFileCompleted)
+		}
+	}
+}
+
+func TestExecuteConfigTransfer_Resume(t *testing.T) {
+	comps := setupTestExecutor()
+	defer comps.logger.Close()
+	var calls [][]string
+	defer mockRcloneSequence([]string{""}, &calls)()
+
+	comps.db.GetRunFilesFunc = func(jobHistoryID uint) ([]db.RunFile, error) {
+		return []db.RunFile{
+			{ID: 1, JobHistoryID: jobHistoryID, Path: "a.csv", Size: 10, Status: db.RunFileCompleted},
+			{ID: 2, JobHistoryID: jobHistoryID, Path: "b.csv", Size: 20, Status: db.RunFilePending},
+		}, nil
+	}
+
+	job := db.Job{ID: 1}
+	config := db.TransferConfig{ID: 2, SourceType: "local", SourcePath: "/src", DestinationType: "local", DestinationPath: "/dst"}
+	history := &db.JobHistory{ID: 8, JobID: 1, ConfigID: 2, ResumedFromID: 7}
+	comps.executor.executeConfigTransfer(job, config, history)
+
+	if history.Status != "completed" || history.FilesTransferred != 1 {
+		t.Fatalf("executeConfigTransfer() status = %s, files = %d: %s", history.Status, history.FilesTransferred, history.ErrorMessage)
+	}
+	// The source is not listed again and completed files are not transferred again
+	if len(calls) != 1 || calls[0][len(calls[0])-2] != "source_2:/src/b.csv" {
+		t.Errorf("executeConfigTransfer() rclone calls = %v, want a single transfer of b.csv", calls)
+	}
+	if len(comps.db.createdRunFiles) != 1 || comps.db.createdRunFiles[0].Path != "b.csv" || comps.db.createdRunFiles[0].JobHistoryID != 8 {
+		t.Errorf("executeConfigTransfer() resumed checkpoint = %+v", comps.db.createdRunFiles)
+	}
+}
+
+func TestExecuteConfigTransfer_ResumeWithoutCheckpoint(t *testing.T) {
+	comps := setupTestExecutor()
+	defer comps.logger.Close()
+	var calls [][]string
+	defer mockRcloneSequence(nil, &calls)()
+
+	config := db.TransferConfig{ID: 2, SourceType: "local", SourcePath: "/src", DestinationType: "local", DestinationPath: "/dst"}
+	history := &db.JobHistory{ID: 8, JobID: 1, ConfigID: 2, ResumedFromID: 7}
+	comps.executor.executeConfigTransfer(db.Job{ID: 1}, config, history)
+
+	if history.Status != "failed" || !strings.Contains(history.ErrorMessage, "no checkpoint") {
+		t.Errorf("executeConfigTransfer() status = %s: %s", history.Status, history.ErrorMessage)
+	}
+	if len(calls) != 0 {
+		t.Errorf("executeConfigTransfer() rclone calls = %v, want none", calls)
+	}
+}
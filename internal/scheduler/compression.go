@@ -0,0 +1,283 @@
+package scheduler
+
+import (
+	"archive/zip"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/starfleetcptn/gomft/internal/encryption"
+)
+
+// Supported compression types for TransferConfig.CompressionType
+const (
+	compressionNone = ""
+	compressionGzip = "gzip"
+	compressionZstd = "zstd"
+	compressionZip  = "zip"
+)
+
+// stagedFile is a local file in the staging directory that is ready for delivery
+type stagedFile struct {
+	path           string // Local path of the file in the staging directory
+	name           string // Relative file name used to build the destination name
+	extension      string // Extension appended after the output pattern has been applied (e.g. ".gz")
+	originalSize   int64  // Uncompressed size
+	compressedSize int64  // Compressed size, 0 if no compression was involved
+
+	encryption *encryption.Result // Outcome of the encryption or decryption step, nil if none was applied
+}
+
+// compressionExtension returns the file extension for a compression type
+func compressionExtension(compressionType string) string {
+	switch compressionType {
+	case compressionGzip:
+		return ".gz"
+	case compressionZstd:
+		return ".zst"
+	case compressionZip:
+		return ".zip"
+	default:
+		return ""
+	}
+}
+
+// detectCompression returns the compression type of a file based on its extension
+// together with the file name without that extension
+func detectCompression(fileName string) (string, string) {
+	lower := strings.ToLower(fileName)
+	for _, compressionType := range []string{compressionGzip, compressionZstd, compressionZip} {
+		ext := compressionExtension(compressionType)
+		if strings.HasSuffix(lower, ext) {
+			return compressionType, fileName[:len(fileName)-len(ext)]
+		}
+	}
+	if strings.HasSuffix(lower, ".tgz") {
+		return compressionGzip, fileName[:len(fileName)-len(".tgz")] + ".tar"
+	}
+	return compressionNone, fileName
+}
+
+// compressFile compresses srcPath into dstPath using gzip or zstd and returns the compressed size
+func compressFile(srcPath, dstPath, compressionType string) (int64, error) {
+	in, err := os.Open(srcPath)
+	if err != nil {
+		return 0, fmt.Errorf("failed to open file for compression: %v", err)
+	}
+	defer in.Close()
+
+	out, err := os.Create(dstPath)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create compressed file: %v", err)
+	}
+	defer out.Close()
+
+	var writer io.WriteCloser
+	switch compressionType {
+	case compressionGzip:
+		gzWriter := gzip.NewWriter(out)
+		gzWriter.Name = filepath.Base(srcPath)
+		writer = gzWriter
+	case compressionZstd:
+		writer, err = zstd.NewWriter(out)
+		if err != nil {
+			return 0, fmt.Errorf("failed to create zstd writer: %v", err)
+		}
+	default:
+		return 0, fmt.Errorf("unsupported compression type: %s", compressionType)
+	}
+
+	if _, err := io.Copy(writer, in); err != nil {
+		writer.Close()
+		return 0, fmt.Errorf("failed to compress file: %v", err)
+	}
+	if err := writer.Close(); err != nil {
+		return 0, fmt.Errorf("failed to finish compressed file: %v", err)
+	}
+
+	info, err := out.Stat()
+	if err != nil {
+		return 0, fmt.Errorf("failed to stat compressed file: %v", err)
+	}
+	return info.Size(), nil
+}
+
+// decompressFile decompresses a staged file into destDir based on its extension.
+// Zip archives may yield several files. Files without a known compression
+// extension are returned unchanged.
+func decompressFile(file stagedFile, destDir string) ([]stagedFile, error) {
+	compressionType, baseName := detectCompression(file.name)
+	if compressionType == compressionNone {
+		return []stagedFile{file}, nil
+	}
+
+	if compressionType == compressionZip {
+		return extractZip(file, destDir)
+	}
+
+	in, err := os.Open(file.path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file for decompression: %v", err)
+	}
+	defer in.Close()
+
+	var reader io.Reader
+	switch compressionType {
+	case compressionGzip:
+		gzReader, err := gzip.NewReader(in)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read gzip file %s: %v", file.name, err)
+		}
+		defer gzReader.Close()
+		reader = gzReader
+	case compressionZstd:
+		zstdReader, err := zstd.NewReader(in)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read zstd file %s: %v", file.name, err)
+		}
+		defer zstdReader.Close()
+		reader = zstdReader
+	}
+
+	outPath := filepath.Join(destDir, filepath.Base(baseName))
+	size, err := writeStagedFile(outPath, reader)
+	if err != nil {
+		return nil, err
+	}
+
+	return []stagedFile{{
+		path:           outPath,
+		name:           baseName,
+		originalSize:   size,
+		compressedSize: file.originalSize,
+	}}, nil
+}
+
+// extractZip extracts every regular file of a zip archive into destDir.
+// Entries keep their relative path next to the archive's own directory.
+func extractZip(file stagedFile, destDir string) ([]stagedFile, error) {
+	archive, err := zip.OpenReader(file.path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open zip file %s: %v", file.name, err)
+	}
+	defer archive.Close()
+
+	baseDir := filepath.Dir(file.name)
+	var files []stagedFile
+	for _, entry := range archive.File {
+		if entry.FileInfo().IsDir() {
+			continue
+		}
+
+		// Reject entries that would escape the staging directory
+		entryName := filepath.Clean(filepath.FromSlash(entry.Name))
+		if filepath.IsAbs(entryName) || entryName == ".." || strings.HasPrefix(entryName, ".."+string(filepath.Separator)) {
+			return nil, fmt.Errorf("zip file %s contains invalid entry %q", file.name, entry.Name)
+		}
+
+		rc, err := entry.Open()
+		if err != nil {
+			return nil, fmt.Errorf("failed to open zip entry %s: %v", entry.Name, err)
+		}
+		outPath := filepath.Join(destDir, entryName)
+		size, err := writeStagedFile(outPath, rc)
+		rc.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		name := filepath.ToSlash(entryName)
+		if baseDir != "." {
+			name = filepath.ToSlash(filepath.Join(baseDir, entryName))
+		}
+		files = append(files, stagedFile{
+			path:           outPath,
+			name:           name,
+			originalSize:   size,
+			compressedSize: int64(entry.CompressedSize64),
+		})
+	}
+
+	return files, nil
+}
+
+// writeZipBundle writes all staged files into a single zip archive at bundlePath.
+// The compressed size of each entry is recorded on the staged file.
+func writeZipBundle(bundlePath string, files []*stagedFile) (int64, error) {
+	out, err := os.Create(bundlePath)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create zip bundle: %v", err)
+	}
+	defer out.Close()
+
+	zipWriter := zip.NewWriter(out)
+	headers := make([]*zip.FileHeader, len(files))
+	for i, file := range files {
+		info, err := os.Stat(file.path)
+		if err != nil {
+			zipWriter.Close()
+			return 0, fmt.Errorf("failed to stat %s: %v", file.name, err)
+		}
+		header, err := zip.FileInfoHeader(info)
+		if err != nil {
+			zipWriter.Close()
+			return 0, fmt.Errorf("failed to create zip header for %s: %v", file.name, err)
+		}
+		header.Name = file.name + file.extension
+		header.Method = zip.Deflate
+		headers[i] = header
+
+		entryWriter, err := zipWriter.CreateHeader(header)
+		if err != nil {
+			zipWriter.Close()
+			return 0, fmt.Errorf("failed to add %s to zip bundle: %v", file.name, err)
+		}
+		in, err := os.Open(file.path)
+		if err != nil {
+			zipWriter.Close()
+			return 0, fmt.Errorf("failed to open %s: %v", file.name, err)
+		}
+		_, err = io.Copy(entryWriter, in)
+		in.Close()
+		if err != nil {
+			zipWriter.Close()
+			return 0, fmt.Errorf("failed to write %s to zip bundle: %v", file.name, err)
+		}
+	}
+	if err := zipWriter.Close(); err != nil {
+		return 0, fmt.Errorf("failed to finish zip bundle: %v", err)
+	}
+
+	// The zip writer fills in the compressed sizes once all entries are closed
+	for i, file := range files {
+		file.compressedSize = int64(headers[i].CompressedSize64)
+	}
+
+	info, err := out.Stat()
+	if err != nil {
+		return 0, fmt.Errorf("failed to stat zip bundle: %v", err)
+	}
+	return info.Size(), nil
+}
+
+// writeStagedFile writes the content of reader to path, creating parent directories as needed
+func writeStagedFile(path string, reader io.Reader) (int64, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return 0, fmt.Errorf("failed to create staging directory: %v", err)
+	}
+	out, err := os.Create(path)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create staged file: %v", err)
+	}
+	defer out.Close()
+
+	size, err := io.Copy(out, reader)
+	if err != nil {
+		return 0, fmt.Errorf("failed to write staged file: %v", err)
+	}
+	return size, nil
+}
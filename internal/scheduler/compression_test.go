@@ -0,0 +1,240 @@
+package scheduler
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/starfleetcptn/gomft/internal/db"
+)
+
+func TestDetectCompression(t *testing.T) {
+	tests := []struct {
+		fileName     string
+		wantType     string
+		wantBaseName string
+	}{
+		{"report.csv.gz", compressionGzip, "report.csv"},
+		{"report.csv.GZ", compressionGzip, "report.csv"},
+		{"data.json.zst", compressionZstd, "data.json"},
+		{"bundle.zip", compressionZip, "bundle"},
+		{"backup.tgz", compressionGzip, "backup.tar"},
+		{"plain.txt", compressionNone, "plain.txt"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.fileName, func(t *testing.T) {
+			gotType, gotBaseName := detectCompression(tt.fileName)
+			if gotType != tt.wantType || gotBaseName != tt.wantBaseName {
+				t.Errorf("detectCompression(%q) = (%q, %q), want (%q, %q)",
+					tt.fileName, gotType, gotBaseName, tt.wantType, tt.wantBaseName)
+			}
+		})
+	}
+}
+
+func TestCompressDecompressRoundTrip(t *testing.T) {
+	content := []byte(strings.Repeat("GoMFT compression test data\n", 100))
+
+	for _, compressionType := range []string{compressionGzip, compressionZstd} {
+		t.Run(compressionType, func(t *testing.T) {
+			dir := t.TempDir()
+			srcPath := filepath.Join(dir, "data.txt")
+			if err := os.WriteFile(srcPath, content, 0600); err != nil {
+				t.Fatalf("Failed to write source file: %v", err)
+			}
+
+			extension := compressionExtension(compressionType)
+			compressedPath := srcPath + extension
+			size, err := compressFile(srcPath, compressedPath, compressionType)
+			if err != nil {
+				t.Fatalf("compressFile() error = %v", err)
+			}
+			if size <= 0 || size >= int64(len(content)) {
+				t.Errorf("compressFile() size = %d, want between 0 and %d", size, len(content))
+			}
+
+			files, err := decompressFile(stagedFile{
+				path:         compressedPath,
+				name:         "data.txt" + extension,
+				originalSize: size,
+			}, filepath.Join(dir, "out"))
+			if err != nil {
+				t.Fatalf("decompressFile() error = %v", err)
+			}
+			if len(files) != 1 {
+				t.Fatalf("decompressFile() returned %d files, want 1", len(files))
+			}
+			if files[0].name != "data.txt" {
+				t.Errorf("decompressFile() name = %q, want %q", files[0].name, "data.txt")
+			}
+			if files[0].originalSize != int64(len(content)) || files[0].compressedSize != size {
+				t.Errorf("decompressFile() sizes = (%d, %d), want (%d, %d)",
+					files[0].originalSize, files[0].compressedSize, len(content), size)
+			}
+
+			got, err := os.ReadFile(files[0].path)
+			if err != nil {
+				t.Fatalf("Failed to read decompressed file: %v", err)
+			}
+			if !bytes.Equal(got, content) {
+				t.Errorf("Decompressed content does not match original")
+			}
+		})
+	}
+}
+
+func TestZipBundleRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	contents := map[string]string{
+		"a.txt":     "first file",
+		"sub/b.txt": "second file",
+	}
+
+	var files []*stagedFile
+	for name, content := range contents {
+		path := filepath.Join(dir, "in", filepath.FromSlash(name))
+		if _, err := writeStagedFile(path, strings.NewReader(content)); err != nil {
+			t.Fatalf("writeStagedFile() error = %v", err)
+		}
+		files = append(files, &stagedFile{path: path, name: name, originalSize: int64(len(content))})
+	}
+
+	bundlePath := filepath.Join(dir, "bundle.zip")
+	if _, err := writeZipBundle(bundlePath, files); err != nil {
+		t.Fatalf("writeZipBundle() error = %v", err)
+	}
+	for _, file := range files {
+		if file.compressedSize <= 0 {
+			t.Errorf("writeZipBundle() did not record compressed size for %s", file.name)
+		}
+	}
+
+	extracted, err := decompressFile(stagedFile{path: bundlePath, name: "bundle.zip"}, filepath.Join(dir, "out"))
+	if err != nil {
+		t.Fatalf("decompressFile() error = %v", err)
+	}
+	if len(extracted) != len(contents) {
+		t.Fatalf("decompressFile() returned %d files, want %d", len(extracted), len(contents))
+	}
+	for _, file := range extracted {
+		got, err := os.ReadFile(file.path)
+		if err != nil {
+			t.Fatalf("Failed to read extracted file %s: %v", file.name, err)
+		}
+		if string(got) != contents[file.name] {
+			t.Errorf("Extracted %s = %q, want %q", file.name, got, contents[file.name])
+		}
+	}
+}
+
+func TestExtractZipRejectsTraversal(t *testing.T) {
+	dir := t.TempDir()
+	zipPath := filepath.Join(dir, "evil.zip")
+
+	out, err := os.Create(zipPath)
+	if err != nil {
+		t.Fatalf("Failed to create zip file: %v", err)
+	}
+	zipWriter := zip.NewWriter(out)
+	entry, err := zipWriter.Create("../escape.txt")
+	if err != nil {
+		t.Fatalf("Failed to create zip entry: %v", err)
+	}
+	entry.Write([]byte("should not be extracted"))
+	zipWriter.Close()
+	out.Close()
+
+	if _, err := extractZip(stagedFile{path: zipPath, name: "evil.zip"}, filepath.Join(dir, "out")); err == nil {
+		t.Error("extractZip() expected error for entry outside the staging directory")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "escape.txt")); !os.IsNotExist(err) {
+		t.Error("extractZip() wrote a file outside the staging directory")
+	}
+}
+
+func TestStageFileCleanup(t *testing.T) {
+	comps := setupTestExecutor()
+	defer comps.logger.Close()
+
+	fail := false
+	restoreExec := MockExecCommand(func(ctx context.Context, command string, args ...string) *exec.Cmd {
+		if fail {
+			return exec.CommandContext(ctx, "false")
+		}
+		// rclone copyto writes the source file to the last argument
+		if err := os.WriteFile(args[len(args)-1], []byte("a,b,c\n"), 0600); err != nil {
+			t.Fatal(err)
+		}
+		return exec.CommandContext(ctx, "true")
+	})
+	defer restoreExec()
+
+	stagingDir := t.TempDir()
+	config := &db.TransferConfig{ID: 1, CompressionType: compressionGzip}
+	staged, cleanup, err := comps.executor.stageFile(config, nil, "rclone", "rclone.conf", stagingDir, "source_1:/in/a.csv", "a.csv", 6)
+	if err != nil {
+		t.Fatalf("stageFile() error = %v", err)
+	}
+	if _, err := os.Stat(staged[0].path); err != nil {
+		t.Fatalf("staged file missing: %v", err)
+	}
+	cleanup()
+	if entries, _ := os.ReadDir(stagingDir); len(entries) != 0 {
+		t.Errorf("staging directory still holds %d entries after cleanup", len(entries))
+	}
+
+	// A file that cannot be staged leaves nothing behind
+	fail = true
+	if _, _, err := comps.executor.stageFile(config, nil, "rclone", "rclone.conf", stagingDir, "source_1:/in/b.csv", "b.csv", 6); err == nil {
+		t.Fatal("stageFile() of a failed download succeeded")
+	}
+	if entries, _ := os.ReadDir(stagingDir); len(entries) != 0 {
+		t.Errorf("staging directory holds %d entries after a failed download", len(entries))
+	}
+}
+
+func TestDestinationFileName(t *testing.T) {
+	tests := []struct {
+		name      string
+		pattern   string
+		fileName  string
+		extension string
+		want      string
+	}{
+		{"No pattern", "", "report.csv", "", "report.csv"},
+		{"No pattern with extension", "", "report.csv", ".gz", "report.csv.gz"},
+		{"Pattern with extension", "${filename}_out.${ext}", "report.csv", ".zst", "report_out.csv.zst"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pattern := &outputPattern{pattern: tt.pattern}
+			if got, err := pattern.fileName(outputPatternFile{name: tt.fileName}, tt.extension); err != nil || got != tt.want {
+				t.Errorf("fileName() = %q, %v, want %q", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestBundleFileName(t *testing.T) {
+	config := &db.TransferConfig{Name: "nightly"}
+	if got := bundleFileName(config); !strings.HasPrefix(got, "gomft_") || !strings.HasSuffix(got, ".zip") {
+		t.Errorf("bundleFileName() = %q, want default gomft_<timestamp>.zip", got)
+	}
+
+	config.BundleName = "${filename}_export"
+	if got := bundleFileName(config); got != "nightly_export.zip" {
+		t.Errorf("bundleFileName() = %q, want %q", got, "nightly_export.zip")
+	}
+
+	config.BundleName = "archive.ZIP"
+	if got := bundleFileName(config); got != "archive.ZIP" {
+		t.Errorf("bundleFileName() = %q, want %q", got, "archive.ZIP")
+	}
+}
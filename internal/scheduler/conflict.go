@@ -0,0 +1,142 @@
+package scheduler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/starfleetcptn/gomft/internal/db"
+)
+
+// Conflict policies applied when a file already exists at the destination
+const (
+	conflictPolicyOverwrite       = "overwrite"
+	conflictPolicySkip            = "skip"
+	conflictPolicyFail            = "fail"
+	conflictPolicyRenameNumber    = "rename_number"
+	conflictPolicyRenameTimestamp = "rename_timestamp"
+	conflictPolicyVersion         = "version"
+)
+
+// Conflict actions recorded in the file metadata
+const (
+	conflictOverwritten = "overwritten"
+	conflictSkipped     = "skipped"
+	conflictRenamed     = "renamed"
+	conflictVersioned   = "versioned"
+)
+
+// versionsDir is the destination folder that keeps replaced files of the version policy
+const versionsDir = ".versions"
+
+// maxConflictRenames limits the numeric suffixes tried before giving up
+const maxConflictRenames = 1000
+
+// conflictTimestampFormat is used for timestamp suffixes and versioned copies
+const conflictTimestampFormat = "20060102T150405"
+
+// checksConflicts reports whether the destination has to be checked before each file is
+// written. Without a policy files are overwritten without being checked, as before.
+func checksConflicts(config *db.TransferConfig) bool {
+	return config.ConflictPolicy != ""
+}
+
+// suffixedName inserts a suffix between the name and the extension of a file
+func suffixedName(fileName, suffix string) string {
+	ext := path.Ext(path.Base(fileName))
+	return strings.TrimSuffix(fileName, ext) + "_" + suffix + ext
+}
+
+// destinationExists reports whether a file exists at the destination
+func (te *TransferExecutor) destinationExists(config *db.TransferConfig, configPath, rclonePath, destFile string) (bool, error) {
+	listArgs := []string{
+		"--config", configPath,
+		"lsjson",
+		"--files-only",
+		"--no-mimetype",
+		buildDestRemotePath(config, destFile),
+	}
+	listCmd := execCommandContext(context.Background(), rclonePath, listArgs...)
+	output, err := listCmd.CombinedOutput()
+	if err != nil {
+		// rclone reports a missing file or parent directory as not found
+		if strings.Contains(strings.ToLower(string(output)), "not found") {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to check destination for %s: %v\nOutput: %s", destFile, err, string(output))
+	}
+
+	var entries []map[string]interface{}
+	if err := json.Unmarshal(output, &entries); err != nil {
+		return false, fmt.Errorf("failed to parse destination listing for %s: %v", destFile, err)
+	}
+	return len(entries) > 0, nil
+}
+
+// resolveConflict applies the conflict policy of a config before a file is written to
+// the destination. It returns the name to deliver the file to and the action taken,
+// which is empty when there was no conflict. Skipped files must not be delivered.
+func (te *TransferExecutor) resolveConflict(config *db.TransferConfig, configPath, rclonePath, destFile string) (string, string, error) {
+	if !checksConflicts(config) {
+		return destFile, "", nil
+	}
+
+	exists, err := te.destinationExists(config, configPath, rclonePath, destFile)
+	if err != nil {
+		return "", "", err
+	}
+	if !exists {
+		return destFile, "", nil
+	}
+
+	switch config.ConflictPolicy {
+	case conflictPolicyOverwrite:
+		return destFile, conflictOverwritten, nil
+	case conflictPolicySkip:
+		return destFile, conflictSkipped, nil
+	case conflictPolicyFail:
+		return "", "", fmt.Errorf("destination file %s already exists", destFile)
+	case conflictPolicyRenameNumber:
+		for i := 1; i <= maxConflictRenames; i++ {
+			candidate := suffixedName(destFile, fmt.Sprintf("%d", i))
+			exists, err := te.destinationExists(config, configPath, rclonePath, candidate)
+			if err != nil {
+				return "", "", err
+			}
+			if !exists {
+				return candidate, conflictRenamed, nil
+			}
+		}
+		return "", "", fmt.Errorf("no free name found for %s after %d attempts", destFile, maxConflictRenames)
+	case conflictPolicyRenameTimestamp:
+		candidate := suffixedName(destFile, time.Now().Format(conflictTimestampFormat))
+		exists, err := te.destinationExists(config, configPath, rclonePath, candidate)
+		if err != nil {
+			return "", "", err
+		}
+		if exists {
+			return "", "", fmt.Errorf("destination file %s already exists", candidate)
+		}
+		return candidate, conflictRenamed, nil
+	case conflictPolicyVersion:
+		// Keep the existing file in the versions folder, mirroring its directory
+		versionFile := path.Join(versionsDir, suffixedName(destFile, time.Now().Format(conflictTimestampFormat)))
+		moveArgs := []string{
+			"--config", configPath,
+			"moveto",
+			buildDestRemotePath(config, destFile),
+			buildDestRemotePath(config, versionFile),
+		}
+		te.logger.LogDebug("Keeping existing destination file %s as %s for config %d", destFile, versionFile, config.ID)
+		moveCmd := execCommandContext(context.Background(), rclonePath, moveArgs...)
+		if output, err := moveCmd.CombinedOutput(); err != nil {
+			return "", "", fmt.Errorf("failed to keep a version of %s: %v\nOutput: %s", destFile, err, string(output))
+		}
+		return destFile, conflictVersioned, nil
+	default:
+		return "", "", fmt.Errorf("unknown conflict policy %q", config.ConflictPolicy)
+	}
+}
@@ -0,0 +1,125 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"testing"
+
+	"github.com/starfleetcptn/gomft/internal/db"
+)
+
+// mockRcloneSequence mocks rclone so that each call returns the next output. Outputs
+// starting with "error:" make the call fail with the rest of the output.
+func mockRcloneSequence(outputs []string, calls *[][]string) func() {
+	return MockExecCommand(func(ctx context.Context, command string, args ...string) *exec.Cmd {
+		output := "[]"
+		if len(*calls) < len(outputs) {
+			output = outputs[len(*calls)]
+		}
+		*calls = append(*calls, args)
+
+		wantError := "0"
+		if len(output) > 6 && output[:6] == "error:" {
+			output = output[6:]
+			wantError = "1"
+		}
+		cs := []string{"-test.run=TestHelperProcess", "--"}
+		cmd := exec.CommandContext(ctx, os.Args[0], cs...)
+		cmd.Env = []string{
+			"GO_TEST_HELPER_PROCESS=1",
+			fmt.Sprintf("GO_TEST_HELPER_PROCESS_OUTPUT=%s", output),
+			"GO_TEST_HELPER_PROCESS_WANT_ERROR=" + wantError,
+		}
+		return cmd
+	})
+}
+
+func TestSuffixedName(t *testing.T) {
+	tests := map[string]string{
+		"report.csv":         "report_1.csv",
+		"2024/12/report.csv": "2024/12/report_1.csv",
+		"archive.tar.gz":     "archive.tar_1.gz",
+		"README":             "README_1",
+		"dir.d/README":       "dir.d/README_1",
+	}
+	for name, want := range tests {
+		if got := suffixedName(name, "1"); got != want {
+			t.Errorf("suffixedName(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestResolveConflict(t *testing.T) {
+	existing := `[{"Path":"report.csv","Size":10}]`
+	tests := []struct {
+		name       string
+		policy     string
+		outputs    []string
+		wantName   string
+		wantAction string
+		wantErr    bool
+		wantCalls  int
+	}{
+		{"No policy", "", nil, "out/report.csv", "", false, 0},
+		{"No conflict", conflictPolicySkip, []string{"[]"}, "out/report.csv", "", false, 1},
+		{"Missing directory", conflictPolicyFail, []string{"error:directory not found"}, "out/report.csv", "", false, 1},
+		{"Overwrite", conflictPolicyOverwrite, []string{existing}, "out/report.csv", conflictOverwritten, false, 1},
+		{"Skip", conflictPolicySkip, []string{existing}, "out/report.csv", conflictSkipped, false, 1},
+		{"Fail", conflictPolicyFail, []string{existing}, "", "", true, 1},
+		{"Rename with number", conflictPolicyRenameNumber, []string{existing, existing, "[]"}, "out/report_2.csv", conflictRenamed, false, 3},
+		{"Listing error", conflictPolicySkip, []string{"error:permission denied"}, "", "", true, 1},
+		{"Unknown policy", "merge", []string{existing}, "", "", true, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			comps := setupTestExecutor()
+			defer comps.logger.Close()
+			var calls [][]string
+			defer mockRcloneSequence(tt.outputs, &calls)()
+
+			config := &db.TransferConfig{ID: 2, DestinationType: "sftp", DestinationPath: "/upload", ConflictPolicy: tt.policy}
+			name, action, err := comps.executor.resolveConflict(config, "/tmp/rclone.conf", "rclone", "out/report.csv")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("resolveConflict() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if name != tt.wantName || action != tt.wantAction {
+				t.Errorf("resolveConflict() = %q, %q, want %q, %q", name, action, tt.wantName, tt.wantAction)
+			}
+			if len(calls) != tt.wantCalls {
+				t.Errorf("resolveConflict() made %d rclone calls, want %d: %v", len(calls), tt.wantCalls, calls)
+			}
+		})
+	}
+}
+
+func TestResolveConflict_TimestampAndVersion(t *testing.T) {
+	comps := setupTestExecutor()
+	defer comps.logger.Close()
+	existing := `[{"Path":"report.csv","Size":10}]`
+	config := &db.TransferConfig{ID: 2, DestinationType: "sftp", DestinationPath: "/upload", ConflictPolicy: conflictPolicyRenameTimestamp}
+
+	var calls [][]string
+	restore := mockRcloneSequence([]string{existing, "[]"}, &calls)
+	name, action, err := comps.executor.resolveConflict(config, "/tmp/rclone.conf", "rclone", "out/report.csv")
+	restore()
+	if err != nil || action != conflictRenamed || !regexp.MustCompile(`^out/report_\d{8}T\d{6}\.csv$`).MatchString(name) {
+		t.Errorf("resolveConflict() with timestamp = %q, %q, %v", name, action, err)
+	}
+
+	calls = nil
+	config.ConflictPolicy = conflictPolicyVersion
+	restore = mockRcloneSequence([]string{existing, ""}, &calls)
+	name, action, err = comps.executor.resolveConflict(config, "/tmp/rclone.conf", "rclone", "out/report.csv")
+	restore()
+	if err != nil || action != conflictVersioned || name != "out/report.csv" {
+		t.Fatalf("resolveConflict() with version = %q, %q, %v", name, action, err)
+	}
+	if len(calls) != 2 || calls[1][2] != "moveto" || calls[1][3] != "dest_2:/upload/out/report.csv" ||
+		!regexp.MustCompile(`^dest_2:/upload/\.versions/out/report_\d{8}T\d{6}\.csv$`).MatchString(calls[1][4]) {
+		t.Errorf("resolveConflict() version calls = %v", calls)
+	}
+}
@@ -0,0 +1,95 @@
+package scheduler
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/starfleetcptn/gomft/internal/db"
+)
+
+// Dedupe scopes: files are duplicates when their content was already delivered by the
+// same job, the same config, any config with the same main destination, or any config
+const (
+	dedupeScopeJob         = "job"
+	dedupeScopeConfig      = "config"
+	dedupeScopeDestination = "destination"
+	dedupeScopeGlobal      = "global"
+)
+
+// Dedupe actions
+const (
+	dedupeActionSkip  = "skip"  // Suppress duplicates
+	dedupeActionAlert = "alert" // Deliver duplicates and report them
+)
+
+// fileStatusDuplicate is the status of the file metadata of suppressed duplicates
+const fileStatusDuplicate = "duplicate"
+
+// usesDedupe reports whether files are checked for duplicates of delivered content
+func usesDedupe(config *db.TransferConfig) bool {
+	return config.DedupeScope != ""
+}
+
+// ValidateDedupe checks the deduplication options of a config
+func ValidateDedupe(config *db.TransferConfig) error {
+	switch config.DedupeScope {
+	case "", dedupeScopeJob, dedupeScopeConfig, dedupeScopeDestination, dedupeScopeGlobal:
+	default:
+		return fmt.Errorf("unknown dedupe scope %q", config.DedupeScope)
+	}
+	switch config.DedupeAction {
+	case "", dedupeActionSkip, dedupeActionAlert:
+	default:
+		return fmt.Errorf("unknown dedupe action %q", config.DedupeAction)
+	}
+	return nil
+}
+
+// dedupeActionName returns the action applied to duplicates, skip when none is set
+func dedupeActionName(config *db.TransferConfig) string {
+	if config.DedupeAction == "" {
+		return dedupeActionSkip
+	}
+	return config.DedupeAction
+}
+
+// duplicateOf describes the delivered file whose content a file duplicates, by hash and
+// size, or returns "" when it is not a duplicate. Files of the same run are compared
+// with each other through seen, which maps content to the first file of the run with it.
+func (te *TransferExecutor) duplicateOf(job db.Job, config *db.TransferConfig, fileName, fileHash string, fileSize int64, seen map[string]string) string {
+	if fileHash == "" {
+		te.logger.LogDebug("No hash found for file %s; it cannot be checked for duplicates", fileName)
+		return ""
+	}
+
+	content := fmt.Sprintf("%s:%d", fileHash, fileSize)
+	if first, ok := seen[content]; ok {
+		return fmt.Sprintf("%s of this run", first)
+	}
+	seen[content] = fileName
+
+	original, err := te.metadataHandler.findDuplicate(job.ID, config, fileHash, fileSize) // Calls interface method
+	if err != nil || original == nil {
+		return ""
+	}
+	return fmt.Sprintf("%s delivered by job %d, config %d on %s",
+		original.FileName, original.JobID, original.ConfigID, original.ProcessedTime.Format("2006-01-02 15:04:05"))
+}
+
+// recordDuplicate stores the metadata of a suppressed duplicate so it shows in the file history
+func (te *TransferExecutor) recordDuplicate(job db.Job, config *db.TransferConfig, fileName, fileHash string, fileSize int64, original string) {
+	metadata := &db.FileMetadata{
+		JobID:         job.ID,
+		ConfigID:      config.ID,
+		FileName:      fileName,
+		OriginalPath:  config.SourcePath,
+		FileSize:      fileSize,
+		FileHash:      fileHash,
+		ProcessedTime: time.Now(),
+		Status:        fileStatusDuplicate,
+		ErrorMessage:  fmt.Sprintf("Duplicate of %s", original),
+	}
+	if err := te.db.CreateFileMetadata(metadata); err != nil { // Calls interface method
+		te.logger.LogError("Error creating file metadata for duplicate %s: %v", fileName, err)
+	}
+}
@@ -0,0 +1,136 @@
+package scheduler
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/starfleetcptn/gomft/internal/db"
+	"gorm.io/gorm"
+)
+
+func TestValidateDedupe(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  db.TransferConfig
+		wantErr bool
+	}{
+		{"Disabled", db.TransferConfig{}, false},
+		{"Destination scope", db.TransferConfig{DedupeScope: "destination"}, false},
+		{"Alert", db.TransferConfig{DedupeScope: "global", DedupeAction: "alert"}, false},
+		{"Unknown scope", db.TransferConfig{DedupeScope: "user"}, true},
+		{"Unknown action", db.TransferConfig{DedupeScope: "job", DedupeAction: "delete"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := ValidateDedupe(&tt.config); (err != nil) != tt.wantErr {
+				t.Errorf("ValidateDedupe() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestFindDuplicate(t *testing.T) {
+	tests := []struct {
+		scope         string
+		wantJobID     uint
+		wantConfigIDs []uint
+	}{
+		{"job", 1, nil},
+		{"config", 0, []uint{2}},
+		{"destination", 0, []uint{3, 2}},
+		{"global", 0, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.scope, func(t *testing.T) {
+			var gotJobID uint
+			var gotConfigIDs []uint
+			mockDB := &mockMetadataDB{
+				FindDeliveredFileByContentFunc: func(fileHash string, fileSize int64, jobID uint, configIDs []uint) (*db.FileMetadata, error) {
+					gotJobID, gotConfigIDs = jobID, configIDs
+					if fileHash != "abc" || fileSize != 10 {
+						t.Errorf("FindDeliveredFileByContent(%q, %d)", fileHash, fileSize)
+					}
+					return nil, gorm.ErrRecordNotFound
+				},
+				GetConfigIDsWithDestinationFunc: func(config *db.TransferConfig) ([]uint, error) {
+					return []uint{3, config.ID}, nil
+				},
+			}
+			logger, _ := newTestLogger(LogLevelDebug)
+			defer logger.Close()
+			mh := NewMetadataHandler(mockDB, logger)
+
+			original, err := mh.findDuplicate(1, &db.TransferConfig{ID: 2, DedupeScope: tt.scope}, "abc", 10)
+			if err != nil || original != nil {
+				t.Fatalf("findDuplicate() = %v, %v, want no duplicate", original, err)
+			}
+			if gotJobID != tt.wantJobID || len(gotConfigIDs) != len(tt.wantConfigIDs) {
+				t.Errorf("findDuplicate() looked up job %d, configs %v, want job %d, configs %v", gotJobID, gotConfigIDs, tt.wantJobID, tt.wantConfigIDs)
+			}
+			for i := range tt.wantConfigIDs {
+				if gotConfigIDs[i] != tt.wantConfigIDs[i] {
+					t.Errorf("findDuplicate() configs = %v, want %v", gotConfigIDs, tt.wantConfigIDs)
+				}
+			}
+		})
+	}
+}
+
+func TestExecuteConfigTransfer_Dedupe(t *testing.T) {
+	// a.csv was delivered by another job, c.csv has the same content as b.csv
+	listing := `[{"Path":"a.csv","Size":10,"Hashes":{"md5":"aaa"}},{"Path":"b.csv","Size":20,"Hashes":{"md5":"bbb"}},{"Path":"c.csv","Size":20,"Hashes":{"md5":"bbb"}}]`
+	tests := []struct {
+		name       string
+		action     string
+		wantCopies int
+		wantStatus string
+	}{
+		{"Skip", "", 1, "completed"},
+		{"Alert", "alert", 3, "completed_with_errors"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			comps := setupTestExecutor()
+			defer comps.logger.Close()
+			comps.metadata.FindDuplicateFunc = func(jobID uint, config *db.TransferConfig, fileHash string, fileSize int64) (*db.FileMetadata, error) {
+				if fileHash == "aaa" && fileSize == 10 {
+					return &db.FileMetadata{FileName: "invoice.csv", JobID: 4, ConfigID: 5}, nil
+				}
+				return nil, nil
+			}
+			var calls [][]string
+			defer mockRcloneSequence([]string{listing}, &calls)()
+
+			config := db.TransferConfig{ID: 2, SourceType: "local", SourcePath: "/src", DestinationType: "local", DestinationPath: "/dst",
+				DedupeScope: "global", DedupeAction: tt.action}
+			history := &db.JobHistory{ID: 7, JobID: 1, ConfigID: 2}
+			comps.executor.executeConfigTransfer(db.Job{ID: 1}, config, history)
+
+			if history.Status != tt.wantStatus || history.DuplicateFiles != 2 {
+				t.Fatalf("executeConfigTransfer() status = %s, duplicates = %d: %s", history.Status, history.DuplicateFiles, history.ErrorMessage)
+			}
+			if copies := len(calls) - 1; copies != tt.wantCopies {
+				t.Errorf("executeConfigTransfer() copied %d files, want %d (calls %v)", copies, tt.wantCopies, calls)
+			}
+
+			duplicates := 0
+			for _, metadata := range comps.db.createdMetadata {
+				if metadata.Status == "duplicate" {
+					duplicates++
+					if metadata.FileName == "a.csv" && !strings.Contains(metadata.ErrorMessage, "invoice.csv delivered by job 4") {
+						t.Errorf("duplicate metadata = %q", metadata.ErrorMessage)
+					}
+				}
+			}
+			if tt.action == "" && duplicates != 2 || tt.action == "alert" && duplicates != 0 {
+				t.Errorf("executeConfigTransfer() recorded %d duplicates", duplicates)
+			}
+			if tt.action == "alert" && !strings.Contains(history.ErrorMessage, "Duplicate file c.csv: same content as b.csv of this run") {
+				t.Errorf("executeConfigTransfer() error message = %q", history.ErrorMessage)
+			}
+		})
+	}
+}
@@ -0,0 +1,152 @@
+package scheduler
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/starfleetcptn/gomft/internal/auth"
+	"github.com/starfleetcptn/gomft/internal/db"
+	"github.com/starfleetcptn/gomft/internal/encryption"
+)
+
+// Supported encryption modes for TransferConfig.EncryptionMode
+const (
+	encryptionModeNone    = ""
+	encryptionModeEncrypt = "encrypt"
+	encryptionModeDecrypt = "decrypt"
+)
+
+// encryptionKeys holds the key material a config needs for a run
+type encryptionKeys struct {
+	keys      []encryption.Key // Recipients when encrypting, private keys when decrypting
+	signer    *encryption.Key  // Private key used to sign outgoing files
+	verifiers []encryption.Key // Public keys trusted to sign incoming files
+}
+
+// loadEncryptionKeys loads the keys configured for a config from the key store
+func (te *TransferExecutor) loadEncryptionKeys(config *db.TransferConfig) (*encryptionKeys, error) {
+	if config.EncryptionMode == encryptionModeNone {
+		return nil, nil
+	}
+	if config.EncryptionMode != encryptionModeEncrypt && config.EncryptionMode != encryptionModeDecrypt {
+		return nil, fmt.Errorf("unsupported encryption mode: %s", config.EncryptionMode)
+	}
+
+	ids := db.ParseKeyIDs(config.EncryptionKeyIDs)
+	if len(ids) == 0 {
+		return nil, fmt.Errorf("no keys configured for %s", config.EncryptionMode)
+	}
+
+	decrypting := config.EncryptionMode == encryptionModeDecrypt
+	keys := &encryptionKeys{}
+	for _, id := range ids {
+		key, err := te.loadEncryptionKey(id, decrypting)
+		if err != nil {
+			return nil, err
+		}
+		keys.keys = append(keys.keys, key)
+	}
+
+	if !decrypting && config.SigningKeyID != 0 {
+		signer, err := te.loadEncryptionKey(config.SigningKeyID, true)
+		if err != nil {
+			return nil, err
+		}
+		keys.signer = &signer
+	}
+
+	if decrypting {
+		for _, id := range db.ParseKeyIDs(config.VerifyKeyIDs) {
+			key, err := te.loadEncryptionKey(id, false)
+			if err != nil {
+				return nil, err
+			}
+			keys.verifiers = append(keys.verifiers, key)
+		}
+	}
+
+	return keys, nil
+}
+
+// loadEncryptionKey loads a single key, decrypting its private part when required
+func (te *TransferExecutor) loadEncryptionKey(id uint, private bool) (encryption.Key, error) {
+	stored, err := te.db.GetEncryptionKey(id)
+	if err != nil {
+		return encryption.Key{}, fmt.Errorf("failed to load encryption key %d: %v", id, err)
+	}
+
+	key := encryption.Key{
+		Type:        stored.Type,
+		Fingerprint: stored.Fingerprint,
+		PublicKey:   stored.PublicKey,
+	}
+	if !private {
+		return key, nil
+	}
+
+	if !stored.HasPrivateKey() {
+		return encryption.Key{}, fmt.Errorf("encryption key %s has no private key", stored.Name)
+	}
+	if key.PrivateKey, err = auth.DecryptKeyMaterial(stored.PrivateKey); err != nil {
+		return encryption.Key{}, fmt.Errorf("failed to unlock encryption key %s: %v", stored.Name, err)
+	}
+	if key.Passphrase, err = auth.DecryptKeyMaterial(stored.Passphrase); err != nil {
+		return encryption.Key{}, fmt.Errorf("failed to unlock encryption key %s: %v", stored.Name, err)
+	}
+	return key, nil
+}
+
+// decryptStagedFile decrypts a staged file into destDir and verifies its signature
+func decryptStagedFile(file stagedFile, destDir string, keys *encryptionKeys, requireSignature bool) (stagedFile, error) {
+	name, _ := encryption.TrimExtension(file.name)
+	outPath := filepath.Join(destDir, filepath.Base(name))
+	if err := os.MkdirAll(destDir, 0700); err != nil {
+		return stagedFile{}, fmt.Errorf("failed to create staging directory: %v", err)
+	}
+
+	result, err := encryption.DecryptFile(file.path, outPath, keys.keys, keys.verifiers)
+	if err != nil {
+		return stagedFile{}, fmt.Errorf("failed to decrypt %s: %v", file.name, err)
+	}
+	if requireSignature && result.SignatureStatus != encryption.SignatureValid {
+		return stagedFile{}, fmt.Errorf("signature verification failed for %s: %s", file.name, result.SignatureStatus)
+	}
+
+	info, err := os.Stat(outPath)
+	if err != nil {
+		return stagedFile{}, fmt.Errorf("failed to stat decrypted file: %v", err)
+	}
+	return stagedFile{
+		path:         outPath,
+		name:         name,
+		originalSize: info.Size(),
+		encryption:   result,
+	}, nil
+}
+
+// encryptStagedFile encrypts a staged file for the configured recipients
+func encryptStagedFile(file *stagedFile, keys *encryptionKeys) error {
+	extension := encryption.Extension(keys.keys[0].Type)
+	encryptedPath := file.path + extension
+
+	result, err := encryption.EncryptFile(file.path, encryptedPath, keys.keys, keys.signer)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt %s: %v", file.name, err)
+	}
+
+	file.path = encryptedPath
+	file.extension += extension
+	file.encryption = result
+	return nil
+}
+
+// applyEncryptionResult records the outcome of an encryption step on file metadata
+func applyEncryptionResult(metadata *db.FileMetadata, result *encryption.Result) {
+	if result == nil {
+		return
+	}
+	metadata.EncryptionOperation = result.Operation
+	metadata.EncryptionFingerprint = result.Fingerprint
+	metadata.SignatureStatus = result.SignatureStatus
+}
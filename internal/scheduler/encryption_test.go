@@ -0,0 +1,145 @@
+package scheduler
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"filippo.io/age"
+	"github.com/starfleetcptn/gomft/internal/db"
+	"github.com/starfleetcptn/gomft/internal/encryption"
+)
+
+func TestLoadEncryptionKeys(t *testing.T) {
+	storedKeys := map[uint]*db.EncryptionKey{
+		1: {ID: 1, Name: "bank", Type: encryption.TypeAge, Fingerprint: "age1bank", PublicKey: "age1bank"},
+		2: {ID: 2, Name: "partner", Type: encryption.TypeAge, Fingerprint: "age1partner", PublicKey: "age1partner"},
+	}
+	mockDB := &mockTransferDB{
+		GetEncryptionKeyFunc: func(id uint) (*db.EncryptionKey, error) {
+			if key, ok := storedKeys[id]; ok {
+				return key, nil
+			}
+			return nil, errors.New("record not found")
+		},
+	}
+	logger, _ := newTestLogger(LogLevelDebug)
+	defer logger.Close()
+	te := NewTransferExecutor(mockDB, logger, &mockTransferMetadataHandler{}, &mockTransferNotifier{})
+
+	tests := []struct {
+		name     string
+		config   db.TransferConfig
+		wantKeys int
+		wantErr  bool
+	}{
+		{"No encryption", db.TransferConfig{}, 0, false},
+		{"Encrypt to two recipients", db.TransferConfig{EncryptionMode: encryptionModeEncrypt, EncryptionKeyIDs: "1, 2"}, 2, false},
+		{"No keys configured", db.TransferConfig{EncryptionMode: encryptionModeEncrypt}, 0, true},
+		{"Unknown key", db.TransferConfig{EncryptionMode: encryptionModeEncrypt, EncryptionKeyIDs: "3"}, 0, true},
+		{"Decrypt without private key", db.TransferConfig{EncryptionMode: encryptionModeDecrypt, EncryptionKeyIDs: "1"}, 0, true},
+		{"Unsupported mode", db.TransferConfig{EncryptionMode: "scramble", EncryptionKeyIDs: "1"}, 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keys, err := te.loadEncryptionKeys(&tt.config)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("loadEncryptionKeys() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			gotKeys := 0
+			if keys != nil {
+				gotKeys = len(keys.keys)
+			}
+			if gotKeys != tt.wantKeys {
+				t.Errorf("loadEncryptionKeys() returned %d keys, want %d", gotKeys, tt.wantKeys)
+			}
+		})
+	}
+}
+
+func TestEncryptDecryptStagedFile(t *testing.T) {
+	identity, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatalf("Failed to generate age identity: %v", err)
+	}
+	key := encryption.Key{
+		Type:        encryption.TypeAge,
+		Fingerprint: identity.Recipient().String(),
+		PublicKey:   identity.Recipient().String(),
+		PrivateKey:  identity.String(),
+	}
+
+	dir := t.TempDir()
+	content := "id,amount\n1,10.00\n"
+	path := filepath.Join(dir, "payments.csv")
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatalf("Failed to write test file: %v", err)
+	}
+
+	file := stagedFile{path: path, name: "payments.csv", extension: ".gz", originalSize: int64(len(content))}
+	if err := encryptStagedFile(&file, &encryptionKeys{keys: []encryption.Key{key}}); err != nil {
+		t.Fatalf("encryptStagedFile() error = %v", err)
+	}
+	if file.extension != ".gz.age" {
+		t.Errorf("encryptStagedFile() extension = %q, want %q", file.extension, ".gz.age")
+	}
+	if file.encryption == nil || file.encryption.Operation != encryption.OperationEncrypted {
+		t.Fatalf("encryptStagedFile() did not record the encryption result")
+	}
+
+	received := stagedFile{path: file.path, name: "payments.csv.age"}
+	keys := &encryptionKeys{keys: []encryption.Key{key}}
+
+	if _, err := decryptStagedFile(received, filepath.Join(dir, "strict"), keys, true); err == nil {
+		t.Error("decryptStagedFile() expected error for unsigned file when a signature is required")
+	}
+
+	decrypted, err := decryptStagedFile(received, filepath.Join(dir, "dec"), keys, false)
+	if err != nil {
+		t.Fatalf("decryptStagedFile() error = %v", err)
+	}
+	if decrypted.name != "payments.csv" {
+		t.Errorf("decryptStagedFile() name = %q, want %q", decrypted.name, "payments.csv")
+	}
+	if decrypted.originalSize != int64(len(content)) {
+		t.Errorf("decryptStagedFile() size = %d, want %d", decrypted.originalSize, len(content))
+	}
+	got, _ := os.ReadFile(decrypted.path)
+	if string(got) != content {
+		t.Errorf("Decrypted content = %q, want %q", got, content)
+	}
+
+	metadata := &db.FileMetadata{}
+	applyEncryptionResult(metadata, decrypted.encryption)
+	if metadata.EncryptionOperation != encryption.OperationDecrypted || metadata.EncryptionFingerprint != key.Fingerprint {
+		t.Errorf("applyEncryptionResult() metadata = %+v", metadata)
+	}
+}
+
+func TestExecuteConfigTransfer_EncryptionWithSync(t *testing.T) {
+	comps := setupTestExecutor()
+	defer comps.logger.Close()
+	comps.db.GetRcloneCommandFunc = func(id uint) (*db.RcloneCommand, error) {
+		return &db.RcloneCommand{ID: id, Name: "sync"}, nil
+	}
+	var calls [][]string
+	defer mockRcloneSequence(nil, &calls)()
+
+	config := db.TransferConfig{ID: 2, SourceType: "local", SourcePath: "/src", DestinationType: "local", DestinationPath: "/dst",
+		CommandID: 3, EncryptionMode: encryptionModeEncrypt, EncryptionKeyIDs: "1"}
+	history := &db.JobHistory{ID: 7, JobID: 1, ConfigID: 2}
+	comps.executor.executeConfigTransfer(db.Job{ID: 1}, config, history)
+
+	if history.Status != "failed" || !strings.Contains(history.ErrorMessage, "sync does not transfer files one at a time, which is needed to compress or encrypt files") {
+		t.Errorf("executeConfigTransfer() status = %s: %s", history.Status, history.ErrorMessage)
+	}
+	if len(calls) != 0 {
+		t.Errorf("executeConfigTransfer() ran rclone %v, want no plaintext transfer", calls)
+	}
+}
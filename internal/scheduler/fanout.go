@@ -0,0 +1,172 @@
+package scheduler
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/starfleetcptn/gomft/internal/db"
+)
+
+// mainDestinationLabel identifies the main destination of a config in the file metadata
+const mainDestinationLabel = "Main destination"
+
+// deliveryTarget is a destination the files of a run are delivered to
+type deliveryTarget struct {
+	label   string
+	config  *db.TransferConfig
+	pattern *outputPattern
+}
+
+// usesFanOut reports whether a config delivers files to additional destinations.
+// Invalid destinations count as fan-out so the run reports them instead of
+// delivering to the main destination only.
+func usesFanOut(config *db.TransferConfig) bool {
+	destinations, err := config.GetDestinations()
+	return err != nil || len(destinations) > 0
+}
+
+// ValidateDestinations checks the additional destinations of a config
+func ValidateDestinations(config *db.TransferConfig) error {
+	destinations, err := config.GetDestinations()
+	if err != nil {
+		return err
+	}
+	for i, destination := range destinations {
+		if destination.Path == "" && !isBucketStorage(destination.Type) {
+			return fmt.Errorf("%s: a path is required", destination.Label(i))
+		}
+		if isBucketStorage(destination.Type) && destination.Bucket == "" {
+			return fmt.Errorf("%s: a bucket is required", destination.Label(i))
+		}
+		destinationConfig := config.ForDestination(destination)
+		if err := ValidateOutputPattern(&destinationConfig); err != nil {
+			return fmt.Errorf("%s: %v", destination.Label(i), err)
+		}
+	}
+	return nil
+}
+
+// deliveryTargets returns the main destination of a config followed by its additional
+// destinations, each with the output pattern it names files with
+func deliveryTargets(config *db.TransferConfig, pattern *outputPattern) ([]deliveryTarget, error) {
+	targets := []deliveryTarget{{label: mainDestinationLabel, config: config, pattern: pattern}}
+	destinations, err := config.GetDestinations()
+	if err != nil {
+		return nil, err
+	}
+	for i, destination := range destinations {
+		destinationConfig := config.ForDestination(destination)
+		destinationPattern, err := newOutputPattern(&destinationConfig, pattern.job, pattern.runID, pattern.now)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", destination.Label(i), err)
+		}
+		targets = append(targets, deliveryTarget{label: destination.Label(i), config: &destinationConfig, pattern: destinationPattern})
+	}
+	return targets, nil
+}
+
+// deliverLocalFile applies the conflict policy and uploads a local file to a destination,
+// or sends it to the partner of an AS2 destination. It returns the name the file was
+// delivered under and the conflict action taken.
+func (te *TransferExecutor) deliverLocalFile(target deliveryTarget, configPath, rclonePath, localPath, destFile string) (string, string, error) {
+	config := target.config
+	if isAS2Destination(config) {
+		return destFile, "", te.sendAS2File(target, localPath, destFile)
+	}
+	destFile, action, err := te.resolveConflict(config, configPath, rclonePath, destFile)
+	if err != nil || action == conflictSkipped {
+		return destFile, action, err
+	}
+	return destFile, action, te.uploadLocalFile(config, rclonePath, localPath, destFile)
+}
+
+// deliverStagedFiles uploads the staged files of a source file to a destination. It
+// returns the names the files were delivered under and the conflict action taken.
+func (te *TransferExecutor) deliverStagedFiles(target deliveryTarget, configPath, rclonePath string, staged []stagedFile, patternFile outputPatternFile) ([]string, string, error) {
+	var names []string
+	var conflictAction string
+	for _, file := range staged {
+		patternFile.name = file.name
+		destFile, err := target.pattern.fileName(patternFile, file.extension)
+		if err != nil {
+			return names, conflictAction, err
+		}
+		destFile, action, err := te.deliverLocalFile(target, configPath, rclonePath, file.path, destFile)
+		if err != nil {
+			return names, conflictAction, err
+		}
+		if action != "" {
+			conflictAction = action
+		}
+		if action == conflictSkipped {
+			continue
+		}
+		names = append(names, destFile)
+	}
+	return names, conflictAction, nil
+}
+
+// deliveryOutcome collects the results of delivering a file to each destination
+type deliveryOutcome struct {
+	results        []db.DestinationResult
+	paths          []string
+	names          map[string][]string // Names of the delivered files by destination
+	conflictAction string
+	failures       []string
+	firstErr       error
+}
+
+// add records the result of delivering a file to a destination under the given names.
+// A file skipped at any destination is reported as skipped, so its source is kept for
+// the next run.
+func (o *deliveryOutcome) add(target deliveryTarget, names []string, action string, err error) {
+	result := db.DestinationResult{Destination: target.label, ConflictAction: action}
+	switch {
+	case err != nil:
+		result.Status = "error"
+		result.Error = err.Error()
+		o.failures = append(o.failures, fmt.Sprintf("%s: %v", target.label, err))
+		if o.firstErr == nil {
+			o.firstErr = err
+		}
+	case action == conflictSkipped:
+		result.Status = "skipped"
+	default:
+		var paths []string
+		for _, name := range names {
+			paths = append(paths, buildDestPathForDB(target.config, name))
+		}
+		result.Status = "processed"
+		result.Path = strings.Join(paths, ", ")
+		o.paths = append(o.paths, paths...)
+		if o.names == nil {
+			o.names = make(map[string][]string)
+		}
+		o.names[target.label] = append(o.names[target.label], names...)
+	}
+	if action != "" && o.conflictAction != conflictSkipped {
+		o.conflictAction = action
+	}
+	o.results = append(o.results, result)
+}
+
+// err returns the error of the delivery: the error itself with a single destination,
+// otherwise a summary of the destinations that failed
+func (o *deliveryOutcome) err() error {
+	if len(o.failures) == 0 {
+		return nil
+	}
+	if len(o.results) == 1 {
+		return o.firstErr
+	}
+	return fmt.Errorf("delivery failed for %d of %d destinations: %s", len(o.failures), len(o.results), strings.Join(o.failures, "; "))
+}
+
+// destinationResults returns the results to record in the file metadata, which are
+// only kept when the file was delivered to more than one destination
+func (o *deliveryOutcome) destinationResults() []db.DestinationResult {
+	if len(o.results) < 2 {
+		return nil
+	}
+	return o.results
+}
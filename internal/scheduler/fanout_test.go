@@ -0,0 +1,130 @@
+package scheduler
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/starfleetcptn/gomft/internal/db"
+)
+
+func TestValidateDestinations(t *testing.T) {
+	tests := []struct {
+		name         string
+		destinations string
+		wantErr      bool
+	}{
+		{"No destinations", "", false},
+		{"Valid destinations", `[{"type":"local","path":"/backup"},{"type":"s3","bucket":"archive","output_pattern":"${filename}"}]`, false},
+		{"Invalid JSON", `[{"type":`, true},
+		{"Unsupported type", `[{"type":"gdrive","path":"/backup"}]`, true},
+		{"Missing path", `[{"type":"sftp","host":"backup.example.com"}]`, true},
+		{"Missing bucket", `[{"type":"s3","path":"/backup"}]`, true},
+		{"Invalid output pattern", `[{"type":"local","path":"/backup","output_pattern":"${unknown}"}]`, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := db.TransferConfig{Destinations: tt.destinations}
+			if err := ValidateDestinations(&config); (err != nil) != tt.wantErr {
+				t.Errorf("ValidateDestinations() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestForDestination_Remote(t *testing.T) {
+	config := db.TransferConfig{ID: 2}
+	// The remote follows the destination when others are removed or reordered
+	if remote := config.ForDestination(db.Destination{ID: "a1b2c3d4", Type: "local"}); remote.GetDestRemote() != "dest_2_a1b2c3d4" {
+		t.Errorf("GetDestRemote() = %q, want dest_2_a1b2c3d4", remote.GetDestRemote())
+	}
+}
+
+func TestDeliveryOutcome(t *testing.T) {
+	main := deliveryTarget{label: mainDestinationLabel, config: &db.TransferConfig{DestinationType: "local", DestinationPath: "/dst"}}
+	backup := deliveryTarget{label: "Backup", config: &db.TransferConfig{DestinationType: "sftp", DestinationPath: "/backup"}}
+
+	// A single destination reports its own error and no per-destination results
+	var single deliveryOutcome
+	single.add(main, nil, "", errors.New("connection refused"))
+	if err := single.err(); err == nil || err.Error() != "connection refused" {
+		t.Errorf("err() with one destination = %v, want connection refused", err)
+	}
+	if results := single.destinationResults(); results != nil {
+		t.Errorf("destinationResults() with one destination = %v, want none", results)
+	}
+
+	var outcome deliveryOutcome
+	outcome.add(main, []string{"a.csv"}, "", nil)
+	outcome.add(backup, nil, "", errors.New("connection refused"))
+	if err := outcome.err(); err == nil || !strings.Contains(err.Error(), "1 of 2 destinations") || !strings.Contains(err.Error(), "Backup: connection refused") {
+		t.Errorf("err() = %v", err)
+	}
+	results := outcome.destinationResults()
+	if len(results) != 2 || results[0].Status != "processed" || results[0].Path != "/dst/a.csv" || results[1].Status != "error" {
+		t.Errorf("destinationResults() = %+v", results)
+	}
+	if names := outcome.names[mainDestinationLabel]; len(names) != 1 || names[0] != "a.csv" || len(outcome.names) != 1 {
+		t.Errorf("names = %v, want a.csv at the main destination only", outcome.names)
+	}
+
+	// A file skipped at one destination stays skipped
+	var skipped deliveryOutcome
+	skipped.add(main, nil, conflictSkipped, nil)
+	skipped.add(backup, []string{"a.csv"}, conflictOverwritten, nil)
+	if skipped.conflictAction != conflictSkipped || skipped.err() != nil {
+		t.Errorf("conflictAction = %q, err = %v, want skipped without error", skipped.conflictAction, skipped.err())
+	}
+}
+
+func TestExecuteConfigTransfer_FanOut(t *testing.T) {
+	listing := `[{"Path":"a.csv","Size":10}]`
+	tests := []struct {
+		name       string
+		outputs    []string
+		wantStatus []string
+		wantDelete bool
+	}{
+		{"All destinations", []string{listing, "", "", "", ""}, []string{"processed", "processed"}, true},
+		{"Failed destination", []string{listing, "", "", "error:connection refused"}, []string{"processed", "error"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			comps := setupTestExecutor()
+			defer comps.logger.Close()
+			var calls [][]string
+			defer mockRcloneSequence(tt.outputs, &calls)()
+
+			config := db.TransferConfig{ID: 2, SourceType: "local", SourcePath: "/src", DestinationType: "local", DestinationPath: "/dst",
+				Destinations: `[{"id":"b4c7","name":"Backup","type":"local","path":"/backup"}]`}
+			config.SetDeleteAfterTransfer(true)
+			history := &db.JobHistory{ID: 7, JobID: 1, ConfigID: 2}
+			comps.executor.executeConfigTransfer(db.Job{ID: 1}, config, history)
+
+			// The file is staged once and uploaded to each destination from the staging area
+			if len(calls) < 4 || calls[1][len(calls[1])-2] != "source_2:/src/a.csv" ||
+				calls[2][len(calls[2])-1] != "dest_2:/dst/a.csv" || calls[3][len(calls[3])-1] != "dest_2_b4c7:/backup/a.csv" {
+				t.Fatalf("executeConfigTransfer() rclone calls = %v", calls)
+			}
+			deleted := len(calls) == 5 && hasArg(calls[4], "deletefile")
+			if deleted != tt.wantDelete {
+				t.Errorf("executeConfigTransfer() deleted source = %v, want %v (calls %v)", deleted, tt.wantDelete, calls)
+			}
+
+			if len(comps.db.createdMetadata) != 1 {
+				t.Fatalf("executeConfigTransfer() created %d metadata records, want 1", len(comps.db.createdMetadata))
+			}
+			results := comps.db.createdMetadata[0].GetDestinationResults()
+			if len(results) != len(tt.wantStatus) {
+				t.Fatalf("destination results = %+v, want %d", results, len(tt.wantStatus))
+			}
+			for i, want := range tt.wantStatus {
+				if results[i].Status != want {
+					t.Errorf("destination %s status = %q, want %q", results[i].Destination, results[i].Status, want)
+				}
+			}
+		})
+	}
+}
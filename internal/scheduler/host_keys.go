@@ -0,0 +1,56 @@
+package scheduler
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/starfleetcptn/gomft/internal/db"
+	"github.com/starfleetcptn/gomft/internal/hostkeys"
+)
+
+// hostKeyFailure explains a failure caused by an SFTP server presenting a host key other
+// than the pinned one, or returns an empty string for other failures
+func hostKeyFailure(output string) string {
+	if !hostkeys.IsKeyMismatch(output) {
+		return ""
+	}
+	return "Host Key Changed: an SFTP server presented a host key that does not match the key pinned for this configuration. " +
+		"The connection was refused as it may be intercepted. If the server's key was replaced, verify the new fingerprint " +
+		"with the server administrator and rotate the pinned key under Settings > Host Keys."
+}
+
+// withHostKeyFailure prefixes an rclone error with the explanation of a host key change
+func withHostKeyFailure(err error, output string) error {
+	if reason := hostKeyFailure(output); reason != "" {
+		return fmt.Errorf("%s\n%v", reason, err)
+	}
+	return err
+}
+
+// pinHostKeysOnFirstUse pins the keys the SFTP servers of a config without a pinned key
+// present, so a server impersonated later is refused instead of trusted on every run
+func (te *TransferExecutor) pinHostKeysOnFirstUse(config *db.TransferConfig) error {
+	pinned, err := te.db.PinHostKeysOnFirstUse(config) // Calls interface method
+	for _, key := range pinned {
+		te.logger.LogInfo("Pinned host key %s %s of %s for config %d on first use, verify it under Settings > Host Keys",
+			key.KeyType, key.Fingerprint, key.Address(), config.ID)
+		auditLog := &db.AuditLog{
+			Action:     "pin",
+			EntityType: "host_key",
+			EntityID:   key.ID,
+			UserID:     config.CreatedBy,
+			Details: db.AuditLogDetails{
+				"config_id":   key.ConfigID,
+				"server":      key.Address(),
+				"key_type":    key.KeyType,
+				"fingerprint": key.Fingerprint,
+				"first_use":   true,
+			},
+			Timestamp: time.Now(),
+		}
+		if err := te.db.CreateAuditLog(auditLog); err != nil { // Calls interface method
+			te.logger.LogError("Error creating audit log for host key of %s: %v", key.Address(), err)
+		}
+	}
+	return err
+}
@@ -0,0 +1,25 @@
+package scheduler
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestHostKeyFailure(t *testing.T) {
+	mismatch := "ERROR : : error listing: NewFs: couldn't connect SSH: ssh: handshake failed: knownhosts: key mismatch"
+	if reason := hostKeyFailure(mismatch); !strings.HasPrefix(reason, "Host Key Changed") {
+		t.Errorf("hostKeyFailure() = %q, want a host key change", reason)
+	}
+	if reason := hostKeyFailure("ERROR : : error listing: directory not found"); reason != "" {
+		t.Errorf("hostKeyFailure() = %q, want none", reason)
+	}
+
+	err := withHostKeyFailure(errors.New("exit status 1"), mismatch)
+	if !strings.HasPrefix(err.Error(), "Host Key Changed") || !strings.HasSuffix(err.Error(), "exit status 1") {
+		t.Errorf("withHostKeyFailure() = %q", err)
+	}
+	if err := withHostKeyFailure(errors.New("exit status 1"), "timeout"); err.Error() != "exit status 1" {
+		t.Errorf("withHostKeyFailure() = %q, want the error unchanged", err)
+	}
+}
@@ -0,0 +1,185 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/starfleetcptn/gomft/internal/db"
+	"github.com/starfleetcptn/gomft/internal/filters"
+	"github.com/starfleetcptn/gomft/internal/httpsource"
+)
+
+// isHTTPSource reports whether the files of a config are downloaded from HTTP(S) URLs
+func isHTTPSource(config *db.TransferConfig) bool {
+	return config.SourceType == "http"
+}
+
+// ValidateHTTPSource checks the URLs and credentials of an HTTP source, and rejects the
+// options that act on files kept at the source, which an HTTP source does not have
+func ValidateHTTPSource(config *db.TransferConfig) error {
+	if !isHTTPSource(config) {
+		return nil
+	}
+	source, err := config.HTTPSource()
+	if err != nil {
+		return err
+	}
+	if err := source.Validate(time.Now()); err != nil {
+		return err
+	}
+	if config.GetArchiveEnabled() || config.GetDeleteAfterTransfer() {
+		return fmt.Errorf("files of an HTTP source cannot be archived or deleted")
+	}
+	if usesTriggers(config) {
+		return fmt.Errorf("trigger files cannot be used with an HTTP source")
+	}
+	if usesQuarantine(config) {
+		return fmt.Errorf("files of an HTTP source cannot be quarantined")
+	}
+	if config.GetSourceCrypt() {
+		return fmt.Errorf("an HTTP source cannot be decrypted with rclone crypt")
+	}
+	return nil
+}
+
+// httpDownloadDir returns the directory the files of the running transfer of an HTTP
+// source are downloaded to
+func (te *TransferExecutor) httpDownloadDir(config *db.TransferConfig) string {
+	return filepath.Join(te.db.GetConfigHTTPDir(config), config.SourceHTTPRunDir) // Calls interface method
+}
+
+// downloadHTTPFiles downloads the files of an HTTP source to the directory of the run and
+// returns their lsjson entries. When processed files are skipped, downloads are conditional
+// on the validators of the version of each URL delivered last, and files the server
+// reports as not modified are left out. A URL that cannot be
+// downloaded is returned as an entry with a DownloadError, so it fails on its own.
+func (te *TransferExecutor) downloadHTTPFiles(job db.Job, config *db.TransferConfig) ([]map[string]interface{}, error) {
+	source, err := config.HTTPSource()
+	if err != nil {
+		return nil, err
+	}
+	previous, err := config.GetHTTPValidators()
+	if err != nil {
+		return nil, err
+	}
+	filter, err := filters.FromConfig(config)
+	if err != nil {
+		return nil, err
+	}
+
+	dir := te.httpDownloadDir(config)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	urls, err := source.Resolve(context.Background(), now)
+	if err != nil {
+		return nil, err
+	}
+
+	// Only the validators of URLs the source still resolves to are kept
+	current := make(map[string]httpsource.Validators)
+	var files []map[string]interface{}
+	failed := 0
+	for _, fileURL := range urls {
+		var validators httpsource.Validators
+		if config.GetSkipProcessedFiles() {
+			validators = previous[fileURL]
+			if !validators.IsZero() {
+				current[fileURL] = validators
+			}
+		}
+
+		file, err := source.Download(context.Background(), fileURL, validators, dir)
+		if err != nil {
+			te.logger.LogError("Error downloading a file for job %d, config %d: %v", job.ID, config.ID, err)
+			name, nameErr := httpsource.FileName(fileURL)
+			if nameErr != nil {
+				name = fileURL
+			}
+			files = append(files, map[string]interface{}{
+				"Path":          name,
+				"Name":          name,
+				"Size":          float64(0),
+				"IsDir":         false,
+				"URL":           fileURL,
+				"DownloadError": err.Error(),
+			})
+			failed++
+			continue
+		}
+		if file.NotModified {
+			te.logger.LogInfo("Skipping unchanged file %s (not modified since its last delivery)", file.Name)
+			continue
+		}
+
+		// Filters by age use the download time when the server reports no modification time
+		modTime := file.ModTime
+		if modTime.IsZero() {
+			modTime = now
+		}
+		if ok, reason := filter.Match(file.Name, file.Size, modTime, now); !ok {
+			te.logger.LogDebug("Skipping downloaded file %s for job %d, config %d: %s", file.Name, job.ID, config.ID, reason)
+			os.Remove(filepath.Join(dir, file.Name))
+			continue
+		}
+
+		entry := map[string]interface{}{
+			"Path":   file.Name,
+			"Name":   file.Name,
+			"Size":   float64(file.Size),
+			"IsDir":  false,
+			"Hashes": map[string]interface{}{"md5": file.MD5},
+			"URL":    fileURL,
+		}
+		if !file.ModTime.IsZero() {
+			entry["ModTime"] = file.ModTime.Format(time.RFC3339Nano)
+		}
+		if !file.Validators.IsZero() {
+			entry["Validators"] = file.Validators
+		}
+		files = append(files, entry)
+	}
+
+	if err := config.SetHTTPValidators(current); err != nil {
+		return nil, err
+	}
+	te.logger.LogInfo("Downloaded %d of %d URLs for job %d, config %d", len(files)-failed, len(urls), job.ID, config.ID)
+	return files, nil
+}
+
+// nextHTTPValidators returns the validators to keep for the URLs of an HTTP source after a
+// run: those of the files the run delivered or found unchanged, and the earlier ones of
+// files that failed, so a failed file is downloaded again by the next run
+func nextHTTPValidators(current map[string]httpsource.Validators, files []map[string]interface{}, delivered []*db.FileMetadata, unchanged []string) map[string]httpsource.Validators {
+	done := make(map[string]bool)
+	for _, metadata := range delivered {
+		done[metadata.FileName] = true
+	}
+	for _, fileName := range unchanged {
+		done[fileName] = true
+	}
+
+	next := make(map[string]httpsource.Validators, len(current))
+	for fileURL, validators := range current {
+		next[fileURL] = validators
+	}
+	for _, entry := range files {
+		path, _ := entry["Path"].(string)
+		fileURL, _ := entry["URL"].(string)
+		if !done[path] || fileURL == "" {
+			continue
+		}
+		if validators, ok := entry["Validators"].(httpsource.Validators); ok {
+			next[fileURL] = validators
+		} else {
+			// Without validators the next run downloads the file unconditionally
+			delete(next, fileURL)
+		}
+	}
+	return next
+}
@@ -0,0 +1,200 @@
+package scheduler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/starfleetcptn/gomft/internal/db"
+	"github.com/starfleetcptn/gomft/internal/httpsource"
+)
+
+func TestValidateHTTPSource(t *testing.T) {
+	enabled := true
+	tests := []struct {
+		name    string
+		config  db.TransferConfig
+		wantErr string
+	}{
+		{"Other source", db.TransferConfig{SourceType: "local"}, ""},
+		{"URL list", db.TransferConfig{SourceType: "http", SourceURLs: "https://vendor.example/report-${date:20060102}.csv"}, ""},
+		{"No URLs", db.TransferConfig{SourceType: "http"}, "base URL or a list of URLs"},
+		{"Invalid header", db.TransferConfig{SourceType: "http", SourceURL: "https://vendor.example/", SourceHTTPHeaders: "X-Vendor"}, "invalid headers"},
+		{"Archive", db.TransferConfig{SourceType: "http", SourceURL: "https://vendor.example/", ArchiveEnabled: &enabled}, "cannot be archived or deleted"},
+		{"Delete after transfer", db.TransferConfig{SourceType: "http", SourceURL: "https://vendor.example/", DeleteAfterTransfer: &enabled}, "cannot be archived or deleted"},
+		{"Trigger files", db.TransferConfig{SourceType: "http", SourceURL: "https://vendor.example/", TriggerMode: "file"}, "trigger files"},
+		{"Quarantine", db.TransferConfig{SourceType: "http", SourceURL: "https://vendor.example/", QuarantinePath: "failed"}, "quarantined"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateHTTPSource(&tt.config)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("ValidateHTTPSource() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("ValidateHTTPSource() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNextHTTPValidators(t *testing.T) {
+	current := map[string]httpsource.Validators{
+		"https://vendor.example/a.csv": {ETag: `"a1"`},
+		"https://vendor.example/b.csv": {ETag: `"b1"`},
+		"https://vendor.example/c.csv": {ETag: `"c1"`},
+	}
+	files := []map[string]interface{}{
+		{"Path": "a.csv", "URL": "https://vendor.example/a.csv", "Validators": httpsource.Validators{ETag: `"a2"`}},
+		{"Path": "b.csv", "URL": "https://vendor.example/b.csv", "Validators": httpsource.Validators{ETag: `"b2"`}},
+		{"Path": "c.csv", "URL": "https://vendor.example/c.csv"},
+		{"Path": "d.csv", "URL": "https://vendor.example/d.csv", "Validators": httpsource.Validators{LastModified: "Thu, 14 Mar 2024 06:00:00 GMT"}},
+	}
+	delivered := []*db.FileMetadata{{FileName: "a.csv"}, {FileName: "c.csv"}}
+
+	// b.csv failed and keeps its earlier version, c.csv no longer has validators
+	next := nextHTTPValidators(current, files, delivered, []string{"d.csv"})
+	want := map[string]httpsource.Validators{
+		"https://vendor.example/a.csv": {ETag: `"a2"`},
+		"https://vendor.example/b.csv": {ETag: `"b1"`},
+		"https://vendor.example/d.csv": {LastModified: "Thu, 14 Mar 2024 06:00:00 GMT"},
+	}
+	if len(next) != len(want) {
+		t.Fatalf("nextHTTPValidators() = %v, want %v", next, want)
+	}
+	for fileURL, validators := range want {
+		if next[fileURL] != validators {
+			t.Errorf("nextHTTPValidators()[%s] = %+v, want %+v", fileURL, next[fileURL], validators)
+		}
+	}
+}
+
+func TestExecuteConfigTransfer_HTTPSource(t *testing.T) {
+	modified := time.Date(2026, 1, 1, 6, 0, 0, 0, time.UTC)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "" || r.Header.Get("X-Vendor") != "gomft" {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		http.ServeContent(w, r, "", modified, strings.NewReader("report of "+r.URL.Path))
+	}))
+	defer server.Close()
+
+	comps := setupTestExecutor()
+	defer comps.logger.Close()
+	downloadDir := filepath.Join(t.TempDir(), "http")
+	comps.db.GetConfigHTTPDirFunc = func(config *db.TransferConfig) string { return downloadDir }
+	var stored string
+	comps.db.UpdateHTTPValidatorsFunc = func(configID uint, validators string) error {
+		stored = validators
+		return nil
+	}
+
+	config := db.TransferConfig{ID: 2, SourceType: "http", SourceURL: server.URL + "/exports/",
+		SourceURLs:        "daily-${date:20060102}.csv\nmonthly.csv",
+		SourceHTTPHeaders: "X-Vendor: gomft",
+		DestinationType:   "local", DestinationPath: "/dst", OutputPattern: "vendor/${filename}.${ext}"}
+	config.SetSkipProcessedFiles(true)
+	daily := "daily-" + time.Now().Format("20060102") + ".csv"
+
+	// A run of the config still in progress keeps its downloads
+	overlapping := filepath.Join(downloadDir, "run_6", "monthly.csv")
+	if err := os.MkdirAll(filepath.Dir(overlapping), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(overlapping, []byte("report"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	var calls [][]string
+	restore := mockRcloneSequence(nil, &calls)
+	history := &db.JobHistory{ID: 7, JobID: 1, ConfigID: 2}
+	comps.executor.executeConfigTransfer(db.Job{ID: 1}, config, history)
+	restore()
+
+	if history.Status != "completed" {
+		t.Fatalf("executeConfigTransfer() status = %s: %s", history.Status, history.ErrorMessage)
+	}
+	copies := make(map[string]string)
+	for _, call := range calls {
+		copies[call[len(call)-2]] = call[len(call)-1]
+	}
+	if len(calls) != 2 || copies["source_2:run_7/"+daily] != "dest_2:/dst/vendor/"+daily || copies["source_2:run_7/monthly.csv"] != "dest_2:/dst/vendor/monthly.csv" {
+		t.Fatalf("executeConfigTransfer() calls = %v, want copies of both reports", calls)
+	}
+	if len(comps.db.createdMetadata) != 2 || comps.db.createdMetadata[0].FileHash == "" || !comps.db.createdMetadata[0].ModTime.Equal(modified) {
+		t.Errorf("executeConfigTransfer() metadata = %+v", comps.db.createdMetadata)
+	}
+	if _, err := os.Stat(filepath.Join(downloadDir, "run_7")); !os.IsNotExist(err) {
+		t.Errorf("executeConfigTransfer() kept the downloads of the run: %v", err)
+	}
+	if _, err := os.Stat(overlapping); err != nil {
+		t.Errorf("executeConfigTransfer() removed the downloads of another run: %v", err)
+	}
+	if !strings.Contains(stored, `"etag":"\"v1\""`) || !strings.Contains(stored, server.URL+"/exports/monthly.csv") {
+		t.Errorf("executeConfigTransfer() stored validators %s", stored)
+	}
+
+	// The next run sends the stored validators and transfers nothing
+	config.SourceHTTPValidators = stored
+	calls = nil
+	restore = mockRcloneSequence(nil, &calls)
+	history = &db.JobHistory{ID: 8, JobID: 1, ConfigID: 2}
+	comps.executor.executeConfigTransfer(db.Job{ID: 1}, config, history)
+	restore()
+
+	if history.Status != "completed" || len(calls) != 0 {
+		t.Errorf("executeConfigTransfer() status = %s, calls = %v, want nothing transferred", history.Status, calls)
+	}
+	if !strings.Contains(comps.logBuf.String(), "Skipping unchanged file monthly.csv (not modified since its last delivery)") {
+		t.Errorf("executeConfigTransfer() did not report the unchanged file:\n%s", comps.logBuf.String())
+	}
+}
+
+func TestExecuteConfigTransfer_HTTPSourceFailedURL(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/missing.csv") {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte("report"))
+	}))
+	defer server.Close()
+
+	comps := setupTestExecutor()
+	defer comps.logger.Close()
+	downloadDir := filepath.Join(t.TempDir(), "http")
+	comps.db.GetConfigHTTPDirFunc = func(config *db.TransferConfig) string { return downloadDir }
+
+	config := db.TransferConfig{ID: 2, SourceType: "http", SourceURL: server.URL + "/exports/",
+		SourceURLs: "missing.csv\nreport.csv", DestinationType: "local", DestinationPath: "/dst"}
+
+	var calls [][]string
+	defer mockRcloneSequence(nil, &calls)()
+	history := &db.JobHistory{ID: 7, JobID: 1, ConfigID: 2}
+	comps.executor.executeConfigTransfer(db.Job{ID: 1}, config, history)
+
+	// The failed URL does not keep the other file from being delivered
+	if history.Status != "completed_with_errors" || !strings.Contains(history.ErrorMessage, "File missing.csv: download failed") {
+		t.Errorf("executeConfigTransfer() status = %s: %s", history.Status, history.ErrorMessage)
+	}
+	if len(calls) != 1 || calls[0][len(calls[0])-1] != "dest_2:/dst/report.csv" {
+		t.Errorf("executeConfigTransfer() calls = %v, want a copy of report.csv", calls)
+	}
+	statuses := make(map[string]string)
+	for _, metadata := range comps.db.createdMetadata {
+		statuses[metadata.FileName] = metadata.Status
+	}
+	if statuses["missing.csv"] != "error" || statuses["report.csv"] != "processed" {
+		t.Errorf("executeConfigTransfer() metadata statuses = %v", statuses)
+	}
+}
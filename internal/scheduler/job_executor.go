@@ -1,6 +1,7 @@
 package scheduler
 
 import (
+	"fmt"
 	"sync"
 	"time"
 
@@ -17,6 +18,7 @@ type JobExecutorDB interface {
 	GetConfigsForJob(jobID uint) ([]db.TransferConfig, error)
 	UpdateJobStatus(job *db.Job) error
 	CreateJobHistory(history *db.JobHistory) error
+	GetResumingRun(historyID uint) (*db.JobHistory, error)
 }
 
 // JobExecutorCron defines the cron methods needed by JobExecutor.
@@ -27,6 +29,8 @@ type JobExecutorCron interface {
 // JobExecutorTransferExecutor defines the transfer executor methods needed by JobExecutor.
 type JobExecutorTransferExecutor interface {
 	executeConfigTransfer(job db.Job, config db.TransferConfig, history *db.JobHistory)
+	releaseQuarantinedFile(config *db.TransferConfig, metadata *db.FileMetadata) error
+	deliverInboundFile(config *db.TransferConfig, fileName, localPath string) (string, error)
 }
 
 // JobExecutorNotifier defines the notification methods needed by JobExecutor.
@@ -47,6 +51,7 @@ type JobExecutor struct {
 	jobMutex         *sync.Mutex                 // Shared mutex from Scheduler
 	transferExecutor JobExecutorTransferExecutor // Use interface
 	notifier         JobExecutorNotifier         // Use interface
+	resumeMutex      sync.Mutex                  // Keeps a run from being resumed twice
 }
 
 // NewJobExecutor creates a new JobExecutor.
@@ -204,3 +209,104 @@ func (je *JobExecutor) processConfiguration(job *db.Job, config *db.TransferConf
 	// Execute the configuration transfer
 	je.transferExecutor.executeConfigTransfer(*job, *config, history) // Calls interface method
 }
+
+// resumeRun starts a new run of the configuration of an earlier run that continues from
+// the checkpoint of that run. The run is validated before it is started in the background.
+// A run is only resumed once; when the resumed run fails as well, it is resumed in turn.
+func (je *JobExecutor) resumeRun(historyID uint) error {
+	var previous db.JobHistory
+	if err := je.db.First(&previous, historyID).Error; err != nil { // Calls interface method
+		return fmt.Errorf("run %d not found: %v", historyID, err)
+	}
+	if !IsResumable(previous.Status) {
+		return fmt.Errorf("run %d has status %s and cannot be resumed", historyID, previous.Status)
+	}
+
+	var job db.Job
+	if err := je.db.First(&job, previous.JobID).Error; err != nil { // Calls interface method
+		return fmt.Errorf("job %d of run %d not found: %v", previous.JobID, historyID, err)
+	}
+	var config db.TransferConfig
+	if err := je.db.First(&config, previous.ConfigID).Error; err != nil { // Calls interface method
+		return fmt.Errorf("configuration %d of run %d not found: %v", previous.ConfigID, historyID, err)
+	}
+
+	// The check and the new run are created together so concurrent requests resume once
+	je.resumeMutex.Lock()
+	defer je.resumeMutex.Unlock()
+	if err := je.checkNotResumed(historyID); err != nil {
+		return err
+	}
+
+	history := &db.JobHistory{
+		JobID:         job.ID,
+		ConfigID:      config.ID,
+		StartTime:     time.Now(),
+		Status:        "running",
+		ResumedFromID: previous.ID,
+	}
+	if err := je.db.CreateJobHistory(history); err != nil { // Calls interface method
+		je.logger.LogError("Error creating job history for resumed run %d of job %d: %v", historyID, job.ID, err)
+		return fmt.Errorf("failed to create the resumed run: %v", err)
+	}
+
+	go func() {
+		je.logger.LogInfo("Resuming run %d of job %d, config %d", historyID, job.ID, config.ID)
+
+		// Send webhook notification for job start
+		je.notifier.SendNotifications(&job, history, &config) // Calls interface method
+
+		// Execute the configuration transfer from the checkpoint
+		je.transferExecutor.executeConfigTransfer(job, config, history) // Calls interface method
+	}()
+	return nil
+}
+
+// checkNotResumed returns an error naming the newest run of the chain when a run has
+// already been resumed
+func (je *JobExecutor) checkNotResumed(historyID uint) error {
+	next, err := je.db.GetResumingRun(historyID) // Calls interface method
+	if err != nil {
+		return fmt.Errorf("failed to check whether run %d was resumed: %v", historyID, err)
+	}
+	if next == nil {
+		return nil
+	}
+	newest := next
+	for {
+		later, err := je.db.GetResumingRun(newest.ID) // Calls interface method
+		if err != nil {
+			return fmt.Errorf("failed to check whether run %d was resumed: %v", newest.ID, err)
+		}
+		if later == nil {
+			break
+		}
+		newest = later
+	}
+	if newest.Status == "running" {
+		return fmt.Errorf("run %d was already resumed and run %d is still running", historyID, newest.ID)
+	}
+	return fmt.Errorf("run %d was already resumed by run %d; resume run %d instead", historyID, next.ID, newest.ID)
+}
+
+// releaseFile moves a quarantined file back to the source of its configuration
+func (je *JobExecutor) releaseFile(metadataID uint) error {
+	var metadata db.FileMetadata
+	if err := je.db.First(&metadata, metadataID).Error; err != nil { // Calls interface method
+		return fmt.Errorf("file %d not found: %v", metadataID, err)
+	}
+	var config db.TransferConfig
+	if err := je.db.First(&config, metadata.ConfigID).Error; err != nil { // Calls interface method
+		return fmt.Errorf("configuration %d of file %d not found: %v", metadata.ConfigID, metadataID, err)
+	}
+	return je.transferExecutor.releaseQuarantinedFile(&config, &metadata) // Calls interface method
+}
+
+// deliverAS2Payload delivers a payload received over AS2 to the destination of a configuration
+func (je *JobExecutor) deliverAS2Payload(configID uint, fileName, localPath string) (string, error) {
+	var config db.TransferConfig
+	if err := je.db.First(&config, configID).Error; err != nil { // Calls interface method
+		return "", fmt.Errorf("configuration %d not found: %v", configID, err)
+	}
+	return je.transferExecutor.deliverInboundFile(&config, fileName, localPath) // Calls interface method
+}
@@ -26,6 +26,7 @@ type mockJobExecutorDB struct {
 	GetConfigsForJobFunc func(jobID uint) ([]db.TransferConfig, error)
 	UpdateJobStatusFunc  func(job *db.Job) error
 	CreateJobHistoryFunc func(history *db.JobHistory) error
+	GetResumingRunFunc   func(historyID uint) (*db.JobHistory, error)
 
 	// Store calls/data
 	firstCalledWithDest  interface{}
@@ -92,6 +93,13 @@ func (m *mockJobExecutorDB) CreateJobHistory(history *db.JobHistory) error {
 	return nil       // Default success
 }
 
+func (m *mockJobExecutorDB) GetResumingRun(historyID uint) (*db.JobHistory, error) {
+	if m.GetResumingRunFunc != nil {
+		return m.GetResumingRunFunc(historyID)
+	}
+	return nil, nil // Default: not resumed
+}
+
 func (m *mockJobExecutorDB) Reset() {
 	m.mu.Lock()
 	defer m.mu.Unlock()
@@ -141,6 +149,8 @@ type mockJobExecutorTransferExecutor struct {
 
 	// Store calls
 	executeConfigTransferCalls []map[string]interface{}
+	releasedFiles              []*db.FileMetadata
+	inboundFiles               []string
 }
 
 func (m *mockJobExecutorTransferExecutor) executeConfigTransfer(job db.Job, config db.TransferConfig, history *db.JobHistory) {
@@ -154,6 +164,18 @@ func (m *mockJobExecutorTransferExecutor) executeConfigTransfer(job db.Job, conf
 	}
 	// Default: Do nothing, just record the call
 }
+func (m *mockJobExecutorTransferExecutor) releaseQuarantinedFile(config *db.TransferConfig, metadata *db.FileMetadata) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.releasedFiles = append(m.releasedFiles, metadata)
+	return nil
+}
+func (m *mockJobExecutorTransferExecutor) deliverInboundFile(config *db.TransferConfig, fileName, localPath string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.inboundFiles = append(m.inboundFiles, fileName)
+	return config.DestinationPath + "/" + fileName, nil
+}
 func (m *mockJobExecutorTransferExecutor) Reset() {
 	m.mu.Lock()
 	defer m.mu.Unlock()
@@ -524,3 +546,66 @@ func TestProcessConfiguration_HistoryError(t *testing.T) {
 	}
 	comps.transfer.mu.Unlock()
 }
+
+func TestResumeRun(t *testing.T) {
+	// Run 7 was resumed by run 8, which was resumed by run 9
+	chain := map[uint]*db.JobHistory{7: {ID: 8, ResumedFromID: 7, Status: "failed"}, 8: {ID: 9, ResumedFromID: 8, Status: "running"}}
+	tests := []struct {
+		name       string
+		status     string
+		resumedBy  map[uint]*db.JobHistory
+		wantErr    string
+		wantResume bool
+	}{
+		{"Failed run", "failed", nil, "", true},
+		{"Interrupted run", "interrupted", nil, "", true},
+		{"Completed run", "completed", nil, "cannot be resumed", false},
+		{"Running run", "running", nil, "cannot be resumed", false},
+		{"Already resumed", "failed", map[uint]*db.JobHistory{7: chain[7]}, "resume run 8 instead", false},
+		{"Resumed run still running", "failed", chain, "run 9 is still running", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			comps := setupTestJobExecutor()
+			defer comps.logger.Close()
+
+			done := make(chan *db.JobHistory, 1)
+			comps.transfer.ExecuteConfigTransferFunc = func(job db.Job, config db.TransferConfig, history *db.JobHistory) {
+				done <- history
+			}
+			comps.db.FirstFunc = func(dest interface{}, conds ...interface{}) *gorm.DB {
+				switch d := dest.(type) {
+				case *db.JobHistory:
+					*d = db.JobHistory{ID: 7, JobID: 1, ConfigID: 2, Status: tt.status}
+				case *db.Job:
+					*d = db.Job{ID: 1, Name: "Resumable Job"}
+				case *db.TransferConfig:
+					*d = db.TransferConfig{ID: 2, Name: "Resumable Config"}
+				}
+				return &gorm.DB{Error: nil}
+			}
+
+			comps.db.GetResumingRunFunc = func(historyID uint) (*db.JobHistory, error) {
+				return tt.resumedBy[historyID], nil
+			}
+
+			err := comps.executor.resumeRun(7)
+			if (err != nil) != (tt.wantErr != "") || (err != nil && !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Fatalf("resumeRun() error = %v, want %q", err, tt.wantErr)
+			}
+			if !tt.wantResume {
+				return
+			}
+
+			select {
+			case history := <-done:
+				if history.ResumedFromID != 7 || history.JobID != 1 || history.ConfigID != 2 || history.Status != "running" {
+					t.Errorf("resumeRun() history = %+v", history)
+				}
+			case <-time.After(time.Second):
+				t.Fatal("resumeRun() did not start the transfer")
+			}
+		})
+	}
+}
@@ -0,0 +1,253 @@
+package scheduler
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/starfleetcptn/gomft/internal/auth"
+	"github.com/starfleetcptn/gomft/internal/db"
+)
+
+// Manifest formats
+const (
+	manifestCSV  = "csv"
+	manifestJSON = "json"
+	manifestXML  = "xml"
+)
+
+// ManifestSignatureExtension is appended to the manifest name for its HMAC signature
+const ManifestSignatureExtension = ".hmac"
+
+// defaultManifestName is the name pattern of manifests when none is configured
+const defaultManifestName = "manifest_${date:20060102_150405}"
+
+// runManifest lists the files delivered by a run
+type runManifest struct {
+	XMLName     xml.Name       `json:"-" xml:"manifest"`
+	Job         string         `json:"job" xml:"job,attr"`
+	Config      string         `json:"config" xml:"config,attr"`
+	RunID       uint           `json:"run_id" xml:"run_id,attr"`
+	GeneratedAt time.Time      `json:"generated_at" xml:"generated_at,attr"`
+	FileCount   int            `json:"file_count" xml:"file_count,attr"`
+	Files       []manifestFile `json:"files" xml:"file"`
+}
+
+// manifestFile is a delivered file as listed in a manifest. Size and hash are those of
+// the source file.
+type manifestFile struct {
+	Name            string `json:"name" xml:"name"`
+	Size            int64  `json:"size" xml:"size"`
+	Hash            string `json:"hash" xml:"hash"`
+	SourcePath      string `json:"source_path" xml:"source_path"`
+	DestinationPath string `json:"destination_path" xml:"destination_path"`
+}
+
+// usesManifest reports whether a manifest is created after each run of a config
+func usesManifest(config *db.TransferConfig) bool {
+	return config.ManifestFormat != ""
+}
+
+// ValidateManifest checks the manifest options of a config
+func ValidateManifest(config *db.TransferConfig) error {
+	if !usesManifest(config) {
+		return nil
+	}
+	switch config.ManifestFormat {
+	case manifestCSV, manifestJSON, manifestXML:
+	default:
+		return fmt.Errorf("unknown manifest format %q", config.ManifestFormat)
+	}
+	if _, err := manifestFileName(config, "job", 1, time.Now()); err != nil {
+		return err
+	}
+	if config.GetManifestSign() && config.ManifestHMACKey == "" {
+		return fmt.Errorf("a signing key is required to sign manifests")
+	}
+	return nil
+}
+
+// manifestFileName returns the destination name of the manifest of a run. The extension
+// of the format is added when the pattern does not end with it.
+func manifestFileName(config *db.TransferConfig, jobName string, runID uint, now time.Time) (string, error) {
+	pattern := config.ManifestName
+	if pattern == "" {
+		pattern = defaultManifestName
+	}
+	p := &outputPattern{pattern: pattern, job: jobName, config: config.Name, runID: runID, now: now}
+	name, err := p.substitute(outputPatternFile{name: "manifest." + config.ManifestFormat, modTime: now}, nil)
+	if err != nil {
+		return "", fmt.Errorf("invalid manifest name: %v", err)
+	}
+	if err := validateOutputPath(name); err != nil {
+		return "", fmt.Errorf("invalid manifest name: %v", err)
+	}
+	if !strings.HasSuffix(strings.ToLower(name), "."+config.ManifestFormat) {
+		name += "." + config.ManifestFormat
+	}
+	return name, nil
+}
+
+// manifestNameRegexp returns a regular expression matching the names the manifests of a
+// config and their signatures get at the destination, including the suffixes of the
+// conflict policy. It returns nil when the name pattern has no fixed text, as it would
+// match delivered files as well.
+func manifestNameRegexp(config *db.TransferConfig) *regexp.Regexp {
+	if !usesManifest(config) {
+		return nil
+	}
+	pattern := config.ManifestName
+	if pattern == "" {
+		pattern = defaultManifestName
+	}
+	extension := "." + config.ManifestFormat
+	if strings.HasSuffix(strings.ToLower(pattern), extension) {
+		pattern = pattern[:len(pattern)-len(extension)]
+	}
+	var re strings.Builder
+	hasText := false
+	for i, part := range patternVariableRegex.Split(pattern, -1) {
+		if i > 0 {
+			re.WriteString("[^/]*")
+		}
+		hasText = hasText || part != ""
+		re.WriteString(regexp.QuoteMeta(part))
+	}
+	if !hasText {
+		return nil
+	}
+	return regexp.MustCompile("(?i)^" + re.String() + "(_[0-9T]+)?" + regexp.QuoteMeta(extension) + "(" + regexp.QuoteMeta(ManifestSignatureExtension) + ")?$")
+}
+
+// newRunManifest lists the delivered files of a run, sorted by name
+func newRunManifest(job db.Job, config *db.TransferConfig, runID uint, now time.Time, delivered []*db.FileMetadata) runManifest {
+	manifest := runManifest{
+		Job:         job.Name,
+		Config:      config.Name,
+		RunID:       runID,
+		GeneratedAt: now.UTC(),
+		FileCount:   len(delivered),
+		Files:       make([]manifestFile, 0, len(delivered)),
+	}
+	for _, metadata := range delivered {
+		manifest.Files = append(manifest.Files, manifestFile{
+			Name:            metadata.FileName,
+			Size:            metadata.FileSize,
+			Hash:            metadata.FileHash,
+			SourcePath:      path.Join(metadata.OriginalPath, metadata.FileName),
+			DestinationPath: metadata.DestinationPath,
+		})
+	}
+	sort.Slice(manifest.Files, func(i, j int) bool {
+		return manifest.Files[i].Name < manifest.Files[j].Name
+	})
+	return manifest
+}
+
+// encode writes the manifest in the given format
+func (m runManifest) encode(format string) ([]byte, error) {
+	switch format {
+	case manifestJSON:
+		return json.MarshalIndent(m, "", "  ")
+	case manifestXML:
+		content, err := xml.MarshalIndent(m, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		return append([]byte(xml.Header), content...), nil
+	case manifestCSV:
+		var buf bytes.Buffer
+		writer := csv.NewWriter(&buf)
+		writer.Write([]string{"name", "size", "hash", "source_path", "destination_path"})
+		for _, file := range m.Files {
+			writer.Write([]string{file.Name, strconv.FormatInt(file.Size, 10), file.Hash, file.SourcePath, file.DestinationPath})
+		}
+		writer.Flush()
+		return buf.Bytes(), writer.Error()
+	}
+	return nil, fmt.Errorf("unknown manifest format %q", format)
+}
+
+// signManifest returns the hex encoded HMAC-SHA256 of a manifest
+func signManifest(content []byte, key string) string {
+	h := hmac.New(sha256.New, []byte(key))
+	h.Write(content)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// deliverManifest writes the manifest of a run, keeps a copy for the run details and
+// uploads it, with its signature when enabled, to each destination. It returns the
+// errors that occurred.
+func (te *TransferExecutor) deliverManifest(job db.Job, config *db.TransferConfig, history *db.JobHistory, targets []deliveryTarget, configPath, rclonePath string, delivered []*db.FileMetadata) []string {
+	if len(delivered) == 0 {
+		te.logger.LogInfo("No files were delivered for job %d, config %d; no manifest is created", job.ID, config.ID)
+		return nil
+	}
+
+	now := time.Now()
+	name, err := manifestFileName(config, job.Name, history.ID, now)
+	if err != nil {
+		return []string{fmt.Sprintf("Manifest error: %v", err)}
+	}
+	content, err := newRunManifest(job, config, history.ID, now, delivered).encode(config.ManifestFormat)
+	if err != nil {
+		return []string{fmt.Sprintf("Manifest error: failed to encode manifest: %v", err)}
+	}
+
+	manifestDir := te.db.GetRunManifestDir(history) // Calls interface method
+	if err := os.MkdirAll(manifestDir, 0700); err != nil {
+		return []string{fmt.Sprintf("Manifest error: failed to create manifest directory: %v", err)}
+	}
+	localPath := filepath.Join(manifestDir, path.Base(name))
+	if err := os.WriteFile(localPath, content, 0600); err != nil {
+		return []string{fmt.Sprintf("Manifest error: failed to write manifest: %v", err)}
+	}
+	history.Manifest = path.Base(name)
+
+	var signaturePath string
+	if config.GetManifestSign() {
+		key, err := auth.DecryptKeyMaterial(config.ManifestHMACKey)
+		if err != nil {
+			return []string{fmt.Sprintf("Manifest error: failed to unlock signing key: %v", err)}
+		}
+		signaturePath = localPath + ManifestSignatureExtension
+		if err := os.WriteFile(signaturePath, []byte(signManifest(content, key)+"\n"), 0600); err != nil {
+			return []string{fmt.Sprintf("Manifest error: failed to write signature: %v", err)}
+		}
+	}
+
+	var errors []string
+	for _, target := range targets {
+		destFile, action, err := te.deliverLocalFile(target, configPath, rclonePath, localPath, name)
+		if err != nil {
+			errors = append(errors, fmt.Sprintf("Manifest error for %s: %v", target.label, err))
+			continue
+		}
+		if action == conflictSkipped {
+			te.logger.LogInfo("Skipping manifest %s at %s for job %d, config %d: destination file already exists", name, target.label, job.ID, config.ID)
+			continue
+		}
+		te.logger.LogInfo("Delivered manifest %s to %s for job %d, config %d", destFile, target.label, job.ID, config.ID)
+
+		// The signature follows the name the conflict policy gave the manifest
+		if signaturePath != "" {
+			if _, _, err := te.deliverLocalFile(target, configPath, rclonePath, signaturePath, destFile+ManifestSignatureExtension); err != nil {
+				errors = append(errors, fmt.Sprintf("Manifest signature error for %s: %v", target.label, err))
+			}
+		}
+	}
+	return errors
+}
@@ -0,0 +1,138 @@
+package scheduler
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/starfleetcptn/gomft/internal/db"
+)
+
+func TestValidateManifest(t *testing.T) {
+	sign := true
+	tests := []struct {
+		name    string
+		config  db.TransferConfig
+		wantErr bool
+	}{
+		{"Disabled", db.TransferConfig{}, false},
+		{"Default name", db.TransferConfig{ManifestFormat: "csv"}, false},
+		{"Name pattern", db.TransferConfig{ManifestFormat: "json", ManifestName: "manifests/${job}_${run_id}"}, false},
+		{"Unknown format", db.TransferConfig{ManifestFormat: "yaml"}, true},
+		{"Unknown variable", db.TransferConfig{ManifestFormat: "xml", ManifestName: "${hash}"}, true},
+		{"Absolute name", db.TransferConfig{ManifestFormat: "csv", ManifestName: "/manifest"}, true},
+		{"Signing without key", db.TransferConfig{ManifestFormat: "csv", ManifestSign: &sign}, true},
+		{"Signing with key", db.TransferConfig{ManifestFormat: "csv", ManifestSign: &sign, ManifestHMACKey: "stored"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := ValidateManifest(&tt.config); (err != nil) != tt.wantErr {
+				t.Errorf("ValidateManifest() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestManifestFileName(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 30, 0, 0, time.UTC)
+	tests := []struct {
+		pattern string
+		format  string
+		want    string
+	}{
+		{"", "csv", "manifest_20240601_123000.csv"},
+		{"${job}_${run_id}.${ext}", "json", "Daily_7.json"},
+		{"MANIFEST.XML", "xml", "MANIFEST.XML"},
+		{"out/${config}", "xml", "out/Reports.xml"},
+	}
+	for _, tt := range tests {
+		config := &db.TransferConfig{Name: "Reports", ManifestFormat: tt.format, ManifestName: tt.pattern}
+		got, err := manifestFileName(config, "Daily", 7, now)
+		if err != nil || got != tt.want {
+			t.Errorf("manifestFileName(%q) = %q, %v, want %q", tt.pattern, got, err, tt.want)
+		}
+	}
+}
+
+func TestRunManifestEncode(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 30, 0, 0, time.UTC)
+	delivered := []*db.FileMetadata{
+		{FileName: "b.csv", FileSize: 20, FileHash: "bbb", OriginalPath: "/src", DestinationPath: "/dst/b.csv"},
+		{FileName: "a,1.csv", FileSize: 10, FileHash: "aaa", OriginalPath: "/src", DestinationPath: "/dst/a,1.csv, /backup/a,1.csv"},
+	}
+	manifest := newRunManifest(db.Job{Name: "Daily"}, &db.TransferConfig{Name: "Reports"}, 7, now, delivered)
+
+	content, err := manifest.encode(manifestCSV)
+	if err != nil {
+		t.Fatalf("encode(csv) error = %v", err)
+	}
+	want := "name,size,hash,source_path,destination_path\n" +
+		"\"a,1.csv\",10,aaa,\"/src/a,1.csv\",\"/dst/a,1.csv, /backup/a,1.csv\"\n" +
+		"b.csv,20,bbb,/src/b.csv,/dst/b.csv\n"
+	if string(content) != want {
+		t.Errorf("encode(csv) = %q, want %q", content, want)
+	}
+
+	content, err = manifest.encode(manifestJSON)
+	if err != nil {
+		t.Fatalf("encode(json) error = %v", err)
+	}
+	var decoded runManifest
+	if err := json.Unmarshal(content, &decoded); err != nil || decoded.FileCount != 2 || decoded.RunID != 7 || decoded.Files[1].SourcePath != "/src/b.csv" {
+		t.Errorf("encode(json) = %s (%v)", content, err)
+	}
+
+	content, err = manifest.encode(manifestXML)
+	if err != nil {
+		t.Fatalf("encode(xml) error = %v", err)
+	}
+	decoded = runManifest{}
+	if err := xml.Unmarshal(content, &decoded); err != nil || decoded.Job != "Daily" || len(decoded.Files) != 2 || decoded.Files[0].Hash != "aaa" {
+		t.Errorf("encode(xml) = %s (%v)", content, err)
+	}
+}
+
+func TestSignManifest(t *testing.T) {
+	// RFC 4231 test case 2
+	got := signManifest([]byte("what do ya want for nothing?"), "Jefe")
+	if got != "5bdcc146bf60754e6a042426089575c75a003f089d2739839dec58b964ec3843" {
+		t.Errorf("signManifest() = %s", got)
+	}
+}
+
+func TestExecuteConfigTransfer_Manifest(t *testing.T) {
+	comps := setupTestExecutor()
+	defer comps.logger.Close()
+	manifestDir := t.TempDir()
+	comps.db.GetRunManifestDirFunc = func(history *db.JobHistory) string { return manifestDir }
+	var calls [][]string
+	listing := `[{"Path":"a.csv","Size":10,"Hashes":{"md5":"aaa"}},{"Path":"b.csv","Size":20,"Hashes":{"md5":"bbb"}}]`
+	defer mockRcloneSequence([]string{listing, "", "", ""}, &calls)()
+
+	config := db.TransferConfig{ID: 2, Name: "Reports", SourceType: "local", SourcePath: "/src", DestinationType: "local", DestinationPath: "/dst",
+		ManifestFormat: "csv", ManifestName: "manifest_${run_id}"}
+	history := &db.JobHistory{ID: 7, JobID: 1, ConfigID: 2}
+	comps.executor.executeConfigTransfer(db.Job{ID: 1, Name: "Daily"}, config, history)
+
+	if history.Status != "completed" || history.Manifest != "manifest_7.csv" {
+		t.Fatalf("executeConfigTransfer() status = %s, manifest = %q: %s", history.Status, history.Manifest, history.ErrorMessage)
+	}
+	// The manifest is uploaded to the destination after the files
+	last := calls[len(calls)-1]
+	if len(calls) != 4 || last[len(last)-1] != "dest_2:/dst/manifest_7.csv" {
+		t.Errorf("executeConfigTransfer() rclone calls = %v, want the manifest upload last", calls)
+	}
+
+	content, err := os.ReadFile(filepath.Join(manifestDir, "manifest_7.csv"))
+	if err != nil {
+		t.Fatalf("manifest was not kept for the run details: %v", err)
+	}
+	if !strings.Contains(string(content), "a.csv,10,aaa,/src/a.csv,/dst/a.csv") || !strings.Contains(string(content), "b.csv,20,bbb,/src/b.csv,/dst/b.csv") {
+		t.Errorf("manifest = %s", content)
+	}
+}
@@ -13,6 +13,8 @@ import (
 type MetadataDB interface {
 	GetFileMetadataByHash(hash string) (*db.FileMetadata, error)
 	GetFileMetadataByJobAndName(jobID uint, fileName string) (*db.FileMetadata, error)
+	FindDeliveredFileByContent(fileHash string, fileSize int64, jobID uint, configIDs []uint) (*db.FileMetadata, error)
+	GetConfigIDsWithDestination(config *db.TransferConfig) ([]uint, error)
 }
 
 // MetadataHandler handles checking file processing history.
@@ -73,3 +75,35 @@ func (mh *MetadataHandler) checkFileProcessingHistory(jobID uint, fileName strin
 
 	return nil, fmt.Errorf("no history found for file %s in job %d", fileName, jobID)
 }
+
+// findDuplicate returns the earliest delivered file with the same content as a file within
+// the dedupe scope of a config, or nil when there is none.
+func (mh *MetadataHandler) findDuplicate(jobID uint, config *db.TransferConfig, fileHash string, fileSize int64) (*db.FileMetadata, error) {
+	var scopeJobID uint
+	var configIDs []uint
+	switch config.DedupeScope {
+	case dedupeScopeJob:
+		scopeJobID = jobID
+	case dedupeScopeConfig:
+		configIDs = []uint{config.ID}
+	case dedupeScopeDestination:
+		ids, err := mh.db.GetConfigIDsWithDestination(config) // Calls the interface method
+		if err != nil {
+			return nil, fmt.Errorf("failed to find configs with the same destination: %v", err)
+		}
+		configIDs = ids
+	case dedupeScopeGlobal:
+	default:
+		return nil, fmt.Errorf("unknown dedupe scope %q", config.DedupeScope)
+	}
+
+	metadata, err := mh.db.FindDeliveredFileByContent(fileHash, fileSize, scopeJobID, configIDs) // Calls the interface method
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		mh.logger.LogError("Error checking for duplicates of hash %s for job %d, config %d: %v", fileHash, jobID, config.ID, err)
+		return nil, err
+	}
+	return metadata, nil
+}
@@ -18,6 +18,8 @@ var _ MetadataDB = (*mockMetadataDB)(nil)
 type mockMetadataDB struct {
 	GetFileMetadataByHashFunc       func(hash string) (*db.FileMetadata, error)
 	GetFileMetadataByJobAndNameFunc func(jobID uint, fileName string) (*db.FileMetadata, error)
+	FindDeliveredFileByContentFunc  func(fileHash string, fileSize int64, jobID uint, configIDs []uint) (*db.FileMetadata, error)
+	GetConfigIDsWithDestinationFunc func(config *db.TransferConfig) ([]uint, error)
 }
 
 // Implement the MetadataDB interface methods
@@ -35,6 +37,20 @@ func (m *mockMetadataDB) GetFileMetadataByJobAndName(jobID uint, fileName string
 	return nil, errors.New("mock GetFileMetadataByJobAndNameFunc not implemented")
 }
 
+func (m *mockMetadataDB) FindDeliveredFileByContent(fileHash string, fileSize int64, jobID uint, configIDs []uint) (*db.FileMetadata, error) {
+	if m.FindDeliveredFileByContentFunc != nil {
+		return m.FindDeliveredFileByContentFunc(fileHash, fileSize, jobID, configIDs)
+	}
+	return nil, errors.New("mock FindDeliveredFileByContentFunc not implemented")
+}
+
+func (m *mockMetadataDB) GetConfigIDsWithDestination(config *db.TransferConfig) ([]uint, error) {
+	if m.GetConfigIDsWithDestinationFunc != nil {
+		return m.GetConfigIDsWithDestinationFunc(config)
+	}
+	return nil, errors.New("mock GetConfigIDsWithDestinationFunc not implemented")
+}
+
 // --- Tests ---
 
 func TestHasFileBeenProcessed(t *testing.T) {
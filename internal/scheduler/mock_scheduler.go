@@ -11,6 +11,12 @@ type MockScheduler struct {
 	RunJobsNow         map[uint]bool
 	ScheduleJobErr     error
 	RunJobNowErr       error
+	ResumedRuns        map[uint]bool
+	ResumeRunErr       error
+	ReleasedFiles      map[uint]bool
+	ReleaseFileErr     error
+	AS2Payloads        map[uint][]string // Config ID -> names of the payloads delivered
+	DeliverAS2Err      error
 	UnscheduleJobCalls int
 	MultiConfigJobs    map[uint][]uint // Track jobs with multiple configs (job ID -> config IDs)
 }
@@ -21,6 +27,9 @@ func NewMockScheduler() *MockScheduler {
 		ScheduledJobs:   make(map[uint]bool),
 		UnscheduledJobs: make(map[uint]bool),
 		RunJobsNow:      make(map[uint]bool),
+		ResumedRuns:     make(map[uint]bool),
+		ReleasedFiles:   make(map[uint]bool),
+		AS2Payloads:     make(map[uint][]string),
 		MultiConfigJobs: make(map[uint][]uint),
 	}
 }
@@ -60,6 +69,36 @@ func (m *MockScheduler) RunJobNow(jobID uint) error {
 	return nil
 }
 
+// ResumeRun mocks resuming a run from its checkpoint
+func (m *MockScheduler) ResumeRun(historyID uint) error {
+	if m.ResumeRunErr != nil {
+		return m.ResumeRunErr
+	}
+
+	m.ResumedRuns[historyID] = true
+	return nil
+}
+
+// ReleaseQuarantinedFile mocks releasing a quarantined file
+func (m *MockScheduler) ReleaseQuarantinedFile(metadataID uint) error {
+	if m.ReleaseFileErr != nil {
+		return m.ReleaseFileErr
+	}
+
+	m.ReleasedFiles[metadataID] = true
+	return nil
+}
+
+// DeliverAS2Payload mocks delivering a payload received over AS2
+func (m *MockScheduler) DeliverAS2Payload(configID uint, fileName, localPath string) (string, error) {
+	if m.DeliverAS2Err != nil {
+		return "", m.DeliverAS2Err
+	}
+
+	m.AS2Payloads[configID] = append(m.AS2Payloads[configID], fileName)
+	return fileName, nil
+}
+
 // UnscheduleJob mocks unscheduling a job
 func (m *MockScheduler) UnscheduleJob(jobID uint) {
 	m.UnscheduleJobCalls++
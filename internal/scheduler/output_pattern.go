@@ -0,0 +1,292 @@
+package scheduler
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/starfleetcptn/gomft/internal/db"
+	"github.com/starfleetcptn/gomft/internal/filters"
+)
+
+// patternVariableRegex matches ${name} and ${name:argument} variables
+var patternVariableRegex = regexp.MustCompile(`\$\{([a-z_]+)(?::([^}]*))?\}`)
+
+// outputPattern expands the output pattern of a config for the files of a single run
+type outputPattern struct {
+	pattern string
+	regex   *regexp.Regexp
+	job     string
+	config  string
+	runID   uint
+	now     time.Time
+}
+
+// outputPatternFile holds the values of a source file that are available to output patterns
+type outputPatternFile struct {
+	name    string
+	size    int64
+	hash    string
+	modTime time.Time
+	seq     int
+}
+
+// newOutputPattern prepares the output pattern of a config for a run
+func newOutputPattern(config *db.TransferConfig, jobName string, runID uint, now time.Time) (*outputPattern, error) {
+	p := &outputPattern{
+		pattern: config.OutputPattern,
+		job:     jobName,
+		config:  config.Name,
+		runID:   runID,
+		now:     now,
+	}
+	if config.OutputPatternRegex != "" {
+		regex, err := regexp.Compile(config.OutputPatternRegex)
+		if err != nil {
+			return nil, fmt.Errorf("invalid capture pattern: %v", err)
+		}
+		p.regex = regex
+	}
+	return p, nil
+}
+
+// fileName returns the destination name of a file and appends the extension added
+// by processing (e.g. ".gz"). Names are unchanged when no output pattern is set.
+func (p *outputPattern) fileName(file outputPatternFile, extension string) (string, error) {
+	if p.pattern == "" {
+		return file.name + extension, nil
+	}
+	name, err := p.expand(file)
+	if err != nil {
+		return "", err
+	}
+	if err := validateOutputPath(name); err != nil {
+		return "", err
+	}
+	return name + extension, nil
+}
+
+// expand replaces the variables of the pattern with the values of a file
+func (p *outputPattern) expand(file outputPatternFile) (string, error) {
+	var captures []string
+	if p.regex != nil {
+		captures = p.regex.FindStringSubmatch(path.Base(file.name))
+		if captures == nil {
+			return "", fmt.Errorf("%s does not match the capture pattern %s", path.Base(file.name), p.regex)
+		}
+	}
+	return p.substitute(file, captures)
+}
+
+// substitute replaces the variables of the pattern using the given capture groups.
+// Unsupported variables are left unchanged and reported by the returned error.
+func (p *outputPattern) substitute(file outputPatternFile, captures []string) (string, error) {
+	ext := filepath.Ext(file.name)
+	filename := strings.TrimSuffix(file.name, ext)
+
+	// A pattern using ${uuid} more than once gets the same value for each use
+	var fileUUID string
+
+	var firstErr error
+	result := patternVariableRegex.ReplaceAllStringFunc(p.pattern, func(match string) string {
+		parts := patternVariableRegex.FindStringSubmatch(match)
+		name, arg := parts[1], parts[2]
+
+		value, err := func() (string, error) {
+			switch name {
+			case "date", "mtime":
+				if arg == "" {
+					return "", fmt.Errorf("${%s} requires a format, e.g. ${%s:20060102}", name, name)
+				}
+				if name == "mtime" {
+					return file.modTime.Format(arg), nil
+				}
+				return p.now.Format(arg), nil
+			case "filename":
+				return filename, nil
+			case "ext":
+				// Remove leading dot from ext before replacing
+				return strings.TrimPrefix(ext, "."), nil
+			case "job":
+				return pathSafe(p.job), nil
+			case "config":
+				return pathSafe(p.config), nil
+			case "run_id":
+				return strconv.FormatUint(uint64(p.runID), 10), nil
+			case "hash":
+				if file.hash == "" {
+					return "", fmt.Errorf("no hash is available for %s", file.name)
+				}
+				return file.hash, nil
+			case "size":
+				return strconv.FormatInt(file.size, 10), nil
+			case "seq":
+				width := 0
+				if arg != "" {
+					parsed, err := strconv.Atoi(arg)
+					if err != nil || parsed < 1 || parsed > 20 {
+						return "", fmt.Errorf("invalid sequence width %q", arg)
+					}
+					width = parsed
+				}
+				return fmt.Sprintf("%0*d", width, file.seq), nil
+			case "uuid":
+				if fileUUID == "" {
+					fileUUID = uuid.NewString()
+				}
+				return fileUUID, nil
+			case "match":
+				return p.capture(captures, arg)
+			default:
+				return "", fmt.Errorf("unknown variable ${%s}", name)
+			}
+		}()
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			return match
+		}
+		return value
+	})
+	return result, firstErr
+}
+
+// capture returns a capture group of the source name by index or by name
+func (p *outputPattern) capture(captures []string, group string) (string, error) {
+	if p.regex == nil {
+		return "", fmt.Errorf("${match:%s} requires a capture pattern", group)
+	}
+	if index, err := strconv.Atoi(group); err == nil {
+		if index < 0 || index >= len(captures) {
+			return "", fmt.Errorf("capture pattern has no group %d", index)
+		}
+		return captures[index], nil
+	}
+	if index := p.regex.SubexpIndex(group); index >= 0 {
+		return captures[index], nil
+	}
+	return "", fmt.Errorf("capture pattern has no group named %q", group)
+}
+
+// pathSafe replaces path separators so names cannot create unexpected directories
+func pathSafe(name string) string {
+	return strings.NewReplacer("/", "_", "\\", "_").Replace(name)
+}
+
+// validateOutputPath checks that an expanded pattern is a relative path inside the destination
+func validateOutputPath(name string) error {
+	if name == "" {
+		return fmt.Errorf("output pattern produced an empty name")
+	}
+	if strings.HasPrefix(name, "/") {
+		return fmt.Errorf("output path %s must be relative to the destination", name)
+	}
+	if strings.HasSuffix(name, "/") {
+		return fmt.Errorf("output path %s must end with a file name", name)
+	}
+	for _, segment := range strings.Split(name, "/") {
+		if segment == "" || segment == "." || segment == ".." {
+			return fmt.Errorf("output path %s contains an invalid directory", name)
+		}
+	}
+	return nil
+}
+
+// OutputPatternPreview is the destination name produced for one example source name
+type OutputPatternPreview struct {
+	Source string
+	Result string
+	Error  string
+}
+
+// ValidateOutputPattern checks the output pattern and capture pattern of a config
+func ValidateOutputPattern(config *db.TransferConfig) error {
+	if config.OutputPattern == "" {
+		if config.OutputPatternRegex != "" {
+			return fmt.Errorf("a capture pattern requires an output pattern")
+		}
+		return nil
+	}
+	p, err := newOutputPattern(config, "job", 1, time.Now())
+	if err != nil {
+		return err
+	}
+	// The config may not have been named yet
+	p.config = "config"
+
+	// Every capture group is given a placeholder so the pattern can be checked
+	// without an example name
+	var captures []string
+	if p.regex != nil {
+		captures = make([]string, p.regex.NumSubexp()+1)
+		for i := range captures {
+			captures[i] = "x"
+		}
+	}
+	name, err := p.substitute(outputPatternFile{name: "example.txt", hash: "0", modTime: time.Now(), seq: 1}, captures)
+	if err != nil {
+		return err
+	}
+	return validateOutputPath(name)
+}
+
+// PreviewOutputPattern applies the output pattern of a config to example source names.
+// Each line holds a name, optionally followed by a size and an age separated by commas,
+// e.g. "reports/2024-01.csv, 10M, 2d". Lines are numbered as a run would number them.
+func PreviewOutputPattern(config *db.TransferConfig, jobName string, examples string, now time.Time) ([]OutputPatternPreview, error) {
+	if err := ValidateOutputPattern(config); err != nil {
+		return nil, err
+	}
+	p, err := newOutputPattern(config, jobName, 1, now)
+	if err != nil {
+		return nil, err
+	}
+
+	var previews []OutputPatternPreview
+	for i, line := range strings.Split(examples, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		parts := strings.Split(line, ",")
+		if len(parts) > 3 {
+			return nil, fmt.Errorf("line %d: expected name, size and age", i+1)
+		}
+		file := outputPatternFile{
+			name:    strings.TrimSpace(parts[0]),
+			hash:    "d41d8cd98f00b204e9800998ecf8427e",
+			modTime: now,
+			seq:     len(previews) + 1,
+		}
+		if len(parts) > 1 && strings.TrimSpace(parts[1]) != "" {
+			size, err := filters.ParseSize(parts[1])
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %v", i+1, err)
+			}
+			file.size = size
+		}
+		if len(parts) > 2 && strings.TrimSpace(parts[2]) != "" {
+			age, err := filters.ParseAge(parts[2])
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %v", i+1, err)
+			}
+			file.modTime = now.Add(-age)
+		}
+
+		preview := OutputPatternPreview{Source: file.name}
+		if result, err := p.fileName(file, ""); err != nil {
+			preview.Error = err.Error()
+		} else {
+			preview.Result = result
+		}
+		previews = append(previews, preview)
+	}
+	return previews, nil
+}
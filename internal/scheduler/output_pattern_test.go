@@ -0,0 +1,126 @@
+package scheduler
+
+import (
+	"reflect"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/starfleetcptn/gomft/internal/db"
+)
+
+func TestOutputPatternFileName(t *testing.T) {
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	modTime := time.Date(2024, 12, 24, 8, 30, 0, 0, time.UTC)
+	file := outputPatternFile{name: "in/invoice_2024-12_ACME.pdf", size: 2048, hash: "abc123", modTime: modTime, seq: 7}
+
+	tests := []struct {
+		name    string
+		pattern string
+		regex   string
+		want    string
+		wantErr bool
+	}{
+		{"No pattern", "", "", "in/invoice_2024-12_ACME.pdf", false},
+		{"Modification time directories", "${mtime:2006/01/02}/${filename}.${ext}", "", "2024/12/24/in/invoice_2024-12_ACME.pdf", false},
+		{"Run date", "${date:20060102}_${seq:4}.${ext}", "", "20250301_0007.pdf", false},
+		{"Job, config and run", "${job}/${config}/${run_id}-${seq}.${ext}", "", "nightly_exports/partners/42-7.pdf", false},
+		{"Hash and size", "${hash}_${size}", "", "abc123_2048", false},
+		{"Numbered capture groups", "${match:2}/${match:1}.pdf", `^invoice_(\d{4}-\d{2})_(\w+)\.pdf$`, "ACME/2024-12.pdf", false},
+		{"Named capture groups", "${match:customer}/${match:month}.pdf", `^invoice_(?P<month>[\d-]+)_(?P<customer>\w+)`, "ACME/2024-12.pdf", false},
+		{"Capture pattern does not match", "${match:1}", `^receipt_(\d+)`, "", true},
+		{"Unknown variable", "${customer}.pdf", "", "", true},
+		{"Missing date format", "${mtime}/${filename}", "", "", true},
+		{"Escapes the destination", "../${filename}", "", "", true},
+		{"Absolute path", "/${filename}", "", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &db.TransferConfig{Name: "partners", OutputPattern: tt.pattern, OutputPatternRegex: tt.regex}
+			pattern, err := newOutputPattern(config, "nightly/exports", 42, now)
+			if err != nil {
+				t.Fatalf("newOutputPattern() error = %v", err)
+			}
+			got, err := pattern.fileName(file, "")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("fileName() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("fileName() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOutputPatternUUID(t *testing.T) {
+	pattern := &outputPattern{pattern: "${uuid}/${uuid}"}
+	got, err := pattern.fileName(outputPatternFile{name: "a.txt"}, "")
+	if err != nil {
+		t.Fatalf("fileName() error = %v", err)
+	}
+	uuid := `[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}`
+	if !regexp.MustCompile(`^(`+uuid+`)/(`+uuid+`)$`).MatchString(got) || got[:36] != got[37:] {
+		t.Errorf("fileName() = %q, want the same uuid twice", got)
+	}
+	if other, _ := pattern.fileName(outputPatternFile{name: "a.txt"}, ""); other == got {
+		t.Errorf("fileName() returned the same uuid for two files")
+	}
+}
+
+func TestValidateOutputPattern(t *testing.T) {
+	tests := []struct {
+		name    string
+		pattern string
+		regex   string
+		wantErr bool
+	}{
+		{"Empty", "", "", false},
+		{"All variables", "${job}/${config}/${date:2006}/${mtime:01}/${run_id}_${seq:3}_${uuid}_${hash}_${size}_${filename}.${ext}", "", false},
+		{"Capture groups", "${match:1}/${match:name}", `(\d+)_(?P<name>\w+)`, false},
+		{"Capture pattern without output pattern", "", `(\d+)`, true},
+		{"Invalid capture pattern", "${match:1}", `(\d+`, true},
+		{"Missing capture pattern", "${match:1}", "", true},
+		{"Unknown group", "${match:3}", `(\d+)`, true},
+		{"Unknown group name", "${match:year}", `(\d+)`, true},
+		{"Invalid sequence width", "${seq:x}", "", true},
+		{"Unknown variable", "${hostname}", "", true},
+		{"Empty directory", "${date:2006}//${filename}", "", true},
+		{"Trailing slash", "${date:2006}/", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &db.TransferConfig{OutputPattern: tt.pattern, OutputPatternRegex: tt.regex}
+			if err := ValidateOutputPattern(config); (err != nil) != tt.wantErr {
+				t.Errorf("ValidateOutputPattern() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestPreviewOutputPattern(t *testing.T) {
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	config := &db.TransferConfig{
+		Name:               "reports",
+		OutputPattern:      "${mtime:2006-01-02}/${match:1}_${seq:2}.${ext}",
+		OutputPatternRegex: `^report_(\w+)`,
+	}
+
+	previews, err := PreviewOutputPattern(config, "daily", "report_sales.csv, 10M, 2d\n\nnotes.txt\nreport_hr.csv\n", now)
+	if err != nil {
+		t.Fatalf("PreviewOutputPattern() error = %v", err)
+	}
+	want := []OutputPatternPreview{
+		{Source: "report_sales.csv", Result: "2025-02-27/sales_01.csv"},
+		{Source: "notes.txt", Error: "notes.txt does not match the capture pattern ^report_(\\w+)"},
+		{Source: "report_hr.csv", Result: "2025-03-01/hr_03.csv"},
+	}
+	if !reflect.DeepEqual(previews, want) {
+		t.Errorf("PreviewOutputPattern() = %+v, want %+v", previews, want)
+	}
+
+	if _, err := PreviewOutputPattern(config, "daily", "report_a.csv, huge", now); err == nil {
+		t.Error("PreviewOutputPattern() expected error for invalid size")
+	}
+}
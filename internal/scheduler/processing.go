@@ -0,0 +1,269 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/starfleetcptn/gomft/internal/db"
+	"github.com/starfleetcptn/gomft/internal/encryption"
+)
+
+// requiresLocalProcessing reports whether files of a config have to be staged
+// locally so they can be processed before delivery or sent over AS2
+func requiresLocalProcessing(config *db.TransferConfig) bool {
+	return config.CompressionType != compressionNone || config.GetDecompressOnReceive() ||
+		config.EncryptionMode != encryptionModeNone || isAS2Destination(config)
+}
+
+// fileCommandFor maps directory based transfer commands to their file-by-file equivalent
+func fileCommandFor(commandName string) string {
+	switch commandName {
+	case "copy":
+		return "copyto"
+	case "move":
+		return "moveto"
+	default:
+		return commandName
+	}
+}
+
+// bundleFileName returns the destination name of the zip bundle for a run
+func bundleFileName(config *db.TransferConfig) string {
+	pattern := config.BundleName
+	if pattern == "" {
+		pattern = "gomft_${date:20060102_150405}"
+	}
+	name := ProcessOutputPattern(pattern, config.Name)
+	if !strings.HasSuffix(strings.ToLower(name), ".zip") {
+		name += ".zip"
+	}
+	return name
+}
+
+// stageFile downloads a source file into the staging directory and applies the
+// configured decryption, decompression, compression and encryption steps. The returned
+// function removes the staged copies, call it once they are delivered or bundled.
+func (te *TransferExecutor) stageFile(config *db.TransferConfig, keys *encryptionKeys, rclonePath, configPath, stagingDir, sourcePath, fileName string, fileSize int64) (_ []stagedFile, _ func(), err error) {
+	fileDir, err := os.MkdirTemp(stagingDir, "file-*")
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create staging directory: %v", err)
+	}
+	defer func() {
+		if err != nil {
+			os.RemoveAll(fileDir)
+		}
+	}()
+
+	localPath := filepath.Join(fileDir, "in", filepath.Base(fileName))
+	if err := os.MkdirAll(filepath.Dir(localPath), 0700); err != nil {
+		return nil, nil, fmt.Errorf("failed to create staging directory: %v", err)
+	}
+
+	// Always copy into the staging area, the source is only removed after delivery
+	downloadArgs := []string{
+		"--config", configPath,
+		"copyto",
+		sourcePath,
+		localPath,
+	}
+	te.logger.LogDebug("Staging file %s for config %d: %s %v", fileName, config.ID, rclonePath, downloadArgs)
+	downloadCmd := execCommandContext(context.Background(), rclonePath, downloadArgs...)
+	if output, err := downloadCmd.CombinedOutput(); err != nil {
+		return nil, nil, withHostKeyFailure(fmt.Errorf("failed to download file for processing: %v\nOutput: %s", err, string(output)), string(output))
+	}
+
+	files := []stagedFile{{path: localPath, name: fileName, originalSize: fileSize}}
+
+	if config.EncryptionMode == encryptionModeDecrypt {
+		decrypted, err := decryptStagedFile(files[0], filepath.Join(fileDir, "dec"), keys, config.GetRequireSignature())
+		if err != nil {
+			return nil, nil, err
+		}
+		te.logger.LogDebug("Decrypted %s with key %s (signature: %s)", fileName, decrypted.encryption.Fingerprint, decrypted.encryption.SignatureStatus)
+		files = []stagedFile{decrypted}
+	}
+
+	if config.GetDecompressOnReceive() {
+		var decompressed []stagedFile
+		for _, file := range files {
+			outputs, err := decompressFile(file, filepath.Join(fileDir, "out"))
+			if err != nil {
+				return nil, nil, err
+			}
+			// Keep the decryption result on every file extracted from the source file
+			for i := range outputs {
+				outputs[i].encryption = file.encryption
+			}
+			decompressed = append(decompressed, outputs...)
+		}
+		files = decompressed
+	}
+
+	if config.CompressionType == compressionGzip || config.CompressionType == compressionZstd {
+		extension := compressionExtension(config.CompressionType)
+		for i := range files {
+			compressedPath := files[i].path + extension
+			size, err := compressFile(files[i].path, compressedPath, config.CompressionType)
+			if err != nil {
+				return nil, nil, err
+			}
+			te.logger.LogDebug("Compressed %s with %s: %d -> %d bytes", files[i].name, config.CompressionType, files[i].originalSize, size)
+			files[i].path = compressedPath
+			files[i].extension = extension
+			files[i].compressedSize = size
+		}
+	}
+
+	// Zip bundles are encrypted as a whole once every file has been staged
+	if config.EncryptionMode == encryptionModeEncrypt && config.CompressionType != compressionZip {
+		for i := range files {
+			if err := encryptStagedFile(&files[i], keys); err != nil {
+				return nil, nil, err
+			}
+			te.logger.LogDebug("Encrypted %s for %s", files[i].name, files[i].encryption.Fingerprint)
+		}
+	}
+
+	return files, func() { os.RemoveAll(fileDir) }, nil
+}
+
+// uploadLocalFile uploads a local file to the destination remote using copyto
+func (te *TransferExecutor) uploadLocalFile(config *db.TransferConfig, rclonePath, localPath, destFile string) error {
+	uploadFile := uploadName(config, destFile)
+	uploadArgs := te.prepareBaseArguments("copyto", config, nil)
+	uploadArgs = append(uploadArgs, localPath, buildDestRemotePath(config, uploadFile))
+
+	te.logger.LogDebug("Full upload command: %s %v", rclonePath, uploadArgs)
+	uploadCmd := execCommandContext(context.Background(), rclonePath, uploadArgs...)
+	output, err := uploadCmd.CombinedOutput()
+	te.logger.LogDebug("Output for upload of %s: %s", destFile, string(output))
+	if err != nil {
+		return withHostKeyFailure(fmt.Errorf("failed to upload %s: %v", destFile, err), string(output))
+	}
+
+	expectedSize := int64(-1)
+	if info, err := os.Stat(localPath); err == nil {
+		expectedSize = info.Size()
+	}
+	return te.completeDelivery(config, te.db.GetConfigRclonePath(config), rclonePath, uploadFile, destFile, expectedSize)
+}
+
+// bundleEntry is a staged file waiting to be delivered as part of a zip bundle
+type bundleEntry struct {
+	file       stagedFile
+	sourcePath string
+	runFileID  uint
+	metadata   *db.FileMetadata
+	cleanup    func() // Removes the staged copy once it is in the bundle
+}
+
+// deliverBundle writes all staged files of a run into a single zip archive, uploads it
+// and finalizes the source files. It returns the metadata of the source files delivered
+// and records the name of the bundle at each destination in destNames.
+func (te *TransferExecutor) deliverBundle(job db.Job, config *db.TransferConfig, targets []deliveryTarget, keys *encryptionKeys, configPath, rclonePath, stagingDir string, deleteSource bool, entries []*bundleEntry, destNames *deliveredNames, transferErrors *[]string) []*db.FileMetadata {
+	bundleName := bundleFileName(config)
+	files := make([]*stagedFile, len(entries))
+	for i, entry := range entries {
+		files[i] = &entry.file
+	}
+
+	te.logger.LogInfo("Bundling %d files into %s for job %d, config %d", len(entries), bundleName, job.ID, config.ID)
+	bundlePath := filepath.Join(stagingDir, filepath.Base(bundleName))
+	bundleSize, err := writeZipBundle(bundlePath, files)
+	// The staged copies are in the bundle now, or cannot be delivered with it
+	for _, entry := range entries {
+		if entry.cleanup != nil {
+			entry.cleanup()
+		}
+	}
+	var bundleEncryption *encryption.Result
+	if err == nil {
+		te.logger.LogDebug("Created zip bundle %s (%d bytes)", bundleName, bundleSize)
+		if config.EncryptionMode == encryptionModeEncrypt {
+			bundle := stagedFile{path: bundlePath, name: bundleName}
+			if err = encryptStagedFile(&bundle, keys); err == nil {
+				bundlePath = bundle.path
+				bundleName += bundle.extension
+				bundleEncryption = bundle.encryption
+			}
+		}
+	}
+	var outcome deliveryOutcome
+	if err == nil {
+		// Each destination receives the bundle in turn
+		for _, target := range targets {
+			destFile, action, deliverErr := te.deliverLocalFile(target, configPath, rclonePath, bundlePath, bundleName)
+			outcome.add(target, []string{destFile}, action, deliverErr)
+		}
+		err = outcome.err()
+	}
+	conflictAction := outcome.conflictAction
+
+	// A single source file may yield several entries when it was decompressed,
+	// so sizes are summed and the source is finalized once
+	var sources []string
+	bySource := make(map[string][]*bundleEntry)
+	for _, entry := range entries {
+		if _, ok := bySource[entry.sourcePath]; !ok {
+			sources = append(sources, entry.sourcePath)
+		}
+		bySource[entry.sourcePath] = append(bySource[entry.sourcePath], entry)
+	}
+
+	if err != nil {
+		te.logger.LogError("Error delivering zip bundle %s for job %d, config %d: %v", bundleName, job.ID, config.ID, err)
+		*transferErrors = append(*transferErrors, fmt.Sprintf("Bundle %s: %v", bundleName, err))
+	} else if conflictAction != conflictSkipped {
+		destNames.add(outcome.names)
+	}
+
+	var delivered []*db.FileMetadata
+	for _, sourcePath := range sources {
+		sourceEntries := bySource[sourcePath]
+		metadata := sourceEntries[0].metadata
+		metadata.ProcessedTime = time.Now()
+		for _, entry := range sourceEntries {
+			metadata.OriginalSize += entry.file.originalSize
+			metadata.CompressedSize += entry.file.compressedSize
+		}
+		applyEncryptionResult(metadata, sourceEntries[0].file.encryption)
+		applyEncryptionResult(metadata, bundleEncryption)
+		metadata.ConflictAction = conflictAction
+		metadata.SetDestinationResults(outcome.destinationResults())
+
+		if err != nil {
+			metadata.Status = "error"
+			metadata.ErrorMessage = err.Error()
+		} else if conflictAction == conflictSkipped {
+			// The sources are kept so they can be delivered once the conflict is resolved
+			metadata.Status = "skipped"
+		} else {
+			delivered = append(delivered, metadata)
+			metadata.DestinationPath = strings.Join(outcome.paths, ", ")
+			metadata.Status = "processed"
+
+			var postErrors []string
+			if deleteSource {
+				// The bundle has been delivered, so the source can now be removed
+				if deleteErr := te.deleteSourceFile(configPath, rclonePath, sourcePath); deleteErr != nil {
+					postErrors = append(postErrors, fmt.Sprintf("Delete error for file %s: %v", metadata.FileName, deleteErr))
+				}
+			}
+			status, finalizeErrors := te.finalizeSourceFile(job, config, configPath, rclonePath, sourcePath, metadata.FileName)
+			metadata.Status = status
+			postErrors = append(postErrors, finalizeErrors...)
+			*transferErrors = append(*transferErrors, postErrors...)
+		}
+
+		if createErr := te.db.CreateFileMetadata(metadata); createErr != nil { // Calls interface method
+			te.logger.LogError("Error creating file metadata for %s: %v", metadata.FileName, createErr)
+		}
+		te.updateCheckpoint(sourceEntries[0].runFileID, metadata.Status, metadata.ErrorMessage)
+	}
+
+	return delivered
+}
@@ -0,0 +1,109 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+
+	"github.com/starfleetcptn/gomft/internal/db"
+)
+
+// Statuses of the file metadata of quarantined files
+const (
+	fileStatusQuarantined = "quarantined"
+	fileStatusReleased    = "released"
+)
+
+// usesQuarantine reports whether files that keep failing are moved to a quarantine folder
+func usesQuarantine(config *db.TransferConfig) bool {
+	return config.QuarantinePath != ""
+}
+
+// ValidateQuarantine checks the quarantine options of a config
+func ValidateQuarantine(config *db.TransferConfig) error {
+	if !usesQuarantine(config) {
+		return nil
+	}
+	if config.QuarantineAfter < 1 {
+		return fmt.Errorf("files must be quarantined after at least one failure")
+	}
+	// A quarantine folder inside the source directory is excluded from the listing
+	if path.Clean("/"+config.QuarantinePath) == path.Clean("/"+config.SourcePath) {
+		return fmt.Errorf("the quarantine path must differ from the source path")
+	}
+	return nil
+}
+
+// buildQuarantineRemotePath returns the rclone path of a file in the quarantine folder on the source
+func buildQuarantineRemotePath(config *db.TransferConfig, fileName string) string {
+	return buildSourceFolderRemotePath(config, config.QuarantinePath, fileName)
+}
+
+// consecutiveFailures returns the number of failed transfers of a file including the
+// current one, counted from its most recent metadata
+func (te *TransferExecutor) consecutiveFailures(job db.Job, config *db.TransferConfig, fileName string) int {
+	previous, err := te.db.GetLatestFileMetadata(job.ID, config.ID, fileName) // Calls interface method
+	if err != nil || previous.Status != "error" {
+		return 1
+	}
+	return previous.FailureCount + 1
+}
+
+// quarantineFile moves a file that failed too often from the source to the quarantine
+// folder, so it no longer fails every run
+func (te *TransferExecutor) quarantineFile(job db.Job, config *db.TransferConfig, configPath, rclonePath, sourcePath, fileName string) error {
+	args := []string{"--config", configPath, "moveto", sourcePath, buildQuarantineRemotePath(config, fileName)}
+	te.logger.LogDebug("Full quarantine command: %s %v", rclonePath, args)
+	output, err := execCommandContext(context.Background(), rclonePath, args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%v\nOutput: %s", err, string(output))
+	}
+	te.logger.LogInfo("Quarantined file %s for job %d, config %d", fileName, job.ID, config.ID)
+	return nil
+}
+
+// releaseQuarantinedFile moves a quarantined file back to the source so the next run
+// transfers it again
+func (te *TransferExecutor) releaseQuarantinedFile(config *db.TransferConfig, metadata *db.FileMetadata) error {
+	if metadata.Status != fileStatusQuarantined {
+		return fmt.Errorf("file %s is not quarantined", metadata.FileName)
+	}
+	if !usesQuarantine(config) {
+		return fmt.Errorf("configuration %d has no quarantine path", config.ID)
+	}
+
+	rclonePath := os.Getenv("RCLONE_PATH")
+	if rclonePath == "" {
+		rclonePath = "rclone"
+	}
+	if err := te.pinHostKeysOnFirstUse(config); err != nil {
+		return err
+	}
+	releaseSSHKeys, err := te.db.WriteSSHKeys(config) // Calls interface method
+	if err != nil {
+		return err
+	}
+	defer releaseSSHKeys()
+
+	args := []string{
+		"--config", te.db.GetConfigRclonePath(config), // Calls interface method
+		"moveto",
+		buildQuarantineRemotePath(config, metadata.FileName),
+		buildSourceRemotePath(config, metadata.FileName),
+	}
+	te.logger.LogDebug("Full release command: %s %v", rclonePath, args)
+	output, err := execCommandContext(context.Background(), rclonePath, args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("failed to release %s: %v\nOutput: %s", metadata.FileName, err, string(output))
+	}
+
+	// The failures start over once the file is back in the source
+	metadata.Status = fileStatusReleased
+	metadata.FailureCount = 0
+	if err := te.db.UpdateFileMetadata(metadata); err != nil { // Calls interface method
+		return fmt.Errorf("released %s but failed to update its metadata: %v", metadata.FileName, err)
+	}
+	te.logger.LogInfo("Released quarantined file %s of job %d, config %d", metadata.FileName, metadata.JobID, config.ID)
+	return nil
+}
@@ -0,0 +1,153 @@
+package scheduler
+
+import (
+	"testing"
+
+	"github.com/starfleetcptn/gomft/internal/db"
+	"gorm.io/gorm"
+)
+
+func TestValidateQuarantine(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  db.TransferConfig
+		wantErr bool
+	}{
+		{"Disabled", db.TransferConfig{}, false},
+		{"Enabled", db.TransferConfig{SourcePath: "/in", QuarantinePath: "/quarantine", QuarantineAfter: 3}, false},
+		{"No threshold", db.TransferConfig{SourcePath: "/in", QuarantinePath: "/quarantine"}, true},
+		{"Source path", db.TransferConfig{SourcePath: "/in", QuarantinePath: "/in", QuarantineAfter: 3}, true},
+		{"Source path with trailing slash", db.TransferConfig{SourcePath: "/in/", QuarantinePath: "/in", QuarantineAfter: 3}, true},
+		{"Inside the source path", db.TransferConfig{SourcePath: "/in", QuarantinePath: "/in/quarantine", QuarantineAfter: 3}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := ValidateQuarantine(&tt.config); (err != nil) != tt.wantErr {
+				t.Errorf("ValidateQuarantine() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSourceFolderInRoot(t *testing.T) {
+	tests := []struct {
+		name       string
+		sourcePath string
+		folder     string
+		want       string
+	}{
+		{"Outside the source", "/in", "/quarantine", ""},
+		{"Inside the source", "/in", "/in/quarantine", "quarantine"},
+		{"Nested inside the source", "in/", "/in/failed/quarantine/", "failed/quarantine"},
+		{"Sibling with the same prefix", "/in", "/inbox", ""},
+		{"Source at the root", "/", "/quarantine", "quarantine"},
+		{"No folder", "/in", "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &db.TransferConfig{SourceType: "sftp", SourcePath: tt.sourcePath}
+			if got := sourceFolderInRoot(config, tt.folder); got != tt.want {
+				t.Errorf("sourceFolderInRoot() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExecuteConfigTransfer_Quarantine(t *testing.T) {
+	listing := `[{"Path":"a.csv","Size":10}]`
+	tests := []struct {
+		name         string
+		previous     *db.FileMetadata
+		wantFailures int
+		wantStatus   string
+	}{
+		{"First failure", nil, 1, "error"},
+		{"Failure after a delivery", &db.FileMetadata{Status: "processed"}, 1, "error"},
+		{"Threshold reached", &db.FileMetadata{Status: "error", FailureCount: 2}, 3, "quarantined"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			comps := setupTestExecutor()
+			defer comps.logger.Close()
+			if tt.previous != nil {
+				comps.db.GetLatestFileMetadataFunc = func(jobID, configID uint, fileName string) (*db.FileMetadata, error) {
+					return tt.previous, nil
+				}
+			}
+			var calls [][]string
+			defer mockRcloneSequence([]string{listing, "error:connection refused", ""}, &calls)()
+
+			config := db.TransferConfig{ID: 2, SourceType: "local", SourcePath: "/src", DestinationType: "local", DestinationPath: "/dst",
+				QuarantinePath: "/quarantine", QuarantineAfter: 3}
+			history := &db.JobHistory{ID: 7, JobID: 1, ConfigID: 2}
+			comps.executor.executeConfigTransfer(db.Job{ID: 1}, config, history)
+
+			if len(comps.db.createdMetadata) != 1 {
+				t.Fatalf("executeConfigTransfer() created %d metadata records, want 1", len(comps.db.createdMetadata))
+			}
+			metadata := comps.db.createdMetadata[0]
+			if metadata.Status != tt.wantStatus || metadata.FailureCount != tt.wantFailures {
+				t.Errorf("metadata status = %q, failures = %d, want %q, %d", metadata.Status, metadata.FailureCount, tt.wantStatus, tt.wantFailures)
+			}
+
+			// The file is moved to the quarantine folder on the source once the threshold is reached
+			quarantined := len(calls) == 3 && hasArg(calls[2], "moveto") &&
+				calls[2][len(calls[2])-2] == "source_2:/src/a.csv" && calls[2][len(calls[2])-1] == "source_2:/quarantine/a.csv"
+			if quarantined != (tt.wantStatus == "quarantined") || (!quarantined && len(calls) != 2) {
+				t.Errorf("executeConfigTransfer() rclone calls = %v", calls)
+			}
+			if history.Status != "completed_with_errors" {
+				t.Errorf("history status = %q, want completed_with_errors", history.Status)
+			}
+		})
+	}
+}
+
+func TestReleaseQuarantinedFile(t *testing.T) {
+	comps := setupTestExecutor()
+	defer comps.logger.Close()
+	var calls [][]string
+	defer mockRcloneSequence(nil, &calls)()
+
+	config := &db.TransferConfig{ID: 2, SourceType: "local", SourcePath: "/src", QuarantinePath: "/quarantine", QuarantineAfter: 3}
+
+	// Only quarantined files can be released
+	if err := comps.executor.releaseQuarantinedFile(config, &db.FileMetadata{FileName: "a.csv", Status: "error"}); err == nil {
+		t.Error("releaseQuarantinedFile() of a failed file succeeded, want an error")
+	}
+
+	metadata := &db.FileMetadata{FileName: "in/a.csv", Status: "quarantined", FailureCount: 3}
+	if err := comps.executor.releaseQuarantinedFile(config, metadata); err != nil {
+		t.Fatalf("releaseQuarantinedFile() error = %v", err)
+	}
+	if len(calls) != 1 || calls[0][len(calls[0])-2] != "source_2:/quarantine/in/a.csv" || calls[0][len(calls[0])-1] != "source_2:/src/in/a.csv" {
+		t.Errorf("releaseQuarantinedFile() rclone calls = %v", calls)
+	}
+	if metadata.Status != "released" || metadata.FailureCount != 0 || len(comps.db.updatedMetadata) != 1 {
+		t.Errorf("releaseQuarantinedFile() metadata = %+v", metadata)
+	}
+}
+
+func TestReleaseFile(t *testing.T) {
+	comps := setupTestJobExecutor()
+	defer comps.logger.Close()
+	comps.db.FirstFunc = func(dest interface{}, conds ...interface{}) *gorm.DB {
+		switch d := dest.(type) {
+		case *db.FileMetadata:
+			*d = db.FileMetadata{ID: 5, ConfigID: 2, FileName: "a.csv", Status: "quarantined"}
+		case *db.TransferConfig:
+			*d = db.TransferConfig{ID: 2, QuarantinePath: "/quarantine"}
+		}
+		return &gorm.DB{Error: nil}
+	}
+
+	if err := comps.executor.releaseFile(5); err != nil {
+		t.Fatalf("releaseFile() error = %v", err)
+	}
+	if len(comps.transfer.releasedFiles) != 1 || comps.transfer.releasedFiles[0].ID != 5 {
+		t.Errorf("releaseFile() released %v, want file 5", comps.transfer.releasedFiles)
+	}
+}
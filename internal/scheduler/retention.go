@@ -0,0 +1,273 @@
+package scheduler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/starfleetcptn/gomft/internal/db"
+	"github.com/starfleetcptn/gomft/internal/filters"
+)
+
+// Retention locations
+const (
+	retentionArchive     = "archive"
+	retentionDestination = "destination"
+)
+
+// retentionRule selects the files of a location that are no longer kept
+type retentionRule struct {
+	keepLast int
+	maxAge   time.Duration
+	maxSize  int64
+}
+
+// isEmpty reports whether the rule keeps every file
+func (r retentionRule) isEmpty() bool {
+	return r.keepLast <= 0 && r.maxAge <= 0 && r.maxSize <= 0
+}
+
+// newRetentionRule builds a rule from the configured values of a location
+func newRetentionRule(keepLast, maxAgeDays int, maxSize string) (retentionRule, error) {
+	if keepLast < 0 {
+		return retentionRule{}, fmt.Errorf("number of files to keep cannot be negative")
+	}
+	if maxAgeDays < 0 {
+		return retentionRule{}, fmt.Errorf("maximum age cannot be negative")
+	}
+	rule := retentionRule{keepLast: keepLast, maxAge: time.Duration(maxAgeDays) * 24 * time.Hour}
+	if maxSize != "" {
+		size, err := filters.ParseSize(maxSize)
+		if err != nil {
+			return retentionRule{}, fmt.Errorf("invalid total size: %v", err)
+		}
+		rule.maxSize = size
+	}
+	return rule, nil
+}
+
+// retentionRules returns the retention rules of the archive and the destination of a config
+func retentionRules(config *db.TransferConfig) (archive, destination retentionRule, err error) {
+	archive, err = newRetentionRule(config.ArchiveRetentionKeepLast, config.ArchiveRetentionMaxAgeDays, config.ArchiveRetentionMaxSize)
+	if err != nil {
+		return retentionRule{}, retentionRule{}, fmt.Errorf("archive retention: %v", err)
+	}
+	destination, err = newRetentionRule(config.DestRetentionKeepLast, config.DestRetentionMaxAgeDays, config.DestRetentionMaxSize)
+	if err != nil {
+		return retentionRule{}, retentionRule{}, fmt.Errorf("destination retention: %v", err)
+	}
+	return archive, destination, nil
+}
+
+// ValidateRetention checks the retention rules of a config for the command it runs.
+// bisync propagates deletions, so files removed from the destination by retention
+// would be deleted from the source as well.
+func ValidateRetention(config *db.TransferConfig, commandName string) error {
+	_, destination, err := retentionRules(config)
+	if err != nil {
+		return err
+	}
+	if commandName == "bisync" && !destination.isEmpty() {
+		return fmt.Errorf("destination retention cannot be used with bisync, which would delete the files from the source as well")
+	}
+	return nil
+}
+
+// expired returns the lsjson entries that the rule no longer keeps. Files are ranked
+// newest first; a file is expired when it falls outside the newest keepLast files,
+// is older than maxAge, or would take the kept files above maxSize.
+func (r retentionRule) expired(files []map[string]interface{}, now time.Time) []map[string]interface{} {
+	sorted := make([]map[string]interface{}, len(files))
+	copy(sorted, files)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		first, _ := fileModTime(sorted[i])
+		second, _ := fileModTime(sorted[j])
+		return first.After(second)
+	})
+
+	var expired []map[string]interface{}
+	var keptSize int64
+	for i, entry := range sorted {
+		size, _ := entry["Size"].(float64)
+		modTime, hasModTime := fileModTime(entry)
+
+		switch {
+		case r.keepLast > 0 && i >= r.keepLast:
+			expired = append(expired, entry)
+		case r.maxAge > 0 && hasModTime && now.Sub(modTime) > r.maxAge:
+			expired = append(expired, entry)
+		case r.maxSize > 0 && keptSize+int64(size) > r.maxSize:
+			expired = append(expired, entry)
+		default:
+			keptSize += int64(size)
+		}
+	}
+	return expired
+}
+
+// retentionRoot returns the rclone path of a retention location
+func retentionRoot(config *db.TransferConfig, location string) string {
+	if location == retentionArchive {
+		return strings.TrimSuffix(buildArchiveRemotePath(config, ""), "/")
+	}
+	return buildDestRemoteRoot(config)
+}
+
+// applyRetention runs the retention rules of a config after a run of a command. Deleted
+// files are recorded in the file metadata and the audit log. In preview mode files are
+// only reported. It returns the errors to add to the job history.
+func (te *TransferExecutor) applyRetention(job db.Job, config *db.TransferConfig, commandName, configPath, rclonePath string) []string {
+	if err := ValidateRetention(config, commandName); err != nil {
+		te.logger.LogError("Invalid retention rules for job %d, config %d: %v", job.ID, config.ID, err)
+		return []string{fmt.Sprintf("Retention error: %v", err)}
+	}
+	archive, destination, err := retentionRules(config)
+	if err != nil {
+		te.logger.LogError("Invalid retention rules for job %d, config %d: %v", job.ID, config.ID, err)
+		return []string{fmt.Sprintf("Retention error: %v", err)}
+	}
+
+	var errors []string
+	if !archive.isEmpty() && config.GetArchiveEnabled() && config.ArchivePath != "" {
+		errors = append(errors, te.applyRetentionRule(job, config, retentionArchive, archive, configPath, rclonePath)...)
+	}
+	if !destination.isEmpty() {
+		errors = append(errors, te.applyRetentionRule(job, config, retentionDestination, destination, configPath, rclonePath)...)
+	}
+	return errors
+}
+
+// applyRetentionRule lists a retention location and deletes the files the rule no longer keeps
+func (te *TransferExecutor) applyRetentionRule(job db.Job, config *db.TransferConfig, location string, rule retentionRule, configPath, rclonePath string) []string {
+	root := retentionRoot(config, location)
+	preview := config.GetRetentionPreview()
+
+	listArgs := []string{
+		"--config", configPath,
+		"lsjson",
+		"--recursive",
+		"--files-only",
+		"--no-mimetype",
+		root,
+	}
+	listCmd := execCommandContext(context.Background(), rclonePath, listArgs...)
+	output, err := listCmd.CombinedOutput()
+	if err != nil {
+		te.logger.LogError("Error listing %s for retention of job %d, config %d: %v", location, job.ID, config.ID, err)
+		return []string{fmt.Sprintf("Retention error for %s: %v", location, err)}
+	}
+	var files []map[string]interface{}
+	if err := json.Unmarshal(output, &files); err != nil {
+		te.logger.LogError("Error parsing %s listing for retention of job %d, config %d: %v", location, job.ID, config.ID, err)
+		return []string{fmt.Sprintf("Retention error for %s: %v", location, err)}
+	}
+
+	if location == retentionDestination {
+		files = withoutArtifacts(config, files)
+	}
+
+	expired := rule.expired(files, time.Now())
+	if len(expired) == 0 {
+		te.logger.LogDebug("Retention keeps all %d files in %s for job %d, config %d", len(files), location, job.ID, config.ID)
+		return nil
+	}
+
+	var errors []string
+	var deleted []string
+	var deletedSize int64
+	for _, entry := range expired {
+		filePath, _ := entry["Path"].(string)
+		size, _ := entry["Size"].(float64)
+		if preview {
+			te.logger.LogInfo("Retention preview: would delete %s from %s for job %d, config %d", filePath, location, job.ID, config.ID)
+			deleted = append(deleted, filePath)
+			deletedSize += int64(size)
+			continue
+		}
+
+		te.logger.LogInfo("Retention: deleting %s from %s for job %d, config %d", filePath, location, job.ID, config.ID)
+		if err := te.deleteSourceFile(configPath, rclonePath, joinRemotePath(root, filePath)); err != nil {
+			te.logger.LogError("Error deleting %s from %s for job %d, config %d: %v", filePath, location, job.ID, config.ID, err)
+			errors = append(errors, fmt.Sprintf("Retention delete error for %s in %s: %v", filePath, location, err))
+			continue
+		}
+		deleted = append(deleted, filePath)
+		deletedSize += int64(size)
+
+		modTime, _ := fileModTime(entry)
+		metadata := &db.FileMetadata{
+			JobID:         job.ID,
+			ConfigID:      config.ID,
+			FileName:      filePath,
+			OriginalPath:  root,
+			FileSize:      int64(size),
+			ModTime:       modTime,
+			ProcessedTime: time.Now(),
+			Status:        db.FileStatusRetentionDeleted,
+		}
+		if location == retentionDestination {
+			metadata.DestinationPath = buildDestPathForDB(config, filePath)
+		}
+		if err := te.db.CreateFileMetadata(metadata); err != nil { // Calls interface method
+			te.logger.LogError("Error creating file metadata for %s: %v", filePath, err)
+		}
+	}
+
+	if len(deleted) == 0 {
+		return errors
+	}
+
+	action := "retention_delete"
+	if preview {
+		action = "retention_preview"
+	}
+	auditLog := &db.AuditLog{
+		Action:     action,
+		EntityType: "config",
+		EntityID:   config.ID,
+		UserID:     job.CreatedBy,
+		Details: db.AuditLogDetails{
+			"job_id":   job.ID,
+			"location": location,
+			"root":     root,
+			"files":    deleted,
+			"count":    len(deleted),
+			"bytes":    deletedSize,
+		},
+		Timestamp: time.Now(),
+	}
+	if err := te.db.CreateAuditLog(auditLog); err != nil { // Calls interface method
+		te.logger.LogError("Error creating audit log for retention of job %d, config %d: %v", job.ID, config.ID, err)
+	}
+	return errors
+}
+
+// withoutArtifacts removes the files GoMFT writes next to the delivered files from a
+// destination listing: replaced versions, manifests with their signatures and completion
+// markers. Retention only applies to the delivered files.
+func withoutArtifacts(config *db.TransferConfig, files []map[string]interface{}) []map[string]interface{} {
+	listed := make(map[string]bool)
+	for _, entry := range files {
+		if filePath, ok := entry["Path"].(string); ok {
+			listed[filePath] = true
+		}
+	}
+	artifacts := make(map[string]bool)
+	if usesMarkers(config) {
+		artifacts = companionFiles(config.MarkerMode, config.MarkerName, listed)
+	}
+	manifest := manifestNameRegexp(config)
+
+	var delivered []map[string]interface{}
+	for _, entry := range files {
+		filePath, _ := entry["Path"].(string)
+		if artifacts[filePath] || strings.HasPrefix(filePath, versionsDir+"/") || (manifest != nil && manifest.MatchString(filePath)) {
+			continue
+		}
+		delivered = append(delivered, entry)
+	}
+	return delivered
+}
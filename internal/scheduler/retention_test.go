@@ -0,0 +1,209 @@
+package scheduler
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/starfleetcptn/gomft/internal/db"
+)
+
+func retentionEntry(path string, size int64, modTime time.Time) map[string]interface{} {
+	return map[string]interface{}{
+		"Path":    path,
+		"Size":    float64(size),
+		"ModTime": modTime.Format(time.RFC3339Nano),
+	}
+}
+
+func TestRetentionRuleExpired(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	day := 24 * time.Hour
+	files := []map[string]interface{}{
+		retentionEntry("c.csv", 300, now.Add(-3*day)),
+		retentionEntry("a.csv", 100, now.Add(-1*day)),
+		retentionEntry("d.csv", 400, now.Add(-10*day)),
+		retentionEntry("b.csv", 200, now.Add(-2*day)),
+	}
+
+	tests := []struct {
+		name string
+		rule retentionRule
+		want []string
+	}{
+		{"Empty rule", retentionRule{}, nil},
+		{"Keep last", retentionRule{keepLast: 2}, []string{"c.csv", "d.csv"}},
+		{"Keep more than present", retentionRule{keepLast: 10}, nil},
+		{"Max age", retentionRule{maxAge: 5 * day}, []string{"d.csv"}},
+		{"Max size", retentionRule{maxSize: 350}, []string{"c.csv", "d.csv"}},
+		{"Combined", retentionRule{keepLast: 3, maxAge: 2*day + time.Hour}, []string{"c.csv", "d.csv"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, entry := range tt.rule.expired(files, now) {
+				got = append(got, entry["Path"].(string))
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("expired() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateRetention(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  db.TransferConfig
+		command string
+		wantErr bool
+	}{
+		{"No rules", db.TransferConfig{}, "copy", false},
+		{"Valid rules", db.TransferConfig{ArchiveRetentionKeepLast: 10, DestRetentionMaxAgeDays: 30, DestRetentionMaxSize: "10G"}, "copy", false},
+		{"Negative keep last", db.TransferConfig{ArchiveRetentionKeepLast: -1}, "copy", true},
+		{"Negative age", db.TransferConfig{DestRetentionMaxAgeDays: -5}, "copy", true},
+		{"Invalid size", db.TransferConfig{ArchiveRetentionMaxSize: "lots"}, "copy", true},
+		{"Destination retention with bisync", db.TransferConfig{DestRetentionKeepLast: 10}, "bisync", true},
+		{"Archive retention with bisync", db.TransferConfig{ArchiveRetentionKeepLast: 10}, "bisync", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := ValidateRetention(&tt.config, tt.command); (err != nil) != tt.wantErr {
+				t.Errorf("ValidateRetention() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestApplyRetention(t *testing.T) {
+	now := time.Now()
+	listing := fmt.Sprintf(`[{"Path":"new.csv","Size":10,"ModTime":%q},{"Path":"2024/old.csv","Size":20,"ModTime":%q}]`,
+		now.Add(-time.Hour).Format(time.RFC3339Nano), now.Add(-48*time.Hour).Format(time.RFC3339Nano))
+	enabled := true
+
+	t.Run("Deletes expired files", func(t *testing.T) {
+		comps := setupTestExecutor()
+		defer comps.logger.Close()
+		var calls [][]string
+		defer mockRcloneSequence([]string{listing, ""}, &calls)()
+
+		job := db.Job{ID: 1, CreatedBy: 7}
+		config := &db.TransferConfig{ID: 4, DestinationType: "sftp", DestinationPath: "/drop", DestRetentionKeepLast: 1}
+		if errs := comps.executor.applyRetention(job, config, "copyto", "/tmp/rclone.conf", "rclone"); len(errs) != 0 {
+			t.Fatalf("applyRetention() errors = %v", errs)
+		}
+		if len(calls) != 2 {
+			t.Fatalf("applyRetention() made %d rclone calls, want 2: %v", len(calls), calls)
+		}
+		if calls[0][2] != "lsjson" || calls[0][len(calls[0])-1] != "dest_4:/drop" {
+			t.Errorf("applyRetention() listing args = %v", calls[0])
+		}
+		if calls[1][2] != "deletefile" || calls[1][3] != "dest_4:/drop/2024/old.csv" {
+			t.Errorf("applyRetention() delete args = %v", calls[1])
+		}
+		if len(comps.db.createdMetadata) != 1 || comps.db.createdMetadata[0].Status != "retention_deleted" || comps.db.createdMetadata[0].FileName != "2024/old.csv" {
+			t.Errorf("applyRetention() metadata = %+v", comps.db.createdMetadata)
+		}
+		if len(comps.db.createdAuditLogs) != 1 {
+			t.Fatalf("applyRetention() created %d audit logs, want 1", len(comps.db.createdAuditLogs))
+		}
+		audit := comps.db.createdAuditLogs[0]
+		if audit.Action != "retention_delete" || audit.UserID != 7 || audit.EntityID != 4 || audit.Details["count"] != 1 {
+			t.Errorf("applyRetention() audit log = %+v", audit)
+		}
+	})
+
+	t.Run("Preview only reports", func(t *testing.T) {
+		comps := setupTestExecutor()
+		defer comps.logger.Close()
+		var calls [][]string
+		defer mockRcloneSequence([]string{listing}, &calls)()
+
+		config := &db.TransferConfig{ID: 4, DestinationType: "sftp", DestinationPath: "/drop", DestRetentionMaxAgeDays: 1, RetentionPreview: &enabled}
+		if errs := comps.executor.applyRetention(db.Job{ID: 1}, config, "copyto", "/tmp/rclone.conf", "rclone"); len(errs) != 0 {
+			t.Fatalf("applyRetention() errors = %v", errs)
+		}
+		if len(calls) != 1 {
+			t.Errorf("applyRetention() made %d rclone calls in preview, want 1: %v", len(calls), calls)
+		}
+		if len(comps.db.createdMetadata) != 0 {
+			t.Errorf("applyRetention() created metadata in preview: %+v", comps.db.createdMetadata)
+		}
+		if len(comps.db.createdAuditLogs) != 1 || comps.db.createdAuditLogs[0].Action != "retention_preview" {
+			t.Errorf("applyRetention() audit logs = %+v", comps.db.createdAuditLogs)
+		}
+	})
+
+	t.Run("Archive requires archiving", func(t *testing.T) {
+		comps := setupTestExecutor()
+		defer comps.logger.Close()
+		var calls [][]string
+		defer mockRcloneSequence(nil, &calls)()
+
+		config := &db.TransferConfig{ID: 4, SourceType: "local", ArchivePath: "/archive", ArchiveRetentionKeepLast: 1}
+		comps.executor.applyRetention(db.Job{ID: 1}, config, "copyto", "/tmp/rclone.conf", "rclone")
+		if len(calls) != 0 {
+			t.Errorf("applyRetention() listed the archive without archiving enabled: %v", calls)
+		}
+
+		config.ArchiveEnabled = &enabled
+		comps.executor.applyRetention(db.Job{ID: 1}, config, "copyto", "/tmp/rclone.conf", "rclone")
+		if len(calls) != 1 || calls[0][len(calls[0])-1] != "source_4:/archive" {
+			t.Errorf("applyRetention() archive listing = %v", calls)
+		}
+	})
+
+	t.Run("Skips GoMFT artifacts", func(t *testing.T) {
+		comps := setupTestExecutor()
+		defer comps.logger.Close()
+		var calls [][]string
+		old := now.Add(-48 * time.Hour).Format(time.RFC3339Nano)
+		artifacts := fmt.Sprintf(`[{"Path":"a.csv","Size":10,"ModTime":%[1]q},{"Path":"a.csv.done","Size":0,"ModTime":%[1]q},`+
+			`{"Path":".versions/a_20240101T000000.csv","Size":10,"ModTime":%[1]q},{"Path":"manifest_20240101_000000.json","Size":5,"ModTime":%[1]q},`+
+			`{"Path":"manifest_20240101_000000.json.hmac","Size":5,"ModTime":%[1]q}]`, old)
+		defer mockRcloneSequence([]string{artifacts, ""}, &calls)()
+
+		config := &db.TransferConfig{ID: 4, DestinationType: "sftp", DestinationPath: "/drop", DestRetentionMaxAgeDays: 1,
+			MarkerMode: companionPerFile, ManifestFormat: manifestJSON}
+		if errs := comps.executor.applyRetention(db.Job{ID: 1}, config, "copyto", "/tmp/rclone.conf", "rclone"); len(errs) != 0 {
+			t.Fatalf("applyRetention() errors = %v", errs)
+		}
+		if len(calls) != 2 || calls[1][3] != "dest_4:/drop/a.csv" {
+			t.Errorf("applyRetention() calls = %v, want only a.csv deleted", calls)
+		}
+	})
+
+	t.Run("Bisync keeps the destination", func(t *testing.T) {
+		comps := setupTestExecutor()
+		defer comps.logger.Close()
+		var calls [][]string
+		defer mockRcloneSequence([]string{listing, ""}, &calls)()
+
+		config := &db.TransferConfig{ID: 4, DestinationType: "sftp", DestinationPath: "/drop", DestRetentionKeepLast: 1}
+		if errs := comps.executor.applyRetention(db.Job{ID: 1}, config, "bisync", "/tmp/rclone.conf", "rclone"); len(errs) != 1 {
+			t.Errorf("applyRetention() errors = %v, want 1", errs)
+		}
+		if len(calls) != 0 {
+			t.Errorf("applyRetention() calls with bisync = %v, want none", calls)
+		}
+	})
+
+	t.Run("Failed delete is reported", func(t *testing.T) {
+		comps := setupTestExecutor()
+		defer comps.logger.Close()
+		var calls [][]string
+		defer mockRcloneSequence([]string{listing, "error:permission denied"}, &calls)()
+
+		config := &db.TransferConfig{ID: 4, DestinationType: "sftp", DestinationPath: "/drop", DestRetentionKeepLast: 1}
+		errs := comps.executor.applyRetention(db.Job{ID: 1}, config, "copyto", "/tmp/rclone.conf", "rclone")
+		if len(errs) != 1 {
+			t.Errorf("applyRetention() errors = %v, want 1", errs)
+		}
+		if len(comps.db.createdAuditLogs) != 0 {
+			t.Errorf("applyRetention() audit logs = %+v, want none", comps.db.createdAuditLogs)
+		}
+	})
+}
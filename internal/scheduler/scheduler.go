@@ -39,6 +39,9 @@ type SchedulerLogger interface {
 // SchedulerJobExecutor defines the job executor methods needed directly by Scheduler.
 type SchedulerJobExecutor interface {
 	executeJob(jobID uint)
+	resumeRun(historyID uint) error
+	releaseFile(metadataID uint) error
+	deliverAS2Payload(configID uint, fileName, localPath string) (string, error)
 }
 
 // --- Scheduler Implementation ---
@@ -224,3 +227,22 @@ func (s *Scheduler) RunJobNow(jobID uint) error {
 	go s.executor.executeJob(jobID) // Calls interface method
 	return nil
 }
+
+// ResumeRun resumes an interrupted or failed run from its checkpoint
+func (s *Scheduler) ResumeRun(historyID uint) error {
+	s.logger.LogInfo("Resuming run %d", historyID)
+	return s.executor.resumeRun(historyID) // Calls interface method
+}
+
+// ReleaseQuarantinedFile moves a quarantined file back to its source
+func (s *Scheduler) ReleaseQuarantinedFile(metadataID uint) error {
+	s.logger.LogInfo("Releasing quarantined file %d", metadataID)
+	return s.executor.releaseFile(metadataID) // Calls interface method
+}
+
+// DeliverAS2Payload delivers a payload received over AS2 to the destination of a
+// configuration and returns the path it was delivered to
+func (s *Scheduler) DeliverAS2Payload(configID uint, fileName, localPath string) (string, error) {
+	s.logger.LogInfo("Delivering AS2 payload %s to configuration %d", fileName, configID)
+	return s.executor.deliverAS2Payload(configID, fileName, localPath) // Calls interface method
+}
@@ -12,6 +12,15 @@ type SchedulerInterface interface {
 	// RunJobNow runs a job immediately
 	RunJobNow(jobID uint) error
 
+	// ResumeRun resumes an interrupted or failed run from its checkpoint
+	ResumeRun(historyID uint) error
+
+	// ReleaseQuarantinedFile moves a quarantined file back to its source
+	ReleaseQuarantinedFile(metadataID uint) error
+
+	// DeliverAS2Payload delivers a payload received over AS2 to the destination of a configuration
+	DeliverAS2Payload(configID uint, fileName, localPath string) (string, error)
+
 	// UnscheduleJob removes a job from the scheduler
 	UnscheduleJob(jobID uint)
 
@@ -203,9 +203,13 @@ var _ SchedulerJobExecutor = (*mockSchedulerJobExecutor)(nil)
 type mockSchedulerJobExecutor struct {
 	mu             sync.Mutex
 	ExecuteJobFunc func(jobID uint)
+	ResumeRunFunc  func(historyID uint) error
 
 	// Store calls
-	executeJobCalls []uint
+	executeJobCalls  []uint
+	resumeRunCalls   []uint
+	releaseFileCalls []uint
+	as2PayloadCalls  []uint
 }
 
 func (m *mockSchedulerJobExecutor) executeJob(jobID uint) {
@@ -216,10 +220,32 @@ func (m *mockSchedulerJobExecutor) executeJob(jobID uint) {
 		m.ExecuteJobFunc(jobID)
 	}
 }
+func (m *mockSchedulerJobExecutor) resumeRun(historyID uint) error {
+	m.mu.Lock()
+	m.resumeRunCalls = append(m.resumeRunCalls, historyID)
+	m.mu.Unlock()
+	if m.ResumeRunFunc != nil {
+		return m.ResumeRunFunc(historyID)
+	}
+	return nil
+}
+func (m *mockSchedulerJobExecutor) releaseFile(metadataID uint) error {
+	m.mu.Lock()
+	m.releaseFileCalls = append(m.releaseFileCalls, metadataID)
+	m.mu.Unlock()
+	return nil
+}
+func (m *mockSchedulerJobExecutor) deliverAS2Payload(configID uint, fileName, localPath string) (string, error) {
+	m.mu.Lock()
+	m.as2PayloadCalls = append(m.as2PayloadCalls, configID)
+	m.mu.Unlock()
+	return fileName, nil
+}
 func (m *mockSchedulerJobExecutor) Reset() {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 	m.executeJobCalls = nil
+	m.resumeRunCalls = nil
 }
 
 // --- Test Setup ---
@@ -0,0 +1,113 @@
+package scheduler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/starfleetcptn/gomft/internal/db"
+)
+
+// defaultStabilityDelay is used when the stability check is enabled without a delay
+const defaultStabilityDelay = 30 * time.Second
+
+// stabilitySleep allows skipping the stability delay during tests.
+var stabilitySleep = time.Sleep
+
+// fileModTime returns the modification time of an lsjson entry
+func fileModTime(entry map[string]interface{}) (time.Time, bool) {
+	modTime, ok := entry["ModTime"].(string)
+	if !ok || modTime == "" {
+		return time.Time{}, false
+	}
+	parsed, err := time.Parse(time.RFC3339Nano, modTime)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return parsed, true
+}
+
+// filterByMinAge splits lsjson entries into files that are older than minAge and files
+// that were modified too recently. Entries without a modification time are treated as old enough.
+func filterByMinAge(files []map[string]interface{}, minAge time.Duration, now time.Time) (old []map[string]interface{}, recent []string) {
+	if minAge <= 0 {
+		return files, nil
+	}
+	for _, entry := range files {
+		if modTime, ok := fileModTime(entry); ok && now.Sub(modTime) < minAge {
+			path, _ := entry["Path"].(string)
+			recent = append(recent, path)
+			continue
+		}
+		old = append(old, entry)
+	}
+	return old, recent
+}
+
+// filterUnchanged keeps the entries of the first listing whose size and modification time
+// are identical in the second listing. Changed or removed files are returned by path.
+func filterUnchanged(first, second []map[string]interface{}) (stable []map[string]interface{}, changed []string) {
+	secondByPath := make(map[string]map[string]interface{}, len(second))
+	for _, entry := range second {
+		if path, ok := entry["Path"].(string); ok {
+			secondByPath[path] = entry
+		}
+	}
+
+	for _, entry := range first {
+		path, _ := entry["Path"].(string)
+		later, ok := secondByPath[path]
+		if !ok || entry["Size"] != later["Size"] || entry["ModTime"] != later["ModTime"] {
+			changed = append(changed, path)
+			continue
+		}
+		stable = append(stable, entry)
+	}
+	return stable, changed
+}
+
+// stableFiles removes files that are still being written from a source listing.
+// Deferred files are not transferred or recorded, so they are picked up by a later run.
+func (te *TransferExecutor) stableFiles(job db.Job, config *db.TransferConfig, rclonePath string, listArgs []string, files []map[string]interface{}) ([]map[string]interface{}, error) {
+	files, recent := filterByMinAge(files, time.Duration(config.MinFileAge)*time.Second, time.Now())
+	if len(recent) > 0 {
+		te.logger.LogInfo("Deferring %d files younger than %d seconds for job %d, config %d: %v",
+			len(recent), config.MinFileAge, job.ID, config.ID, recent)
+	}
+
+	if !config.GetStabilityCheck() || len(files) == 0 {
+		return files, nil
+	}
+
+	delay := time.Duration(config.StabilityDelay) * time.Second
+	if delay <= 0 {
+		delay = defaultStabilityDelay
+	}
+	te.logger.LogInfo("Waiting %v to check that %d files are stable for job %d, config %d", delay, len(files), job.ID, config.ID)
+	stabilitySleep(delay)
+
+	// The second listing only needs sizes and modification times
+	var secondArgs []string
+	for _, arg := range listArgs {
+		if arg != "--hash" {
+			secondArgs = append(secondArgs, arg)
+		}
+	}
+	listCmd := execCommandContext(context.Background(), rclonePath, secondArgs...)
+	listOutput, err := listCmd.CombinedOutput()
+	if err != nil {
+		return nil, fmt.Errorf("%v\nOutput: %s", err, string(listOutput))
+	}
+	var secondEntries []map[string]interface{}
+	if err := json.Unmarshal(listOutput, &secondEntries); err != nil {
+		return nil, fmt.Errorf("failed to parse second listing: %v", err)
+	}
+
+	stable, changed := filterUnchanged(files, secondEntries)
+	if len(changed) > 0 {
+		te.logger.LogInfo("Deferring %d files that are still changing for job %d, config %d: %v",
+			len(changed), job.ID, config.ID, changed)
+	}
+	return stable, nil
+}
@@ -0,0 +1,102 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/starfleetcptn/gomft/internal/db"
+)
+
+func TestFilterByMinAge(t *testing.T) {
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	files := []map[string]interface{}{
+		{"Path": "old.csv", "ModTime": now.Add(-10 * time.Minute).Format(time.RFC3339Nano)},
+		{"Path": "new.csv", "ModTime": now.Add(-30 * time.Second).Format(time.RFC3339Nano)},
+		{"Path": "unknown.csv"},
+	}
+
+	old, recent := filterByMinAge(files, 5*time.Minute, now)
+	if len(old) != 2 || old[0]["Path"] != "old.csv" || old[1]["Path"] != "unknown.csv" {
+		t.Errorf("filterByMinAge() old = %v", old)
+	}
+	if !reflect.DeepEqual(recent, []string{"new.csv"}) {
+		t.Errorf("filterByMinAge() recent = %v, want [new.csv]", recent)
+	}
+
+	if old, recent := filterByMinAge(files, 0, now); len(old) != 3 || recent != nil {
+		t.Errorf("filterByMinAge() without minimum age = %v, %v", old, recent)
+	}
+}
+
+func TestFilterUnchanged(t *testing.T) {
+	first := []map[string]interface{}{
+		{"Path": "done.csv", "Size": float64(100), "ModTime": "2025-03-01T12:00:00Z"},
+		{"Path": "growing.csv", "Size": float64(100), "ModTime": "2025-03-01T12:00:00Z"},
+		{"Path": "touched.csv", "Size": float64(100), "ModTime": "2025-03-01T12:00:00Z"},
+		{"Path": "removed.csv", "Size": float64(100), "ModTime": "2025-03-01T12:00:00Z"},
+	}
+	second := []map[string]interface{}{
+		{"Path": "done.csv", "Size": float64(100), "ModTime": "2025-03-01T12:00:00Z"},
+		{"Path": "growing.csv", "Size": float64(200), "ModTime": "2025-03-01T12:00:00Z"},
+		{"Path": "touched.csv", "Size": float64(100), "ModTime": "2025-03-01T12:00:30Z"},
+	}
+
+	stable, changed := filterUnchanged(first, second)
+	if len(stable) != 1 || stable[0]["Path"] != "done.csv" {
+		t.Errorf("filterUnchanged() stable = %v", stable)
+	}
+	if !reflect.DeepEqual(changed, []string{"growing.csv", "touched.csv", "removed.csv"}) {
+		t.Errorf("filterUnchanged() changed = %v", changed)
+	}
+}
+
+func TestStableFiles_SecondListing(t *testing.T) {
+	comps := setupTestExecutor()
+	defer comps.logger.Close()
+
+	var slept time.Duration
+	originalSleep := stabilitySleep
+	stabilitySleep = func(d time.Duration) { slept = d }
+	defer func() { stabilitySleep = originalSleep }()
+
+	secondListing := `[{"Path":"done.csv","Size":100,"ModTime":"2025-03-01T12:00:00Z"},{"Path":"growing.csv","Size":250,"ModTime":"2025-03-01T12:00:05Z"}]`
+	var listArgs []string
+	restoreExec := MockExecCommand(func(ctx context.Context, command string, args ...string) *exec.Cmd {
+		listArgs = args
+		cs := []string{"-test.run=TestHelperProcess", "--"}
+		cmd := exec.CommandContext(ctx, os.Args[0], cs...)
+		cmd.Env = []string{
+			"GO_TEST_HELPER_PROCESS=1",
+			fmt.Sprintf("GO_TEST_HELPER_PROCESS_OUTPUT=%s", secondListing),
+			"GO_TEST_HELPER_PROCESS_WANT_ERROR=0",
+		}
+		return cmd
+	})
+	defer restoreExec()
+
+	stabilityCheck := true
+	config := &db.TransferConfig{ID: 3, StabilityCheck: &stabilityCheck, StabilityDelay: 5}
+	files := []map[string]interface{}{
+		{"Path": "done.csv", "Size": float64(100), "ModTime": "2025-03-01T12:00:00Z"},
+		{"Path": "growing.csv", "Size": float64(100), "ModTime": "2025-03-01T12:00:00Z"},
+	}
+
+	stable, err := comps.executor.stableFiles(db.Job{ID: 1}, config, "rclone", []string{"lsjson", "--hash", "--recursive", "source_3:in"}, files)
+	if err != nil {
+		t.Fatalf("stableFiles() error = %v", err)
+	}
+	if len(stable) != 1 || stable[0]["Path"] != "done.csv" {
+		t.Errorf("stableFiles() = %v, want only done.csv", stable)
+	}
+	if slept != 5*time.Second {
+		t.Errorf("stableFiles() waited %v, want 5s", slept)
+	}
+	if !reflect.DeepEqual(listArgs, []string{"lsjson", "--recursive", "source_3:in"}) {
+		t.Errorf("stableFiles() second listing args = %v", listArgs)
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"os"
 	"os/exec" // Keep this for the variable type definition
+	"path"
 	"path/filepath"
 	"regexp"
 	"strconv"
@@ -15,6 +16,8 @@ import (
 	"time"
 
 	"github.com/starfleetcptn/gomft/internal/db"
+	"github.com/starfleetcptn/gomft/internal/encryption"
+	"github.com/starfleetcptn/gomft/internal/filters"
 )
 
 // --- Interfaces for Dependencies ---
@@ -22,10 +25,28 @@ import (
 // TransferDB defines the database methods needed by TransferExecutor.
 type TransferDB interface {
 	GetConfigRclonePath(config *db.TransferConfig) string
+	GetConfigBisyncDir(config *db.TransferConfig) string
+	GetConfigHTTPDir(config *db.TransferConfig) string
+	GetRunManifestDir(history *db.JobHistory) string
 	GetRcloneCommand(id uint) (*db.RcloneCommand, error)
 	UpdateJobHistory(history *db.JobHistory) error
 	CreateFileMetadata(metadata *db.FileMetadata) error
+	CreateAuditLog(log *db.AuditLog) error
 	GetRcloneCommandFlagsMap(commandID uint) (map[uint]db.RcloneCommandFlag, error)
+	GetEncryptionKey(id uint) (*db.EncryptionKey, error)
+	CreateRunFiles(files []db.RunFile) error
+	GetRunFiles(jobHistoryID uint) ([]db.RunFile, error)
+	UpdateRunFileStatus(id uint, status, errorMessage string) error
+	GetLatestFileMetadata(jobID, configID uint, fileName string) (*db.FileMetadata, error)
+	UpdateFileMetadata(metadata *db.FileMetadata) error
+	UpdateHighWaterMark(configID uint, mark time.Time) error
+	UpdateHTTPValidators(configID uint, validators string) error
+	RefreshOAuthTokens(config *db.TransferConfig) error
+	WriteSSHKeys(config *db.TransferConfig) (func(), error)
+	PinHostKeysOnFirstUse(config *db.TransferConfig) ([]*db.HostKey, error)
+	GetAS2PartnerByName(name string) (*db.AS2Partner, error)
+	CreateAS2Message(message *db.AS2Message) error
+	UpdateAS2Message(message *db.AS2Message) error
 }
 
 // TransferNotifier defines the notification methods needed by TransferExecutor.
@@ -38,6 +59,7 @@ type TransferNotifier interface {
 type TransferMetadataHandler interface {
 	hasFileBeenProcessed(jobID uint, fileHash string) (bool, *db.FileMetadata, error)
 	checkFileProcessingHistory(jobID uint, fileName string) (*db.FileMetadata, error)
+	findDuplicate(jobID uint, config *db.TransferConfig, fileHash string, fileSize int64) (*db.FileMetadata, error)
 }
 
 // --- Mockable exec Command ---
@@ -71,6 +93,19 @@ func NewTransferExecutor(
 	}
 }
 
+// failRun marks a run as failed with the given error, saves it and sends the notifications
+func (te *TransferExecutor) failRun(job db.Job, config *db.TransferConfig, history *db.JobHistory, message string) {
+	history.Status = "failed"
+	history.ErrorMessage = message
+	endTime := time.Now()
+	history.EndTime = &endTime
+	if err := te.db.UpdateJobHistory(history); err != nil { // Calls interface method
+		te.logger.LogError("Error updating job history for job %d, config %d: %v", job.ID, config.ID, err)
+	}
+	// Send notification for failure
+	te.notifier.SendNotifications(&job, history, config) // Calls interface method
+}
+
 // executeConfigTransfer performs the actual file transfer for a single configuration
 func (te *TransferExecutor) executeConfigTransfer(job db.Job, config db.TransferConfig, history *db.JobHistory) {
 	te.logger.LogDebug("Starting transfer for config %d with params: %+v", config.ID, config)
@@ -81,6 +116,27 @@ func (te *TransferExecutor) executeConfigTransfer(job db.Job, config db.Transfer
 	// Get rclone config path
 	configPath := te.db.GetConfigRclonePath(&config) // Calls interface method
 
+	// Refresh expired OAuth tokens, rclone may still be able to refresh them itself
+	if err := te.db.RefreshOAuthTokens(&config); err != nil { // Calls interface method
+		te.logger.LogError("Failed to refresh OAuth token for config %d: %v", config.ID, err)
+	}
+
+	// SFTP servers without a pinned host key are pinned to the key they present now
+	if err := te.pinHostKeysOnFirstUse(&config); err != nil {
+		te.logger.LogError("Error pinning host keys for job %d, config %d: %v", job.ID, config.ID, err)
+		te.failRun(job, &config, history, fmt.Sprintf("Host Key Error: %v", err))
+		return
+	}
+
+	// Managed SSH keys are only written to disk for the duration of the run
+	releaseSSHKeys, err := te.db.WriteSSHKeys(&config) // Calls interface method
+	if err != nil {
+		te.logger.LogError("Error writing SSH keys for job %d, config %d: %v", job.ID, config.ID, err)
+		te.failRun(job, &config, history, fmt.Sprintf("SSH Key Error: %v", err))
+		return
+	}
+	defer releaseSSHKeys()
+
 	// Get the command to use for the transfer
 	var rcloneCommand string = "copyto" // Default command
 	if config.CommandID > 0 {
@@ -98,6 +154,22 @@ func (te *TransferExecutor) executeConfigTransfer(job db.Job, config db.Transfer
 	commandType := determineCommandType(rcloneCommand) // Package-level call
 	te.logger.LogDebug("Command %s is of type: %s", rcloneCommand, commandType)
 
+	// Options that act on individual files need copy and move to run file by file, other
+	// commands would silently ignore them, so the run fails instead
+	switch reasons := needsFileByFile(&config); {
+	case len(reasons) == 0:
+	case !transfersFileByFile(rcloneCommand):
+		te.logger.LogError("Command %s is not supported with the options of job %d, config %d", rcloneCommand, job.ID, config.ID)
+		te.failRun(job, &config, history, fmt.Sprintf("Command Error: %v", ValidateCommand(&config, rcloneCommand)))
+		return
+	case fileCommandFor(rcloneCommand) != rcloneCommand:
+		te.logger.LogInfo("Using %s instead of %s for job %d, config %d to %s",
+			fileCommandFor(rcloneCommand), rcloneCommand, job.ID, config.ID, strings.Join(reasons, ", "))
+		rcloneCommand = fileCommandFor(rcloneCommand)
+	}
+	processFiles := requiresLocalProcessing(&config)
+	fanOut := usesFanOut(&config)
+
 	// For non-file-by-file transfer commands, use the simple execution approach
 	if commandType != "transfer" || isDirectoryBasedTransfer(rcloneCommand) { // Package-level call
 		te.executeSimpleCommand(rcloneCommand, commandType, job, config, history, configPath)
@@ -105,115 +177,41 @@ func (te *TransferExecutor) executeConfigTransfer(job db.Job, config db.Transfer
 	}
 
 	// The rest of the function handles file-by-file transfer commands (copyto, moveto)
-	// Use lsjson to get file list and metadata in one operation instead of separate size and ls commands
-	listArgs := []string{
-		"--config", configPath,
-		"lsjson",
-		"--hash",
-		"--recursive",
-	}
-
-	// Add file pattern filter if specified
-	if config.FilePattern != "" && config.FilePattern != "*" {
-		// Create a temporary filter file for complex patterns
-		filterFile, err := createRcloneFilterFile(config.FilePattern) // Package-level call from utils.go
-		if err != nil {
-			te.logger.LogError("Error creating filter file for job %d, config %d: %v", job.ID, config.ID, err)
-			history.Status = "failed"
-			history.ErrorMessage = fmt.Sprintf("Filter Creation Error: %v", err)
-			endTime := time.Now()
-			history.EndTime = &endTime
-			if err := te.db.UpdateJobHistory(history); err != nil { // Calls interface method
-				te.logger.LogError("Error updating job history for job %d, config %d: %v", job.ID, config.ID, err)
-			}
-			// Send notification for failure
-			te.notifier.SendNotifications(&job, history, &config) // Calls interface method
-
-			return
-		}
-		defer os.Remove(filterFile)
-		listArgs = append(listArgs, "--filter-from", filterFile)
-	}
-
-	// Add source path with bucket for S3-compatible storage
-	var sourceListPath string
-	if config.SourceType == "s3" || config.SourceType == "minio" || config.SourceType == "b2" {
-		sourceListPath = fmt.Sprintf("source_%d:%s", config.ID, config.SourceBucket)
-		if config.SourcePath != "" && config.SourcePath != "/" {
-			sourceListPath = fmt.Sprintf("source_%d:%s/%s", config.ID, config.SourceBucket, config.SourcePath)
-		}
-	} else {
-		sourceListPath = fmt.Sprintf("source_%d:%s", config.ID, config.SourcePath)
-	}
-
-	listArgs = append(listArgs, sourceListPath)
-
-	// Execute lsjson command
-	te.logger.LogDebug("Full lsjson command: %s %v", os.Getenv("RCLONE_PATH"), listArgs)
 	rclonePath := os.Getenv("RCLONE_PATH")
 	if rclonePath == "" {
 		rclonePath = "rclone"
 	}
-	// Use the mockable execCommandContext
-	listCmd := execCommandContext(context.Background(), rclonePath, listArgs...)
-	listOutput, listErr := listCmd.CombinedOutput()
 
-	// Add debug logging of raw output
-	if listErr == nil {
-		te.logger.LogDebug("Raw lsjson output for job %d config %d:\n%s",
-			job.ID,
-			config.ID,
-			string(listOutput))
-	} else {
-		te.logger.LogDebug("Raw lsjson output (error case) for job %d config %d:\n%s",
-			job.ID,
-			config.ID,
-			string(listOutput))
+	// Downloaded files of an HTTP source are only kept for the duration of the run, in
+	// a directory of their own so overlapping runs of the config keep their files
+	if isHTTPSource(&config) {
+		config.SourceHTTPRunDir = fmt.Sprintf("run_%d", history.ID)
+		defer os.RemoveAll(te.httpDownloadDir(&config))
 	}
 
-	if listErr != nil {
-		te.logger.LogError("Error listing files for job %d, config %d: %v", job.ID, config.ID, listErr)
-		history.Status = "failed"
-		history.ErrorMessage = fmt.Sprintf("File Listing Error: %v\nOutput: %s", listErr, string(listOutput))
-		endTime := time.Now()
-		history.EndTime = &endTime
-		if err := te.db.UpdateJobHistory(history); err != nil { // Calls interface method
-			te.logger.LogError("Error updating job history for job %d, config %d: %v", job.ID, config.ID, err)
+	// A resumed run continues with the files its checkpoint has not completed instead
+	// of listing the source again. HTTP sources are downloaded again, the files the
+	// resumed run delivered are then skipped as processed files.
+	var files []map[string]interface{}
+	var triggers map[string][]string          // Files covered by each trigger file
+	var waitingFiles []map[string]interface{} // Held back until their trigger file arrives
+	if history.ResumedFromID != 0 && !isHTTPSource(&config) {
+		files, err = te.checkpointFiles(job, &config, history.ResumedFromID)
+		if err == nil && usesTriggers(&config) {
+			triggers = coveredFiles(&config, files)
 		}
-		// Send notification for failure
-		te.notifier.SendNotifications(&job, history, &config) // Calls interface method
-		return
+	} else {
+		files, triggers, waitingFiles, err = te.listTransferFiles(job, &config, configPath, rclonePath)
 	}
-
-	// Parse JSON output to get file information
-	var fileEntries []map[string]interface{}
-	if err := json.Unmarshal(listOutput, &fileEntries); err != nil {
-		te.logger.LogError("Error parsing file list JSON for job %d, config %d: %v", job.ID, config.ID, err)
-		history.Status = "failed"
-		history.ErrorMessage = fmt.Sprintf("JSON Parsing Error: %v", err)
-		endTime := time.Now()
-		history.EndTime = &endTime
-		if err := te.db.UpdateJobHistory(history); err != nil { // Calls interface method
-			te.logger.LogError("Error updating job history for job %d, config %d: %v", job.ID, config.ID, err)
-		}
-		// Send notification for failure
-		te.notifier.SendNotifications(&job, history, &config) // Calls interface method
+	if err != nil {
+		te.logger.LogError("Error preparing files for job %d, config %d: %v", job.ID, config.ID, err)
+		te.failRun(job, &config, history, err.Error())
 		return
 	}
 
-	// Calculate total size and filter out directories
-	var files []map[string]interface{}
+	// Calculate total size
 	var totalSize int64
-	for _, entry := range fileEntries {
-		// Process directories
-		if isDir, ok := entry["IsDir"].(bool); ok && isDir {
-			continue
-		}
-
-		// Add to files list
-		files = append(files, entry)
-
-		// Add to total size
+	for _, entry := range files {
 		if size, ok := entry["Size"].(float64); ok {
 			totalSize += int64(size)
 		}
@@ -228,6 +226,11 @@ func (te *TransferExecutor) executeConfigTransfer(job db.Job, config db.Transfer
 		te.logger.LogInfo("No files to transfer for job %d, config %d", job.ID, config.ID)
 		history.Status = "completed"
 		history.ErrorMessage = ""
+		// Retention still applies when nothing new was transferred
+		if retentionErrors := te.applyRetention(job, &config, rcloneCommand, configPath, rclonePath); len(retentionErrors) > 0 {
+			history.Status = "completed_with_errors"
+			history.ErrorMessage = strings.Join(retentionErrors, "\n")
+		}
 		history.FilesTransferred = 0
 		endTime := time.Now()
 		history.EndTime = &endTime
@@ -239,8 +242,17 @@ func (te *TransferExecutor) executeConfigTransfer(job db.Job, config db.Transfer
 		return
 	}
 
+	// Persist the planned files so the run can be resumed if it is interrupted or fails
+	checkpoint := te.saveCheckpoint(job, &config, history, files)
+
 	var transferErrors []string
 	filesTransferred := 0
+	var deliveredFiles []*db.FileMetadata  // Listed in the manifest of the run
+	var unchangedFiles []string            // Skipped as already delivered by an earlier run
+	var heldBackFiles []string             // Failed or skipped, held back from the high-water mark
+	duplicateFiles := 0                    // Content already delivered within the dedupe scope
+	var destNames deliveredNames           // Names delivered to each destination, for completion markers
+	runContents := make(map[string]string) // First file of the run with each content, to find duplicates within the run
 
 	// Use mutex for thread-safe access to shared variables
 	var mutex sync.Mutex
@@ -257,6 +269,52 @@ func (te *TransferExecutor) executeConfigTransfer(job db.Job, config db.Transfer
 	}
 
 	te.logger.LogInfo("Using %d concurrent transfers for job %d, config %d", maxConcurrent, job.ID, config.ID)
+	if config.GetSkipProcessedFiles() {
+		te.logger.LogInfo("Using %s change detection for job %d, config %d", changeDetectionName(&config), job.ID, config.ID)
+	}
+
+	// Load the encryption keys and create a staging directory when files have to be
+	// processed locally or delivered to several destinations
+	var stagingDir string
+	var keys *encryptionKeys
+	if processFiles || fanOut {
+		var err error
+		keys, err = te.loadEncryptionKeys(&config)
+		if err != nil {
+			te.logger.LogError("Error loading encryption keys for job %d, config %d: %v", job.ID, config.ID, err)
+			te.failRun(job, &config, history, fmt.Sprintf("Encryption Key Error: %v", err))
+			return
+		}
+
+		stagingDir, err = os.MkdirTemp("", fmt.Sprintf("gomft-staging-%d-*", config.ID))
+		if err != nil {
+			te.logger.LogError("Error creating staging directory for job %d, config %d: %v", job.ID, config.ID, err)
+			te.failRun(job, &config, history, fmt.Sprintf("Staging Directory Error: %v", err))
+			return
+		}
+		defer os.RemoveAll(stagingDir)
+	}
+
+	// The output pattern is expanded per file, with the sequence numbering files in listing order
+	pattern, err := newOutputPattern(&config, job.Name, history.ID, time.Now())
+	if err != nil {
+		te.logger.LogError("Error preparing output pattern for job %d, config %d: %v", job.ID, config.ID, err)
+		te.failRun(job, &config, history, fmt.Sprintf("Output Pattern Error: %v", err))
+		return
+	}
+	var seq int
+
+	// Every destination is given each file in turn, starting with the main destination
+	targets, err := deliveryTargets(&config, pattern)
+	if err != nil {
+		te.logger.LogError("Error preparing destinations for job %d, config %d: %v", job.ID, config.ID, err)
+		te.failRun(job, &config, history, fmt.Sprintf("Destination Error: %v", err))
+		return
+	}
+
+	// Zip compression bundles all files of the run into a single archive
+	bundleFiles := config.CompressionType == compressionZip
+	var bundleEntries []*bundleEntry
 
 	// Create wait group for concurrent processing
 	var wg sync.WaitGroup
@@ -278,19 +336,7 @@ func (te *TransferExecutor) executeConfigTransfer(job db.Job, config db.Transfer
 		}
 
 		// Extract hash from the file entry
-		fileHash := ""
-		if hashes, ok := fileEntry["Hashes"].(map[string]interface{}); ok {
-			// Try several hash algorithms in order of preference
-			for _, hashType := range []string{"SHA-1", "sha1", "MD5", "md5", "sha256", "crc32"} {
-				if hashValue, found := hashes[hashType]; found {
-					if hashStr, ok := hashValue.(string); ok && hashStr != "" {
-						te.logger.LogDebug("Found hash %s: %s for file %s", hashType, hashStr, fileName)
-						fileHash = hashStr
-						break
-					}
-				}
-			}
-		}
+		fileHash := entryHash(fileEntry)
 
 		// Log if no hash was found
 		if fileHash == "" {
@@ -324,6 +370,8 @@ func (te *TransferExecutor) executeConfigTransfer(job db.Job, config db.Transfer
 
 				if shouldSkip {
 					te.logger.LogInfo("Skipping unchanged file %s (hash matches previous processing)", fileName)
+					te.updateCheckpoint(checkpoint[fileName], "skipped", "")
+					unchangedFiles = append(unchangedFiles, fileName)
 					continue
 				} else {
 					te.logger.LogInfo("Re-processing file %s despite previous processing (skipProcessedFiles=%v)", fileName, skipFiles)
@@ -351,6 +399,8 @@ func (te *TransferExecutor) executeConfigTransfer(job db.Job, config db.Transfer
 
 			if shouldSkip {
 				te.logger.LogInfo("Skipping unchanged file %s (hash matches previous processing)", fileName)
+				te.updateCheckpoint(checkpoint[fileName], "skipped", "")
+				unchangedFiles = append(unchangedFiles, fileName)
 				// Skip this file and continue to the next one
 				continue
 			} else if fileHash != "" && fileHash == prevMetadata.FileHash {
@@ -358,6 +408,37 @@ func (te *TransferExecutor) executeConfigTransfer(job db.Job, config db.Transfer
 			}
 		}
 
+		// Without hashes, unchanged files are recognised by size and modification time or
+		// by the high-water mark, depending on the change detection mode of the config
+		if skipFiles {
+			if reason := te.unchangedReason(job, &config, fileName, fileSize, fileEntry); reason != "" {
+				te.logger.LogInfo("Skipping unchanged file %s (%s)", fileName, reason)
+				te.updateCheckpoint(checkpoint[fileName], "skipped", "")
+				unchangedFiles = append(unchangedFiles, fileName)
+				continue
+			}
+		}
+
+		// Files whose content was already delivered within the dedupe scope are suppressed,
+		// or delivered and reported when the config alerts on duplicates
+		if usesDedupe(&config) {
+			if original := te.duplicateOf(job, &config, fileName, fileHash, fileSize, runContents); original != "" {
+				duplicateFiles++
+				if config.DedupeAction == dedupeActionAlert {
+					te.logger.LogInfo("Delivering duplicate file %s for job %d, config %d: same content as %s", fileName, job.ID, config.ID, original)
+					mutex.Lock()
+					transferErrors = append(transferErrors, fmt.Sprintf("Duplicate file %s: same content as %s", fileName, original))
+					mutex.Unlock()
+				} else {
+					te.logger.LogInfo("Skipping duplicate file %s for job %d, config %d: same content as %s", fileName, job.ID, config.ID, original)
+					te.recordDuplicate(job, &config, fileName, fileHash, fileSize, original)
+					te.updateCheckpoint(checkpoint[fileName], "skipped", "")
+					unchangedFiles = append(unchangedFiles, fileName)
+					continue
+				}
+			}
+		}
+
 		// Mark this file as processed for this execution before launching goroutine
 		// to prevent duplicate processing
 		processedFiles[fileName] = true
@@ -381,6 +462,10 @@ func (te *TransferExecutor) executeConfigTransfer(job db.Job, config db.Transfer
 		currentFileSize := fileSize
 		currentCreateTime := createTime
 		currentModTime := modTime
+		currentRunFileID := checkpoint[fileName]
+		currentDownloadError, _ := fileEntry["DownloadError"].(string)
+		seq++
+		currentPatternFile := outputPatternFile{name: fileName, size: fileSize, hash: fileHash, modTime: modTime, seq: seq}
 
 		// Log the file information that will be processed
 		te.logger.LogDebug("Processing file %d/%d: %s (Size: %d, Hash: %s)",
@@ -396,69 +481,121 @@ func (te *TransferExecutor) executeConfigTransfer(job db.Job, config db.Transfer
 				wg.Done()
 			}()
 
-			// Prepare rclone command
-			transferArgs := te.prepareBaseArguments(rcloneCommand, &config, nil) // Use method call
-
-			// Source and destination paths
-			var sourcePath, destPath string
-
-			// For S3, MinIO, and B2, include the bucket in the path
-			if config.SourceType == "s3" || config.SourceType == "minio" || config.SourceType == "b2" {
-				sourcePath = fmt.Sprintf("source_%d:%s/%s", config.ID, config.SourceBucket, currentFileName)
-				if config.SourcePath != "" && config.SourcePath != "/" {
-					sourcePath = fmt.Sprintf("source_%d:%s/%s/%s", config.ID, config.SourceBucket, config.SourcePath, currentFileName)
-				}
-			} else {
-				sourcePath = fmt.Sprintf("source_%d:%s/%s", config.ID, config.SourcePath, currentFileName)
+			// Source and destination paths (bucket or container is included for S3, MinIO, B2, Azure Blob Storage and GCS)
+			sourcePath := buildSourceRemotePath(&config, currentFileName)
+			destFile, patternErr := pattern.fileName(currentPatternFile, "")
+			destPath := buildDestRemotePath(&config, destFile)
+			if config.OutputPattern != "" && patternErr == nil {
+				te.logger.LogDebug("Renaming file from %s to %s for job %d, config %d", currentFileName, destFile, job.ID, config.ID)
 			}
 
-			var destFile string = currentFileName
-
-			if config.DestinationType == "s3" || config.DestinationType == "minio" || config.DestinationType == "b2" {
-				destPath = fmt.Sprintf("dest_%d:%s/%s", config.ID, config.DestBucket, currentFileName)
-				if config.DestinationPath != "" && config.DestinationPath != "/" {
-					destPath = fmt.Sprintf("dest_%d:%s/%s/%s", config.ID, config.DestBucket, config.DestinationPath, currentFileName)
+			// Create file metadata record
+			fileStatus := "processed"
+			var fileErrorMsg string
+			var destPathForDB string
+			var fileErr error
+			var originalSize, compressedSize int64
+			var encryptionResult *encryption.Result
+			var conflictAction string
+			var destinationResults []db.DestinationResult
+			var names map[string][]string // Names the file was delivered under, by destination
+			var failureCount int
+
+			if currentDownloadError != "" {
+				// The URL of an HTTP source could not be downloaded
+				fileErr = fmt.Errorf("download failed: %s", currentDownloadError)
+			} else if patternErr != nil {
+				// The file is not transferred when its destination name cannot be built
+				fileErr = patternErr
+			} else if processFiles || fanOut {
+				// Download the file to the staging area once and process it before delivery
+				var staged []stagedFile
+				var cleanupStaged func()
+				staged, cleanupStaged, fileErr = te.stageFile(&config, keys, rclonePath, configPath, stagingDir, sourcePath, currentFileName, currentFileSize)
+				if fileErr == nil && bundleFiles {
+					// Files are delivered together once every file of the run has been staged
+					mutex.Lock()
+					for i := range staged {
+						bundleEntries = append(bundleEntries, &bundleEntry{
+							file:       staged[i],
+							sourcePath: sourcePath,
+							runFileID:  currentRunFileID,
+							metadata: &db.FileMetadata{
+								JobID:        job.ID,
+								ConfigID:     config.ID,
+								FileName:     currentFileName,
+								OriginalPath: config.SourcePath,
+								FileSize:     currentFileSize,
+								FileHash:     currentFileHash,
+								CreationTime: currentCreateTime,
+								ModTime:      currentModTime,
+							},
+							cleanup: cleanupStaged,
+						})
+					}
+					mutex.Unlock()
+					return
 				}
-			} else {
-				destPath = fmt.Sprintf("dest_%d:%s/%s", config.ID, config.DestinationPath, currentFileName)
-			}
-
-			// Add output filename pattern if specified
-			if config.OutputPattern != "" {
-				// Process the output pattern for this specific file
-				destFile = ProcessOutputPattern(config.OutputPattern, currentFileName) // Package-level call from utils.go
-
-				if config.DestinationType == "s3" || config.DestinationType == "minio" || config.DestinationType == "b2" {
-					destPath = fmt.Sprintf("dest_%d:%s/%s", config.ID, config.DestBucket, destFile)
-					if config.DestinationPath != "" && config.DestinationPath != "/" {
-						destPath = fmt.Sprintf("dest_%d:%s/%s/%s", config.ID, config.DestBucket, config.DestinationPath, destFile)
+				if fileErr == nil {
+					// Each destination receives the staged files in turn
+					var outcome deliveryOutcome
+					for _, target := range targets {
+						names, action, deliverErr := te.deliverStagedFiles(target, configPath, rclonePath, staged, currentPatternFile)
+						outcome.add(target, names, action, deliverErr)
 					}
-				} else {
-					destPath = fmt.Sprintf("dest_%d:%s/%s", config.ID, config.DestinationPath, destFile)
+					fileErr = outcome.err()
+					conflictAction = outcome.conflictAction
+					destinationResults = outcome.destinationResults()
+					destPathForDB = strings.Join(outcome.paths, ", ")
+					names = outcome.names
+					for _, file := range staged {
+						originalSize += file.originalSize
+						compressedSize += file.compressedSize
+						if file.encryption != nil {
+							encryptionResult = file.encryption
+						}
+					}
+					// Only the staged copies of the files being processed are kept on disk
+					cleanupStaged()
+				}
+				if fileErr == nil && rcloneCommand == "moveto" && conflictAction != conflictSkipped {
+					// The staged copy has been delivered, so the source can now be removed
+					fileErr = te.deleteSourceFile(configPath, rclonePath, sourcePath)
+				}
+			} else if destFile, conflictAction, fileErr = te.resolveConflict(&config, configPath, rclonePath, destFile); fileErr == nil && conflictAction != conflictSkipped {
+				// The conflict policy may have chosen another destination name, and with
+				// atomic delivery the file is written under a temporary name first
+				uploadFile := uploadName(&config, destFile)
+				destPath = buildDestRemotePath(&config, uploadFile)
+
+				// Prepare rclone command
+				transferArgs := te.prepareBaseArguments(rcloneCommand, &config, nil) // Use method call
+
+				// For file-by-file (copyto, moveto), we need source and dest here.
+				transferArgs = append(transferArgs, sourcePath, destPath)
+
+				// Execute transfer for this file
+				te.logger.LogDebug("Full transfer command: %s %v", rclonePath, transferArgs)
+				te.logger.LogDebug("Environment: RCLONE_PATH=%s", os.Getenv("RCLONE_PATH"))
+				// Use the mockable execCommandContext
+				cmd := execCommandContext(context.Background(), rclonePath, transferArgs...)
+				fileOutput, err := cmd.CombinedOutput()
+				fileErr = err
+				if fileErr != nil {
+					fileErr = withHostKeyFailure(fileErr, string(fileOutput))
 				}
 
-				te.logger.LogDebug("Renaming file from %s to %s for job %d, config %d", currentFileName, destFile, job.ID, config.ID)
-			}
-
-			// Add source and destination to the command (already added in prepareBaseArguments for some commands, check logic)
-			// This part needs careful review based on how prepareBaseArguments is structured
-			// For file-by-file (copyto, moveto), we need source and dest here.
-			transferArgs = append(transferArgs, sourcePath, destPath)
-
-			// Execute transfer for this file
-			te.logger.LogDebug("Full transfer command: %s %v", rclonePath, transferArgs)
-			te.logger.LogDebug("Environment: RCLONE_PATH=%s", os.Getenv("RCLONE_PATH"))
-			// Use the mockable execCommandContext
-			cmd := execCommandContext(context.Background(), rclonePath, transferArgs...)
-			fileOutput, fileErr := cmd.CombinedOutput()
+				// Print the output
+				te.logger.LogDebug("Output for file %s: %s", currentFileName, string(fileOutput))
 
-			// Print the output
-			te.logger.LogDebug("Output for file %s: %s", currentFileName, string(fileOutput))
+				if fileErr == nil {
+					fileErr = te.completeDelivery(&config, configPath, rclonePath, uploadFile, destFile, currentFileSize)
+				}
 
-			// Create file metadata record
-			fileStatus := "processed"
-			var fileErrorMsg string
-			var destPathForDB string
+				// Extract the actual destination path (without rclone remote prefix)
+				destPathForDB = buildDestPathForDB(&config, destFile)
+				names = map[string][]string{mainDestinationLabel: {destFile}}
+			}
 
 			// Check if file was successfully transferred
 			if fileErr != nil {
@@ -468,98 +605,42 @@ func (te *TransferExecutor) executeConfigTransfer(job db.Job, config db.Transfer
 				mutex.Unlock()
 				fileStatus = "error"
 				fileErrorMsg = fileErr.Error()
-			} else {
-				mutex.Lock()
-				filesTransferred++
-				mutex.Unlock()
-				te.logger.LogInfo("Successfully transferred file %s for job %d, config %d", currentFileName, job.ID, config.ID)
+				destPathForDB = ""
 
-				// Extract the actual destination path (without rclone remote prefix)
-				if config.DestinationType == "local" {
-					destPathForDB = filepath.Join(config.DestinationPath, destFile)
-				} else {
-					// For remote destinations, store the path format
-					if config.DestinationType == "s3" || config.DestinationType == "minio" || config.DestinationType == "b2" {
-						if config.DestinationPath != "" && config.DestinationPath != "/" {
-							destPathForDB = fmt.Sprintf("%s/%s/%s", config.DestBucket, config.DestinationPath, destFile)
+				// A file that keeps failing is moved aside instead of failing every run
+				if usesQuarantine(&config) {
+					failureCount = te.consecutiveFailures(job, &config, currentFileName)
+					if failureCount >= config.QuarantineAfter {
+						quarantineErr := te.quarantineFile(job, &config, configPath, rclonePath, sourcePath, currentFileName)
+						mutex.Lock()
+						if quarantineErr != nil {
+							te.logger.LogError("Error quarantining file %s for job %d, config %d: %v", currentFileName, job.ID, config.ID, quarantineErr)
+							transferErrors = append(transferErrors, fmt.Sprintf("Quarantine error for file %s: %v", currentFileName, quarantineErr))
 						} else {
-							destPathForDB = fmt.Sprintf("%s/%s", config.DestBucket, destFile)
+							fileStatus = fileStatusQuarantined
+							transferErrors = append(transferErrors, fmt.Sprintf("File %s: moved to quarantine after %d consecutive failures", currentFileName, failureCount))
 						}
-					} else {
-						destPathForDB = fmt.Sprintf("%s/%s", config.DestinationPath, destFile)
-					}
-				}
-
-				// If archiving is enabled and transfer was successful, move files to archive
-				if config.GetArchiveEnabled() && config.ArchivePath != "" {
-					te.logger.LogInfo("Archiving file %s for job %d, config %d", currentFileName, job.ID, config.ID)
-
-					// We don't need to move the file since we used moveto, but we can copy it to archive
-					archiveArgs := []string{
-						"--config", configPath,
-						"copyto",
-						sourcePath,
-					}
-
-					// Construct archive path with bucket if needed
-					var archiveDest string
-					if config.SourceType == "s3" || config.SourceType == "minio" || config.SourceType == "b2" {
-						archiveDest = fmt.Sprintf("source_%d:%s/%s/%s", config.ID, config.SourceBucket, config.ArchivePath, currentFileName)
-					} else {
-						archiveDest = fmt.Sprintf("source_%d:%s/%s", config.ID, config.ArchivePath, currentFileName)
-					}
-
-					archiveArgs = append(archiveArgs, archiveDest)
-
-					te.logger.LogInfo("Executing rclone archive command for job %d, config %d, file %s: rclone %s",
-						job.ID, config.ID, currentFileName, strings.Join(archiveArgs, " "))
-					// Get the rclone path from the environment variable or use the default path
-					rclonePath := os.Getenv("RCLONE_PATH")
-					if rclonePath == "" {
-						rclonePath = "rclone"
-					}
-					// Use the mockable execCommandContext
-					archiveCmd := execCommandContext(context.Background(), rclonePath, archiveArgs...)
-					archiveOutput, archiveErr := archiveCmd.CombinedOutput()
-
-					// Print the output
-					te.logger.LogDebug("Output for file %s: %s", currentFileName, string(archiveOutput))
-
-					// Check if file was successfully transferred
-					if archiveErr != nil {
-						te.logger.LogError("Warning: Error archiving file %s for job %d, config %d: %v", currentFileName, job.ID, config.ID, archiveErr)
-						mutex.Lock()
-						transferErrors = append(transferErrors,
-							fmt.Sprintf("Archive error for file %s: %v", currentFileName, archiveErr))
 						mutex.Unlock()
-					} else {
-						fileStatus = "archived"
 					}
 				}
+			} else if conflictAction == conflictSkipped {
+				// The source is kept so the file can be delivered once the conflict is resolved
+				te.logger.LogInfo("Skipping file %s for job %d, config %d: destination file already exists", currentFileName, job.ID, config.ID)
+				fileStatus = "skipped"
+				destPathForDB = ""
+			} else {
+				mutex.Lock()
+				filesTransferred++
+				mutex.Unlock()
+				destNames.add(names)
+				te.logger.LogInfo("Successfully transferred file %s for job %d, config %d", currentFileName, job.ID, config.ID)
 
-				if config.GetDeleteAfterTransfer() {
-					te.logger.LogInfo("Deleting file %s for job %d, config %d", currentFileName, job.ID, config.ID)
-					deleteArgs := []string{
-						"--config", configPath,
-						"deletefile",
-						sourcePath}
-					// Use the mockable execCommandContext
-					deleteCmd := execCommandContext(context.Background(), rclonePath, deleteArgs...)
-					deleteOutput, deleteErr := deleteCmd.CombinedOutput()
-					te.logger.LogDebug("Output for file %s: %s", currentFileName, string(deleteOutput))
-					if deleteErr != nil {
-						te.logger.LogError("Error deleting file %s for job %d, config %d: %v", currentFileName, job.ID, config.ID, deleteErr)
-						mutex.Lock()
-						transferErrors = append(transferErrors,
-							fmt.Sprintf("Delete error for file %s: %v", currentFileName, deleteErr))
-						mutex.Unlock()
-					} else {
-						if fileStatus == "archived" {
-							fileStatus = "archived_and_deleted"
-						} else {
-							fileStatus = "deleted"
-						}
-					}
+				var postErrors []string
+				fileStatus, postErrors = te.finalizeSourceFile(job, &config, configPath, rclonePath, sourcePath, currentFileName)
+				if len(postErrors) > 0 {
+					mutex.Lock()
+					transferErrors = append(transferErrors, postErrors...)
+					mutex.Unlock()
 				}
 			}
 
@@ -571,19 +652,35 @@ func (te *TransferExecutor) executeConfigTransfer(job db.Job, config db.Transfer
 				OriginalPath:    config.SourcePath,
 				FileSize:        currentFileSize,
 				FileHash:        currentFileHash,
+				OriginalSize:    originalSize,
+				CompressedSize:  compressedSize,
 				CreationTime:    currentCreateTime,
 				ModTime:         currentModTime,
 				ProcessedTime:   time.Now(),
 				DestinationPath: destPathForDB,
 				Status:          fileStatus,
 				ErrorMessage:    fileErrorMsg,
+				FailureCount:    failureCount,
+				ConflictAction:  conflictAction,
 			}
+			applyEncryptionResult(metadata, encryptionResult)
+			metadata.SetDestinationResults(destinationResults)
 
 			if err := te.db.CreateFileMetadata(metadata); err != nil { // Calls interface method
 				te.logger.LogError("Error creating file metadata for %s: %v", currentFileName, err)
 			} else {
 				te.logger.LogDebug("Created file metadata record for %s (ID: %d) with hash: %s", currentFileName, metadata.ID, currentFileHash)
 			}
+			if fileStatus != "error" && fileStatus != "skipped" && fileStatus != fileStatusQuarantined {
+				mutex.Lock()
+				deliveredFiles = append(deliveredFiles, metadata)
+				mutex.Unlock()
+			} else if fileStatus != fileStatusQuarantined {
+				mutex.Lock()
+				heldBackFiles = append(heldBackFiles, currentFileName)
+				mutex.Unlock()
+			}
+			te.updateCheckpoint(currentRunFileID, fileStatus, fileErrorMsg)
 		}()
 	}
 
@@ -593,8 +690,78 @@ func (te *TransferExecutor) executeConfigTransfer(job db.Job, config db.Transfer
 	// Clean up concurrency semaphore
 	close(concurrencySemaphore)
 
+	// Deliver the zip bundle once every file has been staged
+	if bundleFiles && len(bundleEntries) > 0 {
+		bundled := te.deliverBundle(job, &config, targets, keys, configPath, rclonePath, stagingDir, rcloneCommand == "moveto", bundleEntries, &destNames, &transferErrors)
+		filesTransferred += len(bundled)
+		deliveredFiles = append(deliveredFiles, bundled...)
+	}
+
+	// Consume the trigger files whose files have all been delivered or were unchanged
+	if usesTriggers(&config) {
+		completed := make(map[string]bool)
+		for _, metadata := range deliveredFiles {
+			completed[metadata.FileName] = true
+		}
+		for _, fileName := range unchangedFiles {
+			completed[fileName] = true
+		}
+		transferErrors = append(transferErrors, te.consumeTriggers(job, &config, configPath, rclonePath, triggers, completed)...)
+	}
+
+	// Deliver the manifest of the files the run delivered
+	if usesManifest(&config) {
+		transferErrors = append(transferErrors, te.deliverManifest(job, &config, history, targets, configPath, rclonePath, deliveredFiles)...)
+	}
+
+	// Signal completion once the data, and the manifest listing it, is in place
+	if usesMarkers(&config) {
+		transferErrors = append(transferErrors, te.writeMarkers(job, &config, targets, configPath, rclonePath, &destNames, len(transferErrors) > 0)...)
+	}
+
+	// Apply retention rules to the archive and destination once the run is complete
+	transferErrors = append(transferErrors, te.applyRetention(job, &config, rcloneCommand, configPath, rclonePath)...)
+
+	// Move the high-water mark past the files the run delivered
+	if config.GetSkipProcessedFiles() && config.ChangeDetection == changeDetectionHighWaterMark {
+		listed := append(append([]map[string]interface{}{}, files...), waitingFiles...)
+		for _, entry := range waitingFiles {
+			if filePath, ok := entry["Path"].(string); ok {
+				heldBackFiles = append(heldBackFiles, filePath)
+			}
+		}
+		if mark, moved := nextHighWaterMark(config.HighWaterMark, listed, deliveredFiles, heldBackFiles); moved {
+			if err := te.db.UpdateHighWaterMark(config.ID, mark); err != nil { // Calls interface method
+				te.logger.LogError("Error updating high-water mark for job %d, config %d: %v", job.ID, config.ID, err)
+				transferErrors = append(transferErrors, fmt.Sprintf("High-water mark error: %v", err))
+			} else {
+				te.logger.LogInfo("Moved high-water mark for job %d, config %d to %s", job.ID, config.ID, mark.Format(time.RFC3339Nano))
+			}
+		}
+	}
+
+	// Remember the versions of the URLs the run delivered so unchanged files are not downloaded again
+	if isHTTPSource(&config) && config.GetSkipProcessedFiles() {
+		current, err := config.GetHTTPValidators()
+		if err == nil {
+			err = config.SetHTTPValidators(nextHTTPValidators(current, files, deliveredFiles, unchangedFiles))
+		}
+		if err == nil {
+			err = te.db.UpdateHTTPValidators(config.ID, config.SourceHTTPValidators) // Calls interface method
+		}
+		if err != nil {
+			te.logger.LogError("Error updating HTTP validators for job %d, config %d: %v", job.ID, config.ID, err)
+			transferErrors = append(transferErrors, fmt.Sprintf("HTTP validator error: %v", err))
+		}
+	}
+
 	// Update job history with transfer results
 	history.FilesTransferred = filesTransferred
+	history.DuplicateFiles = duplicateFiles
+	if duplicateFiles > 0 {
+		te.logger.LogInfo("Found %d files with content already delivered for job %d, config %d (dedupe scope %s, action %s)",
+			duplicateFiles, job.ID, config.ID, config.DedupeScope, dedupeActionName(&config))
+	}
 
 	if len(transferErrors) > 0 {
 		history.Status = "completed_with_errors"
@@ -621,6 +788,327 @@ func (te *TransferExecutor) executeConfigTransfer(job db.Job, config db.Transfer
 	te.notifier.SendNotifications(&job, history, &config) // Calls interface method
 }
 
+// listTransferFiles lists the source files of a file-by-file transfer, applying the
+// filters of the config and deferring files that are still being written or whose
+// trigger file has not arrived. It also returns the files covered by each trigger file
+// and the files held back for their trigger file.
+func (te *TransferExecutor) listTransferFiles(job db.Job, config *db.TransferConfig, configPath, rclonePath string) ([]map[string]interface{}, map[string][]string, []map[string]interface{}, error) {
+	// HTTP sources are downloaded completely before they are listed, so the stability
+	// check does not apply
+	if isHTTPSource(config) {
+		files, err := te.downloadHTTPFiles(job, config)
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("HTTP Download Error: %v", err)
+		}
+		return files, nil, nil, nil
+	}
+
+	// Use lsjson to get file list and metadata in one operation instead of separate size and ls commands
+	listArgs := []string{
+		"--config", configPath,
+		"lsjson",
+		"--hash",
+		"--recursive",
+	}
+
+	// Add the include/exclude, size and age filters of the config
+	filterArgs, cleanupFilter, err := te.filterArgs(config, 0)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("Filter Creation Error: %v", err)
+	}
+	defer cleanupFilter()
+	listArgs = append(listArgs, filterArgs...)
+
+	// Add source path with bucket for S3-compatible storage
+	listArgs = append(listArgs, buildSourceRemoteRoot(config))
+
+	// Execute lsjson command
+	te.logger.LogDebug("Full lsjson command: %s %v", rclonePath, listArgs)
+	// Use the mockable execCommandContext
+	listCmd := execCommandContext(context.Background(), rclonePath, listArgs...)
+	listOutput, listErr := listCmd.CombinedOutput()
+
+	// Add debug logging of raw output
+	if listErr == nil {
+		te.logger.LogDebug("Raw lsjson output for job %d config %d:\n%s",
+			job.ID,
+			config.ID,
+			string(listOutput))
+	} else {
+		te.logger.LogDebug("Raw lsjson output (error case) for job %d config %d:\n%s",
+			job.ID,
+			config.ID,
+			string(listOutput))
+	}
+
+	if listErr != nil {
+		return nil, nil, nil, withHostKeyFailure(fmt.Errorf("File Listing Error: %v\nOutput: %s", listErr, string(listOutput)), string(listOutput))
+	}
+
+	// Parse JSON output to get file information
+	var fileEntries []map[string]interface{}
+	if err := json.Unmarshal(listOutput, &fileEntries); err != nil {
+		return nil, nil, nil, fmt.Errorf("JSON Parsing Error: %v", err)
+	}
+
+	// Filter out directories
+	var files []map[string]interface{}
+	for _, entry := range fileEntries {
+		// Process directories
+		if isDir, ok := entry["IsDir"].(bool); ok && isDir {
+			continue
+		}
+
+		// Add to files list
+		files = append(files, entry)
+	}
+
+	// Hold back files whose trigger file has not arrived. Triggers are matched before
+	// the stability check so a trigger is not consumed while one of its files is deferred.
+	var triggers map[string][]string
+	var waiting []map[string]interface{}
+	if usesTriggers(config) {
+		files, triggers, waiting, err = te.triggeredFiles(job, config, configPath, rclonePath, files)
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("Trigger File Error: %v", err)
+		}
+	}
+
+	// Defer files that are still being written to a later run
+	files, err = te.stableFiles(job, config, rclonePath, listArgs, files)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("Stability Check Error: %v", err)
+	}
+
+	// Compute the hashes the source does not report so unchanged files can be skipped
+	if config.GetSkipProcessedFiles() && config.ChangeDetection == changeDetectionDownloadHash {
+		if err := te.downloadHashes(job, config, configPath, rclonePath, filterArgs, files); err != nil {
+			return nil, nil, nil, fmt.Errorf("Hash Download Error: %v", err)
+		}
+	}
+	return files, triggers, waiting, nil
+}
+
+// entryHash returns the hash of an lsjson entry, trying several hash algorithms in order of preference
+func entryHash(entry map[string]interface{}) string {
+	hashes, ok := entry["Hashes"].(map[string]interface{})
+	if !ok {
+		return ""
+	}
+	for _, hashType := range []string{"SHA-1", "sha1", "MD5", "md5", "sha256", "crc32"} {
+		if hashStr, ok := hashes[hashType].(string); ok && hashStr != "" {
+			return hashStr
+		}
+	}
+	return ""
+}
+
+// finalizeSourceFile archives and/or deletes a source file after a successful transfer
+// and returns the resulting file status together with any errors that occurred
+func (te *TransferExecutor) finalizeSourceFile(job db.Job, config *db.TransferConfig, configPath, rclonePath, sourcePath, fileName string) (string, []string) {
+	fileStatus := "processed"
+	var errors []string
+
+	// If archiving is enabled and transfer was successful, move files to archive
+	if config.GetArchiveEnabled() && config.ArchivePath != "" {
+		te.logger.LogInfo("Archiving file %s for job %d, config %d", fileName, job.ID, config.ID)
+
+		// We don't need to move the file since we used moveto, but we can copy it to archive
+		archiveArgs := []string{
+			"--config", configPath,
+			"copyto",
+			sourcePath,
+			buildArchiveRemotePath(config, fileName),
+		}
+
+		te.logger.LogInfo("Executing rclone archive command for job %d, config %d, file %s: rclone %s",
+			job.ID, config.ID, fileName, strings.Join(archiveArgs, " "))
+		// Use the mockable execCommandContext
+		archiveCmd := execCommandContext(context.Background(), rclonePath, archiveArgs...)
+		archiveOutput, archiveErr := archiveCmd.CombinedOutput()
+
+		// Print the output
+		te.logger.LogDebug("Output for file %s: %s", fileName, string(archiveOutput))
+
+		// Check if file was successfully transferred
+		if archiveErr != nil {
+			te.logger.LogError("Warning: Error archiving file %s for job %d, config %d: %v", fileName, job.ID, config.ID, archiveErr)
+			errors = append(errors, fmt.Sprintf("Archive error for file %s: %v", fileName, archiveErr))
+		} else {
+			fileStatus = "archived"
+		}
+	}
+
+	if config.GetDeleteAfterTransfer() {
+		te.logger.LogInfo("Deleting file %s for job %d, config %d", fileName, job.ID, config.ID)
+		if deleteErr := te.deleteSourceFile(configPath, rclonePath, sourcePath); deleteErr != nil {
+			te.logger.LogError("Error deleting file %s for job %d, config %d: %v", fileName, job.ID, config.ID, deleteErr)
+			errors = append(errors, fmt.Sprintf("Delete error for file %s: %v", fileName, deleteErr))
+		} else {
+			if fileStatus == "archived" {
+				fileStatus = "archived_and_deleted"
+			} else {
+				fileStatus = "deleted"
+			}
+		}
+	}
+
+	return fileStatus, errors
+}
+
+// deleteSourceFile removes a single file from the source remote
+func (te *TransferExecutor) deleteSourceFile(configPath, rclonePath, sourcePath string) error {
+	deleteArgs := []string{
+		"--config", configPath,
+		"deletefile",
+		sourcePath}
+	// Use the mockable execCommandContext
+	deleteCmd := execCommandContext(context.Background(), rclonePath, deleteArgs...)
+	deleteOutput, deleteErr := deleteCmd.CombinedOutput()
+	te.logger.LogDebug("Output for delete of %s: %s", sourcePath, string(deleteOutput))
+	return deleteErr
+}
+
+// filterArgs returns the rclone flags for the filters of a config and a function
+// that removes the temporary filter file. A minimum age raises the minimum age of the
+// filters, as rclone only applies the last --min-age flag.
+func (te *TransferExecutor) filterArgs(config *db.TransferConfig, minAge time.Duration) ([]string, func(), error) {
+	filter, err := filters.FromConfig(config)
+	if err != nil {
+		return nil, func() {}, err
+	}
+	if err := filter.Validate(); err != nil {
+		return nil, func() {}, err
+	}
+	filter.RaiseMinAge(minAge)
+	// Files moved to the archive or quarantine folder must not be transferred again
+	for _, folder := range []string{config.ArchivePath, config.QuarantinePath} {
+		if dir := sourceFolderInRoot(config, folder); dir != "" {
+			filter.ExcludeDir(dir)
+		}
+	}
+	return filter.Args()
+}
+
+// isBucketStorage checks if a provider type requires the bucket to be part of the path
+func isBucketStorage(providerType string) bool {
+	return providerType == "s3" || providerType == "minio" || providerType == "b2" || providerType == "azureblob" || providerType == "gcs"
+}
+
+// buildSourceRemoteRoot returns the rclone path of the configured source directory
+func buildSourceRemoteRoot(config *db.TransferConfig) string {
+	// The crypt remote is layered over the source directory itself
+	if config.GetSourceCrypt() {
+		return fmt.Sprintf("source_%d_crypt:", config.ID)
+	}
+	// The source remote of an HTTP source reads from the downloads of every run
+	if isHTTPSource(config) {
+		return fmt.Sprintf("source_%d:%s", config.ID, config.SourceHTTPRunDir)
+	}
+	if isBucketStorage(config.SourceType) {
+		if config.SourcePath != "" && config.SourcePath != "/" {
+			return fmt.Sprintf("source_%d:%s/%s", config.ID, config.SourceBucket, config.SourcePath)
+		}
+		return fmt.Sprintf("source_%d:%s", config.ID, config.SourceBucket)
+	}
+	return fmt.Sprintf("source_%d:%s", config.ID, config.SourcePath)
+}
+
+// buildSourceRemotePath returns the rclone path of a file in the source directory
+func buildSourceRemotePath(config *db.TransferConfig, fileName string) string {
+	return joinRemotePath(buildSourceRemoteRoot(config), fileName)
+}
+
+// buildArchiveRemotePath returns the rclone path of a file in the archive directory on the source
+func buildArchiveRemotePath(config *db.TransferConfig, fileName string) string {
+	return buildSourceFolderRemotePath(config, config.ArchivePath, fileName)
+}
+
+// buildSourceFolderRemotePath returns the rclone path of a file in a folder of the source
+// remote, such as the archive or quarantine folder
+func buildSourceFolderRemotePath(config *db.TransferConfig, folder, fileName string) string {
+	// With a crypt source the folder lives inside the encrypted source directory
+	if config.GetSourceCrypt() {
+		return joinRemotePath(fmt.Sprintf("source_%d_crypt:%s", config.ID, strings.Trim(folder, "/")), fileName)
+	}
+	if isBucketStorage(config.SourceType) {
+		return fmt.Sprintf("source_%d:%s/%s/%s", config.ID, config.SourceBucket, folder, fileName)
+	}
+	return fmt.Sprintf("source_%d:%s/%s", config.ID, folder, fileName)
+}
+
+// sourceFolderInRoot returns the path of a folder of the source remote relative to the
+// source directory when the folder lies inside it, or "" otherwise
+func sourceFolderInRoot(config *db.TransferConfig, folder string) string {
+	folder = strings.Trim(folder, "/")
+	if folder == "" {
+		return ""
+	}
+	// With a crypt source the folder lives inside the encrypted source directory
+	if config.GetSourceCrypt() {
+		return folder
+	}
+	folder = path.Clean(folder)
+	root := strings.Trim(path.Clean("/"+config.SourcePath), "/")
+	if root == "" {
+		return folder
+	}
+	if strings.HasPrefix(folder, root+"/") {
+		return strings.TrimPrefix(folder, root+"/")
+	}
+	return ""
+}
+
+// buildDestRemoteRoot returns the rclone path of the configured destination directory
+func buildDestRemoteRoot(config *db.TransferConfig) string {
+	// The crypt remote is layered over the destination directory itself
+	if config.GetDestCrypt() {
+		return fmt.Sprintf("dest_%d_crypt:", config.ID)
+	}
+	// Additional destinations of a config each have their own remote
+	remote := config.GetDestRemote()
+	if isBucketStorage(config.DestinationType) {
+		if config.DestinationPath != "" && config.DestinationPath != "/" {
+			return fmt.Sprintf("%s:%s/%s", remote, config.DestBucket, config.DestinationPath)
+		}
+		return fmt.Sprintf("%s:%s", remote, config.DestBucket)
+	}
+	return fmt.Sprintf("%s:%s", remote, config.DestinationPath)
+}
+
+// buildDestRemotePath returns the rclone path of a file in the destination directory
+func buildDestRemotePath(config *db.TransferConfig, destFile string) string {
+	return joinRemotePath(buildDestRemoteRoot(config), destFile)
+}
+
+// joinRemotePath appends a file name to an rclone path, which may be the root of a remote
+func joinRemotePath(root, fileName string) string {
+	if strings.HasSuffix(root, ":") {
+		return root + fileName
+	}
+	return fmt.Sprintf("%s/%s", root, fileName)
+}
+
+// buildDestPathForDB returns the destination path of a file as stored in the file metadata
+// (without the rclone remote prefix)
+func buildDestPathForDB(config *db.TransferConfig, destFile string) string {
+	if config.DestinationType == "local" {
+		return filepath.Join(config.DestinationPath, destFile)
+	}
+	// AS2 destinations have no path, files are identified by the partner they were sent to
+	if isAS2Destination(config) {
+		return fmt.Sprintf("as2://%s/%s", config.DestAS2Partner, destFile)
+	}
+	// For remote destinations, store the path format
+	if isBucketStorage(config.DestinationType) {
+		if config.DestinationPath != "" && config.DestinationPath != "/" {
+			return fmt.Sprintf("%s/%s/%s", config.DestBucket, config.DestinationPath, destFile)
+		}
+		return fmt.Sprintf("%s/%s", config.DestBucket, destFile)
+	}
+	return fmt.Sprintf("%s/%s", config.DestinationPath, destFile)
+}
+
 // isDirectoryBasedTransfer checks if a transfer command operates on directories rather than individual files
 func isDirectoryBasedTransfer(commandName string) bool {
 	// These commands operate on entire directories, not file-by-file
@@ -634,6 +1122,46 @@ func isDirectoryBasedTransfer(commandName string) bool {
 	return dirBasedCommands[commandName]
 }
 
+// needsFileByFile returns what the options of a config do with individual files, each of
+// which requires transferring the files one at a time
+func needsFileByFile(config *db.TransferConfig) []string {
+	var reasons []string
+	add := func(needed bool, reason string) {
+		if needed {
+			reasons = append(reasons, reason)
+		}
+	}
+	add(config.CompressionType != compressionNone || config.GetDecompressOnReceive() || config.EncryptionMode != encryptionModeNone,
+		"compress or encrypt files")
+	add(isHTTPSource(config), "download files from an HTTP source")
+	add(isAS2Destination(config), "send files to an AS2 partner")
+	add(config.GetStabilityCheck(), "check files are stable before transfer")
+	add(checksConflicts(config), "apply the conflict policy")
+	add(usesAtomicDelivery(config), "deliver files atomically")
+	add(usesFanOut(config), "deliver files to every destination")
+	add(usesManifest(config), "list delivered files in a manifest")
+	add(usesTriggers(config) || usesMarkers(config), "match trigger files and completion markers")
+	add(usesQuarantine(config), "quarantine files that keep failing")
+	return reasons
+}
+
+// transfersFileByFile reports whether a command transfers files one at a time, directly
+// or once copy and move are switched to copyto and moveto
+func transfersFileByFile(commandName string) bool {
+	return determineCommandType(commandName) == "transfer" && !isDirectoryBasedTransfer(fileCommandFor(commandName))
+}
+
+// ValidateCommand rejects commands that do not transfer files one at a time for configs
+// with options that act on individual files, which the command would silently ignore
+func ValidateCommand(config *db.TransferConfig, commandName string) error {
+	reasons := needsFileByFile(config)
+	if len(reasons) == 0 || transfersFileByFile(commandName) {
+		return nil
+	}
+	return fmt.Errorf("%s does not transfer files one at a time, which is needed to %s; use copy or move",
+		commandName, strings.Join(reasons, ", "))
+}
+
 // determineCommandType categorizes rclone commands into types for execution
 func determineCommandType(commandName string) string {
 	// File transfer commands
@@ -718,34 +1246,34 @@ func (te *TransferExecutor) executeSimpleCommand(cmdName string, cmdType string,
 	// Prepare base arguments
 	baseArgs := te.prepareBaseArguments(cmdName, &config, nil) // Use method call
 
-	// Prepare source and destination paths
-	var sourcePath, destPath string
-
-	// Handle source path with bucket for S3-compatible storage
-	if config.SourceType == "s3" || config.SourceType == "minio" || config.SourceType == "b2" {
-		sourcePath = fmt.Sprintf("source_%d:%s", config.ID, config.SourceBucket)
-		if config.SourcePath != "" && config.SourcePath != "/" {
-			sourcePath = fmt.Sprintf("source_%d:%s/%s", config.ID, config.SourceBucket, config.SourcePath)
-		}
-	} else {
-		sourcePath = fmt.Sprintf("source_%d:%s", config.ID, config.SourcePath)
-	}
-
-	// Handle destination path with bucket for S3-compatible storage
-	if config.DestinationType == "s3" || config.DestinationType == "minio" || config.DestinationType == "b2" {
-		destPath = fmt.Sprintf("dest_%d:%s", config.ID, config.DestBucket)
-		if config.DestinationPath != "" && config.DestinationPath != "/" {
-			destPath = fmt.Sprintf("dest_%d:%s/%s", config.ID, config.DestBucket, config.DestinationPath)
-		}
-	} else {
-		destPath = fmt.Sprintf("dest_%d:%s", config.ID, config.DestinationPath)
-	}
+	// Prepare source and destination paths (bucket or container is included for S3, MinIO, B2, Azure Blob Storage and GCS)
+	sourcePath := buildSourceRemoteRoot(&config)
+	destPath := buildDestRemoteRoot(&config)
 
 	// Add appropriate paths based on command type
 	args := baseArgs // Start with base args prepared by prepareBaseArguments
 	switch cmdType {
 	case "transfer":
 		// Directory-based transfers and file-specific transfers handled here
+		// Let rclone skip files that were modified too recently, with the larger of the
+		// minimum file age and the minimum age of the filters
+		filterArgs, cleanupFilter, err := te.filterArgs(&config, time.Duration(config.MinFileAge)*time.Second)
+		if err != nil {
+			te.logger.LogError("Error creating filter file for job %d, config %d: %v", job.ID, config.ID, err)
+			te.failRun(job, &config, history, fmt.Sprintf("Filter Creation Error: %v", err))
+			return
+		}
+		defer cleanupFilter()
+		args = append(args, filterArgs...)
+		if cmdName == "bisync" {
+			// GoMFT keeps the bisync listings and decides when a resync is needed
+			args, err = te.bisyncArgs(&config, args)
+			if err != nil {
+				te.logger.LogError("Error preparing bisync for job %d, config %d: %v", job.ID, config.ID, err)
+				te.failRun(job, &config, history, fmt.Sprintf("Bisync Error: %v", err))
+				return
+			}
+		}
 		args = append(args, sourcePath, destPath)
 	case "maintenance":
 		// Check command needs both source and destination, others may just need source
@@ -791,14 +1319,7 @@ func (te *TransferExecutor) executeSimpleCommand(cmdName string, cmdType string,
 	if err != nil {
 		te.logger.LogError("Error creating temporary log file for job %d, config %d: %v", job.ID, config.ID, err)
 		// Update history and return if log file creation fails
-		history.Status = "failed"
-		history.ErrorMessage = fmt.Sprintf("Log File Creation Error: %v", err)
-		endTime := time.Now()
-		history.EndTime = &endTime
-		if updateErr := te.db.UpdateJobHistory(history); updateErr != nil {
-			te.logger.LogError("Error updating job history after log file error for job %d, config %d: %v", job.ID, config.ID, updateErr)
-		}
-		te.notifier.SendNotifications(&job, history, &config)
+		te.failRun(job, &config, history, fmt.Sprintf("Log File Creation Error: %v", err))
 		return
 	}
 	defer os.Remove(tempLogFile.Name()) // Ensure cleanup
@@ -882,7 +1403,7 @@ func (te *TransferExecutor) executeSimpleCommand(cmdName string, cmdType string,
 					destFile := fileName // Assume filename is the same unless output pattern is used (not handled here)
 					if config.DestinationType == "local" {
 						destPathForDB = filepath.Join(config.DestinationPath, destFile)
-					} else if config.DestinationType == "s3" || config.DestinationType == "minio" || config.DestinationType == "b2" {
+					} else if isBucketStorage(config.DestinationType) {
 						if config.DestinationPath != "" && config.DestinationPath != "/" {
 							destPathForDB = fmt.Sprintf("%s/%s/%s", config.DestBucket, config.DestinationPath, destFile)
 						} else {
@@ -932,6 +1453,14 @@ func (te *TransferExecutor) executeSimpleCommand(cmdName string, cmdType string,
 	history.EndTime = &time.Time{}
 	*history.EndTime = startTime.Add(duration)
 
+	if cmdName == "bisync" {
+		// Report the files that were changed on both sides in the run details
+		if conflicts := bisyncConflicts(string(logContent)); len(conflicts) > 0 {
+			te.logger.LogInfo("Bisync found %d conflicts for job %d, config %d", len(conflicts), job.ID, config.ID)
+			history.Conflicts = strings.Join(conflicts, "\n")
+		}
+	}
+
 	// Check for pattern in stderr that indicates successful completion with warnings
 	// Some commands like sync may complete successfully but with warnings
 	successWithWarnings := strings.Contains(stderr.String(), "Transferred:") &&
@@ -946,6 +1475,14 @@ func (te *TransferExecutor) executeSimpleCommand(cmdName string, cmdType string,
 		history.Status = "failed"
 		// Use stderr directly from the buffer as the error from Run() might not contain it
 		history.ErrorMessage = fmt.Sprintf("Command Error: %v\nStderr: %s", err, stderr.String())
+		if cmdName == "bisync" {
+			if reason := bisyncFailure(&config, stderr.String()+string(logContent)); reason != "" {
+				history.ErrorMessage = reason + "\n" + history.ErrorMessage
+			}
+		}
+		if reason := hostKeyFailure(stderr.String() + string(logContent)); reason != "" {
+			history.ErrorMessage = reason + "\n" + history.ErrorMessage
+		}
 	} else {
 		te.logger.LogInfo("Successfully executed command '%s' for job %d, config %d (duration: %v)",
 			cmdName, job.ID, config.ID, duration)
@@ -987,6 +1524,17 @@ func (te *TransferExecutor) executeSimpleCommand(cmdName string, cmdType string,
 				te.logger.LogDebug("Could not determine FilesTransferred from stderr stats or log parsing.")
 			}
 
+			if cmdName == "bisync" {
+				// The listings now match the current paths, so later runs skip the resync
+				te.saveBisyncState(&config)
+			}
+
+			// Apply retention rules to the archive and destination once the transfer has completed
+			if retentionErrors := te.applyRetention(job, &config, cmdName, configPath, rclonePath); len(retentionErrors) > 0 {
+				history.Status = "completed_with_errors"
+				history.ErrorMessage = strings.Join(retentionErrors, "\n")
+			}
+
 		} else {
 			// For other commands, we don't have file counts, but the command completed
 			history.Status = "completed"
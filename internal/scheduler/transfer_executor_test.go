@@ -7,12 +7,14 @@ import (
 	"fmt"
 	"os" // Added import
 	"os/exec"
+	"path/filepath"
 	"strings"
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/starfleetcptn/gomft/internal/db"
-	// Removed unused: encoding/json, path/filepath, reflect, time, gorm.io/gorm
+	// Removed unused: encoding/json, path/filepath, reflect, gorm.io/gorm
 )
 
 // --- Mock Implementations ---
@@ -23,15 +25,33 @@ var _ TransferDB = (*mockTransferDB)(nil)
 type mockTransferDB struct {
 	mu                           sync.Mutex
 	GetConfigRclonePathFunc      func(config *db.TransferConfig) string
+	GetConfigBisyncDirFunc       func(config *db.TransferConfig) string
+	GetConfigHTTPDirFunc         func(config *db.TransferConfig) string
+	GetRunManifestDirFunc        func(history *db.JobHistory) string
 	GetRcloneCommandFunc         func(id uint) (*db.RcloneCommand, error)
 	UpdateJobHistoryFunc         func(history *db.JobHistory) error
 	CreateFileMetadataFunc       func(metadata *db.FileMetadata) error
+	CreateAuditLogFunc           func(log *db.AuditLog) error
+	GetRunFilesFunc              func(jobHistoryID uint) ([]db.RunFile, error)
 	GetRcloneCommandFlagsMapFunc func(commandID uint) (map[uint]db.RcloneCommandFlag, error)
+	GetEncryptionKeyFunc         func(id uint) (*db.EncryptionKey, error)
+	GetLatestFileMetadataFunc    func(jobID, configID uint, fileName string) (*db.FileMetadata, error)
+	UpdateHighWaterMarkFunc      func(configID uint, mark time.Time) error
+	UpdateHTTPValidatorsFunc     func(configID uint, validators string) error
+	RefreshOAuthTokensFunc       func(config *db.TransferConfig) error
+	WriteSSHKeysFunc             func(config *db.TransferConfig) (func(), error)
+	PinHostKeysOnFirstUseFunc    func(config *db.TransferConfig) ([]*db.HostKey, error)
+	GetAS2PartnerByNameFunc      func(name string) (*db.AS2Partner, error)
 
 	// Store calls/data for verification
 	updatedHistory   *db.JobHistory
 	createdMetadata  []*db.FileMetadata
+	updatedMetadata  []*db.FileMetadata
+	createdAuditLogs []*db.AuditLog
+	createdRunFiles  []db.RunFile
+	runFileStatuses  map[uint]string
 	rcloneConfigPath string
+	as2Messages      []*db.AS2Message
 }
 
 func (m *mockTransferDB) GetConfigRclonePath(config *db.TransferConfig) string {
@@ -41,6 +61,24 @@ func (m *mockTransferDB) GetConfigRclonePath(config *db.TransferConfig) string {
 	m.rcloneConfigPath = "/tmp/mock_rclone.conf" // Default mock path
 	return m.rcloneConfigPath
 }
+func (m *mockTransferDB) GetConfigBisyncDir(config *db.TransferConfig) string {
+	if m.GetConfigBisyncDirFunc != nil {
+		return m.GetConfigBisyncDirFunc(config)
+	}
+	return filepath.Join(os.TempDir(), "gomft_mock_bisync", fmt.Sprintf("config_%d", config.ID))
+}
+func (m *mockTransferDB) GetConfigHTTPDir(config *db.TransferConfig) string {
+	if m.GetConfigHTTPDirFunc != nil {
+		return m.GetConfigHTTPDirFunc(config)
+	}
+	return filepath.Join(os.TempDir(), "gomft_mock_http", fmt.Sprintf("config_%d", config.ID))
+}
+func (m *mockTransferDB) GetRunManifestDir(history *db.JobHistory) string {
+	if m.GetRunManifestDirFunc != nil {
+		return m.GetRunManifestDirFunc(history)
+	}
+	return filepath.Join(os.TempDir(), "gomft_mock_manifests", fmt.Sprintf("run_%d", history.ID))
+}
 func (m *mockTransferDB) GetRcloneCommand(id uint) (*db.RcloneCommand, error) {
 	if m.GetRcloneCommandFunc != nil {
 		return m.GetRcloneCommandFunc(id)
@@ -70,6 +108,97 @@ func (m *mockTransferDB) CreateFileMetadata(metadata *db.FileMetadata) error {
 	metadata.ID = uint(len(m.createdMetadata)) // Assign a mock ID
 	return nil                                 // Default success
 }
+func (m *mockTransferDB) CreateAuditLog(log *db.AuditLog) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.createdAuditLogs = append(m.createdAuditLogs, log) // Store created audit logs
+	if m.CreateAuditLogFunc != nil {
+		return m.CreateAuditLogFunc(log)
+	}
+	return nil // Default success
+}
+func (m *mockTransferDB) CreateRunFiles(files []db.RunFile) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i := range files {
+		files[i].ID = uint(len(m.createdRunFiles) + 1) // Assign a mock ID
+		m.createdRunFiles = append(m.createdRunFiles, files[i])
+	}
+	return nil // Default success
+}
+func (m *mockTransferDB) GetRunFiles(jobHistoryID uint) ([]db.RunFile, error) {
+	if m.GetRunFilesFunc != nil {
+		return m.GetRunFilesFunc(jobHistoryID)
+	}
+	return nil, nil
+}
+func (m *mockTransferDB) UpdateRunFileStatus(id uint, status, errorMessage string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.runFileStatuses == nil {
+		m.runFileStatuses = make(map[uint]string)
+	}
+	m.runFileStatuses[id] = status // Store the last state of each file
+	return nil
+}
+func (m *mockTransferDB) GetLatestFileMetadata(jobID, configID uint, fileName string) (*db.FileMetadata, error) {
+	if m.GetLatestFileMetadataFunc != nil {
+		return m.GetLatestFileMetadataFunc(jobID, configID, fileName)
+	}
+	return nil, errors.New("mock GetLatestFileMetadata not found")
+}
+func (m *mockTransferDB) UpdateFileMetadata(metadata *db.FileMetadata) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.updatedMetadata = append(m.updatedMetadata, metadata) // Store updated metadata
+	return nil
+}
+func (m *mockTransferDB) UpdateHighWaterMark(configID uint, mark time.Time) error {
+	if m.UpdateHighWaterMarkFunc != nil {
+		return m.UpdateHighWaterMarkFunc(configID, mark)
+	}
+	return nil
+}
+func (m *mockTransferDB) UpdateHTTPValidators(configID uint, validators string) error {
+	if m.UpdateHTTPValidatorsFunc != nil {
+		return m.UpdateHTTPValidatorsFunc(configID, validators)
+	}
+	return nil
+}
+func (m *mockTransferDB) RefreshOAuthTokens(config *db.TransferConfig) error {
+	if m.RefreshOAuthTokensFunc != nil {
+		return m.RefreshOAuthTokensFunc(config)
+	}
+	return nil
+}
+func (m *mockTransferDB) WriteSSHKeys(config *db.TransferConfig) (func(), error) {
+	if m.WriteSSHKeysFunc != nil {
+		return m.WriteSSHKeysFunc(config)
+	}
+	return func() {}, nil
+}
+func (m *mockTransferDB) PinHostKeysOnFirstUse(config *db.TransferConfig) ([]*db.HostKey, error) {
+	if m.PinHostKeysOnFirstUseFunc != nil {
+		return m.PinHostKeysOnFirstUseFunc(config)
+	}
+	return nil, nil
+}
+func (m *mockTransferDB) GetAS2PartnerByName(name string) (*db.AS2Partner, error) {
+	if m.GetAS2PartnerByNameFunc != nil {
+		return m.GetAS2PartnerByNameFunc(name)
+	}
+	return nil, fmt.Errorf("AS2 partner %s not found", name)
+}
+func (m *mockTransferDB) CreateAS2Message(message *db.AS2Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.as2Messages = append(m.as2Messages, message)
+	message.ID = uint(len(m.as2Messages)) // Assign a mock ID
+	return nil
+}
+func (m *mockTransferDB) UpdateAS2Message(message *db.AS2Message) error {
+	return nil // Messages are kept by pointer
+}
 func (m *mockTransferDB) GetRcloneCommandFlagsMap(commandID uint) (map[uint]db.RcloneCommandFlag, error) {
 	if m.GetRcloneCommandFlagsMapFunc != nil {
 		return m.GetRcloneCommandFlagsMapFunc(commandID)
@@ -77,6 +206,12 @@ func (m *mockTransferDB) GetRcloneCommandFlagsMap(commandID uint) (map[uint]db.R
 	// Corrected type name
 	return make(map[uint]db.RcloneCommandFlag), nil // Default empty map
 }
+func (m *mockTransferDB) GetEncryptionKey(id uint) (*db.EncryptionKey, error) {
+	if m.GetEncryptionKeyFunc != nil {
+		return m.GetEncryptionKeyFunc(id)
+	}
+	return nil, errors.New("mock GetEncryptionKey not found")
+}
 func (m *mockTransferDB) Reset() {
 	m.mu.Lock()
 	defer m.mu.Unlock()
@@ -133,6 +268,7 @@ type mockTransferMetadataHandler struct {
 	mu                             sync.Mutex
 	HasFileBeenProcessedFunc       func(jobID uint, fileHash string) (bool, *db.FileMetadata, error)
 	CheckFileProcessingHistoryFunc func(jobID uint, fileName string) (*db.FileMetadata, error)
+	FindDuplicateFunc              func(jobID uint, config *db.TransferConfig, fileHash string, fileSize int64) (*db.FileMetadata, error)
 }
 
 func (m *mockTransferMetadataHandler) hasFileBeenProcessed(jobID uint, fileHash string) (bool, *db.FileMetadata, error) {
@@ -147,6 +283,12 @@ func (m *mockTransferMetadataHandler) checkFileProcessingHistory(jobID uint, fil
 	}
 	return nil, fmt.Errorf("mock history not found for %s", fileName) // Default: not found
 }
+func (m *mockTransferMetadataHandler) findDuplicate(jobID uint, config *db.TransferConfig, fileHash string, fileSize int64) (*db.FileMetadata, error) {
+	if m.FindDuplicateFunc != nil {
+		return m.FindDuplicateFunc(jobID, config, fileHash, fileSize)
+	}
+	return nil, nil // Default: no duplicate
+}
 func (m *mockTransferMetadataHandler) Reset() {
 	m.mu.Lock()
 	defer m.mu.Unlock()
@@ -349,6 +491,187 @@ func TestExecuteSimpleCommand_Failure(t *testing.T) {
 	}
 }
 
+func TestExecuteConfigTransfer_SSHKeyError(t *testing.T) {
+	comps := setupTestExecutor()
+	defer comps.logger.Close()
+
+	comps.db.WriteSSHKeysFunc = func(config *db.TransferConfig) (func(), error) {
+		return nil, fmt.Errorf("SSH key %q not found", "partner")
+	}
+	restoreExec := MockExecCommand(func(ctx context.Context, command string, args ...string) *exec.Cmd {
+		t.Errorf("rclone was run without the SSH key: %v", args)
+		return exec.CommandContext(ctx, "false")
+	})
+	defer restoreExec()
+
+	job := db.Job{ID: 3, Name: "Key Job"}
+	config := db.TransferConfig{ID: 30, SourceType: "sftp", SourceHost: "sftp.example.com", SourceSSHKey: "partner", SourcePath: "/src", DestinationType: "local", DestinationPath: "/dst"}
+	history := &db.JobHistory{ID: 300, JobID: 3, ConfigID: 30}
+
+	comps.executor.executeConfigTransfer(job, config, history)
+
+	comps.db.mu.Lock()
+	defer comps.db.mu.Unlock()
+	if comps.db.updatedHistory == nil || comps.db.updatedHistory.Status != "failed" {
+		t.Fatalf("Expected the run to fail, got %+v", comps.db.updatedHistory)
+	}
+	if !strings.Contains(comps.db.updatedHistory.ErrorMessage, "SSH Key Error") {
+		t.Errorf("Expected an SSH key error, got %q", comps.db.updatedHistory.ErrorMessage)
+	}
+}
+
+func TestExecuteConfigTransfer_HostKeyScanError(t *testing.T) {
+	comps := setupTestExecutor()
+	defer comps.logger.Close()
+
+	comps.db.PinHostKeysOnFirstUseFunc = func(config *db.TransferConfig) ([]*db.HostKey, error) {
+		return nil, fmt.Errorf("failed to fetch the host key of %s", "sftp.example.com:22")
+	}
+	restoreExec := MockExecCommand(func(ctx context.Context, command string, args ...string) *exec.Cmd {
+		t.Errorf("rclone was run against an unpinned server: %v", args)
+		return exec.CommandContext(ctx, "false")
+	})
+	defer restoreExec()
+
+	job := db.Job{ID: 4, Name: "Unpinned Job"}
+	config := db.TransferConfig{ID: 40, SourceType: "sftp", SourceHost: "sftp.example.com", SourcePath: "/src", DestinationType: "local", DestinationPath: "/dst"}
+	history := &db.JobHistory{ID: 400, JobID: 4, ConfigID: 40}
+
+	comps.executor.executeConfigTransfer(job, config, history)
+
+	comps.db.mu.Lock()
+	defer comps.db.mu.Unlock()
+	if comps.db.updatedHistory == nil || comps.db.updatedHistory.Status != "failed" {
+		t.Fatalf("Expected the run to fail, got %+v", comps.db.updatedHistory)
+	}
+	if !strings.Contains(comps.db.updatedHistory.ErrorMessage, "Host Key Error") {
+		t.Errorf("Expected a host key error, got %q", comps.db.updatedHistory.ErrorMessage)
+	}
+}
+
+func TestPinHostKeysOnFirstUse(t *testing.T) {
+	comps := setupTestExecutor()
+	defer comps.logger.Close()
+
+	comps.db.PinHostKeysOnFirstUseFunc = func(config *db.TransferConfig) ([]*db.HostKey, error) {
+		return []*db.HostKey{{ID: 5, ConfigID: config.ID, Host: "sftp.example.com", Port: 22, KeyType: "ssh-ed25519", Fingerprint: "SHA256:abc"}}, nil
+	}
+	config := &db.TransferConfig{ID: 40, CreatedBy: 2, SourceType: "sftp", SourceHost: "sftp.example.com"}
+	if err := comps.executor.pinHostKeysOnFirstUse(config); err != nil {
+		t.Fatalf("pinHostKeysOnFirstUse() error = %v", err)
+	}
+
+	comps.db.mu.Lock()
+	defer comps.db.mu.Unlock()
+	if len(comps.db.createdAuditLogs) != 1 {
+		t.Fatalf("Expected one audit log, got %d", len(comps.db.createdAuditLogs))
+	}
+	auditLog := comps.db.createdAuditLogs[0]
+	if auditLog.Action != "pin" || auditLog.EntityType != "host_key" || auditLog.EntityID != 5 || auditLog.UserID != 2 {
+		t.Errorf("Unexpected audit log %+v", auditLog)
+	}
+	if !strings.Contains(comps.logBuf.String(), "SHA256:abc") {
+		t.Errorf("Expected the pinned fingerprint to be logged, got:\n%s", comps.logBuf.String())
+	}
+}
+
+func TestRemotePathsWithCrypt(t *testing.T) {
+	crypt := true
+	config := &db.TransferConfig{
+		ID:              7,
+		SourceType:      "sftp",
+		SourcePath:      "/outbound",
+		ArchivePath:     "/archive/",
+		DestinationType: "s3",
+		DestBucket:      "backups",
+		DestinationPath: "daily",
+	}
+
+	if got := buildSourceRemotePath(config, "a.csv"); got != "source_7:/outbound/a.csv" {
+		t.Errorf("buildSourceRemotePath() = %q", got)
+	}
+	if got := buildDestRemotePath(config, "a.csv"); got != "dest_7:backups/daily/a.csv" {
+		t.Errorf("buildDestRemotePath() = %q", got)
+	}
+	if got := sourceFolderInRoot(config, config.ArchivePath); got != "" {
+		t.Errorf("sourceFolderInRoot() outside the source = %q, want none", got)
+	}
+
+	config.SourceCrypt = &crypt
+	config.DestCrypt = &crypt
+	tests := []struct {
+		name string
+		got  string
+		want string
+	}{
+		{"Source root", buildSourceRemoteRoot(config), "source_7_crypt:"},
+		{"Source file", buildSourceRemotePath(config, "a.csv"), "source_7_crypt:a.csv"},
+		{"Archive file", buildArchiveRemotePath(config, "a.csv"), "source_7_crypt:archive/a.csv"},
+		{"Archive folder in source", sourceFolderInRoot(config, config.ArchivePath), "archive"},
+		{"Destination root", buildDestRemoteRoot(config), "dest_7_crypt:"},
+		{"Destination file", buildDestRemotePath(config, "a.csv"), "dest_7_crypt:a.csv"},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("%s = %q, want %q", tt.name, tt.got, tt.want)
+		}
+	}
+}
+
+func TestRemotePathsWithAzure(t *testing.T) {
+	config := &db.TransferConfig{
+		ID:              7,
+		SourceType:      "azureblob",
+		SourceBucket:    "inbound",
+		SourcePath:      "orders",
+		ArchivePath:     "archive",
+		DestinationType: "azurefiles",
+		DestShare:       "reports",
+		DestinationPath: "/daily",
+	}
+
+	// Blob containers are part of the path like buckets, file shares are set on the remote
+	tests := []struct {
+		name string
+		got  string
+		want string
+	}{
+		{"Source file", buildSourceRemotePath(config, "a.csv"), "source_7:inbound/orders/a.csv"},
+		{"Archive file", buildArchiveRemotePath(config, "a.csv"), "source_7:inbound/archive/a.csv"},
+		{"Destination file", buildDestRemotePath(config, "a.csv"), "dest_7:/daily/a.csv"},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("%s = %q, want %q", tt.name, tt.got, tt.want)
+		}
+	}
+}
+
+func TestRemotePathsWithGCS(t *testing.T) {
+	config := &db.TransferConfig{
+		ID:              8,
+		SourceType:      "local",
+		SourcePath:      "/data",
+		DestinationType: "gcs",
+		DestBucket:      "archive",
+		DestinationPath: "daily",
+	}
+
+	tests := []struct {
+		name string
+		got  string
+		want string
+	}{
+		{"Destination file", buildDestRemotePath(config, "a.csv"), "dest_8:archive/daily/a.csv"},
+		{"Destination path for DB", buildDestPathForDB(config, "a.csv"), "archive/daily/a.csv"},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("%s = %q, want %q", tt.name, tt.got, tt.want)
+		}
+	}
+}
+
 // TODO: Add tests for executeConfigTransfer (file-by-file)
 // - Success case
 // - Error during lsjson
@@ -365,3 +688,31 @@ func TestExecuteSimpleCommand_Failure(t *testing.T) {
 // - Concurrent transfers limit
 // - Output pattern usage
 // - Filter usage
+
+func TestValidateCommand(t *testing.T) {
+	encrypted := db.TransferConfig{EncryptionMode: encryptionModeEncrypt}
+	manifest := db.TransferConfig{ManifestFormat: "csv"}
+	tests := []struct {
+		name    string
+		config  db.TransferConfig
+		command string
+		wantErr string
+	}{
+		{"Copy", encrypted, "copy", ""},
+		{"Moveto", encrypted, "moveto", ""},
+		{"Sync", encrypted, "sync", "compress or encrypt files"},
+		{"Bisync", encrypted, "bisync", "compress or encrypt files"},
+		{"Listing", manifest, "lsjson", "list delivered files in a manifest"},
+		{"HTTP source", db.TransferConfig{SourceType: "http"}, "sync", "download files from an HTTP source"},
+		{"Sync without file options", db.TransferConfig{}, "sync", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateCommand(&tt.config, tt.command)
+			if tt.wantErr == "" && err != nil || tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Errorf("ValidateCommand() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,297 @@
+package scheduler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/starfleetcptn/gomft/internal/db"
+)
+
+// Trigger and marker modes
+const (
+	companionPerFile   = "file"   // One trigger or marker next to each data file
+	companionPerFolder = "folder" // One trigger or marker in each folder holding data files
+)
+
+// Actions applied to a trigger file once the files it covers have been delivered
+const (
+	triggerActionDelete  = "delete"
+	triggerActionArchive = "archive"
+)
+
+// defaultCompanionName is appended to the data file name in per-file mode, and is the
+// file name in per-folder mode, when no name is configured
+const defaultCompanionName = ".done"
+
+// usesTriggers reports whether files are only transferred once their trigger file exists
+func usesTriggers(config *db.TransferConfig) bool {
+	return config.TriggerMode != ""
+}
+
+// usesMarkers reports whether completion markers are written at the destination
+func usesMarkers(config *db.TransferConfig) bool {
+	return config.MarkerMode != ""
+}
+
+// companionPath returns the path of the trigger or marker file of a data file. In per-file
+// mode the name may use the ${filename} and ${ext} variables of the data file.
+func companionPath(mode, name, filePath string) (string, error) {
+	dir, base := path.Split(filePath)
+	switch mode {
+	case companionPerFile:
+		if name == "" {
+			return filePath + defaultCompanionName, nil
+		}
+		p := &outputPattern{pattern: name, now: time.Now()}
+		expanded, err := p.substitute(outputPatternFile{name: base, modTime: p.now}, nil)
+		if err != nil {
+			return "", err
+		}
+		name = expanded
+	case companionPerFolder:
+		if name == "" {
+			name = defaultCompanionName
+		}
+	default:
+		return "", fmt.Errorf("unknown mode %q", mode)
+	}
+	if strings.Contains(name, "/") {
+		return "", fmt.Errorf("%s must be a file name without directories", name)
+	}
+	return dir + name, nil
+}
+
+// validateCompanion checks a trigger or marker mode and name
+func validateCompanion(mode, name string) error {
+	switch mode {
+	case "":
+		return nil
+	case companionPerFolder:
+		if patternVariableRegex.MatchString(name) {
+			return fmt.Errorf("a per-folder name cannot use variables")
+		}
+	case companionPerFile:
+	default:
+		return fmt.Errorf("unknown mode %q", mode)
+	}
+	example := "data/example.csv"
+	companion, err := companionPath(mode, name, example)
+	if err != nil {
+		return err
+	}
+	if companion == example {
+		return fmt.Errorf("the name must differ from the data file name")
+	}
+	return nil
+}
+
+// ValidateTriggers checks the trigger and completion marker options of a config
+func ValidateTriggers(config *db.TransferConfig) error {
+	if err := validateCompanion(config.TriggerMode, config.TriggerName); err != nil {
+		return fmt.Errorf("trigger file: %v", err)
+	}
+	switch config.TriggerAction {
+	case "", triggerActionDelete:
+	case triggerActionArchive:
+		if config.ArchivePath == "" {
+			return fmt.Errorf("trigger file: an archive path is required to archive trigger files")
+		}
+	default:
+		return fmt.Errorf("trigger file: unknown action %q", config.TriggerAction)
+	}
+	if err := validateCompanion(config.MarkerMode, config.MarkerName); err != nil {
+		return fmt.Errorf("completion marker: %v", err)
+	}
+	return nil
+}
+
+// triggerFiles returns the paths of the trigger files among the files of the source
+func triggerFiles(config *db.TransferConfig, sourceFiles map[string]bool) map[string]bool {
+	return companionFiles(config.TriggerMode, config.TriggerName, sourceFiles)
+}
+
+// companionFiles returns the paths of the trigger or marker files among a set of files
+func companionFiles(mode, name string, files map[string]bool) map[string]bool {
+	companions := make(map[string]bool)
+	for filePath := range files {
+		companion, err := companionPath(mode, name, filePath)
+		if err != nil || !files[companion] {
+			continue
+		}
+		// A per-folder companion covers the other files of its folder, a per-file
+		// companion belongs to a different file
+		if companion != filePath || mode == companionPerFolder {
+			companions[companion] = true
+		}
+	}
+	return companions
+}
+
+// triggeredFiles holds back the listed files whose trigger file has not arrived yet and
+// removes the trigger files from the listing. It returns the files that are ready, the
+// files each trigger covers and the files held back.
+func (te *TransferExecutor) triggeredFiles(job db.Job, config *db.TransferConfig, configPath, rclonePath string, files []map[string]interface{}) ([]map[string]interface{}, map[string][]string, []map[string]interface{}, error) {
+	// Trigger files may be excluded by the filters of the config, so the source is
+	// listed again without them
+	listArgs := []string{
+		"--config", configPath,
+		"lsjson",
+		"--files-only",
+		"--recursive",
+		"--no-mimetype",
+		buildSourceRemoteRoot(config),
+	}
+	te.logger.LogDebug("Full trigger lsjson command: %s %v", rclonePath, listArgs)
+	listCmd := execCommandContext(context.Background(), rclonePath, listArgs...)
+	listOutput, err := listCmd.CombinedOutput()
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to list trigger files: %v\nOutput: %s", err, string(listOutput))
+	}
+	var entries []map[string]interface{}
+	if err := json.Unmarshal(listOutput, &entries); err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to parse trigger listing: %v", err)
+	}
+	sourceFiles := make(map[string]bool)
+	for _, entry := range entries {
+		if filePath, ok := entry["Path"].(string); ok {
+			sourceFiles[filePath] = true
+		}
+	}
+
+	triggers := triggerFiles(config, sourceFiles)
+	var ready, waiting []map[string]interface{}
+	covered := make(map[string][]string)
+	for _, entry := range files {
+		filePath, _ := entry["Path"].(string)
+		if triggers[filePath] {
+			continue
+		}
+		trigger, err := companionPath(config.TriggerMode, config.TriggerName, filePath)
+		if err != nil || !sourceFiles[trigger] {
+			te.logger.LogDebug("Waiting for the trigger file of %s for job %d, config %d", filePath, job.ID, config.ID)
+			waiting = append(waiting, entry)
+			continue
+		}
+		ready = append(ready, entry)
+		covered[trigger] = append(covered[trigger], filePath)
+	}
+	if len(waiting) > 0 {
+		te.logger.LogInfo("Holding back %d files without a trigger file for job %d, config %d", len(waiting), job.ID, config.ID)
+	}
+	return ready, covered, waiting, nil
+}
+
+// coveredFiles groups files by their trigger file, for runs resumed from a checkpoint
+func coveredFiles(config *db.TransferConfig, files []map[string]interface{}) map[string][]string {
+	covered := make(map[string][]string)
+	for _, entry := range files {
+		filePath, _ := entry["Path"].(string)
+		if trigger, err := companionPath(config.TriggerMode, config.TriggerName, filePath); err == nil {
+			covered[trigger] = append(covered[trigger], filePath)
+		}
+	}
+	return covered
+}
+
+// consumeTriggers deletes or archives the trigger files whose files have all been
+// delivered. Triggers of files that failed or were skipped are kept for the next run.
+func (te *TransferExecutor) consumeTriggers(job db.Job, config *db.TransferConfig, configPath, rclonePath string, covered map[string][]string, completed map[string]bool) []string {
+	if config.TriggerAction == "" {
+		return nil
+	}
+
+	triggers := make([]string, 0, len(covered))
+	for trigger := range covered {
+		triggers = append(triggers, trigger)
+	}
+	sort.Strings(triggers)
+
+	var errors []string
+	for _, trigger := range triggers {
+		done := true
+		for _, filePath := range covered[trigger] {
+			done = done && completed[filePath]
+		}
+		if !done {
+			te.logger.LogInfo("Keeping trigger file %s for job %d, config %d: not all of its files were delivered", trigger, job.ID, config.ID)
+			continue
+		}
+
+		var err error
+		if config.TriggerAction == triggerActionArchive {
+			args := []string{"--config", configPath, "moveto", buildSourceRemotePath(config, trigger), buildArchiveRemotePath(config, trigger)}
+			output, cmdErr := execCommandContext(context.Background(), rclonePath, args...).CombinedOutput()
+			if cmdErr != nil {
+				err = fmt.Errorf("%v\nOutput: %s", cmdErr, string(output))
+			}
+		} else {
+			err = te.deleteSourceFile(configPath, rclonePath, buildSourceRemotePath(config, trigger))
+		}
+		if err != nil {
+			te.logger.LogError("Error consuming trigger file %s for job %d, config %d: %v", trigger, job.ID, config.ID, err)
+			errors = append(errors, fmt.Sprintf("Trigger error for file %s: %v", trigger, err))
+			continue
+		}
+		te.logger.LogInfo("Consumed trigger file %s (%s) for job %d, config %d", trigger, config.TriggerAction, job.ID, config.ID)
+	}
+	return errors
+}
+
+// deliveredNames collects the names of the files a run delivered to each destination
+type deliveredNames struct {
+	mutex sync.Mutex
+	names map[string][]string
+}
+
+// add records the names of files delivered, by destination label
+func (d *deliveredNames) add(names map[string][]string) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	if d.names == nil {
+		d.names = make(map[string][]string)
+	}
+	for label, destNames := range names {
+		d.names[label] = append(d.names[label], destNames...)
+	}
+}
+
+// writeMarkers writes the completion markers of the delivered files to each destination.
+// Per-folder markers are only written when the run completed without errors, as they
+// signal that a folder is complete.
+func (te *TransferExecutor) writeMarkers(job db.Job, config *db.TransferConfig, targets []deliveryTarget, configPath, rclonePath string, delivered *deliveredNames, runFailed bool) []string {
+	if config.MarkerMode == companionPerFolder && runFailed {
+		te.logger.LogInfo("Not writing completion markers for job %d, config %d: the run had errors", job.ID, config.ID)
+		return nil
+	}
+
+	var errors []string
+	for _, target := range targets {
+		seen := make(map[string]bool)
+		for _, name := range delivered.names[target.label] {
+			marker, err := companionPath(config.MarkerMode, config.MarkerName, name)
+			if err != nil {
+				errors = append(errors, fmt.Sprintf("Marker error for file %s: %v", name, err))
+				continue
+			}
+			if seen[marker] {
+				continue
+			}
+			seen[marker] = true
+
+			// Markers are empty files, written once the data is in place
+			args := []string{"--config", configPath, "touch", buildDestRemotePath(target.config, marker)}
+			te.logger.LogDebug("Full marker command: %s %v", rclonePath, args)
+			if output, err := execCommandContext(context.Background(), rclonePath, args...).CombinedOutput(); err != nil {
+				te.logger.LogError("Error writing completion marker %s to %s for job %d, config %d: %v", marker, target.label, job.ID, config.ID, err)
+				errors = append(errors, fmt.Sprintf("Marker error for %s at %s: %v\nOutput: %s", marker, target.label, err, string(output)))
+			}
+		}
+	}
+	return errors
+}
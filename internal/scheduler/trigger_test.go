@@ -0,0 +1,123 @@
+package scheduler
+
+import (
+	"testing"
+
+	"github.com/starfleetcptn/gomft/internal/db"
+)
+
+func TestCompanionPath(t *testing.T) {
+	tests := []struct {
+		mode     string
+		name     string
+		filePath string
+		want     string
+		wantErr  bool
+	}{
+		{companionPerFile, "", "in/a.csv", "in/a.csv.done", false},
+		{companionPerFile, "${filename}.ok", "in/a.csv", "in/a.ok", false},
+		{companionPerFile, "${filename}_${ext}.trg", "a.csv", "a_csv.trg", false},
+		{companionPerFolder, "", "in/a.csv", "in/.done", false},
+		{companionPerFolder, "READY", "a.csv", "READY", false},
+		{companionPerFile, "done/${filename}", "a.csv", "", true},
+		{companionPerFile, "${hash}.done", "a.csv", "", true},
+		{"batch", "", "a.csv", "", true},
+	}
+	for _, tt := range tests {
+		got, err := companionPath(tt.mode, tt.name, tt.filePath)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("companionPath(%q, %q, %q) = %q, %v, want %q", tt.mode, tt.name, tt.filePath, got, err, tt.want)
+		}
+	}
+}
+
+func TestValidateTriggers(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  db.TransferConfig
+		wantErr bool
+	}{
+		{"Disabled", db.TransferConfig{}, false},
+		{"Per-file trigger", db.TransferConfig{TriggerMode: "file", TriggerName: "${filename}.ok", TriggerAction: "delete"}, false},
+		{"Per-folder marker", db.TransferConfig{MarkerMode: "folder", MarkerName: "_SUCCESS"}, false},
+		{"Unknown mode", db.TransferConfig{TriggerMode: "batch"}, true},
+		{"Same name as the data file", db.TransferConfig{TriggerMode: "file", TriggerName: "${filename}.${ext}"}, true},
+		{"Per-folder variables", db.TransferConfig{MarkerMode: "folder", MarkerName: "${filename}.done"}, true},
+		{"Archive without path", db.TransferConfig{TriggerMode: "folder", TriggerAction: "archive"}, true},
+		{"Archive with path", db.TransferConfig{TriggerMode: "folder", TriggerAction: "archive", ArchivePath: "/archive"}, false},
+		{"Unknown action", db.TransferConfig{TriggerMode: "file", TriggerAction: "rename"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := ValidateTriggers(&tt.config); (err != nil) != tt.wantErr {
+				t.Errorf("ValidateTriggers() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestTriggerFiles(t *testing.T) {
+	sourceFiles := map[string]bool{"a.csv": true, "a.csv.done": true, "b.csv": true, "in/c.csv": true, "in/.done": true}
+
+	perFile := triggerFiles(&db.TransferConfig{TriggerMode: companionPerFile}, sourceFiles)
+	if len(perFile) != 1 || !perFile["a.csv.done"] {
+		t.Errorf("triggerFiles(file) = %v, want a.csv.done", perFile)
+	}
+
+	perFolder := triggerFiles(&db.TransferConfig{TriggerMode: companionPerFolder}, sourceFiles)
+	if len(perFolder) != 1 || !perFolder["in/.done"] {
+		t.Errorf("triggerFiles(folder) = %v, want in/.done", perFolder)
+	}
+}
+
+func TestExecuteConfigTransfer_Triggers(t *testing.T) {
+	comps := setupTestExecutor()
+	defer comps.logger.Close()
+	var calls [][]string
+	listing := `[{"Path":"a.csv","Size":10},{"Path":"a.csv.done","Size":0},{"Path":"b.csv","Size":10}]`
+	defer mockRcloneSequence([]string{listing, listing, "", "", ""}, &calls)()
+
+	config := db.TransferConfig{ID: 2, SourceType: "local", SourcePath: "/src", DestinationType: "local", DestinationPath: "/dst",
+		TriggerMode: "file", TriggerAction: "delete", MarkerMode: "file", MarkerName: "${filename}.ok"}
+	history := &db.JobHistory{ID: 7, JobID: 1, ConfigID: 2}
+	comps.executor.executeConfigTransfer(db.Job{ID: 1}, config, history)
+
+	if history.Status != "completed" || history.FilesTransferred != 1 {
+		t.Fatalf("executeConfigTransfer() status = %s, files = %d: %s", history.Status, history.FilesTransferred, history.ErrorMessage)
+	}
+	// Only the file with a trigger is transferred, then its trigger is deleted and the
+	// completion marker written
+	if len(calls) != 5 || !hasArg(calls[1], "--files-only") ||
+		calls[2][len(calls[2])-2] != "source_2:/src/a.csv" ||
+		!hasArg(calls[3], "deletefile") || calls[3][len(calls[3])-1] != "source_2:/src/a.csv.done" ||
+		!hasArg(calls[4], "touch") || calls[4][len(calls[4])-1] != "dest_2:/dst/a.ok" {
+		t.Errorf("executeConfigTransfer() rclone calls = %v", calls)
+	}
+}
+
+func TestWriteMarkers_FolderAfterErrors(t *testing.T) {
+	comps := setupTestExecutor()
+	defer comps.logger.Close()
+	var calls [][]string
+	defer mockRcloneSequence(nil, &calls)()
+
+	config := &db.TransferConfig{ID: 2, DestinationType: "local", DestinationPath: "/dst", MarkerMode: "folder"}
+	targets := []deliveryTarget{{label: mainDestinationLabel, config: config}}
+	var delivered deliveredNames
+	delivered.add(map[string][]string{mainDestinationLabel: {"in/a.csv", "in/b.csv", "c.csv"}})
+
+	// A folder is not marked complete when the run had errors
+	comps.executor.writeMarkers(db.Job{ID: 1}, config, targets, "", "rclone", &delivered, true)
+	if len(calls) != 0 {
+		t.Errorf("writeMarkers() after errors rclone calls = %v, want none", calls)
+	}
+
+	// Each folder gets a single marker
+	if errs := comps.executor.writeMarkers(db.Job{ID: 1}, config, targets, "", "rclone", &delivered, false); len(errs) != 0 {
+		t.Fatalf("writeMarkers() errors = %v", errs)
+	}
+	if len(calls) != 2 || calls[0][len(calls[0])-1] != "dest_2:/dst/in/.done" || calls[1][len(calls[1])-1] != "dest_2:/dst/.done" {
+		t.Errorf("writeMarkers() rclone calls = %v", calls)
+	}
+}
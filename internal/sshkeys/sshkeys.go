@@ -0,0 +1,115 @@
+// Package sshkeys generates and imports the SSH key pairs SFTP remotes authenticate with.
+package sshkeys
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// Key types that can be generated
+const (
+	TypeED25519 = "ed25519"
+	TypeRSA     = "rsa"
+)
+
+// DefaultRSABits is the size of generated RSA keys when none is given
+const DefaultRSABits = 4096
+
+// KeyPair is an SSH key pair ready to be stored in the key store
+type KeyPair struct {
+	Type        string // Key algorithm, such as ssh-ed25519
+	PublicKey   string // Public key in authorized_keys format
+	Fingerprint string // SHA256 fingerprint as printed by ssh-keygen -l
+	PrivateKey  string // Unencrypted private key in OpenSSH PEM format
+}
+
+// Generate creates a new ed25519 or RSA key pair. The comment is added to the public key
+// so partners can tell the keys apart.
+func Generate(keyType string, bits int, comment string) (*KeyPair, error) {
+	var private crypto.PrivateKey
+	switch keyType {
+	case TypeED25519:
+		_, key, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate ed25519 key: %v", err)
+		}
+		private = key
+	case TypeRSA:
+		if bits == 0 {
+			bits = DefaultRSABits
+		}
+		if bits != 2048 && bits != 3072 && bits != 4096 {
+			return nil, fmt.Errorf("unsupported RSA key size %d, use 2048, 3072 or 4096", bits)
+		}
+		key, err := rsa.GenerateKey(rand.Reader, bits)
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate RSA key: %v", err)
+		}
+		private = key
+	default:
+		return nil, fmt.Errorf("unsupported key type %q", keyType)
+	}
+	return newKeyPair(private, comment)
+}
+
+// Import parses a private key in OpenSSH, PKCS#1, PKCS#8 or EC format. Keys protected by a
+// passphrase are decrypted, the returned private key is not protected anymore.
+func Import(material, passphrase, comment string) (*KeyPair, error) {
+	material = strings.TrimSpace(material)
+	if material == "" {
+		return nil, errors.New("no private key given")
+	}
+
+	private, err := ssh.ParseRawPrivateKey([]byte(material))
+	var missing *ssh.PassphraseMissingError
+	if errors.As(err, &missing) {
+		if passphrase == "" {
+			return nil, errors.New("the private key is protected by a passphrase")
+		}
+		private, err = ssh.ParseRawPrivateKeyWithPassphrase([]byte(material), []byte(passphrase))
+		if errors.Is(err, x509.IncorrectPasswordError) {
+			return nil, errors.New("incorrect passphrase")
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid private key: %v", err)
+	}
+	return newKeyPair(private, comment)
+}
+
+func newKeyPair(private crypto.PrivateKey, comment string) (*KeyPair, error) {
+	signer, err := ssh.NewSignerFromKey(private)
+	if err != nil {
+		return nil, fmt.Errorf("unsupported private key: %v", err)
+	}
+	block, err := ssh.MarshalPrivateKey(private, comment)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode private key: %v", err)
+	}
+
+	public := signer.PublicKey()
+	return &KeyPair{
+		Type:        public.Type(),
+		PublicKey:   AuthorizedKey(public, comment),
+		Fingerprint: ssh.FingerprintSHA256(public),
+		PrivateKey:  string(pem.EncodeToMemory(block)),
+	}, nil
+}
+
+// AuthorizedKey returns the authorized_keys line of a public key followed by a comment
+func AuthorizedKey(public ssh.PublicKey, comment string) string {
+	line := strings.TrimSpace(string(ssh.MarshalAuthorizedKey(public)))
+	if comment = strings.Join(strings.Fields(comment), "-"); comment != "" {
+		line += " " + comment
+	}
+	return line
+}
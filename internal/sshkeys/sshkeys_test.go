@@ -0,0 +1,109 @@
+package sshkeys
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+)
+
+func TestGenerate(t *testing.T) {
+	tests := []struct {
+		keyType  string
+		bits     int
+		wantType string
+		wantErr  bool
+	}{
+		{TypeED25519, 0, ssh.KeyAlgoED25519, false},
+		{TypeRSA, 2048, ssh.KeyAlgoRSA, false},
+		{TypeRSA, 1024, "", true},
+		{"dsa", 0, "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.keyType, func(t *testing.T) {
+			pair, err := Generate(tt.keyType, tt.bits, "partner upload")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Generate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if pair.Type != tt.wantType {
+				t.Errorf("Generate() type = %q, want %q", pair.Type, tt.wantType)
+			}
+			if !strings.HasSuffix(pair.PublicKey, " partner-upload") {
+				t.Errorf("Generate() public key = %q, want the comment partner-upload", pair.PublicKey)
+			}
+
+			// The private key belongs to the public key and is usable without a passphrase
+			signer, err := ssh.ParsePrivateKey([]byte(pair.PrivateKey))
+			if err != nil {
+				t.Fatalf("ParsePrivateKey() error = %v", err)
+			}
+			if got := ssh.FingerprintSHA256(signer.PublicKey()); got != pair.Fingerprint {
+				t.Errorf("Generate() fingerprint = %q, private key has %q", pair.Fingerprint, got)
+			}
+		})
+	}
+}
+
+func TestImport(t *testing.T) {
+	_, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey() error = %v", err)
+	}
+	signer, err := ssh.NewSignerFromKey(private)
+	if err != nil {
+		t.Fatalf("NewSignerFromKey() error = %v", err)
+	}
+	fingerprint := ssh.FingerprintSHA256(signer.PublicKey())
+
+	plain, err := ssh.MarshalPrivateKey(private, "")
+	if err != nil {
+		t.Fatalf("MarshalPrivateKey() error = %v", err)
+	}
+	protected, err := ssh.MarshalPrivateKeyWithPassphrase(private, "", []byte("secret"))
+	if err != nil {
+		t.Fatalf("MarshalPrivateKeyWithPassphrase() error = %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		material   string
+		passphrase string
+		wantErr    string
+	}{
+		{"unprotected", string(pem.EncodeToMemory(plain)), "", ""},
+		{"protected", string(pem.EncodeToMemory(protected)), "secret", ""},
+		{"missing passphrase", string(pem.EncodeToMemory(protected)), "", "protected by a passphrase"},
+		{"wrong passphrase", string(pem.EncodeToMemory(protected)), "wrong", "incorrect passphrase"},
+		{"public key", strings.TrimSpace(string(ssh.MarshalAuthorizedKey(signer.PublicKey()))), "", "invalid private key"},
+		{"empty", "  ", "", "no private key"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pair, err := Import(tt.material, tt.passphrase, "imported")
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Import() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Import() error = %v", err)
+			}
+			if pair.Fingerprint != fingerprint {
+				t.Errorf("Import() fingerprint = %q, want %q", pair.Fingerprint, fingerprint)
+			}
+			// Imported keys are stored without their passphrase
+			if _, err := ssh.ParsePrivateKey([]byte(pair.PrivateKey)); err != nil {
+				t.Errorf("ParsePrivateKey() of the imported key error = %v", err)
+			}
+		})
+	}
+}
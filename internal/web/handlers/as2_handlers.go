@@ -0,0 +1,613 @@
+package handlers
+
+// AS2 handlers: trading partner profiles and the endpoint partners post messages and
+// asynchronous MDNs to
+import (
+	"context"
+	"crypto/rsa"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/starfleetcptn/gomft/components"
+	"github.com/starfleetcptn/gomft/internal/as2"
+	"github.com/starfleetcptn/gomft/internal/db"
+)
+
+// recentAS2Messages is the number of messages listed on the AS2 partners page
+const recentAS2Messages = 50
+
+// as2MDNTimeout bounds posting an asynchronous MDN to the sender
+const as2MDNTimeout = 5 * time.Minute
+
+// as2Receiving holds the messages being processed, so a message posted twice at once is
+// only delivered once
+var as2Receiving = struct {
+	sync.Mutex
+	messages map[string]bool
+}{messages: make(map[string]bool)}
+
+// as2MDNClient posts asynchronous MDNs. Redirects are not followed, they could lead away
+// from the partner's host the MDN URL was checked against.
+var as2MDNClient = &http.Client{
+	CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	},
+}
+
+// HandleAS2Partners renders the AS2 partners page with the recent messages
+func (h *Handlers) HandleAS2Partners(c *gin.Context) {
+	partners, err := h.DB.GetAS2Partners()
+	if err != nil {
+		h.HandleServerError(c, err)
+		return
+	}
+	messages, err := h.DB.GetRecentAS2Messages(recentAS2Messages)
+	if err != nil {
+		log.Printf("Warning: Failed to load AS2 messages: %v", err)
+	}
+
+	components.AS2Partners(c.Request.Context(), partners, messages).Render(c, c.Writer)
+}
+
+// HandleNewAS2Partner renders the form to add an AS2 partner
+func (h *Handlers) HandleNewAS2Partner(c *gin.Context) {
+	partner := &db.AS2Partner{
+		SigningAlgorithm:    as2.SigningSHA256,
+		EncryptionAlgorithm: as2.EncryptionAES256,
+		MDNMode:             as2.MDNSync,
+		SignedMDN:           true,
+		Sign:                true,
+		Encrypt:             true,
+		RequireSigned:       true,
+	}
+	components.AS2PartnerForm(c.Request.Context(), h.as2PartnerFormData(c, partner, true)).Render(c, c.Writer)
+}
+
+// HandleEditAS2Partner renders the form to edit an AS2 partner
+func (h *Handlers) HandleEditAS2Partner(c *gin.Context) {
+	partner, ok := h.loadAS2Partner(c)
+	if !ok {
+		return
+	}
+	components.AS2PartnerForm(c.Request.Context(), h.as2PartnerFormData(c, partner, false)).Render(c, c.Writer)
+}
+
+// HandleCreateAS2Partner adds an AS2 partner
+func (h *Handlers) HandleCreateAS2Partner(c *gin.Context) {
+	userID := c.GetUint("userID")
+	if userID == 0 {
+		h.HandleUnauthorized(c)
+		return
+	}
+
+	partner := &db.AS2Partner{Name: strings.TrimSpace(c.PostForm("name")), CreatedBy: userID}
+	if partner.Name == "" {
+		h.HandleBadRequest(c, "Invalid AS2 Partner", "A name is required")
+		return
+	}
+	if _, err := h.DB.GetAS2PartnerByName(partner.Name); err == nil {
+		h.HandleBadRequest(c, "Invalid AS2 Partner", fmt.Sprintf("An AS2 partner named '%s' already exists", partner.Name))
+		return
+	}
+	if err := h.bindAS2Partner(c, partner); err != nil {
+		h.HandleBadRequest(c, "Invalid AS2 Partner", err.Error())
+		return
+	}
+	if err := h.DB.CreateAS2Partner(partner); err != nil {
+		h.HandleServerError(c, err)
+		return
+	}
+
+	h.auditAS2Partner("create", partner, userID)
+	c.SetCookie("flash_message", fmt.Sprintf("AS2 partner '%s' added", partner.Name), 3600, "/", "", false, true)
+	c.SetCookie("flash_type", "success", 3600, "/", "", false, true)
+	c.Redirect(http.StatusFound, "/admin/settings/as2")
+}
+
+// HandleUpdateAS2Partner saves the changes to an AS2 partner. Names cannot change, as
+// configurations reference partners by name.
+func (h *Handlers) HandleUpdateAS2Partner(c *gin.Context) {
+	userID := c.GetUint("userID")
+	if userID == 0 {
+		h.HandleUnauthorized(c)
+		return
+	}
+
+	partner, ok := h.loadAS2Partner(c)
+	if !ok {
+		return
+	}
+	if err := h.bindAS2Partner(c, partner); err != nil {
+		h.HandleBadRequest(c, "Invalid AS2 Partner", err.Error())
+		return
+	}
+	if err := h.DB.UpdateAS2Partner(partner); err != nil {
+		h.HandleServerError(c, err)
+		return
+	}
+
+	h.auditAS2Partner("update", partner, userID)
+	c.SetCookie("flash_message", fmt.Sprintf("AS2 partner '%s' updated", partner.Name), 3600, "/", "", false, true)
+	c.SetCookie("flash_type", "success", 3600, "/", "", false, true)
+	c.Redirect(http.StatusFound, "/admin/settings/as2")
+}
+
+// HandleDeleteAS2Partner removes an AS2 partner that no configuration sends to
+func (h *Handlers) HandleDeleteAS2Partner(c *gin.Context) {
+	userID := c.GetUint("userID")
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	partnerID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid partner ID"})
+		return
+	}
+	partner, err := h.DB.GetAS2Partner(uint(partnerID))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "AS2 partner not found"})
+		return
+	}
+
+	count, err := h.DB.CountConfigsUsingAS2Partner(partner.Name)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check if the partner is in use"})
+		return
+	}
+	if count > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": fmt.Sprintf("Cannot delete AS2 partner '%s' because it is used by %d transfer configurations", partner.Name, count),
+		})
+		return
+	}
+
+	if err := h.DB.DeleteAS2Partner(partner.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete AS2 partner"})
+		return
+	}
+
+	h.auditAS2Partner("delete", partner, userID)
+	c.JSON(http.StatusOK, gin.H{"message": fmt.Sprintf("AS2 partner '%s' deleted", partner.Name)})
+}
+
+// HandleDownloadAS2Certificate sends the local certificate of a partnership, for the
+// partner to encrypt messages and verify signatures with
+func (h *Handlers) HandleDownloadAS2Certificate(c *gin.Context) {
+	partner, ok := h.loadAS2Partner(c)
+	if !ok {
+		return
+	}
+	if partner.LocalCertificate == "" {
+		h.HandleNotFound(c, "Certificate Not Found", "The AS2 partner has no local certificate")
+		return
+	}
+
+	fileName := unsafeFileNameChars.ReplaceAllString(partner.LocalAS2ID, "_") + ".pem"
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", fileName))
+	c.String(http.StatusOK, partner.LocalCertificate)
+}
+
+// loadAS2Partner returns the partner of the id route parameter, or renders an error
+func (h *Handlers) loadAS2Partner(c *gin.Context) (*db.AS2Partner, bool) {
+	partnerID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		h.HandleBadRequest(c, "Invalid AS2 Partner", "Invalid partner ID")
+		return nil, false
+	}
+	partner, err := h.DB.GetAS2Partner(uint(partnerID))
+	if err != nil {
+		h.HandleNotFound(c, "AS2 Partner Not Found", "The requested AS2 partner does not exist")
+		return nil, false
+	}
+	return partner, true
+}
+
+// as2PartnerFormData returns the data of the AS2 partner form
+func (h *Handlers) as2PartnerFormData(c *gin.Context, partner *db.AS2Partner, isNew bool) components.AS2PartnerFormData {
+	var configs []db.TransferConfig
+	if err := h.DB.Order("name asc").Find(&configs).Error; err != nil {
+		log.Printf("Warning: Failed to load configurations: %v", err)
+	}
+	data := components.AS2PartnerFormData{
+		Partner:     partner,
+		IsNew:       isNew,
+		Configs:     configs,
+		EndpointURL: requestBaseURL(c) + "/as2",
+	}
+	if partner.LocalCertificate != "" {
+		if cert, err := as2.ParseCertificate([]byte(partner.LocalCertificate)); err == nil {
+			data.LocalFingerprint = as2.Fingerprint(cert)
+			data.LocalExpires = cert.NotAfter
+		}
+	}
+	return data
+}
+
+// bindAS2Partner reads the AS2 partner form into a partner and validates the profile
+func (h *Handlers) bindAS2Partner(c *gin.Context, partner *db.AS2Partner) error {
+	checked := func(name string) bool {
+		value := c.PostForm(name)
+		return value == "on" || value == "true"
+	}
+	partner.AS2ID = strings.TrimSpace(c.PostForm("as2_id"))
+	partner.LocalAS2ID = strings.TrimSpace(c.PostForm("local_as2_id"))
+	partner.URL = strings.TrimSpace(c.PostForm("url"))
+	partner.Certificate = strings.TrimSpace(c.PostForm("certificate"))
+	partner.ContentType = strings.TrimSpace(c.PostForm("content_type"))
+	partner.Sign = checked("sign")
+	partner.SigningAlgorithm = c.PostForm("signing_algorithm")
+	partner.Encrypt = checked("encrypt")
+	partner.EncryptionAlgorithm = c.PostForm("encryption_algorithm")
+	partner.Compress = checked("compress")
+	partner.MDNMode = c.PostForm("mdn_mode")
+	partner.SignedMDN = checked("signed_mdn")
+	partner.AsyncMDNURL = strings.TrimSpace(c.PostForm("async_mdn_url"))
+	partner.RequireSigned = checked("require_signed")
+	partner.RequireEncrypted = checked("require_encrypted")
+	inboundConfigID, _ := strconv.ParseUint(c.PostForm("inbound_config_id"), 10, 64)
+	partner.InboundConfigID = uint(inboundConfigID)
+
+	if partner.MDNMode != as2.MDNNone && partner.MDNMode != as2.MDNSync && partner.MDNMode != as2.MDNAsync {
+		return fmt.Errorf("unsupported MDN mode %q", partner.MDNMode)
+	}
+	if partner.MDNMode == as2.MDNAsync && partner.AsyncMDNURL == "" {
+		partner.AsyncMDNURL = requestBaseURL(c) + "/as2"
+	}
+	if partner.Certificate != "" {
+		cert, err := as2.ParseCertificate([]byte(partner.Certificate))
+		if err != nil {
+			return fmt.Errorf("partner certificate: %v", err)
+		}
+		if time.Now().After(cert.NotAfter) {
+			return fmt.Errorf("the partner certificate expired on %s", cert.NotAfter.Format("2006-01-02"))
+		}
+	}
+
+	switch c.PostForm("local_identity") {
+	case "generate":
+		if partner.LocalAS2ID == "" {
+			return fmt.Errorf("the local AS2 ID is required to generate a certificate")
+		}
+		certPEM, keyPEM, err := as2.GenerateCertificate(partner.LocalAS2ID, 2048, 3)
+		if err != nil {
+			return err
+		}
+		if err := partner.SetLocalIdentity(certPEM, keyPEM); err != nil {
+			return err
+		}
+	case "import":
+		certPEM := strings.TrimSpace(c.PostForm("local_certificate"))
+		keyPEM := strings.TrimSpace(c.PostForm("local_private_key"))
+		cert, err := as2.ParseCertificate([]byte(certPEM))
+		if err != nil {
+			return fmt.Errorf("local certificate: %v", err)
+		}
+		key, err := as2.ParsePrivateKey([]byte(keyPEM))
+		if err != nil {
+			return fmt.Errorf("local private key: %v", err)
+		}
+		if publicKey, ok := cert.PublicKey.(*rsa.PublicKey); !ok || !publicKey.Equal(&key.PublicKey) {
+			return fmt.Errorf("the local private key does not belong to the local certificate")
+		}
+		if err := partner.SetLocalIdentity(certPEM, keyPEM); err != nil {
+			return err
+		}
+	case "remove":
+		partner.LocalCertificate, partner.LocalPrivateKey = "", ""
+	}
+
+	if partner.InboundConfigID != 0 {
+		if partner.Certificate == "" {
+			return fmt.Errorf("the partner certificate is required to deliver inbound payloads, as messages cannot be authenticated without it")
+		}
+		config, err := h.DB.GetTransferConfig(partner.InboundConfigID)
+		if err != nil {
+			return fmt.Errorf("the inbound configuration does not exist")
+		}
+		if config.IsAS2Destination() {
+			return fmt.Errorf("inbound payloads cannot be delivered to configuration '%s', which sends to an AS2 partner", config.Name)
+		}
+	}
+	if existing, err := h.DB.FindAS2Partnership(partner.AS2ID, partner.LocalAS2ID); err == nil && existing.ID != partner.ID {
+		return fmt.Errorf("AS2 partner '%s' already uses the AS2 IDs %s and %s", existing.Name, partner.AS2ID, partner.LocalAS2ID)
+	}
+
+	local, profile, err := partner.Profile()
+	if err != nil {
+		return err
+	}
+	return profile.Validate(local)
+}
+
+// auditAS2Partner records a change to an AS2 partner in the audit log
+func (h *Handlers) auditAS2Partner(action string, partner *db.AS2Partner, userID uint) {
+	auditLog := db.AuditLog{
+		Action:     action,
+		EntityType: "as2_partner",
+		EntityID:   partner.ID,
+		UserID:     userID,
+		Details: map[string]interface{}{
+			"name":         partner.Name,
+			"as2_id":       partner.AS2ID,
+			"local_as2_id": partner.LocalAS2ID,
+			"url":          partner.URL,
+		},
+		Timestamp: time.Now(),
+	}
+	if err := h.DB.Create(&auditLog).Error; err != nil {
+		log.Printf("Error creating audit log: %v", err)
+	}
+}
+
+// HandleAS2Receive is the AS2 endpoint. Partners post messages, whose payloads are
+// delivered to the inbound configuration of the partnership and confirmed with an MDN,
+// and the asynchronous MDNs of the messages GoMFT sent.
+func (h *Handlers) HandleAS2Receive(c *gin.Context) {
+	maxSize := as2MaxMessageSize()
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, maxSize+1))
+	if err != nil {
+		c.String(http.StatusBadRequest, "Failed to read the AS2 message")
+		return
+	}
+	if int64(len(body)) > maxSize {
+		c.String(http.StatusRequestEntityTooLarge, "AS2 message too large")
+		return
+	}
+	if as2.IsMDN(c.Request.Header, body) {
+		h.receiveAS2MDN(c, body)
+		return
+	}
+
+	req, err := as2.ParseRequest(c.Request.Header)
+	if err != nil {
+		c.String(http.StatusBadRequest, err.Error())
+		return
+	}
+	// Without a partnership there is no certificate to sign an MDN with
+	partner, err := h.DB.FindAS2Partnership(req.From, req.To)
+	if err != nil {
+		log.Printf("AS2: rejected message %s from unknown partnership %s to %s", req.MessageID, req.From, req.To)
+		c.String(http.StatusForbidden, fmt.Sprintf("Unknown AS2 partnership from %s to %s", req.From, req.To))
+		return
+	}
+
+	// A message is only delivered once, a replayed or resent message is rejected
+	release, ok := claimAS2Message(partner.ID, req.MessageID)
+	if !ok {
+		c.String(http.StatusConflict, fmt.Sprintf("Message %s is already being processed", req.MessageID))
+		return
+	}
+	defer release()
+	received, err := h.DB.HasReceivedAS2Message(partner.ID, req.MessageID)
+	if err != nil {
+		c.String(http.StatusInternalServerError, "Failed to check the AS2 message")
+		return
+	}
+	if received {
+		log.Printf("AS2: rejected message %s from %s, which was already received", req.MessageID, partner.Name)
+		c.String(http.StatusConflict, fmt.Sprintf("Message %s was already received", req.MessageID))
+		return
+	}
+
+	record := &db.AS2Message{
+		MessageID:   req.MessageID,
+		Direction:   db.AS2Inbound,
+		PartnerID:   partner.ID,
+		PartnerName: partner.Name,
+		ConfigID:    partner.InboundConfigID,
+		FileSize:    int64(len(body)),
+		MDNMode:     req.MDN,
+		MDNStatus:   db.MDNStatusNone,
+	}
+	local, profile, failure := partner.Profile()
+	if failure != nil {
+		failure = as2.Failure(as2.DispositionUnexpectedProcessing, failure)
+	}
+	if req.MDN == as2.MDNAsync && failure == nil {
+		if err := profile.CheckMDNURL(req.AsyncURL); err != nil {
+			log.Printf("AS2: rejected message %s from %s: %v", req.MessageID, partner.Name, err)
+			record.MDNStatus = db.MDNStatusFailed
+			record.ErrorMessage = err.Error()
+			h.saveAS2Message(record)
+			c.String(http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+	var payload *as2.Payload
+	if failure == nil {
+		payload, failure = req.Open(body, local, profile)
+	}
+	if failure == nil {
+		record.FileName = payload.FileName
+		record.FileSize = int64(len(payload.Data))
+		record.MIC, record.MICAlgorithm = payload.MIC, payload.MICAlgorithm
+		record.Signed, record.Encrypted, record.Compressed = payload.Signed, payload.Encrypted, payload.Compressed
+		record.DestinationPath, failure = h.deliverAS2Payload(partner, payload)
+	}
+	if failure != nil {
+		log.Printf("AS2: failed to process message %s from %s: %v", req.MessageID, partner.Name, failure)
+		record.ErrorMessage = failure.Error()
+	} else {
+		log.Printf("AS2: received %s from %s as message %s", payload.FileName, partner.Name, req.MessageID)
+	}
+
+	if req.MDN == as2.MDNNone {
+		h.saveAS2Message(record)
+		if failure != nil {
+			c.String(http.StatusInternalServerError, failure.Error())
+			return
+		}
+		c.Status(http.StatusOK)
+		return
+	}
+
+	// The sender of a message whose signature failed is unknown, its MDN is not posted on
+	// behalf of whoever sent it
+	if req.MDN == as2.MDNAsync && as2.IsSignatureFailure(failure) {
+		record.MDNStatus = db.MDNStatusFailed
+		h.saveAS2Message(record)
+		c.String(http.StatusForbidden, failure.Error())
+		return
+	}
+
+	mdn, err := as2.BuildMDN(local, req, payload, failure)
+	if err != nil {
+		record.MDNStatus = db.MDNStatusFailed
+		record.ErrorMessage = fmt.Sprintf("failed to build the MDN: %v", err)
+		h.saveAS2Message(record)
+		c.String(http.StatusInternalServerError, "Failed to build the MDN")
+		return
+	}
+	record.MDNStatus = db.MDNStatusSent
+	record.MDNDisposition = mdn.Disposition
+	now := time.Now()
+	record.MDNReceivedAt = &now
+	h.saveAS2Message(record)
+
+	if req.MDN == as2.MDNSync {
+		for name, values := range mdn.Header {
+			c.Writer.Header()[name] = values
+		}
+		c.Data(http.StatusOK, mdn.Header.Get("Content-Type"), mdn.Body)
+		return
+	}
+
+	// Asynchronous MDNs are posted once the request is answered
+	c.Status(http.StatusOK)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), as2MDNTimeout)
+		defer cancel()
+		if err := as2.SendMDN(ctx, as2MDNClient, req.AsyncURL, mdn); err != nil {
+			log.Printf("AS2: failed to send the MDN of message %s to %s: %v", req.MessageID, req.AsyncURL, err)
+			record.MDNStatus = db.MDNStatusFailed
+			record.ErrorMessage = strings.TrimSpace(record.ErrorMessage + "\n" + err.Error())
+			h.saveAS2Message(record)
+		}
+	}()
+}
+
+// as2MaxMessageSize returns the size of the largest inbound message accepted, in bytes. The
+// messages are read into memory, AS2_MAX_MESSAGE_SIZE sets the limit in megabytes.
+func as2MaxMessageSize() int64 {
+	if envSize := os.Getenv("AS2_MAX_MESSAGE_SIZE"); envSize != "" {
+		if size, err := strconv.ParseInt(envSize, 10, 64); err == nil && size > 0 {
+			return size << 20
+		}
+	}
+	return as2.DefaultMaxMessageSize
+}
+
+// claimAS2Message marks a message of a partner as being processed. It returns false when
+// it already is, otherwise a function to call once it is done.
+func claimAS2Message(partnerID uint, messageID string) (func(), bool) {
+	key := fmt.Sprintf("%d:%s", partnerID, messageID)
+	as2Receiving.Lock()
+	defer as2Receiving.Unlock()
+	if as2Receiving.messages[key] {
+		return nil, false
+	}
+	as2Receiving.messages[key] = true
+	return func() {
+		as2Receiving.Lock()
+		defer as2Receiving.Unlock()
+		delete(as2Receiving.messages, key)
+	}, true
+}
+
+// HandleAS2Probe answers GET requests to the AS2 endpoint, which partners and connection
+// tests use to check it is reachable
+func (h *Handlers) HandleAS2Probe(c *gin.Context) {
+	c.Header("Allow", http.MethodPost)
+	c.String(http.StatusMethodNotAllowed, "AS2 messages are posted to this endpoint")
+}
+
+// deliverAS2Payload writes an inbound payload to a temporary file and delivers it to the
+// destination of the inbound configuration of the partner
+func (h *Handlers) deliverAS2Payload(partner *db.AS2Partner, payload *as2.Payload) (string, error) {
+	if partner.InboundConfigID == 0 {
+		return "", as2.Failure(as2.DispositionUnexpectedProcessing, fmt.Errorf("no inbound configuration receives the payloads of %s", partner.Name))
+	}
+	if partner.Certificate == "" {
+		return "", as2.Failure(as2.DispositionInsufficientSecurity, fmt.Errorf("%s has no certificate to authenticate its messages with", partner.Name))
+	}
+	file, err := os.CreateTemp("", "gomft-as2-*")
+	if err != nil {
+		return "", as2.Failure(as2.DispositionUnexpectedProcessing, err)
+	}
+	defer os.Remove(file.Name())
+	_, err = file.Write(payload.Data)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return "", as2.Failure(as2.DispositionUnexpectedProcessing, err)
+	}
+
+	destination, err := h.Scheduler.DeliverAS2Payload(partner.InboundConfigID, payload.FileName, file.Name())
+	if err != nil {
+		return "", as2.Failure(as2.DispositionUnexpectedProcessing, err)
+	}
+	return destination, nil
+}
+
+// receiveAS2MDN matches an asynchronous MDN to the message it confirms and records it
+func (h *Handlers) receiveAS2MDN(c *gin.Context, body []byte) {
+	from, to := as2.Parties(c.Request.Header)
+	partner, err := h.DB.FindAS2Partnership(from, to)
+	if err != nil {
+		c.String(http.StatusForbidden, fmt.Sprintf("Unknown AS2 partnership from %s to %s", from, to))
+		return
+	}
+	_, profile, err := partner.Profile()
+	if err != nil {
+		c.String(http.StatusInternalServerError, "Invalid AS2 partner profile")
+		return
+	}
+	// Anyone can post to the endpoint, an MDN is only trusted unsigned when the partner has
+	// no certificate to sign it with
+	mdn, err := as2.ParseMDN(c.Request.Header, body, profile.Certificate, profile.SignedMDN || profile.Certificate != nil)
+	if err != nil {
+		log.Printf("AS2: rejected MDN from %s: %v", partner.Name, err)
+		c.String(http.StatusBadRequest, err.Error())
+		return
+	}
+	message, err := h.DB.GetOutboundAS2Message(mdn.OriginalMessageID)
+	if err != nil || message.PartnerID != partner.ID {
+		c.String(http.StatusNotFound, fmt.Sprintf("No message %s was sent to %s", mdn.OriginalMessageID, partner.Name))
+		return
+	}
+
+	status, errorMessage := db.MDNStatusProcessed, ""
+	if err := as2.CheckMDN(mdn, message.MessageID, message.MIC, message.MICAlgorithm); err != nil {
+		status, errorMessage = db.MDNStatusFailed, err.Error()
+		log.Printf("AS2: MDN of message %s reports a failure: %v", message.MessageID, err)
+	}
+	if err := h.DB.RecordAS2MDN(message, status, mdn.Disposition, errorMessage); err != nil {
+		c.String(http.StatusInternalServerError, "Failed to record the MDN")
+		return
+	}
+	c.Status(http.StatusOK)
+}
+
+// saveAS2Message creates or updates the record of an inbound message
+func (h *Handlers) saveAS2Message(record *db.AS2Message) {
+	var err error
+	if record.ID == 0 {
+		err = h.DB.CreateAS2Message(record)
+	} else {
+		err = h.DB.UpdateAS2Message(record)
+	}
+	if err != nil {
+		log.Printf("Error recording AS2 message %s: %v", record.MessageID, err)
+	}
+}
@@ -12,8 +12,13 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/starfleetcptn/gomft/components"
 
+	"github.com/starfleetcptn/gomft/internal/auth"
 	"github.com/starfleetcptn/gomft/internal/db"
+	"github.com/starfleetcptn/gomft/internal/filters"
+	"github.com/starfleetcptn/gomft/internal/ftps"
+	"github.com/starfleetcptn/gomft/internal/httpsource"
 	"github.com/starfleetcptn/gomft/internal/rclone_service" // Assuming we create this package
+	"github.com/starfleetcptn/gomft/internal/scheduler"
 )
 
 // HandleConfigs handles the GET /configs route
@@ -39,9 +44,27 @@ func (h *Handlers) HandleConfigs(c *gin.Context) {
 
 // HandleNewConfig handles the GET /configs/new route
 func (h *Handlers) HandleNewConfig(c *gin.Context) {
+	encryptionKeys, err := h.DB.GetEncryptionKeys()
+	if err != nil {
+		log.Printf("Warning: Failed to load encryption keys: %v", err)
+	}
+
+	sshKeys, err := h.DB.GetSSHKeys()
+	if err != nil {
+		log.Printf("Warning: Failed to load SSH keys: %v", err)
+	}
+
+	as2Partners, err := h.DB.GetAS2Partners()
+	if err != nil {
+		log.Printf("Warning: Failed to load AS2 partners: %v", err)
+	}
+
 	data := components.ConfigFormData{
-		Config: &db.TransferConfig{},
-		IsNew:  true,
+		Config:         &db.TransferConfig{},
+		IsNew:          true,
+		EncryptionKeys: encryptionKeys,
+		SSHKeys:        sshKeys,
+		AS2Partners:    as2Partners,
 	}
 	components.ConfigForm(c.Request.Context(), data).Render(c, c.Writer)
 }
@@ -98,12 +121,34 @@ func (h *Handlers) HandleEditConfig(c *gin.Context) {
 		}
 	}
 
+	encryptionKeys, err := h.DB.GetEncryptionKeys()
+	if err != nil {
+		log.Printf("Warning: Failed to load encryption keys: %v", err)
+	}
+
+	sshKeys, err := h.DB.GetSSHKeys()
+	if err != nil {
+		log.Printf("Warning: Failed to load SSH keys: %v", err)
+	}
+
+	as2Partners, err := h.DB.GetAS2Partners()
+	if err != nil {
+		log.Printf("Warning: Failed to load AS2 partners: %v", err)
+	}
+
+	if err := h.DB.LoadHostKeys(&config); err != nil {
+		log.Printf("Warning: Failed to load pinned host keys for config %d: %v", config.ID, err)
+	}
+
 	data := components.ConfigFormData{
 		Config:             &config,
 		IsNew:              false,
 		InitialCommand:     initialCommand,
 		SelectedFlagsMap:   selectedFlagsMap,
 		SelectedFlagValues: selectedFlagValues,
+		EncryptionKeys:     encryptionKeys,
+		SSHKeys:            sshKeys,
+		AS2Partners:        as2Partners,
 	}
 	components.ConfigForm(c.Request.Context(), data).Render(c, c.Writer)
 }
@@ -121,6 +166,31 @@ func (h *Handlers) HandleCreateConfig(c *gin.Context) {
 	userID := c.GetUint("userID")
 	config.CreatedBy = userID
 
+	if err := validateConfigFilters(&config); err != nil {
+		c.String(http.StatusBadRequest, fmt.Sprintf("Invalid filters: %v", err))
+		return
+	}
+
+	if err := scheduler.ValidateOutputPattern(&config); err != nil {
+		c.String(http.StatusBadRequest, fmt.Sprintf("Invalid output pattern: %v", err))
+		return
+	}
+
+	if err := scheduler.ValidateBisync(&config); err != nil {
+		c.String(http.StatusBadRequest, fmt.Sprintf("Invalid bisync options: %v", err))
+		return
+	}
+
+	if err := scheduler.ValidateDestinations(&config); err != nil {
+		c.String(http.StatusBadRequest, fmt.Sprintf("Invalid destinations: %v", err))
+		return
+	}
+
+	if err := h.DB.CheckSSHKeys(&config); err != nil {
+		c.String(http.StatusBadRequest, fmt.Sprintf("Invalid SSH key: %v", err))
+		return
+	}
+
 	// Process Boolean fields
 	skipProcessedVal := c.Request.FormValue("skip_processed_files")
 	skipProcessedValue := skipProcessedVal == "on" || skipProcessedVal == "true"
@@ -142,6 +212,105 @@ func (h *Handlers) HandleCreateConfig(c *gin.Context) {
 	destPassiveModeValue := destPassiveModeVal == "on" || destPassiveModeVal == "true"
 	config.DestPassiveMode = &destPassiveModeValue
 
+	parseFTPSCheckboxes(c, &config, "source")
+	parseFTPSCheckboxes(c, &config, "dest")
+
+	decompressOnReceiveVal := c.Request.FormValue("decompress_on_receive")
+	decompressOnReceiveValue := decompressOnReceiveVal == "on" || decompressOnReceiveVal == "true"
+	config.DecompressOnReceive = &decompressOnReceiveValue
+
+	requireSignatureVal := c.Request.FormValue("require_signature")
+	requireSignatureValue := requireSignatureVal == "on" || requireSignatureVal == "true"
+	config.RequireSignature = &requireSignatureValue
+
+	sourceCryptVal := c.Request.FormValue("source_crypt")
+	sourceCryptValue := sourceCryptVal == "on" || sourceCryptVal == "true"
+	config.SourceCrypt = &sourceCryptValue
+
+	destCryptVal := c.Request.FormValue("dest_crypt")
+	destCryptValue := destCryptVal == "on" || destCryptVal == "true"
+	config.DestCrypt = &destCryptValue
+
+	stabilityCheckVal := c.Request.FormValue("stability_check")
+	stabilityCheckValue := stabilityCheckVal == "on" || stabilityCheckVal == "true"
+	config.StabilityCheck = &stabilityCheckValue
+
+	atomicDeliveryVal := c.Request.FormValue("atomic_delivery")
+	atomicDeliveryValue := atomicDeliveryVal == "on" || atomicDeliveryVal == "true"
+	config.AtomicDelivery = &atomicDeliveryValue
+
+	atomicVerifyVal := c.Request.FormValue("atomic_verify")
+	atomicVerifyValue := atomicVerifyVal == "on" || atomicVerifyVal == "true"
+	config.AtomicVerify = &atomicVerifyValue
+
+	retentionPreviewVal := c.Request.FormValue("retention_preview")
+	retentionPreviewValue := retentionPreviewVal == "on" || retentionPreviewVal == "true"
+	config.RetentionPreview = &retentionPreviewValue
+
+	manifestSignVal := c.Request.FormValue("manifest_sign")
+	manifestSignValue := manifestSignVal == "on" || manifestSignVal == "true"
+	config.ManifestSign = &manifestSignValue
+
+	if err := encryptManifestKey(&config, ""); err != nil {
+		c.String(http.StatusInternalServerError, fmt.Sprintf("Failed to store manifest signing key: %v", err))
+		return
+	}
+
+	if err := encryptServiceAccounts(&config, "", ""); err != nil {
+		c.String(http.StatusBadRequest, fmt.Sprintf("Invalid service account key: %v", err))
+		return
+	}
+
+	if err := encryptFTPSClientCerts(c, &config, "", ""); err != nil {
+		c.String(http.StatusBadRequest, fmt.Sprintf("Invalid FTPS options: %v", err))
+		return
+	}
+
+	if err := encryptHTTPSecret(&config, "", ""); err != nil {
+		c.String(http.StatusBadRequest, fmt.Sprintf("Invalid HTTP source: %v", err))
+		return
+	}
+
+	if err := scheduler.ValidateManifest(&config); err != nil {
+		c.String(http.StatusBadRequest, fmt.Sprintf("Invalid manifest options: %v", err))
+		return
+	}
+
+	if err := scheduler.ValidateTriggers(&config); err != nil {
+		c.String(http.StatusBadRequest, fmt.Sprintf("Invalid trigger options: %v", err))
+		return
+	}
+
+	if err := scheduler.ValidateQuarantine(&config); err != nil {
+		c.String(http.StatusBadRequest, fmt.Sprintf("Invalid quarantine options: %v", err))
+		return
+	}
+
+	if err := scheduler.ValidateDedupe(&config); err != nil {
+		c.String(http.StatusBadRequest, fmt.Sprintf("Invalid deduplication options: %v", err))
+		return
+	}
+
+	if err := scheduler.ValidateChangeDetection(&config); err != nil {
+		c.String(http.StatusBadRequest, fmt.Sprintf("Invalid change detection options: %v", err))
+		return
+	}
+
+	if err := scheduler.ValidateHTTPSource(&config); err != nil {
+		c.String(http.StatusBadRequest, fmt.Sprintf("Invalid HTTP source: %v", err))
+		return
+	}
+
+	if err := scheduler.ValidateAS2Destination(&config); err != nil {
+		c.String(http.StatusBadRequest, fmt.Sprintf("Invalid AS2 destination: %v", err))
+		return
+	}
+
+	if err := h.DB.CheckAS2Partner(&config); err != nil {
+		c.String(http.StatusBadRequest, fmt.Sprintf("Invalid AS2 destination: %v", err))
+		return
+	}
+
 	// Google Photos specific fields
 	destReadOnlyVal := c.Request.FormValue("dest_read_only")
 	destReadOnlyValue := destReadOnlyVal == "on" || destReadOnlyVal == "true"
@@ -173,6 +342,21 @@ func (h *Handlers) HandleCreateConfig(c *gin.Context) {
 		config.CommandID = 1
 	}
 
+	// Options that act on individual files need a command that transfers file by file
+	commandName := ""
+	if command, err := h.DB.GetRcloneCommand(config.CommandID); err == nil {
+		commandName = command.Name
+		if err := scheduler.ValidateCommand(&config, command.Name); err != nil {
+			c.String(http.StatusBadRequest, fmt.Sprintf("Invalid command: %v", err))
+			return
+		}
+	}
+
+	if err := scheduler.ValidateRetention(&config, commandName); err != nil {
+		c.String(http.StatusBadRequest, fmt.Sprintf("Invalid retention rules: %v", err))
+		return
+	}
+
 	// Get command_flags and store as JSON
 	commandFlags := c.PostFormArray("command_flags")
 	if len(commandFlags) > 0 {
@@ -285,6 +469,9 @@ func (h *Handlers) HandleCreateConfig(c *gin.Context) {
 		return
 	}
 
+	// Pin the host keys confirmed in the form before the remotes are created
+	h.saveHostKeys(c, &config, userID)
+
 	// Generate rclone config file
 
 	if err := h.DB.GenerateRcloneConfig(&config); err != nil {
@@ -340,6 +527,41 @@ func (h *Handlers) HandleUpdateConfig(c *gin.Context) {
 	config.CreatedBy = existingConfig.CreatedBy
 	config.CreatedAt = existingConfig.CreatedAt
 
+	// Keep the high-water mark while the config reads the same source with it
+	if config.ChangeDetection == existingConfig.ChangeDetection && config.SourcePath == existingConfig.SourcePath {
+		config.HighWaterMark = existingConfig.HighWaterMark
+	}
+
+	// Validators of URLs the config no longer downloads are dropped by its next run
+	if config.SourceType == "http" && existingConfig.SourceType == "http" {
+		config.SourceHTTPValidators = existingConfig.SourceHTTPValidators
+	}
+
+	if err := validateConfigFilters(&config); err != nil {
+		c.String(http.StatusBadRequest, fmt.Sprintf("Invalid filters: %v", err))
+		return
+	}
+
+	if err := scheduler.ValidateOutputPattern(&config); err != nil {
+		c.String(http.StatusBadRequest, fmt.Sprintf("Invalid output pattern: %v", err))
+		return
+	}
+
+	if err := scheduler.ValidateBisync(&config); err != nil {
+		c.String(http.StatusBadRequest, fmt.Sprintf("Invalid bisync options: %v", err))
+		return
+	}
+
+	if err := scheduler.ValidateDestinations(&config); err != nil {
+		c.String(http.StatusBadRequest, fmt.Sprintf("Invalid destinations: %v", err))
+		return
+	}
+
+	if err := h.DB.CheckSSHKeys(&config); err != nil {
+		c.String(http.StatusBadRequest, fmt.Sprintf("Invalid SSH key: %v", err))
+		return
+	}
+
 	// Process Boolean fields
 	skipProcessedVal := c.Request.FormValue("skip_processed_files")
 	skipProcessedValue := skipProcessedVal == "on" || skipProcessedVal == "true"
@@ -361,6 +583,105 @@ func (h *Handlers) HandleUpdateConfig(c *gin.Context) {
 	destPassiveModeValue := destPassiveModeVal == "on" || destPassiveModeVal == "true"
 	config.DestPassiveMode = &destPassiveModeValue
 
+	parseFTPSCheckboxes(c, &config, "source")
+	parseFTPSCheckboxes(c, &config, "dest")
+
+	decompressOnReceiveVal := c.Request.FormValue("decompress_on_receive")
+	decompressOnReceiveValue := decompressOnReceiveVal == "on" || decompressOnReceiveVal == "true"
+	config.DecompressOnReceive = &decompressOnReceiveValue
+
+	requireSignatureVal := c.Request.FormValue("require_signature")
+	requireSignatureValue := requireSignatureVal == "on" || requireSignatureVal == "true"
+	config.RequireSignature = &requireSignatureValue
+
+	sourceCryptVal := c.Request.FormValue("source_crypt")
+	sourceCryptValue := sourceCryptVal == "on" || sourceCryptVal == "true"
+	config.SourceCrypt = &sourceCryptValue
+
+	destCryptVal := c.Request.FormValue("dest_crypt")
+	destCryptValue := destCryptVal == "on" || destCryptVal == "true"
+	config.DestCrypt = &destCryptValue
+
+	stabilityCheckVal := c.Request.FormValue("stability_check")
+	stabilityCheckValue := stabilityCheckVal == "on" || stabilityCheckVal == "true"
+	config.StabilityCheck = &stabilityCheckValue
+
+	atomicDeliveryVal := c.Request.FormValue("atomic_delivery")
+	atomicDeliveryValue := atomicDeliveryVal == "on" || atomicDeliveryVal == "true"
+	config.AtomicDelivery = &atomicDeliveryValue
+
+	atomicVerifyVal := c.Request.FormValue("atomic_verify")
+	atomicVerifyValue := atomicVerifyVal == "on" || atomicVerifyVal == "true"
+	config.AtomicVerify = &atomicVerifyValue
+
+	retentionPreviewVal := c.Request.FormValue("retention_preview")
+	retentionPreviewValue := retentionPreviewVal == "on" || retentionPreviewVal == "true"
+	config.RetentionPreview = &retentionPreviewValue
+
+	manifestSignVal := c.Request.FormValue("manifest_sign")
+	manifestSignValue := manifestSignVal == "on" || manifestSignVal == "true"
+	config.ManifestSign = &manifestSignValue
+
+	if err := encryptManifestKey(&config, existingConfig.ManifestHMACKey); err != nil {
+		c.String(http.StatusInternalServerError, fmt.Sprintf("Failed to store manifest signing key: %v", err))
+		return
+	}
+
+	if err := encryptServiceAccounts(&config, existingConfig.SourceServiceAccount, existingConfig.DestServiceAccount); err != nil {
+		c.String(http.StatusBadRequest, fmt.Sprintf("Invalid service account key: %v", err))
+		return
+	}
+
+	if err := encryptFTPSClientCerts(c, &config, existingConfig.SourceFTPSClientCert, existingConfig.DestFTPSClientCert); err != nil {
+		c.String(http.StatusBadRequest, fmt.Sprintf("Invalid FTPS options: %v", err))
+		return
+	}
+
+	if err := encryptHTTPSecret(&config, existingConfig.SourceHTTPSecret, existingConfig.SourceHTTPAuth); err != nil {
+		c.String(http.StatusBadRequest, fmt.Sprintf("Invalid HTTP source: %v", err))
+		return
+	}
+
+	if err := scheduler.ValidateManifest(&config); err != nil {
+		c.String(http.StatusBadRequest, fmt.Sprintf("Invalid manifest options: %v", err))
+		return
+	}
+
+	if err := scheduler.ValidateTriggers(&config); err != nil {
+		c.String(http.StatusBadRequest, fmt.Sprintf("Invalid trigger options: %v", err))
+		return
+	}
+
+	if err := scheduler.ValidateQuarantine(&config); err != nil {
+		c.String(http.StatusBadRequest, fmt.Sprintf("Invalid quarantine options: %v", err))
+		return
+	}
+
+	if err := scheduler.ValidateDedupe(&config); err != nil {
+		c.String(http.StatusBadRequest, fmt.Sprintf("Invalid deduplication options: %v", err))
+		return
+	}
+
+	if err := scheduler.ValidateChangeDetection(&config); err != nil {
+		c.String(http.StatusBadRequest, fmt.Sprintf("Invalid change detection options: %v", err))
+		return
+	}
+
+	if err := scheduler.ValidateHTTPSource(&config); err != nil {
+		c.String(http.StatusBadRequest, fmt.Sprintf("Invalid HTTP source: %v", err))
+		return
+	}
+
+	if err := scheduler.ValidateAS2Destination(&config); err != nil {
+		c.String(http.StatusBadRequest, fmt.Sprintf("Invalid AS2 destination: %v", err))
+		return
+	}
+
+	if err := h.DB.CheckAS2Partner(&config); err != nil {
+		c.String(http.StatusBadRequest, fmt.Sprintf("Invalid AS2 destination: %v", err))
+		return
+	}
+
 	// Google Photos specific fields
 	destReadOnlyVal := c.Request.FormValue("dest_read_only")
 	destReadOnlyValue := destReadOnlyVal == "on" || destReadOnlyVal == "true"
@@ -392,6 +713,21 @@ func (h *Handlers) HandleUpdateConfig(c *gin.Context) {
 		config.CommandID = 1
 	}
 
+	// Options that act on individual files need a command that transfers file by file
+	commandName := ""
+	if command, err := h.DB.GetRcloneCommand(config.CommandID); err == nil {
+		commandName = command.Name
+		if err := scheduler.ValidateCommand(&config, command.Name); err != nil {
+			c.String(http.StatusBadRequest, fmt.Sprintf("Invalid command: %v", err))
+			return
+		}
+	}
+
+	if err := scheduler.ValidateRetention(&config, commandName); err != nil {
+		c.String(http.StatusBadRequest, fmt.Sprintf("Invalid retention rules: %v", err))
+		return
+	}
+
 	// Get command_flags and store as JSON
 	commandFlags := c.PostFormArray("command_flags")
 	if len(commandFlags) > 0 {
@@ -455,6 +791,13 @@ func (h *Handlers) HandleUpdateConfig(c *gin.Context) {
 	// Preserve the Google Drive authentication status if it's already authenticated
 	config.GoogleDriveAuthenticated = existingConfig.GoogleDriveAuthenticated
 
+	// Preserve the OAuth tokens of sides that keep their provider
+	for _, side := range []string{"source", "dest"} {
+		if config.SideType(side) == existingConfig.SideType(side) {
+			config.SetOAuthToken(side, existingConfig.GetOAuthToken(side))
+		}
+	}
+
 	// Update the LastUpdated timestamp
 	config.UpdatedAt = time.Now()
 
@@ -463,6 +806,9 @@ func (h *Handlers) HandleUpdateConfig(c *gin.Context) {
 		return
 	}
 
+	// Pin the host keys confirmed in the form before the remotes are created
+	h.saveHostKeys(c, &config, userID)
+
 	// Regenerate the rclone config file
 	if err := h.DB.GenerateRcloneConfig(&config); err != nil {
 		log.Printf("Warning: Failed to regenerate rclone config after update: %v", err)
@@ -553,9 +899,57 @@ func (h *Handlers) HandleDeleteConfig(c *gin.Context) {
 		return
 	}
 
+	// Remove the bisync listings of the deleted config
+	if err := h.DB.ResetBisyncState(&config); err != nil {
+		log.Printf("HandleDeleteConfig: Warning - Failed to remove bisync state: %v", err)
+	}
+
 	c.JSON(http.StatusOK, gin.H{"message": "Config deleted successfully"})
 }
 
+// HandleResetBisyncState handles the POST /configs/:id/bisync/reset route. It removes the
+// bisync listings of a config so the next bisync run starts with a resync.
+func (h *Handlers) HandleResetBisyncState(c *gin.Context) {
+	id := c.Param("id")
+	userID := c.GetUint("userID")
+
+	var config db.TransferConfig
+	if err := h.DB.First(&config, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Config not found"})
+		return
+	}
+
+	// Check if user owns this config
+	if config.CreatedBy != userID {
+		// Check if user is admin
+		isAdmin, exists := c.Get("isAdmin")
+		if !exists || isAdmin != true {
+			c.JSON(http.StatusForbidden, gin.H{"error": "You do not have permission to reset this config"})
+			return
+		}
+	}
+
+	if err := h.DB.ResetBisyncState(&config); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to reset bisync state: %v", err)})
+		return
+	}
+
+	// Create audit log for the reset
+	auditLog := db.AuditLog{
+		Action:     "bisync_reset",
+		EntityType: "config",
+		EntityID:   config.ID,
+		UserID:     userID,
+		Details:    map[string]interface{}{"name": config.Name},
+		Timestamp:  time.Now(),
+	}
+	if err := h.DB.Create(&auditLog).Error; err != nil {
+		log.Printf("HandleResetBisyncState: Warning - Failed to create audit log: %v", err)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Bisync state reset, the next run will resync"})
+}
+
 // HandleDuplicateConfig handles the POST /configs/:id/duplicate route
 func (h *Handlers) HandleDuplicateConfig(c *gin.Context) {
 	id := c.Param("id")
@@ -584,6 +978,13 @@ func (h *Handlers) HandleDuplicateConfig(c *gin.Context) {
 	duplicateConfig.CreatedAt = time.Now()
 	duplicateConfig.UpdatedAt = time.Now()
 	duplicateConfig.CreatedBy = userID
+	duplicateConfig.HighWaterMark = nil       // The copy starts with its own high-water mark
+	duplicateConfig.SourceHTTPValidators = "" // and downloads every URL on its first run
+
+	// The copy is authorized separately, as some providers rotate refresh tokens
+	duplicateConfig.SourceOAuthToken = ""
+	duplicateConfig.DestOAuthToken = ""
+	duplicateConfig.GoogleDriveAuthenticated = nil
 
 	// Deep copy all boolean pointers
 	skipProcessedVal := *originalConfig.SkipProcessedFiles
@@ -601,6 +1002,60 @@ func (h *Handlers) HandleDuplicateConfig(c *gin.Context) {
 	destPassiveModeVal := *originalConfig.DestPassiveMode
 	duplicateConfig.DestPassiveMode = &destPassiveModeVal
 
+	sourceFTPSSkipVerifyVal := originalConfig.GetSourceFTPSSkipVerify()
+	duplicateConfig.SourceFTPSSkipVerify = &sourceFTPSSkipVerifyVal
+	sourceFTPSSessionReuseVal := originalConfig.GetSourceFTPSSessionReuse()
+	duplicateConfig.SourceFTPSSessionReuse = &sourceFTPSSessionReuseVal
+	destFTPSSkipVerifyVal := originalConfig.GetDestFTPSSkipVerify()
+	duplicateConfig.DestFTPSSkipVerify = &destFTPSSkipVerifyVal
+	destFTPSSessionReuseVal := originalConfig.GetDestFTPSSessionReuse()
+	duplicateConfig.DestFTPSSessionReuse = &destFTPSSessionReuseVal
+
+	if originalConfig.DecompressOnReceive != nil {
+		decompressOnReceiveVal := *originalConfig.DecompressOnReceive
+		duplicateConfig.DecompressOnReceive = &decompressOnReceiveVal
+	}
+
+	if originalConfig.RequireSignature != nil {
+		requireSignatureVal := *originalConfig.RequireSignature
+		duplicateConfig.RequireSignature = &requireSignatureVal
+	}
+
+	if originalConfig.SourceCrypt != nil {
+		sourceCryptVal := *originalConfig.SourceCrypt
+		duplicateConfig.SourceCrypt = &sourceCryptVal
+	}
+
+	if originalConfig.DestCrypt != nil {
+		destCryptVal := *originalConfig.DestCrypt
+		duplicateConfig.DestCrypt = &destCryptVal
+	}
+
+	if originalConfig.StabilityCheck != nil {
+		stabilityCheckVal := *originalConfig.StabilityCheck
+		duplicateConfig.StabilityCheck = &stabilityCheckVal
+	}
+
+	if originalConfig.AtomicDelivery != nil {
+		atomicDeliveryVal := *originalConfig.AtomicDelivery
+		duplicateConfig.AtomicDelivery = &atomicDeliveryVal
+	}
+
+	if originalConfig.AtomicVerify != nil {
+		atomicVerifyVal := *originalConfig.AtomicVerify
+		duplicateConfig.AtomicVerify = &atomicVerifyVal
+	}
+
+	if originalConfig.RetentionPreview != nil {
+		retentionPreviewVal := *originalConfig.RetentionPreview
+		duplicateConfig.RetentionPreview = &retentionPreviewVal
+	}
+
+	if originalConfig.ManifestSign != nil {
+		manifestSignVal := *originalConfig.ManifestSign
+		duplicateConfig.ManifestSign = &manifestSignVal
+	}
+
 	// Google Photos specific fields
 	if originalConfig.DestReadOnly != nil {
 		destReadOnlyVal := *originalConfig.DestReadOnly
@@ -685,6 +1140,11 @@ func (h *Handlers) HandleDuplicateConfig(c *gin.Context) {
 		return
 	}
 
+	// The copy connects to the same servers, keep their pinned host keys
+	if err := h.DB.CopyHostKeys(originalConfig.ID, duplicateConfig.ID); err != nil {
+		log.Printf("Warning: Failed to copy pinned host keys to duplicate: %v", err)
+	}
+
 	// Generate rclone config file for the duplicate
 	if err := h.DB.GenerateRcloneConfig(&duplicateConfig); err != nil {
 		log.Printf("Warning: Failed to generate rclone config for duplicate: %v", err)
@@ -718,6 +1178,8 @@ func (h *Handlers) HandleTestProviderConnection(c *gin.Context) {
 		sourcePassiveModeValue := sourcePassiveModeVal == "on" || sourcePassiveModeVal == "true"
 		config.SourcePassiveMode = &sourcePassiveModeValue
 
+		parseFTPSCheckboxes(c, &config, "source")
+
 		sourceReadOnlyVal := c.Request.FormValue("source_read_only")
 		sourceReadOnlyValue := sourceReadOnlyVal == "on" || sourceReadOnlyVal == "true"
 		config.SourceReadOnly = &sourceReadOnlyValue
@@ -729,11 +1191,17 @@ func (h *Handlers) HandleTestProviderConnection(c *gin.Context) {
 		useBuiltinAuthSourceVal := c.Request.FormValue("use_builtin_auth_source")
 		useBuiltinAuthSourceValue := useBuiltinAuthSourceVal == "on" || useBuiltinAuthSourceVal == "true"
 		config.UseBuiltinAuthSource = &useBuiltinAuthSourceValue
+
+		sourceCryptVal := c.Request.FormValue("source_crypt")
+		sourceCryptValue := sourceCryptVal == "on" || sourceCryptVal == "true"
+		config.SourceCrypt = &sourceCryptValue
 	} else if providerType == "destination" {
 		destPassiveModeVal := c.Request.FormValue("dest_passive_mode")
 		destPassiveModeValue := destPassiveModeVal == "on" || destPassiveModeVal == "true"
 		config.DestPassiveMode = &destPassiveModeValue
 
+		parseFTPSCheckboxes(c, &config, "dest")
+
 		destReadOnlyVal := c.Request.FormValue("dest_read_only")
 		destReadOnlyValue := destReadOnlyVal == "on" || destReadOnlyVal == "true"
 		config.DestReadOnly = &destReadOnlyValue
@@ -745,11 +1213,39 @@ func (h *Handlers) HandleTestProviderConnection(c *gin.Context) {
 		useBuiltinAuthDestVal := c.Request.FormValue("use_builtin_auth_dest")
 		useBuiltinAuthDestValue := useBuiltinAuthDestVal == "on" || useBuiltinAuthDestVal == "true"
 		config.UseBuiltinAuthDest = &useBuiltinAuthDestValue
+
+		destCryptVal := c.Request.FormValue("dest_crypt")
+		destCryptValue := destCryptVal == "on" || destCryptVal == "true"
+		config.DestCrypt = &destCryptValue
+	}
+
+	// Fetch the host key of SFTP servers so it can be confirmed and pinned
+	side := "source"
+	if providerType == "destination" {
+		side = "dest"
+	}
+	var scan *hostKeyScan
+	if config.IsSFTPSide(side) {
+		var err error
+		if scan, err = scanHostKey(&config, side); err != nil {
+			log.Printf("Error fetching host key: %v", err)
+		}
 	}
 
 	// Call the rclone test function (to be implemented)
-	success, message, err := rclone_service.TestRcloneConnection(config, providerType, h.DB) // Pass DB if needed for built-in auth
-	toastType := "info"                                                                      // Default type
+	var success bool
+	var message string
+	var err error
+	if scan != nil && scan.Status == "changed" {
+		// Do not send credentials to a server that may be impersonated
+		message = fmt.Sprintf("Host key changed: the server presented %s, which does not match the pinned key. Verify the new key with the server administrator before trusting it.", scan.Fingerprint)
+	} else {
+		success, message, err = rclone_service.TestRcloneConnection(config, providerType, h.DB) // Pass DB if needed for built-in auth
+		if success && scan != nil && scan.Status == "new" {
+			message = fmt.Sprintf("%s Host key fingerprint: %s. Pin it to reject any other key.", message, scan.Fingerprint)
+		}
+	}
+	toastType := "info" // Default type
 	if err != nil {
 		log.Printf("Error testing rclone connection: %v. Message: %s", err, message) // Log both err and message
 		toastType = "error"
@@ -768,6 +1264,9 @@ func (h *Handlers) HandleTestProviderConnection(c *gin.Context) {
 			"type":    toastType,
 		},
 	}
+	if scan != nil {
+		toastData["hostKeyScanned"] = scan
+	}
 
 	// Marshal data to JSON for the header
 	jsonData, err := json.Marshal(toastData)
@@ -783,3 +1282,210 @@ func (h *Handlers) HandleTestProviderConnection(c *gin.Context) {
 	c.Header("HX-Trigger", string(jsonData))
 	c.Status(http.StatusOK) // Return 200 OK, but with no body swap intended
 }
+
+// encryptManifestKey encrypts a submitted manifest signing key for storage. A blank key
+// keeps the stored key, and the key is removed when manifests are no longer signed.
+func encryptManifestKey(config *db.TransferConfig, storedKey string) error {
+	if !config.GetManifestSign() {
+		config.ManifestHMACKey = ""
+		return nil
+	}
+	if config.ManifestHMACKey == "" {
+		config.ManifestHMACKey = storedKey
+		return nil
+	}
+	encrypted, err := auth.EncryptKeyMaterial(config.ManifestHMACKey)
+	if err != nil {
+		return err
+	}
+	config.ManifestHMACKey = encrypted
+	return nil
+}
+
+// encryptServiceAccounts checks and encrypts uploaded Google Cloud Storage service account
+// keys. A blank key keeps the stored key, and the key is removed when the side is no longer GCS.
+func encryptServiceAccounts(config *db.TransferConfig, storedSource, storedDest string) error {
+	source, err := encryptServiceAccount(config.SourceType, config.SourceServiceAccount, storedSource)
+	if err != nil {
+		return fmt.Errorf("source: %v", err)
+	}
+	dest, err := encryptServiceAccount(config.DestinationType, config.DestServiceAccount, storedDest)
+	if err != nil {
+		return fmt.Errorf("destination: %v", err)
+	}
+	config.SourceServiceAccount = source
+	config.DestServiceAccount = dest
+	return nil
+}
+
+// encryptServiceAccount returns the value to store for one side's service account key
+func encryptServiceAccount(providerType, submitted, stored string) (string, error) {
+	if providerType != "gcs" {
+		return "", nil
+	}
+	if strings.TrimSpace(submitted) == "" {
+		if stored == "" {
+			return "", fmt.Errorf("a service account JSON key is required")
+		}
+		return stored, nil
+	}
+
+	var key struct {
+		Type        string `json:"type"`
+		ClientEmail string `json:"client_email"`
+		PrivateKey  string `json:"private_key"`
+	}
+	if err := json.Unmarshal([]byte(submitted), &key); err != nil {
+		return "", fmt.Errorf("not a JSON key file: %v", err)
+	}
+	if key.Type != "service_account" || key.ClientEmail == "" || key.PrivateKey == "" {
+		return "", fmt.Errorf("the JSON file is not a service account key")
+	}
+	return auth.EncryptKeyMaterial(submitted)
+}
+
+// parseFTPSCheckboxes processes the FTPS checkboxes of a side of the config form
+func parseFTPSCheckboxes(c *gin.Context, config *db.TransferConfig, side string) {
+	skipVerifyVal := c.Request.FormValue(side + "_ftps_skip_verify")
+	skipVerify := skipVerifyVal == "on" || skipVerifyVal == "true"
+	sessionReuseVal := c.Request.FormValue(side + "_ftps_session_reuse")
+	sessionReuse := sessionReuseVal == "on" || sessionReuseVal == "true"
+	if side == "source" {
+		config.SourceFTPSSkipVerify = &skipVerify
+		config.SourceFTPSSessionReuse = &sessionReuse
+	} else {
+		config.DestFTPSSkipVerify = &skipVerify
+		config.DestFTPSSessionReuse = &sessionReuse
+	}
+}
+
+// encryptFTPSClientCerts checks the FTPS options of the FTP sides of a config and encrypts
+// uploaded client certificates. A blank certificate keeps the stored one unless its removal
+// was requested, and the certificate is removed when the side no longer uses FTPS.
+func encryptFTPSClientCerts(c *gin.Context, config *db.TransferConfig, storedSource, storedDest string) error {
+	if c.Request.FormValue("source_ftps_client_cert_remove") != "" {
+		storedSource = ""
+	}
+	if c.Request.FormValue("dest_ftps_client_cert_remove") != "" {
+		storedDest = ""
+	}
+
+	source, err := encryptFTPSClientCert(config.SourceType, ftps.Options{
+		Mode:       config.SourceFTPSMode,
+		CACert:     config.SourceFTPSCACert,
+		ClientCert: config.SourceFTPSClientCert,
+	}, storedSource)
+	if err != nil {
+		return fmt.Errorf("source: %v", err)
+	}
+	dest, err := encryptFTPSClientCert(config.DestinationType, ftps.Options{
+		Mode:       config.DestFTPSMode,
+		CACert:     config.DestFTPSCACert,
+		ClientCert: config.DestFTPSClientCert,
+	}, storedDest)
+	if err != nil {
+		return fmt.Errorf("destination: %v", err)
+	}
+	config.SourceFTPSClientCert = source
+	config.DestFTPSClientCert = dest
+	return nil
+}
+
+// encryptFTPSClientCert returns the value to store for one side's FTPS client certificate
+func encryptFTPSClientCert(providerType string, opts ftps.Options, stored string) (string, error) {
+	if providerType != "ftp" {
+		return "", nil
+	}
+	if err := opts.Validate(); err != nil {
+		return "", err
+	}
+	if !opts.Enabled() {
+		return "", nil
+	}
+	if strings.TrimSpace(opts.ClientCert) == "" {
+		return stored, nil
+	}
+	return auth.EncryptKeyMaterial(opts.ClientCert)
+}
+
+// encryptHTTPSecret encrypts the password or token of an HTTP source. A blank secret keeps
+// the stored one while the authentication type is unchanged.
+func encryptHTTPSecret(config *db.TransferConfig, stored, storedAuth string) error {
+	if config.SourceType != "http" || config.SourceHTTPAuth == "" {
+		config.SourceHTTPSecret = ""
+		return nil
+	}
+	if config.SourceHTTPSecret == "" {
+		if stored == "" || config.SourceHTTPAuth != storedAuth {
+			if config.SourceHTTPAuth == httpsource.AuthBearer {
+				return fmt.Errorf("a bearer token is required")
+			}
+			return fmt.Errorf("a password is required for basic authentication")
+		}
+		config.SourceHTTPSecret = stored
+		return nil
+	}
+	encrypted, err := auth.EncryptKeyMaterial(config.SourceHTTPSecret)
+	if err != nil {
+		return err
+	}
+	config.SourceHTTPSecret = encrypted
+	return nil
+}
+
+// validateConfigFilters checks the include/exclude, size and age filters of a submitted config
+func validateConfigFilters(config *db.TransferConfig) error {
+	filter, err := filters.FromConfig(config)
+	if err != nil {
+		return err
+	}
+	return filter.Validate()
+}
+
+// HandleTestFilters handles the POST /configs/test-filters route
+func (h *Handlers) HandleTestFilters(c *gin.Context) {
+	var config db.TransferConfig
+	if err := c.ShouldBind(&config); err != nil {
+		components.TestResult(false, fmt.Sprintf("Invalid form data: %v", err)).Render(c, c.Writer)
+		return
+	}
+
+	filter, err := filters.FromConfig(&config)
+	if err == nil {
+		err = filter.Validate()
+	}
+	if err != nil {
+		components.TestResult(false, fmt.Sprintf("Invalid filters: %v", err)).Render(c, c.Writer)
+		return
+	}
+
+	results, err := filter.TestSample(c.PostForm("sample_listing"), time.Now())
+	if err != nil {
+		components.TestResult(false, fmt.Sprintf("Invalid sample listing: %v", err)).Render(c, c.Writer)
+		return
+	}
+
+	components.FilterTestResults(results).Render(c, c.Writer)
+}
+
+// HandlePreviewOutputPattern handles the POST /configs/preview-output-pattern route
+func (h *Handlers) HandlePreviewOutputPattern(c *gin.Context) {
+	var config db.TransferConfig
+	if err := c.ShouldBind(&config); err != nil {
+		components.TestResult(false, fmt.Sprintf("Invalid form data: %v", err)).Render(c, c.Writer)
+		return
+	}
+	if config.OutputPattern == "" {
+		components.TestResult(false, "Enter an output pattern to preview").Render(c, c.Writer)
+		return
+	}
+
+	// The config is not attached to a job yet, so a placeholder job name is used
+	previews, err := scheduler.PreviewOutputPattern(&config, "example_job", c.PostForm("output_pattern_examples"), time.Now())
+	if err != nil {
+		components.TestResult(false, fmt.Sprintf("Invalid output pattern: %v", err)).Render(c, c.Writer)
+		return
+	}
+
+	components.OutputPatternPreview(previews).Render(c, c.Writer)
+}
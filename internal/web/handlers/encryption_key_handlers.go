@@ -0,0 +1,158 @@
+package handlers
+
+// Key store handlers for PGP and age encryption keys
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/starfleetcptn/gomft/components"
+	"github.com/starfleetcptn/gomft/internal/auth"
+	"github.com/starfleetcptn/gomft/internal/db"
+	"github.com/starfleetcptn/gomft/internal/encryption"
+)
+
+// HandleEncryptionKeys renders the key store page
+func (h *Handlers) HandleEncryptionKeys(c *gin.Context) {
+	keys, err := h.DB.GetEncryptionKeys()
+	if err != nil {
+		h.HandleServerError(c, err)
+		return
+	}
+
+	components.EncryptionKeys(c.Request.Context(), keys).Render(c, c.Writer)
+}
+
+// HandleCreateEncryptionKey imports a PGP or age key into the key store
+func (h *Handlers) HandleCreateEncryptionKey(c *gin.Context) {
+	userID := c.GetUint("userID")
+	if userID == 0 {
+		h.HandleUnauthorized(c)
+		return
+	}
+
+	name := strings.TrimSpace(c.PostForm("name"))
+	keyType := c.PostForm("type")
+	material := strings.TrimSpace(c.PostForm("key_material"))
+	passphrase := c.PostForm("passphrase")
+
+	if name == "" {
+		h.HandleBadRequest(c, "Invalid Key", "A name is required")
+		return
+	}
+
+	info, err := encryption.ParseKey(keyType, material, passphrase)
+	if err != nil {
+		h.HandleBadRequest(c, "Invalid Key", err.Error())
+		return
+	}
+
+	key := &db.EncryptionKey{
+		Name:        name,
+		Type:        info.Type,
+		Fingerprint: info.Fingerprint,
+		PublicKey:   info.PublicKey,
+		CreatedBy:   userID,
+	}
+
+	// Private key material is only stored encrypted
+	if info.HasPrivateKey {
+		if key.PrivateKey, err = auth.EncryptKeyMaterial(material); err != nil {
+			h.HandleServerError(c, err)
+			return
+		}
+		if key.Passphrase, err = auth.EncryptKeyMaterial(passphrase); err != nil {
+			h.HandleServerError(c, err)
+			return
+		}
+	}
+
+	if err := h.DB.CreateEncryptionKey(key); err != nil {
+		h.HandleServerError(c, err)
+		return
+	}
+
+	auditLog := db.AuditLog{
+		Action:     "create",
+		EntityType: "encryption_key",
+		EntityID:   key.ID,
+		UserID:     userID,
+		Details: map[string]interface{}{
+			"name":        key.Name,
+			"type":        key.Type,
+			"fingerprint": key.Fingerprint,
+			"private_key": key.HasPrivateKey(),
+		},
+		Timestamp: time.Now(),
+	}
+	if err := h.DB.Create(&auditLog).Error; err != nil {
+		log.Printf("Error creating audit log: %v", err)
+	}
+
+	// Set success flash and redirect
+	c.SetCookie("flash_message", fmt.Sprintf("Key '%s' added to the key store", key.Name), 3600, "/", "", false, true)
+	c.SetCookie("flash_type", "success", 3600, "/", "", false, true)
+
+	c.Redirect(http.StatusFound, "/admin/settings/encryption-keys")
+}
+
+// HandleDeleteEncryptionKey removes a key from the key store
+func (h *Handlers) HandleDeleteEncryptionKey(c *gin.Context) {
+	userID := c.GetUint("userID")
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	keyID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid key ID"})
+		return
+	}
+
+	key, err := h.DB.GetEncryptionKey(uint(keyID))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Encryption key not found"})
+		return
+	}
+
+	// Keys that are still referenced by a config cannot be removed
+	count, err := h.DB.CountConfigsUsingEncryptionKey(key.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check if key is in use"})
+		return
+	}
+	if count > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": fmt.Sprintf("Cannot delete key '%s' because it is used by %d transfer configurations", key.Name, count),
+		})
+		return
+	}
+
+	if err := h.DB.DeleteEncryptionKey(key.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete encryption key"})
+		return
+	}
+
+	auditLog := db.AuditLog{
+		Action:     "delete",
+		EntityType: "encryption_key",
+		EntityID:   key.ID,
+		UserID:     userID,
+		Details: map[string]interface{}{
+			"name":        key.Name,
+			"type":        key.Type,
+			"fingerprint": key.Fingerprint,
+		},
+		Timestamp: time.Now(),
+	}
+	if err := h.DB.Create(&auditLog).Error; err != nil {
+		log.Printf("Error creating audit log: %v", err)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": fmt.Sprintf("Key '%s' deleted", key.Name)})
+}
@@ -2,8 +2,10 @@ package handlers
 
 import (
 	"fmt"
+	"log"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/starfleetcptn/gomft/components"
@@ -12,11 +14,13 @@ import (
 	"github.com/starfleetcptn/gomft/components/file_metadata/list"
 	"github.com/starfleetcptn/gomft/components/file_metadata/search"
 	"github.com/starfleetcptn/gomft/internal/db"
+	"github.com/starfleetcptn/gomft/internal/scheduler"
 )
 
 // FileMetadataHandler handles displaying and searching file metadata
 type FileMetadataHandler struct {
-	DB *db.DB
+	DB        *db.DB
+	Scheduler scheduler.SchedulerInterface
 }
 
 type UserIDKey string
@@ -483,6 +487,54 @@ func (h *FileMetadataHandler) DeleteFileMetadata(c *gin.Context) {
 	}
 }
 
+// ReleaseQuarantinedFile moves a quarantined file back to the source of its configuration
+// so the next run transfers it again
+func (h *FileMetadataHandler) ReleaseQuarantinedFile(c *gin.Context) {
+	userID := c.GetUint("userID")
+
+	// Get file ID from URL parameter
+	fileID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.String(http.StatusBadRequest, "Invalid file ID")
+		return
+	}
+
+	// Check if the user has access to this file
+	var fileMetadata db.FileMetadata
+	err = h.DB.DB.First(&fileMetadata, fileID).Error
+	if err != nil {
+		c.String(http.StatusNotFound, "File not found")
+		return
+	}
+
+	var jobCreator uint
+	err = h.DB.DB.Model(&db.Job{}).Where("id = ?", fileMetadata.JobID).Pluck("created_by", &jobCreator).Error
+	if err != nil || jobCreator != userID {
+		c.String(http.StatusForbidden, "You don't have permission to release this file")
+		return
+	}
+
+	if err := h.Scheduler.ReleaseQuarantinedFile(fileMetadata.ID); err != nil {
+		c.String(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// Create audit log for the released file
+	auditLog := db.AuditLog{
+		Action:     "release_file",
+		EntityType: "file",
+		EntityID:   fileMetadata.ID,
+		UserID:     userID,
+		Details:    map[string]interface{}{"file_name": fileMetadata.FileName, "job_id": fileMetadata.JobID, "config_id": fileMetadata.ConfigID},
+		Timestamp:  time.Now(),
+	}
+	if err := h.DB.Create(&auditLog).Error; err != nil {
+		log.Printf("ReleaseQuarantinedFile: Warning - Failed to create audit log: %v", err)
+	}
+
+	c.String(http.StatusOK, fmt.Sprintf("%s has been moved back to the source and will be transferred by the next run", fileMetadata.FileName))
+}
+
 // HandleFileMetadataPartial handles rendering just the partial template for file metadata
 func (h *FileMetadataHandler) HandleFileMetadataPartial(c *gin.Context) {
 	// Check if this is an HTMX request or a direct browser request
@@ -0,0 +1,225 @@
+package handlers
+
+// Host key handlers for pinning the SSH host keys of SFTP servers
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/starfleetcptn/gomft/components"
+	"github.com/starfleetcptn/gomft/internal/db"
+	"github.com/starfleetcptn/gomft/internal/hostkeys"
+)
+
+// hostKeyScanTimeout is how long fetching the host key of a server may take
+const hostKeyScanTimeout = 10 * time.Second
+
+// hostKeyScan is the result of fetching the host key of the SFTP server of a side, sent to
+// the config form to let the user confirm and pin the key
+type hostKeyScan struct {
+	Side        string `json:"side"`
+	Status      string `json:"status"` // new, pinned or changed
+	KeyType     string `json:"key_type"`
+	Fingerprint string `json:"fingerprint"`
+	Line        string `json:"line"` // known_hosts line pinning the key to the server
+}
+
+// scanHostKey fetches the host key of the SFTP server of a side of a submitted config and
+// compares it with the key pinned in the form
+func scanHostKey(config *db.TransferConfig, side string) (*hostKeyScan, error) {
+	server := config.SideServer(side)
+	scanned, err := hostkeys.Scan(server.Host, server.Port, hostKeyScanTimeout)
+	if err != nil {
+		return nil, err
+	}
+	line, err := hostkeys.KnownHostsLine(server.Host, server.Port, scanned.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+	pinned, err := config.PinnedHostKey(side)
+	if err != nil {
+		return nil, err
+	}
+
+	scan := &hostKeyScan{Side: side, Status: "new", KeyType: scanned.Type, Fingerprint: scanned.Fingerprint, Line: line}
+	if pinned != nil {
+		scan.Status = "pinned"
+		if pinned.Fingerprint != scanned.Fingerprint {
+			scan.Status = "changed"
+		}
+	}
+	return scan, nil
+}
+
+// saveHostKeys pins the host keys submitted with a config form, or removes the pins that
+// were cleared. Keys of other servers than the config connects to are removed.
+func (h *Handlers) saveHostKeys(c *gin.Context, config *db.TransferConfig, userID uint) {
+	for _, side := range []string{"source", "dest"} {
+		if _, submitted := c.GetPostForm(side + "_host_key"); !submitted {
+			continue
+		}
+		changed, key, err := h.DB.SaveHostKey(config, side, userID)
+		if err != nil {
+			log.Printf("Warning: Failed to pin host key of the %s of config %d: %v", side, config.ID, err)
+			continue
+		}
+		if !changed {
+			continue
+		}
+
+		action := "pin"
+		if config.GetHostKey(side) == "" {
+			action = "unpin"
+		}
+		h.auditHostKey(action, key, userID)
+	}
+
+	if err := h.DB.PruneHostKeys(config); err != nil {
+		log.Printf("Warning: Failed to remove unused host keys of config %d: %v", config.ID, err)
+	}
+}
+
+// auditHostKey records a change of a pinned host key in the audit log
+func (h *Handlers) auditHostKey(action string, key *db.HostKey, userID uint) {
+	auditLog := db.AuditLog{
+		Action:     action,
+		EntityType: "host_key",
+		EntityID:   key.ID,
+		UserID:     userID,
+		Details: map[string]interface{}{
+			"config_id":   key.ConfigID,
+			"server":      key.Address(),
+			"key_type":    key.KeyType,
+			"fingerprint": key.Fingerprint,
+		},
+		Timestamp: time.Now(),
+	}
+	if err := h.DB.Create(&auditLog).Error; err != nil {
+		log.Printf("Error creating audit log: %v", err)
+	}
+}
+
+// HandleHostKeys renders the page of pinned host keys
+func (h *Handlers) HandleHostKeys(c *gin.Context) {
+	keys, err := h.DB.GetHostKeys()
+	if err != nil {
+		h.HandleServerError(c, err)
+		return
+	}
+
+	components.HostKeys(c.Request.Context(), keys).Render(c, c.Writer)
+}
+
+// HandleScanHostKey fetches the current host key of the server of a pinned key, to review
+// it before rotating the pin
+func (h *Handlers) HandleScanHostKey(c *gin.Context) {
+	key, ok := h.getHostKeyParam(c)
+	if !ok {
+		return
+	}
+
+	scanned, err := hostkeys.Scan(key.Host, key.Port, hostKeyScanTimeout)
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"key_type":    scanned.Type,
+		"fingerprint": scanned.Fingerprint,
+		"matches":     scanned.Fingerprint == key.Fingerprint,
+	})
+}
+
+// HandleRotateHostKey pins the key a server currently presents, after the admin confirmed
+// its fingerprint
+func (h *Handlers) HandleRotateHostKey(c *gin.Context) {
+	userID := c.GetUint("userID")
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	key, ok := h.getHostKeyParam(c)
+	if !ok {
+		return
+	}
+
+	confirmed := strings.TrimSpace(c.PostForm("fingerprint"))
+	if confirmed == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Confirm the fingerprint of the new host key"})
+		return
+	}
+
+	// The key is fetched again so only the key the admin reviewed is pinned
+	scanned, err := hostkeys.Scan(key.Host, key.Port, hostKeyScanTimeout)
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+		return
+	}
+	if scanned.Fingerprint != confirmed {
+		c.JSON(http.StatusConflict, gin.H{
+			"error": fmt.Sprintf("The server now presents %s, not the confirmed key. Review the key again.", scanned.Fingerprint),
+		})
+		return
+	}
+
+	rotated, err := h.DB.PinHostKey(key.ConfigID, key.Host, key.Port, scanned.PublicKey, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to pin host key"})
+		return
+	}
+	h.auditHostKey("rotate", rotated, userID)
+
+	if err := h.DB.SyncKnownHosts(&key.Config); err != nil {
+		log.Printf("Warning: Failed to update known_hosts of config %d: %v", key.ConfigID, err)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": fmt.Sprintf("Host key of %s rotated", key.Address())})
+}
+
+// HandleDeleteHostKey removes a pinned host key, the server's key is no longer checked
+func (h *Handlers) HandleDeleteHostKey(c *gin.Context) {
+	userID := c.GetUint("userID")
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	key, ok := h.getHostKeyParam(c)
+	if !ok {
+		return
+	}
+
+	if err := h.DB.DeleteHostKey(key.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete host key"})
+		return
+	}
+	h.auditHostKey("unpin", key, userID)
+
+	if err := h.DB.SyncKnownHosts(&key.Config); err != nil {
+		log.Printf("Warning: Failed to update known_hosts of config %d: %v", key.ConfigID, err)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": fmt.Sprintf("Host key of %s deleted", key.Address())})
+}
+
+// getHostKeyParam loads the pinned host key of the id route parameter, or writes an error
+func (h *Handlers) getHostKeyParam(c *gin.Context) (*db.HostKey, bool) {
+	keyID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid host key ID"})
+		return nil, false
+	}
+
+	key, err := h.DB.GetHostKey(uint(keyID))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Host key not found"})
+		return nil, false
+	}
+	return key, true
+}
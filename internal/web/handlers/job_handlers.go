@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"time"
@@ -11,6 +13,7 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/starfleetcptn/gomft/components"
 	"github.com/starfleetcptn/gomft/internal/db"
+	"github.com/starfleetcptn/gomft/internal/scheduler"
 )
 
 // HandleJobs handles the GET /jobs route
@@ -89,6 +92,18 @@ func (h *Handlers) HandleJobRunDetails(c *gin.Context) {
 		Config:     config,
 	}
 
+	// Show the progress of the run's checkpoint so an unfinished run can be resumed
+	if total, remaining, err := h.DB.CountRunFiles(jobHistory.ID); err == nil {
+		data.CheckpointFiles = total
+		data.RemainingFiles = remaining
+		data.Resumable = total > 0 && scheduler.IsResumable(jobHistory.Status)
+	}
+
+	// Show the AS2 messages the run sent and the state of their MDNs
+	if messages, err := h.DB.GetAS2MessagesForRun(jobHistory.ID); err == nil {
+		data.AS2Messages = messages
+	}
+
 	components.JobRunDetails(c.Request.Context(), data).Render(c, c.Writer)
 }
 
@@ -734,6 +749,110 @@ func (h *Handlers) HandleRunJob(c *gin.Context) {
 	c.String(http.StatusOK, successScript)
 }
 
+// HandleResumeJobRun handles the POST /job-runs/:id/resume route
+func (h *Handlers) HandleResumeJobRun(c *gin.Context) {
+	id := c.Param("id")
+	userID := c.GetUint("userID")
+
+	var jobHistory db.JobHistory
+	if err := h.DB.First(&jobHistory, id).Error; err != nil {
+		c.Header("Content-Type", "text/html")
+		c.String(http.StatusNotFound, "Job run not found")
+		return
+	}
+
+	var job db.Job
+	if err := h.DB.First(&job, jobHistory.JobID).Error; err != nil {
+		c.Header("Content-Type", "text/html")
+		c.String(http.StatusNotFound, "Job not found")
+		return
+	}
+
+	// Check if user owns this job
+	if job.CreatedBy != userID {
+		// Check if user is admin
+		isAdmin, exists := c.Get("isAdmin")
+		if !exists || isAdmin != true {
+			c.Header("Content-Type", "text/html")
+			c.String(http.StatusForbidden, "You do not have permission to resume this job run")
+			return
+		}
+	}
+
+	// Resume the run from its checkpoint
+	if err := h.Scheduler.ResumeRun(jobHistory.ID); err != nil {
+		c.Header("Content-Type", "text/html")
+		c.String(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// Create audit log for the resumed run
+	auditLog := db.AuditLog{
+		Action:     "resume_run",
+		EntityType: "job",
+		EntityID:   job.ID,
+		UserID:     userID,
+		Details:    map[string]interface{}{"name": job.Name, "job_id": job.ID, "run_id": jobHistory.ID},
+		Timestamp:  time.Now(),
+	}
+	if err := h.DB.Create(&auditLog).Error; err != nil {
+		log.Printf("HandleResumeJobRun: Warning - Failed to create audit log: %v", err)
+	}
+
+	c.Header("Content-Type", "text/html")
+	c.String(http.StatusOK, fmt.Sprintf("Run #%d of job \"%s\" is being resumed", jobHistory.ID, job.Name))
+}
+
+// HandleDownloadRunManifest handles the GET /job-runs/:id/manifest route. The signature
+// of the manifest is returned instead when the signature query parameter is set.
+func (h *Handlers) HandleDownloadRunManifest(c *gin.Context) {
+	id := c.Param("id")
+	userID := c.GetUint("userID")
+
+	var jobHistory db.JobHistory
+	if err := h.DB.First(&jobHistory, id).Error; err != nil {
+		h.HandleNotFound(c, "Job run not found", "The requested job run does not exist")
+		return
+	}
+
+	var job db.Job
+	if err := h.DB.First(&job, jobHistory.JobID).Error; err != nil {
+		h.HandleNotFound(c, "Job not found", "The job of this run does not exist")
+		return
+	}
+
+	// Check if user owns this job
+	if job.CreatedBy != userID {
+		// Check if user is admin
+		isAdmin, exists := c.Get("isAdmin")
+		if !exists || isAdmin != true {
+			c.String(http.StatusForbidden, "You don't have permission to download this manifest")
+			return
+		}
+	}
+
+	if jobHistory.Manifest == "" {
+		h.HandleNotFound(c, "Manifest not found", "This run did not create a manifest")
+		return
+	}
+
+	filename := jobHistory.Manifest
+	if c.Query("signature") == "true" {
+		filename += scheduler.ManifestSignatureExtension
+	}
+	filePath := filepath.Join(h.DB.GetRunManifestDir(&jobHistory), filepath.Base(filename))
+	if _, err := os.Stat(filePath); os.IsNotExist(err) {
+		h.HandleNotFound(c, "Manifest not found", "The manifest of this run is no longer available")
+		return
+	}
+
+	// Serve the file for download
+	c.Header("Content-Description", "File Transfer")
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s", filepath.Base(filename)))
+	c.Header("Content-Type", "application/octet-stream")
+	c.File(filePath)
+}
+
 // HandleDuplicateJob handles duplication of a job
 func (h *Handlers) HandleDuplicateJob(c *gin.Context) {
 	// Get the job ID from the URL
@@ -9,11 +9,14 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/gin-contrib/sessions"
 	"github.com/gin-gonic/gin"
+	"github.com/starfleetcptn/gomft/internal/db"
+	"github.com/starfleetcptn/gomft/internal/oauth"
 )
 
 // GoogleDriveAuthHandler initiates the Google Drive OAuth flow
@@ -237,3 +240,197 @@ func extractToken(configData string) string {
 	}
 	return ""
 }
+
+// oauthSideLabels names the sides of a config in messages
+var oauthSideLabels = map[string]string{"source": "source", "dest": "destination"}
+
+// oauthCallbackPath returns the path of the OAuth callback of a provider type. Google keeps
+// the callback path registered in existing Google API projects.
+func oauthCallbackPath(providerType string) string {
+	if providerType == "gdrive" || providerType == "gphotos" {
+		return "/configs/gdrive-callback"
+	}
+	return "/configs/oauth-callback"
+}
+
+// requestBaseURL returns the external URL of GoMFT used in redirect URIs
+func requestBaseURL(c *gin.Context) string {
+	baseURL := os.Getenv("BASE_URL")
+	if baseURL == "" {
+		// Try to detect the base URL from the request
+		scheme := "http"
+		if c.Request.TLS != nil {
+			scheme = "https"
+		}
+		baseURL = fmt.Sprintf("%s://%s", scheme, c.Request.Host)
+	}
+	return strings.TrimSuffix(baseURL, "/")
+}
+
+// HandleOAuthAuthorize handles the GET /configs/:id/oauth/:side route
+func (h *Handlers) HandleOAuthAuthorize(c *gin.Context) {
+	configID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		RenderErrorPage(c, "Invalid configuration ID", err.Error())
+		return
+	}
+
+	config, err := h.DB.GetTransferConfig(uint(configID))
+	if err != nil {
+		RenderErrorPage(c, "Configuration not found", err.Error())
+		return
+	}
+
+	side := c.Param("side")
+	if _, ok := oauthSideLabels[side]; !ok {
+		RenderErrorPage(c, "Invalid side", "The side to authorize must be source or dest")
+		return
+	}
+
+	h.startOAuth(c, config, side)
+}
+
+// startOAuth redirects the user to the consent page of the OAuth provider of a side of a config
+func (h *Handlers) startOAuth(c *gin.Context, config *db.TransferConfig, side string) {
+	providerType := config.SideType(side)
+	provider, ok := oauth.Get(providerType)
+	if !ok {
+		RenderErrorPage(c, "Not an OAuth configuration", fmt.Sprintf("The %s of the configuration is not authorized with OAuth", oauthSideLabels[side]))
+		return
+	}
+
+	client := h.DB.GetOAuthClient(config, side)
+	if client.ID == "" || client.Secret == "" {
+		RenderErrorPage(c, "Missing OAuth client",
+			fmt.Sprintf("Enter the client ID and secret of your %s app in the configuration, or configure a default client in the environment", provider.Name()))
+		return
+	}
+
+	driveID, driveType := config.DestDriveID, config.DestDriveType
+	if side == "source" {
+		driveID, driveType = config.SourceDriveID, config.SourceDriveType
+	}
+	if providerType == "onedrive" && driveType == oauth.DriveTypeDocumentLibrary && driveID == "" {
+		RenderErrorPage(c, "Missing drive ID", "Enter the drive ID of the SharePoint document library in the configuration")
+		return
+	}
+
+	// Generate state parameter for CSRF protection
+	state, err := generateResetToken(32)
+	if err != nil {
+		RenderErrorPage(c, "Failed to start authorization", err.Error())
+		return
+	}
+
+	// Store the state and the side being authorized for the callback
+	c.SetCookie("oauth_state", state, 3600, "/", "", false, true)
+	c.SetCookie("oauth_config_id", fmt.Sprintf("%d", config.ID), 3600, "/", "", false, true)
+	c.SetCookie("oauth_side", side, 3600, "/", "", false, true)
+
+	redirectURI := requestBaseURL(c) + oauthCallbackPath(providerType)
+	c.Redirect(http.StatusFound, provider.AuthCodeURL(client, redirectURI, state))
+}
+
+// HandleOAuthCallback handles the GET /configs/oauth-callback route
+func (h *Handlers) HandleOAuthCallback(c *gin.Context) {
+	if errorCode := c.Query("error"); errorCode != "" {
+		details := c.Query("error_description")
+		if details == "" {
+			details = errorCode
+		}
+		RenderErrorPage(c, "Authorization failed", details)
+		return
+	}
+
+	authCode := c.Query("code")
+	if authCode == "" {
+		RenderErrorPage(c, "Authorization failed", "No authorization code received")
+		return
+	}
+
+	// Verify state parameter to prevent CSRF
+	storedState, err := c.Cookie("oauth_state")
+	if err != nil || storedState == "" || c.Query("state") != storedState {
+		RenderErrorPage(c, "Authorization failed", "Invalid state parameter")
+		return
+	}
+
+	configIDStr, err := c.Cookie("oauth_config_id")
+	if err != nil {
+		RenderErrorPage(c, "Session expired", "The authorization session has expired")
+		return
+	}
+	configID, err := strconv.ParseUint(configIDStr, 10, 64)
+	if err != nil {
+		RenderErrorPage(c, "Invalid configuration ID", err.Error())
+		return
+	}
+	side, err := c.Cookie("oauth_side")
+	if _, ok := oauthSideLabels[side]; err != nil || !ok {
+		RenderErrorPage(c, "Session expired", "The authorization session has expired")
+		return
+	}
+
+	config, err := h.DB.GetTransferConfig(uint(configID))
+	if err != nil {
+		RenderErrorPage(c, "Failed to get configuration", err.Error())
+		return
+	}
+	providerType := config.SideType(side)
+	provider, ok := oauth.Get(providerType)
+	if !ok {
+		RenderErrorPage(c, "Not an OAuth configuration", fmt.Sprintf("The %s of the configuration is not authorized with OAuth", oauthSideLabels[side]))
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 30*time.Second)
+	defer cancel()
+
+	redirectURI := requestBaseURL(c) + oauthCallbackPath(providerType)
+	token, err := provider.Exchange(ctx, h.DB.GetOAuthClient(config, side), redirectURI, authCode)
+	if err != nil {
+		RenderErrorPage(c, "Failed to exchange authorization code for token", err.Error())
+		return
+	}
+
+	// Point the remote at the selected drive of the account
+	if resolver, ok := provider.(oauth.DriveResolver); ok {
+		driveID, driveType := &config.DestDriveID, &config.DestDriveType
+		if side == "source" {
+			driveID, driveType = &config.SourceDriveID, &config.SourceDriveType
+		}
+		drive, err := resolver.ResolveDrive(ctx, token, *driveID)
+		if err == nil {
+			err = oauth.CheckDriveType(*driveType, drive)
+		}
+		if err != nil {
+			RenderErrorPage(c, "Failed to select the drive", err.Error())
+			return
+		}
+		*driveID, *driveType = drive.ID, drive.Type
+		if err := h.DB.UpdateTransferConfig(config); err != nil {
+			RenderErrorPage(c, "Failed to update configuration", err.Error())
+			return
+		}
+	}
+
+	if err := h.DB.StoreOAuthToken(config, side, token.JSON()); err != nil {
+		RenderErrorPage(c, "Failed to store token", err.Error())
+		return
+	}
+
+	// Clear cookies
+	c.SetCookie("oauth_state", "", -1, "/", "", false, true)
+	c.SetCookie("oauth_config_id", "", -1, "/", "", false, true)
+	c.SetCookie("oauth_side", "", -1, "/", "", false, true)
+
+	// Redirect to the config list with a success message
+	status := "oauth_auth_success"
+	switch providerType {
+	case "gdrive":
+		status = "gdrive_auth_success"
+	case "gphotos":
+		status = "gphotos_auth_success"
+	}
+	c.Redirect(http.StatusFound, fmt.Sprintf("/configs?status=%s", status))
+}
@@ -26,6 +26,10 @@ func (h *Handlers) RegisterRoutes(router *gin.Engine) {
 	router.GET("/auth/provider/:id", h.HandleAuthProviderInit)
 	router.GET("/auth/callback", h.HandleAuthProviderCallback)
 
+	// AS2 endpoint, partners authenticate with their certificates
+	router.GET("/as2", h.HandleAS2Probe)
+	router.POST("/as2", h.HandleAS2Receive)
+
 	// Protected routes
 	authorized := router.Group("/")
 	authorized.Use(h.AuthMiddleware())
@@ -57,7 +61,10 @@ func (h *Handlers) RegisterRoutes(router *gin.Engine) {
 		authorized.POST("/configs/:id", h.HandleUpdateConfig)
 		authorized.DELETE("/configs/:id", h.HandleDeleteConfig)
 		authorized.POST("/configs/:id/duplicate", h.HandleDuplicateConfig)
+		authorized.POST("/configs/:id/bisync/reset", h.HandleResetBisyncState)
 		authorized.POST("/configs/test-connection", h.HandleTestProviderConnection)
+		authorized.POST("/configs/test-filters", h.HandleTestFilters)
+		authorized.POST("/configs/preview-output-pattern", h.HandlePreviewOutputPattern)
 
 		// Path validation endpoint
 		authorized.GET("/check-path", h.HandleCheckPath)
@@ -67,6 +74,10 @@ func (h *Handlers) RegisterRoutes(router *gin.Engine) {
 		authorized.GET("/configs/gdrive-callback", h.HandleGDriveAuthCallback)
 		authorized.GET("/configs/gdrive-token", h.HandleGDriveTokenProcess)
 
+		// OAuth authorization routes of OneDrive, Dropbox, Box and Google
+		authorized.GET("/configs/:id/oauth/:side", h.HandleOAuthAuthorize)
+		authorized.GET("/configs/oauth-callback", h.HandleOAuthCallback)
+
 		// Duplicate rclone endpoints for web interface to access - same path as API
 		rcloneHandler := NewRcloneHandler(h.DB)
 		authorized.GET("api/rclone/commands", func(c *gin.Context) { rcloneHandler.RcloneCommandOptions(c) })
@@ -84,12 +95,14 @@ func (h *Handlers) RegisterRoutes(router *gin.Engine) {
 		authorized.POST("/jobs/:id/run", h.HandleRunJob)
 		authorized.GET("/history", h.HandleHistory)
 		authorized.GET("/job-runs/:id", h.HandleJobRunDetails)
+		authorized.POST("/job-runs/:id/resume", h.HandleResumeJobRun)
+		authorized.GET("/job-runs/:id/manifest", h.HandleDownloadRunManifest)
 		authorized.GET("/profile", h.HandleProfile)
 		authorized.POST("/profile/theme", h.HandleUpdateTheme)
 		authorized.POST("/logout", h.HandleLogout)
 
 		// File metadata routes
-		fileMetadataHandler := &FileMetadataHandler{DB: h.DB}
+		fileMetadataHandler := &FileMetadataHandler{DB: h.DB, Scheduler: h.Scheduler}
 		fileGroup := authorized.Group("/files")
 		fileGroup.GET("", fileMetadataHandler.ListFileMetadata)
 		fileGroup.GET("/:id", fileMetadataHandler.GetFileMetadataDetails)
@@ -97,6 +110,7 @@ func (h *Handlers) RegisterRoutes(router *gin.Engine) {
 		fileGroup.GET("/search", fileMetadataHandler.SearchFileMetadata)
 		fileGroup.GET("/search/partial", fileMetadataHandler.HandleFileMetadataSearchPartial)
 		fileGroup.DELETE("/:id", fileMetadataHandler.DeleteFileMetadata)
+		fileGroup.POST("/:id/release", fileMetadataHandler.ReleaseQuarantinedFile)
 		fileGroup.GET("/partial", fileMetadataHandler.HandleFileMetadataPartial)
 
 		// AJAX routes for dashboard
@@ -177,6 +191,33 @@ func (h *Handlers) RegisterRoutes(router *gin.Engine) {
 			authProviderGroup.DELETE("/:id", h.HandleDeleteAuthProvider)
 			authProviderGroup.POST("/:id/test", h.HandleTestAuthProviderConnection)
 
+			// Key store routes
+			settingsGroup.GET("/encryption-keys", h.HandleEncryptionKeys)
+			settingsGroup.POST("/encryption-keys", h.HandleCreateEncryptionKey)
+			settingsGroup.DELETE("/encryption-keys/:id", h.HandleDeleteEncryptionKey)
+
+			// Pinned SSH host key routes
+			settingsGroup.GET("/host-keys", h.HandleHostKeys)
+			settingsGroup.POST("/host-keys/:id/scan", h.HandleScanHostKey)
+			settingsGroup.POST("/host-keys/:id/rotate", h.HandleRotateHostKey)
+			settingsGroup.DELETE("/host-keys/:id", h.HandleDeleteHostKey)
+
+			// SSH key store routes
+			settingsGroup.GET("/ssh-keys", h.HandleSSHKeys)
+			settingsGroup.POST("/ssh-keys", h.HandleCreateSSHKey)
+			settingsGroup.GET("/ssh-keys/:id/public", h.HandleDownloadSSHPublicKey)
+			settingsGroup.DELETE("/ssh-keys/:id", h.HandleDeleteSSHKey)
+
+			// AS2 partner routes
+			as2Group := settingsGroup.Group("/as2")
+			as2Group.GET("", h.HandleAS2Partners)
+			as2Group.GET("/new", h.HandleNewAS2Partner)
+			as2Group.POST("", h.HandleCreateAS2Partner)
+			as2Group.GET("/:id/edit", h.HandleEditAS2Partner)
+			as2Group.POST("/:id", h.HandleUpdateAS2Partner)
+			as2Group.DELETE("/:id", h.HandleDeleteAS2Partner)
+			as2Group.GET("/:id/certificate", h.HandleDownloadAS2Certificate)
+
 			// Notification routes
 			settingsGroup.GET("/notifications", h.HandleNotificationsPage)
 			settingsGroup.GET("/notifications/new", h.HandleNewNotificationPage)
@@ -0,0 +1,188 @@
+package handlers
+
+// Key store handlers for the SSH key pairs of SFTP remotes
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/starfleetcptn/gomft/components"
+	"github.com/starfleetcptn/gomft/internal/auth"
+	"github.com/starfleetcptn/gomft/internal/db"
+	"github.com/starfleetcptn/gomft/internal/sshkeys"
+)
+
+// unsafeFileNameChars matches the characters replaced in the name of a downloaded public key
+var unsafeFileNameChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// HandleSSHKeys renders the SSH key store page
+func (h *Handlers) HandleSSHKeys(c *gin.Context) {
+	keys, err := h.DB.GetSSHKeys()
+	if err != nil {
+		h.HandleServerError(c, err)
+		return
+	}
+
+	components.SSHKeys(c.Request.Context(), keys).Render(c, c.Writer)
+}
+
+// HandleCreateSSHKey generates a key pair or imports a private key into the SSH key store
+func (h *Handlers) HandleCreateSSHKey(c *gin.Context) {
+	userID := c.GetUint("userID")
+	if userID == 0 {
+		h.HandleUnauthorized(c)
+		return
+	}
+
+	name := strings.TrimSpace(c.PostForm("name"))
+	if name == "" {
+		h.HandleBadRequest(c, "Invalid Key", "A name is required")
+		return
+	}
+	if _, err := h.DB.GetSSHKeyByName(name); err == nil {
+		h.HandleBadRequest(c, "Invalid Key", fmt.Sprintf("An SSH key named '%s' already exists", name))
+		return
+	}
+
+	var pair *sshkeys.KeyPair
+	var err error
+	generated := c.PostForm("mode") != "import"
+	if generated {
+		bits, _ := strconv.Atoi(c.PostForm("bits"))
+		pair, err = sshkeys.Generate(c.PostForm("type"), bits, name)
+	} else {
+		pair, err = sshkeys.Import(c.PostForm("private_key"), c.PostForm("passphrase"), name)
+	}
+	if err != nil {
+		h.HandleBadRequest(c, "Invalid Key", err.Error())
+		return
+	}
+
+	key := &db.SSHKey{
+		Name:        name,
+		Type:        pair.Type,
+		Fingerprint: pair.Fingerprint,
+		PublicKey:   pair.PublicKey,
+		Generated:   generated,
+		CreatedBy:   userID,
+	}
+
+	// Private key material is only stored encrypted
+	if key.PrivateKey, err = auth.EncryptKeyMaterial(pair.PrivateKey); err != nil {
+		h.HandleServerError(c, err)
+		return
+	}
+
+	if err := h.DB.CreateSSHKey(key); err != nil {
+		h.HandleServerError(c, err)
+		return
+	}
+
+	action := "import"
+	if generated {
+		action = "generate"
+	}
+	auditLog := db.AuditLog{
+		Action:     action,
+		EntityType: "ssh_key",
+		EntityID:   key.ID,
+		UserID:     userID,
+		Details: map[string]interface{}{
+			"name":        key.Name,
+			"type":        key.Type,
+			"fingerprint": key.Fingerprint,
+		},
+		Timestamp: time.Now(),
+	}
+	if err := h.DB.Create(&auditLog).Error; err != nil {
+		log.Printf("Error creating audit log: %v", err)
+	}
+
+	// Set success flash and redirect
+	c.SetCookie("flash_message", fmt.Sprintf("SSH key '%s' added to the key store", key.Name), 3600, "/", "", false, true)
+	c.SetCookie("flash_type", "success", 3600, "/", "", false, true)
+
+	c.Redirect(http.StatusFound, "/admin/settings/ssh-keys")
+}
+
+// HandleDownloadSSHPublicKey sends the public key of an SSH key in authorized_keys format,
+// ready to be installed on a partner's server
+func (h *Handlers) HandleDownloadSSHPublicKey(c *gin.Context) {
+	keyID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		h.HandleBadRequest(c, "Invalid Key", "Invalid key ID")
+		return
+	}
+
+	key, err := h.DB.GetSSHKey(uint(keyID))
+	if err != nil {
+		h.HandleNotFound(c, "SSH Key Not Found", "The requested SSH key does not exist")
+		return
+	}
+
+	fileName := unsafeFileNameChars.ReplaceAllString(key.Name, "_") + ".pub"
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", fileName))
+	c.String(http.StatusOK, key.PublicKey+"\n")
+}
+
+// HandleDeleteSSHKey removes a key pair from the SSH key store
+func (h *Handlers) HandleDeleteSSHKey(c *gin.Context) {
+	userID := c.GetUint("userID")
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	keyID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid key ID"})
+		return
+	}
+
+	key, err := h.DB.GetSSHKey(uint(keyID))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "SSH key not found"})
+		return
+	}
+
+	// Keys that are still referenced by a config cannot be removed
+	count, err := h.DB.CountConfigsUsingSSHKey(key.Name)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check if key is in use"})
+		return
+	}
+	if count > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": fmt.Sprintf("Cannot delete SSH key '%s' because it is used by %d transfer configurations", key.Name, count),
+		})
+		return
+	}
+
+	if err := h.DB.DeleteSSHKey(key.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete SSH key"})
+		return
+	}
+
+	auditLog := db.AuditLog{
+		Action:     "delete",
+		EntityType: "ssh_key",
+		EntityID:   key.ID,
+		UserID:     userID,
+		Details: map[string]interface{}{
+			"name":        key.Name,
+			"type":        key.Type,
+			"fingerprint": key.Fingerprint,
+		},
+		Timestamp: time.Now(),
+	}
+	if err := h.DB.Create(&auditLog).Error; err != nil {
+		log.Printf("Error creating audit log: %v", err)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": fmt.Sprintf("SSH key '%s' deleted", key.Name)})
+}
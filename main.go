@@ -142,6 +142,13 @@ func main() {
 		log.Printf("Admin role assigned to admin user successfully")
 	}
 
+	// Runs still marked as running were interrupted when the application stopped
+	if count, err := database.MarkInterruptedJobHistories(); err != nil {
+		log.Printf("Warning: Failed to mark interrupted runs: %v", err)
+	} else if count > 0 {
+		log.Printf("Marked %d interrupted runs; they can be resumed from their checkpoints", count)
+	}
+
 	// Initialize scheduler components
 	log.Printf("Initializing scheduler components...")
 	schedLogger := scheduler.NewLogger()                                    // Create the scheduler's logger